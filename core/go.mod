@@ -1,27 +1,7 @@
-
-Go · MOD
-Copy
-
 module github.com/google-dork-parser/core
 
 go 1.21
 
-require (
-	github.com/go-rod/rod v0.116.0
-	github.com/go-rod/stealth v0.4.9
-	github.com/valyala/fasthttp v1.51.0
-	golang.org/x/net v0.19.0
-	golang.org/x/sync v0.5.0
-)
+require golang.org/x/net v0.19.0
 
-require (
-	github.com/andybalholm/brotli v1.0.6 // indirect
-	github.com/klauspost/compress v1.17.4 // indirect
-	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	github.com/ysmood/fetchup v0.2.4 // indirect
-	github.com/ysmood/goob v0.4.0 // indirect
-	github.com/ysmood/got v0.39.4 // indirect
-	github.com/ysmood/gson v0.7.3 // indirect
-	github.com/ysmood/leakless v0.8.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
-)
+require golang.org/x/text v0.14.0 // indirect
@@ -0,0 +1,315 @@
+// Package dispatch runs the controller's messages on the search engines:
+// an init message builds and selects the engines, and each task message
+// runs on one of them.
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/engine"
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/protocol"
+	"github.com/google-dork-parser/core/internal/proxy"
+)
+
+// defaultTimeout bounds a request when the init message sets no timeout
+const defaultTimeout = 30 * time.Second
+
+// Dispatcher holds the engines an init message configured and runs tasks
+// on them
+type Dispatcher struct {
+	registry *engine.Registry
+	balancer *engine.Balancer // Picks the engine of tasks that name none
+	failover *engine.Failover // Moves blocked tasks to the next engine
+	fanOut   *engine.FanOut   // Runs fan-out tasks on every engine at once
+	timeout  time.Duration
+
+	// exclusions is shared by every engine's extractor, so an edit
+	// applies to all of them
+	exclusions *parser.ExclusionList
+}
+
+// New builds every implemented engine from an init message's config and
+// enables the ones it selects, google when it selects none. It fails when
+// a selected engine has no implementation or the exclusion file cannot be
+// read.
+func New(config protocol.EngineConfig) (*Dispatcher, error) {
+	return build(config, engineBuilders)
+}
+
+// build is New with the engines made by builders
+func build(config protocol.EngineConfig, builders map[engine.EngineType]engineBuilder) (*Dispatcher, error) {
+	exclusions := parser.NewExclusionList(config.ExcludedDomains...)
+	if config.ExclusionFile != "" {
+		if _, err := exclusions.LoadFile(config.ExclusionFile); err != nil {
+			return nil, err
+		}
+	}
+
+	registry := engine.NewRegistry()
+	for engineType, newEngine := range builders {
+		e := newEngine(config)
+		if extracting, ok := e.(interface{ GetExtractor() *parser.Extractor }); ok {
+			extracting.GetExtractor().SetExclusions(exclusions)
+		}
+		registry.Register(engineType, e)
+	}
+
+	selected := config.Engines
+	if len(selected) == 0 && config.Engine != "" {
+		selected = []protocol.Engine{config.Engine}
+	}
+	if len(selected) == 0 {
+		selected = []protocol.Engine{protocol.EngineGoogle}
+	}
+	enabled := make(map[engine.EngineType]bool)
+	for _, name := range selected {
+		if _, ok := registry.Get(engine.EngineType(name)); !ok {
+			return nil, fmt.Errorf("engine %q is not implemented", name)
+		}
+		enabled[engine.EngineType(name)] = true
+	}
+	for name, weight := range config.Weights {
+		engineConfig, ok := registry.GetConfig(engine.EngineType(name))
+		if !ok {
+			return nil, fmt.Errorf("weight for unknown engine %q", name)
+		}
+		engineConfig.Weight = weight
+		registry.SetConfig(engine.EngineType(name), engineConfig)
+	}
+	failover := engine.DefaultFailoverConfig()
+	if len(config.Failover) > 0 {
+		failover.Order = nil
+		for _, name := range config.Failover {
+			if _, ok := registry.Get(engine.EngineType(name)); !ok {
+				return nil, fmt.Errorf("failover engine %q is not implemented", name)
+			}
+			failover.Order = append(failover.Order, engine.EngineType(name))
+		}
+	}
+	if config.FailoverAttempts > 0 {
+		failover.Attempts = config.FailoverAttempts
+	}
+
+	for _, engineType := range registry.List() {
+		if enabled[engineType] {
+			registry.Enable(engineType)
+		} else {
+			registry.Disable(engineType)
+		}
+	}
+
+	timeout := time.Duration(config.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	balancer := engine.NewBalancer(registry)
+	return &Dispatcher{
+		registry: registry,
+		balancer: balancer,
+		failover: engine.NewFailover(registry, balancer, failover),
+		fanOut:   engine.NewFanOut(registry, balancer),
+		timeout:  timeout,
+
+		exclusions: exclusions,
+	}, nil
+}
+
+// Registry returns the dispatcher's engines
+func (d *Dispatcher) Registry() *engine.Registry {
+	return d.registry
+}
+
+// Exclusions returns the domains dropped from every engine's results
+func (d *Dispatcher) Exclusions() *parser.ExclusionList {
+	return d.exclusions
+}
+
+// AddExclusions drops the domains and their subdomains from every engine's
+// results, returning how many were not already dropped
+func (d *Dispatcher) AddExclusions(domains []string) int {
+	return d.exclusions.Add(domains...)
+}
+
+// RemoveExclusions stops dropping the domains. The ones that can be are
+// removed even when others fail; the error names each that could not be,
+// as base or never excluded.
+func (d *Dispatcher) RemoveExclusions(domains []string) error {
+	var errs []error
+	for _, domain := range domains {
+		if err := d.exclusions.Remove(domain); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Task runs a task on the engine it names, or one the balancer picks from
+// the enabled engines when it names none; when that engine keeps blocking
+// it, the task fails over to the next enabled engine of the failover
+// chain. It returns the message answering the task: a ResultMessage
+// naming the engine that answered, or a BlockedMessage or ErrorMessage
+// when it failed. Fan-out tasks run on every weighted engine instead.
+func (d *Dispatcher) Task(ctx context.Context, task *protocol.TaskMessage) interface{} {
+	request, err := d.searchRequest(task)
+	if err != nil {
+		return errorMessage(task.TaskID, "invalid_task", err)
+	}
+	if task.FanOut {
+		return d.fanOutTask(ctx, task, request)
+	}
+
+	engineType, _, ok := d.balancer.Pick(engine.EngineType(task.Engine))
+	if !ok {
+		return errorMessage(task.TaskID, "unknown_engine", fmt.Errorf("engine %q is not implemented", task.Engine))
+	}
+
+	start := time.Now()
+	response, err := d.failover.Search(ctx, engineType, request, nil)
+	return reply(task, response, err, time.Since(start))
+}
+
+// fanOutTask runs a task on every fan-out engine and answers it with their
+// merged URLs, best first
+func (d *Dispatcher) fanOutTask(ctx context.Context, task *protocol.TaskMessage, request *engine.SearchRequest) interface{} {
+	start := time.Now()
+	response, err := d.fanOut.Search(ctx, request, nil)
+	if err != nil {
+		return errorMessage(task.TaskID, "search_failed", err)
+	}
+
+	msg := &protocol.ResultMessage{
+		BaseMessage: protocol.NewBaseMessage(protocol.MsgTypeResult),
+		TaskID:      task.TaskID,
+		Dork:        task.Dork,
+		Page:        task.Page,
+		URLs:        response.URLs(),
+		TimeTaken:   time.Since(start).Milliseconds(),
+	}
+	msg.ID = task.ID
+	for _, result := range response.Results {
+		sources := make([]protocol.Engine, len(result.Engines))
+		for i, engineType := range result.Engines {
+			sources[i] = protocol.Engine(engineType)
+		}
+		msg.Sources = append(msg.Sources, sources)
+	}
+	for _, engineResponse := range response.Responses {
+		if engineResponse.Error == nil && engineResponse.HasNextPage {
+			msg.HasNextPage = true
+		}
+	}
+	return msg
+}
+
+// searchRequest converts a task message into an engine search request
+func (d *Dispatcher) searchRequest(task *protocol.TaskMessage) (*engine.SearchRequest, error) {
+	request := &engine.SearchRequest{
+		ID:           task.TaskID,
+		Dork:         task.Dork,
+		Page:         task.Page,
+		Headers:      task.Headers,
+		Timeout:      d.timeout,
+		Continuation: task.Continuation,
+		Options: engine.SearchOptions{
+			Type:        engine.SearchType(task.SearchType),
+			TimeRange:   engine.TimeRange(task.TimeRange),
+			Verbatim:    task.Verbatim,
+			Filter:      task.Filter,
+			OrganicOnly: task.OrganicOnly,
+		},
+	}
+
+	if task.Proxy != "" {
+		p, err := proxy.ParseProxy(task.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		request.Proxy = p
+	}
+
+	var err error
+	if task.DateFrom != "" {
+		if request.Options.DateFrom, err = time.Parse("2006-01-02", task.DateFrom); err != nil {
+			return nil, fmt.Errorf("invalid date_from: %w", err)
+		}
+	}
+	if task.DateTo != "" {
+		if request.Options.DateTo, err = time.Parse("2006-01-02", task.DateTo); err != nil {
+			return nil, fmt.Errorf("invalid date_to: %w", err)
+		}
+	}
+	return request, nil
+}
+
+// reply converts a search's outcome into the message answering its task
+func reply(task *protocol.TaskMessage, response *engine.SearchResponse, err error, elapsed time.Duration) interface{} {
+	if response != nil && (response.Blocked || response.Captcha) {
+		return blockedMessage(task, response, blockReason(response, err), err)
+	}
+	if errors.Is(err, engine.ErrRateLimited) {
+		return blockedMessage(task, response, protocol.BlockRateLimit, err)
+	}
+	if err != nil {
+		return errorMessage(task.TaskID, "search_failed", err)
+	}
+
+	msg := &protocol.ResultMessage{
+		BaseMessage:  protocol.NewBaseMessage(protocol.MsgTypeResult),
+		TaskID:       task.TaskID,
+		Dork:         task.Dork,
+		Page:         task.Page,
+		URLs:         response.URLs,
+		RawURLs:      response.RawURLs,
+		HasNextPage:  response.HasNextPage,
+		TimeTaken:    elapsed.Milliseconds(),
+		ProxyUsed:    response.ProxyUsed,
+		Engine:       protocol.Engine(response.EngineUsed),
+		Continuation: response.Continuation,
+	}
+	msg.ID = task.ID
+	return msg
+}
+
+// blockReason tells why an engine refused a search
+func blockReason(response *engine.SearchResponse, err error) protocol.BlockReason {
+	var searchErr *engine.SearchError
+	switch {
+	case response.Captcha:
+		return protocol.BlockCaptcha
+	case errors.As(err, &searchErr) && searchErr.Type == engine.ErrorTypeRateLimit:
+		return protocol.BlockRateLimit
+	default:
+		return protocol.BlockBanned
+	}
+}
+
+func blockedMessage(task *protocol.TaskMessage, response *engine.SearchResponse, reason protocol.BlockReason, err error) *protocol.BlockedMessage {
+	msg := &protocol.BlockedMessage{
+		BaseMessage: protocol.NewBaseMessage(protocol.MsgTypeBlocked),
+		TaskID:      task.TaskID,
+		Dork:        task.Dork,
+		Reason:      reason,
+	}
+	msg.ID = task.ID
+	if response != nil {
+		msg.Proxy = response.ProxyUsed
+	}
+	if err != nil {
+		msg.Detail = err.Error()
+	}
+	return msg
+}
+
+func errorMessage(taskID, code string, err error) *protocol.ErrorMessage {
+	return &protocol.ErrorMessage{
+		BaseMessage: protocol.NewBaseMessage(protocol.MsgTypeError),
+		TaskID:      taskID,
+		Code:        code,
+		Message:     err.Error(),
+	}
+}
@@ -0,0 +1,393 @@
+package dispatch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google-dork-parser/core/internal/engine"
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/protocol"
+)
+
+// fakeEngine answers every search with the same URLs, or blocks it
+type fakeEngine struct {
+	name     string
+	urls     []string
+	blocked  bool
+	searches int32
+}
+
+func (f *fakeEngine) Name() string { return f.name }
+
+func (f *fakeEngine) Search(ctx context.Context, request *engine.SearchRequest) (*engine.SearchResponse, error) {
+	atomic.AddInt32(&f.searches, 1)
+	response := &engine.SearchResponse{
+		RequestID:  request.ID,
+		Dork:       request.Dork,
+		Page:       request.Page,
+		EngineUsed: f.name,
+	}
+	if f.blocked {
+		response.Blocked = true
+		response.Error = engine.NewSearchError(engine.ErrorTypeBlocked, "blocked by "+f.name, nil)
+		return response, response.Error
+	}
+	response.URLs = f.urls
+	return response, nil
+}
+
+func (f *fakeEngine) BuildURL(query string, page int) string { return "" }
+
+func (f *fakeEngine) ParseResponse(html string) *parser.ExtractionResult {
+	return &parser.ExtractionResult{}
+}
+
+func (f *fakeEngine) IsBlocked(html string) bool { return false }
+func (f *fakeEngine) IsCaptcha(html string) bool { return false }
+func (f *fakeEngine) GetDomains() []string       { return nil }
+
+// newTestDispatcher returns a dispatcher for config whose engines of the
+// given types are fakes, implemented or not
+func newTestDispatcher(t *testing.T, config protocol.EngineConfig, fakes ...*fakeEngine) *Dispatcher {
+	t.Helper()
+	builders := make(map[engine.EngineType]engineBuilder)
+	for engineType, newEngine := range engineBuilders {
+		builders[engineType] = newEngine
+	}
+	for _, fake := range fakes {
+		fake := fake
+		builders[engine.EngineType(fake.name)] = func(protocol.EngineConfig) engine.Engine { return fake }
+	}
+
+	d, err := build(config, builders)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	return d
+}
+
+func TestNewSelectsEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  protocol.EngineConfig
+		enabled []engine.EngineType
+		err     bool
+	}{
+		{name: "default", enabled: []engine.EngineType{engine.EngineTypeGoogle}},
+		{
+			name:    "google",
+			config:  protocol.EngineConfig{Engine: protocol.EngineGoogle},
+			enabled: []engine.EngineType{engine.EngineTypeGoogle},
+		},
+		{
+			name:    "duckduckgo",
+			config:  protocol.EngineConfig{Engine: protocol.EngineDuckDuckGo},
+			enabled: []engine.EngineType{engine.EngineTypeDuckDuckGo},
+		},
+		{
+			name:    "yahoo",
+			config:  protocol.EngineConfig{Engine: protocol.EngineYahoo},
+			enabled: []engine.EngineType{engine.EngineTypeYahoo},
+		},
+		{
+			name:    "baidu",
+			config:  protocol.EngineConfig{Engine: protocol.EngineBaidu},
+			enabled: []engine.EngineType{engine.EngineTypeBaidu},
+		},
+		{
+			// Engines takes the place of Engine
+			name:    "engines",
+			config:  protocol.EngineConfig{Engine: protocol.EngineYahoo, Engines: []protocol.Engine{protocol.EngineBrave, protocol.EngineGoogle}},
+			enabled: []engine.EngineType{engine.EngineTypeBrave, engine.EngineTypeGoogle},
+		},
+		{name: "bing", config: protocol.EngineConfig{Engine: protocol.EngineBing}, err: true},
+		{name: "unknown", config: protocol.EngineConfig{Engines: []protocol.Engine{"google", "altavista"}}, err: true},
+		{name: "unknown failover", config: protocol.EngineConfig{Failover: []protocol.Engine{"google", "altavista"}}, err: true},
+		{name: "unknown weight", config: protocol.EngineConfig{Weights: map[protocol.Engine]float64{"altavista": 1}}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(tt.config)
+			if tt.err {
+				if err == nil {
+					t.Fatal("New succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if got := d.registry.GetEnabledTypes(); !reflect.DeepEqual(got, tt.enabled) {
+				t.Errorf("enabled engines = %v, want %v", got, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestTaskEngine(t *testing.T) {
+	google := &fakeEngine{name: "google", urls: []string{"https://a.example/"}}
+	yahoo := &fakeEngine{name: "yahoo", urls: []string{"https://b.example/"}}
+	d := newTestDispatcher(t, protocol.EngineConfig{Engine: protocol.EngineYahoo}, google, yahoo)
+
+	tests := []struct {
+		name   string
+		engine string
+		want   protocol.Engine
+	}{
+		{name: "selected", want: protocol.EngineYahoo},
+		{name: "named", engine: "google", want: protocol.EngineGoogle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t1", Dork: "inurl:x", Engine: tt.engine})
+			result, ok := msg.(*protocol.ResultMessage)
+			if !ok {
+				t.Fatalf("got %#v, want a result", msg)
+			}
+			if result.Engine != tt.want {
+				t.Errorf("engine = %q, want %q", result.Engine, tt.want)
+			}
+		})
+	}
+
+	msg := d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t2", Engine: "ask"})
+	if e, ok := msg.(*protocol.ErrorMessage); !ok || e.Code != "unknown_engine" {
+		t.Errorf("task on ask = %#v, want an unknown_engine error", msg)
+	}
+}
+
+func TestTaskBalanced(t *testing.T) {
+	google := &fakeEngine{name: "google"}
+	yahoo := &fakeEngine{name: "yahoo"}
+	brave := &fakeEngine{name: "brave"}
+	d := newTestDispatcher(t, protocol.EngineConfig{
+		Engines: []protocol.Engine{protocol.EngineGoogle, protocol.EngineYahoo, protocol.EngineBrave},
+		Weights: map[protocol.Engine]float64{protocol.EngineGoogle: 3, protocol.EngineYahoo: 1, protocol.EngineBrave: 0},
+	}, google, yahoo, brave)
+
+	const tasks = 400
+	for i := 0; i < tasks; i++ {
+		d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t", Dork: "inurl:x"})
+	}
+
+	// Weights of 3 and 1 give google three tasks in four, and brave, of
+	// weight 0, none
+	if n := atomic.LoadInt32(&google.searches); n < tasks*6/10 || n > tasks*9/10 {
+		t.Errorf("google ran %d of %d tasks, want about 3/4", n, tasks)
+	}
+	if n := atomic.LoadInt32(&brave.searches); n != 0 {
+		t.Errorf("brave ran %d tasks at weight 0", n)
+	}
+
+	d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t", Dork: "inurl:x", Engine: "brave"})
+	if n := atomic.LoadInt32(&brave.searches); n != 1 {
+		t.Errorf("brave ran %d tasks naming it, want 1", n)
+	}
+}
+
+func TestTaskBlocked(t *testing.T) {
+	google := &fakeEngine{name: "google", blocked: true}
+	d := newTestDispatcher(t, protocol.EngineConfig{FailoverAttempts: 3}, google)
+
+	msg := d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t1", Dork: "inurl:x"})
+	blocked, ok := msg.(*protocol.BlockedMessage)
+	if !ok {
+		t.Fatalf("got %#v, want a blocked message", msg)
+	}
+	if blocked.Reason != protocol.BlockBanned {
+		t.Errorf("reason = %q, want %q", blocked.Reason, protocol.BlockBanned)
+	}
+	if n := atomic.LoadInt32(&google.searches); n != 3 {
+		t.Errorf("google searched %d times, want FailoverAttempts = 3", n)
+	}
+}
+
+func TestTaskFailover(t *testing.T) {
+	google := &fakeEngine{name: "google", blocked: true}
+	yahoo := &fakeEngine{name: "yahoo", urls: []string{"https://b.example/"}}
+	brave := &fakeEngine{name: "brave", urls: []string{"https://c.example/"}}
+	d := newTestDispatcher(t, protocol.EngineConfig{
+		Engines:          []protocol.Engine{protocol.EngineGoogle, protocol.EngineYahoo, protocol.EngineBrave},
+		Failover:         []protocol.Engine{protocol.EngineGoogle, protocol.EngineYahoo, protocol.EngineBrave},
+		FailoverAttempts: 2,
+	}, google, yahoo, brave)
+
+	msg := d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t1", Dork: "inurl:x", Engine: "google"})
+	result, ok := msg.(*protocol.ResultMessage)
+	if !ok {
+		t.Fatalf("got %#v, want a result", msg)
+	}
+	if result.Engine != protocol.EngineYahoo || !reflect.DeepEqual(result.URLs, yahoo.urls) {
+		t.Errorf("result from %q with %v, want yahoo's %v", result.Engine, result.URLs, yahoo.urls)
+	}
+	if n := atomic.LoadInt32(&google.searches); n != 2 {
+		t.Errorf("google searched %d times before failing over, want 2", n)
+	}
+	if n := atomic.LoadInt32(&brave.searches); n != 0 {
+		t.Errorf("brave searched %d times after yahoo answered", n)
+	}
+}
+
+func TestTaskFanOut(t *testing.T) {
+	google := &fakeEngine{name: "google", urls: []string{"https://a.example/", "https://b.example/"}}
+	yahoo := &fakeEngine{name: "yahoo", urls: []string{"https://b.example/", "https://c.example/"}}
+	brave := &fakeEngine{name: "brave", blocked: true}
+	baidu := &fakeEngine{name: "baidu", urls: []string{"https://d.example/"}}
+	d := newTestDispatcher(t, protocol.EngineConfig{
+		Engines: []protocol.Engine{protocol.EngineGoogle, protocol.EngineYahoo, protocol.EngineBrave, protocol.EngineBaidu},
+		Weights: map[protocol.Engine]float64{protocol.EngineGoogle: 2, protocol.EngineYahoo: 1, protocol.EngineBrave: 1, protocol.EngineBaidu: 0},
+	}, google, yahoo, brave, baidu)
+
+	msg := d.Task(context.Background(), &protocol.TaskMessage{TaskID: "t1", Dork: "inurl:x", FanOut: true})
+	result, ok := msg.(*protocol.ResultMessage)
+	if !ok {
+		t.Fatalf("got %#v, want a result", msg)
+	}
+
+	// b.example, found by both engines, ranks first; baidu, of weight 0,
+	// is not asked and brave's block leaves nothing
+	wantURLs := []string{"https://b.example/", "https://a.example/", "https://c.example/"}
+	wantSources := [][]protocol.Engine{{"google", "yahoo"}, {"google"}, {"yahoo"}}
+	if !reflect.DeepEqual(result.URLs, wantURLs) {
+		t.Errorf("urls = %v, want %v", result.URLs, wantURLs)
+	}
+	if !reflect.DeepEqual(result.Sources, wantSources) {
+		t.Errorf("sources = %v, want %v", result.Sources, wantSources)
+	}
+	if n := atomic.LoadInt32(&brave.searches); n != 1 {
+		t.Errorf("brave searched %d times, want 1", n)
+	}
+	if n := atomic.LoadInt32(&baidu.searches); n != 0 {
+		t.Errorf("baidu searched %d times at weight 0", n)
+	}
+}
+
+// serveLines runs messages through a server whose dispatchers are built by
+// newDispatcher, returning the lines it wrote
+func serveLines(t *testing.T, newDispatcher func(protocol.EngineConfig) (*Dispatcher, error), messages ...string) []map[string]interface{} {
+	t.Helper()
+
+	var out strings.Builder
+	s := newServer(&out)
+	s.newDispatcher = newDispatcher
+	if err := s.serve(context.Background(), strings.NewReader(strings.Join(messages, "\n"))); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid output line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestServeInitTask(t *testing.T) {
+	yahoo := &fakeEngine{name: "yahoo", urls: []string{"https://b.example/"}}
+	newDispatcher := func(config protocol.EngineConfig) (*Dispatcher, error) {
+		return newTestDispatcher(t, config, yahoo), nil
+	}
+
+	lines := serveLines(t, newDispatcher,
+		`{"type":"task","task_id":"early","dork":"x"}`,
+		`{"type":"init","config":{"engine":"yahoo","workers":2}}`,
+		`{"type":"task","task_id":"t1","dork":"inurl:x"}`,
+		`{"type":"stop"}`,
+		`{"type":"task","task_id":"late","dork":"x"}`,
+	)
+
+	if len(lines) != 3 {
+		t.Fatalf("got %d messages, want 3: %v", len(lines), lines)
+	}
+	if lines[0]["type"] != "error" || lines[0]["code"] != "not_initialized" {
+		t.Errorf("task before init answered %v", lines[0])
+	}
+	if lines[1]["type"] != "ready" || lines[1]["max_workers"] != 2.0 {
+		t.Errorf("init answered %v", lines[1])
+	}
+	if lines[2]["type"] != "result" || lines[2]["task_id"] != "t1" || lines[2]["engine"] != "yahoo" {
+		t.Errorf("task answered %v", lines[2])
+	}
+}
+
+func TestServeExclusions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blacklist.txt")
+	if err := os.WriteFile(file, []byte("# listed\nfile.example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	s := newServer(&out)
+	send := func(line string) {
+		t.Helper()
+		out.Reset()
+		if err := s.serve(context.Background(), strings.NewReader(line)); err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	}
+
+	send(`{"type":"add_exclusion","domains":["early.example"]}`)
+	if !strings.Contains(out.String(), `"code":"not_initialized"`) {
+		t.Errorf("add_exclusion before init answered %q", out.String())
+	}
+
+	initMsg := map[string]interface{}{
+		"type":   "init",
+		"config": map[string]interface{}{"excluded_domains": []string{"preset.example"}, "exclusion_file": file},
+	}
+	send(string(protocol.MustJSON(initMsg)))
+	d := s.dispatcher
+	if d == nil {
+		t.Fatalf("init failed: %s", out.String())
+	}
+
+	// Every engine drops what the list holds, as they share it
+	for _, engineType := range d.registry.List() {
+		e, _ := d.registry.Get(engineType)
+		if extracting, ok := e.(interface{ GetExtractor() *parser.Extractor }); ok && extracting.GetExtractor().Exclusions() != d.Exclusions() {
+			t.Errorf("%s has an exclusion list of its own", engineType)
+		}
+	}
+	google, _ := d.registry.Get(engine.EngineTypeGoogle)
+	extractor := google.(interface{ GetExtractor() *parser.Extractor }).GetExtractor()
+	extract := func() []string {
+		return extractor.ExtractURLs([]string{
+			"https://preset.example/a",
+			"https://www.file.example/b",
+			"https://shop.added.example/c",
+			"https://kept.example/d",
+		}).URLs
+	}
+
+	send(`{"type":"add_exclusion","domains":["added.example"]}`)
+	if out.Len() != 0 {
+		t.Errorf("add_exclusion answered %q", out.String())
+	}
+	if urls := extract(); len(urls) != 1 || !strings.Contains(urls[0], "kept.example") {
+		t.Errorf("extracted %v, want only kept.example", urls)
+	}
+
+	// Base domains stay, and the rest of the message still applies
+	send(`{"type":"remove_exclusion","domains":["added.example","google.com","preset.example"]}`)
+	if !strings.Contains(out.String(), `"code":"exclusion_failed"`) || !strings.Contains(out.String(), "google.com") {
+		t.Errorf("removing a base domain answered %q", out.String())
+	}
+	if got, want := d.Exclusions().Custom(), []string{"file.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("excluded domains = %v, want %v", got, want)
+	}
+	if urls := extract(); len(urls) != 3 {
+		t.Errorf("extracted %v after removal, want 3 URLs", urls)
+	}
+}
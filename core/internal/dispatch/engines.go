@@ -0,0 +1,104 @@
+package dispatch
+
+import (
+	"github.com/google-dork-parser/core/internal/engine"
+	"github.com/google-dork-parser/core/internal/protocol"
+)
+
+// engineBuilder builds an engine from an init message's config
+type engineBuilder func(config protocol.EngineConfig) engine.Engine
+
+// engineBuilders holds a builder for each implemented engine; bing and ask
+// have none
+var engineBuilders = map[engine.EngineType]engineBuilder{
+	engine.EngineTypeGoogle:     newGoogle,
+	engine.EngineTypeDuckDuckGo: newDuckDuckGo,
+	engine.EngineTypeYandex:     newYandex,
+	engine.EngineTypeYahoo:      newYahoo,
+	engine.EngineTypeBrave:      newBrave,
+	engine.EngineTypeStartpage:  newStartpage,
+	engine.EngineTypeGoogleCSE:  newGoogleCSE,
+	engine.EngineTypeBingAPI:    newBingAPI,
+	engine.EngineTypeBaidu:      newBaidu,
+	engine.EngineTypeSogou:      newSogou,
+}
+
+func newGoogle(config protocol.EngineConfig) engine.Engine {
+	google := engine.DefaultGoogleConfig()
+	if len(config.GoogleDomains) > 0 {
+		google.Domains = config.GoogleDomains
+	}
+	if len(config.UserAgents) > 0 {
+		google.UserAgents = config.UserAgents
+	}
+	return engine.NewGoogle(google)
+}
+
+func newDuckDuckGo(config protocol.EngineConfig) engine.Engine {
+	duckDuckGo := engine.DefaultDuckDuckGoConfig()
+	if len(config.UserAgents) > 0 {
+		duckDuckGo.UserAgents = config.UserAgents
+	}
+	return engine.NewDuckDuckGo(duckDuckGo)
+}
+
+func newYandex(config protocol.EngineConfig) engine.Engine {
+	yandex := engine.DefaultYandexConfig()
+	if len(config.UserAgents) > 0 {
+		yandex.UserAgents = config.UserAgents
+	}
+	return engine.NewYandex(yandex)
+}
+
+func newYahoo(config protocol.EngineConfig) engine.Engine {
+	yahoo := engine.DefaultYahooConfig()
+	if len(config.UserAgents) > 0 {
+		yahoo.UserAgents = config.UserAgents
+	}
+	return engine.NewYahoo(yahoo)
+}
+
+func newBrave(config protocol.EngineConfig) engine.Engine {
+	brave := engine.DefaultBraveConfig()
+	if len(config.UserAgents) > 0 {
+		brave.UserAgents = config.UserAgents
+	}
+	return engine.NewBrave(brave)
+}
+
+func newStartpage(config protocol.EngineConfig) engine.Engine {
+	startpage := engine.DefaultStartpageConfig()
+	if len(config.UserAgents) > 0 {
+		startpage.UserAgents = config.UserAgents
+	}
+	return engine.NewStartpage(startpage)
+}
+
+func newGoogleCSE(config protocol.EngineConfig) engine.Engine {
+	googleCSE := engine.DefaultGoogleCSEConfig()
+	googleCSE.Key = config.GoogleCSEKey
+	googleCSE.CX = config.GoogleCSECX
+	return engine.NewGoogleCSE(googleCSE)
+}
+
+func newBingAPI(config protocol.EngineConfig) engine.Engine {
+	bingAPI := engine.DefaultBingAPIConfig()
+	bingAPI.Keys = config.BingAPIKeys
+	return engine.NewBingAPI(bingAPI)
+}
+
+func newBaidu(config protocol.EngineConfig) engine.Engine {
+	baidu := engine.DefaultBaiduConfig()
+	if len(config.UserAgents) > 0 {
+		baidu.UserAgents = config.UserAgents
+	}
+	return engine.NewBaidu(baidu)
+}
+
+func newSogou(config protocol.EngineConfig) engine.Engine {
+	sogou := engine.DefaultSogouConfig()
+	if len(config.UserAgents) > 0 {
+		sogou.UserAgents = config.UserAgents
+	}
+	return engine.NewSogou(sogou)
+}
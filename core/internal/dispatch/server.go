@@ -0,0 +1,179 @@
+package dispatch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/google-dork-parser/core/internal/protocol"
+)
+
+// Version is reported to the controller in the ready message
+const Version = "1.0.0"
+
+// maxMessageSize bounds one line of input
+const maxMessageSize = 1 << 20
+
+// Serve reads the controller's messages from r, one JSON object per line,
+// and writes the answers to w the same way, until a stop message or the
+// end of r. Tasks run concurrently, up to the init message's Workers at
+// once; Serve waits for them before it returns.
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	return newServer(w).serve(ctx, r)
+}
+
+// server is the state of one Serve call
+type server struct {
+	mu sync.Mutex // Serializes writes to w
+	w  io.Writer
+
+	newDispatcher func(protocol.EngineConfig) (*Dispatcher, error)
+	dispatcher    *Dispatcher
+	slots         chan struct{} // Holds a token per running task
+	tasks         sync.WaitGroup
+}
+
+func newServer(w io.Writer) *server {
+	return &server{w: w, newDispatcher: New}
+}
+
+func (s *server) serve(ctx context.Context, r io.Reader) error {
+	defer s.tasks.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		msgType, err := protocol.Parse(line)
+		if err != nil {
+			s.send(errorMessage("", "invalid_message", err))
+			continue
+		}
+		if msgType == protocol.MsgTypeStop {
+			return nil
+		}
+		s.handle(ctx, msgType, line)
+	}
+	return scanner.Err()
+}
+
+// handle answers one message
+func (s *server) handle(ctx context.Context, msgType protocol.MessageType, data []byte) {
+	switch msgType {
+	case protocol.MsgTypeInit:
+		msg, err := protocol.ParseInit(data)
+		if err != nil {
+			s.send(errorMessage("", "invalid_message", err))
+			return
+		}
+		s.init(msg)
+
+	case protocol.MsgTypeTask:
+		msg, err := protocol.ParseTask(data)
+		if err != nil {
+			s.send(errorMessage("", "invalid_message", err))
+			return
+		}
+		s.task(ctx, msg)
+
+	case protocol.MsgTypeAddExclusion, protocol.MsgTypeRemoveExclusion:
+		msg, err := protocol.ParseExclusion(data)
+		if err != nil {
+			s.send(errorMessage("", "invalid_message", err))
+			return
+		}
+		s.exclusion(msgType, msg)
+
+	default:
+		s.send(errorMessage("", "unsupported_message", fmt.Errorf("%q messages are not supported", msgType)))
+	}
+}
+
+// init builds the engines; a second init replaces them once the running
+// tasks finish
+func (s *server) init(msg *protocol.InitMessage) {
+	dispatcher, err := s.newDispatcher(msg.Config)
+	if err != nil {
+		s.send(&protocol.ErrorMessage{
+			BaseMessage: protocol.NewBaseMessage(protocol.MsgTypeError),
+			Code:        "init_failed",
+			Message:     err.Error(),
+			Fatal:       true,
+		})
+		return
+	}
+
+	workers := msg.Config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	s.tasks.Wait()
+	s.dispatcher = dispatcher
+	s.slots = make(chan struct{}, workers)
+
+	s.send(&protocol.ReadyMessage{
+		BaseMessage: protocol.NewBaseMessage(protocol.MsgTypeReady),
+		Version:     Version,
+		GoVersion:   runtime.Version(),
+		MaxWorkers:  workers,
+	})
+}
+
+// task runs a task in the background once a worker slot is free
+func (s *server) task(ctx context.Context, msg *protocol.TaskMessage) {
+	if s.dispatcher == nil {
+		s.send(errorMessage(msg.TaskID, "not_initialized", errors.New("task before init")))
+		return
+	}
+
+	dispatcher, slots := s.dispatcher, s.slots
+	slots <- struct{}{}
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		defer func() { <-slots }()
+		s.send(dispatcher.Task(ctx, msg))
+	}()
+}
+
+// exclusion edits the excluded domains; it answers only when some domain
+// could not be removed
+func (s *server) exclusion(msgType protocol.MessageType, msg *protocol.ExclusionMessage) {
+	if s.dispatcher == nil {
+		s.send(errorMessage("", "not_initialized", errors.New("exclusion before init")))
+		return
+	}
+
+	if msgType == protocol.MsgTypeAddExclusion {
+		s.dispatcher.AddExclusions(msg.Domains)
+		return
+	}
+	if err := s.dispatcher.RemoveExclusions(msg.Domains); err != nil {
+		s.send(errorMessage("", "exclusion_failed", err))
+	}
+}
+
+// send writes a message as one line
+func (s *server) send(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		data = protocol.MustJSON(errorMessage("", "internal_error", err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
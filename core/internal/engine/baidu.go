@@ -0,0 +1,264 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Baidu implements the Engine interface for Baidu, for dorks after
+// Chinese-language content. Pages are numbered by result offset with pn=.
+// Result title links go through Baidu's /link?url= redirect, whose target
+// cannot be read from the link; most results also carry their URL in an
+// mu attribute, and the rest are resolved by following the redirect.
+type Baidu struct {
+	*BaseEngine
+	headerGen *stealth.HeaderGenerator
+}
+
+// BaiduConfig holds Baidu engine configuration
+type BaiduConfig struct {
+	Domains    []string
+	Timeout    time.Duration
+	UserAgents []string
+}
+
+// DefaultBaiduConfig returns default Baidu configuration
+func DefaultBaiduConfig() BaiduConfig {
+	return BaiduConfig{
+		Domains:    []string{"www.baidu.com"},
+		Timeout:    30 * time.Second,
+		UserAgents: stealth.DefaultUserAgents(),
+	}
+}
+
+// NewBaidu creates a new Baidu search engine
+func NewBaidu(config BaiduConfig) *Baidu {
+	if len(config.Domains) == 0 {
+		config.Domains = DefaultBaiduConfig().Domains
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	b := &Baidu{
+		BaseEngine: NewBaseEngine("baidu", config.Domains),
+		headerGen:  stealth.NewHeaderGenerator(config.UserAgents),
+	}
+	b.SetDetector(b)
+	b.Use(HeaderProfile(b.headerGen), acceptChinese, UnwrapRedirects(baiduPendingRedirects), b.challenge, DecodeCJK)
+	return b
+}
+
+// Search performs a Baidu search
+func (b *Baidu) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = b.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", b.buildSearchURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "baidu",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := b.Do(ctx, request, req)
+	response.PageSize = baiduPageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := b.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// baiduPageSize is the number of results asked of a Baidu page with rn=
+const baiduPageSize = 10
+
+// BuildURL builds a Baidu search URL
+func (b *Baidu) BuildURL(query string, page int) string {
+	return b.buildSearchURL(b.selectDomain(), query, page)
+}
+
+// buildSearchURL builds a Baidu search URL; ie= tells Baidu the query is
+// UTF-8, which it would otherwise take for GBK
+func (b *Baidu) buildSearchURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("wd", query)
+	params.Set("ie", "utf-8")
+	params.Set("rn", strconv.Itoa(baiduPageSize))
+	if page > 0 {
+		params.Set("pn", strconv.Itoa(page*baiduPageSize))
+	}
+	return fmt.Sprintf("https://%s/s?%s", domain, params.Encode())
+}
+
+func (b *Baidu) selectDomain() string {
+	domains := b.GetDomains()
+	if len(domains) == 0 {
+		return "www.baidu.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// challenge reports Baidu's security check as a CAPTCHA. Baidu redirects
+// a suspect client to wappass.baidu.com and serves the check with status
+// 200, which Classify would take for an empty results page.
+func (b *Baidu) challenge(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		response := ex.Response
+		switch {
+		case b.IsCaptcha(ex.Body):
+			response.Captcha = true
+			response.Error = NewSearchError(ErrorTypeCaptcha, "baidu security check", nil)
+		case response.StatusCode == http.StatusForbidden || b.IsBlocked(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "blocked by baidu", nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+var (
+	// Results are c-container blocks: "result" for web pages, "result-op"
+	// for Baidu's own cards, which may still link out
+	baiduItemPattern = regexp.MustCompile(`<div[^>]+class="[^"]*\bc-container\b[^"]*"`)
+
+	// A result's own URL, written in its mu attribute
+	baiduDirectPattern = regexp.MustCompile(`^<div[^>]+\bmu="([^"]+)"`)
+
+	// A result's title link, mostly a /link?url= redirect
+	baiduLinkPattern = regexp.MustCompile(`<h3[^>]*>\s*<a[^>]+href="([^"]+)"`)
+
+	// Sponsored results are marked 广告 (advertisement)
+	baiduAdPattern = regexp.MustCompile(`data-tuiguang|ec-tuiguang|>广告<`)
+
+	baiduNextPattern  = regexp.MustCompile(`<a[^>]+class="n"[^>]*>\s*下一页`)
+	baiduTotalPattern = regexp.MustCompile(`找到相关结果(?:数)?约?([\d,]+)个`)
+)
+
+// ParseResponse parses Baidu results HTML. Each result takes the URL of
+// its mu attribute, else its title link, which UnwrapRedirects will have
+// resolved when it could; sponsored results and links left on Baidu are
+// dropped.
+func (b *Baidu) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, baiduItemPattern) {
+		if baiduAdPattern.MatchString(item) {
+			continue
+		}
+		match := baiduDirectPattern.FindStringSubmatch(item)
+		if match == nil {
+			match = baiduLinkPattern.FindStringSubmatch(item)
+		}
+		if match == nil {
+			continue
+		}
+		link := html.UnescapeString(match[1])
+		if !isBaiduURL(link) && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	result := b.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = baiduNextPattern.MatchString(page)
+	if match := baiduTotalPattern.FindStringSubmatch(page); match != nil {
+		result.TotalResults = match[1]
+	}
+	return result
+}
+
+// baiduPendingRedirects returns the title links of results without an mu
+// attribute, as written in the page
+func baiduPendingRedirects(page string) []string {
+	var pending []string
+	for _, item := range splitBefore(page, baiduItemPattern) {
+		if baiduAdPattern.MatchString(item) || baiduDirectPattern.MatchString(item) {
+			continue
+		}
+		if match := baiduLinkPattern.FindStringSubmatch(item); match != nil && strings.Contains(match[1], "/link?url=") {
+			pending = append(pending, match[1])
+		}
+	}
+	return pending
+}
+
+// isBaiduURL reports whether a link stays on Baidu: redirects left
+// unresolved, Baidu's own services and its static hosts
+func isBaiduURL(link string) bool {
+	return onDomains(link, "baidu.com", "bdimg.com", "bdstatic.com")
+}
+
+// onDomains reports whether a link is on one of domains or their
+// subdomains; a link without a host is taken to be
+func onDomains(link string, domains ...string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked checks for Baidu's access error page
+func (b *Baidu) IsBlocked(page string) bool {
+	return strings.Contains(page, "您的访问出错了") || strings.Contains(page, "百度访问受限")
+}
+
+// IsCaptcha checks for Baidu's security check, on wappass.baidu.com
+func (b *Baidu) IsCaptcha(page string) bool {
+	for _, marker := range []string{
+		"wappass.baidu.com/static/captcha",
+		"<title>百度安全验证</title>",
+		"passMod_spin-wrap",
+	} {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptChinese asks for Chinese pages first, as a browser in China does
+func acceptChinese(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		ex.Request.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+		return next(ctx, ex)
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// blockRateDecay is how much each search outcome moves an engine's
+	// block rate; about the last 1/blockRateDecay searches count
+	blockRateDecay = 0.1
+
+	// minBlockFactor keeps a heavily blocked engine getting a trickle of
+	// tasks, so that its recovery shows in its block rate
+	minBlockFactor = 0.05
+)
+
+// Balancer distributes tasks across a registry's enabled engines in
+// proportion to their configured weight, scaled down by each engine's
+// live block rate
+type Balancer struct {
+	registry   *Registry
+	mu         sync.Mutex
+	blockRates map[EngineType]float64 // Moving average of blocked searches, 0 to 1
+	rng        *rand.Rand
+}
+
+// NewBalancer creates a balancer over the registry's engines. An engine's
+// block rate starts over whenever it is enabled again.
+func NewBalancer(registry *Registry) *Balancer {
+	b := &Balancer{
+		registry:   registry,
+		blockRates: make(map[EngineType]float64),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	registry.OnChange(func(event RegistryEvent) {
+		if event.Type == RegistryEventEnabled {
+			b.mu.Lock()
+			delete(b.blockRates, event.Engine)
+			b.mu.Unlock()
+		}
+	})
+	return b
+}
+
+// Pick returns the engine for a task. An explicit override is used when it
+// is registered; otherwise an enabled engine is chosen at random, weighted
+// by its configured weight times the share of its searches not blocked.
+// It returns false when there is no engine to use.
+func (b *Balancer) Pick(override EngineType) (EngineType, Engine, bool) {
+	if override != "" {
+		engine, ok := b.registry.Get(override)
+		return override, engine, ok
+	}
+
+	types := b.registry.GetEnabledTypes()
+	if len(types) == 0 {
+		return "", nil, false
+	}
+
+	b.mu.Lock()
+	weights := make([]float64, len(types))
+	totalWeight := 0.0
+	for i, engineType := range types {
+		config, _ := b.registry.GetConfig(engineType)
+		if config.Weight <= 0 {
+			continue
+		}
+
+		factor := 1 - b.blockRates[engineType]
+		if factor < minBlockFactor {
+			factor = minBlockFactor
+		}
+		weights[i] = config.Weight * factor
+		totalWeight += weights[i]
+	}
+	pick := b.rng.Float64()
+	b.mu.Unlock()
+
+	// Without any positive weight, every enabled engine is as good
+	var chosen EngineType
+	if totalWeight == 0 {
+		chosen = types[int(pick*float64(len(types)))]
+	} else {
+		chosen = types[len(types)-1]
+		cumulative := 0.0
+		for i, weight := range weights {
+			cumulative += weight
+			if pick*totalWeight < cumulative {
+				chosen = types[i]
+				break
+			}
+		}
+	}
+
+	engine, ok := b.registry.Get(chosen)
+	return chosen, engine, ok
+}
+
+// Record feeds a search's outcome into its engine's block rate; CAPTCHA
+// pages count as blocked
+func (b *Balancer) Record(engineType EngineType, response *SearchResponse) {
+	blocked := 0.0
+	if response.Blocked || response.Captcha {
+		blocked = 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rate := b.blockRates[engineType]
+	b.blockRates[engineType] = rate + (blocked-rate)*blockRateDecay
+}
+
+// BlockRate returns an engine's current block rate, from 0 to 1
+func (b *Balancer) BlockRate(engineType EngineType) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blockRates[engineType]
+}
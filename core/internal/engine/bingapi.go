@@ -0,0 +1,295 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+)
+
+// BingAPI implements the Engine interface with the Bing Web Search API on
+// Azure. Like GoogleCSE it answers JSON without a proxy, and it takes
+// several subscription keys: requests go round them in turn, and a key the
+// API refuses is set aside so the search is retried with the next one.
+type BingAPI struct {
+	*BaseEngine
+	market string
+
+	keysMu sync.Mutex
+	keys   []*bingAPIKey
+	next   int // Index of the key to try first
+}
+
+// bingAPIKey is a subscription key and whether it can be used
+type bingAPIKey struct {
+	key       string
+	revoked   bool      // The API does not know the key
+	restUntil time.Time // Out of quota or calls per second until then
+}
+
+// BingAPIConfig holds Bing Web Search API engine configuration
+type BingAPIConfig struct {
+	Keys    []string // Subscription keys, used in turn
+	Market  string   // mkt, such as en-US; empty lets the API choose
+	Timeout time.Duration
+}
+
+// DefaultBingAPIConfig returns default Bing Web Search API configuration
+func DefaultBingAPIConfig() BingAPIConfig {
+	return BingAPIConfig{
+		Timeout: 30 * time.Second,
+	}
+}
+
+const (
+	// bingAPIPageSize is the most results the API returns per query
+	bingAPIPageSize = 50
+
+	// bingAPIQuotaRest is how long a key out of call volume quota is set
+	// aside; the API does not say when the quota renews
+	bingAPIQuotaRest = time.Hour
+
+	// bingAPIRateRest is how long a key over its calls per second is set
+	// aside
+	bingAPIRateRest = time.Second
+)
+
+// NewBingAPI creates a new Bing Web Search API engine
+func NewBingAPI(config BingAPIConfig) *BingAPI {
+	b := &BingAPI{
+		BaseEngine: NewBaseEngine("bing_api", []string{"api.bing.microsoft.com"}),
+		market:     config.Market,
+	}
+	for _, key := range config.Keys {
+		if key != "" {
+			b.keys = append(b.keys, &bingAPIKey{key: key})
+		}
+	}
+	// JSON is not a page, so Classify has nothing to look at
+	b.middleware = []Middleware{Timing, Transport}
+	b.SetDetector(b)
+	b.Use(b.rotateKeys, b.apiStatus)
+	return b
+}
+
+// Search performs a Bing Web Search API query
+func (b *BingAPI) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	response := &SearchResponse{
+		RequestID:  request.ID,
+		Dork:       request.Dork,
+		Page:       request.Page,
+		EngineUsed: "bing_api",
+		Domain:     "api.bing.microsoft.com",
+		PageSize:   bingAPIPageSize,
+	}
+	if len(b.keys) == 0 {
+		response.Error = NewSearchError(ErrorTypeUnknown, "bing api needs a subscription key", nil)
+		return response, response.Error
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", b.BuildURL(request.Dork, request.Page), nil)
+	if err != nil {
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err = b.Do(ctx, request, req)
+	response.PageSize = bingAPIPageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := b.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// BuildURL builds a Bing Web Search API request URL. The key goes in a
+// header, so the URL is safe to log.
+func (b *BingAPI) BuildURL(query string, page int) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("count", strconv.Itoa(bingAPIPageSize))
+	params.Set("responseFilter", "Webpages")
+	params.Set("safeSearch", "Off")
+	params.Set("textDecorations", "false")
+	if page > 0 {
+		params.Set("offset", strconv.Itoa(page*bingAPIPageSize))
+	}
+	if b.market != "" {
+		params.Set("mkt", b.market)
+	}
+	return "https://api.bing.microsoft.com/v7.0/search?" + params.Encode()
+}
+
+// rotateKeys sends the request with the next usable key. When the API
+// refuses the key, it is set aside and the request is sent again with
+// another, until one is answered or none is left.
+func (b *BingAPI) rotateKeys(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		for attempt := 0; ; attempt++ {
+			key, err := b.pickKey()
+			if err != nil {
+				if attempt > 0 {
+					// The last key's refusal says more than its absence
+					return ex.Response.Error
+				}
+				ex.Response.Error = err
+				return err
+			}
+			ex.Request.Header.Set("Ocp-Apim-Subscription-Key", key.key)
+			ex.Request.Header.Set("Accept", "application/json")
+
+			err = next(ctx, ex)
+			if !b.setAside(key, ex.Response.StatusCode) || attempt+1 >= len(b.keys) {
+				return err
+			}
+			ex.Response.StatusCode = 0
+			ex.Response.Blocked = false
+			ex.Response.Error = nil
+		}
+	}
+}
+
+// pickKey returns the next usable key in turn
+func (b *BingAPI) pickKey() (*bingAPIKey, error) {
+	b.keysMu.Lock()
+	defer b.keysMu.Unlock()
+
+	now := time.Now()
+	revoked := 0
+	for i := range b.keys {
+		key := b.keys[(b.next+i)%len(b.keys)]
+		if key.revoked {
+			revoked++
+			continue
+		}
+		if now.Before(key.restUntil) {
+			continue
+		}
+		b.next = (b.next + i + 1) % len(b.keys)
+		return key, nil
+	}
+	if revoked == len(b.keys) {
+		return nil, NewSearchError(ErrorTypeUnknown, "bing api refused every subscription key", nil)
+	}
+	return nil, NewSearchError(ErrorTypeRateLimit, "every bing api key is out of quota", nil)
+}
+
+// setAside takes a key the API refused with status out of turn, and
+// reports whether it did: 401 for good, 403 (call volume quota) for
+// bingAPIQuotaRest and 429 (calls per second) for bingAPIRateRest
+func (b *BingAPI) setAside(key *bingAPIKey, status int) bool {
+	b.keysMu.Lock()
+	defer b.keysMu.Unlock()
+
+	switch status {
+	case http.StatusUnauthorized:
+		key.revoked = true
+	case http.StatusForbidden:
+		key.restUntil = time.Now().Add(bingAPIQuotaRest)
+	case http.StatusTooManyRequests:
+		key.restUntil = time.Now().Add(bingAPIRateRest)
+	default:
+		return false
+	}
+	return true
+}
+
+// KeysAvailable returns how many keys can be used now
+func (b *BingAPI) KeysAvailable() int {
+	b.keysMu.Lock()
+	defer b.keysMu.Unlock()
+
+	now := time.Now()
+	available := 0
+	for _, key := range b.keys {
+		if !key.revoked && !now.Before(key.restUntil) {
+			available++
+		}
+	}
+	return available
+}
+
+// apiStatus turns the API's error statuses into search errors
+func (b *BingAPI) apiStatus(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+
+		response := ex.Response
+		switch response.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusTooManyRequests:
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeRateLimit, "bing api calls per second exceeded", nil)
+		case http.StatusForbidden:
+			response.Error = NewSearchError(ErrorTypeRateLimit, "bing api call volume quota exceeded", nil)
+		case http.StatusUnauthorized:
+			response.Error = NewSearchError(ErrorTypeUnknown, "bing api refused the subscription key", nil)
+		case http.StatusBadRequest:
+			response.Error = NewSearchError(ErrorTypeParse, "bing api refused the query", nil)
+		default:
+			response.Error = NewSearchError(ErrorTypeNetwork, fmt.Sprintf("unexpected status: %d", response.StatusCode), nil)
+		}
+		return response.Error
+	}
+}
+
+// bingAPIResponse is the part of an API response the engine reads
+type bingAPIResponse struct {
+	WebPages struct {
+		TotalEstimatedMatches int `json:"totalEstimatedMatches"`
+		Value                 []struct {
+			URL string `json:"url"`
+		} `json:"value"`
+	} `json:"webPages"`
+}
+
+// ParseResponse parses an API response. Web pages map to URLs in their
+// order; a response that is not API JSON gives none.
+func (b *BingAPI) ParseResponse(body string) *parser.ExtractionResult {
+	var decoded bingAPIResponse
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		return b.GetExtractor().ExtractURLs(nil)
+	}
+
+	pages := decoded.WebPages
+	links := make([]string, 0, len(pages.Value))
+	for _, page := range pages.Value {
+		if page.URL != "" {
+			links = append(links, page.URL)
+		}
+	}
+
+	result := b.GetExtractor().ExtractURLs(links)
+	// The estimate is rough, so a short page ends the results too
+	result.HasNextPage = len(pages.Value) == bingAPIPageSize && pages.TotalEstimatedMatches > 0
+	if pages.TotalEstimatedMatches > 0 {
+		result.TotalResults = strconv.Itoa(pages.TotalEstimatedMatches)
+	}
+	return result
+}
+
+// IsBlocked reports false: the API refuses with statuses, not pages
+func (b *BingAPI) IsBlocked(body string) bool {
+	return false
+}
+
+// IsCaptcha reports false: the API has no CAPTCHA
+func (b *BingAPI) IsCaptcha(body string) bool {
+	return false
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestBingAPIKeyRotation(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      []string
+		searches  int
+		used      []string // Keys the API saw, in order
+		errType   SearchErrorType
+		available int
+	}{
+		{
+			name:      "round robin",
+			keys:      []string{"good1", "good2"},
+			searches:  3,
+			used:      []string{"good1", "good2", "good1"},
+			available: 2,
+		},
+		{
+			// A refused key is set aside and the search sent again with
+			// the next
+			name:      "revoked key skipped",
+			keys:      []string{"revoked", "good1"},
+			searches:  2,
+			used:      []string{"revoked", "good1", "good1"},
+			available: 1,
+		},
+		{
+			name:      "quota key rests",
+			keys:      []string{"quota", "good1", "good2"},
+			searches:  2,
+			used:      []string{"quota", "good1", "good2"},
+			available: 2,
+		},
+		{
+			// Once every key is out, searches are refused without asking
+			// the API
+			name:      "every key spent",
+			keys:      []string{"quota", "busy"},
+			searches:  2,
+			used:      []string{"quota", "busy"},
+			errType:   ErrorTypeRateLimit,
+			available: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var used []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := r.Header.Get("Ocp-Apim-Subscription-Key")
+				mu.Lock()
+				used = append(used, key)
+				mu.Unlock()
+
+				switch key {
+				case "revoked":
+					w.WriteHeader(http.StatusUnauthorized)
+				case "quota":
+					w.WriteHeader(http.StatusForbidden)
+				case "busy":
+					w.WriteHeader(http.StatusTooManyRequests)
+				default:
+					w.Write([]byte(`{"webPages": {"value": []}}`))
+				}
+			}))
+			defer server.Close()
+
+			config := DefaultBingAPIConfig()
+			config.Keys = tt.keys
+			b := NewBingAPI(config)
+
+			var response *SearchResponse
+			for i := 0; i < tt.searches; i++ {
+				req, err := http.NewRequest("GET", server.URL+"/v7.0/search?q=test", nil)
+				if err != nil {
+					t.Fatalf("failed to create request: %v", err)
+				}
+				response, _ = b.Do(context.Background(), &SearchRequest{ID: "r1", Dork: "test"}, req)
+			}
+
+			if !reflect.DeepEqual(used, tt.used) {
+				t.Errorf("keys used = %q, want %q", used, tt.used)
+			}
+			var searchErr *SearchError
+			if tt.errType == "" {
+				if response.Error != nil {
+					t.Errorf("unexpected error: %v", response.Error)
+				}
+			} else if !errors.As(response.Error, &searchErr) || searchErr.Type != tt.errType {
+				t.Errorf("error = %v, want a %s error", response.Error, tt.errType)
+			}
+			if available := b.KeysAvailable(); available != tt.available {
+				t.Errorf("keys available = %d, want %d", available, tt.available)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Brave implements the Engine interface for Brave Search. Pages are
+// numbered from 0 with offset=, up to the last Brave serves, and result
+// links are direct. Brave throttles with 429 and, for clients it suspects,
+// a proof-of-work CAPTCHA page.
+type Brave struct {
+	*BaseEngine
+	headerGen *stealth.HeaderGenerator
+}
+
+// BraveConfig holds Brave engine configuration
+type BraveConfig struct {
+	Domains    []string
+	Timeout    time.Duration
+	UserAgents []string
+}
+
+// DefaultBraveConfig returns default Brave configuration
+func DefaultBraveConfig() BraveConfig {
+	return BraveConfig{
+		Domains:    []string{"search.brave.com"},
+		Timeout:    30 * time.Second,
+		UserAgents: stealth.DefaultUserAgents(),
+	}
+}
+
+// NewBrave creates a new Brave search engine
+func NewBrave(config BraveConfig) *Brave {
+	if len(config.Domains) == 0 {
+		config.Domains = DefaultBraveConfig().Domains
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	b := &Brave{
+		BaseEngine: NewBaseEngine("brave", config.Domains),
+		headerGen:  stealth.NewHeaderGenerator(config.UserAgents),
+	}
+	b.SetDetector(b)
+	b.Use(HeaderProfile(b.headerGen), b.referer, b.throttle)
+	return b
+}
+
+// Search performs a Brave search
+func (b *Brave) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = b.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", buildBraveURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "brave",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := b.Do(ctx, request, req)
+	response.PageSize = bravePageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := b.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage && request.Page < braveLastPage
+
+	return response, nil
+}
+
+const (
+	// bravePageSize is the number of web results on a Brave page
+	bravePageSize = 20
+
+	// braveLastPage is the highest offset Brave serves
+	braveLastPage = 9
+)
+
+// BuildURL builds a Brave search URL
+func (b *Brave) BuildURL(query string, page int) string {
+	return buildBraveURL(b.selectDomain(), query, page)
+}
+
+func buildBraveURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("source", "web")
+	if page > 0 {
+		params.Set("offset", strconv.Itoa(page))
+	}
+	return fmt.Sprintf("https://%s/search?%s", domain, params.Encode())
+}
+
+func (b *Brave) selectDomain() string {
+	domains := b.GetDomains()
+	if len(domains) == 0 {
+		return "search.brave.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// referer sends the previous page as the Referer of later pages, as
+// Brave's pager links do, and none with the first
+func (b *Brave) referer(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if ex.Search.Page > 0 {
+			ex.Request.Header.Set("Referer", buildBraveURL(ex.Request.URL.Host, ex.Search.Dork, ex.Search.Page-1))
+		} else {
+			ex.Request.Header.Del("Referer")
+		}
+		return next(ctx, ex)
+	}
+}
+
+// throttle reports Brave's refusals: its CAPTCHA page as a CAPTCHA, and a
+// 429, which Brave sends without a CAPTCHA to clients over its rate, as a
+// block
+func (b *Brave) throttle(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		response := ex.Response
+		switch {
+		case b.IsCaptcha(ex.Body):
+			response.Captcha = true
+			response.Error = NewSearchError(ErrorTypeCaptcha, "brave captcha", nil)
+		case response.StatusCode == http.StatusTooManyRequests:
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "rate limited by brave", nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+var (
+	// Web results are snippets of type "web"; news, videos and other
+	// clusters have their own types
+	braveItemPattern = regexp.MustCompile(`<div[^>]+class="[^"]*\bsnippet\b[^"]*"[^>]*data-type="web"|<div[^>]+data-type="web"[^>]*class="[^"]*\bsnippet\b`)
+
+	// The first outbound link of a snippet is its title link
+	braveLinkPattern = regexp.MustCompile(`<a[^>]+href="(https?://[^"]+)"`)
+
+	// The pager's "Next" link, enabled
+	braveNextPattern = regexp.MustCompile(`<a[^>]+href="[^"]*[?&](?:amp;)?offset=\d+[^"]*"[^>]*>(?:\s*<[^>]+>)*\s*Next\b`)
+)
+
+// ParseResponse parses Brave results HTML. Each web result is a snippet
+// whose first link is the result; links back to Brave, such as its
+// summarizer and image search, are dropped.
+func (b *Brave) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, braveItemPattern) {
+		match := braveLinkPattern.FindStringSubmatch(item)
+		if match == nil {
+			continue
+		}
+		link := html.UnescapeString(match[1])
+		if !isBraveURL(link) && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	result := b.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = braveNextPattern.MatchString(page)
+	return result
+}
+
+// isBraveURL reports whether a link stays on Brave
+func isBraveURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return host == "brave.com" || strings.HasSuffix(host, ".brave.com")
+}
+
+// IsCaptcha checks for Brave's CAPTCHA page, a proof-of-work challenge
+// solved in the browser
+func (b *Brave) IsCaptcha(page string) bool {
+	for _, marker := range []string{
+		"pow-captcha",
+		"/search/captcha",
+		"captcha-container",
+	} {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
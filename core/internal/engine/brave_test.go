@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBraveThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		page    string
+		captcha bool
+		blocked bool
+		errType SearchErrorType
+	}{
+		{
+			// Brave answers too many requests with 429 and no challenge,
+			// which is a block rather than a CAPTCHA to solve
+			name:    "too many requests",
+			status:  http.StatusTooManyRequests,
+			page:    `<html><body>Too Many Requests</body></html>`,
+			blocked: true,
+			errType: ErrorTypeBlocked,
+		},
+		{
+			name:    "proof of work captcha",
+			status:  http.StatusOK,
+			page:    `<html><body><div id="captcha-container"><script src="/static/pow-captcha.js"></script></div></body></html>`,
+			captcha: true,
+			errType: ErrorTypeCaptcha,
+		},
+		{
+			name:   "results",
+			status: http.StatusOK,
+			page:   readFixture(t, "brave.html"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBrave(DefaultBraveConfig())
+			response := doPage(t, b, tt.status, tt.page)
+
+			if response.Captcha != tt.captcha || response.Blocked != tt.blocked {
+				t.Errorf("captcha = %v, blocked = %v, want %v, %v (error %v)",
+					response.Captcha, response.Blocked, tt.captcha, tt.blocked, response.Error)
+			}
+
+			var searchErr *SearchError
+			if tt.errType == "" {
+				if response.Error != nil {
+					t.Errorf("unexpected error: %v", response.Error)
+				}
+			} else if !errors.As(response.Error, &searchErr) || searchErr.Type != tt.errType {
+				t.Errorf("error = %v, want a %s error", response.Error, tt.errType)
+			}
+		})
+	}
+}
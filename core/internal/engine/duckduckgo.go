@@ -0,0 +1,307 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// DuckDuckGo implements the Engine interface for DuckDuckGo's HTML
+// endpoint, which serves results without JavaScript. Searches are posted
+// as a form, pages are requested with the s/dc offsets of the previous
+// page's "Next" form, and result links go through a /l/?uddg= redirector
+// that is unwrapped to the target.
+type DuckDuckGo struct {
+	*BaseEngine
+	headerGen      *stealth.HeaderGenerator
+	region         string
+	resultsPerPage int
+}
+
+// DuckDuckGoConfig holds DuckDuckGo engine configuration
+type DuckDuckGoConfig struct {
+	Domains        []string
+	Region         string // kl parameter, such as "us-en"; "wt-wt" is no region
+	ResultsPerPage int    // Offset step used when a page gives no "Next" form
+	Timeout        time.Duration
+	UserAgents     []string
+}
+
+// DefaultDuckDuckGoConfig returns default DuckDuckGo configuration
+func DefaultDuckDuckGoConfig() DuckDuckGoConfig {
+	return DuckDuckGoConfig{
+		Domains:        []string{"html.duckduckgo.com"},
+		Region:         "wt-wt",
+		ResultsPerPage: 10,
+		Timeout:        30 * time.Second,
+		UserAgents:     stealth.DefaultUserAgents(),
+	}
+}
+
+// NewDuckDuckGo creates a new DuckDuckGo search engine
+func NewDuckDuckGo(config DuckDuckGoConfig) *DuckDuckGo {
+	defaults := DefaultDuckDuckGoConfig()
+	if len(config.Domains) == 0 {
+		config.Domains = defaults.Domains
+	}
+	if config.Region == "" {
+		config.Region = defaults.Region
+	}
+	if config.ResultsPerPage == 0 {
+		config.ResultsPerPage = defaults.ResultsPerPage
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	d := &DuckDuckGo{
+		BaseEngine:     NewBaseEngine("duckduckgo", config.Domains),
+		headerGen:      stealth.NewHeaderGenerator(config.UserAgents),
+		region:         config.Region,
+		resultsPerPage: config.ResultsPerPage,
+	}
+	d.SetDetector(d)
+	d.Use(HeaderProfile(d.headerGen), d.formHeaders, d.challenge)
+	return d
+}
+
+// Search performs a DuckDuckGo search
+func (d *DuckDuckGo) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = d.selectDomain()
+	}
+
+	form := d.buildForm(request.Dork, request.Page, request.Continuation)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s/html/", domain), strings.NewReader(form.Encode()))
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "duckduckgo",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := d.Do(ctx, request, req)
+	response.PageSize = d.resultsPerPage
+	if err != nil {
+		return response, err
+	}
+
+	result := d.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.Continuation = result.Continuation
+
+	return response, nil
+}
+
+// BuildURL builds a DuckDuckGo search URL. The HTML endpoint also answers
+// the form as a GET, which is what a link can carry; Search posts it.
+func (d *DuckDuckGo) BuildURL(query string, page int) string {
+	form := d.buildForm(query, page, nil)
+	return fmt.Sprintf("https://%s/html/?%s", d.selectDomain(), form.Encode())
+}
+
+// buildForm returns the search form for a page. Pages after the first
+// post the offsets of the previous page's "Next" form when the request
+// carries them, or offsets counted in the configured page size.
+func (d *DuckDuckGo) buildForm(query string, page int, cont *parser.Continuation) url.Values {
+	form := url.Values{}
+	form.Set("q", query)
+	form.Set("b", "")
+	form.Set("kl", d.region)
+
+	if page == 0 && cont == nil {
+		return form
+	}
+
+	start := page * d.resultsPerPage
+	if cont != nil && cont.Start > 0 {
+		start = cont.Start
+	}
+	dc := start + 1
+	if cont != nil && cont.DC > 0 {
+		dc = cont.DC
+	}
+
+	form.Set("s", strconv.Itoa(start))
+	form.Set("dc", strconv.Itoa(dc))
+	form.Set("nextParams", "")
+	form.Set("v", "l")
+	form.Set("o", "json")
+	form.Set("api", "d.js")
+	if cont != nil && cont.Token != "" {
+		form.Set("vqd", cont.Token)
+	}
+	return form
+}
+
+func (d *DuckDuckGo) selectDomain() string {
+	domains := d.GetDomains()
+	if len(domains) == 0 {
+		return "html.duckduckgo.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// formHeaders makes the request look like the endpoint's own form was
+// submitted, over the search-page Referer the header profile sets
+func (d *DuckDuckGo) formHeaders(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		origin := "https://" + ex.Request.URL.Host
+		ex.Request.Header.Set("Origin", origin)
+		ex.Request.Header.Set("Referer", origin+"/")
+		ex.Request.Header.Set("Sec-Fetch-Site", "same-origin")
+		return next(ctx, ex)
+	}
+}
+
+// challenge reports DuckDuckGo's anomaly challenge as a CAPTCHA. The
+// endpoint answers a suspect client with 202 and a challenge page, which
+// Classify would otherwise take for an unexpected status or a results page.
+func (d *DuckDuckGo) challenge(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		if ex.Response.StatusCode == http.StatusAccepted || d.IsCaptcha(ex.Body) {
+			ex.Response.Captcha = true
+			ex.Response.Error = NewSearchError(ErrorTypeCaptcha, "anomaly challenge", nil)
+			return ex.Response.Error
+		}
+		if d.IsBlocked(ex.Body) {
+			ex.Response.Blocked = true
+			ex.Response.Error = NewSearchError(ErrorTypeBlocked, "blocked by duckduckgo", nil)
+			return ex.Response.Error
+		}
+		return nil
+	}
+}
+
+var (
+	ddgLinkPattern = regexp.MustCompile(`<a[^>]+class="result__a"[^>]*>`)
+	ddgHrefPattern = regexp.MustCompile(`href="([^"]+)"`)
+
+	ddgFormPattern  = regexp.MustCompile(`(?s)<form[^>]*>(.*?)</form>`)
+	ddgInputPattern = regexp.MustCompile(`<input[^>]*>`)
+	ddgNamePattern  = regexp.MustCompile(`name="([^"]*)"`)
+	ddgValuePattern = regexp.MustCompile(`value="([^"]*)"`)
+)
+
+// ParseResponse parses DuckDuckGo HTML results. Ads are left out: their
+// links go through the y.js click tracker rather than /l/.
+func (d *DuckDuckGo) ParseResponse(page string) *parser.ExtractionResult {
+	if strings.Contains(page, `class="no-results"`) {
+		return d.GetExtractor().ExtractURLs(nil)
+	}
+
+	var links []string
+	for _, tag := range ddgLinkPattern.FindAllString(page, -1) {
+		href := ddgHrefPattern.FindStringSubmatch(tag)
+		if href == nil {
+			continue
+		}
+		if link := UnwrapDuckDuckGoURL(href[1]); link != "" {
+			links = append(links, link)
+		}
+	}
+
+	result := d.GetExtractor().ExtractURLs(links)
+	if cont := parseDuckDuckGoNext(page); cont != nil {
+		result.HasNextPage = true
+		result.Continuation = cont
+	}
+	return result
+}
+
+// UnwrapDuckDuckGoURL returns the target of a result link: the uddg
+// parameter of a //duckduckgo.com/l/ redirector link, or the link itself
+// when it is direct. Ad click links and other DuckDuckGo pages give "".
+func UnwrapDuckDuckGoURL(link string) string {
+	link = html.UnescapeString(link)
+	if strings.HasPrefix(link, "//") {
+		link = "https:" + link
+	} else if strings.HasPrefix(link, "/") {
+		link = "https://duckduckgo.com" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "duckduckgo.com" && !strings.HasSuffix(host, ".duckduckgo.com") {
+		return link
+	}
+	if parsed.Path != "/l/" && parsed.Path != "/l" {
+		return ""
+	}
+	return parsed.Query().Get("uddg")
+}
+
+// parseDuckDuckGoNext reads the hidden fields of a page's "Next" form into
+// a continuation: s and dc as Start and DC, the vqd session token as
+// Token. It returns nil on the last page, which has no such form.
+func parseDuckDuckGoNext(page string) *parser.Continuation {
+	for _, form := range ddgFormPattern.FindAllStringSubmatch(page, -1) {
+		if !strings.Contains(form[1], `value="Next"`) {
+			continue
+		}
+
+		cont := &parser.Continuation{}
+		for _, input := range ddgInputPattern.FindAllString(form[1], -1) {
+			name := ddgNamePattern.FindStringSubmatch(input)
+			value := ddgValuePattern.FindStringSubmatch(input)
+			if name == nil || value == nil {
+				continue
+			}
+			switch name[1] {
+			case "s":
+				cont.Start, _ = strconv.Atoi(value[1])
+			case "dc":
+				cont.DC, _ = strconv.Atoi(value[1])
+			case "vqd":
+				cont.Token = html.UnescapeString(value[1])
+			}
+		}
+		return cont
+	}
+	return nil
+}
+
+// IsBlocked checks if blocked by DuckDuckGo
+func (d *DuckDuckGo) IsBlocked(page string) bool {
+	lower := strings.ToLower(page)
+	for _, indicator := range []string{
+		"if this error persists, please let us know",
+		"unfortunately, bots use duckduckgo too",
+	} {
+		if strings.Contains(lower, indicator) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCaptcha checks for DuckDuckGo's anomaly challenge
+func (d *DuckDuckGo) IsCaptcha(page string) bool {
+	return strings.Contains(page, "anomaly-modal") || strings.Contains(page, "challenge-form")
+}
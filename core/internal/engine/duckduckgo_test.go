@@ -0,0 +1,64 @@
+package engine
+
+import "testing"
+
+func TestUnwrapDuckDuckGoURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{
+			name: "redirect",
+			link: "//duckduckgo.com/l/?uddg=https%3A%2F%2Fwww.example.com%2Fadmin%2F&rut=6b1c",
+			want: "https://www.example.com/admin/",
+		},
+		{
+			name: "escaped redirect",
+			link: "//duckduckgo.com/l/?uddg=https%3A%2F%2Fwww.example.com%2Fsearch%3Fq%3Da%26b%3D1&amp;rut=6b1c",
+			want: "https://www.example.com/search?q=a&b=1",
+		},
+		{
+			name: "relative redirect",
+			link: "/l/?uddg=https%3A%2F%2Fportal.example.org%2Flogin",
+			want: "https://portal.example.org/login",
+		},
+		{
+			name: "direct",
+			link: "https://portal.example.org/login",
+			want: "https://portal.example.org/login",
+		},
+		{
+			name: "ad",
+			link: "https://duckduckgo.com/y.js?ad_domain=ads.example.net&u3=x",
+			want: "",
+		},
+		{
+			name: "redirect without target",
+			link: "https://html.duckduckgo.com/l/?rut=6b1c",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnwrapDuckDuckGoURL(tt.link); got != tt.want {
+				t.Errorf("UnwrapDuckDuckGoURL(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuckDuckGoNext(t *testing.T) {
+	cont := parseDuckDuckGoNext(readFixture(t, "duckduckgo.html"))
+	if cont == nil {
+		t.Fatal("no continuation for a page with a Next form")
+	}
+	if cont.Start != 10 || cont.DC != 11 || cont.Token != "4-12345678901234567890" {
+		t.Errorf("continuation = %+v, want start 10, dc 11 and the vqd token", cont)
+	}
+
+	if cont := parseDuckDuckGoNext(`<form action="/html/" method="post"><input type="submit" value="Previous"></form>`); cont != nil {
+		t.Errorf("continuation = %+v on the last page, want nil", cont)
+	}
+}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/google-dork-parser/core/internal/parser"
@@ -39,9 +41,69 @@ type SearchRequest struct {
 	Page        int
 	Proxy       *proxy.Proxy
 	UserAgent   string
-	Headers     map[string]string
+	Headers     map[string]string // Over the engine's custom headers; "" removes one
 	Timeout     time.Duration
 	RetryCount  int
+
+	// Continuation, from the previous page's response, requests the page
+	// by its token instead of a start offset when set
+	Continuation *parser.Continuation
+
+	// Domain pins the search domain, so that a dork's pages share one
+	// domain's session and page size; empty picks one at random
+	Domain string
+
+	// Options are advanced search parameters, such as a time range
+	Options SearchOptions
+}
+
+// TimeRange limits a search to pages indexed within a recent period
+type TimeRange string
+
+const (
+	TimeRangeHour  TimeRange = "h"
+	TimeRangeDay   TimeRange = "d"
+	TimeRangeWeek  TimeRange = "w"
+	TimeRangeMonth TimeRange = "m"
+	TimeRangeYear  TimeRange = "y"
+)
+
+// SearchType picks a search vertical; the zero value is web search
+type SearchType string
+
+const (
+	SearchTypeWeb    SearchType = ""
+	SearchTypeNews   SearchType = "news"
+	SearchTypeImages SearchType = "images"
+	SearchTypeVideos SearchType = "videos"
+)
+
+// SearchOptions are advanced search parameters. Engines apply the ones
+// they support and ignore the rest; Google supports them all.
+type SearchOptions struct {
+	// Type searches a vertical, such as news, instead of the web
+	Type SearchType
+
+	// TimeRange keeps pages indexed within the period; a custom range
+	// takes precedence over it
+	TimeRange TimeRange
+
+	// DateFrom and DateTo keep pages indexed within a custom range of
+	// days; either may be zero to leave that end open
+	DateFrom time.Time
+	DateTo   time.Time
+
+	// Verbatim searches the dork as written, without auto-correction or
+	// synonyms
+	Verbatim bool
+
+	// Filter lets the engine omit results it finds similar to others.
+	// Dorks want every match, so similar results are shown by default.
+	Filter bool
+
+	// OrganicOnly drops ads, featured snippets and "People also ask"
+	// links, on engines that type their results
+	OrganicOnly bool
 }
 
 // SearchResponse represents a search response
@@ -51,6 +113,7 @@ type SearchResponse struct {
 	Page         int
 	URLs         []string
 	RawURLs      []string
+	Types        []string // Result type of each URL (parser.TypeOrganic, ...), in URLs' order
 	HasNextPage  bool
 	TotalResults string
 	StatusCode   int
@@ -61,6 +124,12 @@ type SearchResponse struct {
 	ProxyUsed    string
 	EngineUsed   string
 	HTML         string // Raw HTML (optional, for debugging)
+
+	// Continuation is how to request the next page, when the page says
+	Continuation *parser.Continuation
+
+	Domain   string // Domain searched
+	PageSize int    // Results per page asked of the domain
 }
 
 // EngineType represents the type of search engine
@@ -73,18 +142,32 @@ const (
 	EngineTypeDuckDuckGo EngineType = "duckduckgo"
 	EngineTypeYandex     EngineType = "yandex"
 	EngineTypeAsk        EngineType = "ask"
+	EngineTypeBrave      EngineType = "brave"
+	EngineTypeStartpage  EngineType = "startpage"
+	EngineTypeGoogleCSE  EngineType = "google_cse"
+	EngineTypeBingAPI    EngineType = "bing_api"
+	EngineTypeBaidu      EngineType = "baidu"
+	EngineTypeSogou      EngineType = "sogou"
 )
 
 // EngineConfig holds configuration for an engine
 type EngineConfig struct {
 	Type            EngineType
 	Enabled         bool
-	Weight          float64 // For load balancing between engines
+	Weight          float64 // Share of tasks, see Balancer; 0 only runs tasks naming the engine
 	ResultsPerPage  int
 	MaxPages        int
 	Domains         []string
-	CustomHeaders   map[string]string
-	RateLimitPerMin int
+	CustomHeaders   map[string]string // Set on every request; values may use {domain}, {engine} and {page}
+	RateLimitPerMin int               // Requests per minute across the engine's domains; 0 for no limit
+
+	// DomainRateLimitPerMin limits the requests to each of the engine's
+	// domains on top of RateLimitPerMin; 0 for no limit
+	DomainRateLimitPerMin int
+
+	// RateLimitBurst is how many requests may go out back to back before
+	// the limits space them; 0 for 1
+	RateLimitBurst int
 }
 
 // DefaultEngineConfigs returns default configurations for all engines
@@ -177,13 +260,102 @@ func DefaultEngineConfigs() map[EngineType]EngineConfig {
 			},
 			RateLimitPerMin: 30,
 		},
+		EngineTypeBrave: {
+			Type:           EngineTypeBrave,
+			Enabled:        false,
+			Weight:         0.5,
+			ResultsPerPage: 20,
+			MaxPages:       10, // Brave serves no page past offset=9
+			Domains: []string{
+				"search.brave.com",
+			},
+			RateLimitPerMin: 30,
+		},
+		EngineTypeStartpage: {
+			Type:           EngineTypeStartpage,
+			Enabled:        false,
+			Weight:         0.5,
+			ResultsPerPage: 10,
+			MaxPages:       10,
+			Domains: []string{
+				"www.startpage.com",
+			},
+			RateLimitPerMin: 20,
+		},
+		EngineTypeGoogleCSE: {
+			Type:           EngineTypeGoogleCSE,
+			Enabled:        false,
+			Weight:         0, // Spends a daily quota; runs tasks that name it
+			ResultsPerPage: 10,
+			MaxPages:       10, // The API serves the first 100 results
+			Domains: []string{
+				"www.googleapis.com",
+			},
+			RateLimitPerMin: 60,
+		},
+		EngineTypeBingAPI: {
+			Type:           EngineTypeBingAPI,
+			Enabled:        false,
+			Weight:         0, // Paid per call; runs tasks that name it
+			ResultsPerPage: 50,
+			MaxPages:       20,
+			Domains: []string{
+				"api.bing.microsoft.com",
+			},
+			RateLimitPerMin: 180, // Three calls per second on the S1 tier
+		},
+		EngineTypeBaidu: {
+			Type:           EngineTypeBaidu,
+			Enabled:        false,
+			Weight:         0, // Chinese-language results; runs tasks that name it
+			ResultsPerPage: 10,
+			MaxPages:       10,
+			Domains: []string{
+				"www.baidu.com",
+			},
+			RateLimitPerMin: 20,
+		},
+		EngineTypeSogou: {
+			Type:           EngineTypeSogou,
+			Enabled:        false,
+			Weight:         0, // Chinese-language results; runs tasks that name it
+			ResultsPerPage: 10,
+			MaxPages:       10,
+			Domains: []string{
+				"www.sogou.com",
+			},
+			RateLimitPerMin: 20,
+		},
 	}
 }
 
-// Registry holds all registered engines
+// Registry holds all registered engines. It is safe for concurrent use, so
+// engines can be enabled, disabled and reconfigured while the scheduler
+// enumerates them.
 type Registry struct {
+	mu      sync.RWMutex
 	engines map[EngineType]Engine
 	configs map[EngineType]EngineConfig
+	hooks   []func(RegistryEvent)
+	limiter *RateLimiter          // Enforces each engine's rate limits, see Register
+	limited map[EngineType]Engine // Engine whose chain has each name's rate limit
+}
+
+// RegistryEventType defines the kind of change made to a Registry
+type RegistryEventType string
+
+const (
+	RegistryEventRegistered RegistryEventType = "registered"
+	RegistryEventEnabled    RegistryEventType = "enabled"
+	RegistryEventDisabled   RegistryEventType = "disabled"
+	RegistryEventConfig     RegistryEventType = "config"
+)
+
+// RegistryEvent describes a change made to a Registry
+type RegistryEvent struct {
+	Type   RegistryEventType
+	Engine EngineType
+	Config EngineConfig // The engine's configuration after the change
 }
 
 // NewRegistry creates a new engine registry
@@ -191,82 +363,224 @@ func NewRegistry() *Registry {
 	return &Registry{
 		engines: make(map[EngineType]Engine),
 		configs: DefaultEngineConfigs(),
+		limiter: NewRateLimiter(true),
+		limited: make(map[EngineType]Engine),
+	}
+}
+
+// OnChange adds a hook called after each change to the registry. Hooks run
+// synchronously in the order they were added, outside the registry's lock,
+// so they may call back into the registry.
+func (r *Registry) OnChange(hook func(RegistryEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// notify runs the change hooks for an event
+func (r *Registry) notify(event RegistryEvent) {
+	r.mu.RLock()
+	hooks := make([]func(RegistryEvent), len(r.hooks))
+	copy(hooks, r.hooks)
+	r.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(event)
 	}
 }
 
-// Register registers an engine
+// Register registers an engine. Engines that take middleware get the
+// registry's rate limiting, added to their chain once however often they
+// are registered under the name.
 func (r *Registry) Register(engineType EngineType, engine Engine) {
+	r.mu.Lock()
 	r.engines[engineType] = engine
+	config := r.configs[engineType]
+	limit := r.limited[engineType] != engine
+	r.limited[engineType] = engine
+	r.mu.Unlock()
+
+	if e, ok := engine.(interface{ Use(...Middleware) }); ok && limit {
+		e.Use(r.RateLimit(engineType))
+	}
+	applyConfig(engine, config)
+
+	r.notify(RegistryEvent{Type: RegistryEventRegistered, Engine: engineType, Config: config})
+}
+
+// RateLimiter returns the limiter enforcing the engines' rate limits; it
+// blocks until switched with SetBlocking
+func (r *Registry) RateLimiter() *RateLimiter {
+	return r.limiter
 }
 
 // Get returns an engine by type
 func (r *Registry) Get(engineType EngineType) (Engine, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	engine, ok := r.engines[engineType]
 	return engine, ok
 }
 
-// GetEnabled returns all enabled engines
+// GetEnabled returns a snapshot of the enabled engines, ordered by type.
+// Later changes to the registry do not affect the returned slice.
 func (r *Registry) GetEnabled() []Engine {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	engines := make([]Engine, 0)
-	for engineType, engine := range r.engines {
+	for _, engineType := range r.sortedTypes() {
 		if config, ok := r.configs[engineType]; ok && config.Enabled {
-			engines = append(engines, engine)
+			engines = append(engines, r.engines[engineType])
 		}
 	}
 	return engines
 }
 
+// GetEnabledTypes returns a snapshot of the enabled engines' types, ordered
+// by type
+func (r *Registry) GetEnabledTypes() []EngineType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]EngineType, 0)
+	for _, engineType := range r.sortedTypes() {
+		if config, ok := r.configs[engineType]; ok && config.Enabled {
+			types = append(types, engineType)
+		}
+	}
+	return types
+}
+
 // GetConfig returns the configuration for an engine
 func (r *Registry) GetConfig(engineType EngineType) (EngineConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	config, ok := r.configs[engineType]
 	return config, ok
 }
 
+// Configs returns a snapshot of all engine configurations
+func (r *Registry) Configs() map[EngineType]EngineConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	configs := make(map[EngineType]EngineConfig, len(r.configs))
+	for engineType, config := range r.configs {
+		configs[engineType] = config
+	}
+	return configs
+}
+
 // SetConfig sets the configuration for an engine
 func (r *Registry) SetConfig(engineType EngineType, config EngineConfig) {
+	r.mu.Lock()
 	r.configs[engineType] = config
+	engine := r.engines[engineType]
+	r.mu.Unlock()
+
+	if engine != nil {
+		applyConfig(engine, config)
+	}
+
+	r.notify(RegistryEvent{Type: RegistryEventConfig, Engine: engineType, Config: config})
 }
 
 // Enable enables an engine
 func (r *Registry) Enable(engineType EngineType) {
-	if config, ok := r.configs[engineType]; ok {
-		config.Enabled = true
-		r.configs[engineType] = config
-	}
+	r.setEnabled(engineType, true)
 }
 
 // Disable disables an engine
 func (r *Registry) Disable(engineType EngineType) {
-	if config, ok := r.configs[engineType]; ok {
-		config.Enabled = false
-		r.configs[engineType] = config
+	r.setEnabled(engineType, false)
+}
+
+// setEnabled switches an engine on or off, notifying the hooks only when
+// its state changes
+func (r *Registry) setEnabled(engineType EngineType, enabled bool) {
+	r.mu.Lock()
+	config, ok := r.configs[engineType]
+	if !ok || config.Enabled == enabled {
+		r.mu.Unlock()
+		return
+	}
+	config.Enabled = enabled
+	r.configs[engineType] = config
+	r.mu.Unlock()
+
+	event := RegistryEvent{Type: RegistryEventDisabled, Engine: engineType, Config: config}
+	if enabled {
+		event.Type = RegistryEventEnabled
+	}
+	r.notify(event)
+}
+
+// applyConfig hands an engine the parts of its configuration it applies
+// to its own requests
+func applyConfig(engine Engine, config EngineConfig) {
+	if e, ok := engine.(interface{ SetCustomHeaders(map[string]string) }); ok {
+		e.SetCustomHeaders(config.CustomHeaders)
 	}
 }
 
-// List returns all registered engine types
+// List returns a snapshot of all registered engine types, ordered by type
 func (r *Registry) List() []EngineType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedTypes()
+}
+
+// sortedTypes returns the registered engine types in order; the caller
+// holds the lock
+func (r *Registry) sortedTypes() []EngineType {
 	types := make([]EngineType, 0, len(r.engines))
 	for t := range r.engines {
 		types = append(types, t)
 	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
 	return types
 }
 
+// Detector recognizes block and CAPTCHA pages; Classify asks it about
+// every page fetched
+type Detector interface {
+	IsBlocked(html string) bool
+	IsCaptcha(html string) bool
+}
+
 // BaseEngine provides common functionality for engines
 type BaseEngine struct {
-	name      string
-	domains   []string
-	extractor *parser.Extractor
+	name       string
+	domains    []string
+	extractor  *parser.Extractor
+	middleware []Middleware // Request execution steps, see Do
+	detector   Detector     // The engine's own checks, see SetDetector
+
+	middlewareMu sync.RWMutex // Use may run while Do does, as Register calls it
+
+	customHeaders   map[string]string // EngineConfig.CustomHeaders, templates unexpanded
+	customHeadersMu sync.RWMutex
 }
 
 // NewBaseEngine creates a new base engine
 func NewBaseEngine(name string, domains []string) *BaseEngine {
 	cleaner := parser.NewURLCleaner(parser.DefaultCleanerConfig())
-	return &BaseEngine{
+	e := &BaseEngine{
 		name:      name,
 		domains:   domains,
 		extractor: parser.NewExtractor(cleaner),
 	}
+	e.detector = e
+	e.middleware = e.DefaultMiddleware()
+	return e
+}
+
+// SetDetector sets the checks Classify runs on each page. An engine with
+// its own IsBlocked or IsCaptcha passes itself from its constructor; the
+// base engine's generic checks apply until then.
+func (e *BaseEngine) SetDetector(d Detector) {
+	e.detector = d
 }
 
 // Name returns the engine name
@@ -327,6 +641,11 @@ func (e *SearchError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the underlying error, such as ErrRateLimited
+func (e *SearchError) Unwrap() error {
+	return e.Err
+}
+
 // NewSearchError creates a new search error
 func NewSearchError(errType SearchErrorType, message string, err error) *SearchError {
 	return &SearchError{
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google-dork-parser/core/internal/parser"
+)
+
+// readFixture returns a page kept in testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return string(data)
+}
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		fixture   string
+		parse     func(string) *parser.ExtractionResult
+		urls      []string
+		unordered bool // URLs are compared sorted
+		next      bool
+		total     string
+	}{
+		{
+			// Google's extractor gathers links pattern by pattern, so
+			// their order is not the page's
+			fixture:   "google.html",
+			parse:     NewGoogle(DefaultGoogleConfig()).ParseResponse,
+			urls:      []string{"https://ads.example.net/landing", "https://portal.example.org/admin/", "https://www.example.com/admin/login.php"},
+			unordered: true,
+			next:      true,
+			total:     "1,230",
+		},
+		{
+			fixture: "duckduckgo.html",
+			parse:   NewDuckDuckGo(DefaultDuckDuckGoConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+		},
+		{
+			fixture: "yahoo.html",
+			parse:   NewYahoo(DefaultYahooConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+			total:   "1,230",
+		},
+		{
+			fixture: "yandex.html",
+			parse:   NewYandex(DefaultYandexConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+			total:   "2 тыс.",
+		},
+		{
+			fixture: "brave.html",
+			parse:   NewBrave(DefaultBraveConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+		},
+		{
+			fixture: "startpage.html",
+			parse:   NewStartpage(DefaultStartpageConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+		},
+		{
+			// The second result's redirect was not resolved, so only the
+			// result with an mu attribute is left
+			fixture: "baidu.html",
+			parse:   NewBaidu(DefaultBaiduConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/"},
+			next:    true,
+			total:   "1,230",
+		},
+		{
+			fixture: "sogou.html",
+			parse:   NewSogou(DefaultSogouConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+			total:   "1,230",
+		},
+		{
+			fixture: "googlecse.json",
+			parse:   NewGoogleCSE(DefaultGoogleCSEConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			next:    true,
+			total:   "1230",
+		},
+		{
+			// Fewer than a full page of results is the last page
+			fixture: "bingapi.json",
+			parse:   NewBingAPI(DefaultBingAPIConfig()).ParseResponse,
+			urls:    []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+			total:   "1230",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			result := tt.parse(readFixture(t, tt.fixture))
+
+			urls := result.URLs
+			if tt.unordered {
+				urls = append([]string(nil), urls...)
+				sort.Strings(urls)
+			}
+			if !reflect.DeepEqual(urls, tt.urls) {
+				t.Errorf("urls = %q, want %q", urls, tt.urls)
+			}
+			if result.HasNextPage != tt.next {
+				t.Errorf("has next page = %v, want %v", result.HasNextPage, tt.next)
+			}
+			if result.TotalResults != tt.total {
+				t.Errorf("total results = %q, want %q", result.TotalResults, tt.total)
+			}
+		})
+	}
+}
+
+func TestParseStartpageToken(t *testing.T) {
+	if token := parseStartpageToken(readFixture(t, "startpage.html")); token != "AbC123xyz20" {
+		t.Errorf("token = %q, want %q", token, "AbC123xyz20")
+	}
+}
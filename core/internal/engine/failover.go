@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google-dork-parser/core/internal/proxy"
+)
+
+// FailoverConfig holds engine failover configuration
+type FailoverConfig struct {
+	// Order is the chain a blocked task moves down; a task starting on an
+	// engine outside it moves to its first engine
+	Order []EngineType
+
+	// Attempts is how many blocked or CAPTCHA answers an engine gives a
+	// task, each through a fresh proxy, before the task moves on
+	Attempts int
+}
+
+// DefaultFailoverConfig returns default failover configuration
+func DefaultFailoverConfig() FailoverConfig {
+	return FailoverConfig{
+		Order:    []EngineType{EngineTypeGoogle, EngineTypeBing, EngineTypeDuckDuckGo},
+		Attempts: 2,
+	}
+}
+
+// Failover runs a task on its engine and, when that engine keeps blocking
+// it, on the next enabled engine of a failover chain. Only blocks, CAPTCHAs
+// and a non-blocking rate limiter's refusals move a task on; other failures
+// are the task's own.
+type Failover struct {
+	registry *Registry
+	balancer *Balancer // Fed each attempt's outcome when set
+	config   FailoverConfig
+}
+
+// NewFailover creates a failover runner over the registry's engines.
+// balancer may be nil; when set, every attempt feeds its engine's block
+// rate.
+func NewFailover(registry *Registry, balancer *Balancer, config FailoverConfig) *Failover {
+	if config.Attempts <= 0 {
+		config.Attempts = DefaultFailoverConfig().Attempts
+	}
+	return &Failover{registry: registry, balancer: balancer, config: config}
+}
+
+// Chain returns the engines a task starting on first is tried on, in
+// order: first, then the enabled engines of the failover order after it.
+// Disabled engines are skipped, but first is tried even when it is
+// disabled, as a task may name it.
+func (f *Failover) Chain(first EngineType) []EngineType {
+	chain := []EngineType{first}
+
+	rest := f.config.Order
+	for i, engineType := range rest {
+		if engineType == first {
+			rest = rest[i+1:]
+			break
+		}
+	}
+	for _, engineType := range rest {
+		config, ok := f.registry.GetConfig(engineType)
+		if _, registered := f.registry.Get(engineType); !ok || !registered || !config.Enabled {
+			continue
+		}
+		if !containsEngine(chain, engineType) {
+			chain = append(chain, engineType)
+		}
+	}
+	return chain
+}
+
+// Search runs request on first, with a proxy from proxyGetter for each
+// attempt, or request.Proxy when proxyGetter is nil. After Attempts
+// blocked answers, or at once when the engine is over its rate limit, the
+// task moves to the next engine of its chain, its domain and continuation
+// left behind as they belong to the engine before. The response's
+// EngineUsed names the engine that answered; when every engine refused
+// the task, the last refusal is returned.
+func (f *Failover) Search(ctx context.Context, first EngineType, request *SearchRequest, proxyGetter func() *proxy.Proxy) (*SearchResponse, error) {
+	var response *SearchResponse
+	var err error
+
+	for i, engineType := range f.Chain(first) {
+		engine, ok := f.registry.Get(engineType)
+		if !ok {
+			continue
+		}
+
+		attempt := *request
+		if i > 0 {
+			attempt.Domain = ""
+			attempt.Continuation = nil
+		}
+
+		for n := 0; n < f.config.Attempts; n++ {
+			if proxyGetter != nil {
+				attempt.Proxy = proxyGetter()
+			}
+			attempt.RetryCount = request.RetryCount + n
+
+			response, err = engine.Search(ctx, &attempt)
+			if errors.Is(err, ErrRateLimited) {
+				// Nothing was sent, so there is no outcome to record and
+				// no point in retrying the engine now
+				break
+			}
+			if f.balancer != nil {
+				f.balancer.Record(engineType, response)
+			}
+			if !response.Blocked && !response.Captcha {
+				return response, err
+			}
+			if ctx.Err() != nil {
+				return response, ctx.Err()
+			}
+		}
+	}
+
+	if response == nil {
+		return &SearchResponse{
+			RequestID: request.ID,
+			Dork:      request.Dork,
+			Page:      request.Page,
+		}, NewSearchError(ErrorTypeUnknown, "no engine registered to run the task", nil)
+	}
+	return response, err
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/proxy"
+)
+
+// fusionRank damps how much a URL's rank on one engine weighs against its
+// being found by several: a URL scores weight/(fusionRank+rank) on each
+// engine that returns it
+const fusionRank = 60
+
+// FanOut runs a dork on every enabled engine with a positive weight at
+// once and merges what they find into one list of URLs. Where Balancer
+// sends each task to one engine, FanOut sends it to all of them, for
+// dorks worth the extra requests.
+type FanOut struct {
+	registry *Registry
+	balancer *Balancer // Fed each engine's outcome when set
+}
+
+// FanOutResponse is the merged outcome of a fan-out search
+type FanOutResponse struct {
+	Dork    string
+	Page    int
+	Results []MergedResult // Best first
+
+	// Responses holds each engine's own response, failed ones included
+	Responses map[EngineType]*SearchResponse
+}
+
+// MergedResult is a URL found by one or more engines of a fan-out search
+type MergedResult struct {
+	URL     string
+	Engines []EngineType // Engines that returned it, highest weight first
+	Score   float64      // Sum of weight/(fusionRank+rank) over Engines
+}
+
+// NewFanOut creates a fan-out coordinator over the registry's engines.
+// balancer may be nil; when set, every engine's outcome feeds its block
+// rate, as if the engine had been picked.
+func NewFanOut(registry *Registry, balancer *Balancer) *FanOut {
+	return &FanOut{registry: registry, balancer: balancer}
+}
+
+// Engines returns the engines a fan-out search runs on: the enabled ones
+// with a positive weight, highest weight first. Engines with weight 0 run
+// only tasks naming them, so they are left out.
+func (f *FanOut) Engines() []EngineType {
+	var types []EngineType
+	weights := make(map[EngineType]float64)
+	for _, engineType := range f.registry.GetEnabledTypes() {
+		config, _ := f.registry.GetConfig(engineType)
+		if config.Weight > 0 {
+			types = append(types, engineType)
+			weights[engineType] = config.Weight
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool { return weights[types[i]] > weights[types[j]] })
+	return types
+}
+
+// Search runs request on every fan-out engine concurrently, each with a
+// proxy of its own from proxyGetter, or request.Proxy when proxyGetter is
+// nil. The request's domain and continuation belong to one engine, so
+// they are not passed on. It fails only when no engine answered; the
+// merged results of the engines that did are returned either way.
+func (f *FanOut) Search(ctx context.Context, request *SearchRequest, proxyGetter func() *proxy.Proxy) (*FanOutResponse, error) {
+	response := &FanOutResponse{
+		Dork:      request.Dork,
+		Page:      request.Page,
+		Responses: make(map[EngineType]*SearchResponse),
+	}
+
+	types := f.Engines()
+	if len(types) == 0 {
+		return response, NewSearchError(ErrorTypeUnknown, "no enabled engine with a positive weight", nil)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, engineType := range types {
+		engine, ok := f.registry.Get(engineType)
+		if !ok {
+			continue
+		}
+
+		engineRequest := *request
+		engineRequest.ID = fmt.Sprintf("%s-%s", request.ID, engineType)
+		engineRequest.Domain = ""
+		engineRequest.Continuation = nil
+		if proxyGetter != nil {
+			engineRequest.Proxy = proxyGetter()
+		}
+
+		wg.Add(1)
+		go func(engineType EngineType, engine Engine, engineRequest *SearchRequest) {
+			defer wg.Done()
+
+			engineResponse, err := engine.Search(ctx, engineRequest)
+			if engineResponse == nil {
+				engineResponse = &SearchResponse{
+					RequestID:  engineRequest.ID,
+					Dork:       engineRequest.Dork,
+					Page:       engineRequest.Page,
+					EngineUsed: engine.Name(),
+				}
+			}
+			if err != nil && engineResponse.Error == nil {
+				engineResponse.Error = err
+			}
+			if f.balancer != nil {
+				f.balancer.Record(engineType, engineResponse)
+			}
+
+			mu.Lock()
+			response.Responses[engineType] = engineResponse
+			mu.Unlock()
+		}(engineType, engine, &engineRequest)
+	}
+	wg.Wait()
+
+	response.Results = f.merge(types, response.Responses)
+
+	for _, engineResponse := range response.Responses {
+		if engineResponse.Error == nil {
+			return response, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return response, err
+	}
+	return response, NewSearchError(ErrorTypeUnknown, "every fan-out engine failed", nil)
+}
+
+// merge fuses the engines' URL lists, weighting each engine's ranks by its
+// configured weight. URLs are deduplicated on their normalized form; the
+// first engine in types to return one gives its spelling.
+func (f *FanOut) merge(types []EngineType, responses map[EngineType]*SearchResponse) []MergedResult {
+	var results []MergedResult
+	index := make(map[string]int)
+
+	for _, engineType := range types {
+		engineResponse := responses[engineType]
+		if engineResponse == nil || engineResponse.Error != nil {
+			continue
+		}
+		config, _ := f.registry.GetConfig(engineType)
+
+		for rank, link := range engineResponse.URLs {
+			key := parser.NormalizeURL(link)
+			i, ok := index[key]
+			if !ok {
+				i = len(results)
+				index[key] = i
+				results = append(results, MergedResult{URL: link})
+			} else if containsEngine(results[i].Engines, engineType) {
+				continue
+			}
+			results[i].Engines = append(results[i].Engines, engineType)
+			results[i].Score += config.Weight / float64(fusionRank+rank+1)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+// containsEngine reports whether engines holds engineType
+func containsEngine(engines []EngineType, engineType EngineType) bool {
+	for _, e := range engines {
+		if e == engineType {
+			return true
+		}
+	}
+	return false
+}
+
+// URLs returns the merged URLs, best first
+func (r *FanOutResponse) URLs() []string {
+	urls := make([]string, len(r.Results))
+	for i, result := range r.Results {
+		urls[i] = result.URL
+	}
+	return urls
+}
@@ -2,14 +2,12 @@ package engine
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"io"
 	"math/rand"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google-dork-parser/core/internal/parser"
@@ -24,8 +22,17 @@ type Google struct {
 	domains      []string
 	resultsPerPage int
 	httpClient   *http.Client
+
+	// Page sizes found per domain. Some frontends ignore large num= values
+	// and serve 10 results whatever was asked, which would skip results
+	// when the next page's start assumes the larger size.
+	pageSizes   map[string]int
+	pageSizesMu sync.RWMutex
 }
 
+// defaultPageSize is the page size Google serves when num= is ignored
+const defaultPageSize = 10
+
 // GoogleConfig holds Google engine configuration
 type GoogleConfig struct {
 	Domains        []string
@@ -69,139 +76,131 @@ func NewGoogle(config GoogleConfig) *Google {
 		config.UserAgents = stealth.DefaultUserAgents()
 	}
 
-	return &Google{
+	g := &Google{
 		BaseEngine:     NewBaseEngine("google", config.Domains),
 		headerGen:      stealth.NewHeaderGenerator(config.UserAgents),
 		domains:        config.Domains,
 		resultsPerPage: config.ResultsPerPage,
+		pageSizes:      make(map[string]int),
 	}
+	g.SetDetector(g)
+	g.Use(HeaderProfile(g.headerGen), g.cookies)
+	return g
 }
 
 // Search performs a Google search
 func (g *Google) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
-	start := time.Now()
-
-	response := &SearchResponse{
-		RequestID:  request.ID,
-		Dork:       request.Dork,
-		Page:       request.Page,
-		EngineUsed: "google",
+	// Select a random Google domain, unless the request pins one
+	domain := request.Domain
+	if domain == "" {
+		domain = g.selectDomain()
 	}
+	pageSize := g.PageSize(domain)
 
-	// Select a random Google domain
-	domain := g.selectDomain()
-
-	// Build search URL
-	searchURL := g.buildSearchURL(domain, request.Dork, request.Page)
-
-	// Create HTTP client with proxy
-	client, err := g.createClient(request.Proxy, request.Timeout)
-	if err != nil {
-		response.Error = NewSearchError(ErrorTypeProxy, "failed to create client", err)
-		return response, err
+	// Build search URL; a continuation from the previous page avoids deep
+	// start offsets, which Google increasingly refuses
+	var searchURL string
+	if request.Continuation != nil {
+		searchURL = g.buildContinuationURL(domain, request.Dork, request.Page, request.Continuation, request.Options)
+	} else {
+		searchURL = g.buildSearchURL(domain, request.Dork, request.Page, request.Options)
 	}
 
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "google",
+			Domain:     domain,
+		}
 		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
 		return response, err
 	}
 
-	// Set headers
-	g.setHeaders(req, domain, request)
-
-	// Execute request
-	resp, err := client.Do(req)
+	// Fetch and classify the page
+	response, err := g.Do(ctx, request, req)
+	response.PageSize = pageSize
 	if err != nil {
-		if ctx.Err() != nil {
-			response.Error = NewSearchError(ErrorTypeTimeout, "request timed out", err)
-		} else {
-			response.Error = NewSearchError(ErrorTypeNetwork, "request failed", err)
-		}
 		return response, err
 	}
-	defer resp.Body.Close()
-
-	response.StatusCode = resp.StatusCode
-	response.Latency = time.Since(start)
 
-	if request.Proxy != nil {
-		response.ProxyUsed = request.Proxy.ID
+	// Parse results, by the vertical's own parser when one was searched
+	result := g.ParseVertical(response.HTML, request.Options.Type)
+	if request.Options.OrganicOnly {
+		result = result.Organic()
 	}
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+	response.Continuation = result.Continuation
 
-	// Check status code
-	if resp.StatusCode == 429 {
-		response.Error = NewSearchError(ErrorTypeRateLimit, "rate limited", nil)
-		response.Blocked = true
-		return response, response.Error
+	// Verticals lay out their own number of results, which says nothing
+	// of whether the domain honours num=
+	if request.Options.Type == SearchTypeWeb {
+		g.probePageSize(domain, pageSize, result)
 	}
 
-	if resp.StatusCode == 503 {
-		response.Error = NewSearchError(ErrorTypeBlocked, "service unavailable (likely blocked)", nil)
-		response.Blocked = true
-		return response, response.Error
-	}
+	return response, nil
+}
 
-	if resp.StatusCode != 200 {
-		response.Error = NewSearchError(ErrorTypeNetwork, fmt.Sprintf("unexpected status: %d", resp.StatusCode), nil)
-		return response, response.Error
-	}
+// PageSize returns the results per page asked of a domain: the configured
+// size, or 10 once the domain has been found to ignore larger sizes
+func (g *Google) PageSize(domain string) int {
+	g.pageSizesMu.RLock()
+	defer g.pageSizesMu.RUnlock()
 
-	// Read body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		response.Error = NewSearchError(ErrorTypeNetwork, "failed to read response", err)
-		return response, err
+	if size, ok := g.pageSizes[domain]; ok {
+		return size
 	}
+	return g.resultsPerPage
+}
 
-	html := string(body)
-	response.HTML = html
-
-	// Check for CAPTCHA
-	if g.IsCaptcha(html) {
-		response.Captcha = true
-		response.Error = NewSearchError(ErrorTypeCaptcha, "CAPTCHA detected", nil)
-		return response, response.Error
+// probePageSize checks a results page against the size asked for. A page
+// with further pages but under half the asked size means the domain
+// ignores num=, and it is asked for 10 from then on; a full page confirms
+// the size for the session.
+func (g *Google) probePageSize(domain string, asked int, result *parser.ExtractionResult) {
+	if asked <= defaultPageSize || !result.HasNextPage {
+		return
 	}
 
-	// Check for blocks
-	if g.IsBlocked(html) {
-		response.Blocked = true
-		response.Error = NewSearchError(ErrorTypeBlocked, "blocked by Google", nil)
-		return response, response.Error
+	size := asked
+	if len(result.RawURLs)*2 < asked {
+		size = defaultPageSize
 	}
 
-	// Parse results
-	result := g.ParseResponse(html)
-	response.URLs = result.URLs
-	response.RawURLs = result.RawURLs
-	response.HasNextPage = result.HasNextPage
-	response.TotalResults = result.TotalResults
+	g.pageSizesMu.Lock()
+	defer g.pageSizesMu.Unlock()
 
-	return response, nil
+	// A domain once found to ignore num= stays at 10 for the session
+	if known, ok := g.pageSizes[domain]; ok && known < size {
+		return
+	}
+	g.pageSizes[domain] = size
 }
 
 // BuildURL builds a Google search URL
 func (g *Google) BuildURL(query string, page int) string {
 	domain := g.selectDomain()
-	return g.buildSearchURL(domain, query, page)
+	return g.buildSearchURL(domain, query, page, SearchOptions{})
 }
 
-func (g *Google) buildSearchURL(domain, query string, page int) string {
-	// URL encode the query
-	encodedQuery := url.QueryEscape(query)
-
-	// Calculate start position
-	start := page * g.resultsPerPage
+func (g *Google) buildSearchURL(domain, query string, page int, options SearchOptions) string {
+	// Calculate start position from the domain's page size
+	size := g.PageSize(domain)
+	start := page * size
 
 	// Build URL with parameters
 	params := url.Values{}
 	params.Set("q", query)
-	params.Set("num", fmt.Sprintf("%d", g.resultsPerPage))
+	params.Set("num", fmt.Sprintf("%d", size))
 	params.Set("hl", "en")
 	params.Set("safe", "off")
-	params.Set("filter", "0") // Don't filter similar results
 
 	if start > 0 {
 		params.Set("start", fmt.Sprintf("%d", start))
@@ -214,48 +213,114 @@ func (g *Google) buildSearchURL(domain, query string, page int) string {
 	if rand.Float32() < 0.3 {
 		params.Set("nfpr", "1") // No auto-correction
 	}
+	setSearchOptions(params, options)
 
 	return fmt.Sprintf("https://%s/search?%s", domain, params.Encode())
+}
 
-	// Alternative simpler format:
-	// return fmt.Sprintf("https://%s/search?q=%s&num=%d&start=%d&hl=en",
-	// 	domain, encodedQuery, g.resultsPerPage, start)
-	_ = encodedQuery // Silence unused warning
+// BuildContinuationURL builds the request a "More results" button makes
+// for the batch after a page, from the page's continuation. Google answers
+// it with the next results as an HTML fragment, which ParseResponse reads
+// like a full page.
+func (g *Google) BuildContinuationURL(query string, page int, cont *parser.Continuation) string {
+	domain := g.selectDomain()
+	return g.buildContinuationURL(domain, query, page, cont, SearchOptions{})
 }
 
-func (g *Google) selectDomain() string {
-	if len(g.domains) == 0 {
-		return "www.google.com"
+func (g *Google) buildContinuationURL(domain, query string, page int, cont *parser.Continuation, options SearchOptions) string {
+	start := cont.Start
+	if start == 0 {
+		start = page * g.PageSize(domain)
 	}
-	return g.domains[rand.Intn(len(g.domains))]
-}
 
-func (g *Google) setHeaders(req *http.Request, domain string, sr *SearchRequest) {
-	// Generate stealth headers
-	headers := g.headerGen.GenerateForSearch(domain, sr.Page > 0)
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("hl", "en")
+	params.Set("safe", "off")
+	params.Set("sa", "N")
+	setSearchOptions(params, options)
+	if cont.EI != "" {
+		params.Set("ei", cont.EI)
+	}
+	if cont.Token != "" {
+		params.Set("sstk", cont.Token)
+	}
 
-	// Apply generated headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	// The async container is named after the session and offset, with a
+	// literal 1 between them; without a session id the button's request
+	// cannot be reproduced, so the plain next page is asked for instead
+	id := cont.ArcID
+	if id == "" {
+		id = cont.EI
+	}
+	async := cont.Async && id != ""
+	if async {
+		arc := fmt.Sprintf("srp_%s_1%d", id, start)
+		params.Set("async", fmt.Sprintf("arc_id:%s,ffilt:all,ve_name:MoreResultsContainer,use_ac:true,inf:1,_id:arc-%s,_pms:s,_fmt:pc", arc, arc))
 	}
 
-	// Override with custom user agent if provided
-	if sr.UserAgent != "" {
-		req.Header.Set("User-Agent", sr.UserAgent)
+	// The async container carries the position itself; start= is only
+	// needed when the page offered no way to continue without it
+	if !async {
+		params.Set("start", fmt.Sprintf("%d", start))
 	}
 
-	// Apply any custom headers from request
-	for key, value := range sr.Headers {
-		req.Header.Set(key, value)
+	return fmt.Sprintf("https://%s/search?%s", domain, params.Encode())
+}
+
+// googleDateLayout is how Google's custom date range writes a day
+const googleDateLayout = "1/2/2006"
+
+// setSearchOptions sets the parameters of a search's options: tbm for a
+// vertical, filter=0 unless filtering is wanted, nfpr=1 and tbs=li:1 for
+// verbatim searches, and the time range as tbs=qdr:<period> or a custom
+// cdr range
+func setSearchOptions(params url.Values, options SearchOptions) {
+	if tbm, ok := googleVerticals[options.Type]; ok {
+		params.Set("tbm", tbm)
+	}
+	if !options.Filter {
+		params.Set("filter", "0")
+	}
+
+	var tbs []string
+	if !options.DateFrom.IsZero() || !options.DateTo.IsZero() {
+		tbs = append(tbs, "cdr:1")
+		if !options.DateFrom.IsZero() {
+			tbs = append(tbs, "cd_min:"+options.DateFrom.Format(googleDateLayout))
+		}
+		if !options.DateTo.IsZero() {
+			tbs = append(tbs, "cd_max:"+options.DateTo.Format(googleDateLayout))
+		}
+	} else {
+		switch options.TimeRange {
+		case TimeRangeHour, TimeRangeDay, TimeRangeWeek, TimeRangeMonth, TimeRangeYear:
+			tbs = append(tbs, "qdr:"+string(options.TimeRange))
+		}
+	}
+	if options.Verbatim {
+		params.Set("nfpr", "1")
+		tbs = append(tbs, "li:1")
+	}
+	if len(tbs) > 0 {
+		params.Set("tbs", strings.Join(tbs, ","))
 	}
+}
 
-	// Ensure critical headers are set
-	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", stealth.RandomUserAgent())
+func (g *Google) selectDomain() string {
+	if len(g.domains) == 0 {
+		return "www.google.com"
 	}
+	return g.domains[rand.Intn(len(g.domains))]
+}
 
-	// Add cookies to look more legitimate
-	req.Header.Set("Cookie", g.generateCookies())
+// cookies adds Google's consent cookies to each request, over any the
+// search set
+func (g *Google) cookies(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		ex.Request.Header.Set("Cookie", g.generateCookies())
+		return next(ctx, ex)
+	}
 }
 
 func (g *Google) generateCookies() string {
@@ -276,93 +341,6 @@ func (g *Google) generateCookies() string {
 	return strings.Join(cookies, "; ")
 }
 
-func (g *Google) createClient(p *proxy.Proxy, timeout time.Duration) (*http.Client, error) {
-	if timeout == 0 {
-		timeout = 30 * time.Second
-	}
-
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   timeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: false,
-			MinVersion:         tls.VersionTLS12,
-		},
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		DisableCompression:    false,
-	}
-
-	// Configure proxy if provided
-	if p != nil {
-		proxyURL, err := url.Parse(p.URL())
-		if err != nil {
-			return nil, fmt.Errorf("invalid proxy URL: %w", err)
-		}
-
-		switch p.Protocol {
-		case proxy.ProtocolHTTP, proxy.ProtocolHTTPS:
-			transport.Proxy = http.ProxyURL(proxyURL)
-
-		case proxy.ProtocolSOCKS4, proxy.ProtocolSOCKS5:
-			// For SOCKS, we need to use a custom dialer
-			dialer, err := g.createSOCKSDialer(p, timeout)
-			if err != nil {
-				return nil, err
-			}
-			transport.DialContext = dialer
-
-		default:
-			return nil, fmt.Errorf("unsupported proxy protocol: %s", p.Protocol)
-		}
-	}
-
-	return &http.Client{
-		Transport: transport,
-		Timeout:   timeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Allow up to 5 redirects
-			if len(via) >= 5 {
-				return fmt.Errorf("too many redirects")
-			}
-			// Copy headers to redirect request
-			for key, values := range via[0].Header {
-				for _, value := range values {
-					req.Header.Add(key, value)
-				}
-			}
-			return nil
-		},
-	}, nil
-}
-
-func (g *Google) createSOCKSDialer(p *proxy.Proxy, timeout time.Duration) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
-	proxyAddr := fmt.Sprintf("%s:%s", p.Host, p.Port)
-
-	return func(ctx context.Context, network, addr string) (net.Conn, error) {
-		// Create a basic TCP connection to the SOCKS proxy
-		dialer := &net.Dialer{
-			Timeout:   timeout,
-			KeepAlive: 30 * time.Second,
-		}
-
-		conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
-		if err != nil {
-			return nil, err
-		}
-
-		// For full SOCKS5 support, you'd implement the handshake here
-		// For now, we'll rely on the proxy package in health.go
-		// This is a simplified version
-
-		return conn, nil
-	}, nil
-}
-
 // GetDomains returns Google domains
 func (g *Google) GetDomains() []string {
 	return g.domains
@@ -382,6 +360,13 @@ func (g *Google) AddDomain(domain string) {
 func (g *Google) SearchMultiplePages(ctx context.Context, dork string, maxPages int, proxyGetter func() *proxy.Proxy, delay time.Duration) ([]*SearchResponse, error) {
 	responses := make([]*SearchResponse, 0, maxPages)
 
+	// Each page is requested with the previous page's continuation when it
+	// had one, and by start offset otherwise. The pages stay on the first
+	// page's domain, whose session the continuation belongs to and whose
+	// page size the offsets are counted in.
+	var cont *parser.Continuation
+	var domain string
+
 	for page := 0; page < maxPages; page++ {
 		// Check context
 		select {
@@ -400,11 +385,20 @@ func (g *Google) SearchMultiplePages(ctx context.Context, dork string, maxPages
 			Page:    page,
 			Proxy:   p,
 			Timeout: 30 * time.Second,
+
+			Continuation: cont,
+			Domain:       domain,
 		}
 
-		// Execute search
+		// Execute search; a continuation Google refuses outright, rather than
+		// for blocking the proxy, is retried once by start offset
 		response, err := g.Search(ctx, request)
+		if err != nil && cont != nil && response.StatusCode >= 400 && !response.Blocked {
+			request.Continuation = nil
+			response, err = g.Search(ctx, request)
+		}
 		responses = append(responses, response)
+		domain = response.Domain
 
 		// Stop if error or no more pages
 		if err != nil {
@@ -416,6 +410,7 @@ func (g *Google) SearchMultiplePages(ctx context.Context, dork string, maxPages
 		if len(response.URLs) == 0 {
 			break
 		}
+		cont = response.Continuation
 
 		// Delay between pages
 		if delay > 0 && page < maxPages-1 {
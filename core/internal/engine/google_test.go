@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// doPage runs a request for a page served by a test server through the
+// engine's middleware chain
+func doPage(t *testing.T, e interface {
+	Do(context.Context, *SearchRequest, *http.Request) (*SearchResponse, error)
+}, status int, page string) *SearchResponse {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(page))
+	}))
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest("GET", server.URL+"/search?q=test", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	response, _ := e.Do(context.Background(), &SearchRequest{ID: "r1", Dork: "test"}, req)
+	return response
+}
+
+func TestGoogleClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    string
+		captcha bool
+		blocked bool
+	}{
+		{
+			// Only Google's own checks know the /sorry/ CAPTCHA form
+			name:    "captcha form",
+			page:    `<html><body><form id="captcha-form" action="/sorry/index" method="post"><input type="hidden" name="continue" value="x"></form></body></html>`,
+			captcha: true,
+		},
+		{
+			name:    "recaptcha",
+			page:    `<html><body><script src="https://www.recaptcha.net/recaptcha/api.js"></script></body></html>`,
+			captcha: true,
+		},
+		{
+			name:    "sorry redirect",
+			page:    `<html><body><script>location.replace("/sorry/index?continue=x")</script></body></html>`,
+			blocked: true,
+		},
+		{
+			// Block words in ordinary results are not a block page
+			name: "results about blocking",
+			page: `<html><body><div class="g"><a href="https://example.com/403"><h3>403 Forbidden: access denied and blocked requests explained</h3></a></div></body></html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGoogle(DefaultGoogleConfig())
+			response := doPage(t, g, http.StatusOK, tt.page)
+
+			if response.Captcha != tt.captcha || response.Blocked != tt.blocked {
+				t.Errorf("captcha = %v, blocked = %v, want %v, %v (error %v)",
+					response.Captcha, response.Blocked, tt.captcha, tt.blocked, response.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+)
+
+// GoogleCSE implements the Engine interface with the Google Custom Search
+// JSON API. It needs an API key and a search engine ID (cx) set to search
+// the whole web, and no proxy: the API answers JSON to any client within
+// its quota, which makes it a fit for small runs. The API serves the first
+// 100 results of a query, ten per page, and counts each page against a
+// daily quota that resets at midnight Pacific time.
+type GoogleCSE struct {
+	*BaseEngine
+	key string
+	cx  string
+
+	quotaMu    sync.Mutex
+	dailyQuota int
+	used       int
+	quotaDay   string // Pacific date the count is for
+}
+
+// GoogleCSEConfig holds Custom Search engine configuration
+type GoogleCSEConfig struct {
+	Key        string // API key
+	CX         string // Programmable Search Engine ID
+	DailyQuota int    // Queries allowed per day; 100 is the free tier
+	Timeout    time.Duration
+}
+
+// DefaultGoogleCSEConfig returns default Custom Search configuration
+func DefaultGoogleCSEConfig() GoogleCSEConfig {
+	return GoogleCSEConfig{
+		DailyQuota: 100,
+		Timeout:    30 * time.Second,
+	}
+}
+
+const (
+	// googleCSEPageSize is the most results the API returns per query
+	googleCSEPageSize = 10
+
+	// googleCSELastPage is the last page within the API's 100 results
+	googleCSELastPage = 9
+)
+
+// NewGoogleCSE creates a new Custom Search engine
+func NewGoogleCSE(config GoogleCSEConfig) *GoogleCSE {
+	if config.DailyQuota == 0 {
+		config.DailyQuota = DefaultGoogleCSEConfig().DailyQuota
+	}
+
+	c := &GoogleCSE{
+		BaseEngine: NewBaseEngine("google_cse", []string{"www.googleapis.com"}),
+		key:        config.Key,
+		cx:         config.CX,
+		dailyQuota: config.DailyQuota,
+	}
+	// JSON is not a page: the markup checks of Classify would take result
+	// snippets about blocked or forbidden pages for a block
+	c.middleware = []Middleware{Timing, Transport}
+	c.SetDetector(c)
+	c.Use(c.quota, c.apiKey, c.apiStatus)
+	return c
+}
+
+// Search performs a Custom Search query
+func (c *GoogleCSE) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	response := &SearchResponse{
+		RequestID:  request.ID,
+		Dork:       request.Dork,
+		Page:       request.Page,
+		EngineUsed: "google_cse",
+		Domain:     "www.googleapis.com",
+		PageSize:   googleCSEPageSize,
+	}
+	if c.key == "" || c.cx == "" {
+		response.Error = NewSearchError(ErrorTypeUnknown, "custom search needs an API key and a cx", nil)
+		return response, response.Error
+	}
+	if request.Page > googleCSELastPage {
+		response.Error = NewSearchError(ErrorTypeParse, "custom search serves no results past the 100th", nil)
+		return response, response.Error
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BuildURL(request.Dork, request.Page), nil)
+	if err != nil {
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err = c.Do(ctx, request, req)
+	response.PageSize = googleCSEPageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := c.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage && request.Page < googleCSELastPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// BuildURL builds a Custom Search request URL. The API key goes in a
+// header, so the URL is safe to log.
+func (c *GoogleCSE) BuildURL(query string, page int) string {
+	params := url.Values{}
+	params.Set("cx", c.cx)
+	params.Set("q", query)
+	params.Set("num", strconv.Itoa(googleCSEPageSize))
+	if page > 0 {
+		params.Set("start", strconv.Itoa(page*googleCSEPageSize+1))
+	}
+	return "https://www.googleapis.com/customsearch/v1?" + params.Encode()
+}
+
+// apiKey sends the API key
+func (c *GoogleCSE) apiKey(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		ex.Request.Header.Set("X-Goog-Api-Key", c.key)
+		ex.Request.Header.Set("Accept", "application/json")
+		return next(ctx, ex)
+	}
+}
+
+// pacific is the time zone of the quota's day
+var pacific = func() *time.Location {
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		return loc
+	}
+	return time.FixedZone("PST", -8*60*60)
+}()
+
+// quota refuses queries once the day's quota is used, without asking the
+// API, and counts those it lets through
+func (c *GoogleCSE) quota(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		c.quotaMu.Lock()
+		c.rollQuotaDay()
+		if c.used >= c.dailyQuota {
+			c.quotaMu.Unlock()
+			ex.Response.Error = NewSearchError(ErrorTypeRateLimit,
+				fmt.Sprintf("custom search daily quota of %d queries used", c.dailyQuota), nil)
+			return ex.Response.Error
+		}
+		c.used++
+		c.quotaMu.Unlock()
+
+		err := next(ctx, ex)
+
+		// The API keeps its own count, which may include other clients
+		// of the key; its word ends the day
+		if ex.Response.StatusCode == http.StatusTooManyRequests {
+			c.quotaMu.Lock()
+			c.used = max(c.used, c.dailyQuota)
+			c.quotaMu.Unlock()
+		}
+		return err
+	}
+}
+
+// rollQuotaDay starts a new count on a new Pacific day (must hold quotaMu)
+func (c *GoogleCSE) rollQuotaDay() {
+	if day := time.Now().In(pacific).Format("2006-01-02"); day != c.quotaDay {
+		c.quotaDay = day
+		c.used = 0
+	}
+}
+
+// QuotaRemaining returns how many queries are left today
+func (c *GoogleCSE) QuotaRemaining() int {
+	c.quotaMu.Lock()
+	defer c.quotaMu.Unlock()
+	c.rollQuotaDay()
+	return max(c.dailyQuota-c.used, 0)
+}
+
+// apiStatus turns the API's error statuses into search errors: 429 is the
+// quota, 400 and 403 a key or cx the API refuses
+func (c *GoogleCSE) apiStatus(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+
+		response := ex.Response
+		switch response.StatusCode {
+		case http.StatusOK:
+			return nil
+		case http.StatusTooManyRequests:
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeRateLimit, "custom search quota exceeded", nil)
+		case http.StatusBadRequest, http.StatusForbidden:
+			response.Error = NewSearchError(ErrorTypeUnknown,
+				fmt.Sprintf("custom search refused the key or cx (%d)", response.StatusCode), nil)
+		default:
+			response.Error = NewSearchError(ErrorTypeNetwork, fmt.Sprintf("unexpected status: %d", response.StatusCode), nil)
+		}
+		return response.Error
+	}
+}
+
+// googleCSEResponse is the part of an API response the engine reads
+type googleCSEResponse struct {
+	Items []struct {
+		Link string `json:"link"`
+	} `json:"items"`
+	SearchInformation struct {
+		TotalResults string `json:"totalResults"`
+	} `json:"searchInformation"`
+	Queries struct {
+		NextPage []json.RawMessage `json:"nextPage"`
+	} `json:"queries"`
+}
+
+// ParseResponse parses an API response. Items map to URLs in their order;
+// a response that is not API JSON gives none.
+func (c *GoogleCSE) ParseResponse(body string) *parser.ExtractionResult {
+	var decoded googleCSEResponse
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		return c.GetExtractor().ExtractURLs(nil)
+	}
+
+	links := make([]string, 0, len(decoded.Items))
+	for _, item := range decoded.Items {
+		if item.Link != "" {
+			links = append(links, item.Link)
+		}
+	}
+
+	result := c.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = len(decoded.Queries.NextPage) > 0
+	result.TotalResults = decoded.SearchInformation.TotalResults
+	return result
+}
+
+// IsBlocked reports false: the API refuses with statuses, not pages
+func (c *GoogleCSE) IsBlocked(body string) bool {
+	return false
+}
+
+// IsCaptcha reports false: the API has no CAPTCHA
+func (c *GoogleCSE) IsCaptcha(body string) bool {
+	return false
+}
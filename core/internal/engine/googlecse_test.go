@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoogleCSEQuota(t *testing.T) {
+	today := time.Now().In(pacific).Format("2006-01-02")
+	yesterday := time.Now().In(pacific).AddDate(0, 0, -1).Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		quotaDay  string
+		used      int
+		status    int
+		sent      bool
+		errType   SearchErrorType
+		remaining int
+	}{
+		{
+			name:      "quota left",
+			quotaDay:  today,
+			used:      3,
+			status:    http.StatusOK,
+			sent:      true,
+			remaining: 1,
+		},
+		{
+			// A spent quota is not asked about again until the next day
+			name:      "quota used today",
+			quotaDay:  today,
+			used:      5,
+			status:    http.StatusOK,
+			errType:   ErrorTypeRateLimit,
+			remaining: 0,
+		},
+		{
+			name:      "quota used yesterday",
+			quotaDay:  yesterday,
+			used:      5,
+			status:    http.StatusOK,
+			sent:      true,
+			remaining: 4,
+		},
+		{
+			// The API's own count may include other clients of the key
+			name:      "api out of quota",
+			quotaDay:  today,
+			used:      1,
+			status:    http.StatusTooManyRequests,
+			sent:      true,
+			errType:   ErrorTypeRateLimit,
+			remaining: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"items": []}`))
+			}))
+			defer server.Close()
+
+			config := DefaultGoogleCSEConfig()
+			config.Key, config.CX, config.DailyQuota = "key", "cx", 5
+			c := NewGoogleCSE(config)
+			c.quotaDay, c.used = tt.quotaDay, tt.used
+
+			req, err := http.NewRequest("GET", server.URL+"/customsearch/v1?q=test", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			response, _ := c.Do(context.Background(), &SearchRequest{ID: "r1", Dork: "test"}, req)
+
+			if sent := hits.Load() > 0; sent != tt.sent {
+				t.Errorf("request sent = %v, want %v", sent, tt.sent)
+			}
+			var searchErr *SearchError
+			if tt.errType == "" {
+				if response.Error != nil {
+					t.Errorf("unexpected error: %v", response.Error)
+				}
+			} else if !errors.As(response.Error, &searchErr) || searchErr.Type != tt.errType {
+				t.Errorf("error = %v, want a %s error", response.Error, tt.errType)
+			}
+			if remaining := c.QuotaRemaining(); remaining != tt.remaining {
+				t.Errorf("quota remaining = %d, want %d", remaining, tt.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google-dork-parser/core/internal/parser"
+)
+
+// googleVerticals maps search types to Google's tbm parameter
+var googleVerticals = map[SearchType]string{
+	SearchTypeNews:   "nws",
+	SearchTypeImages: "isch",
+	SearchTypeVideos: "vid",
+}
+
+var (
+	// News results are cards, SoaBEf in the current layout and dbsr in
+	// the older one, whose first link is the article
+	googleNewsItemPattern = regexp.MustCompile(`<div[^>]+class="(?:[^"]*\s)?(?:SoaBEf|dbsr)\b`)
+
+	// Video results are cards, RzdJxc or dXiKIc, whose first link is the
+	// video's page
+	googleVideoItemPattern = regexp.MustCompile(`<div[^>]+class="(?:[^"]*\s)?(?:RzdJxc|dXiKIc)\b`)
+
+	// The first link of a card, direct or through Google's /url redirect
+	googleItemLinkPattern = regexp.MustCompile(`<a[^>]+href="(https?://[^"]+|/url\?[^"]+)"`)
+
+	// Image results link the page an image is on, not the image: as
+	// imgrefurl in /imgres links, in the "2003" entry of the results'
+	// metadata, or through /url in the basic layout
+	googleImageRefPattern  = regexp.MustCompile(`[?&](?:amp;)?imgrefurl=([^&"]+)`)
+	googleImageMetaPattern = regexp.MustCompile(`"2003":\[null,"[^"]*","((?:[^"\\]|\\.)+)"`)
+	googleRedirectPattern  = regexp.MustCompile(`href="(/url\?[^"]+)"`)
+)
+
+// ParseVertical parses the results page of a search type. Pages, the
+// pager and the result count are read as on the web; only the results
+// differ. A vertical page without any result its parser knows falls back
+// to the web parser, so a layout change degrades instead of losing every
+// result. Video results mostly link YouTube, which is excluded like
+// Google's other domains.
+func (g *Google) ParseVertical(page string, searchType SearchType) *parser.ExtractionResult {
+	result := g.ParseResponse(page)
+
+	var links []string
+	switch searchType {
+	case SearchTypeNews:
+		links = googleItemLinks(page, googleNewsItemPattern)
+	case SearchTypeVideos:
+		links = googleItemLinks(page, googleVideoItemPattern)
+	case SearchTypeImages:
+		links = googleImagePages(page)
+	default:
+		return result
+	}
+	if len(links) == 0 {
+		return result
+	}
+
+	vertical := g.GetExtractor().ExtractURLs(links)
+	result.URLs = vertical.URLs
+	result.RawURLs = vertical.RawURLs
+	result.Types = vertical.Types
+	return result
+}
+
+// googleItemLinks returns the first link of each card, in page order
+func googleItemLinks(page string, itemPattern *regexp.Regexp) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, itemPattern) {
+		match := googleItemLinkPattern.FindStringSubmatch(item)
+		if match == nil {
+			continue
+		}
+		link := unwrapGoogleURL(html.UnescapeString(match[1]))
+		if link != "" && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// googleImagePages returns the pages image results are on, in page order
+func googleImagePages(page string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	add := func(link string) {
+		if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+
+	for _, match := range googleImageRefPattern.FindAllStringSubmatch(page, -1) {
+		if link, err := url.QueryUnescape(match[1]); err == nil {
+			add(link)
+		}
+	}
+	for _, match := range googleImageMetaPattern.FindAllStringSubmatch(page, -1) {
+		if link, err := strconv.Unquote(`"` + match[1] + `"`); err == nil {
+			add(link)
+		}
+	}
+	if len(links) == 0 {
+		for _, match := range googleRedirectPattern.FindAllStringSubmatch(page, -1) {
+			add(unwrapGoogleURL(html.UnescapeString(match[1])))
+		}
+	}
+	return links
+}
+
+// unwrapGoogleURL returns the target of a /url?q= redirect, or an absolute
+// link as it is; a redirect without a target gives ""
+func unwrapGoogleURL(link string) string {
+	rest, ok := strings.CutPrefix(link, "/url?")
+	if !ok {
+		return link
+	}
+	query, err := url.ParseQuery(rest)
+	if err != nil {
+		return ""
+	}
+	if target := query.Get("q"); target != "" {
+		return target
+	}
+	return query.Get("url")
+}
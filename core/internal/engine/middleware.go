@@ -0,0 +1,471 @@
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/google-dork-parser/core/internal/proxy"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Exchange is one search request on its way through the middleware chain
+type Exchange struct {
+	Search   *SearchRequest  // The search being made
+	Request  *http.Request   // The HTTP request for it
+	Client   *http.Client    // Client to send it with, set by Transport
+	Response *SearchResponse // Filled in as the chain runs
+	Body     string          // Page HTML, once fetched
+	Waited   time.Duration   // Time spent on rate limits, left out of Latency
+}
+
+// Handler executes an exchange
+type Handler func(ctx context.Context, ex *Exchange) error
+
+// Middleware wraps a handler with a step of request execution
+type Middleware func(next Handler) Handler
+
+// Chain composes middleware around a handler; the first middleware is
+// the outermost
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// DefaultMiddleware returns the steps every engine's requests go through:
+// timing, transport selection and classification of the response
+func (e *BaseEngine) DefaultMiddleware() []Middleware {
+	return []Middleware{Timing, Transport, e.Classify}
+}
+
+// Use appends middleware to the engine's chain, inside the middleware
+// already there; requests already under way keep the chain they started with
+func (e *BaseEngine) Use(middleware ...Middleware) {
+	e.middlewareMu.Lock()
+	e.middleware = append(e.middleware, middleware...)
+	e.middlewareMu.Unlock()
+}
+
+// Do executes an engine's search request through its middleware chain and
+// returns the response with the page HTML, leaving only parsing to the
+// engine. Failures are reported both as the error and in the response.
+func (e *BaseEngine) Do(ctx context.Context, search *SearchRequest, req *http.Request) (*SearchResponse, error) {
+	ex := &Exchange{
+		Search:  search,
+		Request: req.WithContext(ctx),
+		Response: &SearchResponse{
+			RequestID:  search.ID,
+			Dork:       search.Dork,
+			Page:       search.Page,
+			EngineUsed: e.name,
+			Domain:     req.URL.Host,
+		},
+	}
+	if search.Proxy != nil {
+		ex.Response.ProxyUsed = search.Proxy.ID
+	}
+
+	// Custom headers go on last, so that they win over every generated one
+	e.middlewareMu.RLock()
+	middleware := append(e.middleware[:len(e.middleware):len(e.middleware)], e.CustomHeaders)
+	e.middlewareMu.RUnlock()
+	err := Chain(fetch, middleware...)(ctx, ex)
+	return ex.Response, err
+}
+
+// SetCustomHeaders sets headers added to each of the engine's requests.
+// Values are templates: {domain}, {engine} and {page} are replaced by the
+// request's domain, the engine's name and the page number.
+func (e *BaseEngine) SetCustomHeaders(headers map[string]string) {
+	copied := make(map[string]string, len(headers))
+	for key, value := range headers {
+		copied[key] = value
+	}
+
+	e.customHeadersMu.Lock()
+	defer e.customHeadersMu.Unlock()
+	e.customHeaders = copied
+}
+
+// CustomHeaders sets the engine's custom headers on the request, then the
+// search's own headers over them; a header whose value expands to ""
+// is removed instead, so a task can drop one the engine sets
+func (e *BaseEngine) CustomHeaders(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		e.customHeadersMu.RLock()
+		custom := e.customHeaders
+		e.customHeadersMu.RUnlock()
+
+		if len(custom) == 0 && len(ex.Search.Headers) == 0 {
+			return next(ctx, ex)
+		}
+
+		template := strings.NewReplacer(
+			"{domain}", ex.Request.URL.Host,
+			"{engine}", e.name,
+			"{page}", strconv.Itoa(ex.Search.Page),
+		)
+		for _, headers := range []map[string]string{custom, ex.Search.Headers} {
+			for key, value := range headers {
+				if value = template.Replace(value); value == "" {
+					ex.Request.Header.Del(key)
+				} else {
+					ex.Request.Header.Set(key, value)
+				}
+			}
+		}
+		return next(ctx, ex)
+	}
+}
+
+// fetch sends the request and reads the page; it ends every chain
+func fetch(ctx context.Context, ex *Exchange) error {
+	client := ex.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(ex.Request)
+	if err != nil {
+		if ctx.Err() != nil {
+			ex.Response.Error = NewSearchError(ErrorTypeTimeout, "request timed out", err)
+		} else {
+			ex.Response.Error = NewSearchError(ErrorTypeNetwork, "request failed", err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	ex.Response.StatusCode = resp.StatusCode
+	if resp.StatusCode != 200 {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ex.Response.Error = NewSearchError(ErrorTypeNetwork, "failed to read response", err)
+		return err
+	}
+	ex.Body = string(body)
+	ex.Response.HTML = ex.Body
+	return nil
+}
+
+// Timing records how long the rest of the chain took, rate limit waits
+// aside
+func Timing(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		start := time.Now()
+		err := next(ctx, ex)
+		ex.Response.Latency = time.Since(start) - ex.Waited
+		return err
+	}
+}
+
+// Transport picks the HTTP client for the request's proxy and timeout
+func Transport(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		client, err := newClient(ex.Search.Proxy, ex.Search.Timeout)
+		if err != nil {
+			ex.Response.Error = NewSearchError(ErrorTypeProxy, "failed to create client", err)
+			return err
+		}
+		ex.Client = client
+		return next(ctx, ex)
+	}
+}
+
+// Classify turns rate limiting, blocking and CAPTCHA pages into errors,
+// recognizing the pages by the engine's detector
+func (e *BaseEngine) Classify(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+
+		response := ex.Response
+		switch {
+		case response.StatusCode == 429:
+			response.Error = NewSearchError(ErrorTypeRateLimit, "rate limited", nil)
+			response.Blocked = true
+		case response.StatusCode == 503:
+			response.Error = NewSearchError(ErrorTypeBlocked, "service unavailable (likely blocked)", nil)
+			response.Blocked = true
+		case response.StatusCode != 200:
+			response.Error = NewSearchError(ErrorTypeNetwork, fmt.Sprintf("unexpected status: %d", response.StatusCode), nil)
+		case e.detector.IsCaptcha(ex.Body):
+			response.Captcha = true
+			response.Error = NewSearchError(ErrorTypeCaptcha, "CAPTCHA detected", nil)
+		case e.detector.IsBlocked(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, fmt.Sprintf("blocked by %s", e.name), nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+// HeaderProfile sets a browser header profile on the request, with the
+// search's own user agent over it
+func HeaderProfile(gen *stealth.HeaderGenerator) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ex *Exchange) error {
+			req, search := ex.Request, ex.Search
+
+			for key, value := range gen.GenerateForSearch(req.URL.Host, search.Page > 0) {
+				req.Header.Set(key, value)
+			}
+			if search.UserAgent != "" {
+				req.Header.Set("User-Agent", search.UserAgent)
+			}
+			if req.Header.Get("User-Agent") == "" {
+				req.Header.Set("User-Agent", stealth.RandomUserAgent())
+			}
+			return next(ctx, ex)
+		}
+	}
+}
+
+// newClient creates an HTTP client going through a proxy, or direct when
+// the proxy is nil
+func newClient(p *proxy.Proxy, timeout time.Duration) (*http.Client, error) {
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: false,
+			MinVersion:         tls.VersionTLS12,
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableCompression:    false,
+	}
+
+	// Configure proxy if provided
+	if p != nil {
+		proxyURL, err := url.Parse(p.URL())
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+
+		switch p.Protocol {
+		case proxy.ProtocolHTTP, proxy.ProtocolHTTPS:
+			transport.Proxy = http.ProxyURL(proxyURL)
+
+		case proxy.ProtocolSOCKS4, proxy.ProtocolSOCKS5:
+			// For SOCKS, we need to use a custom dialer
+			transport.DialContext = socksDialer(p, timeout)
+
+		default:
+			return nil, fmt.Errorf("unsupported proxy protocol: %s", p.Protocol)
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Allow up to 5 redirects
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			// Copy headers to redirect request
+			for key, values := range via[0].Header {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+func socksDialer(p *proxy.Proxy, timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := fmt.Sprintf("%s:%s", p.Host, p.Port)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		// Create a basic TCP connection to the SOCKS proxy
+		dialer := &net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}
+
+		conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		// For full SOCKS5 support, you'd implement the handshake here
+		// For now, we'll rely on the proxy package in health.go
+		// This is a simplified version
+
+		return conn, nil
+	}
+}
+
+// cjkCharsetPattern matches the charset a page declares in a meta tag
+var cjkCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset=["']?([\w-]+)`)
+
+// DecodeCJK converts a page that is not UTF-8 to it, from the charset the
+// page declares, else from GB18030, the superset of GBK and GB2312 that
+// Chinese pages not in UTF-8 are mostly written in. Middleware reading
+// the page must come before it in the chain.
+func DecodeCJK(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil || utf8.ValidString(ex.Body) {
+			return err
+		}
+
+		label := "gb18030"
+		if match := cjkCharsetPattern.FindStringSubmatch(ex.Body); match != nil {
+			label = match[1]
+		}
+		reader, err := charset.NewReaderLabel(label, strings.NewReader(ex.Body))
+		if err != nil {
+			// An unknown charset is left as it is; the page may parse anyway
+			return nil
+		}
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return nil
+		}
+		ex.Body = string(decoded)
+		ex.Response.HTML = ex.Body
+		return nil
+	}
+}
+
+const (
+	// redirectResolveLimit is the most redirect links resolved at once
+	redirectResolveLimit = 5
+
+	// redirectPageLimit is how much of a redirect page is read for a
+	// script or meta refresh naming the target
+	redirectPageLimit = 64 << 10
+)
+
+// redirectTargetPatterns find the target of a redirect page that answers
+// 200 instead of 3xx
+var redirectTargetPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`window\.location\.replace\(\s*["']([^"']+)["']`),
+	regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh["']?[^>]+content=["'][^"']*url=\s*'?([^"'>]+)`),
+}
+
+// UnwrapRedirects returns middleware resolving the redirect links pending
+// picks out of a results page, as the page writes them. Each is requested
+// over the search's client without following it, and its target, from
+// the Location header or the script or meta refresh of a redirect page,
+// replaces the link in the page. A link that cannot be resolved is left
+// as it is.
+func UnwrapRedirects(pending func(page string) []string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ex *Exchange) error {
+			if err := next(ctx, ex); err != nil || ex.Response.StatusCode != http.StatusOK {
+				return err
+			}
+			links := pending(ex.Body)
+			if len(links) == 0 {
+				return nil
+			}
+
+			client := http.DefaultClient
+			if ex.Client != nil {
+				client = ex.Client
+			}
+			noFollow := *client
+			noFollow.CheckRedirect = func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+
+			targets := make([]string, len(links))
+			var wg sync.WaitGroup
+			slots := make(chan struct{}, redirectResolveLimit)
+			for i, link := range links {
+				wg.Add(1)
+				go func(i int, link string) {
+					defer wg.Done()
+					slots <- struct{}{}
+					defer func() { <-slots }()
+					targets[i] = resolveRedirect(ctx, &noFollow, ex.Request, html.UnescapeString(link))
+				}(i, link)
+			}
+			wg.Wait()
+
+			var replacements []string
+			for i, link := range links {
+				if targets[i] != "" {
+					replacements = append(replacements, `"`+link+`"`, `"`+html.EscapeString(targets[i])+`"`)
+				}
+			}
+			if len(replacements) > 0 {
+				ex.Body = strings.NewReplacer(replacements...).Replace(ex.Body)
+				ex.Response.HTML = ex.Body
+			}
+			return nil
+		}
+	}
+}
+
+// resolveRedirect returns the target of a redirect link found on the page
+// search requested, or "" when it has none
+func resolveRedirect(ctx context.Context, client *http.Client, search *http.Request, link string) string {
+	linkURL, err := search.URL.Parse(link)
+	if err != nil {
+		return ""
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", linkURL.String(), nil)
+	if err != nil {
+		return ""
+	}
+	req.Header = search.Header.Clone()
+	req.Header.Set("Referer", search.URL.String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var target string
+	if location := resp.Header.Get("Location"); location != "" && resp.StatusCode/100 == 3 {
+		target = location
+	} else if resp.StatusCode == http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, redirectPageLimit))
+		for _, pattern := range redirectTargetPatterns {
+			if match := pattern.FindSubmatch(body); match != nil {
+				target = html.UnescapeString(string(match[1]))
+				break
+			}
+		}
+	}
+
+	targetURL, err := linkURL.Parse(target)
+	if target == "" || err != nil || (targetURL.Scheme != "http" && targetURL.Scheme != "https") {
+		return ""
+	}
+	return targetURL.String()
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google-dork-parser/core/internal/parser"
+)
+
+func TestDecodeCJK(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "declared gbk",
+			page: "<html><head><meta charset=\"gbk\"><title>\xb0\xd9\xb6\xc8</title></head></html>",
+			want: "<title>百度</title>",
+		},
+		{
+			// Chinese pages that declare nothing are read as GB18030
+			name: "undeclared",
+			page: "<html><head><title>\xb0\xd9\xb6\xc8</title></head></html>",
+			want: "<title>百度</title>",
+		},
+		{
+			name: "declared big5",
+			page: "<html><head><meta http-equiv=\"content-type\" content=\"text/html;charset=big5\"><title>\xa4\xa4\xa4\xe5</title></head></html>",
+			want: "<title>中文</title>",
+		},
+		{
+			name: "utf-8",
+			page: "<html><head><meta charset=\"gbk\"><title>百度</title></head></html>",
+			want: "<title>百度</title>",
+		},
+		{
+			name: "unknown charset",
+			page: "<html><head><meta charset=\"x-unknown\"><title>\xb0\xd9</title></head></html>",
+			want: "<title>\xb0\xd9</title>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetch := func(ctx context.Context, ex *Exchange) error {
+				ex.Body = tt.page
+				ex.Response.HTML = tt.page
+				return nil
+			}
+			ex := &Exchange{Response: &SearchResponse{}}
+			if err := Chain(fetch, DecodeCJK)(context.Background(), ex); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(ex.Body, tt.want) {
+				t.Errorf("body = %q, want it to contain %q", ex.Body, tt.want)
+			}
+			if ex.Response.HTML != ex.Body {
+				t.Errorf("response HTML = %q, want the body %q", ex.Response.HTML, ex.Body)
+			}
+		})
+	}
+}
+
+func TestUnwrapRedirects(t *testing.T) {
+	tests := []struct {
+		name      string
+		engine    func() redirectingEngine
+		page      string
+		redirects map[string]func(w http.ResponseWriter)
+		urls      []string
+	}{
+		{
+			// A redirect that cannot be resolved is left as it is and
+			// dropped by the parser
+			name:   "baidu location",
+			engine: func() redirectingEngine { return NewBaidu(DefaultBaiduConfig()) },
+			page: `<div id="content_left">` +
+				`<div class="result c-container" id="1"><h3 class="t"><a href="/link?url=AAAA" target="_blank">Admin</a></h3></div>` +
+				`<div class="result c-container" id="2"><h3 class="t"><a href="/link?url=BBBB" target="_blank">Gone</a></h3></div>` +
+				`<div class="result c-container" id="3"><h3 class="t"><a href="/link?url=CCCC&amp;wd=x" target="_blank">Portal</a></h3></div>` +
+				`</div>`,
+			redirects: map[string]func(w http.ResponseWriter){
+				"AAAA": func(w http.ResponseWriter) {
+					w.Header().Set("Location", "https://www.example.com/admin/")
+					w.WriteHeader(http.StatusFound)
+				},
+				"BBBB": func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusNotFound)
+				},
+				"CCCC": func(w http.ResponseWriter) {
+					w.Header().Set("Location", "https://portal.example.org/login")
+					w.WriteHeader(http.StatusFound)
+				},
+			},
+			urls: []string{"https://www.example.com/admin/", "https://portal.example.org/login"},
+		},
+		{
+			// Sogou answers its redirects with a page naming the target
+			name:   "sogou redirect page",
+			engine: func() redirectingEngine { return NewSogou(DefaultSogouConfig()) },
+			page: `<div class="results">` +
+				`<div class="vrwrap"><h3 class="vr-title"><a target="_blank" href="/link?url=CCCC">Portal</a></h3></div>` +
+				`<div class="vrwrap"><h3 class="vr-title"><a target="_blank" href="/link?url=DDDD">Admin</a></h3></div>` +
+				`</div>`,
+			redirects: map[string]func(w http.ResponseWriter){
+				"CCCC": func(w http.ResponseWriter) {
+					w.Write([]byte(`<script>window.location.replace("https://portal.example.org/login")</script>`))
+				},
+				"DDDD": func(w http.ResponseWriter) {
+					w.Write([]byte(`<meta http-equiv="refresh" content="0;URL='https://www.example.com/admin/'">`))
+				},
+			},
+			urls: []string{"https://portal.example.org/login", "https://www.example.com/admin/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/link" {
+					if redirect, ok := tt.redirects[r.URL.Query().Get("url")]; ok {
+						redirect(w)
+						return
+					}
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(tt.page))
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest("GET", server.URL+"/s?wd=test", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			e := tt.engine()
+			response, err := e.Do(context.Background(), &SearchRequest{ID: "r1", Dork: "test"}, req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			result := e.ParseResponse(response.HTML)
+			if !reflect.DeepEqual(result.URLs, tt.urls) {
+				t.Errorf("urls = %q, want %q", result.URLs, tt.urls)
+			}
+		})
+	}
+}
+
+// redirectingEngine is an engine whose pages link results through
+// redirects
+type redirectingEngine interface {
+	Do(context.Context, *SearchRequest, *http.Request) (*SearchResponse, error)
+	ParseResponse(string) *parser.ExtractionResult
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is the cause of a search refused by a non-blocking rate
+// limiter, as the engine or its domain has no request left to spend
+var ErrRateLimited = errors.New("engine rate limit reached")
+
+// RateLimiter enforces EngineConfig.RateLimitPerMin with a token bucket per
+// engine, and DomainRateLimitPerMin with one per engine and domain. Each
+// request takes a token from both. A blocking limiter waits for the tokens;
+// a non-blocking one fails the request with ErrRateLimited instead, so the
+// caller can hand the task elsewhere.
+type RateLimiter struct {
+	mu       sync.Mutex
+	blocking bool
+	buckets  map[rateKey]*tokenBucket
+	now      func() time.Time
+}
+
+// rateKey names a bucket; Domain is empty for an engine's own bucket
+type rateKey struct {
+	Engine EngineType
+	Domain string
+}
+
+// tokenBucket holds the requests an engine or domain may still make.
+// Tokens go below zero while waiters hold reservations.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter; blocking sets whether requests
+// over the limit wait or fail
+func NewRateLimiter(blocking bool) *RateLimiter {
+	return &RateLimiter{
+		blocking: blocking,
+		buckets:  make(map[rateKey]*tokenBucket),
+		now:      time.Now,
+	}
+}
+
+// SetBlocking switches between waiting for tokens and failing without them
+func (l *RateLimiter) SetBlocking(blocking bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.blocking = blocking
+}
+
+// Blocking reports whether requests over the limit wait
+func (l *RateLimiter) Blocking() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.blocking
+}
+
+// Wait takes a token for a request to domain from the engine's buckets,
+// rated by its config, and returns how long it waited for them. A limit
+// of 0 leaves its bucket out. Without tokens, a non-blocking limiter
+// returns ErrRateLimited at once and takes nothing; a blocking one waits,
+// giving the tokens back if ctx ends first.
+func (l *RateLimiter) Wait(ctx context.Context, engineType EngineType, config EngineConfig, domain string) (time.Duration, error) {
+	type limit struct {
+		key    rateKey
+		perMin int
+	}
+	limits := []limit{{rateKey{Engine: engineType}, config.RateLimitPerMin}}
+	if domain != "" {
+		limits = append(limits, limit{rateKey{Engine: engineType, Domain: domain}, config.DomainRateLimitPerMin})
+	}
+	burst := float64(config.RateLimitBurst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	var wait time.Duration
+	var taken []*tokenBucket
+	for _, lim := range limits {
+		if lim.perMin <= 0 {
+			continue
+		}
+		bucket := l.refill(lim.key, lim.perMin, burst, now)
+		if bucket.tokens < 1 {
+			deficit := time.Duration((1 - bucket.tokens) * float64(time.Minute) / float64(lim.perMin))
+			if deficit > wait {
+				wait = deficit
+			}
+		}
+		taken = append(taken, bucket)
+	}
+	if wait > 0 && !l.blocking {
+		l.mu.Unlock()
+		return 0, ErrRateLimited
+	}
+	for _, bucket := range taken {
+		bucket.tokens--
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return wait, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for _, bucket := range taken {
+			bucket.tokens++
+		}
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// refill returns a bucket with the tokens earned since it was last used,
+// up to burst; a new bucket starts full. The caller holds the lock.
+func (l *RateLimiter) refill(key rateKey, perMin int, burst float64, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = bucket
+		return bucket
+	}
+
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens += elapsed.Minutes() * float64(perMin)
+		bucket.last = now
+	}
+	if bucket.tokens > burst {
+		bucket.tokens = burst
+	}
+	return bucket
+}
+
+// RateLimit returns middleware taking a token from the limiter for each of
+// the engine's requests, rated by its configuration in the registry at the
+// time. Time spent waiting is left out of the response's latency.
+func (r *Registry) RateLimit(engineType EngineType) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ex *Exchange) error {
+			config, ok := r.GetConfig(engineType)
+			if !ok {
+				return next(ctx, ex)
+			}
+
+			waited, err := r.limiter.Wait(ctx, engineType, config, ex.Request.URL.Host)
+			ex.Waited += waited
+			if err != nil {
+				ex.Response.Error = NewSearchError(ErrorTypeRateLimit, "request not sent", err)
+				return ex.Response.Error
+			}
+			return next(ctx, ex)
+		}
+	}
+}
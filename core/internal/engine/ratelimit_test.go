@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRegisterTwiceLimitsOnce(t *testing.T) {
+	registry := NewRegistry()
+	registry.RateLimiter().SetBlocking(false)
+	now := time.Now()
+	registry.RateLimiter().now = func() time.Time { return now }
+
+	config := DefaultEngineConfigs()[EngineTypeGoogle]
+	config.RateLimitPerMin = 60
+	config.RateLimitBurst = 1
+	config.DomainRateLimitPerMin = 0
+	registry.SetConfig(EngineTypeGoogle, config)
+
+	g := NewGoogle(DefaultGoogleConfig())
+	registry.Register(EngineTypeGoogle, g)
+	registry.Register(EngineTypeGoogle, g)
+
+	// A second limiter in the chain would take a token of its own and
+	// refuse the first request
+	page := `<html><body><div class="g"><a href="https://example.com/"><h3>Example</h3></a></div></body></html>`
+	if response := doPage(t, g, http.StatusOK, page); errors.Is(response.Error, ErrRateLimited) {
+		t.Fatalf("first request rate limited: %v", response.Error)
+	}
+
+	// With the clock stopped, the one token is spent
+	if response := doPage(t, g, http.StatusOK, page); !errors.Is(response.Error, ErrRateLimited) {
+		t.Errorf("second request error = %v, want %v", response.Error, ErrRateLimited)
+	}
+}
+
+func TestRegisterWhileSearching(t *testing.T) {
+	registry := NewRegistry()
+	registry.RateLimiter().SetBlocking(false)
+	g := NewGoogle(DefaultGoogleConfig())
+	registry.Register(EngineTypeGoogle, g)
+
+	// Registering under another name adds to the chain Do is reading;
+	// run with -race
+	page := `<html><body><div class="g"><a href="https://example.com/"><h3>Example</h3></a></div></body></html>`
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			doPage(t, g, http.StatusOK, page)
+		}
+	}()
+	for _, engineType := range []EngineType{EngineTypeStartpage, EngineTypeGoogleCSE, EngineTypeBingAPI} {
+		registry.Register(engineType, g)
+	}
+	<-done
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	type step struct {
+		advance time.Duration // Clock change before the request
+		domain  string
+		err     error
+	}
+	tests := []struct {
+		name     string
+		blocking bool
+		perMin   int
+		domain   int // Per-domain limit
+		burst    int
+		steps    []step
+	}{
+		{
+			name:   "burst",
+			perMin: 60,
+			burst:  2,
+			steps:  []step{{}, {}, {err: ErrRateLimited}},
+		},
+		{
+			name:   "refill",
+			perMin: 60,
+			burst:  1,
+			steps: []step{
+				{},
+				{err: ErrRateLimited},
+				{advance: 500 * time.Millisecond, err: ErrRateLimited},
+				{advance: 500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "domain",
+			domain: 60,
+			burst:  1,
+			steps: []step{
+				{domain: "a.example.com"},
+				{domain: "a.example.com", err: ErrRateLimited},
+				{domain: "b.example.com"},
+			},
+		},
+		{
+			// b's token is left alone when the engine's bucket refuses
+			// the request, so it is there a second later
+			name:   "refusal takes nothing",
+			perMin: 60,
+			domain: 30,
+			burst:  1,
+			steps: []step{
+				{domain: "a.example.com"},
+				{domain: "b.example.com", err: ErrRateLimited},
+				{advance: time.Second, domain: "b.example.com"},
+			},
+		},
+		{
+			// A wait cut short gives its token back
+			name:     "canceled wait",
+			blocking: true,
+			perMin:   60,
+			burst:    1,
+			steps: []step{
+				{},
+				{err: context.Canceled},
+				{advance: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.blocking)
+			now := time.Now()
+			limiter.now = func() time.Time { return now }
+
+			config := EngineConfig{
+				RateLimitPerMin:       tt.perMin,
+				DomainRateLimitPerMin: tt.domain,
+				RateLimitBurst:        tt.burst,
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				if _, err := limiter.Wait(ctx, EngineTypeGoogle, config, step.domain); !errors.Is(err, step.err) {
+					t.Fatalf("request %d: error = %v, want %v", i+1, err, step.err)
+				}
+			}
+		})
+	}
+}
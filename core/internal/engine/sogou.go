@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Sogou implements the Engine interface for Sogou, the second Chinese
+// engine. Pages are numbered from 1 with page=. Like Baidu, Sogou links
+// results through a /link?url= redirect; its target page names the URL in
+// a script, and UnwrapRedirects reads it from there.
+type Sogou struct {
+	*BaseEngine
+	headerGen *stealth.HeaderGenerator
+}
+
+// SogouConfig holds Sogou engine configuration
+type SogouConfig struct {
+	Domains    []string
+	Timeout    time.Duration
+	UserAgents []string
+}
+
+// DefaultSogouConfig returns default Sogou configuration
+func DefaultSogouConfig() SogouConfig {
+	return SogouConfig{
+		Domains:    []string{"www.sogou.com"},
+		Timeout:    30 * time.Second,
+		UserAgents: stealth.DefaultUserAgents(),
+	}
+}
+
+// NewSogou creates a new Sogou search engine
+func NewSogou(config SogouConfig) *Sogou {
+	if len(config.Domains) == 0 {
+		config.Domains = DefaultSogouConfig().Domains
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	s := &Sogou{
+		BaseEngine: NewBaseEngine("sogou", config.Domains),
+		headerGen:  stealth.NewHeaderGenerator(config.UserAgents),
+	}
+	s.SetDetector(s)
+	s.Use(HeaderProfile(s.headerGen), acceptChinese, UnwrapRedirects(sogouPendingRedirects), s.antispider, DecodeCJK)
+	return s
+}
+
+// Search performs a Sogou search
+func (s *Sogou) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = s.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.buildSearchURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "sogou",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := s.Do(ctx, request, req)
+	response.PageSize = sogouPageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := s.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// sogouPageSize is the number of results on a Sogou page
+const sogouPageSize = 10
+
+// BuildURL builds a Sogou search URL
+func (s *Sogou) BuildURL(query string, page int) string {
+	return s.buildSearchURL(s.selectDomain(), query, page)
+}
+
+// buildSearchURL builds a Sogou search URL; ie= tells Sogou the query is
+// UTF-8
+func (s *Sogou) buildSearchURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("ie", "utf8")
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page+1))
+	}
+	return fmt.Sprintf("https://%s/web?%s", domain, params.Encode())
+}
+
+func (s *Sogou) selectDomain() string {
+	domains := s.GetDomains()
+	if len(domains) == 0 {
+		return "www.sogou.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// antispider reports Sogou's antispider check as a CAPTCHA. Sogou
+// redirects a suspect client to /antispider/ and serves the check with
+// status 200.
+func (s *Sogou) antispider(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		response := ex.Response
+		switch {
+		case s.IsCaptcha(ex.Body):
+			response.Captcha = true
+			response.Error = NewSearchError(ErrorTypeCaptcha, "sogou antispider check", nil)
+		case response.StatusCode == http.StatusForbidden || s.IsBlocked(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "blocked by sogou", nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+var (
+	// Results are vrwrap blocks, or rb in older markup
+	sogouItemPattern = regexp.MustCompile(`<div[^>]+class="(?:vrwrap|rb)\b[^"]*"`)
+
+	// A result's own URL, when the page writes it in a data-url attribute
+	sogouDirectPattern = regexp.MustCompile(`\bdata-url="(https?://[^"]+)"`)
+
+	// A result's title link, mostly a /link?url= redirect
+	sogouLinkPattern = regexp.MustCompile(`<h3[^>]*>\s*<a[^>]+href="([^"]+)"`)
+
+	// Sponsored results sit in sponsor blocks
+	sogouAdPattern = regexp.MustCompile(`class="[^"]*sponsor|>广告<`)
+
+	sogouNextPattern  = regexp.MustCompile(`id="sogou_next"`)
+	sogouTotalPattern = regexp.MustCompile(`找到约([\d,]+)条`)
+)
+
+// ParseResponse parses Sogou results HTML. Each result takes its title
+// link, which UnwrapRedirects will have resolved when it could, else the
+// URL of a data-url attribute; sponsored results and links left on Sogou
+// are dropped.
+func (s *Sogou) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, sogouItemPattern) {
+		if sogouAdPattern.MatchString(item) {
+			continue
+		}
+		link := ""
+		if match := sogouLinkPattern.FindStringSubmatch(item); match != nil {
+			link = html.UnescapeString(match[1])
+		}
+		if isSogouURL(link) {
+			if match := sogouDirectPattern.FindStringSubmatch(item); match != nil {
+				link = html.UnescapeString(match[1])
+			}
+		}
+		if !isSogouURL(link) && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	result := s.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = sogouNextPattern.MatchString(page)
+	if match := sogouTotalPattern.FindStringSubmatch(page); match != nil {
+		result.TotalResults = match[1]
+	}
+	return result
+}
+
+// sogouPendingRedirects returns the title links of results that are
+// redirects, as written in the page
+func sogouPendingRedirects(page string) []string {
+	var pending []string
+	for _, item := range splitBefore(page, sogouItemPattern) {
+		if sogouAdPattern.MatchString(item) {
+			continue
+		}
+		if match := sogouLinkPattern.FindStringSubmatch(item); match != nil && strings.Contains(match[1], "/link?url=") {
+			pending = append(pending, match[1])
+		}
+	}
+	return pending
+}
+
+// isSogouURL reports whether a link stays on Sogou: redirects left
+// unresolved, Sogou's own services and its static hosts
+func isSogouURL(link string) bool {
+	return onDomains(link, "sogou.com", "sogoucdn.com")
+}
+
+// IsBlocked checks for Sogou's access refusal page
+func (s *Sogou) IsBlocked(page string) bool {
+	return strings.Contains(page, "您的访问请求被拒绝")
+}
+
+// IsCaptcha checks for Sogou's antispider check
+func (s *Sogou) IsCaptcha(page string) bool {
+	for _, marker := range []string{
+		"/antispider/",
+		"id=\"seccodeForm\"",
+		"检测到您网络中存在异常访问请求",
+	} {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
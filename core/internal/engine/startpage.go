@@ -0,0 +1,367 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Startpage implements the Engine interface for Startpage, which serves
+// Google's results without tracking and challenges far less. A search must
+// carry the sc session token of Startpage's search form, so one is read
+// from the homepage before the first search through each proxy and kept
+// while searches with it succeed. Pages are numbered from 1 with page=.
+type Startpage struct {
+	*BaseEngine
+	headerGen *stealth.HeaderGenerator
+	language  string
+
+	tokensMu sync.Mutex
+	tokens   map[string]startpageToken // By proxy ID; "" is direct
+}
+
+// startpageToken is an sc token and when it was read
+type startpageToken struct {
+	value   string
+	fetched time.Time
+}
+
+// startpageTokenTTL is how long a token is used before a fresh one is read
+const startpageTokenTTL = 10 * time.Minute
+
+// StartpageConfig holds Startpage engine configuration
+type StartpageConfig struct {
+	Domains    []string
+	Language   string // Interface and results language, such as "english"
+	Timeout    time.Duration
+	UserAgents []string
+}
+
+// DefaultStartpageConfig returns default Startpage configuration
+func DefaultStartpageConfig() StartpageConfig {
+	return StartpageConfig{
+		Domains:    []string{"www.startpage.com"},
+		Language:   "english",
+		Timeout:    30 * time.Second,
+		UserAgents: stealth.DefaultUserAgents(),
+	}
+}
+
+// NewStartpage creates a new Startpage search engine
+func NewStartpage(config StartpageConfig) *Startpage {
+	defaults := DefaultStartpageConfig()
+	if len(config.Domains) == 0 {
+		config.Domains = defaults.Domains
+	}
+	if config.Language == "" {
+		config.Language = defaults.Language
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	s := &Startpage{
+		BaseEngine: NewBaseEngine("startpage", config.Domains),
+		headerGen:  stealth.NewHeaderGenerator(config.UserAgents),
+		language:   config.Language,
+		tokens:     make(map[string]startpageToken),
+	}
+	s.SetDetector(s)
+	s.Use(HeaderProfile(s.headerGen), s.session, s.challenge)
+	return s
+}
+
+// Search performs a Startpage search
+func (s *Startpage) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = s.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.buildSearchURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "startpage",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := s.Do(ctx, request, req)
+	response.PageSize = startpagePageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := s.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+
+	return response, nil
+}
+
+// startpagePageSize is the number of web results on a Startpage page
+const startpagePageSize = 10
+
+// BuildURL builds a Startpage search URL. It has no sc token, which Search
+// adds, so Startpage answers it with its homepage rather than results.
+func (s *Startpage) BuildURL(query string, page int) string {
+	return s.buildSearchURL(s.selectDomain(), query, page)
+}
+
+func (s *Startpage) buildSearchURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("cat", "web")
+	params.Set("t", "device")
+	params.Set("lui", s.language)
+	params.Set("language", s.language)
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page+1))
+	}
+	return fmt.Sprintf("https://%s/sp/search?%s", domain, params.Encode())
+}
+
+func (s *Startpage) selectDomain() string {
+	domains := s.GetDomains()
+	if len(domains) == 0 {
+		return "www.startpage.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// session adds the proxy's sc token to the search, reading one from the
+// homepage first when it has none, and sends the headers of Startpage's
+// form. A results page carries the token for the next search; a search
+// that fails drops it, so the next one starts a new session.
+func (s *Startpage) session(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		key := ""
+		if ex.Search.Proxy != nil {
+			key = ex.Search.Proxy.ID
+		}
+
+		token := s.token(key)
+		if token == "" {
+			var err error
+			if token, err = s.fetchToken(ctx, ex); err != nil {
+				return err
+			}
+			s.setToken(key, token)
+		}
+
+		query := ex.Request.URL.Query()
+		query.Set("sc", token)
+		ex.Request.URL.RawQuery = query.Encode()
+
+		origin := "https://" + ex.Request.URL.Host
+		ex.Request.Header.Set("Sec-Fetch-Site", "same-origin")
+		if ex.Search.Page > 0 {
+			ex.Request.Header.Set("Referer", origin+"/sp/search")
+		} else {
+			ex.Request.Header.Set("Referer", origin+"/")
+		}
+
+		err := next(ctx, ex)
+		switch {
+		case err != nil || ex.Response.StatusCode != http.StatusOK:
+			s.setToken(key, "")
+		default:
+			if fresh := parseStartpageToken(ex.Body); fresh != "" {
+				s.setToken(key, fresh)
+			}
+		}
+		return err
+	}
+}
+
+// fetchToken reads an sc token from the homepage, with the headers of the
+// search over the same client
+func (s *Startpage) fetchToken(ctx context.Context, ex *Exchange) (string, error) {
+	fail := func(errType SearchErrorType, message string, err error) (string, error) {
+		ex.Response.Error = NewSearchError(errType, message, err)
+		if err == nil {
+			err = ex.Response.Error
+		}
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://"+ex.Request.URL.Host+"/", nil)
+	if err != nil {
+		return fail(ErrorTypeNetwork, "failed to create homepage request", err)
+	}
+	req.Header = ex.Request.Header.Clone()
+	req.Header.Del("Referer")
+	req.Header.Set("Sec-Fetch-Site", "none")
+
+	client := ex.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fail(ErrorTypeTimeout, "homepage request timed out", err)
+		}
+		return fail(ErrorTypeNetwork, "homepage request failed", err)
+	}
+	defer resp.Body.Close()
+
+	ex.Response.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden {
+		ex.Response.Blocked = true
+		return fail(ErrorTypeBlocked, fmt.Sprintf("startpage homepage refused (%d)", resp.StatusCode), nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail(ErrorTypeNetwork, fmt.Sprintf("unexpected homepage status: %d", resp.StatusCode), nil)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return fail(ErrorTypeNetwork, "failed to read homepage", err)
+	}
+	page := string(body)
+	if s.IsCaptcha(page) {
+		ex.Response.Captcha = true
+		return fail(ErrorTypeCaptcha, "startpage captcha", nil)
+	}
+	token := parseStartpageToken(page)
+	if token == "" {
+		return fail(ErrorTypeParse, "no sc token on the startpage homepage", nil)
+	}
+	return token, nil
+}
+
+// token returns the proxy's sc token, or "" when it has none or it is
+// too old to use
+func (s *Startpage) token(key string) string {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+	token, ok := s.tokens[key]
+	if !ok || time.Since(token.fetched) > startpageTokenTTL {
+		return ""
+	}
+	return token.value
+}
+
+// setToken stores the proxy's sc token; "" drops it
+func (s *Startpage) setToken(key, value string) {
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+	if value == "" {
+		delete(s.tokens, key)
+		return
+	}
+	s.tokens[key] = startpageToken{value: value, fetched: time.Now()}
+}
+
+// challenge reports Startpage's CAPTCHA page, which it may serve with
+// status 200, as a CAPTCHA
+func (s *Startpage) challenge(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		if s.IsCaptcha(ex.Body) {
+			ex.Response.Captcha = true
+			ex.Response.Error = NewSearchError(ErrorTypeCaptcha, "startpage captcha", nil)
+			return ex.Response.Error
+		}
+		return nil
+	}
+}
+
+var (
+	// The sc field of the search and pager forms, in either attribute order
+	startpageTokenPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`<input[^>]+name="sc"[^>]+value="([^"]+)"`),
+		regexp.MustCompile(`<input[^>]+value="([^"]+)"[^>]+name="sc"`),
+	}
+
+	// Organic results; ads sit in their own containers
+	startpageItemPattern = regexp.MustCompile(`<div[^>]+class="(?:w-gl__result|result)\b[^"]*"`)
+
+	// The title link of a result
+	startpageLinkPattern = regexp.MustCompile(`<a[^>]+class="[^"]*(?:w-gl__result-title|result-title)[^"]*"[^>]*href="([^"]+)"|<a[^>]+href="([^"]+)"[^>]*class="[^"]*(?:w-gl__result-title|result-title)`)
+
+	// The pager's "Next" button
+	startpageNextPattern = regexp.MustCompile(`<(?:button|a)[^>]+class="[^"]*pagination__next-prev-button next\b|<(?:button|a)[^>]+class="[^"]*\bnext\b[^"]*"[^>]*>\s*Next\b`)
+)
+
+// parseStartpageToken returns the sc token of a page's form, or ""
+func parseStartpageToken(page string) string {
+	for _, pattern := range startpageTokenPatterns {
+		if match := pattern.FindStringSubmatch(page); match != nil {
+			return html.UnescapeString(match[1])
+		}
+	}
+	return ""
+}
+
+// ParseResponse parses Startpage results HTML. Result links are direct;
+// links that stay on Startpage, such as its anonymous view, are dropped.
+func (s *Startpage) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, startpageItemPattern) {
+		match := startpageLinkPattern.FindStringSubmatch(item)
+		if match == nil {
+			continue
+		}
+		link := match[1]
+		if link == "" {
+			link = match[2]
+		}
+		link = html.UnescapeString(link)
+		if !isStartpageURL(link) && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	result := s.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = startpageNextPattern.MatchString(page)
+	return result
+}
+
+// isStartpageURL reports whether a link stays on Startpage
+func isStartpageURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return host == "startpage.com" || strings.HasSuffix(host, ".startpage.com")
+}
+
+// IsCaptcha checks for Startpage's CAPTCHA page
+func (s *Startpage) IsCaptcha(page string) bool {
+	for _, marker := range []string{
+		"/sp/captcha",
+		"captcha__form",
+		"g-recaptcha",
+	} {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Yahoo implements the Engine interface for Yahoo search. Pages are
+// requested by the 1-based index of their first result with b=, and result
+// links go through r.search.yahoo.com redirects, which the extractor
+// unwraps to their RU= target.
+type Yahoo struct {
+	*BaseEngine
+	headerGen      *stealth.HeaderGenerator
+	resultsPerPage int
+}
+
+// YahooConfig holds Yahoo engine configuration
+type YahooConfig struct {
+	Domains        []string // Regional hosts such as uk.search.yahoo.com pick the market
+	ResultsPerPage int      // Step of the b= offset between pages
+	Timeout        time.Duration
+	UserAgents     []string
+}
+
+// DefaultYahooConfig returns default Yahoo configuration
+func DefaultYahooConfig() YahooConfig {
+	return YahooConfig{
+		Domains:        []string{"search.yahoo.com"},
+		ResultsPerPage: 10,
+		Timeout:        30 * time.Second,
+		UserAgents:     stealth.DefaultUserAgents(),
+	}
+}
+
+// NewYahoo creates a new Yahoo search engine
+func NewYahoo(config YahooConfig) *Yahoo {
+	defaults := DefaultYahooConfig()
+	if len(config.Domains) == 0 {
+		config.Domains = defaults.Domains
+	}
+	if config.ResultsPerPage == 0 {
+		config.ResultsPerPage = defaults.ResultsPerPage
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	y := &Yahoo{
+		BaseEngine:     NewBaseEngine("yahoo", config.Domains),
+		headerGen:      stealth.NewHeaderGenerator(config.UserAgents),
+		resultsPerPage: config.ResultsPerPage,
+	}
+	y.SetDetector(y)
+	y.Use(HeaderProfile(y.headerGen), y.referer, y.rateLimited)
+	return y
+}
+
+// Search performs a Yahoo search
+func (y *Yahoo) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = y.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", y.buildSearchURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "yahoo",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := y.Do(ctx, request, req)
+	response.PageSize = y.resultsPerPage
+	if err != nil {
+		return response, err
+	}
+
+	result := y.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// BuildURL builds a Yahoo search URL
+func (y *Yahoo) BuildURL(query string, page int) string {
+	return y.buildSearchURL(y.selectDomain(), query, page)
+}
+
+func (y *Yahoo) buildSearchURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("p", query)
+	if page > 0 {
+		params.Set("b", strconv.Itoa(page*y.resultsPerPage+1))
+		params.Set("pz", strconv.Itoa(y.resultsPerPage))
+	}
+	return fmt.Sprintf("https://%s/search?%s", domain, params.Encode())
+}
+
+func (y *Yahoo) selectDomain() string {
+	domains := y.GetDomains()
+	if len(domains) == 0 {
+		return "search.yahoo.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// referer sends the previous page as the Referer of later pages, as
+// Yahoo's pager links do, and none with the first
+func (y *Yahoo) referer(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if ex.Search.Page > 0 {
+			ex.Request.Header.Set("Referer", y.buildSearchURL(ex.Request.URL.Host, ex.Search.Dork, ex.Search.Page-1))
+		} else {
+			ex.Request.Header.Del("Referer")
+		}
+		return next(ctx, ex)
+	}
+}
+
+// yahooStatusRateLimited is the non-standard status of Yahoo's "error 999"
+// page, served to clients it throttles
+const yahooStatusRateLimited = 999
+
+// rateLimited reports Yahoo's ways of refusing a client as blocks: the 999
+// throttle page, which may also come with status 200, and the consent wall
+// EU clients are redirected to: a page posting to consent.yahoo.com with no
+// results on it. Yahoo rarely shows a CAPTCHA.
+func (y *Yahoo) rateLimited(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		response := ex.Response
+		switch {
+		case response.StatusCode == yahooStatusRateLimited || y.IsBlocked(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "rate limited by yahoo (error 999)", nil)
+		case yahooConsentPattern.MatchString(ex.Body) && !yahooItemPattern.MatchString(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "yahoo consent wall", nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+var (
+	// Organic results are "algo" blocks; ads and other modules are not
+	yahooItemPattern = regexp.MustCompile(`<div[^>]+class="[^"]*\balgo\b[^"]*"`)
+
+	// The title link of a result: the first link of its compTitle, or in
+	// older markup the link inside its h3
+	yahooLinkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?s)class="[^"]*compTitle[^"]*".*?<a[^>]+href="([^"]+)"`),
+		regexp.MustCompile(`<h3[^>]*>\s*<a[^>]+href="([^"]+)"`),
+	}
+
+	yahooNextPattern    = regexp.MustCompile(`<a[^>]+class="[^"]*\bnext\b[^"]*"`)
+	yahooTotalPattern   = regexp.MustCompile(`(?i)(?:about\s+)?([\d,.]+)\s+(?:search\s+)?results`)
+	yahooConsentPattern = regexp.MustCompile(`(?:consent|guce)\.yahoo\.com/`)
+)
+
+// ParseResponse parses Yahoo results HTML. Each organic result is an algo
+// block whose title link is a redirect the extractor unwraps; links that
+// stay on Yahoo, such as its own verticals, are dropped.
+func (y *Yahoo) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, yahooItemPattern) {
+		for _, pattern := range yahooLinkPatterns {
+			match := pattern.FindStringSubmatch(item)
+			if match == nil {
+				continue
+			}
+			link := html.UnescapeString(match[1])
+			target := parser.UnwrapYahooURL(link)
+			if target != "" && !isYahooURL(target) && !seen[target] {
+				seen[target] = true
+				links = append(links, link)
+			}
+			break
+		}
+	}
+
+	result := y.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = yahooNextPattern.MatchString(page)
+	if match := yahooTotalPattern.FindStringSubmatch(page); match != nil {
+		result.TotalResults = match[1]
+	}
+	return result
+}
+
+// isYahooURL reports whether a link stays on Yahoo: its verticals, image
+// hosts and redirects left unwrapped
+func isYahooURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range []string{"yahoo.com", "yahoo.net", "yimg.com"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked checks for Yahoo's "error 999" throttle page
+func (y *Yahoo) IsBlocked(page string) bool {
+	lower := strings.ToLower(page)
+	return strings.Contains(lower, "unable to process request at this time") &&
+		strings.Contains(lower, "999")
+}
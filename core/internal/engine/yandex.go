@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
+	"github.com/google-dork-parser/core/internal/stealth"
+)
+
+// Yandex implements the Engine interface for Yandex search. Pages are
+// numbered from 0 with p=, the region is set with lr=, and a client Yandex
+// suspects is sent to its SmartCaptcha, which looks nothing like Google's
+// reCAPTCHA and is detected on its own markers.
+type Yandex struct {
+	*BaseEngine
+	headerGen *stealth.HeaderGenerator
+	region    int
+}
+
+// YandexConfig holds Yandex engine configuration
+type YandexConfig struct {
+	Domains    []string
+	Region     int // lr parameter, a Yandex region ID such as 213 (Moscow); 0 lets Yandex pick
+	Timeout    time.Duration
+	UserAgents []string
+}
+
+// DefaultYandexConfig returns default Yandex configuration
+func DefaultYandexConfig() YandexConfig {
+	return YandexConfig{
+		Domains:    []string{"yandex.com", "yandex.ru"},
+		Timeout:    30 * time.Second,
+		UserAgents: stealth.DefaultUserAgents(),
+	}
+}
+
+// NewYandex creates a new Yandex search engine
+func NewYandex(config YandexConfig) *Yandex {
+	if len(config.Domains) == 0 {
+		config.Domains = DefaultYandexConfig().Domains
+	}
+	if len(config.UserAgents) == 0 {
+		config.UserAgents = stealth.DefaultUserAgents()
+	}
+
+	y := &Yandex{
+		BaseEngine: NewBaseEngine("yandex", config.Domains),
+		headerGen:  stealth.NewHeaderGenerator(config.UserAgents),
+		region:     config.Region,
+	}
+	y.SetDetector(y)
+	y.Use(HeaderProfile(y.headerGen), y.referer, y.smartCaptcha)
+	return y
+}
+
+// Search performs a Yandex search
+func (y *Yandex) Search(ctx context.Context, request *SearchRequest) (*SearchResponse, error) {
+	domain := request.Domain
+	if domain == "" {
+		domain = y.selectDomain()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", y.buildSearchURL(domain, request.Dork, request.Page), nil)
+	if err != nil {
+		response := &SearchResponse{
+			RequestID:  request.ID,
+			Dork:       request.Dork,
+			Page:       request.Page,
+			EngineUsed: "yandex",
+			Domain:     domain,
+		}
+		response.Error = NewSearchError(ErrorTypeNetwork, "failed to create request", err)
+		return response, err
+	}
+
+	response, err := y.Do(ctx, request, req)
+	response.PageSize = yandexPageSize
+	if err != nil {
+		return response, err
+	}
+
+	result := y.ParseResponse(response.HTML)
+	response.URLs = result.URLs
+	response.RawURLs = result.RawURLs
+	response.Types = result.Types
+	response.HasNextPage = result.HasNextPage
+	response.TotalResults = result.TotalResults
+
+	return response, nil
+}
+
+// yandexPageSize is the number of organic results on a Yandex page, which
+// cannot be changed without an account
+const yandexPageSize = 10
+
+// BuildURL builds a Yandex search URL
+func (y *Yandex) BuildURL(query string, page int) string {
+	return y.buildSearchURL(y.selectDomain(), query, page)
+}
+
+func (y *Yandex) buildSearchURL(domain, query string, page int) string {
+	params := url.Values{}
+	params.Set("text", query)
+	if page > 0 {
+		params.Set("p", strconv.Itoa(page))
+	}
+	if y.region > 0 {
+		params.Set("lr", strconv.Itoa(y.region))
+	}
+	return fmt.Sprintf("https://%s/search/?%s", domain, params.Encode())
+}
+
+func (y *Yandex) selectDomain() string {
+	domains := y.GetDomains()
+	if len(domains) == 0 {
+		return "yandex.com"
+	}
+	return domains[rand.Intn(len(domains))]
+}
+
+// referer replaces the Google-style Referer of later pages with the one a
+// Yandex pager link sends
+func (y *Yandex) referer(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if ex.Search.Page > 0 {
+			ex.Request.Header.Set("Referer", y.buildSearchURL(ex.Request.URL.Host, ex.Search.Dork, ex.Search.Page-1))
+		} else {
+			ex.Request.Header.Del("Referer")
+		}
+		return next(ctx, ex)
+	}
+}
+
+// smartCaptcha reports Yandex's SmartCaptcha as a CAPTCHA. Yandex
+// redirects a suspect client to /showcaptcha and serves the challenge with
+// status 200, which Classify would otherwise take for an empty results
+// page. A 403 or the restricted-access page is a block.
+func (y *Yandex) smartCaptcha(next Handler) Handler {
+	return func(ctx context.Context, ex *Exchange) error {
+		if err := next(ctx, ex); err != nil {
+			return err
+		}
+		response := ex.Response
+		switch {
+		case y.IsCaptcha(ex.Body):
+			response.Captcha = true
+			response.Error = NewSearchError(ErrorTypeCaptcha, "SmartCaptcha", nil)
+		case response.StatusCode == http.StatusForbidden || y.IsBlocked(ex.Body):
+			response.Blocked = true
+			response.Error = NewSearchError(ErrorTypeBlocked, "blocked by yandex", nil)
+		default:
+			return nil
+		}
+		return response.Error
+	}
+}
+
+var (
+	// Organic result title links, attributes in either order
+	yandexLinkPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`<a[^>]+class="[^"]*(?:OrganicTitle-Link|organic__url)[^"]*"[^>]*href="([^"]+)"`),
+		regexp.MustCompile(`<a[^>]+href="([^"]+)"[^>]*class="[^"]*(?:OrganicTitle-Link|organic__url)[^"]*"`),
+	}
+
+	yandexItemPattern  = regexp.MustCompile(`<li[^>]+class="[^"]*serp-item[^"]*"`)
+	yandexNextPattern  = regexp.MustCompile(`Pager-Item_type_next|class="[^"]*pager__item_kind_next|aria-label="(?:Next page|Следующая страница)"`)
+	yandexTotalPattern = regexp.MustCompile(`(?i)(?:found|нашлось)\s+([\d\s,.]+(?:\s*(?:thousand|million|тыс\.|млн))?)\s+(?:results|результат)`)
+)
+
+// ParseResponse parses Yandex results HTML. Each organic result is one
+// serp-item; ads among them link through the yabs.yandex click tracker and
+// other Yandex services link to Yandex itself, and both are dropped. A
+// page without results has no serp-item.
+func (y *Yandex) ParseResponse(page string) *parser.ExtractionResult {
+	var links []string
+	seen := make(map[string]bool)
+	for _, item := range splitBefore(page, yandexItemPattern) {
+		for _, pattern := range yandexLinkPatterns {
+			match := pattern.FindStringSubmatch(item)
+			if match == nil {
+				continue
+			}
+			link := html.UnescapeString(match[1])
+			if !isYandexURL(link) && !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+			break
+		}
+	}
+
+	result := y.GetExtractor().ExtractURLs(links)
+	result.HasNextPage = yandexNextPattern.MatchString(page)
+	if match := yandexTotalPattern.FindStringSubmatch(page); match != nil {
+		result.TotalResults = strings.TrimSpace(match[1])
+	}
+	return result
+}
+
+// splitBefore splits s before each match of pattern, dropping what comes
+// before the first
+func splitBefore(s string, pattern *regexp.Regexp) []string {
+	starts := pattern.FindAllStringIndex(s, -1)
+	parts := make([]string, len(starts))
+	for i, start := range starts {
+		end := len(s)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		parts[i] = s[start[0]:end]
+	}
+	return parts
+}
+
+// isYandexURL reports whether a link stays on Yandex: ad click trackers,
+// cached copies and Yandex's own services
+func isYandexURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range []string{"yandex.ru", "yandex.com", "yandex.net", "ya.ru"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBlocked checks if blocked by Yandex
+func (y *Yandex) IsBlocked(page string) bool {
+	lower := strings.ToLower(page)
+	return strings.Contains(lower, "access to our service has been temporarily restricted") ||
+		strings.Contains(lower, "доступ к нашему сервису временно запрещён")
+}
+
+// IsCaptcha checks for Yandex's SmartCaptcha: the checkbox and image
+// challenges both post the /checkcaptcha form
+func (y *Yandex) IsCaptcha(page string) bool {
+	for _, marker := range []string{
+		"/checkcaptcha",
+		"/showcaptcha",
+		"checkbox-captcha-form",
+		"CheckboxCaptcha",
+		"AdvancedCaptcha",
+	} {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+	return false
+}
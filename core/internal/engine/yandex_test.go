@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestYandexSmartCaptcha(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		page    string
+		captcha bool
+		blocked bool
+	}{
+		{
+			name:    "image captcha",
+			status:  http.StatusOK,
+			page:    `<html><body><form method="post" action="/checkcaptcha?key=abc"><img src="https://ext.captcha.yandex.net/image?key=abc"></form></body></html>`,
+			captcha: true,
+		},
+		{
+			name:    "checkbox captcha",
+			status:  http.StatusOK,
+			page:    `<html><body><form id="checkbox-captcha-form" class="CheckboxCaptcha-Form" method="post"></form></body></html>`,
+			captcha: true,
+		},
+		{
+			name:    "showcaptcha redirect",
+			status:  http.StatusOK,
+			page:    `<html><body><a href="https://yandex.ru/showcaptcha?cc=1&amp;retpath=x">continue</a></body></html>`,
+			captcha: true,
+		},
+		{
+			name:    "restricted",
+			status:  http.StatusOK,
+			page:    `<html><body><h1>Access to our service has been temporarily restricted</h1></body></html>`,
+			blocked: true,
+		},
+		{
+			name:    "forbidden",
+			status:  http.StatusForbidden,
+			blocked: true,
+		},
+		{
+			name:   "results",
+			status: http.StatusOK,
+			page:   readFixture(t, "yandex.html"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := NewYandex(DefaultYandexConfig())
+			response := doPage(t, y, tt.status, tt.page)
+
+			if response.Captcha != tt.captcha || response.Blocked != tt.blocked {
+				t.Errorf("captcha = %v, blocked = %v, want %v, %v (error %v)",
+					response.Captcha, response.Blocked, tt.captcha, tt.blocked, response.Error)
+			}
+			if (response.Error != nil) != (tt.captcha || tt.blocked) {
+				t.Errorf("error = %v", response.Error)
+			}
+		})
+	}
+}
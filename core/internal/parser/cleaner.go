@@ -1,464 +1,199 @@
-package proxy
+package parser
 
 import (
-	"math/rand"
-	"sync"
-	"sync/atomic"
-	"time"
+	"errors"
+	"html"
+	"net/url"
+	"sort"
+	"strings"
 )
 
-// RotationStrategy defines how proxies are rotated
-type RotationStrategy string
-
-const (
-	StrategyRoundRobin   RotationStrategy = "round_robin"
-	StrategyRandom       RotationStrategy = "random"
-	StrategyLeastUsed    RotationStrategy = "least_used"
-	StrategyLeastLatency RotationStrategy = "least_latency"
-	StrategyWeighted     RotationStrategy = "weighted"
-)
-
-// Rotator handles proxy rotation
-type Rotator struct {
-	manager       *Manager
-	strategy      RotationStrategy
-	mu            sync.RWMutex
-	currentIndex  uint64
-	usageCount    map[string]int64
-	rotateAfter   int
-	requestCount  map[string]int
-	stickySession map[string]string // task -> proxy mapping
-	rng           *rand.Rand
+// CleanerConfig holds URL cleaning options
+type CleanerConfig struct {
+	UnwrapRedirects      bool     // Replace /url?q= redirects with their target
+	RemoveTrackingParams bool     // Drop the TrackingParams from query strings
+	RemoveFragment       bool     // Drop #fragments
+	TrackingParams       []string // Parameter names; a trailing * matches a prefix
+	MaxLength            int      // Longer URLs are refused; 0 for no limit
 }
 
-// RotatorConfig holds rotator configuration
-type RotatorConfig struct {
-	Strategy     RotationStrategy
-	RotateAfter  int  // Rotate after N requests per proxy
-	StickyTasks  bool // Keep same proxy for same task
-}
-
-// DefaultRotatorConfig returns default configuration
-func DefaultRotatorConfig() RotatorConfig {
-	return RotatorConfig{
-		Strategy:    StrategyRoundRobin,
-		RotateAfter: 1, // Rotate every request by default
-		StickyTasks: false,
+// DefaultCleanerConfig returns default URL cleaning options
+func DefaultCleanerConfig() CleanerConfig {
+	return CleanerConfig{
+		UnwrapRedirects:      true,
+		RemoveTrackingParams: true,
+		RemoveFragment:       true,
+		TrackingParams: []string{
+			"utm_*", "gclid", "fbclid", "msclkid", "yclid", "dclid",
+			"mc_cid", "mc_eid", "_ga", "_gl", "ved", "usg", "sa", "ei",
+		},
+		MaxLength: 2048,
 	}
 }
 
-// NewRotator creates a new proxy rotator
-func NewRotator(manager *Manager, config RotatorConfig) *Rotator {
-	return &Rotator{
-		manager:       manager,
-		strategy:      config.Strategy,
-		usageCount:    make(map[string]int64),
-		rotateAfter:   config.RotateAfter,
-		requestCount:  make(map[string]int),
-		stickySession: make(map[string]string),
-		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
-	}
+// URLCleaner turns raw result links into clean absolute URLs
+type URLCleaner struct {
+	config CleanerConfig
 }
 
-// Next returns the next proxy to use
-func (r *Rotator) Next() *Proxy {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	proxies := r.manager.GetAlive()
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	var proxy *Proxy
-
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(proxies)
-	case StrategyRandom:
-		proxy = r.random(proxies)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(proxies)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(proxies)
-	case StrategyWeighted:
-		proxy = r.weighted(proxies)
-	default:
-		proxy = r.roundRobin(proxies)
-	}
-
-	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
-	}
-
-	return proxy
+// NewURLCleaner creates a new URL cleaner
+func NewURLCleaner(config CleanerConfig) *URLCleaner {
+	return &URLCleaner{config: config}
 }
 
-// NextForTask returns a proxy for a specific task (supports sticky sessions)
-func (r *Rotator) NextForTask(taskID string) *Proxy {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+var (
+	ErrEmptyURL    = errors.New("empty url")
+	ErrInvalidURL  = errors.New("not an absolute http or https url")
+	ErrURLTooLong  = errors.New("url too long")
+	googleRedirect = "/url?"
+)
 
-	// Check for sticky session
-	if proxyID, ok := r.stickySession[taskID]; ok {
-		proxy := r.manager.Get(proxyID)
-		if proxy != nil && proxy.Status == StatusAlive {
-			r.usageCount[proxy.ID]++
-			r.manager.RecordUsage(proxy.ID)
-			return proxy
+// CleanAndExtract returns the URL a raw result link leads to: HTML
+// entities decoded, an escaped URL unescaped, a Google redirect replaced
+// by its target and tracking parameters and the fragment dropped. A link
+// with nothing to drop is returned as written.
+func (c *URLCleaner) CleanAndExtract(rawURL string) (string, error) {
+	link := strings.TrimSpace(html.UnescapeString(rawURL))
+	if link == "" {
+		return "", ErrEmptyURL
+	}
+
+	// Links lifted from attributes are sometimes escaped as a whole
+	lower := strings.ToLower(link)
+	if strings.HasPrefix(lower, "http%3a") || strings.HasPrefix(lower, "https%3a") {
+		if unescaped, err := url.QueryUnescape(link); err == nil {
+			link = unescaped
 		}
-		// Proxy no longer valid, remove sticky session
-		delete(r.stickySession, taskID)
-	}
-
-	proxies := r.manager.GetAlive()
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	var proxy *Proxy
-
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(proxies)
-	case StrategyRandom:
-		proxy = r.random(proxies)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(proxies)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(proxies)
-	case StrategyWeighted:
-		proxy = r.weighted(proxies)
-	default:
-		proxy = r.roundRobin(proxies)
-	}
-
-	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
-		r.stickySession[taskID] = proxy.ID
 	}
 
-	return proxy
-}
-
-// NextN returns N different proxies
-func (r *Rotator) NextN(n int) []*Proxy {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	proxies := r.manager.GetAlive()
-	if len(proxies) == 0 {
-		return nil
+	if c.config.UnwrapRedirects && strings.Contains(link, googleRedirect) {
+		if u, err := url.Parse(link); err == nil {
+			if target := u.Query().Get("q"); target != "" {
+				link = target
+			} else if target := u.Query().Get("url"); target != "" {
+				link = target
+			}
+		}
 	}
 
-	if n > len(proxies) {
-		n = len(proxies)
+	if c.config.MaxLength > 0 && len(link) > c.config.MaxLength {
+		return "", ErrURLTooLong
 	}
 
-	// Shuffle and take first N
-	shuffled := make([]*Proxy, len(proxies))
-	copy(shuffled, proxies)
-	r.rng.Shuffle(len(shuffled), func(i, j int) {
-		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
-	})
-
-	result := shuffled[:n]
-
-	for _, proxy := range result {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", ErrInvalidURL
 	}
 
-	return result
-}
-
-// ShouldRotate checks if proxy should be rotated based on request count
-func (r *Rotator) ShouldRotate(proxyID string) bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	count := r.requestCount[proxyID]
-	return count >= r.rotateAfter
-}
-
-// RecordRequest records a request for rotation tracking
-func (r *Rotator) RecordRequest(proxyID string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.requestCount[proxyID]++
-	if r.requestCount[proxyID] >= r.rotateAfter {
-		r.requestCount[proxyID] = 0
+	changed := false
+	if c.config.RemoveFragment && (u.Fragment != "" || strings.HasSuffix(link, "#")) {
+		u.Fragment = ""
+		u.RawFragment = ""
+		changed = true
 	}
-}
-
-// ResetRequestCount resets request count for a proxy
-func (r *Rotator) ResetRequestCount(proxyID string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.requestCount[proxyID] = 0
-}
-
-// ClearStickySession clears sticky session for a task
-func (r *Rotator) ClearStickySession(taskID string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	delete(r.stickySession, taskID)
-}
-
-// ClearAllStickySessions clears all sticky sessions
-func (r *Rotator) ClearAllStickySessions() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.stickySession = make(map[string]string)
-}
-
-// SetStrategy changes the rotation strategy
-func (r *Rotator) SetStrategy(strategy RotationStrategy) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.strategy = strategy
-}
-
-// GetStrategy returns current strategy
-func (r *Rotator) GetStrategy() RotationStrategy {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	return r.strategy
-}
-
-// SetRotateAfter changes the rotate after count
-func (r *Rotator) SetRotateAfter(count int) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.rotateAfter = count
-}
-
-// Stats returns rotation statistics
-func (r *Rotator) Stats() map[string]interface{} {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	totalUsage := int64(0)
-	maxUsage := int64(0)
-	minUsage := int64(-1)
-
-	for _, count := range r.usageCount {
-		totalUsage += count
-		if count > maxUsage {
-			maxUsage = count
+	if c.config.RemoveTrackingParams && u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if c.isTrackingParam(name) {
+				query.Del(name)
+				changed = true
+			}
 		}
-		if minUsage == -1 || count < minUsage {
-			minUsage = count
+		if changed {
+			u.RawQuery = query.Encode()
 		}
 	}
 
-	if minUsage == -1 {
-		minUsage = 0
-	}
-
-	return map[string]interface{}{
-		"strategy":        r.strategy,
-		"rotate_after":    r.rotateAfter,
-		"total_rotations": totalUsage,
-		"max_usage":       maxUsage,
-		"min_usage":       minUsage,
-		"sticky_sessions": len(r.stickySession),
-	}
-}
-
-// roundRobin returns proxies in order
-func (r *Rotator) roundRobin(proxies []*Proxy) *Proxy {
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	index := atomic.AddUint64(&r.currentIndex, 1) - 1
-	return proxies[index%uint64(len(proxies))]
-}
-
-// random returns a random proxy
-func (r *Rotator) random(proxies []*Proxy) *Proxy {
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	return proxies[r.rng.Intn(len(proxies))]
-}
-
-// leastUsed returns the least used proxy
-func (r *Rotator) leastUsed(proxies []*Proxy) *Proxy {
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	var leastUsedProxy *Proxy
-	minUsage := int64(-1)
-
-	for _, proxy := range proxies {
-		usage := r.usageCount[proxy.ID]
-		if minUsage == -1 || usage < minUsage {
-			minUsage = usage
-			leastUsedProxy = proxy
-		}
+	if !changed {
+		return link, nil
 	}
-
-	return leastUsedProxy
+	return u.String(), nil
 }
 
-// leastLatency returns the proxy with lowest latency
-func (r *Rotator) leastLatency(proxies []*Proxy) *Proxy {
-	if len(proxies) == 0 {
-		return nil
-	}
-
-	var bestProxy *Proxy
-	minLatency := time.Duration(-1)
-
-	for _, proxy := range proxies {
-		if proxy.Latency > 0 {
-			if minLatency == -1 || proxy.Latency < minLatency {
-				minLatency = proxy.Latency
-				bestProxy = proxy
+// isTrackingParam reports whether a query parameter is one to drop
+func (c *URLCleaner) isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range c.config.TrackingParams {
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
 			}
+		} else if name == param {
+			return true
 		}
 	}
-
-	// If no proxy has latency data, fall back to random
-	if bestProxy == nil {
-		return r.random(proxies)
-	}
-
-	return bestProxy
+	return false
 }
 
-// weighted returns a proxy based on weighted random selection
-// Weight is based on success rate and latency
-func (r *Rotator) weighted(proxies []*Proxy) *Proxy {
-	if len(proxies) == 0 {
-		return nil
+// ExtractDomain returns the host of a URL, lowercased and without www.
+func ExtractDomain(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
 	}
-
-	// Calculate weights
-	weights := make([]float64, len(proxies))
-	totalWeight := 0.0
-
-	for i, proxy := range proxies {
-		weight := 1.0
-
-		// Factor in success rate (higher is better)
-		successRate := proxy.SuccessRate()
-		if successRate > 0 {
-			weight *= (successRate / 100.0) + 0.5 // 0.5 to 1.5
-		}
-
-		// Factor in latency (lower is better)
-		if proxy.Latency > 0 {
-			latencyFactor := 1.0 / (float64(proxy.Latency.Milliseconds())/1000.0 + 1)
-			weight *= latencyFactor + 0.5
-		}
-
-		// Factor in usage (lower is better for distribution)
-		usage := r.usageCount[proxy.ID]
-		if usage > 0 {
-			usageFactor := 1.0 / (float64(usage)/100.0 + 1)
-			weight *= usageFactor + 0.5
-		}
-
-		weights[i] = weight
-		totalWeight += weight
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", ErrInvalidURL
 	}
-
-	// Normalize weights and select
-	if totalWeight == 0 {
-		return r.random(proxies)
-	}
-
-	pick := r.rng.Float64() * totalWeight
-	cumulative := 0.0
-
-	for i, weight := range weights {
-		cumulative += weight
-		if pick <= cumulative {
-			return proxies[i]
-		}
-	}
-
-	return proxies[len(proxies)-1]
+	return strings.TrimPrefix(host, "www."), nil
 }
 
-// Exclude returns a proxy excluding specific IDs
-func (r *Rotator) Exclude(excludeIDs []string) *Proxy {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// secondLevelLabels precede a country code in registrable domains such as
+// example.co.uk
+var secondLevelLabels = map[string]bool{
+	"co": true, "com": true, "org": true, "net": true, "gov": true, "edu": true, "ac": true,
+}
 
-	proxies := r.manager.GetAlive()
-	if len(proxies) == 0 {
-		return nil
+// ExtractTopDomain returns the registrable domain of a URL: its last two
+// labels, or three under a second-level label like co.uk
+func ExtractTopDomain(rawURL string) (string, error) {
+	domain, err := ExtractDomain(rawURL)
+	if err != nil {
+		return "", err
 	}
-
-	// Filter out excluded proxies
-	excludeMap := make(map[string]bool)
-	for _, id := range excludeIDs {
-		excludeMap[id] = true
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return domain, nil
 	}
-
-	filtered := make([]*Proxy, 0, len(proxies))
-	for _, proxy := range proxies {
-		if !excludeMap[proxy.ID] {
-			filtered = append(filtered, proxy)
-		}
-	}
-
-	if len(filtered) == 0 {
-		return nil
+	n := 2
+	if len(labels) >= 3 && len(labels[len(labels)-1]) == 2 && secondLevelLabels[labels[len(labels)-2]] {
+		n = 3
 	}
+	return strings.Join(labels[len(labels)-n:], "."), nil
+}
 
-	var proxy *Proxy
+// IsValidURL reports whether a URL is absolute http or https with a host
+func IsValidURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Host != "" && (u.Scheme == "http" || u.Scheme == "https")
+}
 
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(filtered)
-	case StrategyRandom:
-		proxy = r.random(filtered)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(filtered)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(filtered)
-	case StrategyWeighted:
-		proxy = r.weighted(filtered)
-	default:
-		proxy = r.roundRobin(filtered)
+// NormalizeURL returns the form of a URL used to spot duplicates: scheme
+// and host lowercased, www. and a trailing slash dropped, the fragment
+// dropped and the query parameters sorted. A URL that does not parse is
+// only lowercased.
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return strings.ToLower(rawURL)
 	}
 
-	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		sort.Strings(params)
+		u.RawQuery = strings.Join(params, "&")
 	}
-
-	return proxy
-}
-
-// GetUsageCount returns usage count for a proxy
-func (r *Rotator) GetUsageCount(proxyID string) int64 {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	return r.usageCount[proxyID]
+	return u.String()
 }
 
-// ResetUsageCount resets all usage counts
-func (r *Rotator) ResetUsageCount() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	r.usageCount = make(map[string]int64)
+// HasParameters reports whether a URL has a query string
+func HasParameters(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.RawQuery != ""
 }
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// Continuation describes how to request the results after the current
+// page. Newer layouts drop the numbered pager for a "More results" button
+// that loads the next batch asynchronously, so a page can have more
+// results without any pnnext link.
+type Continuation struct {
+	Start int    `json:"start,omitempty"`  // Offset of the next batch's first result, 0 when the page does not say
+	EI    string `json:"ei,omitempty"`     // Search session id (kEI)
+	ArcID string `json:"arc_id,omitempty"` // Id of the async results container
+	Token string `json:"token,omitempty"`  // sstk token of the next-page link
+	Async bool   `json:"async,omitempty"`  // The page loads further results with "More results"
+
+	// DC is the result counter DuckDuckGo's next-page form posts with
+	// Start, the position of the next page's first result
+	DC int `json:"dc,omitempty"`
+}
+
+var (
+	// "More results" button and async results container markers
+	moreResultsPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`aria-label="More results"`),
+		regexp.MustCompile(`>More results<`),
+		regexp.MustCompile(`ve_name:MoreResultsContainer`),
+		regexp.MustCompile(`id="arc-srp_`),
+	}
+
+	// Next-page links, attributes in either order
+	nextHrefPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`<a[^>]+(?:id="pnnext"|aria-label="(?:Next page|More results)")[^>]*href="([^"]+)"`),
+		regexp.MustCompile(`<a[^>]+href="([^"]+)"[^>]*(?:id="pnnext"|aria-label="(?:Next page|More results)")`),
+	}
+
+	eiPattern    = regexp.MustCompile(`kEI\s*[:=]\s*'([^']+)'`)
+	arcIDPattern = regexp.MustCompile(`id="arc-srp_([A-Za-z0-9_-]+)_\d+"`)
+)
+
+// ParseContinuation finds the continuation of a results page: the
+// next-page link's start offset and token, and the "More results" async
+// markers. It returns nil when the page has neither.
+func ParseContinuation(page string) *Continuation {
+	cont := &Continuation{}
+	found := false
+
+	for _, pattern := range moreResultsPatterns {
+		if pattern.MatchString(page) {
+			cont.Async = true
+			found = true
+			break
+		}
+	}
+
+	for _, pattern := range nextHrefPatterns {
+		match := pattern.FindStringSubmatch(page)
+		if match == nil {
+			continue
+		}
+		next, err := url.Parse(html.UnescapeString(match[1]))
+		if err != nil {
+			continue
+		}
+		query := next.Query()
+		if start, err := strconv.Atoi(query.Get("start")); err == nil && start > 0 {
+			cont.Start = start
+			found = true
+		}
+		cont.Token = query.Get("sstk")
+		cont.EI = query.Get("ei")
+		break
+	}
+
+	if !found {
+		return nil
+	}
+
+	if cont.EI == "" {
+		if match := eiPattern.FindStringSubmatch(page); match != nil {
+			cont.EI = match[1]
+		}
+	}
+	if match := arcIDPattern.FindStringSubmatch(page); match != nil {
+		cont.ArcID = match[1]
+	}
+	return cont
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// baseExcludedDomains are the search engines' own domains. They are always
+// excluded and cannot be removed at runtime.
+var baseExcludedDomains = []string{
+	"google.com",
+	"youtube.com",
+	"youtu.be",
+	"gstatic.com",
+	"googleapis.com",
+	"googleusercontent.com",
+	"googlesyndication.com",
+	"googleadservices.com",
+	"doubleclick.net",
+	"google-analytics.com",
+	"schema.org",
+	"w3.org",
+}
+
+// ExclusionList holds the domains whose URLs are dropped from results: an
+// immutable base set plus user domains loaded from files or added at runtime
+type ExclusionList struct {
+	base   map[string]bool
+	custom map[string]bool
+	mu     sync.RWMutex
+}
+
+// NewExclusionList creates an exclusion list with the base domains and the
+// given user domains
+func NewExclusionList(domains ...string) *ExclusionList {
+	l := &ExclusionList{
+		base:   make(map[string]bool),
+		custom: make(map[string]bool),
+	}
+	for _, domain := range baseExcludedDomains {
+		l.base[domain] = true
+	}
+	l.Add(domains...)
+	return l
+}
+
+// normalizeDomain lowercases a domain and strips a scheme, "www." and any
+// path, so "https://www.Example.com/x" becomes "example.com"
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.TrimPrefix(domain, "www.")
+	return strings.TrimSuffix(domain, ".")
+}
+
+// Add excludes the domains and their subdomains, returning how many were
+// not already excluded
+func (l *ExclusionList) Add(domains ...string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	added := 0
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if domain == "" || l.base[domain] || l.custom[domain] {
+			continue
+		}
+		l.custom[domain] = true
+		added++
+	}
+	return added
+}
+
+// Remove stops excluding a user domain. Base domains cannot be removed.
+func (l *ExclusionList) Remove(domain string) error {
+	domain = normalizeDomain(domain)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.base[domain] {
+		return fmt.Errorf("%s is a built-in exclusion", domain)
+	}
+	if !l.custom[domain] {
+		return fmt.Errorf("%s is not excluded", domain)
+	}
+	delete(l.custom, domain)
+	return nil
+}
+
+// LoadFile adds the domains listed in a file such as config/blacklist.txt,
+// one per line; blank lines and lines starting with # are ignored
+func (l *ExclusionList) LoadFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open exclusion file: %w", err)
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read exclusion file: %w", err)
+	}
+
+	return l.Add(domains...), nil
+}
+
+// Contains checks if a domain or one of its parents is excluded. Google's
+// country domains (google.de, www.google.co.uk) always are.
+func (l *ExclusionList) Contains(domain string) bool {
+	domain = strings.ToLower(domain)
+	if strings.HasPrefix(domain, "google.") || strings.HasPrefix(domain, "www.google.") {
+		return true
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for d := domain; d != ""; {
+		if l.base[d] || l.custom[d] {
+			return true
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return false
+}
+
+// Custom returns the user domains, sorted
+func (l *ExclusionList) Custom() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	domains := make([]string, 0, len(l.custom))
+	for domain := range l.custom {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Base returns the built-in domains, sorted
+func (l *ExclusionList) Base() []string {
+	domains := make([]string, 0, len(l.base))
+	for domain := range l.base {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
@@ -1,13 +1,15 @@
 package parser
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 )
 
 // Extractor extracts URLs from HTML content
 type Extractor struct {
-	cleaner *URLCleaner
+	cleaner    *URLCleaner
+	exclusions *ExclusionList
 }
 
 // ExtractionResult holds extraction results
@@ -16,6 +18,13 @@ type ExtractionResult struct {
 	RawURLs     []string // Original URLs before cleaning
 	HasNextPage bool     // Whether there's a next page
 	TotalResults string  // Estimated total results (if found)
+
+	// Types holds each URL's result type (TypeOrganic, TypeAd, ...), in
+	// URLs' order
+	Types []string
+
+	// Continuation is how to request the next page, when the page says
+	Continuation *Continuation
 }
 
 // NewExtractor creates a new URL extractor
@@ -24,10 +33,23 @@ func NewExtractor(cleaner *URLCleaner) *Extractor {
 		cleaner = NewURLCleaner(DefaultCleanerConfig())
 	}
 	return &Extractor{
-		cleaner: cleaner,
+		cleaner:    cleaner,
+		exclusions: NewExclusionList(),
 	}
 }
 
+// Exclusions returns the extractor's excluded domains, which may be edited
+// while it is in use
+func (e *Extractor) Exclusions() *ExclusionList {
+	return e.exclusions
+}
+
+// SetExclusions replaces the extractor's excluded domains, so that several
+// extractors share one list; call it before the extractor is in use
+func (e *Extractor) SetExclusions(l *ExclusionList) {
+	e.exclusions = l
+}
+
 // Google search result patterns
 var (
 	// Main result link patterns
@@ -86,39 +108,6 @@ var (
 		regexp.MustCompile(`No results found`),
 		regexp.MustCompile(`Your search.*?did not match`),
 	}
-
-	// Domains to exclude (Google's own domains, etc.)
-	excludedDomains = map[string]bool{
-		"google.com":           true,
-		"www.google.com":       true,
-		"accounts.google.com":  true,
-		"support.google.com":   true,
-		"policies.google.com":  true,
-		"maps.google.com":      true,
-		"translate.google.com": true,
-		"scholar.google.com":   true,
-		"books.google.com":     true,
-		"news.google.com":      true,
-		"images.google.com":    true,
-		"video.google.com":     true,
-		"play.google.com":      true,
-		"drive.google.com":     true,
-		"docs.google.com":      true,
-		"mail.google.com":      true,
-		"calendar.google.com":  true,
-		"youtube.com":          true,
-		"www.youtube.com":      true,
-		"youtu.be":             true,
-		"gstatic.com":          true,
-		"googleapis.com":       true,
-		"googleusercontent.com": true,
-		"googlesyndication.com": true,
-		"googleadservices.com": true,
-		"doubleclick.net":      true,
-		"google-analytics.com": true,
-		"schema.org":           true,
-		"w3.org":               true,
-	}
 )
 
 // ExtractFromHTML extracts URLs from Google search results HTML
@@ -126,6 +115,7 @@ func (e *Extractor) ExtractFromHTML(html string) *ExtractionResult {
 	result := &ExtractionResult{
 		URLs:    make([]string, 0),
 		RawURLs: make([]string, 0),
+		Types:   make([]string, 0),
 	}
 
 	// Check for empty results
@@ -140,99 +130,166 @@ func (e *Extractor) ExtractFromHTML(html string) *ExtractionResult {
 		result.TotalResults = matches[1]
 	}
 
-	// Check for next page
+	// Check for next page, by the numbered pager or the newer "More
+	// results" button
 	for _, pattern := range nextPagePatterns {
 		if pattern.MatchString(html) {
 			result.HasNextPage = true
 			break
 		}
 	}
+	if cont := ParseContinuation(html); cont != nil {
+		result.HasNextPage = true
+		result.Continuation = cont
+	}
 
-	// Collect all potential URLs
-	urlCandidates := make(map[string]bool)
+	// Collect all potential URLs with their result type, by the container
+	// each is linked from. A URL linked from several places is organic if
+	// any link is.
+	types := findTypeRanges(html)
+	urlCandidates := make(map[string]string)
+	mark := func(rawURL, typ string) {
+		if _, ok := urlCandidates[rawURL]; !ok || typ == TypeOrganic {
+			urlCandidates[rawURL] = typ
+		}
+	}
 
 	// Method 1: Extract from /url?q= pattern
-	googleURLMatches := googleURLPattern.FindAllStringSubmatch(html, -1)
-	for _, match := range googleURLMatches {
-		if len(match) > 1 {
-			decoded := decodeURL(match[1])
-			if decoded != "" {
-				urlCandidates[decoded] = true
-			}
+	for _, match := range googleURLPattern.FindAllStringSubmatchIndex(html, -1) {
+		if decoded := decodeURL(html[match[2]:match[3]]); decoded != "" {
+			mark(decoded, types.typeAt(match[0]))
 		}
 	}
 
 	// Method 2: Extract direct hrefs
-	directMatches := directHrefPattern.FindAllStringSubmatch(html, -1)
-	for _, match := range directMatches {
-		if len(match) > 1 {
-			urlCandidates[match[1]] = true
-		}
+	for _, match := range directHrefPattern.FindAllStringSubmatchIndex(html, -1) {
+		mark(html[match[2]:match[3]], types.typeAt(match[0]))
 	}
 
 	// Method 3: Try all result patterns
 	for _, pattern := range resultPatterns {
-		matches := pattern.FindAllStringSubmatch(html, -1)
-		for _, match := range matches {
-			for i := 1; i < len(match); i++ {
-				if match[i] != "" {
-					// Check if it's a /url?q= format
-					if strings.HasPrefix(match[i], "/url?") {
-						subMatches := googleURLPattern.FindStringSubmatch(match[i])
-						if len(subMatches) > 1 {
-							decoded := decodeURL(subMatches[1])
-							if decoded != "" {
-								urlCandidates[decoded] = true
-							}
+		for _, match := range pattern.FindAllStringSubmatchIndex(html, -1) {
+			typ := types.typeAt(match[0])
+			for i := 2; i+1 < len(match); i += 2 {
+				if match[i] < 0 || match[i] == match[i+1] {
+					continue
+				}
+				value := html[match[i]:match[i+1]]
+				// Check if it's a /url?q= format
+				if strings.HasPrefix(value, "/url?") {
+					subMatches := googleURLPattern.FindStringSubmatch(value)
+					if len(subMatches) > 1 {
+						if decoded := decodeURL(subMatches[1]); decoded != "" {
+							mark(decoded, typ)
 						}
-					} else if strings.HasPrefix(match[i], "http") {
-						urlCandidates[match[i]] = true
 					}
+				} else if strings.HasPrefix(value, "http") {
+					mark(value, typ)
 				}
 			}
 		}
 	}
 
+	// Method 4: Ad click redirects lead to the advertiser
+	for _, match := range adClickPattern.FindAllStringSubmatchIndex(html, -1) {
+		if decoded := decodeURL(html[match[2]:match[3]]); decoded != "" {
+			mark(decoded, TypeAd)
+		}
+	}
+
 	// Process and filter URLs
 	seen := make(map[string]bool)
-	
-	for rawURL := range urlCandidates {
-		// Store raw URL
-		result.RawURLs = append(result.RawURLs, rawURL)
 
-		// Clean the URL
-		cleaned, err := e.cleaner.CleanAndExtract(rawURL)
-		if err != nil || cleaned == "" {
-			continue
-		}
+	for rawURL, typ := range urlCandidates {
+		e.addURL(result, seen, rawURL, typ)
+	}
 
-		// Extract domain for filtering
-		domain, err := ExtractDomain(cleaned)
-		if err != nil {
-			continue
-		}
+	return result
+}
 
-		// Skip excluded domains
-		if e.isExcludedDomain(domain) {
-			continue
-		}
+// ExtractURLs cleans and filters result links an engine has already picked
+// out of its page, keeping their order
+func (e *Extractor) ExtractURLs(rawURLs []string) *ExtractionResult {
+	result := &ExtractionResult{
+		URLs:    make([]string, 0, len(rawURLs)),
+		RawURLs: make([]string, 0, len(rawURLs)),
+		Types:   make([]string, 0, len(rawURLs)),
+	}
 
-		// Skip if not valid URL
-		if !IsValidURL(cleaned) {
-			continue
-		}
+	seen := make(map[string]bool)
+	for _, rawURL := range rawURLs {
+		e.addURL(result, seen, rawURL, TypeOrganic)
+	}
+	return result
+}
 
-		// Deduplicate
-		normalized := NormalizeURL(cleaned)
-		if seen[normalized] {
-			continue
-		}
-		seen[normalized] = true
+// addURL records a raw URL and adds its cleaned form to the result, unless
+// it is invalid, excluded or already seen
+func (e *Extractor) addURL(result *ExtractionResult, seen map[string]bool, rawURL, typ string) {
+	// Store raw URL
+	result.RawURLs = append(result.RawURLs, rawURL)
 
-		result.URLs = append(result.URLs, cleaned)
+	// Clean the URL, unwrapped when it is a Yahoo redirect
+	cleaned, err := e.cleaner.CleanAndExtract(UnwrapYahooURL(rawURL))
+	if err != nil || cleaned == "" {
+		return
 	}
 
-	return result
+	// Extract domain for filtering
+	domain, err := ExtractDomain(cleaned)
+	if err != nil {
+		return
+	}
+
+	// Skip excluded domains
+	if e.isExcludedDomain(domain) {
+		return
+	}
+
+	// Skip if not valid URL
+	if !IsValidURL(cleaned) {
+		return
+	}
+
+	// Deduplicate
+	normalized := NormalizeURL(cleaned)
+	if seen[normalized] {
+		return
+	}
+	seen[normalized] = true
+
+	result.URLs = append(result.URLs, cleaned)
+	result.Types = append(result.Types, typ)
+}
+
+// yahooSegmentPattern matches the start of a Yahoo redirect path segment
+var yahooSegmentPattern = regexp.MustCompile(`/R[A-Z]=`)
+
+// UnwrapYahooURL returns the target of a Yahoo redirect link, the RU
+// segment of r.search.yahoo.com/_ylt=.../RV=2/RU=<target>/RK=2/RS=...,
+// or the link itself when it is not one. A redirect without a target
+// gives "".
+func UnwrapYahooURL(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || !strings.EqualFold(parsed.Hostname(), "r.search.yahoo.com") {
+		return link
+	}
+
+	// The target is escaped within the path, so it is read from the link
+	// as written rather than from the decoded path
+	i := strings.Index(link, "/RU=")
+	if i < 0 {
+		return ""
+	}
+	target := link[i+len("/RU="):]
+	if end := yahooSegmentPattern.FindStringIndex(target); end != nil {
+		target = target[:end[0]]
+	}
+	target, err = url.QueryUnescape(target)
+	if err != nil {
+		return ""
+	}
+	return target
 }
 
 // IsCaptcha checks if the HTML indicates a CAPTCHA page
@@ -246,16 +303,15 @@ func (e *Extractor) IsCaptcha(html string) bool {
 	return false
 }
 
-// IsBlocked checks if the HTML indicates we're blocked
+// IsBlocked checks if the HTML indicates we're blocked. Only whole
+// phrases of block pages count: words like "blocked" or "forbidden" turn
+// up in the titles and snippets of ordinary results.
 func (e *Extractor) IsBlocked(html string) bool {
 	blockedPatterns := []string{
 		"unusual traffic",
 		"automated queries",
 		"please show you're not a robot",
 		"sorry, we could not verify",
-		"blocked",
-		"forbidden",
-		"access denied",
 	}
 
 	htmlLower := strings.ToLower(html)
@@ -280,25 +336,7 @@ func (e *Extractor) IsEmpty(html string) bool {
 
 // isExcludedDomain checks if a domain should be excluded
 func (e *Extractor) isExcludedDomain(domain string) bool {
-	// Direct match
-	if excludedDomains[domain] {
-		return true
-	}
-
-	// Check for Google domains
-	if strings.HasSuffix(domain, ".google.com") ||
-		strings.HasSuffix(domain, ".googleapis.com") ||
-		strings.HasSuffix(domain, ".gstatic.com") ||
-		strings.HasSuffix(domain, ".googleusercontent.com") {
-		return true
-	}
-
-	// Check for google.TLD pattern
-	if strings.HasPrefix(domain, "google.") || strings.HasPrefix(domain, "www.google.") {
-		return true
-	}
-
-	return false
+	return e.exclusions.Contains(domain)
 }
 
 // decodeURL decodes a URL-encoded string
@@ -383,10 +421,12 @@ func (e *Extractor) ExtractWithParams(html string) *ExtractionResult {
 
 	filteredURLs := make([]string, 0)
 	filteredRaw := make([]string, 0)
+	filteredTypes := make([]string, 0)
 
 	for i, u := range fullResult.URLs {
 		if HasParameters(u) {
 			filteredURLs = append(filteredURLs, u)
+			filteredTypes = append(filteredTypes, fullResult.Types[i])
 			if i < len(fullResult.RawURLs) {
 				filteredRaw = append(filteredRaw, fullResult.RawURLs[i])
 			}
@@ -396,8 +436,10 @@ func (e *Extractor) ExtractWithParams(html string) *ExtractionResult {
 	return &ExtractionResult{
 		URLs:        filteredURLs,
 		RawURLs:     filteredRaw,
+		Types:       filteredTypes,
 		HasNextPage: fullResult.HasNextPage,
 		TotalResults: fullResult.TotalResults,
+		Continuation: fullResult.Continuation,
 	}
 }
 
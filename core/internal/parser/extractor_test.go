@@ -0,0 +1,46 @@
+package parser
+
+import "testing"
+
+func TestUnwrapYahooURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{
+			name: "redirect",
+			link: "https://r.search.yahoo.com/_ylt=AwrFQh;_ylu=Y29sbwNiZjE-/RV=2/RE=1700000000/RO=10/RU=https%3a%2f%2fwww.example.com%2fadmin%2f/RK=2/RS=abcDEF-",
+			want: "https://www.example.com/admin/",
+		},
+		{
+			// The target's own escapes survive unwrapping
+			name: "target with query",
+			link: "https://r.search.yahoo.com/_ylt=AwrFQh/RV=2/RE=1700000000/RO=10/RU=https%3a%2f%2fwww.example.com%2fsearch%3fq%3da%2520b%26p%3d1/RK=2/RS=x-",
+			want: "https://www.example.com/search?q=a%20b&p=1",
+		},
+		{
+			name: "target last",
+			link: "https://r.search.yahoo.com/_ylt=AwrFQh/RV=2/RU=https%3a%2f%2fportal.example.org%2flogin",
+			want: "https://portal.example.org/login",
+		},
+		{
+			name: "direct",
+			link: "https://portal.example.org/login",
+			want: "https://portal.example.org/login",
+		},
+		{
+			name: "redirect without target",
+			link: "https://r.search.yahoo.com/_ylt=AwrFQh/RV=2/RE=1700000000/RK=2/RS=x-",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnwrapYahooURL(tt.link); got != tt.want {
+				t.Errorf("UnwrapYahooURL(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Result types: where on the page a result was linked from
+const (
+	TypeOrganic         = "organic"
+	TypeAd              = "ad"               // Sponsored result or googleadservices redirect
+	TypeFeaturedSnippet = "featured_snippet" // Answer box above the results
+	TypeQuestion        = "people_also_ask"  // Link in a "People also ask" answer
+)
+
+// typeMarkers find the opening tag of the containers of non-organic
+// results, in precedence order: an ad in an answer box is an ad
+var typeMarkers = []struct {
+	typ string
+	re  *regexp.Regexp
+}{
+	{TypeAd, regexp.MustCompile(`<div[^>]+\bid="(?:tads|tadsb|bottomads)"|<div[^>]+\bdata-text-ad\b`)},
+	{TypeQuestion, regexp.MustCompile(`<div[^>]+\bclass="[^"]*\brelated-question-pair\b`)},
+	{TypeFeaturedSnippet, regexp.MustCompile(`<block-component\b|<div[^>]+\bclass="[^"]*\b(?:xpdopen|ifM9O)\b`)},
+}
+
+// adClickPattern finds the advertiser URL of an ad click redirect, /aclk on
+// googleadservices.com or Google itself
+var adClickPattern = regexp.MustCompile(`/aclk\?[^"]*?adurl=([^&"]+)`)
+
+// typeRange is a byte range of a page inside a container of one result
+// type, end exclusive
+type typeRange struct {
+	typ        string
+	start, end int
+}
+
+// typeRanges are the containers of non-organic results on a page
+type typeRanges []typeRange
+
+// findTypeRanges returns the ranges of the page inside containers of each
+// non-organic result type, in typeMarkers' order
+func findTypeRanges(html string) typeRanges {
+	var ranges typeRanges
+	for _, marker := range typeMarkers {
+		for _, loc := range marker.re.FindAllStringIndex(html, -1) {
+			if end := elementEnd(html, loc[0]); end > 0 {
+				ranges = append(ranges, typeRange{marker.typ, loc[0], end})
+			}
+		}
+	}
+	return ranges
+}
+
+// typeAt returns the type of a result linked at offset
+func (r typeRanges) typeAt(offset int) string {
+	for _, t := range r {
+		if t.start <= offset && offset < t.end {
+			return t.typ
+		}
+	}
+	return TypeOrganic
+}
+
+// elementEnd returns the offset just past the closing tag of the element
+// opening at start, balancing nested elements of the same name, or 0 when
+// it is never closed
+func elementEnd(html string, start int) int {
+	nameEnd := start + 1
+	for nameEnd < len(html) && isTagNameByte(html[nameEnd]) {
+		nameEnd++
+	}
+	name := html[start+1 : nameEnd]
+	if name == "" {
+		return 0
+	}
+
+	openTag, closeTag := "<"+name, "</"+name+">"
+	depth := 1
+	for i := nameEnd; i < len(html); {
+		nextOpen := indexTag(html[i:], openTag)
+		nextClose := strings.Index(html[i:], closeTag)
+		if nextClose < 0 {
+			return 0
+		}
+		if nextOpen >= 0 && nextOpen < nextClose {
+			depth++
+			i += nextOpen + len(openTag)
+			continue
+		}
+		depth--
+		i += nextClose + len(closeTag)
+		if depth == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// indexTag finds an opening tag of the given "<name" prefix, skipping
+// longer names that share it ("<div" in "<divider")
+func indexTag(s, open string) int {
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], open)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		after := i + len(open)
+		if after >= len(s) || !isTagNameByte(s[after]) {
+			return i
+		}
+		offset = after
+	}
+}
+
+func isTagNameByte(c byte) bool {
+	return c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Organic returns the result without its ads, featured snippets and
+// "People also ask" links. RawURLs are kept as they are.
+func (r *ExtractionResult) Organic() *ExtractionResult {
+	organic := *r
+	organic.URLs = make([]string, 0, len(r.URLs))
+	organic.Types = make([]string, 0, len(r.URLs))
+	for i, u := range r.URLs {
+		if i < len(r.Types) && r.Types[i] != TypeOrganic {
+			continue
+		}
+		organic.URLs = append(organic.URLs, u)
+		organic.Types = append(organic.Types, TypeOrganic)
+	}
+	return &organic
+}
@@ -3,6 +3,8 @@ package protocol
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/google-dork-parser/core/internal/parser"
 )
 
 // MessageType defines the type of message
@@ -19,6 +21,9 @@ const (
 	MsgTypeAddProxy MessageType = "add_proxy"
 	MsgTypeDelProxy MessageType = "del_proxy"
 
+	MsgTypeAddExclusion    MessageType = "add_exclusion"
+	MsgTypeRemoveExclusion MessageType = "remove_exclusion"
+
 	// Outgoing messages (to TypeScript)
 	MsgTypeReady       MessageType = "ready"
 	MsgTypeResult      MessageType = "result"
@@ -63,6 +68,12 @@ const (
 	EngineDuckDuckGo Engine = "duckduckgo"
 	EngineYandex     Engine = "yandex"
 	EngineAsk        Engine = "ask"
+	EngineBrave      Engine = "brave"
+	EngineStartpage  Engine = "startpage"
+	EngineGoogleCSE  Engine = "google_cse"
+	EngineBingAPI    Engine = "bing_api"
+	EngineBaidu      Engine = "baidu"
+	EngineSogou      Engine = "sogou"
 )
 
 // BaseMessage is the common structure for all messages
@@ -92,6 +103,31 @@ type EngineConfig struct {
 	ProxyRotateAfter int      `json:"proxy_rotate_after"`
 	UserAgents       []string `json:"user_agents"`
 	GoogleDomains    []string `json:"google_domains"`
+
+	// Domains dropped from results on top of the engines' own; the file
+	// lists one domain per line
+	ExcludedDomains []string `json:"excluded_domains,omitempty"`
+	ExclusionFile   string   `json:"exclusion_file,omitempty"`
+
+	// Engines enables several engines in place of Engine; tasks naming no
+	// engine are spread across them in proportion to their weights, scaled
+	// down by how often each is blocked. Weights overrides the engines'
+	// default weights; an engine of weight 0 only runs tasks naming it.
+	Engines []Engine           `json:"engines,omitempty"`
+	Weights map[Engine]float64 `json:"weights,omitempty"`
+
+	// Failover is the engine chain tasks move down when their engine keeps
+	// blocking them, each engine giving FailoverAttempts blocked answers
+	// first; empty keeps google, bing, duckduckgo. Only the engines
+	// enabled by Engine or Engines take over tasks.
+	Failover         []Engine `json:"failover,omitempty"`
+	FailoverAttempts int      `json:"failover_attempts,omitempty"`
+
+	// Credentials of the API engines; google_cse needs a key and a cx, and
+	// bing_api uses its keys in turn
+	GoogleCSEKey string   `json:"google_cse_key,omitempty"`
+	GoogleCSECX  string   `json:"google_cse_cx,omitempty"`
+	BingAPIKeys  []string `json:"bing_api_keys,omitempty"`
 }
 
 // TaskMessage assigns a search task
@@ -101,6 +137,44 @@ type TaskMessage struct {
 	Dork   string `json:"dork"`
 	Proxy  string `json:"proxy,omitempty"`
 	Page   int    `json:"page"`
+
+	// Engine runs the task on the named engine; empty leaves the choice to
+	// weighted balancing across the enabled engines
+	Engine string `json:"engine,omitempty"`
+
+	// FanOut runs the task on every enabled engine of positive weight at
+	// once, in place of Engine, and merges the URLs they return
+	FanOut bool `json:"fan_out,omitempty"`
+
+	// Continuation is the previous page's continuation; the page is then
+	// requested through it, and by start offset only when it is absent
+	Continuation *parser.Continuation `json:"continuation,omitempty"`
+
+	// Headers are set on the task's request over the engine's custom
+	// headers; an empty value removes the engine's header
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// SearchType searches a vertical, "news", "images" or "videos",
+	// instead of the web
+	SearchType string `json:"search_type,omitempty"`
+
+	// TimeRange keeps pages indexed within the last hour, day, week, month
+	// or year: "h", "d", "w", "m" or "y"
+	TimeRange string `json:"time_range,omitempty"`
+
+	// DateFrom and DateTo keep pages indexed within a custom range of days,
+	// as YYYY-MM-DD; it takes precedence over TimeRange
+	DateFrom string `json:"date_from,omitempty"`
+	DateTo   string `json:"date_to,omitempty"`
+
+	// Verbatim searches the dork as written, and Filter lets the engine
+	// omit results similar to others
+	Verbatim bool `json:"verbatim,omitempty"`
+	Filter   bool `json:"filter,omitempty"`
+
+	// OrganicOnly drops ads, featured snippets and "People also ask"
+	// links from the results
+	OrganicOnly bool `json:"organic_only,omitempty"`
 }
 
 // ProxyMessage adds or removes a proxy
@@ -110,6 +184,13 @@ type ProxyMessage struct {
 	Protocol string `json:"protocol"` // http, socks4, socks5
 }
 
+// ExclusionMessage adds or removes excluded domains. Subdomains follow
+// their domain; the engines' own domains cannot be removed.
+type ExclusionMessage struct {
+	BaseMessage
+	Domains []string `json:"domains"`
+}
+
 // --- Outgoing Messages ---
 
 // ReadyMessage signals engine is ready
@@ -132,6 +213,17 @@ type ResultMessage struct {
 	HasNextPage bool     `json:"has_next_page"`
 	TimeTaken   int64    `json:"time_taken_ms"`
 	ProxyUsed   string   `json:"proxy_used"`
+
+	// Engine names the engine that produced the results, which is not the
+	// task's when the task failed over. Fan-out results name no engine;
+	// Sources gives the engines that returned each URL instead, in URLs'
+	// order, and there are no raw URLs.
+	Engine  Engine     `json:"engine,omitempty"`
+	Sources [][]Engine `json:"sources,omitempty"`
+
+	// Continuation requests the next page; pass it back in the next page's
+	// task
+	Continuation *parser.Continuation `json:"continuation,omitempty"`
 }
 
 // ErrorMessage reports an error
@@ -244,6 +336,15 @@ func ParseProxy(data []byte) (*ProxyMessage, error) {
 	return &msg, nil
 }
 
+// ParseExclusion parses an add_exclusion or remove_exclusion message
+func ParseExclusion(data []byte) (*ExclusionMessage, error) {
+	var msg ExclusionMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
 // ToJSON converts a message to JSON bytes
 func ToJSON(msg interface{}) ([]byte, error) {
 	return json.Marshal(msg)
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestManagerReleaseBudget(t *testing.T) {
+	config := DefaultManagerConfig()
+	config.MaxReleases = 2
+	config.ReleaseInterval = 50 * time.Millisecond
+	manager := NewManager(config)
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("p%d", i)
+		addProxy(manager, id)
+		// Due in the order added, the first longest overdue
+		manager.Quarantine(id, -time.Duration(5-i)*time.Second)
+	}
+
+	alive := func() int { return len(manager.AliveSnapshot()) }
+
+	// The two longest overdue come back first, and no more until the
+	// interval is over
+	if n := alive(); n != 2 {
+		t.Fatalf("alive = %d after the first release, want 2", n)
+	}
+	for _, proxy := range manager.AliveSnapshot() {
+		if proxy.ID != "p0" && proxy.ID != "p1" {
+			t.Errorf("released %s before the longest overdue", proxy.ID)
+		}
+	}
+	if n := alive(); n != 2 {
+		t.Errorf("alive = %d within the interval, want 2", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n := alive(); n != 4 {
+		t.Errorf("alive = %d after one interval, want 4", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n := alive(); n != 5 {
+		t.Errorf("alive = %d after two intervals, want 5", n)
+	}
+
+	// Without a budget every due proxy comes back at once
+	config.MaxReleases = 0
+	manager = NewManager(config)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("p%d", i)
+		addProxy(manager, id)
+		manager.Quarantine(id, -time.Second)
+	}
+	if n := alive(); n != 5 {
+		t.Errorf("alive = %d without a budget, want 5", n)
+	}
+}
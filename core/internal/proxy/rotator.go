@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"context"
 	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,17 +20,47 @@ const (
 	StrategyWeighted     RotationStrategy = "weighted"
 )
 
+const (
+	// DefaultUsageTTL is how long the counts of an unused proxy are kept
+	DefaultUsageTTL = time.Hour
+
+	// DefaultStickyTTL is how long an idle task keeps its proxy
+	DefaultStickyTTL = 30 * time.Minute
+
+	// DefaultMaxStickySessions bounds the sticky sessions held at once
+	DefaultMaxStickySessions = 10000
+)
+
 // Rotator handles proxy rotation
 type Rotator struct {
 	manager       *Manager
 	strategy      RotationStrategy
-	mu            sync.RWMutex
+	mu            sync.RWMutex // Held for reading by picks, see Next
 	currentIndex  uint64
-	usageCount    map[string]int64
+	usage         map[string]*proxyUsage // proxy -> uses, for compaction too
 	rotateAfter   int
 	requestCount  map[string]int
-	stickySession map[string]string // task -> proxy mapping
-	rng           *rand.Rand
+	stickySession map[string]stickyEntry // task -> proxy mapping
+	weights       WeightConfig
+
+	usageTTL          time.Duration
+	stickyTTL         time.Duration
+	maxStickySessions int
+	now               func() time.Time
+}
+
+// proxyUsage is how often and when the rotator last handed out a proxy.
+// Its fields change atomically, so a pick counts one holding r.mu for
+// reading; only adding and dropping entries needs it for writing.
+type proxyUsage struct {
+	count    atomic.Int64
+	lastUsed atomic.Int64 // UnixNano
+}
+
+// stickyEntry is a task's proxy and when the task last asked for it
+type stickyEntry struct {
+	proxyID  string
+	lastUsed time.Time
 }
 
 // RotatorConfig holds rotator configuration
@@ -36,62 +68,113 @@ type RotatorConfig struct {
 	Strategy     RotationStrategy
 	RotateAfter  int  // Rotate after N requests per proxy
 	StickyTasks  bool // Keep same proxy for same task
+	Weights      WeightConfig // Weighted strategy coefficients; zero uses the defaults
+
+	// Tracking bounds, so a long run over a churning pool keeps steady
+	// memory; zero uses the defaults
+	UsageTTL          time.Duration // Forget the counts of a proxy unused this long
+	StickyTTL         time.Duration // End a sticky session idle this long
+	MaxStickySessions int           // Past this, the idlest sessions end first
+}
+
+// WeightConfig holds the coefficients of the weighted strategy. A proxy's
+// weight is the product of three factors:
+//
+//	success: SuccessFloor + SuccessRate/100
+//	latency: LatencyFloor + 1/(latency/LatencyScale + 1)
+//	usage:   UsageFloor + 1/(uses/UsageScale + 1)
+//
+// A factor is skipped while its input is zero or its scale is zero. Raise
+// the floors to flatten a factor; a short LatencyScale suits latency-sensitive
+// work and a short UsageScale spreads load for ban avoidance.
+type WeightConfig struct {
+	SuccessFloor float64
+	LatencyFloor float64
+	LatencyScale time.Duration // Latency at which the latency bonus halves
+	UsageFloor   float64
+	UsageScale   float64 // Uses at which the usage bonus halves
+}
+
+// DefaultWeightConfig returns the default weighted strategy coefficients
+func DefaultWeightConfig() WeightConfig {
+	return WeightConfig{
+		SuccessFloor: 0.5,
+		LatencyFloor: 0.5,
+		LatencyScale: time.Second,
+		UsageFloor:   0.5,
+		UsageScale:   100,
+	}
 }
 
 // DefaultRotatorConfig returns default configuration
 func DefaultRotatorConfig() RotatorConfig {
 	return RotatorConfig{
-		Strategy:    StrategyRoundRobin,
-		RotateAfter: 1, // Rotate every request by default
-		StickyTasks: false,
+		Strategy:          StrategyRoundRobin,
+		RotateAfter:       1, // Rotate every request by default
+		StickyTasks:       false,
+		Weights:           DefaultWeightConfig(),
+		UsageTTL:          DefaultUsageTTL,
+		StickyTTL:         DefaultStickyTTL,
+		MaxStickySessions: DefaultMaxStickySessions,
 	}
 }
 
 // NewRotator creates a new proxy rotator
 func NewRotator(manager *Manager, config RotatorConfig) *Rotator {
+	if config.Weights == (WeightConfig{}) {
+		config.Weights = DefaultWeightConfig()
+	}
+	if config.UsageTTL <= 0 {
+		config.UsageTTL = DefaultUsageTTL
+	}
+	if config.StickyTTL <= 0 {
+		config.StickyTTL = DefaultStickyTTL
+	}
+	if config.MaxStickySessions <= 0 {
+		config.MaxStickySessions = DefaultMaxStickySessions
+	}
+
 	return &Rotator{
-		manager:       manager,
-		strategy:      config.Strategy,
-		usageCount:    make(map[string]int64),
-		rotateAfter:   config.RotateAfter,
-		requestCount:  make(map[string]int),
-		stickySession: make(map[string]string),
-		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		manager:           manager,
+		strategy:          config.Strategy,
+		usage:             make(map[string]*proxyUsage),
+		rotateAfter:       config.RotateAfter,
+		requestCount:      make(map[string]int),
+		stickySession:     make(map[string]stickyEntry),
+		weights:           config.Weights,
+		usageTTL:          config.UsageTTL,
+		stickyTTL:         config.StickyTTL,
+		maxStickySessions: config.MaxStickySessions,
+		now:               time.Now,
 	}
 }
 
-// Next returns the next proxy to use
+// Next returns the next proxy to use. It selects from the manager's shared
+// alive snapshot, so it neither copies the pool nor allocates, and holds
+// r.mu only for reading, so concurrent picks do not queue behind each
+// other. The first pick of a proxy new to the rotator takes r.mu for
+// writing, to add its usage entry.
 func (r *Rotator) Next() *Proxy {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	proxies := r.manager.GetAlive()
+	r.mu.RLock()
+	proxies := r.manager.AliveSnapshot()
 	if len(proxies) == 0 {
+		r.mu.RUnlock()
 		return nil
 	}
 
-	var proxy *Proxy
-
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(proxies)
-	case StrategyRandom:
-		proxy = r.random(proxies)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(proxies)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(proxies)
-	case StrategyWeighted:
-		proxy = r.weighted(proxies)
-	default:
-		proxy = r.roundRobin(proxies)
+	proxy := r.selectFrom(proxies)
+	usage, ok := r.usage[proxy.ID]
+	if ok {
+		usage.count.Add(1)
+		usage.lastUsed.Store(r.now().UnixNano())
 	}
+	r.mu.RUnlock()
 
-	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
+	if !ok {
+		r.mu.Lock()
+		r.use(proxy)
+		r.mu.Unlock()
 	}
-
 	return proxy
 }
 
@@ -100,44 +183,34 @@ func (r *Rotator) NextForTask(taskID string) *Proxy {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	now := r.now()
+
 	// Check for sticky session
-	if proxyID, ok := r.stickySession[taskID]; ok {
-		proxy := r.manager.Get(proxyID)
-		if proxy != nil && proxy.Status == StatusAlive {
-			r.usageCount[proxy.ID]++
-			r.manager.RecordUsage(proxy.ID)
+	if entry, ok := r.stickySession[taskID]; ok {
+		proxy := r.manager.Get(entry.proxyID)
+		if proxy != nil && proxy.Status == StatusAlive && now.Sub(entry.lastUsed) < r.stickyTTL {
+			r.use(proxy)
+			r.stickySession[taskID] = stickyEntry{proxyID: proxy.ID, lastUsed: now}
 			return proxy
 		}
-		// Proxy no longer valid, remove sticky session
+		// Proxy no longer valid or session expired, remove sticky session
 		delete(r.stickySession, taskID)
 	}
 
-	proxies := r.manager.GetAlive()
+	proxies := r.manager.AliveSnapshot()
 	if len(proxies) == 0 {
 		return nil
 	}
 
-	var proxy *Proxy
-
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(proxies)
-	case StrategyRandom:
-		proxy = r.random(proxies)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(proxies)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(proxies)
-	case StrategyWeighted:
-		proxy = r.weighted(proxies)
-	default:
-		proxy = r.roundRobin(proxies)
-	}
+	proxy := r.selectFrom(proxies)
 
 	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
-		r.stickySession[taskID] = proxy.ID
+		r.use(proxy)
+		if len(r.stickySession) >= r.maxStickySessions {
+			// Make room for a batch, so a full map is not sorted per task
+			r.evictSticky(r.maxStickySessions * 3 / 4)
+		}
+		r.stickySession[taskID] = stickyEntry{proxyID: proxy.ID, lastUsed: now}
 	}
 
 	return proxy
@@ -148,7 +221,7 @@ func (r *Rotator) NextN(n int) []*Proxy {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	proxies := r.manager.GetAlive()
+	proxies := r.manager.AliveSnapshot()
 	if len(proxies) == 0 {
 		return nil
 	}
@@ -160,15 +233,14 @@ func (r *Rotator) NextN(n int) []*Proxy {
 	// Shuffle and take first N
 	shuffled := make([]*Proxy, len(proxies))
 	copy(shuffled, proxies)
-	r.rng.Shuffle(len(shuffled), func(i, j int) {
+	rand.Shuffle(len(shuffled), func(i, j int) {
 		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
 	})
 
 	result := shuffled[:n]
 
 	for _, proxy := range result {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
+		r.use(proxy)
 	}
 
 	return result
@@ -188,9 +260,10 @@ func (r *Rotator) RecordRequest(proxyID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.track(proxyID).lastUsed.Store(r.now().UnixNano())
 	r.requestCount[proxyID]++
 	if r.requestCount[proxyID] >= r.rotateAfter {
-		r.requestCount[proxyID] = 0
+		delete(r.requestCount, proxyID)
 	}
 }
 
@@ -199,7 +272,7 @@ func (r *Rotator) ResetRequestCount(proxyID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.requestCount[proxyID] = 0
+	delete(r.requestCount, proxyID)
 }
 
 // ClearStickySession clears sticky session for a task
@@ -215,7 +288,7 @@ func (r *Rotator) ClearAllStickySessions() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.stickySession = make(map[string]string)
+	r.stickySession = make(map[string]stickyEntry)
 }
 
 // SetStrategy changes the rotation strategy
@@ -251,7 +324,11 @@ func (r *Rotator) Stats() map[string]interface{} {
 	maxUsage := int64(0)
 	minUsage := int64(-1)
 
-	for _, count := range r.usageCount {
+	for _, usage := range r.usage {
+		count := usage.count.Load()
+		if count == 0 {
+			continue // Only requests recorded
+		}
 		totalUsage += count
 		if count > maxUsage {
 			maxUsage = count
@@ -272,6 +349,25 @@ func (r *Rotator) Stats() map[string]interface{} {
 		"max_usage":       maxUsage,
 		"min_usage":       minUsage,
 		"sticky_sessions": len(r.stickySession),
+		"tracked_proxies": len(r.usage),
+	}
+}
+
+// selectFrom picks a proxy from proxies by the current strategy. proxies may
+// be the shared alive snapshot, so strategies only read it; they read the
+// rotator's own state too, needing r.mu held for reading only.
+func (r *Rotator) selectFrom(proxies []*Proxy) *Proxy {
+	switch r.strategy {
+	case StrategyRandom:
+		return r.random(proxies)
+	case StrategyLeastUsed:
+		return r.leastUsed(proxies)
+	case StrategyLeastLatency:
+		return r.leastLatency(proxies)
+	case StrategyWeighted:
+		return r.weighted(proxies)
+	default:
+		return r.roundRobin(proxies)
 	}
 }
 
@@ -291,7 +387,7 @@ func (r *Rotator) random(proxies []*Proxy) *Proxy {
 		return nil
 	}
 
-	return proxies[r.rng.Intn(len(proxies))]
+	return proxies[rand.Intn(len(proxies))]
 }
 
 // leastUsed returns the least used proxy
@@ -304,7 +400,7 @@ func (r *Rotator) leastUsed(proxies []*Proxy) *Proxy {
 	minUsage := int64(-1)
 
 	for _, proxy := range proxies {
-		usage := r.usageCount[proxy.ID]
+		usage := r.usageOf(proxy.ID)
 		if minUsage == -1 || usage < minUsage {
 			minUsage = usage
 			leastUsedProxy = proxy
@@ -323,6 +419,8 @@ func (r *Rotator) leastLatency(proxies []*Proxy) *Proxy {
 	var bestProxy *Proxy
 	minLatency := time.Duration(-1)
 
+	// The manager updates latencies under its own lock
+	r.manager.mu.RLock()
 	for _, proxy := range proxies {
 		if proxy.Latency > 0 {
 			if minLatency == -1 || proxy.Latency < minLatency {
@@ -331,6 +429,7 @@ func (r *Rotator) leastLatency(proxies []*Proxy) *Proxy {
 			}
 		}
 	}
+	r.manager.mu.RUnlock()
 
 	// If no proxy has latency data, fall back to random
 	if bestProxy == nil {
@@ -347,34 +446,17 @@ func (r *Rotator) weighted(proxies []*Proxy) *Proxy {
 		return nil
 	}
 
-	// Calculate weights
-	weights := make([]float64, len(proxies))
-	totalWeight := 0.0
-
-	for i, proxy := range proxies {
-		weight := 1.0
-
-		// Factor in success rate (higher is better)
-		successRate := proxy.SuccessRate()
-		if successRate > 0 {
-			weight *= (successRate / 100.0) + 0.5 // 0.5 to 1.5
-		}
-
-		// Factor in latency (lower is better)
-		if proxy.Latency > 0 {
-			latencyFactor := 1.0 / (float64(proxy.Latency.Milliseconds())/1000.0 + 1)
-			weight *= latencyFactor + 0.5
-		}
-
-		// Factor in usage (lower is better for distribution)
-		usage := r.usageCount[proxy.ID]
-		if usage > 0 {
-			usageFactor := 1.0 / (float64(usage)/100.0 + 1)
-			weight *= usageFactor + 0.5
-		}
+	// Weights are computed twice rather than stored, keeping selection
+	// allocation-free. The manager's read lock holds the health stats
+	// still between the passes, but concurrent picks go on counting uses,
+	// so the second pass can total a little differently from the first;
+	// a pick past its end takes the last proxy.
+	r.manager.mu.RLock()
+	defer r.manager.mu.RUnlock()
 
-		weights[i] = weight
-		totalWeight += weight
+	totalWeight := 0.0
+	for _, proxy := range proxies {
+		totalWeight += r.weight(proxy)
 	}
 
 	// Normalize weights and select
@@ -382,25 +464,53 @@ func (r *Rotator) weighted(proxies []*Proxy) *Proxy {
 		return r.random(proxies)
 	}
 
-	pick := r.rng.Float64() * totalWeight
+	pick := rand.Float64() * totalWeight
 	cumulative := 0.0
 
-	for i, weight := range weights {
-		cumulative += weight
+	for _, proxy := range proxies {
+		cumulative += r.weight(proxy)
 		if pick <= cumulative {
-			return proxies[i]
+			return proxy
 		}
 	}
 
 	return proxies[len(proxies)-1]
 }
 
+// weight scores a proxy for weighted selection using r.weights; the
+// caller holds the manager's read lock
+func (r *Rotator) weight(proxy *Proxy) float64 {
+	w := r.weights
+	weight := 1.0
+
+	// Factor in success rate (higher is better)
+	successRate := proxy.SuccessRate()
+	if successRate > 0 {
+		weight *= (successRate / 100.0) + w.SuccessFloor
+	}
+
+	// Factor in latency (lower is better)
+	if proxy.Latency > 0 && w.LatencyScale > 0 {
+		latencyFactor := 1.0 / (float64(proxy.Latency)/float64(w.LatencyScale) + 1)
+		weight *= latencyFactor + w.LatencyFloor
+	}
+
+	// Factor in usage (lower is better for distribution)
+	usage := r.usageOf(proxy.ID)
+	if usage > 0 && w.UsageScale > 0 {
+		usageFactor := 1.0 / (float64(usage)/w.UsageScale + 1)
+		weight *= usageFactor + w.UsageFloor
+	}
+
+	return weight
+}
+
 // Exclude returns a proxy excluding specific IDs
 func (r *Rotator) Exclude(excludeIDs []string) *Proxy {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	proxies := r.manager.GetAlive()
+	proxies := r.manager.AliveSnapshot()
 	if len(proxies) == 0 {
 		return nil
 	}
@@ -422,26 +532,10 @@ func (r *Rotator) Exclude(excludeIDs []string) *Proxy {
 		return nil
 	}
 
-	var proxy *Proxy
-
-	switch r.strategy {
-	case StrategyRoundRobin:
-		proxy = r.roundRobin(filtered)
-	case StrategyRandom:
-		proxy = r.random(filtered)
-	case StrategyLeastUsed:
-		proxy = r.leastUsed(filtered)
-	case StrategyLeastLatency:
-		proxy = r.leastLatency(filtered)
-	case StrategyWeighted:
-		proxy = r.weighted(filtered)
-	default:
-		proxy = r.roundRobin(filtered)
-	}
+	proxy := r.selectFrom(filtered)
 
 	if proxy != nil {
-		r.usageCount[proxy.ID]++
-		r.manager.RecordUsage(proxy.ID)
+		r.use(proxy)
 	}
 
 	return proxy
@@ -452,7 +546,7 @@ func (r *Rotator) GetUsageCount(proxyID string) int64 {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	return r.usageCount[proxyID]
+	return r.usageOf(proxyID)
 }
 
 // ResetUsageCount resets all usage counts
@@ -460,5 +554,107 @@ func (r *Rotator) ResetUsageCount() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.usageCount = make(map[string]int64)
+	for _, usage := range r.usage {
+		usage.count.Store(0)
+	}
+}
+
+// usageOf returns how often a proxy was handed out; the caller holds r.mu
+// for reading at least
+func (r *Rotator) usageOf(proxyID string) int64 {
+	if usage, ok := r.usage[proxyID]; ok {
+		return usage.count.Load()
+	}
+	return 0
+}
+
+// track returns a proxy's usage entry, adding it if missing; the caller
+// holds r.mu for writing
+func (r *Rotator) track(proxyID string) *proxyUsage {
+	usage, ok := r.usage[proxyID]
+	if !ok {
+		usage = &proxyUsage{}
+		r.usage[proxyID] = usage
+	}
+	return usage
+}
+
+// use counts a selection of proxy; the caller holds r.mu for writing
+func (r *Rotator) use(proxy *Proxy) {
+	usage := r.track(proxy.ID)
+	usage.count.Add(1)
+	usage.lastUsed.Store(r.now().UnixNano())
+}
+
+// Compact forgets what the rotator no longer needs: the counts of proxies
+// removed from the manager or unused for UsageTTL, and sticky sessions idle
+// for StickyTTL or whose proxy is gone. Run it periodically on long runs;
+// StartCompaction does.
+func (r *Rotator) Compact() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+
+	// Survivors go into new maps, since a Go map keeps its buckets after
+	// its entries are deleted
+	usage := make(map[string]*proxyUsage)
+	requestCount := make(map[string]int)
+	for proxyID, entry := range r.usage {
+		used := time.Unix(0, entry.lastUsed.Load())
+		if now.Sub(used) >= r.usageTTL || r.manager.Get(proxyID) == nil {
+			continue
+		}
+		usage[proxyID] = entry
+		if count, ok := r.requestCount[proxyID]; ok {
+			requestCount[proxyID] = count
+		}
+	}
+	r.usage = usage
+	r.requestCount = requestCount
+
+	stickySession := make(map[string]stickyEntry)
+	for taskID, entry := range r.stickySession {
+		if now.Sub(entry.lastUsed) >= r.stickyTTL || r.manager.Get(entry.proxyID) == nil {
+			continue
+		}
+		stickySession[taskID] = entry
+	}
+	r.stickySession = stickySession
+	r.evictSticky(r.maxStickySessions)
+}
+
+// StartCompaction compacts the rotator every interval until ctx is done
+func (r *Rotator) StartCompaction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Compact()
+		}
+	}
+}
+
+// evictSticky ends the idlest sticky sessions until at most n remain; the
+// caller holds r.mu
+func (r *Rotator) evictSticky(n int) {
+	excess := len(r.stickySession) - n
+	if excess <= 0 {
+		return
+	}
+
+	taskIDs := make([]string, 0, len(r.stickySession))
+	for taskID := range r.stickySession {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Slice(taskIDs, func(i, j int) bool {
+		return r.stickySession[taskIDs[i]].lastUsed.Before(r.stickySession[taskIDs[j]].lastUsed)
+	})
+	for _, taskID := range taskIDs[:excess] {
+		delete(r.stickySession, taskID)
+	}
 }
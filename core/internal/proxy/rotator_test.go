@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock drives a rotator's clock by hand
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestRotator(config RotatorConfig) (*Rotator, *Manager, *fakeClock) {
+	manager := NewManager(DefaultManagerConfig())
+	rotator := NewRotator(manager, config)
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	rotator.now = clock.Now
+	return rotator, manager, clock
+}
+
+func addProxy(manager *Manager, id string) {
+	manager.Add(&Proxy{ID: id, Host: "127.0.0.1", Port: "8080", Protocol: ProtocolHTTP})
+	manager.MarkAlive(id, 100*time.Millisecond)
+}
+
+func TestRotatorCompact(t *testing.T) {
+	r, manager, clock := newTestRotator(RotatorConfig{
+		Strategy:  StrategyRoundRobin,
+		UsageTTL:  time.Hour,
+		StickyTTL: 10 * time.Minute,
+	})
+	addProxy(manager, "p1")
+	addProxy(manager, "p2")
+
+	first := r.NextForTask("task")
+	if first == nil {
+		t.Fatal("no proxy")
+	}
+	r.NextForTask("other")
+	r.RecordRequest("p1")
+
+	// A session idle past StickyTTL ends
+	clock.now = clock.now.Add(11 * time.Minute)
+	r.Compact()
+	if n := len(r.stickySession); n != 0 {
+		t.Errorf("%d sticky sessions after StickyTTL, want 0", n)
+	}
+	if r.GetUsageCount("p1") == 0 {
+		t.Error("usage forgotten before UsageTTL")
+	}
+
+	// The counts of a removed proxy go at once, an unused one's after UsageTTL
+	manager.Remove("p2")
+	r.Compact()
+	if _, ok := r.usage["p2"]; ok {
+		t.Error("usage of a removed proxy kept")
+	}
+	clock.now = clock.now.Add(time.Hour)
+	r.Compact()
+	if len(r.usage) != 0 || len(r.requestCount) != 0 {
+		t.Errorf("counts kept past UsageTTL: %v %v", r.usage, r.requestCount)
+	}
+}
+
+func TestRotatorStickyExpiry(t *testing.T) {
+	r, manager, clock := newTestRotator(RotatorConfig{Strategy: StrategyRoundRobin, StickyTTL: 10 * time.Minute})
+	for i := 0; i < 4; i++ {
+		addProxy(manager, fmt.Sprintf("p%d", i))
+	}
+
+	first := r.NextForTask("task")
+	clock.now = clock.now.Add(9 * time.Minute)
+	if got := r.NextForTask("task"); got != first {
+		t.Fatalf("sticky proxy = %v, want %v", got, first)
+	}
+
+	// Each use keeps the session alive; idling past StickyTTL ends it
+	clock.now = clock.now.Add(9 * time.Minute)
+	if got := r.NextForTask("task"); got != first {
+		t.Fatalf("sticky proxy after use = %v, want %v", got, first)
+	}
+	clock.now = clock.now.Add(11 * time.Minute)
+	if got := r.NextForTask("task"); got == first {
+		t.Error("sticky session outlived StickyTTL")
+	}
+}
+
+func TestRotatorMaxStickySessions(t *testing.T) {
+	r, manager, clock := newTestRotator(RotatorConfig{Strategy: StrategyRandom, MaxStickySessions: 100})
+	addProxy(manager, "p1")
+
+	for i := 0; i < 1000; i++ {
+		clock.now = clock.now.Add(time.Second)
+		r.NextForTask(fmt.Sprintf("task-%d", i))
+		if n := len(r.stickySession); n > 100 {
+			t.Fatalf("%d sticky sessions, want at most 100", n)
+		}
+	}
+
+	// The newest sessions are the ones kept
+	if _, ok := r.stickySession["task-999"]; !ok {
+		t.Error("newest sticky session evicted")
+	}
+	if _, ok := r.stickySession["task-0"]; ok {
+		t.Error("oldest sticky session kept")
+	}
+}
+
+// TestRotatorLongRunMemory simulates a day of work over a churning pool:
+// every minute some proxies leave and new ones join, and each new task
+// takes a sticky session. With compaction every 10 minutes, the tracking
+// maps and the heap stay level instead of growing with the run.
+func TestRotatorLongRunMemory(t *testing.T) {
+	const (
+		poolSize       = 200
+		churnPerMinute = 5
+		tasksPerMinute = 200
+	)
+
+	r, manager, clock := newTestRotator(RotatorConfig{
+		Strategy:    StrategyLeastUsed,
+		RotateAfter: 3,
+		StickyTasks: true,
+		UsageTTL:    time.Hour,
+		StickyTTL:   30 * time.Minute,
+	})
+
+	nextProxy, nextTask := 0, 0
+	for ; nextProxy < poolSize; nextProxy++ {
+		addProxy(manager, fmt.Sprintf("proxy-%d", nextProxy))
+	}
+
+	var baseline uint64
+	maxSticky, maxTracked := 0, 0
+	for minute := 1; minute <= 24*60; minute++ {
+		clock.now = clock.now.Add(time.Minute)
+
+		for i := 0; i < churnPerMinute; i++ {
+			manager.Remove(fmt.Sprintf("proxy-%d", nextProxy-poolSize))
+			addProxy(manager, fmt.Sprintf("proxy-%d", nextProxy))
+			nextProxy++
+		}
+
+		for i := 0; i < tasksPerMinute; i++ {
+			if p := r.NextForTask(fmt.Sprintf("task-%d", nextTask)); p != nil {
+				r.RecordRequest(p.ID)
+			}
+			nextTask++
+		}
+
+		if minute%10 == 0 {
+			r.Compact()
+		}
+
+		if n := len(r.stickySession); n > maxSticky {
+			maxSticky = n
+		}
+		if n := len(r.usage); n > maxTracked {
+			maxTracked = n
+		}
+
+		// Measure the heap once the maps have filled up to their steady size
+		if minute == 2*60 {
+			baseline = heapInUse()
+		}
+	}
+
+	// Sessions live at most StickyTTL plus a compaction interval
+	if limit := tasksPerMinute * 40; maxSticky > limit {
+		t.Errorf("max sticky sessions = %d, want at most %d", maxSticky, limit)
+	}
+	// Removed proxies linger at most a compaction interval
+	if limit := poolSize + churnPerMinute*10; maxTracked > limit {
+		t.Errorf("max tracked proxies = %d, want at most %d", maxTracked, limit)
+	}
+	if len(r.requestCount) > maxTracked {
+		t.Errorf("request counts = %d, past %d tracked proxies", len(r.requestCount), maxTracked)
+	}
+
+	// Without compaction the day's 288000 sessions alone take tens of MB
+	final := heapInUse()
+	if final > baseline+4<<20 {
+		t.Errorf("heap grew from %d to %d bytes over the run", baseline, final)
+	}
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// TestRotatorConcurrentNext picks proxies from many goroutines while the
+// manager updates their health and the rotator compacts; run with -race
+func TestRotatorConcurrentNext(t *testing.T) {
+	strategies := []RotationStrategy{StrategyRoundRobin, StrategyRandom, StrategyLeastUsed, StrategyLeastLatency, StrategyWeighted}
+	for _, strategy := range strategies {
+		t.Run(string(strategy), func(t *testing.T) {
+			r, manager, _ := newTestRotator(RotatorConfig{Strategy: strategy})
+			for i := 0; i < 8; i++ {
+				addProxy(manager, fmt.Sprintf("p%d", i))
+			}
+
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 500; i++ {
+						if p := r.Next(); p == nil {
+							t.Error("no proxy")
+							return
+						}
+					}
+				}()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					id := fmt.Sprintf("p%d", i%8)
+					manager.MarkSlow(id, time.Duration(i)*time.Millisecond)
+					r.RecordRequest(id)
+					if i%50 == 0 {
+						r.Compact()
+					}
+				}
+			}()
+			wg.Wait()
+
+			total := int64(0)
+			for i := 0; i < 8; i++ {
+				total += r.GetUsageCount(fmt.Sprintf("p%d", i))
+			}
+			if total != 8*500 {
+				t.Errorf("counted %d picks, want %d", total, 8*500)
+			}
+		})
+	}
+}
+
+func TestRotatorNextAllocs(t *testing.T) {
+	for _, strategy := range []RotationStrategy{StrategyRoundRobin, StrategyLeastUsed, StrategyWeighted} {
+		r, manager, _ := newTestRotator(RotatorConfig{Strategy: strategy})
+		for i := 0; i < 8; i++ {
+			addProxy(manager, fmt.Sprintf("p%d", i))
+		}
+		// The first pick of each proxy adds its usage entry
+		for i := 0; i < 64; i++ {
+			r.Next()
+		}
+		if allocs := testing.AllocsPerRun(100, func() { r.Next() }); allocs != 0 {
+			t.Errorf("%s: Next allocates %.1f times, want 0", strategy, allocs)
+		}
+	}
+}
+
+// BenchmarkRotatorNext picks proxies from parallel goroutines; picks share
+// r.mu for reading, so they scale with GOMAXPROCS instead of queuing
+func BenchmarkRotatorNext(b *testing.B) {
+	for _, strategy := range []RotationStrategy{StrategyRoundRobin, StrategyLeastUsed, StrategyWeighted} {
+		b.Run(string(strategy), func(b *testing.B) {
+			r, manager, _ := newTestRotator(RotatorConfig{Strategy: strategy})
+			for i := 0; i < 100; i++ {
+				addProxy(manager, fmt.Sprintf("p%d", i))
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					r.Next()
+				}
+			})
+		})
+	}
+}
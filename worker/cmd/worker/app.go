@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+
+	"dorker/worker/internal/audit"
+	"dorker/worker/internal/classify"
+	"dorker/worker/internal/daemon"
+	"dorker/worker/internal/egress"
+	"dorker/worker/internal/htmldump"
+	"dorker/worker/internal/logging"
+	"dorker/worker/internal/pacing"
+	"dorker/worker/internal/params"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/sshtunnel"
+	"dorker/worker/internal/worker"
+)
+
+// app holds the process-wide state set up from the command line: what
+// every job shares and what exit has to clean up
+type app struct {
+	logger  *logging.Logger
+	logRing *logging.RingBuffer // Entries served to get_logs
+
+	// pidFilePath is removed by exit when set
+	pidFilePath string
+
+	// auditLog is closed by exit when set
+	auditLog *audit.Log
+
+	// htmlDump receives fetched results pages when set
+	htmlDump *htmldump.Dumper
+
+	// egressDialer carries connections to the scraping proxies through the
+	// machine's own proxy; nil connects to them directly
+	egressDialer *egress.Dialer
+
+	// hostOverrides pins host names for requests through the proxies,
+	// from --hosts-file and --host
+	hostOverrides proxy.Hosts
+
+	// sshTunnels serves the --ssh-hosts servers as proxies and is closed
+	// by exit when set
+	sshTunnels *sshtunnel.Tunnels
+
+	// rotatingExits are the --vpn-proxy and --tor proxies, whose exits
+	// are rotated when burned
+	rotatingExits []rotatingExit
+
+	// pacingRecorder is exported to pacingExportPath by exit when set
+	pacingRecorder   *pacing.Recorder
+	pacingExportPath string
+
+	// paramHarvester is exported to paramsExportPath by exit when set
+	paramHarvester   *params.Harvester
+	paramsExportPath string
+
+	// exportPool is exported to proxiesExportPath by exit when that is set
+	exportPool        *proxy.Pool
+	proxiesExportPath string
+
+	// serviceStop is closed by the Windows service manager to request a
+	// drain; nil (never ready) otherwise
+	serviceStop <-chan struct{}
+
+	// reattachListener is closed by exit when set, removing a Unix socket
+	reattachListener net.Listener
+
+	// allowSensitive lets dorks tagged by dorklist.Sensitive run; without
+	// it they are held back
+	allowSensitive bool
+
+	// crashDir receives crash dump bundles; empty uses the temp directory
+	crashDir string
+
+	// panicStack holds the stack of the last recovered panic for the crash
+	// dump
+	panicStack atomic.Pointer[[]byte]
+}
+
+// exit writes the pacing, parameter and proxy exports, closes the audit log
+// and the SSH tunnels, removes the PID file and the reattach socket and
+// exits; os.Exit skips deferred cleanup
+func (a *app) exit(code int) {
+	if a.pacingRecorder != nil {
+		if err := a.pacingRecorder.Export(a.pacingExportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		}
+	}
+	if a.paramHarvester != nil {
+		if err := a.paramHarvester.Export(a.paramsExportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		}
+	}
+	if a.exportPool != nil && a.proxiesExportPath != "" {
+		if err := proxy.ExportList(a.proxiesExportPath, a.exportPool.GetAll()); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		}
+	}
+	if a.auditLog != nil {
+		a.auditLog.Close()
+	}
+	a.sshTunnels.Close()
+	if a.pidFilePath != "" {
+		daemon.RemovePIDFile(a.pidFilePath)
+	}
+	if a.reattachListener != nil {
+		a.reattachListener.Close()
+	}
+	os.Exit(code)
+}
+
+// newPool creates the proxy pool of a job, reached through the egress
+// dialer with the job's bind rules, and keeps it for --proxies-export
+func (a *app) newPool(config *protocol.InitConfig) *proxy.Pool {
+	// Bind rules shape every connection of the job, checks included
+	a.egressDialer = a.egressDialer.WithBind(config.Bind)
+
+	pool := proxy.NewPool(poolConfigFrom(config))
+	pool.SetEgress(a.egressDialer)
+	a.exportPool = pool
+	return pool
+}
+
+// newWorker creates a worker on pool with the shared outputs attached.
+// Alerts are logged and passed to onAlert.
+func (a *app) newWorker(config worker.Config, pool *proxy.Pool, onAlert func(worker.Alert)) *worker.Worker {
+	w := worker.New(config, pool)
+	w.SetAuditLog(a.auditLog)
+	w.SetEgress(a.egressDialer)
+	w.SetHosts(a.hostOverrides)
+	w.SetHTMLDump(a.htmlDump)
+	w.SetClassifier(classify.New(w.Config().Classifier))
+	w.SetPacingRecorder(a.pacingRecorder)
+	w.SetParamHarvester(a.paramHarvester)
+	w.SetAlertHandler(func(alert worker.Alert) {
+		a.logger.Warnf("Alert %s: %s", alert.Event, alert.Message)
+		onAlert(alert)
+	})
+	w.SetAlertErrorHandler(func(err error) {
+		a.logger.Warnf("Alert notification failed: %v", err)
+	})
+	return w
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"dorker/worker/internal/bench"
+)
+
+// runBench runs `worker bench` and returns the exit code: 0 when every task
+// succeeded
+func runBench(args []string) int {
+	config := bench.DefaultConfig()
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.IntVar(&config.Workers, "workers", config.Workers, "Worker goroutines")
+	fs.IntVar(&config.Proxies, "proxies", config.Proxies, "Proxies in the pool, all leading to the mock engine")
+	fs.IntVar(&config.Tasks, "tasks", config.Tasks, "Search tasks to run")
+	fs.IntVar(&config.Results, "results", config.Results, "Results on each mock results page")
+	fs.DurationVar(&config.Latency, "latency", config.Latency, "Mock engine answer time")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Time limit for the whole run")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	if !*asJSON {
+		fmt.Printf("Dorker Worker v%s benchmark\n\n", Version)
+	}
+	report, err := bench.Run(ctx, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bench: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.Write(os.Stdout)
+	}
+
+	if report.Failed > 0 || report.Succeeded < config.Tasks {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"time"
+
+	"dorker/worker/internal/asn"
+	"dorker/worker/internal/cache"
+	"dorker/worker/internal/classify"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/dirlist"
+	"dorker/worker/internal/fingerprint"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/resolve"
+	"dorker/worker/internal/verify"
+	"dorker/worker/internal/worker"
+)
+
+// workerConfigFrom maps init settings onto a worker configuration
+func workerConfigFrom(config *protocol.InitConfig) worker.Config {
+	workerConfig := worker.DefaultConfig()
+	workerConfig.Workers = config.Workers
+	workerConfig.RequestTimeout = config.Timeout
+	workerConfig.BaseDelay = config.BaseDelay
+	workerConfig.MinDelay = config.MinDelay
+	workerConfig.MaxDelay = config.MaxDelay
+	workerConfig.MaxRetries = config.MaxRetries
+	workerConfig.RetryDelay = config.RetryDelay
+	workerConfig.ResultsPerPage = config.ResultsPerPage
+	workerConfig.WarmUp = config.WarmUp
+	workerConfig.TaskTimeout = config.TaskTimeout
+	workerConfig.MaxRunDuration = config.MaxRunDuration
+	workerConfig.MaxRequests = config.MaxRequests
+	workerConfig.EngineMaxRequests = config.EngineMaxRequests
+	workerConfig.Costs = config.Costs
+	if config.Dedup != "" {
+		workerConfig.Dedup.Mode = dedup.Mode(config.Dedup)
+	}
+	if config.DedupCapacity > 0 {
+		workerConfig.Dedup.Capacity = config.DedupCapacity
+	}
+	if config.DedupFPRate > 0 {
+		workerConfig.Dedup.FalsePositiveRate = config.DedupFPRate
+	}
+	workerConfig.Dedup.SpillPath = config.DedupSpill
+	workerConfig.Dedup.Granularity = dedup.Granularity(config.DedupGranularity)
+	workerConfig.ParseWorkers = config.ParseWorkers
+	workerConfig.EnrichWorkers = config.EnrichWorkers
+	workerConfig.CanaryDork = config.CanaryDork
+	workerConfig.CanaryInterval = config.CanaryInterval
+	workerConfig.CanaryMinResults = config.CanaryMinResults
+	workerConfig.GeoMatch = config.GeoMatch
+	workerConfig.Classifier = classify.Config{URL: config.ClassifierURL, Timeout: config.ClassifierTimeout}
+	workerConfig.MinConfidence = config.MinConfidence
+	workerConfig.Assets = config.AssetFilter
+	workerConfig.SERPFeatures = config.SERPFeatures
+	workerConfig.Mobile = config.MobileSERP
+	workerConfig.OrganicOnly = config.OrganicOnly
+	workerConfig.Scope = config.Scope
+	workerConfig.TitleInclude = config.TitleInclude
+	workerConfig.TitleExclude = config.TitleExclude
+	workerConfig.DetectLanguage = config.DetectLanguage
+	workerConfig.Languages = config.Languages
+	workerConfig.Resolve = resolve.Config{
+		Enabled: config.ResolveRedirects,
+		Hosts:   config.ResolveHosts,
+		MaxHops: config.ResolveMaxHops,
+		Timeout: config.ResolveTimeout,
+	}
+	workerConfig.Crawl = dirlist.Config{
+		Enabled:  config.CrawlListings,
+		MaxDepth: config.CrawlMaxDepth,
+		MaxFiles: config.CrawlMaxFiles,
+		Timeout:  config.CrawlTimeout,
+	}
+	workerConfig.Verify = verify.Config{
+		Patterns: config.VerifyPatterns,
+		Require:  config.VerifyRequire,
+		Timeout:  config.VerifyTimeout,
+	}
+	workerConfig.Fingerprint = fingerprint.Config{Enabled: config.FingerprintHosts}
+	workerConfig.Networks = asn.Config{Enabled: config.ResolveNetworks}
+	workerConfig.Alerts = config.Alerts
+	workerConfig.Cache = cache.Config{TTL: config.CacheTTL, Dir: config.CacheDir}
+	workerConfig.IdempotencyWindow = config.IdempotencyWindow
+	workerConfig.Seed = config.Seed
+	workerConfig.TraceRate = config.TraceRate
+	return workerConfig
+}
+
+// poolConfigFrom maps init settings onto a proxy pool configuration
+func poolConfigFrom(config *protocol.InitConfig) proxy.PoolConfig {
+	poolConfig := proxy.DefaultPoolConfig()
+	if config.Strategy != "" {
+		poolConfig.Strategy = proxy.RotationStrategy(config.Strategy)
+	}
+	poolConfig.Shards = config.PoolShards
+	poolConfig.MaxShare = config.MaxProxyShare
+	switch {
+	case config.Probation > 0:
+		poolConfig.ProbationSuccesses = config.Probation
+	case config.Probation < 0:
+		poolConfig.ProbationSuccesses = 0
+	}
+	if config.ProbationShare > 0 {
+		poolConfig.ProbationShare = config.ProbationShare
+	}
+	if w := config.Weights.SuccessBonus; w != nil {
+		poolConfig.Weights.SuccessBonus = *w
+	}
+	if w := config.Weights.SlowLatency; w != nil {
+		poolConfig.Weights.SlowLatency = time.Duration(*w) * time.Millisecond
+	}
+	if w := config.Weights.SlowFactor; w != nil {
+		poolConfig.Weights.SlowFactor = *w
+	}
+	if config.Preflight {
+		poolConfig.PreflightURL = config.PreflightURL
+		if poolConfig.PreflightURL == "" {
+			poolConfig.PreflightURL = proxy.DefaultPreflightURL
+		}
+	}
+	if config.Recheck {
+		poolConfig.RecheckURL = config.PreflightURL
+		if poolConfig.RecheckURL == "" {
+			poolConfig.RecheckURL = proxy.DefaultPreflightURL
+		}
+	}
+	switch {
+	case config.MaxReleases > 0:
+		poolConfig.MaxReleases = config.MaxReleases
+	case config.MaxReleases < 0:
+		poolConfig.MaxReleases = 0
+	}
+	return poolConfig
+}
+
+// weightsFrom describes pool weight coefficients with the init keys
+func weightsFrom(weights proxy.WeightConfig) protocol.Weights {
+	slowLatency := weights.SlowLatency.Milliseconds()
+	return protocol.Weights{
+		SuccessBonus: &weights.SuccessBonus,
+		SlowLatency:  &slowLatency,
+		SlowFactor:   &weights.SlowFactor,
+	}
+}
+
+// effectiveConfig describes the settings the worker is actually running
+// with, after defaults, profile, file, environment and reloads are merged
+func effectiveConfig(profile, proxyFile string, w *worker.Worker, pool *proxy.Pool) *protocol.Message {
+	workerConfig := w.Config()
+	poolConfig := pool.Config()
+
+	// The init keys turn probation and the release budget off with -1,
+	// since 0 asks for the default
+	probation := poolConfig.ProbationSuccesses
+	if probation == 0 {
+		probation = -1
+	}
+	maxReleases := poolConfig.MaxReleases
+	if maxReleases == 0 {
+		maxReleases = -1
+	}
+
+	config := &protocol.InitConfig{
+		Profile:           profile,
+		Workers:           workerConfig.Workers,
+		Timeout:           workerConfig.RequestTimeout,
+		BaseDelay:         workerConfig.BaseDelay,
+		MinDelay:          workerConfig.MinDelay,
+		MaxDelay:          workerConfig.MaxDelay,
+		MaxRetries:        workerConfig.MaxRetries,
+		RetryDelay:        workerConfig.RetryDelay,
+		ResultsPerPage:    workerConfig.ResultsPerPage,
+		Strategy:          string(poolConfig.Strategy),
+		WarmUp:            workerConfig.WarmUp,
+		TaskTimeout:       workerConfig.TaskTimeout,
+		MaxRunDuration:    workerConfig.MaxRunDuration,
+		MaxRequests:       workerConfig.MaxRequests,
+		EngineMaxRequests: workerConfig.EngineMaxRequests,
+		Costs:             workerConfig.Costs,
+		Dedup:             string(workerConfig.Dedup.Mode),
+		DedupCapacity:     workerConfig.Dedup.Capacity,
+		DedupFPRate:       workerConfig.Dedup.FalsePositiveRate,
+		DedupSpill:        workerConfig.Dedup.SpillPath,
+		DedupGranularity:  string(workerConfig.Dedup.Granularity),
+		ParseWorkers:      workerConfig.ParseWorkers,
+		EnrichWorkers:     workerConfig.EnrichWorkers,
+		PoolShards:        poolConfig.Shards,
+		MaxProxyShare:     poolConfig.MaxShare,
+		Probation:         probation,
+		ProbationShare:    poolConfig.ProbationShare,
+		Weights:           weightsFrom(poolConfig.Weights),
+		Preflight:         poolConfig.PreflightURL != "",
+		PreflightURL:      poolConfig.PreflightURL,
+		Recheck:           poolConfig.RecheckURL != "",
+		MaxReleases:       maxReleases,
+		CanaryDork:        workerConfig.CanaryDork,
+		CanaryInterval:    workerConfig.CanaryInterval,
+		CanaryMinResults:  workerConfig.CanaryMinResults,
+		GeoMatch:          workerConfig.GeoMatch,
+		ClassifierURL:     workerConfig.Classifier.URL,
+		ClassifierTimeout: workerConfig.Classifier.Timeout,
+		MinConfidence:     workerConfig.MinConfidence,
+		AssetFilter:       workerConfig.Assets,
+		SERPFeatures:      workerConfig.SERPFeatures,
+		MobileSERP:        workerConfig.Mobile,
+		OrganicOnly:       workerConfig.OrganicOnly,
+		Scope:             workerConfig.Scope,
+		TitleInclude:      workerConfig.TitleInclude,
+		TitleExclude:      workerConfig.TitleExclude,
+		DetectLanguage:    workerConfig.DetectLanguage,
+		Languages:         workerConfig.Languages,
+		ResolveRedirects:  workerConfig.Resolve.Enabled,
+		ResolveHosts:      workerConfig.Resolve.Hosts,
+		ResolveMaxHops:    workerConfig.Resolve.MaxHops,
+		ResolveTimeout:    workerConfig.Resolve.Timeout,
+		CrawlListings:     workerConfig.Crawl.Enabled,
+		CrawlMaxDepth:     workerConfig.Crawl.MaxDepth,
+		CrawlMaxFiles:     workerConfig.Crawl.MaxFiles,
+		CrawlTimeout:      workerConfig.Crawl.Timeout,
+		VerifyPatterns:    workerConfig.Verify.Patterns,
+		VerifyRequire:     workerConfig.Verify.Require,
+		VerifyTimeout:     workerConfig.Verify.Timeout,
+		FingerprintHosts:  workerConfig.Fingerprint.Enabled,
+		ResolveNetworks:   workerConfig.Networks.Enabled,
+		Alerts:            workerConfig.Alerts,
+		CacheTTL:          workerConfig.Cache.TTL,
+		CacheDir:          workerConfig.Cache.Dir,
+		IdempotencyWindow: workerConfig.IdempotencyWindow,
+		Seed:              workerConfig.Seed,
+		TraceRate:         workerConfig.TraceRate,
+		ProxyFile:         proxyFile,
+	}
+
+	msg := config.ToMessage()
+	msg.SetData("initialized", true)
+	msg.SetData("proxy_count", pool.Stats().Total)
+	msg.SetData("buffer_size", workerConfig.BufferSize)
+	msg.SetData("max_pages", workerConfig.MaxPages)
+	msg.SetData("pool", map[string]any{
+		"max_failures":          poolConfig.MaxFailures,
+		"cooldown_duration":     poolConfig.CooldownDuration.Milliseconds(),
+		"quarantine_duration":   poolConfig.QuarantineDuration.Milliseconds(),
+		"health_check_interval": poolConfig.HealthCheckInterval.Milliseconds(),
+		"min_success_rate":      poolConfig.MinSuccessRate,
+	})
+	return msg
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"runtime/debug"
+
+	"dorker/worker/internal/crashdump"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/worker"
+)
+
+// recordPanic keeps the panicking goroutine's stack; call it from the
+// deferred function that recovered
+func (a *app) recordPanic() {
+	stack := debug.Stack()
+	a.panicStack.Store(&stack)
+}
+
+// writeCrashDump bundles recent protocol traffic, pool stats, the effective
+// config and the stacks of a fatal termination and returns the bundle's
+// path, or "" when it could not be written
+func (a *app) writeCrashDump(reason protocol.ShutdownReason, message string, handler *protocol.Handler,
+	profile, proxyFile string, w *worker.Worker, pool *proxy.Pool) string {
+	bundle := crashdump.Bundle{
+		Version: Version,
+		Reason:  string(reason),
+		Message: message,
+		Sections: map[string]any{
+			"messages": handler.History(),
+		},
+	}
+	if stack := a.panicStack.Load(); stack != nil {
+		bundle.PanicStack = *stack
+	}
+	if pool != nil {
+		bundle.Sections["pool"] = pool.Stats()
+	}
+	if w != nil {
+		bundle.Sections["worker"] = w.Stats()
+		if pool != nil {
+			// Proxies are summarized as a count, never listed
+			bundle.Sections["config"] = effectiveConfig(profile, proxyFile, w, pool).Data
+		}
+	}
+
+	path, err := crashdump.Write(a.crashDir, bundle)
+	if err != nil {
+		handler.SendLog("error", err.Error())
+		return ""
+	}
+	return path
+}
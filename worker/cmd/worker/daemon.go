@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"dorker/worker/internal/daemon"
+)
+
+// Service actions accepted by --service
+const (
+	serviceInstall   = "install"
+	serviceUninstall = "uninstall"
+	serviceRun       = "run"
+)
+
+// runServiceCommand installs or uninstalls the worker as a system service
+func runServiceCommand(action, name string) int {
+	switch action {
+	case serviceInstall:
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ Failed to locate executable: %v\n", err)
+			return 1
+		}
+		exe, _ = filepath.Abs(exe)
+
+		config := daemon.DefaultServiceConfig(exe)
+		config.Name = name
+		config.Args = serviceArgs(name)
+		if err := daemon.InstallService(config); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		fmt.Printf("✓ Installed service %s\n", name)
+		if runtime.GOOS == "linux" {
+			fmt.Printf("  Enable it with: systemctl daemon-reload && systemctl enable --now %s\n", name)
+		}
+		return 0
+
+	case serviceUninstall:
+		if err := daemon.UninstallService(name); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return 1
+		}
+		fmt.Printf("✓ Uninstalled service %s\n", name)
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "✗ Unknown service action %q (want install, uninstall or run)\n", action)
+		return 1
+	}
+}
+
+// serviceArgs rebuilds the command line for the installed service from the
+// flags given alongside --service install. Relative paths would resolve
+// against the service manager's working directory, so they are made absolute.
+func serviceArgs(name string) []string {
+	args := []string{"--standalone"}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "service", "service-name", "standalone":
+			return
+		case "dorks", "proxies", "output", "config", "pid-file", "log-file", "audit-log", "pacing-export", "proxies-export":
+			if abs, err := filepath.Abs(f.Value.String()); err == nil {
+				args = append(args, "--"+f.Name+"="+abs)
+				return
+			}
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+
+	// The Windows service manager needs the process to register with it
+	if runtime.GOOS == "windows" {
+		args = append(args, "--service="+serviceRun, "--service-name="+name)
+	}
+	return args
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"dorker/worker/internal/runfile"
+)
+
+// runDiff runs `worker diff` and returns the exit code, following diff(1):
+// 0 when the runs hold the same URLs, 1 when they differ, 2 on errors
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the diff as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: worker diff [--json] <run A> <run B>")
+		return 2
+	}
+
+	a, err := runfile.Load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return 2
+	}
+	b, err := runfile.Load(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %v\n", err)
+		return 2
+	}
+
+	d := runfile.Compare(a, b)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(d)
+	} else {
+		d.Write(os.Stdout)
+	}
+
+	if d.Changed() {
+		return 1
+	}
+	return 0
+}
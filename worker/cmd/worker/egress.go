@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dorker/worker/internal/logging"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/sshtunnel"
+	"dorker/worker/internal/tor"
+	"dorker/worker/internal/vpn"
+)
+
+// rotatingExit is a provided proxy with the rotator of its exit
+type rotatingExit struct {
+	proxy    *proxy.Proxy
+	rotator  proxy.Rotator // nil leaves the exit alone
+	interval time.Duration // Least time between two rotations
+}
+
+// startSSHTunnels opens a tunnel to each SSH server listed in path;
+// servers that fail to connect are logged and left out
+func (a *app) startSSHTunnels(path string, config sshtunnel.Config) error {
+	hosts, errs := sshtunnel.ParseFile(path)
+	for _, err := range errs {
+		a.logger.Warnf("SSH host: %v", err)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no usable SSH hosts in %s", path)
+	}
+
+	a.sshTunnels, errs = sshtunnel.Start(hosts, config)
+	for _, err := range errs {
+		a.logger.Warnf("SSH tunnel: %v", err)
+	}
+	a.logger.Infof("SSH: %d of %d tunnels up", len(a.sshTunnels.Proxies()), len(hosts))
+	return nil
+}
+
+// addVPNExit adds the proxy of a VPN client whose exit rotate changes
+func (a *app) addVPNExit(line, rotate string, interval time.Duration) error {
+	vpnProxy, err := proxy.NewParser().ParseLine(line)
+	if err != nil || vpnProxy == nil {
+		return fmt.Errorf("invalid --vpn-proxy %s", proxy.RedactCredentials(line))
+	}
+	vpnProxy.Provider = vpn.Provider
+
+	rotator, err := vpn.New(rotate)
+	if err != nil {
+		return err
+	}
+	a.rotatingExits = append(a.rotatingExits, rotatingExit{vpnProxy, loggedRotator{rotator, vpnProxy.ID, a.logger}, interval})
+	a.logger.Infof("VPN: exit %s rotated at most every %s", vpnProxy, interval)
+	return nil
+}
+
+// addTorExit adds the local Tor client, rotating its circuits through the
+// control port when one is configured
+func (a *app) addTorExit(config tor.Config, interval time.Duration) error {
+	torProxy, err := tor.Proxy(config)
+	if err != nil {
+		return err
+	}
+
+	exit := rotatingExit{proxy: torProxy}
+	if config.ControlAddr != "" {
+		if config.Password, err = proxy.ExpandEnv(config.Password); err != nil {
+			return fmt.Errorf("--tor-password: %w", err)
+		}
+		controller := tor.NewController(config)
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		err := controller.Check(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		exit.rotator = loggedRotator{controller, torProxy.ID, a.logger}
+		exit.interval = max(interval, tor.NewnymInterval)
+	}
+	a.rotatingExits = append(a.rotatingExits, exit)
+	a.logger.Infof("Tor: searching through %s, circuits rotated at most every %s", config.SOCKSAddr, exit.interval)
+	return nil
+}
+
+// addProvidedProxies adds the SSH tunnel, VPN and Tor proxies to a pool
+// and returns how many it added
+func (a *app) addProvidedProxies(pool *proxy.Pool) int {
+	added, _ := pool.AddProxies(a.sshTunnels.Proxies())
+	for _, exit := range a.rotatingExits {
+		if pool.AddProxy(exit.proxy) == nil {
+			pool.SetRotator(exit.proxy.ID, exit.rotator, exit.interval)
+			added++
+		}
+	}
+	return added
+}
+
+// loggedRotator logs each rotation of a proxy's exit
+type loggedRotator struct {
+	proxy.Rotator
+	id     string
+	logger *logging.Logger
+}
+
+func (r loggedRotator) Rotate(ctx context.Context) error {
+	start := time.Now()
+	if err := r.Rotator.Rotate(ctx); err != nil {
+		r.logger.Warnf("Exit rotation of %s failed: %v", r.id, err)
+		return err
+	}
+	r.logger.Infof("Rotated exit of %s in %s", r.id, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"dorker/worker/internal/protocol"
+)
+
+// eofOptions holds what IPC mode does when the controller closes stdin
+type eofOptions struct {
+	action       protocol.EOFAction
+	addr         string        // Reattach socket; empty uses protocol.DefaultReattachAddr
+	drainTimeout time.Duration // Limit for EOFDrain
+}
+
+// listenReattach opens the socket a new controller attaches on
+func listenReattach(opts eofOptions) (net.Listener, string, error) {
+	addr := opts.addr
+	if addr == "" {
+		addr = protocol.DefaultReattachAddr()
+	}
+	listener, err := protocol.ListenController(addr)
+	if err != nil {
+		return nil, addr, fmt.Errorf("failed to listen for controllers on %s: %w", addr, err)
+	}
+	return listener, addr, nil
+}
+
+// ignoreBrokenPipe keeps a write to a stdout the controller no longer reads
+// from killing the worker before a drain finishes and the exports are written
+func ignoreBrokenPipe() {
+	signal.Ignore(syscall.SIGPIPE)
+}
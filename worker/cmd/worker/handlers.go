@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"dorker/worker/internal/daemon"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/snapshot"
+	"dorker/worker/internal/worker"
+)
+
+// registerHandlers wires the controller's messages to the session
+func (s *ipcSession) registerHandlers() {
+	s.handler.OnInit(s.onInit)
+	s.handler.OnImportSnapshot(s.onImportSnapshot)
+	s.handler.OnExportSnapshot(s.onExportSnapshot)
+	s.handler.OnTask(s.onTask)
+	s.handler.OnPause(s.onPause)
+	s.handler.OnResume(s.onResume)
+	s.handler.OnGetStats(s.onGetStats)
+	s.handler.OnCheckProxies(s.onCheckProxies)
+	s.handler.OnGetConfig(s.onGetConfig)
+	s.handler.OnGetLogs(func(limit int, level string) {
+		sendLogs(s.handler, s.logRing, limit, level)
+	})
+	s.handler.OnReattach(s.onReattach)
+	s.handler.OnShutdown(s.onShutdown)
+}
+
+// onInit starts the job
+func (s *ipcSession) onInit(config *protocol.InitConfig) {
+	// A reattached controller finds the job already running
+	if s.w != nil {
+		s.handler.SendError("already_initialized", "Worker already initialized")
+		return
+	}
+	s.startJob(config, nil)
+}
+
+// onImportSnapshot continues a job exported on another machine
+func (s *ipcSession) onImportSnapshot(path string) {
+	if s.w != nil {
+		s.handler.SendError("already_initialized", "Worker already initialized")
+		return
+	}
+	snap, err := snapshot.Read(path)
+	if err != nil {
+		s.handler.SendError("snapshot_failed", err.Error())
+		return
+	}
+	s.logger.Infof("Importing snapshot taken %s by v%s: %d tasks, %d proxies",
+		snap.Created.Format(time.RFC3339), snap.Version, len(snap.Tasks), len(snap.Proxies))
+	s.startJob(snapshotConfig(snap, s.profile), snap)
+}
+
+// onExportSnapshot stops the job and stores it so it can continue
+// elsewhere. In-flight tasks finish first, so no task runs on both
+// machines; the worker exits once the snapshot is written.
+func (s *ipcSession) onExportSnapshot(path string) {
+	if s.w == nil || s.proxyPool == nil {
+		s.handler.SendError("not_initialized", "Worker not initialized")
+		return
+	}
+	if path == "" {
+		s.handler.SendError("snapshot_failed", "export_snapshot requires a path")
+		return
+	}
+
+	s.w.Stop()
+	s.proxyPool.StopHealthCheck()
+	s.pending = append(s.pending, s.w.TakePending()...)
+
+	// The job stays stopped on failure, so the export can be retried
+	if err := writeSnapshot(path, s.jobConfig, s.w, s.proxyPool, s.urlSet, s.pending); err != nil {
+		s.handler.SendError("snapshot_failed", err.Error())
+		return
+	}
+	s.handler.SendStatus("snapshot_exported", fmt.Sprintf("%d pending tasks and %d proxies written to %s",
+		len(s.pending), s.proxyPool.Stats().Total, path))
+	s.terminate(protocol.ReasonShutdown, "snapshot exported to "+path)
+}
+
+// onTask submits a search or fetch task to the worker
+func (s *ipcSession) onTask(task *protocol.TaskData) {
+	if s.w == nil {
+		s.handler.SendError("not_initialized", "Worker not initialized")
+		return
+	}
+	if task.Type != string(worker.TaskTypeFetch) {
+		if err := s.sensitiveError(task.Dork); err != nil {
+			s.handler.SendError("sensitive_dork", err.Error())
+			return
+		}
+	}
+
+	err := s.w.Submit(&worker.Task{
+		ID:       task.ID,
+		Type:     worker.TaskType(task.Type),
+		Dork:     task.Dork,
+		URL:      task.URL,
+		Page:     task.Page,
+		Deadline: task.DeadlineFrom(time.Now()),
+
+		MinConfidence: task.MinConfidence,
+		Scope:         task.Scope,
+
+		DedupGranularity: dedup.Granularity(task.DedupGranularity),
+
+		TitleInclude: task.TitleInclude,
+		TitleExclude: task.TitleExclude,
+		Languages:    task.Languages,
+
+		IdempotencyKey: task.IdempotencyKey,
+	})
+
+	if err != nil {
+		s.handler.SendError("submit_failed", err.Error())
+	}
+}
+
+func (s *ipcSession) onPause() {
+	if s.w != nil {
+		s.w.Pause()
+	}
+}
+
+func (s *ipcSession) onResume() {
+	if s.w != nil {
+		if err := s.w.Start(); err != nil {
+			s.handler.SendError("resume_failed", err.Error())
+		}
+	}
+}
+
+func (s *ipcSession) onGetStats() {
+	if s.w == nil || s.proxyPool == nil {
+		s.handler.SendStats(&protocol.StatsData{})
+		return
+	}
+	s.handler.SendStats(statsData(s.w, s.proxyPool))
+}
+
+// onCheckProxies checks the given proxies, or all of them, in the
+// background and reports each one as it finishes
+func (s *ipcSession) onCheckProxies(proxyIDs []string) {
+	if s.proxyPool == nil {
+		s.handler.SendError("not_initialized", "Worker not initialized")
+		return
+	}
+
+	pool := s.proxyPool
+	targets := pool.GetAll()
+	if len(proxyIDs) > 0 {
+		targets = pool.GetByIDs(proxyIDs)
+	}
+
+	go func() {
+		checker := proxy.NewChecker(proxy.DefaultCheckerConfig())
+		checker.SetEgress(s.egressDialer)
+		alive := 0
+		checker.CheckAll(context.Background(), targets, func(r *proxy.CheckResult) {
+			if r.Alive {
+				alive++
+				pool.ReportSuccess(r.ProxyID, r.Latency)
+			} else {
+				pool.ReportFailure(r.ProxyID)
+			}
+
+			s.handler.SendProxyCheck(&protocol.ProxyCheckData{
+				ProxyID:   r.ProxyID,
+				Alive:     r.Alive,
+				LatencyMs: r.Latency.Milliseconds(),
+				Anonymity: r.Anonymity,
+				Country:   r.Country,
+				Error:     r.Error,
+			})
+		})
+		s.handler.SendStatus("proxy_check_complete", fmt.Sprintf("%d/%d proxies alive", alive, len(targets)))
+	}()
+}
+
+func (s *ipcSession) onGetConfig() {
+	if s.w == nil || s.proxyPool == nil {
+		// Before init, report what an init without settings would get
+		msg := protocol.ParseInitConfigWithProfile(protocol.NewMessage(protocol.MsgTypeInit), s.profile).ToMessage()
+		msg.SetData("initialized", false)
+		s.handler.Send(msg)
+		return
+	}
+	s.handler.Send(effectiveConfig(s.profile, s.proxyFile, s.w, s.proxyPool))
+}
+
+// onReattach brings a reattached controller up to date with the running
+// job
+func (s *ipcSession) onReattach() {
+	s.logger.Infof("Controller reattached")
+	if s.w != nil && s.proxyPool != nil {
+		s.handler.Send(effectiveConfig(s.profile, s.proxyFile, s.w, s.proxyPool))
+		s.handler.SendStats(statsData(s.w, s.proxyPool))
+	}
+}
+
+func (s *ipcSession) onShutdown() {
+	if s.w != nil {
+		s.w.Stop()
+	}
+	if s.proxyPool != nil {
+		s.proxyPool.StopHealthCheck()
+	}
+}
+
+// signalActions drains on SIGINT/SIGTERM, reloads --config and the proxy
+// file on SIGHUP and dumps stats on SIGUSR1
+func (s *ipcSession) signalActions() signalActions {
+	return signalActions{
+		shutdown: func(sig os.Signal) {
+			daemon.Notify(daemon.NotifyStopping)
+			s.handler.SendStatus("interrupted", fmt.Sprintf("Received %s, draining", sig))
+			if s.w != nil && !s.w.Drain(drainTimeout) {
+				s.logger.Warnf("Drain timed out with tasks still pending")
+			}
+			s.terminate(protocol.ReasonSignal, fmt.Sprintf("received %s", sig))
+		},
+		force: func(sig os.Signal) {
+			s.handler.SendTermination(protocol.ReasonSignal, fmt.Sprintf("received %s while draining", sig))
+			s.exit(protocol.ReasonSignal.ExitCode())
+		},
+		reload: s.reload,
+		dumpStats: func() {
+			dumpStats(s.w, s.proxyPool)
+		},
+	}
+}
+
+// reload applies --config to the running worker and reloads the proxy file
+func (s *ipcSession) reload() {
+	if s.w == nil || s.proxyPool == nil {
+		s.logger.Warnf("Reload ignored: worker not initialized")
+		return
+	}
+	daemon.Notify(daemon.NotifyReloading)
+	defer daemon.Notify(daemon.NotifyReady)
+	if s.configFile != "" {
+		fileConfig, err := protocol.LoadInitConfigFile(s.configFile, s.profile)
+		if err != nil {
+			s.logger.Errorf("Config reload failed: %v", err)
+		} else {
+			s.w.Reconfigure(workerConfigFrom(fileConfig))
+			if fileConfig.ProxyFile != "" {
+				s.proxyFile = fileConfig.ProxyFile
+			}
+			s.logger.Infof("Config reloaded")
+		}
+	}
+	if s.proxyFile != "" {
+		added, removed, errs := s.proxyPool.ReloadFromFile(s.proxyFile)
+		s.logger.Infof("Proxies reloaded: %d added, %d removed, %d errors", added, removed, len(errs))
+		stats := s.proxyPool.Stats()
+		s.handler.SendProxyInfo(stats.Alive, stats.Dead, stats.Quarantined)
+	}
+}
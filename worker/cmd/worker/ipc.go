@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"dorker/worker/internal/cache"
+	"dorker/worker/internal/daemon"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/logging"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/snapshot"
+	"dorker/worker/internal/verify"
+	"dorker/worker/internal/worker"
+)
+
+// poolWatchInterval is how often IPC mode checks for an exhausted proxy pool
+const poolWatchInterval = 5 * time.Second
+
+// ipcSession is IPC mode's state: the controller connection and the job
+// started by init or import_snapshot, nil until then
+type ipcSession struct {
+	*app
+	handler    *protocol.Handler
+	configFile string // --config, overriding init and reloaded on SIGHUP
+	profile    string
+	eof        eofOptions
+
+	w           *worker.Worker
+	proxyPool   *proxy.Pool
+	proxyFile   string
+	resultsDone chan struct{}
+
+	// Job state kept for export_snapshot: the init config in effect, the
+	// dedup set and the tasks taken out of the stopped worker
+	jobConfig protocol.InitConfig
+	urlSet    dedup.Set
+	pending   []*worker.Task
+
+	finishOnce sync.Once
+}
+
+// runIPCMode serves the controller on stdin/stdout until it shuts the
+// worker down or goes away, and returns the exit code
+func (a *app) runIPCMode(configFile, profile string, eof eofOptions) int {
+	handler := protocol.NewHandler()
+	handler.SetDefaultProfile(profile)
+	a.logger.AddSink(protocolSink(handler))
+
+	s := &ipcSession{
+		app:        a,
+		handler:    handler,
+		configFile: configFile,
+		profile:    profile,
+		eof:        eof,
+	}
+
+	switch eof.action {
+	case protocol.EOFDrain:
+		ignoreBrokenPipe()
+	case protocol.EOFReattach:
+		listener, addr, err := listenReattach(eof)
+		if err != nil {
+			a.logger.Errorf("%v", err)
+			return 1
+		}
+		a.reattachListener = listener
+		handler.ReattachOn(listener)
+		a.logger.Infof("A new controller can attach on %s if stdin closes", addr)
+	}
+
+	// Report panics as fatal instead of dying with a bare stack trace
+	defer func() {
+		if r := recover(); r != nil {
+			a.recordPanic()
+			s.terminate(protocol.ReasonPanic, fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	s.registerHandlers()
+	watchSignals(s.signalActions())
+
+	// Start handler; it returns on shutdown message or controller EOF
+	daemon.Notify(daemon.NotifyReady)
+	reason := handler.Start()
+	daemon.Notify(daemon.NotifyStopping)
+	if reason == protocol.ReasonControllerEOF && eof.action == protocol.EOFDrain && s.w != nil {
+		a.logger.Infof("Controller closed stdin, finishing %d queued tasks", s.w.TaskQueueLength())
+		if !s.w.Drain(eof.drainTimeout) {
+			a.logger.Warnf("Drain timed out with tasks still pending")
+		}
+		handler.SendStats(statsData(s.w, s.proxyPool))
+	}
+	s.finish(reason, "")
+
+	return reason.ExitCode()
+}
+
+// finish stops everything, flushes pending results and sends the final
+// done/fatal message once
+func (s *ipcSession) finish(reason protocol.ShutdownReason, message string) {
+	s.finishOnce.Do(func() {
+		// Dump before stopping so the goroutine stacks show the failure
+		var dumpPath string
+		if reason.IsFatal() {
+			dumpPath = s.writeCrashDump(reason, message, s.handler, s.profile, s.proxyFile, s.w, s.proxyPool)
+			if dumpPath != "" {
+				message += " (diagnostics: " + dumpPath + ")"
+			}
+		}
+
+		if s.w != nil {
+			s.w.Stop()
+		}
+		if s.resultsDone != nil {
+			select {
+			case <-s.resultsDone:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		if s.proxyPool != nil {
+			s.proxyPool.StopHealthCheck()
+		}
+		if reason.IsFatal() {
+			s.handler.SendFatalWithDump(reason, message, dumpPath)
+		} else {
+			s.handler.SendTermination(reason, message)
+		}
+	})
+}
+
+// terminate finishes and exits; used from goroutines that cannot return to
+// runIPCMode
+func (s *ipcSession) terminate(reason protocol.ShutdownReason, message string) {
+	s.finish(reason, message)
+	s.exit(reason.ExitCode())
+}
+
+// startJob sets up and starts the job for init, or for import_snapshot
+// with the snapshot's proxies, dedup set and tasks
+func (s *ipcSession) startJob(config *protocol.InitConfig, snap *snapshot.Snapshot) {
+	config, err := s.jobInitConfig(config)
+	if err != nil {
+		s.terminate(protocol.ReasonInitFailed, err.Error())
+		return
+	}
+	s.profile = config.Profile
+	s.proxyFile = config.ProxyFile
+	s.jobConfig = *config
+
+	s.proxyPool = s.newPool(config)
+	if len(config.Bind) > 0 {
+		s.logger.Infof("Bind: %d proxy groups bound to a source", len(config.Bind))
+	}
+	s.loadProxies(config, snap)
+
+	if summary, ran := s.runPreflight(s.proxyPool); ran {
+		s.handler.SendStatus("preflight_complete", fmt.Sprintf("%d passed, %d banned, %d failed",
+			summary.Passed, summary.Banned, summary.Failed))
+	}
+
+	// Send proxy info
+	stats := s.proxyPool.Stats()
+	s.handler.SendProxyInfo(stats.Alive, stats.Dead, stats.Quarantined)
+
+	if stats.Total == 0 {
+		s.terminate(protocol.ReasonInitFailed, "no valid proxies loaded")
+		return
+	}
+
+	s.w = s.newWorker(workerConfigFrom(config), s.proxyPool, func(alert worker.Alert) {
+		s.handler.SendAlert(alert.Event, alert.Message)
+	})
+
+	if snap != nil && snap.Dedup != nil {
+		s.urlSet, err = dedup.Import(s.w.Config().Dedup, bytes.NewReader(snap.Dedup))
+	} else {
+		s.urlSet, err = dedup.New(s.w.Config().Dedup)
+	}
+	if err != nil {
+		s.terminate(protocol.ReasonInitFailed, err.Error())
+		return
+	}
+	s.w.SetDedup(s.urlSet)
+
+	pageCache, err := cache.New(s.w.Config().Cache)
+	if err != nil {
+		s.terminate(protocol.ReasonInitFailed, err.Error())
+		return
+	}
+	s.w.SetCache(pageCache)
+
+	// Start result processor
+	s.resultsDone = make(chan struct{})
+	go s.guard(s.terminate, func() {
+		defer close(s.resultsDone)
+		processResults(s.handler, s.w)
+	})
+
+	// Start worker
+	s.w.Start()
+
+	// Start proxy pool health check
+	s.proxyPool.StartHealthCheck()
+	go s.guard(s.terminate, func() { watchPool(s.proxyPool, s.terminate) })
+	go s.guard(s.terminate, func() { watchRunEnd(s.handler, s.w, s.proxyPool, s.logger, s.terminate) })
+
+	s.handler.Send(effectiveConfig(s.profile, s.proxyFile, s.w, s.proxyPool))
+	s.handler.SendStatus("initialized", fmt.Sprintf("Worker initialized with %d workers", config.Workers))
+
+	if snap != nil {
+		s.resumeTasks(snap.Tasks)
+	}
+}
+
+// jobInitConfig merges --config over the init message and validates the
+// result
+func (s *ipcSession) jobInitConfig(config *protocol.InitConfig) (*protocol.InitConfig, error) {
+	// Settings from --config override the init message
+	if s.configFile != "" {
+		fileConfig, err := protocol.LoadInitConfigFile(s.configFile, config.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if fileConfig.ProxyFile == "" {
+			fileConfig.ProxyFile = config.ProxyFile
+		}
+		fileConfig.Proxies = config.Proxies
+		config = fileConfig
+	}
+	if _, err := protocol.LookupProfile(config.Profile); err != nil {
+		return nil, err
+	}
+	if err := config.Bind.Validate(); err != nil {
+		return nil, err
+	}
+	if err := (verify.Config{Patterns: config.VerifyPatterns}).Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Alerts.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadProxies fills the job's pool from the proxy file, the init list, the
+// provided proxies and a snapshot's proxies with their standing
+func (s *ipcSession) loadProxies(config *protocol.InitConfig, snap *snapshot.Snapshot) {
+	if config.ProxyFile != "" {
+		added, errs := s.proxyPool.LoadFromFile(config.ProxyFile)
+		s.logger.Infof("Loaded %d proxies from file", added)
+		for _, err := range errs {
+			s.logger.Warnf("Proxy load error: %v", err)
+		}
+	}
+
+	if len(config.Proxies) > 0 {
+		parser := proxy.NewParser()
+		for _, p := range config.Proxies {
+			prx, err := parser.ParseLine(p)
+			if err != nil {
+				s.logger.Warnf("Invalid proxy: %s", proxy.RedactCredentials(p))
+				continue
+			}
+			if prx != nil {
+				s.proxyPool.AddProxy(prx)
+			}
+		}
+	}
+
+	s.addProvidedProxies(s.proxyPool)
+
+	if snap != nil {
+		s.proxyPool.AddProxies(snap.Proxies)
+		s.proxyPool.RestoreState(snap.ProxyState)
+	}
+}
+
+// resumeTasks submits a snapshot's pending tasks, holding back sensitive
+// dorks as a task message would
+func (s *ipcSession) resumeTasks(tasks []*worker.Task) {
+	for _, task := range tasks {
+		if task.Type != worker.TaskTypeFetch {
+			if err := s.sensitiveError(task.Dork); err != nil {
+				s.logger.Warnf("Snapshot task %s not resumed: %v", task.ID, err)
+				continue
+			}
+		}
+		if err := s.w.Submit(task); err != nil {
+			s.logger.Warnf("Snapshot task %s not resumed: %v", task.ID, err)
+		}
+	}
+}
+
+// runPreflight tries every proxy against the engine when the pool has a
+// pre-flight URL, reporting whether it ran. Proxies the engine already bans
+// are quarantined before any dork is sent through them.
+func (a *app) runPreflight(pool *proxy.Pool) (proxy.PreflightSummary, bool) {
+	if pool.Config().PreflightURL == "" {
+		return proxy.PreflightSummary{}, false
+	}
+
+	checker := proxy.NewChecker(proxy.DefaultCheckerConfig())
+	checker.SetEgress(a.egressDialer)
+	summary := pool.Preflight(context.Background(), checker, func(r *proxy.PreflightResult) {
+		if r.Outcome == proxy.PreflightBanned {
+			a.logger.Warnf("Pre-flight: proxy %s is banned by the engine (%s), quarantined", r.ProxyID, r.Error)
+		}
+	})
+	return summary, true
+}
+
+// guard runs fn and converts a panic into a fatal termination
+func (a *app) guard(terminate func(protocol.ShutdownReason, string), fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.recordPanic()
+			terminate(protocol.ReasonPanic, fmt.Sprintf("panic: %v", r))
+		}
+	}()
+	fn()
+}
+
+// watchPool terminates the worker once no proxy can serve requests again
+func watchPool(pool *proxy.Pool, terminate func(protocol.ShutdownReason, string)) {
+	ticker := time.NewTicker(poolWatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if pool.Exhausted() {
+			terminate(protocol.ReasonPoolExhausted, "all proxies are dead")
+			return
+		}
+	}
+}
+
+// watchRunEnd ends the run once max_run_duration elapses or max_requests is
+// used up. Remaining tasks are flushed as timeout or skipped results and a
+// stats summary precedes the done message.
+func watchRunEnd(handler *protocol.Handler, w *worker.Worker, pool *proxy.Pool, logger *logging.Logger, terminate func(protocol.ShutdownReason, string)) {
+	<-w.Ended()
+
+	reason, limit := runEndReason(w)
+	logger.Warnf("%s reached, stopping remaining tasks", limit)
+	if !w.Drain(drainTimeout) {
+		logger.Warnf("Drain timed out with tasks still pending")
+	}
+
+	summary := statsData(w, pool)
+	handler.SendStats(summary)
+	terminate(reason, fmt.Sprintf("%s reached: %d completed, %d failed, %d URLs, %d requests",
+		limit, summary.TasksCompleted, summary.TasksFailed, summary.URLsFound, summary.Requests))
+}
+
+// runEndReason maps the cause of an ended run to a shutdown reason and a
+// description of the limit that was hit
+func runEndReason(w *worker.Worker) (protocol.ShutdownReason, string) {
+	config := w.Config()
+	if errors.Is(w.EndReason(), worker.ErrBudgetExhausted) {
+		return protocol.ReasonBudget, fmt.Sprintf("max_requests of %d", config.MaxRequests)
+	}
+	return protocol.ReasonDeadline, fmt.Sprintf("max_run_duration of %s", config.MaxRunDuration)
+}
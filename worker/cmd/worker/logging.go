@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dorker/worker/internal/logging"
+	"dorker/worker/internal/protocol"
+)
+
+// logOptions holds the logging flags
+type logOptions struct {
+	level      string
+	sinks      string // Comma-separated: stderr, syslog, file:<path>
+	maxSizeMB  int
+	maxBackups int
+	ringSize   int
+}
+
+// newLogger builds a logger from the flags. The ring buffer is always
+// attached so that get_logs works regardless of the configured sinks.
+// Nothing is ever written to stdout, which carries the IPC protocol.
+func newLogger(opts logOptions) (*logging.Logger, *logging.RingBuffer, error) {
+	level, err := logging.ParseLevel(opts.level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ring := logging.NewRingBuffer(opts.ringSize)
+	logger := logging.New(level, ring)
+
+	for _, spec := range strings.Split(opts.sinks, ",") {
+		spec = strings.TrimSpace(spec)
+		switch {
+		case spec == "":
+			continue
+		case spec == "stderr":
+			logger.AddSink(logging.NewWriterSink(os.Stderr))
+		case spec == "syslog":
+			sink, err := logging.NewSyslogSink("dorker-worker")
+			if err != nil {
+				logger.Close()
+				return nil, nil, err
+			}
+			logger.AddSink(sink)
+		case strings.HasPrefix(spec, "file:"):
+			sink, err := logging.NewFileSink(strings.TrimPrefix(spec, "file:"), int64(opts.maxSizeMB)*1024*1024, opts.maxBackups)
+			if err != nil {
+				logger.Close()
+				return nil, nil, err
+			}
+			logger.AddSink(sink)
+		default:
+			logger.Close()
+			return nil, nil, fmt.Errorf("unknown log sink: %s", spec)
+		}
+	}
+
+	return logger, ring, nil
+}
+
+// protocolSink forwards log entries to the controller as log messages
+func protocolSink(handler *protocol.Handler) logging.Sink {
+	return logging.FuncSink(func(entry logging.Entry) error {
+		return handler.SendLog(entry.Level.String(), entry.Message)
+	})
+}
+
+// sendLogs answers a get_logs request from the ring buffer
+func sendLogs(handler *protocol.Handler, ring *logging.RingBuffer, limit int, level string) {
+	minLevel, err := logging.ParseLevel(level)
+	if err != nil {
+		handler.SendError("invalid_level", err.Error())
+		return
+	}
+
+	entries := ring.Entries(limit, minLevel)
+	logs := &protocol.LogsData{Entries: make([]protocol.LogEntryData, 0, len(entries))}
+	for _, e := range entries {
+		logs.Entries = append(logs.Entries, protocol.LogEntryData{
+			Timestamp: e.Time.UnixMilli(),
+			Level:     e.Level.String(),
+			Message:   e.Message,
+		})
+	}
+	handler.SendLogs(logs)
+}
@@ -4,15 +4,21 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"dorker/worker/internal/audit"
+	"dorker/worker/internal/daemon"
+	"dorker/worker/internal/egress"
 	"dorker/worker/internal/engine"
+	"dorker/worker/internal/htmldump"
+	"dorker/worker/internal/output"
+	"dorker/worker/internal/pacing"
+	"dorker/worker/internal/params"
 	"dorker/worker/internal/protocol"
 	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/sshtunnel"
 	"dorker/worker/internal/stealth"
-	"dorker/worker/internal/worker"
+	"dorker/worker/internal/tor"
 )
 
 var (
@@ -20,428 +26,251 @@ var (
 	BuildTime = "unknown"
 )
 
+// subcommands run instead of the worker when named as the first argument;
+// each returns the exit code
+var subcommands = map[string]func(args []string) int{
+	"selftest": runSelfTest,
+	"bench":    runBench,
+	"decrypt":  runDecrypt,
+	"diff":     runDiff,
+	"merge":    runMerge,
+	"shard":    runShard,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	a := &app{hostOverrides: make(proxy.Hosts)}
+	var opts standaloneOptions
+
 	// Parse flags
 	showVersion := flag.Bool("version", false, "Show version")
 	standalone := flag.Bool("standalone", false, "Run in standalone mode")
-	dorkFile := flag.String("dorks", "", "Path to dorks file (standalone mode)")
-	proxyFile := flag.String("proxies", "", "Path to proxies file (standalone mode)")
-	outputDir := flag.String("output", "./output", "Output directory (standalone mode)")
-	workers := flag.Int("workers", 10, "Number of workers (standalone mode)")
+	flag.StringVar(&opts.dorkFile, "dorks", "", "Path to dorks file (standalone mode)")
+	flag.StringVar(&opts.proxyFile, "proxies", "", "Path to proxies file (standalone mode)")
+	flag.StringVar(&opts.outputDir, "output", "./output", "Output directory (standalone mode)")
+	flag.IntVar(&opts.workers, "workers", 10, "Number of workers (standalone mode)")
+	flag.StringVar(&opts.configFile, "config", "", "JSON config file using init keys; applied at startup and reloaded on SIGHUP")
+	pidFile := flag.String("pid-file", "", "Write the process ID to this file")
+	logFile := flag.String("log-file", "", "Append log output to this file")
+	serviceAction := flag.String("service", "", "Service control: install, uninstall or run")
+	serviceName := flag.String("service-name", "dorker-worker", "Name used by --service")
+	auditFile := flag.String("audit-log", "", "Append a JSONL record of every outgoing request to this file")
+	htmlDumpDir := flag.String("html-dump", "", "Write every fetched results page to this directory for debugging")
+	flag.StringVar(&a.crashDir, "crash-dir", "", "Directory receiving diagnostic bundles on fatal errors (default: system temp directory)")
+	pacingExport := flag.String("pacing-export", "", "At exit, write per-minute request, block and CAPTCHA counts per engine to this .csv or .json file")
+	paramsExport := flag.String("params-export", "", "At exit, write the query parameter names seen per domain to this .txt or .json file")
+	flag.StringVar(&a.proxiesExportPath, "proxies-export", "", "At exit, write the proxy list with its metadata to this .txt, .csv or .json file")
+	flag.StringVar(&opts.profile, "profile", "", "Tuning preset: stealth, balanced or aggressive (default balanced)")
+	egressProxy := flag.String("egress-proxy", egress.Environment, "Reach the proxies through: env (HTTPS_PROXY/HTTP_PROXY/NO_PROXY), direct, or an http(s):// proxy URL")
+	sshConfig := sshtunnel.DefaultConfig()
+	sshHosts := flag.String("ssh-hosts", "", "File of SSH servers (user@host[:port] per line) to use as SOCKS5 proxies through dynamic forwards")
+	flag.StringVar(&sshConfig.KeyFile, "ssh-key", "", "Private key for --ssh-hosts entries without their own (default: the SSH agent and ~/.ssh/id_*)")
+	flag.StringVar(&sshConfig.KnownHosts, "ssh-known-hosts", "", "known_hosts file checking --ssh-hosts host keys (default: ~/.ssh/known_hosts)")
+	hostsFile := flag.String("hosts-file", "", "Hosts file (address, then names) pinning host names for requests through the proxies")
+	flag.Func("host", "Pin a host name for requests through the proxies: host=ip[,ip] (repeatable)", func(value string) error {
+		hosts, err := proxy.ParseHostOverrides(value)
+		if err == nil {
+			a.hostOverrides = a.hostOverrides.Merge(hosts)
+		}
+		return err
+	})
+	vpnProxyLine := flag.String("vpn-proxy", "", "Proxy served by a VPN client whose exit --vpn-rotate changes when it is burned")
+	vpnRotate := flag.String("vpn-rotate", "", "Shell command, or [METHOD] http(s):// URL of the VPN client's API, giving --vpn-proxy a new exit")
+	vpnRotateInterval := flag.Duration("vpn-rotate-interval", time.Minute, "Least time between two --vpn-rotate runs")
+	useTor := flag.Bool("tor", false, "Search through the local Tor client, with new circuits when its exit is burned")
+	torConfig := tor.DefaultConfig()
+	flag.StringVar(&torConfig.SOCKSAddr, "tor-socks", torConfig.SOCKSAddr, "Tor SocksPort used by --tor")
+	flag.StringVar(&torConfig.ControlAddr, "tor-control", torConfig.ControlAddr, "Tor ControlPort rotating --tor circuits; empty never rotates them")
+	flag.StringVar(&torConfig.Password, "tor-password", "", "Tor control port password, ${NAME} read from the environment (default: the auth cookie)")
+	torRotateInterval := flag.Duration("tor-rotate-interval", 30*time.Second, "Least time between two --tor circuit rotations; Tor allows one per 10s")
+	flag.BoolVar(&a.allowSensitive, "allow-sensitive", false, "Search dorks tagged as looking for credentials, keys, personal data or database dumps")
+	flag.IntVar(&opts.output.Shards, "output-shards", 1, "Result writer shards and goroutines (standalone mode)")
+	outputOrder := flag.String("output-order", string(output.OrderStrict), "Result line order: strict, key (per dork) or none")
+	outputRotateMB := flag.Int("output-rotate-mb", 0, "Start a new results file after this many MB; 0 never rotates")
+	flag.BoolVar(&opts.ranked, "output-ranked", false, "Also write each dork's URLs by SERP position to ranked_<time>.tsv at exit (standalone mode)")
+	flag.BoolVar(&opts.httpx, "output-httpx", false, "Also write every URL once to httpx_<time>.txt at exit, an httpx -l target list (standalone mode)")
+	flag.BoolVar(&opts.nuclei, "output-nuclei", false, "Also write nuclei -l target lists, split by template tags from dork categories, to nuclei_<time>/ at exit (standalone mode)")
+	flag.StringVar(&opts.sink.Template, "sink-template", "", "Render each result batch through this Go template file (standalone mode)")
+	flag.StringVar(&opts.sink.Command, "sink-exec", "", "Shell command receiving each rendered batch on stdin")
+	flag.StringVar(&opts.sink.File, "sink-file", "", "File each rendered batch is appended to")
+	flag.DurationVar(&opts.sink.Timeout, "sink-timeout", 30*time.Second, "Time limit for one --sink-exec run")
+	resultKeyFile := flag.String("result-key-file", "", "Encrypt result files with the AES-256 key in this file (default: $DORKER_RESULT_KEY when set)")
+	var logOpts logOptions
+	flag.StringVar(&logOpts.level, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	flag.StringVar(&logOpts.sinks, "log-sinks", "", "Comma-separated log sinks: stderr, syslog, file:<path>")
+	flag.IntVar(&logOpts.maxSizeMB, "log-max-size", 50, "Rotate file sinks after this many MB")
+	flag.IntVar(&logOpts.maxBackups, "log-backups", 5, "Rotated log files to keep")
+	flag.IntVar(&logOpts.ringSize, "log-ring", 1000, "Log entries kept in memory for get_logs")
+	var eofOpts eofOptions
+	onEOF := flag.String("on-eof", string(protocol.EOFExit), "When the controller closes stdin: exit, drain (finish queued tasks first) or reattach (wait on --reattach-addr for a new controller)")
+	flag.StringVar(&eofOpts.addr, "reattach-addr", "", "Socket for --on-eof reattach: host:port or unix:<path> (default: dorker-worker-<pid>.sock in the system temp directory)")
+	flag.DurationVar(&eofOpts.drainTimeout, "eof-drain-timeout", 10*time.Minute, "Time limit for --on-eof drain")
 	flag.Parse()
+	opts.output.Ordering = output.Ordering(*outputOrder)
+	opts.output.MaxFileSize = int64(*outputRotateMB) << 20
 
 	if *showVersion {
 		fmt.Printf("Dorker Worker v%s (built: %s)\n", Version, BuildTime)
 		os.Exit(0)
 	}
 
-	// Check if running in IPC mode or standalone
-	stat, _ := os.Stdin.Stat()
-	isIPCMode := (stat.Mode()&os.ModeCharDevice) == 0 && !*standalone
-
-	if isIPCMode {
-		runIPCMode()
-	} else {
-		runStandaloneMode(*dorkFile, *proxyFile, *outputDir, *workers)
+	if _, err := protocol.LookupProfile(opts.profile); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		os.Exit(1)
 	}
-}
-
-func runIPCMode() {
-	// Create protocol handler
-	handler := protocol.NewHandler()
-
-	// Worker instance (created on init)
-	var w *worker.Worker
-	var proxyPool *proxy.Pool
-
-	// Handle init
-	handler.OnInit(func(config *protocol.InitConfig) {
-		// Create proxy pool
-		poolConfig := proxy.DefaultPoolConfig()
-		proxyPool = proxy.NewPool(poolConfig)
-
-		// Load proxies from file if provided
-		if config.ProxyFile != "" {
-			added, errs := proxyPool.LoadFromFile(config.ProxyFile)
-			handler.SendLog("info", fmt.Sprintf("Loaded %d proxies from file", added))
-			for _, err := range errs {
-				handler.SendLog("warn", fmt.Sprintf("Proxy load error: %v", err))
-			}
-		}
-
-		// Load proxies from list if provided
-		if len(config.Proxies) > 0 {
-			parser := proxy.NewParser()
-			for _, p := range config.Proxies {
-				prx, err := parser.ParseLine(p)
-				if err != nil {
-					handler.SendLog("warn", fmt.Sprintf("Invalid proxy: %s", p))
-					continue
-				}
-				if prx != nil {
-					proxyPool.AddProxy(prx)
-				}
-			}
-		}
-
-		// Send proxy info
-		stats := proxyPool.Stats()
-		handler.SendProxyInfo(stats.Alive, stats.Dead, stats.Quarantined)
-
-		// Create worker config
-		workerConfig := worker.DefaultConfig()
-		workerConfig.Workers = config.Workers
-		workerConfig.RequestTimeout = config.Timeout
-		workerConfig.BaseDelay = config.BaseDelay
-		workerConfig.MinDelay = config.MinDelay
-		workerConfig.MaxDelay = config.MaxDelay
-		workerConfig.MaxRetries = config.MaxRetries
-		workerConfig.ResultsPerPage = config.ResultsPerPage
-
-		// Create worker
-		w = worker.New(workerConfig, proxyPool)
-
-		// Start result processor
-		go processResults(handler, w)
-
-		// Start worker
-		w.Start()
-
-		// Start proxy pool health check
-		proxyPool.StartHealthCheck()
-
-		handler.SendStatus("initialized", fmt.Sprintf("Worker initialized with %d workers", config.Workers))
-	})
 
-	// Handle task
-	handler.OnTask(func(task *protocol.TaskData) {
-		if w == nil {
-			handler.SendError("not_initialized", "Worker not initialized")
-			return
-		}
-
-		err := w.Submit(&worker.Task{
-			ID:   task.ID,
-			Dork: task.Dork,
-			Page: task.Page,
-		})
-
-		if err != nil {
-			handler.SendError("submit_failed", err.Error())
-		}
-	})
-
-	// Handle pause
-	handler.OnPause(func() {
-		if w != nil {
-			w.Stop()
-		}
-	})
-
-	// Handle resume
-	handler.OnResume(func() {
-		if w != nil {
-			w.Start()
-		}
-	})
-
-	// Handle get stats
-	handler.OnGetStats(func() {
-		if w == nil || proxyPool == nil {
-			handler.SendStats(&protocol.StatsData{})
-			return
-		}
+	eofAction, err := protocol.ParseEOFAction(*onEOF)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		os.Exit(1)
+	}
+	eofOpts.action = eofAction
 
-		workerStats := w.Stats()
-		proxyStats := proxyPool.Stats()
+	if !opts.output.Ordering.Valid() {
+		fmt.Fprintf(os.Stderr, "✗ unknown --output-order %q (want strict, key or none)\n", *outputOrder)
+		os.Exit(1)
+	}
 
-		// Calculate ETA
-		var etaMs int64
-		if workerStats.RequestsPerSec > 0 {
-			remaining := workerStats.TasksTotal - workerStats.TasksCompleted - workerStats.TasksFailed
-			etaMs = int64(float64(remaining) / workerStats.RequestsPerSec * 1000)
-		}
+	if opts.output.Key, err = loadResultKey(*resultKeyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		os.Exit(1)
+	}
 
-		handler.SendStats(&protocol.StatsData{
-			TasksTotal:     workerStats.TasksTotal,
-			TasksCompleted: workerStats.TasksCompleted,
-			TasksFailed:    workerStats.TasksFailed,
-			TasksPending:   int64(w.TaskQueueLength()),
-			URLsFound:      workerStats.URLsFound,
-			CaptchaCount:   workerStats.CaptchaCount,
-			BlockCount:     workerStats.BlockCount,
-			ProxiesAlive:   proxyStats.Alive,
-			ProxiesDead:    proxyStats.Dead,
-			RequestsPerSec: workerStats.RequestsPerSec,
-			ElapsedMs:      workerStats.TotalDuration.Milliseconds(),
-			ETAMs:          etaMs,
-		})
-	})
+	if *serviceAction != "" && *serviceAction != serviceRun {
+		os.Exit(runServiceCommand(*serviceAction, *serviceName))
+	}
 
-	// Handle shutdown
-	handler.OnShutdown(func() {
-		if w != nil {
-			w.Stop()
-		}
-		if proxyPool != nil {
-			proxyPool.StopHealthCheck()
-		}
-	})
+	// Check if running in IPC mode or standalone. Services have no usable stdin.
+	isIPCMode := false
+	if stat, err := os.Stdin.Stat(); err == nil && *serviceAction == "" {
+		isIPCMode = (stat.Mode()&os.ModeCharDevice) == 0 && !*standalone
+	}
 
-	// Handle OS signals
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		handler.SendStatus("interrupted", "Received interrupt signal")
-		if w != nil {
-			w.Stop()
+	// In IPC mode stdout carries the protocol, so only stderr is redirected
+	if *logFile != "" {
+		if _, err := daemon.RedirectOutput(*logFile, !isIPCMode); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
-		os.Exit(0)
-	}()
+	}
 
-	// Start handler
-	handler.Start()
-}
+	if a.logger, a.logRing, err = newLogger(logOpts); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+		os.Exit(1)
+	}
 
-func processResults(handler *protocol.Handler, w *worker.Worker) {
-	for result := range w.Results() {
-		// Convert URLs to string slice
-		urls := make([]string, len(result.URLs))
-		for i, u := range result.URLs {
-			urls[i] = u.URL
+	if *auditFile != "" {
+		if a.auditLog, err = audit.Open(*auditFile); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
+	}
 
-		handler.SendResult(&protocol.ResultData{
-			TaskID:   result.TaskID,
-			Dork:     result.Dork,
-			URLs:     urls,
-			Status:   string(result.Status),
-			Error:    result.Error,
-			ProxyID:  result.ProxyID,
-			Duration: result.Duration.Milliseconds(),
-		})
-
-		// Send progress update every result
-		stats := w.Stats()
-		if stats.TasksTotal > 0 {
-			percentage := float64(stats.TasksCompleted+stats.TasksFailed) / float64(stats.TasksTotal) * 100
-			handler.SendProgress(&protocol.ProgressData{
-				Current:    stats.TasksCompleted + stats.TasksFailed,
-				Total:      stats.TasksTotal,
-				Percentage: percentage,
-			})
+	if *htmlDumpDir != "" {
+		if a.htmlDump, err = htmldump.Open(*htmlDumpDir); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
 	}
-}
 
-func runStandaloneMode(dorkFile, proxyFile, outputDir string, numWorkers int) {
-	printBanner()
-
-	if dorkFile == "" || proxyFile == "" {
-		fmt.Println("Usage: dorker-worker --standalone --dorks <file> --proxies <file> [options]")
-		fmt.Println()
-		fmt.Println("Options:")
-		fmt.Println("  --dorks     Path to dorks file (required)")
-		fmt.Println("  --proxies   Path to proxies file (required)")
-		fmt.Println("  --output    Output directory (default: ./output)")
-		fmt.Println("  --workers   Number of workers (default: 10)")
-		fmt.Println("  --version   Show version")
-		fmt.Println()
-		fmt.Println("Example:")
-		fmt.Println("  dorker-worker --standalone --dorks dorks.txt --proxies proxies.txt --workers 20")
-		fmt.Println()
+	if a.egressDialer, err = egress.New(*egressProxy); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %v\n", err)
 		os.Exit(1)
 	}
-
-	// Create proxy pool
-	fmt.Println("Loading proxies...")
-	poolConfig := proxy.DefaultPoolConfig()
-	proxyPool := proxy.NewPool(poolConfig)
-
-	added, errs := proxyPool.LoadFromFile(proxyFile)
-	fmt.Printf("✓ Loaded %d proxies\n", added)
-	if len(errs) > 0 {
-		fmt.Printf("⚠ %d proxy errors\n", len(errs))
+	if a.egressDialer != nil {
+		a.logger.Infof("Egress: proxies reached %s", a.egressDialer.Describe())
 	}
 
-	if added == 0 {
-		fmt.Println("✗ No valid proxies found")
-		os.Exit(1)
+	if *sshHosts != "" {
+		if err := a.startSSHTunnels(*sshHosts, sshConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Load dorks
-	fmt.Println("Loading dorks...")
-	dorks, err := loadDorks(dorkFile)
-	if err != nil {
-		fmt.Printf("✗ Failed to load dorks: %v\n", err)
-		os.Exit(1)
+	if *hostsFile != "" {
+		fileHosts, err := proxy.ReadHostsFile(*hostsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
+		}
+		// --host wins over the file
+		a.hostOverrides = fileHosts.Merge(a.hostOverrides)
 	}
-	fmt.Printf("✓ Loaded %d dorks\n", len(dorks))
-
-	// Create output directory
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		fmt.Printf("✗ Failed to create output directory: %v\n", err)
-		os.Exit(1)
+	if len(a.hostOverrides) > 0 {
+		a.logger.Infof("Hosts: %d host names pinned", len(a.hostOverrides))
 	}
 
-	// Create worker
-	workerConfig := worker.DefaultConfig()
-	workerConfig.Workers = numWorkers
-	w := worker.New(workerConfig, proxyPool)
-
-	// Start worker
-	fmt.Println()
-	fmt.Printf("Starting %d workers...\n", numWorkers)
-	w.Start()
-	proxyPool.StartHealthCheck()
-
-	// Create output file
-	outputFile, err := os.Create(fmt.Sprintf("%s/results_%d.txt", outputDir, time.Now().Unix()))
-	if err != nil {
-		fmt.Printf("✗ Failed to create output file: %v\n", err)
+	if (*vpnProxyLine == "") != (*vpnRotate == "") {
+		fmt.Fprintln(os.Stderr, "✗ --vpn-proxy and --vpn-rotate go together")
 		os.Exit(1)
 	}
-	defer outputFile.Close()
-
-	// Process results in background
-	done := make(chan struct{})
-	var urlCount int64
-	go func() {
-		for result := range w.Results() {
-			for _, u := range result.URLs {
-				outputFile.WriteString(u.URL + "\n")
-				urlCount++
-			}
+	if *vpnProxyLine != "" {
+		if err := a.addVPNExit(*vpnProxyLine, *vpnRotate, *vpnRotateInterval); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
-		close(done)
-	}()
-
-	// Submit dorks
-	fmt.Println("Processing dorks...")
-	fmt.Println()
-
-	for i, dork := range dorks {
-		w.Submit(&worker.Task{
-			ID:   fmt.Sprintf("task_%d", i),
-			Dork: dork,
-		})
 	}
 
-	// Wait for completion
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	for {
-		select {
-		case <-sigCh:
-			fmt.Println("\n\nInterrupted. Shutting down...")
-			w.Stop()
-			proxyPool.StopHealthCheck()
-			<-done
-			printFinalStats(w, urlCount, outputDir)
-			os.Exit(0)
-
-		case <-ticker.C:
-			stats := w.Stats()
-			proxyStats := proxyPool.Stats()
-
-			completed := stats.TasksCompleted + stats.TasksFailed
-			total := stats.TasksTotal
-			percentage := float64(completed) / float64(total) * 100
-
-			fmt.Printf("\r[%.1f%%] %d/%d dorks | %d URLs | %.1f req/s | Proxies: %d alive",
-				percentage, completed, total, urlCount, stats.RequestsPerSec, proxyStats.Alive)
-
-			if completed >= total {
-				fmt.Println()
-				w.Stop()
-				proxyPool.StopHealthCheck()
-				<-done
-				printFinalStats(w, urlCount, outputDir)
-				return
-			}
+	if *useTor {
+		if err := a.addTorExit(torConfig, *torRotateInterval); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
 	}
-}
 
-func loadDorks(filepath string) ([]string, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, err
+	if *pacingExport != "" {
+		a.pacingRecorder = pacing.NewRecorder(time.Minute)
+		a.pacingExportPath = *pacingExport
 	}
-	defer file.Close()
 
-	var dorks []string
-	var buf [4096]byte
-	var line []byte
+	if *paramsExport != "" {
+		a.paramHarvester = params.NewHarvester()
+		a.paramsExportPath = *paramsExport
+	}
 
-	for {
-		n, err := file.Read(buf[:])
-		if n == 0 {
-			if len(line) > 0 {
-				dorks = append(dorks, string(line))
-			}
-			break
+	if *pidFile != "" {
+		if err := daemon.WritePIDFile(*pidFile); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			os.Exit(1)
 		}
+		a.pidFilePath = *pidFile
+	}
 
-		for i := 0; i < n; i++ {
-			if buf[i] == '\n' {
-				if len(line) > 0 && line[0] != '#' {
-					dorks = append(dorks, string(line))
-				}
-				line = line[:0]
-			} else if buf[i] != '\r' {
-				line = append(line, buf[i])
-			}
+	if isIPCMode {
+		a.exit(a.runIPCMode(opts.configFile, opts.profile, eofOpts))
+	}
+
+	// An explicit --workers flag wins over the config file
+	workersSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "workers" {
+			workersSet = true
 		}
+	})
+	if !workersSet {
+		opts.workers = 0
+	}
 
+	if *serviceAction == serviceRun {
+		err := daemon.RunService(*serviceName, func(stop <-chan struct{}) {
+			a.serviceStop = stop
+			a.runStandalone(opts)
+		})
 		if err != nil {
-			break
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			a.exit(1)
 		}
+		a.exit(0)
 	}
 
-	return dorks, nil
-}
-
-func printBanner() {
-	fmt.Println("╔═══════════════════════════════════════════════════════════════════╗")
-	fmt.Println("║     ██████╗  ██████╗ ██████╗ ██╗  ██╗███████╗██████╗              ║")
-	fmt.Println("║     ██╔══██╗██╔═══██╗██╔══██╗██║ ██╔╝██╔════╝██╔══██╗             ║")
-	fmt.Println("║     ██║  ██║██║   ██║██████╔╝█████╔╝ █████╗  ██████╔╝             ║")
-	fmt.Println("║     ██║  ██║██║   ██║██╔══██╗██╔═██╗ ██╔══╝  ██╔══██╗             ║")
-	fmt.Println("║     ██████╔╝╚██████╔╝██║  ██║██║  ██╗███████╗██║  ██║             ║")
-	fmt.Println("║     ╚═════╝  ╚═════╝ ╚═╝  ╚═╝╚═╝  ╚═╝╚══════╝╚═╝  ╚═╝             ║")
-	fmt.Println("║                                                                   ║")
-	fmt.Printf("║                  Google Dork Parser v%-6s                       ║\n", Version)
-	fmt.Println("║                       Worker Engine                               ║")
-	fmt.Println("║                                                                   ║")
-	fmt.Println("╚═══════════════════════════════════════════════════════════════════╝")
-	fmt.Println()
-}
-
-func printFinalStats(w *worker.Worker, urlCount int64, outputDir string) {
-	stats := w.Stats()
-
-	fmt.Println()
-	fmt.Println("═══════════════════════════════════════════════════════════════════")
-	fmt.Println("                           COMPLETE")
-	fmt.Println("═══════════════════════════════════════════════════════════════════")
-	fmt.Println()
-	fmt.Printf("  Total Dorks:      %d\n", stats.TasksTotal)
-	fmt.Printf("  Completed:        %d\n", stats.TasksCompleted)
-	fmt.Printf("  Failed:           %d\n", stats.TasksFailed)
-	fmt.Printf("  URLs Found:       %d\n", urlCount)
-	fmt.Printf("  CAPTCHAs:         %d\n", stats.CaptchaCount)
-	fmt.Printf("  Blocks:           %d\n", stats.BlockCount)
-	fmt.Printf("  Duration:         %s\n", stats.TotalDuration.Round(time.Second))
-	fmt.Printf("  Avg Speed:        %.1f req/s\n", stats.RequestsPerSec)
-	fmt.Println()
-	fmt.Printf("  Results saved to: %s/\n", outputDir)
-	fmt.Println()
+	a.runStandalone(opts)
+	a.exit(0)
 }
 
 // Blank imports to ensure packages are included
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/runfile"
+)
+
+// runMerge runs `worker merge` and returns the exit code: 0 when every file
+// merged
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	outPath := fs.String("out", "", "Write the merged JSONL to this file (default: stdout)")
+	granularity := fs.String("dedup", "", "Dedup level: url, params, path or domain (default: dedup_granularity from --config or $DORKER_DEDUP_GRANULARITY, else url)")
+	configFile := fs.String("config", "", "JSON config file whose dedup_granularity applies")
+	statsPath := fs.String("stats", "", "Also write the aggregate stats to this JSON file")
+	inputs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(inputs) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: worker merge [--out <file>] [--dedup <level>] [--stats <file>] <result file>...")
+		return 2
+	}
+
+	g := dedup.Granularity(*granularity)
+	if g == "" {
+		config, err := loadCommandConfig(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "merge: %v\n", err)
+			return 2
+		}
+		g = dedup.Granularity(config.DedupGranularity)
+	}
+	if !g.Valid() {
+		fmt.Fprintf(os.Stderr, "merge: unknown dedup level %q (want url, params, path or domain)\n", g)
+		return 2
+	}
+
+	// Summaries go to stderr when the merged records take stdout
+	var out io.Writer = os.Stdout
+	report := io.Writer(os.Stdout)
+	if *outPath != "" {
+		for _, input := range inputs {
+			if sameFile(input, *outPath) {
+				fmt.Fprintf(os.Stderr, "merge: --out %s is also an input\n", *outPath)
+				return 2
+			}
+		}
+		f, err := os.Create(*outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "merge: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	} else {
+		report = os.Stderr
+	}
+
+	m := runfile.NewMerger(out, g)
+	code := 0
+	for _, input := range inputs {
+		if err := m.AddFile(input); err != nil {
+			fmt.Fprintf(os.Stderr, "merge: %v\n", err)
+			code = 1
+		}
+	}
+	if err := m.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "merge: %v\n", err)
+		return 2
+	}
+
+	stats := m.Stats()
+	stats.Write(report)
+	if *statsPath != "" {
+		data, _ := json.MarshalIndent(stats, "", "  ")
+		if err := os.WriteFile(*statsPath, append(data, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "merge: %v\n", err)
+			return 2
+		}
+	}
+	return code
+}
+
+// loadCommandConfig returns the settings a subcommand reads: the --config
+// file when given, else the defaults with the environment over them
+func loadCommandConfig(configFile string) (*protocol.InitConfig, error) {
+	if configFile != "" {
+		return protocol.LoadInitConfigFile(configFile, "")
+	}
+	return protocol.ParseInitConfigWithProfile(protocol.NewMessage(protocol.MsgTypeInit), ""), nil
+}
+
+// parseInterspersed parses fs's flags wherever they appear among args, so
+// `merge *.jsonl --out combined.jsonl` works, and returns the other args
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest, nil
+		}
+		if args[0] == "--" {
+			return append(rest, args[1:]...), nil
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// sameFile reports whether a and b name the same existing file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"dorker/worker/internal/asn"
+	"dorker/worker/internal/fingerprint"
+	"dorker/worker/internal/worker"
+)
+
+func printBanner() {
+	fmt.Println("╔═══════════════════════════════════════════════════════════════════╗")
+	fmt.Println("║     ██████╗  ██████╗ ██████╗ ██╗  ██╗███████╗██████╗              ║")
+	fmt.Println("║     ██╔══██╗██╔═══██╗██╔══██╗██║ ██╔╝██╔════╝██╔══██╗             ║")
+	fmt.Println("║     ██║  ██║██║   ██║██████╔╝█████╔╝ █████╗  ██████╔╝             ║")
+	fmt.Println("║     ██║  ██║██║   ██║██╔══██╗██╔═██╗ ██╔══╝  ██╔══██╗             ║")
+	fmt.Println("║     ██████╔╝╚██████╔╝██║  ██║██║  ██╗███████╗██║  ██║             ║")
+	fmt.Println("║     ╚═════╝  ╚═════╝ ╚═╝  ╚═╝╚═╝  ╚═╝╚══════╝╚═╝  ╚═╝             ║")
+	fmt.Println("║                                                                   ║")
+	fmt.Printf("║                  Google Dork Parser v%-6s                       ║\n", Version)
+	fmt.Println("║                       Worker Engine                               ║")
+	fmt.Println("║                                                                   ║")
+	fmt.Println("╚═══════════════════════════════════════════════════════════════════╝")
+	fmt.Println()
+}
+
+// printFinalStats prints the standalone run summary
+func (a *app) printFinalStats(w *worker.Worker, urlCount int64, outputDir string) {
+	stats := w.Stats()
+
+	fmt.Println()
+	fmt.Println("═══════════════════════════════════════════════════════════════════")
+	fmt.Println("                           COMPLETE")
+	fmt.Println("═══════════════════════════════════════════════════════════════════")
+	fmt.Println()
+	fmt.Printf("  Total Dorks:      %d\n", stats.TasksTotal)
+	fmt.Printf("  Completed:        %d\n", stats.TasksCompleted)
+	fmt.Printf("  Failed:           %d\n", stats.TasksFailed)
+	fmt.Printf("  URLs Found:       %d\n", urlCount)
+	if stats.DuplicateURLs > 0 {
+		fmt.Printf("  Duplicates:       %d dropped\n", stats.DuplicateURLs)
+	}
+	if stats.ResolvedURLs > 0 {
+		fmt.Printf("  Redirects:        %d links resolved\n", stats.ResolvedURLs)
+	}
+	if stats.ListingFiles > 0 {
+		fmt.Printf("  Listing files:    %d found\n", stats.ListingFiles)
+	}
+	if stats.VerifiedURLs > 0 || stats.UnverifiedURLs > 0 {
+		fmt.Printf("  Verified:         %d confirmed, %d dropped\n", stats.VerifiedURLs, stats.UnverifiedURLs)
+	}
+	if stats.HostFingerprints > 0 {
+		printHostClusters(stats.HostFingerprints, w.HostClusters())
+	}
+	if stats.AlertFindings > 0 {
+		fmt.Printf("  Alert findings:   %d\n", stats.AlertFindings)
+	}
+	if stats.ResolvedHosts > 0 {
+		printNetworkGroups(stats.ResolvedHosts, w.NetworkGroups())
+	}
+	if stats.CacheHits > 0 {
+		fmt.Printf("  Cache hits:       %d tasks\n", stats.CacheHits)
+	}
+	fmt.Printf("  CAPTCHAs:         %d\n", stats.CaptchaCount)
+	fmt.Printf("  Blocks:           %d\n", stats.BlockCount)
+	fmt.Printf("  Duration:         %s\n", stats.TotalDuration.Round(time.Second))
+	fmt.Printf("  Avg Speed:        %.1f req/s\n", stats.RequestsPerSec)
+	if summary := w.CostSummary(); summary.Total > 0 {
+		fmt.Printf("  Est. Cost:        %.2f (%.1f MB transferred)\n", summary.Total, float64(summary.Bytes)/(1<<20))
+		printCostBreakdown("Proxy group", summary.Proxies)
+		printCostBreakdown("Engine", summary.Engines)
+	}
+	if a.paramHarvester != nil {
+		fmt.Printf("  Parameters:       %d domains, written to %s at exit\n", a.paramHarvester.Len(), a.paramsExportPath)
+	}
+	fmt.Println()
+	fmt.Printf("  Results saved to: %s/\n", outputDir)
+	fmt.Println()
+}
+
+// maxClustersShown bounds the host clusters the final report lists
+const maxClustersShown = 10
+
+// printHostClusters prints the favicon hashes shared by several hosts,
+// largest cluster first, so hosts running the same application stand out
+func printHostClusters(hosts int64, clusters []fingerprint.Cluster) {
+	fmt.Printf("  Fingerprints:     %d hosts, %d favicon hashes\n", hosts, len(clusters))
+	for i, cluster := range clusters {
+		if i == maxClustersShown || len(cluster.Hosts) < 2 {
+			break
+		}
+		title := cluster.Title
+		if title == "" {
+			title = cluster.Hosts[0]
+		}
+		fmt.Printf("    %-12d %4d hosts  %s\n", cluster.FaviconHash, len(cluster.Hosts), title)
+	}
+}
+
+// maxNetblocksShown bounds the netblocks the final report lists per AS
+const maxNetblocksShown = 3
+
+// printNetworkGroups prints the ASes announcing the result hosts, most
+// hosts first, with their largest netblocks, so infrastructure of one
+// organization shows up together
+func printNetworkGroups(hosts int64, groups []asn.Group) {
+	fmt.Printf("  Networks:         %d hosts, %d ASes\n", hosts, len(groups))
+	for i, group := range groups {
+		if i == maxClustersShown {
+			break
+		}
+		fmt.Printf("    AS%-10d %4d hosts  %s\n", group.ASN, group.Hosts, group.Name)
+		for j, block := range group.Netblocks {
+			if j == maxNetblocksShown {
+				break
+			}
+			fmt.Printf("      %-20s %4d hosts\n", block.Prefix, len(block.Hosts))
+		}
+	}
+}
+
+// printCostBreakdown prints spend per proxy group or engine, largest first
+func printCostBreakdown(label string, amounts map[string]float64) {
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return amounts[names[i]] > amounts[names[j]] })
+
+	for _, name := range names {
+		fmt.Printf("    %-14s  %-24s %.2f\n", label, name, amounts[name])
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/base64"
+
+	"dorker/worker/internal/engine"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/worker"
+)
+
+// statsData builds a stats message from the worker and pool counters
+func statsData(w *worker.Worker, pool *proxy.Pool) *protocol.StatsData {
+	workerStats := w.Stats()
+	proxyStats := pool.Stats()
+
+	// Calculate ETA
+	var etaMs int64
+	if workerStats.RequestsPerSec > 0 {
+		remaining := workerStats.TasksTotal - workerStats.TasksCompleted - workerStats.TasksFailed
+		etaMs = int64(float64(remaining) / workerStats.RequestsPerSec * 1000)
+	}
+
+	return &protocol.StatsData{
+		TasksTotal:        workerStats.TasksTotal,
+		TasksCompleted:    workerStats.TasksCompleted,
+		TasksFailed:       workerStats.TasksFailed,
+		TasksPending:      int64(w.TaskQueueLength()),
+		URLsFound:         workerStats.URLsFound,
+		CaptchaCount:      workerStats.CaptchaCount,
+		BlockCount:        workerStats.BlockCount,
+		Requests:          workerStats.Requests,
+		EstimatedCost:     workerStats.EstimatedCost,
+		DuplicateURLs:     workerStats.DuplicateURLs,
+		ClassifiedPages:   workerStats.ClassifiedPages,
+		LayoutChanges:     workerStats.LayoutChanges,
+		LowConfidenceURLs: workerStats.LowConfidenceURLs,
+		OutOfScopeURLs:    workerStats.OutOfScopeURLs,
+		ResolvedURLs:      workerStats.ResolvedURLs,
+		ListingFiles:      workerStats.ListingFiles,
+		VerifiedURLs:      workerStats.VerifiedURLs,
+		UnverifiedURLs:    workerStats.UnverifiedURLs,
+		HostFingerprints:  workerStats.HostFingerprints,
+		ResolvedHosts:     workerStats.ResolvedHosts,
+		AlertFindings:     workerStats.AlertFindings,
+		TitleFilteredURLs: workerStats.TitleFilteredURLs,
+		OffLanguageURLs:   workerStats.OffLanguageURLs,
+		CacheHits:         workerStats.CacheHits,
+		DuplicateTasks:    workerStats.DuplicateTasks,
+		ProxiesAlive:      proxyStats.Alive,
+		ProxiesDead:       proxyStats.Dead,
+		ProxiesProbation:  proxyStats.Probation,
+		RequestsPerSec:    workerStats.RequestsPerSec,
+		ElapsedMs:         workerStats.TotalDuration.Milliseconds(),
+		ETAMs:             etaMs,
+	}
+}
+
+// processResults sends each worker result to the controller, followed by
+// a progress update
+func processResults(handler *protocol.Handler, w *worker.Worker) {
+	for result := range w.Results() {
+		// Convert URLs to string slice
+		urls := make([]string, len(result.URLs))
+		confidence := make([]float64, len(result.URLs))
+		types := make([]string, len(result.URLs))
+		positions := make([]int, len(result.URLs))
+		var titles, descriptions, displayURLs, languages, finalURLs []string
+		var files [][]string
+		var matches [][]engine.Match
+		var fingerprints []*engine.Fingerprint
+		var networks []*engine.Network
+		for i, u := range result.URLs {
+			urls[i] = u.URL
+			confidence[i] = u.Confidence
+			types[i] = u.Type
+			positions[i] = u.Position
+			if u.Title != "" {
+				if titles == nil {
+					titles = make([]string, len(result.URLs))
+				}
+				titles[i] = u.Title
+			}
+			if u.Description != "" {
+				if descriptions == nil {
+					descriptions = make([]string, len(result.URLs))
+				}
+				descriptions[i] = u.Description
+			}
+			if u.DisplayURL != "" {
+				if displayURLs == nil {
+					displayURLs = make([]string, len(result.URLs))
+				}
+				displayURLs[i] = u.DisplayURL
+			}
+			if u.Language != "" {
+				if languages == nil {
+					languages = make([]string, len(result.URLs))
+				}
+				languages[i] = u.Language
+			}
+			if u.FinalURL != "" {
+				if finalURLs == nil {
+					finalURLs = make([]string, len(result.URLs))
+				}
+				finalURLs[i] = u.FinalURL
+			}
+			if len(u.Files) > 0 {
+				if files == nil {
+					files = make([][]string, len(result.URLs))
+				}
+				files[i] = u.Files
+			}
+			if len(u.Matches) > 0 {
+				if matches == nil {
+					matches = make([][]engine.Match, len(result.URLs))
+				}
+				matches[i] = u.Matches
+			}
+			if u.Fingerprint != nil {
+				if fingerprints == nil {
+					fingerprints = make([]*engine.Fingerprint, len(result.URLs))
+				}
+				fingerprints[i] = u.Fingerprint
+			}
+			if u.Network != nil {
+				if networks == nil {
+					networks = make([]*engine.Network, len(result.URLs))
+				}
+				networks[i] = u.Network
+			}
+		}
+
+		var body string
+		if len(result.Body) > 0 {
+			body = base64.StdEncoding.EncodeToString(result.Body)
+		}
+
+		handler.SendResult(&protocol.ResultData{
+			TaskID:       result.TaskID,
+			Dork:         result.Dork,
+			URLs:         urls,
+			Confidence:   confidence,
+			Types:        types,
+			Positions:    positions,
+			Titles:       titles,
+			Descriptions: descriptions,
+			DisplayURLs:  displayURLs,
+			Languages:    languages,
+			FinalURLs:    finalURLs,
+			Files:        files,
+			Matches:      matches,
+			Fingerprints: fingerprints,
+			Networks:     networks,
+			OutOfScope:   result.OutOfScope,
+			Cached:       result.Cached,
+			Duplicate:    result.Duplicate,
+			Trace:        result.Trace,
+			Status:       string(result.Status),
+			Error:        result.Error,
+			ProxyID:      result.ProxyID,
+			Duration:     result.Duration.Milliseconds(),
+			URL:          result.URL,
+			StatusCode:   result.StatusCode,
+			Body:         body,
+		})
+
+		// Send progress update every result
+		stats := w.Stats()
+		if stats.TasksTotal > 0 {
+			percentage := float64(stats.TasksCompleted+stats.TasksFailed) / float64(stats.TasksTotal) * 100
+			handler.SendProgress(&protocol.ProgressData{
+				Current:       stats.TasksCompleted + stats.TasksFailed,
+				Total:         stats.TasksTotal,
+				Percentage:    percentage,
+				EstimatedCost: stats.EstimatedCost,
+			})
+		}
+	}
+}
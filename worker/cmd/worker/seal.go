@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"dorker/worker/internal/seal"
+)
+
+// resultKeyEnv holds the result encryption key when --result-key-file is
+// not given
+const resultKeyEnv = "DORKER_RESULT_KEY"
+
+// loadResultKey returns the result encryption key, nil when none is set
+func loadResultKey(path string) ([]byte, error) {
+	key, err := seal.LoadKey(os.Getenv(resultKeyEnv), path)
+	if err != nil {
+		return nil, fmt.Errorf("result key: %w", err)
+	}
+	return key, nil
+}
+
+// runDecrypt runs `worker decrypt` and returns the exit code: 0 when every
+// file opened whole
+func runDecrypt(args []string) int {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "File holding the key (default: $"+resultKeyEnv+")")
+	outPath := fs.String("o", "", "Write the plaintext to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: worker decrypt [--key-file <file>] [-o <file>] <sealed file>...")
+		return 2
+	}
+
+	key, err := loadResultKey(*keyFile)
+	if err == nil && key == nil {
+		err = fmt.Errorf("no key: set $%s or --key-file", resultKeyEnv)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
+		return 2
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "decrypt: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+
+	code := 0
+	for _, path := range fs.Args() {
+		if err := decryptFile(out, path, key); err != nil {
+			fmt.Fprintf(os.Stderr, "decrypt: %s: %v\n", path, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// decryptFile copies the plaintext of a sealed file to out. Records before
+// a cut are authentic and still written, as a crashed run leaves its file
+// without the last record.
+func decryptFile(out io.Writer, path string, key []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := seal.NewReader(f, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		if errors.Is(err, seal.ErrTruncated) {
+			return fmt.Errorf("%w; the records before the cut were written", err)
+		}
+		return err
+	}
+	return nil
+}
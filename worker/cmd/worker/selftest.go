@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/selftest"
+)
+
+// runSelfTest runs `worker selftest` and returns the exit code: 0 when
+// every check passed
+func runSelfTest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	probe := fs.String("probe", "", "Check this proxy live against the judge URL (any supported proxy format)")
+	timeout := fs.Duration("timeout", 30*time.Second, "Time limit for the whole self-test")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	fmt.Printf("Dorker Worker v%s self-test\n\n", Version)
+	report := selftest.Run(ctx, selftest.Options{
+		ProbeProxy: *probe,
+		Checker:    proxy.DefaultCheckerConfig(),
+	})
+	report.Write(os.Stdout)
+
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
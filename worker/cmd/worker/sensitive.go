@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"dorker/worker/internal/dorklist"
+)
+
+// sensitiveError returns why a search dork is held back, or nil when it may
+// run
+func (a *app) sensitiveError(dork string) error {
+	if a.allowSensitive {
+		return nil
+	}
+	categories := dorklist.Sensitive(dork)
+	if len(categories) == 0 {
+		return nil
+	}
+	return fmt.Errorf("dork %q looks for %s; start the worker with --allow-sensitive to search it",
+		dork, strings.Join(categories, ", "))
+}
+
+// withholdSensitive splits a standalone dork list into the dorks that may
+// run and the ones held back, printing the latter
+func (a *app) withholdSensitive(dorks []string) []string {
+	if a.allowSensitive {
+		return dorks
+	}
+
+	kept := dorks[:0:0]
+	var withheld int
+	for _, dork := range dorks {
+		categories := dorklist.Sensitive(dork)
+		if len(categories) == 0 {
+			kept = append(kept, dork)
+			continue
+		}
+		if withheld == 0 {
+			fmt.Println("⚠ Sensitive dorks held back (run with --allow-sensitive to search them):")
+		}
+		withheld++
+		fmt.Printf("    %s  [%s]\n", dork, strings.Join(categories, ", "))
+	}
+	return kept
+}
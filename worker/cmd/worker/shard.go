@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dorker/worker/internal/dorklist"
+	"dorker/worker/internal/runfile"
+)
+
+// shardManifest describes a split dork list, written next to the shards
+type shardManifest struct {
+	Source         string               `json:"source"`
+	Created        int64                `json:"created"`
+	Dorks          int                  `json:"dorks"`  // Dorks split, after merging duplicates
+	Merged         int                  `json:"merged"` // Duplicate entries dropped
+	Pages          int                  `json:"estimated_pages"`
+	PagesPerDork   int                  `json:"pages_per_dork"`
+	ResultsPerPage int                  `json:"results_per_page"`
+	History        []string             `json:"history,omitempty"`
+	Shards         []shardManifestEntry `json:"shards"`
+}
+
+// shardManifestEntry is one shard file of a manifest
+type shardManifestEntry struct {
+	File  string `json:"file"`
+	Dorks int    `json:"dorks"`
+	Pages int    `json:"estimated_pages"`
+}
+
+// historyFlag collects the --history files, which may be given repeatedly
+type historyFlag []string
+
+func (h *historyFlag) String() string { return strings.Join(*h, ",") }
+
+func (h *historyFlag) Set(path string) error {
+	*h = append(*h, path)
+	return nil
+}
+
+// runShard runs `worker shard` and returns the exit code: 0 when the shards
+// and manifest were written
+func runShard(args []string) int {
+	fs := flag.NewFlagSet("shard", flag.ContinueOnError)
+	dorkFile := fs.String("dorks", "", "Dork list to split (required)")
+	n := fs.Int("n", 0, "Number of shards (required)")
+	outDir := fs.String("out", ".", "Directory receiving the shard files and manifest")
+	pages := fs.Int("pages", 1, "Pages searched per dork")
+	configFile := fs.String("config", "", "JSON config file whose results_per_page applies to --history")
+	var history historyFlag
+	fs.Var(&history, "history", "Result file of an earlier run, for each dork's yield (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *dorkFile == "" || *n < 1 || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: worker shard --dorks <file> --n <shards> [--out <dir>] [--pages <n>] [--history <result file>]...")
+		return 2
+	}
+
+	config, err := loadCommandConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+		return 2
+	}
+
+	all, err := loadDorks(*dorkFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+		return 2
+	}
+	dorks, merged := dorklist.Merge(all)
+	if len(dorks) == 0 {
+		fmt.Fprintf(os.Stderr, "shard: %s has no dorks\n", *dorkFile)
+		return 2
+	}
+
+	estimator := dorklist.Estimator{Pages: *pages, ResultsPerPage: config.ResultsPerPage}
+	if len(history) > 0 {
+		if estimator.Yield, err = loadYield(history); err != nil {
+			fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+			return 2
+		}
+	}
+	shards := dorklist.Split(dorks, *n, estimator.Estimate)
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+		return 2
+	}
+	manifest := shardManifest{
+		Source:         *dorkFile,
+		Created:        time.Now().Unix(),
+		Dorks:          len(dorks),
+		Merged:         len(merged),
+		PagesPerDork:   max(*pages, 1),
+		ResultsPerPage: config.ResultsPerPage,
+		History:        history,
+	}
+
+	// Shards are named after the list, numbered to sort in order
+	base := strings.TrimSuffix(filepath.Base(*dorkFile), filepath.Ext(*dorkFile))
+	width := len(fmt.Sprint(len(shards)))
+	for i, shard := range shards {
+		name := fmt.Sprintf("%s_%0*d.txt", base, width, i+1)
+		data := strings.Join(shard.Dorks, "\n")
+		if data != "" {
+			data += "\n"
+		}
+		if err := os.WriteFile(filepath.Join(*outDir, name), []byte(data), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+			return 2
+		}
+		manifest.Shards = append(manifest.Shards, shardManifestEntry{File: name, Dorks: len(shard.Dorks), Pages: shard.Pages})
+		manifest.Pages += shard.Pages
+	}
+
+	manifestPath := filepath.Join(*outDir, base+"_manifest.json")
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "shard: %v\n", err)
+		return 2
+	}
+
+	for _, entry := range manifest.Shards {
+		fmt.Printf("  %-30s %6d dorks %8d pages\n", entry.File, entry.Dorks, entry.Pages)
+	}
+	fmt.Printf("\n%d dorks (%d duplicates merged), %d estimated pages; manifest %s\n",
+		manifest.Dorks, manifest.Merged, manifest.Pages, manifestPath)
+	return 0
+}
+
+// loadYield counts the distinct URLs each dork found in earlier result
+// files, keyed by its normalized form. Records without a dork, as in plain
+// URL lists, say nothing about yield and are skipped.
+func loadYield(paths []string) (map[string]int, error) {
+	found := make(map[string]map[string]bool)
+	for _, path := range paths {
+		err := runfile.ReadFile(path, func(r runfile.Record) error {
+			if r.Dork == "" {
+				return nil
+			}
+			key := dorklist.Normalize(r.Dork)
+			if found[key] == nil {
+				found[key] = make(map[string]bool)
+			}
+			found[key][r.URL] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	yield := make(map[string]int, len(found))
+	for key, urls := range found {
+		yield[key] = len(urls)
+	}
+	return yield, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/worker"
+)
+
+// drainTimeout bounds how long a shutdown signal waits for in-flight tasks
+const drainTimeout = 30 * time.Second
+
+// signalActions holds the callbacks run for each class of OS signal
+type signalActions struct {
+	shutdown  func(sig os.Signal) // First SIGINT/SIGTERM: drain and exit
+	force     func(sig os.Signal) // Repeated SIGINT/SIGTERM while draining
+	reload    func()              // SIGHUP
+	dumpStats func()              // SIGUSR1
+}
+
+// watchSignals dispatches OS signals to the given actions in the background
+func watchSignals(actions signalActions) {
+	sigCh := make(chan os.Signal, 1)
+	signals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	signals = append(signals, reloadSignals...)
+	signals = append(signals, statsSignals...)
+	signal.Notify(sigCh, signals...)
+
+	go func() {
+		shuttingDown := false
+		for sig := range sigCh {
+			switch {
+			case isSignal(sig, reloadSignals):
+				if actions.reload != nil {
+					actions.reload()
+				}
+			case isSignal(sig, statsSignals):
+				if actions.dumpStats != nil {
+					actions.dumpStats()
+				}
+			case shuttingDown:
+				if actions.force != nil {
+					actions.force(sig)
+				}
+			default:
+				shuttingDown = true
+				go actions.shutdown(sig)
+			}
+		}
+	}()
+}
+
+// isSignal reports whether sig is one of the given signals
+func isSignal(sig os.Signal, signals []os.Signal) bool {
+	for _, s := range signals {
+		if sig == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dumpStats writes worker and proxy pool statistics to stderr as JSON
+func dumpStats(w *worker.Worker, pool *proxy.Pool) {
+	dump := map[string]any{"ts": time.Now().UnixMilli()}
+	if w != nil {
+		dump["worker"] = w.Stats()
+		dump["tasks_pending"] = w.TaskQueueLength()
+		dump["parse_pending"] = w.ParseQueueLength()
+		dump["enrich_pending"] = w.EnrichQueueLength()
+	}
+	if pool != nil {
+		dump["proxies"] = pool.Stats()
+	}
+
+	data, err := json.Marshal(dump)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode stats: %v\n", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(data))
+}
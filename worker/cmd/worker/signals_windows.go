@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// Windows has no SIGHUP/SIGUSR1 equivalents for console processes
+var (
+	reloadSignals []os.Signal
+	statsSignals  []os.Signal
+)
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"time"
+
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/snapshot"
+	"dorker/worker/internal/worker"
+)
+
+// writeSnapshot stores a stopped job in a snapshot archive at path: its
+// init config with the effective seed, the tasks still pending, the proxies
+// with their standing and the dedup set
+func writeSnapshot(path string, config protocol.InitConfig, w *worker.Worker, pool *proxy.Pool, urlSet dedup.Set, pending []*worker.Task) error {
+	// Proxies travel in the archive, not as init keys
+	config.Proxies = nil
+	config.ProxyFile = ""
+	config.Seed = w.Config().Seed
+
+	snap := &snapshot.Snapshot{
+		Version:    Version,
+		Created:    time.Now(),
+		Config:     config.ToMessage().Data,
+		Tasks:      pending,
+		Proxies:    pool.GetAll(),
+		ProxyState: pool.State(),
+		Stats:      w.Stats(),
+	}
+	if urlSet != nil {
+		var buf bytes.Buffer
+		if err := dedup.Export(urlSet, &buf); err != nil {
+			return err
+		}
+		snap.Dedup = buf.Bytes()
+	}
+	return snapshot.Write(path, snap)
+}
+
+// snapshotConfig returns the init config a snapshot was taken with
+func snapshotConfig(snap *snapshot.Snapshot, profile string) *protocol.InitConfig {
+	msg := protocol.NewMessage(protocol.MsgTypeInit)
+	for key, value := range snap.Config {
+		msg.SetData(key, value)
+	}
+	return protocol.ParseInitConfigWithProfile(msg, profile)
+}
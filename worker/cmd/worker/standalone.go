@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dorker/worker/internal/cache"
+	"dorker/worker/internal/daemon"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/dorklist"
+	"dorker/worker/internal/output"
+	"dorker/worker/internal/protocol"
+	"dorker/worker/internal/sink"
+	"dorker/worker/internal/verify"
+	"dorker/worker/internal/worker"
+)
+
+// standaloneOptions holds the command line of a standalone run
+type standaloneOptions struct {
+	dorkFile   string
+	proxyFile  string
+	outputDir  string
+	workers    int // 0 takes the config's
+	configFile string
+	profile    string
+
+	output output.ShardedConfig
+	ranked bool // Also write ranked_<time>.tsv
+	httpx  bool // Also write httpx_<time>.txt
+	nuclei bool // Also write nuclei_<time>/
+	sink   sink.Config
+}
+
+// runStandalone searches a dork file and writes the results to the output
+// directory, printing progress to the terminal
+func (a *app) runStandalone(opts standaloneOptions) {
+	printBanner()
+	profile := opts.profile
+
+	if opts.dorkFile == "" || (opts.proxyFile == "" && a.sshTunnels == nil && len(a.rotatingExits) == 0) {
+		fmt.Println("Usage: dorker-worker --standalone --dorks <file> --proxies <file> [options]")
+		fmt.Println()
+		fmt.Println("Options:")
+		fmt.Println("  --dorks     Path to dorks file (required)")
+		fmt.Println("  --proxies   Path to proxies file (required without --ssh-hosts, --vpn-proxy or --tor)")
+		fmt.Println("  --output    Output directory (default: ./output)")
+		fmt.Println("  --workers   Number of workers (default: 10)")
+		fmt.Println("  --config    JSON config file (reloaded on SIGHUP)")
+		fmt.Println("  --profile   stealth, balanced or aggressive (default: balanced)")
+		fmt.Println("  --allow-sensitive   Search dorks after credentials, keys or personal data")
+		fmt.Println("  --egress-proxy      env, direct or http(s):// proxy in front of the proxies (default: env)")
+		fmt.Println("  --ssh-hosts         SSH servers used as SOCKS5 proxies (user@host[:port] per line)")
+		fmt.Println("  --ssh-key           Private key for --ssh-hosts; --ssh-known-hosts checks their host keys")
+		fmt.Println("  --vpn-proxy         Proxy of a VPN client, with --vpn-rotate changing its exit when burned")
+		fmt.Println("  --tor               Search through the local Tor client, rotating circuits when burned")
+		fmt.Println("  --audit-log JSONL file recording every outgoing request")
+		fmt.Println("  --html-dump Directory receiving every fetched results page")
+		fmt.Println("  --output-shards     Parallel result writers (default: 1)")
+		fmt.Println("  --output-order      strict, key or none (default: strict)")
+		fmt.Println("  --output-rotate-mb  Rotate results files after this many MB")
+		fmt.Println("  --output-ranked     Also write URLs per dork by SERP position (.tsv)")
+		fmt.Println("  --output-httpx      Also write an httpx target list (.txt)")
+		fmt.Println("  --output-nuclei     Also write nuclei target lists by template tags")
+		fmt.Println("  --result-key-file   Encrypt result files with this AES-256 key")
+		fmt.Println("  --sink-template     Go template rendered per result batch for --sink-exec/--sink-file")
+		fmt.Println("  --pacing-export  Per-minute request/block/CAPTCHA counts (.csv or .json)")
+		fmt.Println("  --params-export  Query parameter names seen per domain (.txt or .json)")
+		fmt.Println("  --proxies-export Proxy list with metadata (.txt, .csv or .json)")
+		fmt.Println("  --pid-file  Write the process ID to this file")
+		fmt.Println("  --log-file  Append output to this file")
+		fmt.Println("  --service   install, uninstall or run as a system service")
+		fmt.Println("  --version   Show version")
+		fmt.Println()
+		fmt.Println("Run \"worker selftest\" to check the parser, proxy formats and IPC.")
+		fmt.Println("Run \"worker bench\" to measure throughput against a local mock engine.")
+		fmt.Println("Run \"worker decrypt\" to read encrypted result files.")
+		fmt.Println("Run \"worker diff\" to compare the URLs of two result files.")
+		fmt.Println("Run \"worker merge\" to combine result files from several workers.")
+		fmt.Println("Run \"worker shard\" to split a dork list between several workers.")
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  dorker-worker --standalone --dorks dorks.txt --proxies proxies.txt --workers 20")
+		fmt.Println()
+		a.exit(1)
+	}
+
+	// Resolve settings: --config file, then profile, then defaults
+	config := protocol.ParseInitConfigWithProfile(protocol.NewMessage(protocol.MsgTypeInit), profile)
+	if opts.configFile != "" {
+		fileConfig, err := protocol.LoadInitConfigFile(opts.configFile, profile)
+		if err != nil {
+			fmt.Printf("✗ Failed to load config: %v\n", err)
+			a.exit(1)
+		}
+		if _, err := protocol.LookupProfile(fileConfig.Profile); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			a.exit(1)
+		}
+		config = fileConfig
+	}
+	if config.Profile != "" {
+		profile = config.Profile
+	}
+	if err := config.Bind.Validate(); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		a.exit(1)
+	}
+	if err := (verify.Config{Patterns: config.VerifyPatterns}).Validate(); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		a.exit(1)
+	}
+	if err := config.Alerts.Validate(); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		a.exit(1)
+	}
+	if len(config.Bind) > 0 {
+		fmt.Printf("Bind: %d proxy groups bound to a source\n", len(config.Bind))
+	}
+
+	// Create proxy pool
+	fmt.Println("Loading proxies...")
+	proxyPool := a.newPool(config)
+
+	var added int
+	var errs []error
+	if opts.proxyFile != "" {
+		added, errs = proxyPool.LoadFromFile(opts.proxyFile)
+	}
+	added += a.addProvidedProxies(proxyPool)
+	fmt.Printf("✓ Loaded %d proxies\n", added)
+	if len(errs) > 0 {
+		fmt.Printf("⚠ %d proxy errors\n", len(errs))
+	}
+
+	if added == 0 {
+		fmt.Println("✗ No valid proxies found")
+		a.exit(1)
+	}
+
+	if proxyPool.Config().PreflightURL != "" {
+		fmt.Println("Running engine pre-flight...")
+	}
+	if summary, ran := a.runPreflight(proxyPool); ran {
+		fmt.Printf("✓ Pre-flight: %d passed, %d banned, %d failed\n", summary.Passed, summary.Banned, summary.Failed)
+	}
+
+	// Load dorks
+	fmt.Println("Loading dorks...")
+	dorks, err := loadDorks(opts.dorkFile)
+	if err != nil {
+		fmt.Printf("✗ Failed to load dorks: %v\n", err)
+		a.exit(1)
+	}
+
+	// Near-duplicate dorks would search the same query again
+	dorks, merged := dorklist.Merge(dorks)
+	fmt.Printf("✓ Loaded %d dorks\n", len(dorks))
+	if kept := a.withholdSensitive(dorks); len(kept) < len(dorks) {
+		a.logger.Warnf("Held back %d sensitive dorks", len(dorks)-len(kept))
+		if len(kept) == 0 {
+			fmt.Println("✗ No dorks left to search")
+			a.exit(1)
+		}
+		dorks = kept
+	}
+
+	// Create output directory
+	if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+		fmt.Printf("✗ Failed to create output directory: %v\n", err)
+		a.exit(1)
+	}
+	started := time.Now().Unix()
+	if len(merged) > 0 {
+		reportPath := fmt.Sprintf("%s/merged_dorks_%d.tsv", opts.outputDir, started)
+		if err := writeMergeReport(reportPath, merged); err != nil {
+			fmt.Printf("⚠ %d duplicate dorks merged; failed to write the report: %v\n", len(merged), err)
+		} else {
+			fmt.Printf("✓ Merged %d duplicate dorks, listed in %s\n", len(merged), reportPath)
+		}
+		a.logger.Infof("Merged %d duplicate dorks", len(merged))
+	}
+
+	var resultSink *sink.Sink
+	if opts.sink.Template != "" {
+		if resultSink, err = sink.New(opts.sink); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			a.exit(1)
+		}
+	}
+
+	// Create worker
+	workerConfig := workerConfigFrom(config)
+	if opts.workers > 0 {
+		workerConfig.Workers = opts.workers
+	}
+	numWorkers := workerConfig.Workers
+	w := a.newWorker(workerConfig, proxyPool, func(alert worker.Alert) {
+		if alert.Event == worker.AlertFinding {
+			fmt.Printf("\n⚠ %s\n", alert.Message)
+		}
+	})
+
+	urlSet, err := dedup.New(workerConfig.Dedup)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		a.exit(1)
+	}
+	w.SetDedup(urlSet)
+
+	pageCache, err := cache.New(workerConfig.Cache)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		a.exit(1)
+	}
+	w.SetCache(pageCache)
+
+	// Start worker
+	fmt.Println()
+	if profile != "" {
+		fmt.Printf("Profile: %s (%s-%s delay, %d retries, num=%d, %s rotation)\n", profile,
+			workerConfig.MinDelay, workerConfig.MaxDelay, workerConfig.MaxRetries, workerConfig.ResultsPerPage, config.Strategy)
+	}
+	fmt.Printf("Starting %d workers...\n", numWorkers)
+	a.logger.Infof("Starting %d workers for %d dorks", numWorkers, len(dorks))
+	w.Start()
+	proxyPool.StartHealthCheck()
+	daemon.Notify(daemon.NotifyReady)
+
+	// Create output file
+	// Encrypted files are named for it, so nobody opens them as text
+	sealed := ""
+	if opts.output.Key != nil {
+		sealed = ".enc"
+	}
+	outputFile, err := output.NewShardedWriter(fmt.Sprintf("%s/results_%d.txt%s", opts.outputDir, started, sealed), opts.output)
+	if err != nil {
+		fmt.Printf("✗ Failed to create output file: %v\n", err)
+		a.exit(1)
+	}
+	defer outputFile.Close()
+
+	// The ranked file is written once every page is in, at the end of the run
+	var rankedFile *output.RankedWriter
+	if opts.ranked {
+		rankedFile = output.NewRankedWriter(fmt.Sprintf("%s/ranked_%d.tsv%s", opts.outputDir, started, sealed), opts.output.Key)
+	}
+	var scanFiles *output.ScanWriter
+	if opts.httpx || opts.nuclei {
+		var httpxPath, nucleiDir string
+		if opts.httpx {
+			httpxPath = fmt.Sprintf("%s/httpx_%d.txt%s", opts.outputDir, started, sealed)
+		}
+		if opts.nuclei {
+			nucleiDir = fmt.Sprintf("%s/nuclei_%d", opts.outputDir, started)
+		}
+		scanFiles = output.NewScanWriter(httpxPath, nucleiDir, sealed, opts.output.Key)
+	}
+
+	// closeOutputs finishes the outputs written at the end of the run
+	closeOutputs := func() {
+		if rankedFile != nil {
+			if err := rankedFile.Close(); err != nil {
+				fmt.Printf("⚠ %v\n", err)
+				a.logger.Errorf("Ranked output failed: %v", err)
+			}
+		}
+		if scanFiles != nil {
+			if err := scanFiles.Close(); err != nil {
+				fmt.Printf("⚠ %v\n", err)
+				a.logger.Errorf("Scanner output failed: %v", err)
+			}
+		}
+		if resultSink != nil {
+			if err := resultSink.Close(); err != nil {
+				fmt.Printf("⚠ %v\n", err)
+				a.logger.Errorf("Result sink failed: %v", err)
+			}
+		}
+	}
+
+	// Process results in background, one consumer per writer shard. Strict
+	// ordering needs a single consumer to keep result order.
+	consumers := opts.output.Shards
+	if consumers < 1 || opts.output.Ordering == output.OrderStrict {
+		consumers = 1
+	}
+	done := make(chan struct{})
+	var urlCount atomic.Int64
+	var consumersWG sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		consumersWG.Add(1)
+		go func() {
+			defer consumersWG.Done()
+			for result := range w.Results() {
+				for _, u := range result.URLs {
+					outputFile.WriteLine(result.Dork, u.Target())
+					if rankedFile != nil {
+						rankedFile.Add(result.Dork, u.Target(), u.Position)
+					}
+					if scanFiles != nil {
+						scanFiles.Add(result.Dork, u.Target())
+					}
+					// A listing's files follow it; they have no SERP rank
+					for _, file := range u.Files {
+						outputFile.WriteLine(result.Dork, file)
+						if scanFiles != nil {
+							scanFiles.Add(result.Dork, file)
+						}
+					}
+					urlCount.Add(int64(len(u.Files)))
+				}
+				urlCount.Add(int64(len(result.URLs)))
+				if resultSink != nil {
+					if err := resultSink.Write(sink.BatchFrom(result)); err != nil {
+						a.logger.Warnf("Result sink: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		consumersWG.Wait()
+		close(done)
+	}()
+
+	// Submit dorks
+	fmt.Println("Processing dorks...")
+	fmt.Println()
+
+	for i, dork := range dorks {
+		w.Submit(&worker.Task{
+			ID:   fmt.Sprintf("task_%d", i),
+			Dork: dork,
+		})
+	}
+
+	// Wait for completion
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	interruptCh := make(chan os.Signal, 1)
+	watchSignals(signalActions{
+		shutdown: func(sig os.Signal) {
+			interruptCh <- sig
+		},
+		force: func(sig os.Signal) {
+			fmt.Println("\nForced exit")
+			a.exit(protocol.ReasonSignal.ExitCode())
+		},
+		reload: func() {
+			daemon.Notify(daemon.NotifyReloading)
+			defer daemon.Notify(daemon.NotifyReady)
+			if opts.configFile != "" {
+				if fileConfig, err := protocol.LoadInitConfigFile(opts.configFile, profile); err != nil {
+					fmt.Printf("\n⚠ Config reload failed: %v\n", err)
+					a.logger.Errorf("Config reload failed: %v", err)
+				} else {
+					w.Reconfigure(workerConfigFrom(fileConfig))
+				}
+			}
+			added, removed, errs := proxyPool.ReloadFromFile(opts.proxyFile)
+			fmt.Printf("\n✓ Reloaded proxies: %d added, %d removed, %d errors\n", added, removed, len(errs))
+			a.logger.Infof("Proxies reloaded: %d added, %d removed, %d errors", added, removed, len(errs))
+		},
+		dumpStats: func() {
+			dumpStats(w, proxyPool)
+		},
+	})
+
+	// drain finishes in-flight tasks and flushes results before stopping
+	drain := func() {
+		daemon.Notify(daemon.NotifyStopping)
+		if !w.Drain(drainTimeout) {
+			a.logger.Warnf("Drain timed out with tasks still pending")
+		}
+		proxyPool.StopHealthCheck()
+		<-done
+		// Closing writes an encrypted file's last record; exit skips defers
+		outputFile.Sync()
+		outputFile.Close()
+		closeOutputs()
+		a.printFinalStats(w, urlCount.Load(), opts.outputDir)
+	}
+
+	for {
+		select {
+		case <-interruptCh:
+			fmt.Println("\n\nInterrupted. Draining in-flight tasks...")
+			drain()
+			a.exit(protocol.ReasonSignal.ExitCode())
+
+		case <-a.serviceStop:
+			fmt.Println("\n\nService stopping. Draining in-flight tasks...")
+			drain()
+			return
+
+		case <-w.Ended():
+			reason, limit := runEndReason(w)
+			fmt.Printf("\n\n%s reached. Flushing partial results...\n", limit)
+			drain()
+			a.exit(reason.ExitCode())
+
+		case <-ticker.C:
+			stats := w.Stats()
+			proxyStats := proxyPool.Stats()
+
+			completed := stats.TasksCompleted + stats.TasksFailed
+			total := stats.TasksTotal
+			percentage := float64(completed) / float64(total) * 100
+
+			fmt.Printf("\r[%.1f%%] %d/%d dorks | %d URLs | %.1f req/s | Proxies: %d alive",
+				percentage, completed, total, urlCount.Load(), stats.RequestsPerSec, proxyStats.Alive)
+			if stats.EstimatedCost > 0 {
+				fmt.Printf(" | Cost: %.2f", stats.EstimatedCost)
+			}
+
+			if completed >= total {
+				fmt.Println()
+				daemon.Notify(daemon.NotifyStopping)
+				w.Stop()
+				proxyPool.StopHealthCheck()
+				<-done
+				closeOutputs()
+				a.printFinalStats(w, urlCount.Load(), opts.outputDir)
+				return
+			}
+		}
+	}
+}
+
+// writeMergeReport lists the dorks dropped as duplicates next to the ones
+// kept
+func writeMergeReport(path string, merged []dorklist.Merged) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := dorklist.WriteReport(f, merged); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadDorks(filepath string) ([]string, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var dorks []string
+	var buf [4096]byte
+	var line []byte
+
+	for {
+		n, err := file.Read(buf[:])
+		if n == 0 {
+			if len(line) > 0 {
+				dorks = append(dorks, string(line))
+			}
+			break
+		}
+
+		for i := 0; i < n; i++ {
+			if buf[i] == '\n' {
+				if len(line) > 0 && line[0] != '#' {
+					dorks = append(dorks, string(line))
+				}
+				line = line[:0]
+			} else if buf[i] != '\r' {
+				line = append(line, buf[i])
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	return dorks, nil
+}
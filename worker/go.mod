@@ -2,49 +2,6 @@ module dorker/worker
 
 go 1.22
 
-require (
-	github.com/Danny-Dasilva/CycleTLS/cycletls v1.0.26
-	github.com/PuerkitoBio/goquery v1.8.1
-	github.com/bits-and-blooms/bloom/v3 v3.6.0
-	github.com/corpix/uarand v0.2.0
-	github.com/goccy/go-json v0.10.2
-	github.com/panjf2000/ants/v2 v2.9.0
-	github.com/rs/zerolog v1.31.0
-	github.com/spf13/viper v1.18.2
-	golang.org/x/net v0.19.0
-	golang.org/x/time v0.5.0
-)
+require golang.org/x/crypto v0.21.0
 
-require (
-	github.com/Danny-Dasilva/fhttp v0.0.0-20240217042913-eeeb0b347ce1 // indirect
-	github.com/Danny-Dasilva/utls v0.0.0-20240310150143-7c2a2e5f86cd // indirect
-	github.com/andybalholm/brotli v1.1.0 // indirect
-	github.com/andybalholm/cascadia v1.3.2 // indirect
-	github.com/bits-and-blooms/bitset v1.13.0 // indirect
-	github.com/cloudflare/circl v1.3.7 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
-	github.com/gorilla/websocket v1.5.1 // indirect
-	github.com/hashicorp/hcl v1.0.0 // indirect
-	github.com/klauspost/compress v1.17.7 // indirect
-	github.com/magiconair/properties v1.8.7 // indirect
-	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/pelletier/go-toml/v2 v2.1.1 // indirect
-	github.com/quic-go/quic-go v0.41.0 // indirect
-	github.com/refraction-networking/utls v1.6.3 // indirect
-	github.com/sagikazarmark/locafero v0.4.0 // indirect
-	github.com/sagikazarmark/slog-shim v0.1.0 // indirect
-	github.com/sourcegraph/conc v0.3.0 // indirect
-	github.com/spf13/afero v1.11.0 // indirect
-	github.com/spf13/cast v1.6.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/subosito/gotenv v1.6.0 // indirect
-	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/crypto v0.21.0 // indirect
-	golang.org/x/exp v0.0.0-20240222234643-814bf88cf225 // indirect
-	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
-	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
-)
+require golang.org/x/sys v0.18.0 // indirect
@@ -0,0 +1,401 @@
+// Package alert evaluates user rules against results as they come in and
+// notifies a webhook or a Telegram chat of the findings they raise, so a
+// critical result, such as an exposed .env file, is heard of during a long
+// run instead of after it.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dorker/worker/internal/proxy"
+)
+
+// Severities of a rule, from the least to the most urgent
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Notification channels a rule may name
+const (
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+// Config describes alerting; it is off without rules
+type Config struct {
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Webhook receives each finding sent to ChannelWebhook as a JSON POST
+	Webhook string `json:"webhook,omitempty"`
+
+	// Telegram is the bot and chat findings sent to ChannelTelegram go to
+	Telegram Telegram `json:"telegram,omitempty"`
+}
+
+// Telegram names a bot by its API token and the chat it posts in. Either
+// may be a ${NAME} reference read from the environment.
+type Telegram struct {
+	Token  string `json:"token,omitempty"`
+	ChatID string `json:"chat_id,omitempty"`
+}
+
+// Rule raises a finding for a result whose URL, title and dork match its
+// regular expressions; unset ones match anything, but one must be set
+type Rule struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Dork     string   `json:"dork,omitempty"`
+	Severity string   `json:"severity,omitempty"` // Default SeverityMedium
+	Notify   []string `json:"notify,omitempty"`   // Channels besides the controller and log
+}
+
+// Finding is a result a rule matched
+type Finding struct {
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	Dork     string    `json:"dork"`
+	TaskID   string    `json:"task_id"`
+	Time     time.Time `json:"time"`
+}
+
+// Message returns a one-line description of the finding
+func (f Finding) Message() string {
+	return fmt.Sprintf("[%s] %s: %s (dork %q)", strings.ToUpper(f.Severity), f.Rule, f.URL, f.Dork)
+}
+
+// Limits of the notifier
+const (
+	// queueSize bounds the notifications waiting for delivery; more are
+	// dropped rather than slowing the run
+	queueSize = 1000
+
+	// maxSeen bounds the findings remembered to raise each once; past it
+	// repeats may be raised again
+	maxSeen = 100000
+
+	// deliveryTimeout bounds one delivery
+	deliveryTimeout = 10 * time.Second
+
+	// closeTimeout bounds how long Close waits for queued deliveries
+	closeTimeout = 30 * time.Second
+)
+
+// telegramAPI is the Bot API endpoint; tests point it elsewhere
+var telegramAPI = "https://api.telegram.org"
+
+// rule is a compiled rule
+type rule struct {
+	Rule
+	url, title, dork *regexp.Regexp
+}
+
+// Validate checks that every rule has a name, a pattern that compiles, a
+// known severity and channels that are configured
+func (c Config) Validate() error {
+	for _, r := range c.Rules {
+		if _, err := compile(r); err != nil {
+			return err
+		}
+		for _, channel := range r.Notify {
+			switch channel {
+			case ChannelWebhook:
+				if c.Webhook == "" {
+					return fmt.Errorf("alert rule %q: notifies the webhook, but none is set", r.Name)
+				}
+			case ChannelTelegram:
+				if c.Telegram.Token == "" || c.Telegram.ChatID == "" {
+					return fmt.Errorf("alert rule %q: notifies Telegram, but no token and chat_id are set", r.Name)
+				}
+			default:
+				return fmt.Errorf("alert rule %q: unknown channel %q", r.Name, channel)
+			}
+		}
+	}
+	if _, err := proxy.ExpandEnv(c.Webhook); err != nil {
+		return fmt.Errorf("alert webhook: %w", err)
+	}
+	if _, err := proxy.ExpandEnv(c.Telegram.Token); err != nil {
+		return fmt.Errorf("alert Telegram token: %w", err)
+	}
+	if _, err := proxy.ExpandEnv(c.Telegram.ChatID); err != nil {
+		return fmt.Errorf("alert Telegram chat: %w", err)
+	}
+	return nil
+}
+
+func compile(r Rule) (*rule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("alert rule without a name")
+	}
+	if r.URL == "" && r.Title == "" && r.Dork == "" {
+		return nil, fmt.Errorf("alert rule %q: needs a url, title or dork pattern", r.Name)
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityMedium
+	case SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+	default:
+		return nil, fmt.Errorf("alert rule %q: unknown severity %q", r.Name, r.Severity)
+	}
+
+	compiled := &rule{Rule: r}
+	for _, p := range []struct {
+		field string
+		expr  string
+		re    **regexp.Regexp
+	}{
+		{"url", r.URL, &compiled.url},
+		{"title", r.Title, &compiled.title},
+		{"dork", r.Dork, &compiled.dork},
+	} {
+		if p.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.expr)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %q: %s pattern: %w", r.Name, p.field, err)
+		}
+		*p.re = re
+	}
+	return compiled, nil
+}
+
+// matches reports whether every set pattern matches
+func (r *rule) matches(rawURL, title, dork string) bool {
+	return (r.url == nil || r.url.MatchString(rawURL)) &&
+		(r.title == nil || r.title.MatchString(title)) &&
+		(r.dork == nil || r.dork.MatchString(dork))
+}
+
+// delivery is a finding on its way to one channel
+type delivery struct {
+	channel string
+	finding Finding
+}
+
+// Notifier evaluates rules and delivers findings in the background, one at
+// a time in the order raised. It is safe for concurrent use.
+type Notifier struct {
+	rules    []*rule
+	webhook  string
+	telegram Telegram
+	client   *http.Client
+
+	mu   sync.Mutex
+	seen map[string]bool
+
+	queueMu sync.Mutex // Held to send on or close queue
+	queue   chan delivery
+	done    chan struct{}
+	closed  bool
+	dropped atomic.Int64
+	failed  atomic.Int64
+	onError func(error)
+}
+
+// New returns the notifier described by config, or nil when it has no
+// rules. Rules that do not compile, and channels whose settings do not
+// expand, are left out; Validate reports them.
+func New(config Config) *Notifier {
+	var rules []*rule
+	for _, r := range config.Rules {
+		if compiled, err := compile(r); err == nil {
+			rules = append(rules, compiled)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	n := &Notifier{
+		rules:  rules,
+		client: &http.Client{Timeout: deliveryTimeout},
+		seen:   make(map[string]bool),
+		queue:  make(chan delivery, queueSize),
+		done:   make(chan struct{}),
+	}
+	n.webhook, _ = proxy.ExpandEnv(config.Webhook)
+	token, tokenErr := proxy.ExpandEnv(config.Telegram.Token)
+	chat, chatErr := proxy.ExpandEnv(config.Telegram.ChatID)
+	if tokenErr == nil && chatErr == nil {
+		n.telegram = Telegram{Token: token, ChatID: chat}
+	}
+	go n.deliver()
+	return n
+}
+
+// SetErrorHandler calls fn for every failed delivery, from the delivering
+// goroutine
+func (n *Notifier) SetErrorHandler(fn func(error)) {
+	n.onError = fn
+}
+
+// Check evaluates the rules against one result and returns the findings
+// it raises, queuing their notifications. A rule raises a finding once per
+// URL and run.
+func (n *Notifier) Check(taskID, dork, rawURL, title string) []Finding {
+	var findings []Finding
+	for _, r := range n.rules {
+		if !r.matches(rawURL, title, dork) {
+			continue
+		}
+
+		key := r.Name + "\x00" + rawURL
+		n.mu.Lock()
+		if n.seen[key] {
+			n.mu.Unlock()
+			continue
+		}
+		if len(n.seen) >= maxSeen {
+			n.seen = make(map[string]bool)
+		}
+		n.seen[key] = true
+		n.mu.Unlock()
+
+		finding := Finding{
+			Rule:     r.Name,
+			Severity: r.Severity,
+			URL:      rawURL,
+			Title:    title,
+			Dork:     dork,
+			TaskID:   taskID,
+			Time:     time.Now(),
+		}
+		findings = append(findings, finding)
+		for _, channel := range r.Notify {
+			n.enqueue(delivery{channel: channel, finding: finding})
+		}
+	}
+	return findings
+}
+
+// enqueue queues a delivery, dropping it when the queue is full or closed
+func (n *Notifier) enqueue(d delivery) {
+	n.queueMu.Lock()
+	defer n.queueMu.Unlock()
+
+	if n.closed {
+		n.dropped.Add(1)
+		return
+	}
+	select {
+	case n.queue <- d:
+	default:
+		n.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many notifications were dropped on a full queue
+func (n *Notifier) Dropped() int64 { return n.dropped.Load() }
+
+// Failed returns how many notifications could not be delivered
+func (n *Notifier) Failed() int64 { return n.failed.Load() }
+
+// deliver sends queued notifications until the queue is closed
+func (n *Notifier) deliver() {
+	defer close(n.done)
+	for d := range n.queue {
+		var err error
+		switch d.channel {
+		case ChannelWebhook:
+			err = n.sendWebhook(d.finding)
+		case ChannelTelegram:
+			err = n.sendTelegram(d.finding)
+		}
+		if err != nil {
+			n.failed.Add(1)
+			if n.onError != nil {
+				n.onError(err)
+			}
+		}
+	}
+}
+
+// sendWebhook POSTs the finding as JSON
+func (n *Notifier) sendWebhook(finding Finding) error {
+	if n.webhook == "" {
+		return fmt.Errorf("alert webhook: not configured")
+	}
+	body, err := json.Marshal(finding)
+	if err != nil {
+		return err
+	}
+	return n.post(n.webhook, "application/json", body, "alert webhook")
+}
+
+// sendTelegram posts the finding's message in the chat
+func (n *Notifier) sendTelegram(finding Finding) error {
+	if n.telegram.Token == "" {
+		return fmt.Errorf("alert Telegram: not configured")
+	}
+	form := url.Values{}
+	form.Set("chat_id", n.telegram.ChatID)
+	form.Set("text", finding.Message())
+	form.Set("disable_web_page_preview", "true")
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", telegramAPI, n.telegram.Token)
+	// The endpoint holds the token, so errors name the service instead
+	return n.post(endpoint, "application/x-www-form-urlencoded", []byte(form.Encode()), "alert Telegram")
+}
+
+// post sends body to endpoint; any status other than 2xx is an error.
+// Errors are reported under label without the endpoint, which may hold a
+// secret.
+func (n *Notifier) post(endpoint, contentType string, body []byte, label string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: invalid endpoint", label)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := n.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: status %d", label, resp.StatusCode)
+	}
+	return nil
+}
+
+// Close stops taking notifications and waits up to 30 seconds for the
+// queued ones to be delivered. Later calls do nothing.
+func (n *Notifier) Close() {
+	n.queueMu.Lock()
+	if n.closed {
+		n.queueMu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	n.queueMu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(closeTimeout):
+	}
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"valid", Config{Rules: []Rule{{Name: "env", URL: `\.env$`, Severity: SeverityHigh}}}, ""},
+		{"no name", Config{Rules: []Rule{{URL: `x`}}}, "without a name"},
+		{"no pattern", Config{Rules: []Rule{{Name: "empty"}}}, "needs a url"},
+		{"bad regex", Config{Rules: []Rule{{Name: "bad", URL: `(`}}}, "url pattern"},
+		{"bad severity", Config{Rules: []Rule{{Name: "s", URL: `x`, Severity: "urgent"}}}, "unknown severity"},
+		{"no webhook", Config{Rules: []Rule{{Name: "w", URL: `x`, Notify: []string{ChannelWebhook}}}}, "none is set"},
+		{"no telegram", Config{Rules: []Rule{{Name: "t", URL: `x`, Notify: []string{ChannelTelegram}}}}, "no token"},
+		{"bad channel", Config{Rules: []Rule{{Name: "c", URL: `x`, Notify: []string{"email"}}}}, "unknown channel"},
+		{"unset variable", Config{Webhook: "https://hooks.example/${ALERT_TEST_UNSET}"}, "ALERT_TEST_UNSET"},
+	}
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: Validate = %v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Validate = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	n := New(Config{Rules: []Rule{
+		{Name: "env", URL: `\.env$`, Severity: SeverityHigh},
+		{Name: "admin", URL: `/admin`, Title: `(?i)login`},
+		{Name: "backup dorks", Dork: `filetype:bak`, Severity: SeverityLow},
+	}})
+	defer n.Close()
+
+	findings := n.Check("t1", "inurl:.env", "https://a.example/.env", "")
+	if len(findings) != 1 || findings[0].Rule != "env" || findings[0].Severity != SeverityHigh || findings[0].TaskID != "t1" {
+		t.Fatalf("findings = %+v", findings)
+	}
+	if again := n.Check("t2", "ext:env", "https://a.example/.env", ""); len(again) != 0 {
+		t.Errorf("a URL raised a rule twice: %+v", again)
+	}
+
+	if got := n.Check("t3", "inurl:admin", "https://b.example/admin/", "Dashboard"); len(got) != 0 {
+		t.Errorf("rule matched without its title: %+v", got)
+	}
+	if got := n.Check("t3", "inurl:admin", "https://c.example/admin/", "Admin Login"); len(got) != 1 || got[0].Severity != SeverityMedium {
+		t.Errorf("findings = %+v, want one at the default severity", got)
+	}
+	if got := n.Check("t4", "filetype:bak site:d.example", "https://d.example/db.bak", ""); len(got) != 1 || got[0].Rule != "backup dorks" {
+		t.Errorf("findings = %+v", got)
+	}
+
+	if New(Config{}) != nil {
+		t.Error("a notifier without rules should be nil")
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var webhook []Finding
+	var telegram []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/hook":
+			var f Finding
+			if err := json.Unmarshal(body, &f); err != nil {
+				t.Errorf("webhook body: %v", err)
+			}
+			webhook = append(webhook, f)
+		case "/botsecret/sendMessage":
+			form, _ := url.ParseQuery(string(body))
+			telegram = append(telegram, form)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defer func(api string) { telegramAPI = api }(telegramAPI)
+	telegramAPI = server.URL
+	t.Setenv("ALERT_TEST_TOKEN", "secret")
+
+	n := New(Config{
+		Rules: []Rule{
+			{Name: "env", URL: `\.env$`, Severity: SeverityCritical, Notify: []string{ChannelWebhook, ChannelTelegram}},
+			{Name: "quiet", URL: `\.log$`},
+		},
+		Webhook:  server.URL + "/hook",
+		Telegram: Telegram{Token: "${ALERT_TEST_TOKEN}", ChatID: "42"},
+	})
+	n.Check("t1", "ext:env", "https://a.example/.env", "")
+	n.Check("t1", "ext:env", "https://a.example/debug.log", "")
+	n.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(webhook) != 1 || webhook[0].URL != "https://a.example/.env" || webhook[0].Severity != SeverityCritical {
+		t.Errorf("webhook got %+v", webhook)
+	}
+	if len(telegram) != 1 || telegram[0].Get("chat_id") != "42" || !strings.Contains(telegram[0].Get("text"), "[CRITICAL] env: https://a.example/.env") {
+		t.Errorf("telegram got %+v", telegram)
+	}
+	if n.Failed() != 0 || n.Dropped() != 0 {
+		t.Errorf("failed %d, dropped %d", n.Failed(), n.Dropped())
+	}
+}
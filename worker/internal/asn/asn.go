@@ -0,0 +1,276 @@
+// Package asn resolves result hosts to their addresses and looks up the
+// autonomous system and netblock announcing them, through Team Cymru's
+// IP to ASN DNS service, so hosts run by the same organization can be
+// grouped when checking a finding against a scope.
+package asn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dorker/worker/internal/engine"
+)
+
+// Config describes network lookups; they are off unless Enabled
+type Config struct {
+	Enabled bool `json:"enabled"`
+
+	// Timeout bounds the lookups of one host
+	Timeout time.Duration `json:"timeout"`
+}
+
+// DefaultTimeout is used when Config.Timeout is unset
+const DefaultTimeout = 10 * time.Second
+
+// maxHosts bounds the hosts kept for the report; hosts beyond it are not
+// looked up
+const maxHosts = 50000
+
+// Zones of Team Cymru's service: the origin of an address, reversed into
+// the zone of its family, and the name of an AS
+const (
+	originZone  = "origin.asn.cymru.com"
+	origin6Zone = "origin6.asn.cymru.com"
+	asZone      = "asn.cymru.com"
+)
+
+// resolver is the part of *net.Resolver lookups use
+type resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Lookup looks each host up once per run and keeps the outcome, failures
+// included, for grouping. It is safe for concurrent use.
+type Lookup struct {
+	timeout  time.Duration
+	resolver resolver
+
+	mu      sync.Mutex
+	hosts   map[string]*engine.Network // By host name; nil for hosts that did not resolve
+	asNames map[uint32]string
+}
+
+// New returns the lookup described by config, or nil when it is disabled.
+// Queries go to the system's resolver, not through the proxies.
+func New(config Config) *Lookup {
+	if !config.Enabled {
+		return nil
+	}
+	return newLookup(config, net.DefaultResolver)
+}
+
+func newLookup(config Config, r resolver) *Lookup {
+	l := &Lookup{
+		timeout:  config.Timeout,
+		resolver: r,
+		hosts:    make(map[string]*engine.Network),
+		asNames:  make(map[uint32]string),
+	}
+	if l.timeout <= 0 {
+		l.timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Cached returns the network of a host seen before, nil when it did not
+// resolve. ok is false for a host not seen yet, or one that is not looked
+// up as the host limit is reached.
+func (l *Lookup) Cached(host string) (network *engine.Network, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	network, ok = l.hosts[host]
+	return network, ok
+}
+
+// Full reports whether the host limit is reached
+func (l *Lookup) Full() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.hosts) >= maxHosts
+}
+
+// Take resolves host and looks up the origin of its first address, and
+// keeps the outcome. A host that does not resolve fails; one whose address
+// no AS announces has an ASN of 0.
+func (l *Lookup) Take(ctx context.Context, host string) (*engine.Network, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	network, err := l.take(ctx, host)
+
+	l.mu.Lock()
+	if _, ok := l.hosts[host]; ok || len(l.hosts) < maxHosts {
+		l.hosts[host] = network
+	}
+	l.mu.Unlock()
+	return network, err
+}
+
+func (l *Lookup) take(ctx context.Context, host string) (*engine.Network, error) {
+	var ips []string
+	if addr, err := netip.ParseAddr(host); err == nil {
+		ips = []string{addr.String()}
+	} else {
+		if ips, err = l.resolver.LookupHost(ctx, host); err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", host, err)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("resolve %s: no addresses", host)
+		}
+	}
+
+	network := &engine.Network{IPs: ips}
+	addr, err := netip.ParseAddr(ips[0])
+	if err != nil {
+		return network, nil
+	}
+	records, err := l.resolver.LookupTXT(ctx, OriginName(addr))
+	if err != nil || len(records) == 0 {
+		// Unannounced space has no record, which is not a failure
+		return network, nil
+	}
+	asn, prefix, country, ok := ParseOrigin(records[0])
+	if !ok {
+		return network, nil
+	}
+	network.ASN, network.Prefix, network.Country = asn, prefix, country
+	network.Name = l.asName(ctx, asn)
+	return network, nil
+}
+
+// asName returns the registered name of an AS, looking it up the first
+// time; "" when the lookup fails
+func (l *Lookup) asName(ctx context.Context, asn uint32) string {
+	l.mu.Lock()
+	name, ok := l.asNames[asn]
+	l.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	records, err := l.resolver.LookupTXT(ctx, fmt.Sprintf("AS%d.%s", asn, asZone))
+	if err != nil || len(records) == 0 {
+		return ""
+	}
+	name = ParseName(records[0])
+	l.mu.Lock()
+	l.asNames[asn] = name
+	l.mu.Unlock()
+	return name
+}
+
+// OriginName returns the name whose TXT record holds the origin of addr:
+// its IPv4 octets, or IPv6 nibbles, reversed under the zone of its family
+func OriginName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	var labels []string
+	if addr.Is4() {
+		b := addr.As4()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(b[i])))
+		}
+		return strings.Join(labels, ".") + "." + originZone
+	}
+	b := addr.As16()
+	for i := len(b) - 1; i >= 0; i-- {
+		labels = append(labels, strconv.FormatUint(uint64(b[i]&0xf), 16), strconv.FormatUint(uint64(b[i]>>4), 16))
+	}
+	return strings.Join(labels, ".") + "." + origin6Zone
+}
+
+// ParseOrigin reads an origin record, such as
+// "13335 | 104.16.0.0/13 | US | arin | 2014-03-28". Space announced by
+// several ASes lists them all; the first is kept.
+func ParseOrigin(record string) (asn uint32, prefix, country string, ok bool) {
+	fields := strings.Split(record, "|")
+	if len(fields) < 3 {
+		return 0, "", "", false
+	}
+	asns := strings.Fields(fields[0])
+	if len(asns) == 0 {
+		return 0, "", "", false
+	}
+	n, err := strconv.ParseUint(asns[0], 10, 32)
+	if err != nil {
+		return 0, "", "", false
+	}
+	return uint32(n), strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2]), true
+}
+
+// ParseName reads the name of an AS record, such as
+// "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+func ParseName(record string) string {
+	fields := strings.Split(record, "|")
+	if len(fields) < 5 {
+		return ""
+	}
+	return strings.TrimSpace(fields[4])
+}
+
+// Netblock is an announced prefix and the hosts in it
+type Netblock struct {
+	Prefix string
+	Hosts  []string // Sorted
+}
+
+// Group is the hosts announced by one AS
+type Group struct {
+	ASN       uint32
+	Name      string
+	Country   string
+	Hosts     int
+	Netblocks []Netblock // Most hosts first
+}
+
+// Groups groups the hosts looked up by the AS announcing them, and each
+// AS's hosts by netblock, largest first. Hosts without an AS are left out.
+func (l *Lookup) Groups() []Group {
+	l.mu.Lock()
+	groups := make(map[uint32]*Group)
+	blocks := make(map[uint32]map[string][]string)
+	for host, network := range l.hosts {
+		if network == nil || network.ASN == 0 {
+			continue
+		}
+		group, ok := groups[network.ASN]
+		if !ok {
+			group = &Group{ASN: network.ASN, Name: network.Name, Country: network.Country}
+			groups[network.ASN] = group
+			blocks[network.ASN] = make(map[string][]string)
+		}
+		group.Hosts++
+		blocks[network.ASN][network.Prefix] = append(blocks[network.ASN][network.Prefix], host)
+	}
+	l.mu.Unlock()
+
+	result := make([]Group, 0, len(groups))
+	for asn, group := range groups {
+		for prefix, hosts := range blocks[asn] {
+			sort.Strings(hosts)
+			group.Netblocks = append(group.Netblocks, Netblock{Prefix: prefix, Hosts: hosts})
+		}
+		sort.Slice(group.Netblocks, func(i, j int) bool {
+			a, b := group.Netblocks[i], group.Netblocks[j]
+			if len(a.Hosts) != len(b.Hosts) {
+				return len(a.Hosts) > len(b.Hosts)
+			}
+			return a.Prefix < b.Prefix
+		})
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hosts != result[j].Hosts {
+			return result[i].Hosts > result[j].Hosts
+		}
+		return result[i].ASN < result[j].ASN
+	})
+	return result
+}
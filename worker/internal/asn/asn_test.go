@@ -0,0 +1,108 @@
+package asn
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// fakeResolver answers from maps and counts TXT queries
+type fakeResolver struct {
+	hosts   map[string][]string
+	txt     map[string]string
+	queries int
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if ips, ok := r.hosts[host]; ok {
+		return ips, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.queries++
+	if record, ok := r.txt[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestOriginName(t *testing.T) {
+	tests := map[string]string{
+		"104.16.1.2":        "2.1.16.104.origin.asn.cymru.com",
+		"::ffff:192.0.2.1":  "1.2.0.192.origin.asn.cymru.com",
+		"2606:4700::6810:1": "1.0.0.0.0.1.8.6." + "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0." + "0.0.7.4.6.0.6.2.origin6.asn.cymru.com",
+	}
+	for ip, want := range tests {
+		if got := OriginName(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("OriginName(%s) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestParseRecords(t *testing.T) {
+	asn, prefix, country, ok := ParseOrigin("13335 209242 | 104.16.0.0/13 | US | arin | 2014-03-28")
+	if !ok || asn != 13335 || prefix != "104.16.0.0/13" || country != "US" {
+		t.Errorf("ParseOrigin = %d, %q, %q, %v", asn, prefix, country, ok)
+	}
+	if _, _, _, ok := ParseOrigin("garbage"); ok {
+		t.Error("ParseOrigin accepted a record without fields")
+	}
+	if got := ParseName("13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"); got != "CLOUDFLARENET, US" {
+		t.Errorf("ParseName = %q", got)
+	}
+}
+
+func TestTakeAndGroups(t *testing.T) {
+	r := &fakeResolver{
+		hosts: map[string][]string{
+			"a.example": {"198.51.100.7"},
+			"b.example": {"198.51.100.9", "198.51.100.10"},
+			"c.example": {"203.0.113.5"},
+			"d.example": {"192.0.2.1"},
+		},
+		txt: map[string]string{
+			"7.100.51.198.origin.asn.cymru.com": "64500 | 198.51.100.0/24 | DE | ripencc | 2001-01-01",
+			"9.100.51.198.origin.asn.cymru.com": "64500 | 198.51.100.0/24 | DE | ripencc | 2001-01-01",
+			"5.113.0.203.origin.asn.cymru.com":  "64500 | 203.0.113.0/24 | DE | ripencc | 2001-01-01",
+			"AS64500.asn.cymru.com":             "64500 | DE | ripencc | 2001-01-01 | EXAMPLE-AS, DE",
+		},
+	}
+	l := newLookup(Config{Enabled: true}, r)
+	ctx := context.Background()
+
+	for _, host := range []string{"a.example", "b.example", "c.example", "d.example"} {
+		if _, err := l.Take(ctx, host); err != nil {
+			t.Fatalf("Take(%s): %v", host, err)
+		}
+	}
+	if _, err := l.Take(ctx, "gone.example"); err == nil {
+		t.Error("Take of a host that does not resolve succeeded")
+	}
+	// The AS name is looked up once: three origins and one name
+	if r.queries != 5 {
+		t.Errorf("TXT queries = %d, want 5", r.queries)
+	}
+
+	network, ok := l.Cached("b.example")
+	if !ok || network.ASN != 64500 || network.Name != "EXAMPLE-AS, DE" || len(network.IPs) != 2 {
+		t.Errorf("Cached(b.example) = %+v, %v", network, ok)
+	}
+	if network, ok := l.Cached("d.example"); !ok || network.ASN != 0 {
+		t.Errorf("unannounced host: Cached = %+v, %v", network, ok)
+	}
+	if network, ok := l.Cached("gone.example"); !ok || network != nil {
+		t.Errorf("failed host: Cached = %+v, %v", network, ok)
+	}
+
+	groups := l.Groups()
+	if len(groups) != 1 || groups[0].ASN != 64500 || groups[0].Hosts != 3 {
+		t.Fatalf("Groups = %+v", groups)
+	}
+	blocks := groups[0].Netblocks
+	if len(blocks) != 2 || blocks[0].Prefix != "198.51.100.0/24" || len(blocks[0].Hosts) != 2 || blocks[1].Prefix != "203.0.113.0/24" {
+		t.Errorf("Netblocks = %+v", blocks)
+	}
+}
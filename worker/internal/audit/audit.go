@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record describes a single outgoing request
+type Record struct {
+	Timestamp  time.Time `json:"ts"`
+	TaskID     string    `json:"task_id"`
+	Engine     string    `json:"engine"`
+	Domain     string    `json:"domain"`
+	Dork       string    `json:"dork,omitempty"`
+	URL        string    `json:"url,omitempty"` // Set for fetch tasks and result steps
+	Page       int       `json:"page"`
+	Attempt    int       `json:"attempt"`
+	Proxy      string    `json:"proxy"`
+	StatusCode int       `json:"status_code"` // 0 when no response was received
+	Outcome    string    `json:"outcome"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Log is an append-only JSONL audit log. Each record is written with a
+// single write call so lines never interleave.
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens or creates an audit log for appending
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{file: f}, nil
+}
+
+// Record appends a record to the log
+func (l *Log) Record(record Record) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log closed")
+	}
+	_, err = l.file.Write(data)
+	return err
+}
+
+// Sync flushes the log to disk
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+// Close syncs and closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	l.file.Sync()
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid JSONL line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestLogRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	err = log.Record(Record{
+		TaskID:     "t1",
+		Engine:     "google",
+		Domain:     "www.google.com",
+		Dork:       "inurl:admin",
+		Page:       1,
+		Proxy:      "http_1.2.3.4_8080",
+		StatusCode: 200,
+		Outcome:    "success",
+		DurationMs: 420,
+	})
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	log.Close()
+
+	records := readRecords(t, path)
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	if records[0].Dork != "inurl:admin" || records[0].StatusCode != 200 {
+		t.Errorf("record = %+v", records[0])
+	}
+	if records[0].Timestamp.IsZero() {
+		t.Error("timestamp should be filled in")
+	}
+
+	if err := log.Record(Record{}); err == nil {
+		t.Error("Record after Close should fail")
+	}
+}
+
+func TestLogAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	for i := 0; i < 2; i++ {
+		log, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		log.Record(Record{TaskID: fmt.Sprintf("t%d", i), Timestamp: time.Now()})
+		log.Close()
+	}
+
+	records := readRecords(t, path)
+	if len(records) != 2 || records[0].TaskID != "t0" || records[1].TaskID != "t1" {
+		t.Errorf("records = %+v", records)
+	}
+}
+
+func TestLogConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			log.Record(Record{TaskID: fmt.Sprintf("t%d", i), Outcome: "success"})
+		}(i)
+	}
+	wg.Wait()
+	log.Close()
+
+	if records := readRecords(t, path); len(records) != 50 {
+		t.Errorf("records = %d, want 50", len(records))
+	}
+}
@@ -0,0 +1,173 @@
+// Package bench runs the worker's full pipeline, from task queue through
+// proxy selection, requests, parsing and results, against a local mock
+// engine, so performance regressions show before a release.
+package bench
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"time"
+
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/worker"
+)
+
+// Config describes a benchmark run
+type Config struct {
+	Workers int           `json:"workers"` // Worker goroutines
+	Proxies int           `json:"proxies"` // Proxies in the pool, all leading to the mock
+	Tasks   int           `json:"tasks"`   // Search tasks to run
+	Results int           `json:"results"` // Results on each mock page
+	Latency time.Duration `json:"latency"` // Mock engine answer time
+}
+
+// DefaultConfig returns a run that takes a few seconds
+func DefaultConfig() Config {
+	return Config{
+		Workers: 10,
+		Proxies: 20,
+		Tasks:   2000,
+		Results: 10,
+	}
+}
+
+// Report holds the outcome of a benchmark run. Allocations cover the whole
+// process, the in-process mock included, so compare them between runs of
+// the same configuration rather than read them as absolutes.
+type Report struct {
+	Config Config `json:"config"`
+
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	URLs      int           `json:"urls"`
+	Searches  int64         `json:"searches"` // Requests the mock answered, retries included
+	Elapsed   time.Duration `json:"elapsed"`
+
+	TasksPerSec float64 `json:"tasks_per_sec"`
+	URLsPerSec  float64 `json:"urls_per_sec"`
+
+	// Task latency percentiles, from the worker's result durations
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+
+	Allocs        uint64  `json:"allocs"`
+	AllocBytes    uint64  `json:"alloc_bytes"`
+	AllocsPerTask float64 `json:"allocs_per_task"`
+	BytesPerTask  float64 `json:"bytes_per_task"`
+}
+
+// Run starts a mock engine, runs config.Tasks search tasks through a worker
+// against it and reports how the run went. A cancelled ctx ends the run
+// early with the tasks finished so far.
+func Run(ctx context.Context, config Config) (*Report, error) {
+	if config.Workers <= 0 || config.Proxies <= 0 || config.Tasks <= 0 {
+		return nil, fmt.Errorf("workers, proxies and tasks must be positive")
+	}
+
+	mock, err := NewMockEngine(config.Results, config.Latency)
+	if err != nil {
+		return nil, err
+	}
+	defer mock.Close()
+
+	// The pool never quarantines or rests a proxy on its own during a run;
+	// the mock answers every request
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	host, port := mock.Addr()
+	for i := 0; i < config.Proxies; i++ {
+		pool.AddProxy(&proxy.Proxy{ID: fmt.Sprintf("bench_%d", i), Host: host, Port: port, Type: proxy.ProxyTypeHTTP})
+	}
+
+	workerConfig := worker.DefaultConfig()
+	workerConfig.Workers = config.Workers
+	workerConfig.BufferSize = config.Tasks
+	workerConfig.BaseDelay = 0
+	workerConfig.MinDelay = 0
+	workerConfig.MaxDelay = 0
+	workerConfig.RetryDelay = 0
+	w := worker.New(workerConfig, pool)
+	w.SetTLSConfig(&tls.Config{RootCAs: mock.RootCAs()})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	w.Start()
+	defer w.Stop()
+	for i := 0; i < config.Tasks; i++ {
+		task := &worker.Task{ID: fmt.Sprintf("bench_%d", i), Dork: fmt.Sprintf("inurl:admin bench %d", i)}
+		if err := w.Submit(task); err != nil {
+			return nil, fmt.Errorf("failed to submit task %d: %w", i, err)
+		}
+	}
+
+	report := &Report{Config: config}
+	durations := make([]time.Duration, 0, config.Tasks)
+collect:
+	for len(durations) < config.Tasks {
+		select {
+		case result := <-w.Results():
+			if result.Status == worker.StatusSuccess || result.Status == worker.StatusNoResults {
+				report.Succeeded++
+			} else {
+				report.Failed++
+			}
+			report.URLs += len(result.URLs)
+			durations = append(durations, result.Duration)
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	report.Elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	report.Searches = mock.Searches()
+
+	if seconds := report.Elapsed.Seconds(); seconds > 0 {
+		report.TasksPerSec = float64(len(durations)) / seconds
+		report.URLsPerSec = float64(report.URLs) / seconds
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	report.P50 = percentile(durations, 50)
+	report.P90 = percentile(durations, 90)
+	report.P99 = percentile(durations, 99)
+	report.Max = percentile(durations, 100)
+
+	report.Allocs = after.Mallocs - before.Mallocs
+	report.AllocBytes = after.TotalAlloc - before.TotalAlloc
+	if n := len(durations); n > 0 {
+		report.AllocsPerTask = float64(report.Allocs) / float64(n)
+		report.BytesPerTask = float64(report.AllocBytes) / float64(n)
+	}
+	return report, nil
+}
+
+// percentile returns the p-th percentile of sorted durations by nearest
+// rank, 0 when there are none
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Write prints the report for a terminal
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "Workers %d, proxies %d, tasks %d, %d results per page, %v mock latency\n\n",
+		r.Config.Workers, r.Config.Proxies, r.Config.Tasks, r.Config.Results, r.Config.Latency)
+	fmt.Fprintf(w, "  tasks        %d succeeded, %d failed, %d searches\n", r.Succeeded, r.Failed, r.Searches)
+	fmt.Fprintf(w, "  elapsed      %v\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  throughput   %.1f tasks/s, %.1f URLs/s\n", r.TasksPerSec, r.URLsPerSec)
+	fmt.Fprintf(w, "  latency      p50 %v, p90 %v, p99 %v, max %v\n",
+		r.P50.Round(time.Microsecond), r.P90.Round(time.Microsecond), r.P99.Round(time.Microsecond), r.Max.Round(time.Microsecond))
+	fmt.Fprintf(w, "  allocations  %.0f per task, %.1f KB per task\n", r.AllocsPerTask, r.BytesPerTask/1024)
+}
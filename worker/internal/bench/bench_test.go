@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), Config{Workers: 4, Proxies: 3, Tasks: 40, Results: 5})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if report.Succeeded != 40 || report.Failed != 0 {
+		t.Errorf("succeeded %d, failed %d, want 40 and 0", report.Succeeded, report.Failed)
+	}
+	if report.URLs != 200 {
+		t.Errorf("URLs = %d, want 200", report.URLs)
+	}
+	if report.Searches != 40 {
+		t.Errorf("searches = %d, want 40", report.Searches)
+	}
+	if report.TasksPerSec <= 0 || report.P50 <= 0 || report.P50 > report.P99 || report.P99 > report.Max {
+		t.Errorf("throughput %v, latency p50 %v p99 %v max %v", report.TasksPerSec, report.P50, report.P99, report.Max)
+	}
+	if report.AllocsPerTask <= 0 {
+		t.Errorf("AllocsPerTask = %v", report.AllocsPerTask)
+	}
+
+	var out strings.Builder
+	report.Write(&out)
+	if !strings.Contains(out.String(), "40 succeeded, 0 failed") {
+		t.Errorf("report output:\n%s", out.String())
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	if _, err := Run(context.Background(), Config{Workers: 1, Proxies: 0, Tasks: 1}); err == nil {
+		t.Error("Run should reject a run without proxies")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := map[int]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond}
+	for p, want := range tests {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%d) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile of nothing = %v, want 0", got)
+	}
+}
@@ -0,0 +1,243 @@
+package bench
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MockEngine is a local stand-in for the search engine and the proxies in
+// front of it. Requests reach it as a proxy, HTTPS tunnelled with CONNECT
+// the way the worker sends them, and every search is answered with a
+// results page. Its certificate covers the Google domains, so a client
+// trusting RootCAs sees a normal TLS connection.
+type MockEngine struct {
+	results int
+	latency time.Duration
+
+	listener net.Listener
+	proxy    *http.Server // Accepts proxied requests and CONNECT tunnels
+	engine   *http.Server // Serves the searches inside the tunnels
+	tunnels  *connListener
+	tls      *tls.Config
+	roots    *x509.CertPool
+
+	searches atomic.Int64
+}
+
+// NewMockEngine starts a mock engine on a local port answering each search
+// with results URLs after latency
+func NewMockEngine(results int, latency time.Duration) (*MockEngine, error) {
+	cert, roots, err := newCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mock certificate: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	m := &MockEngine{
+		results:  results,
+		latency:  latency,
+		listener: listener,
+		tunnels:  newConnListener(listener.Addr()),
+		tls:      &tls.Config{Certificates: []tls.Certificate{cert}},
+		roots:    roots,
+	}
+	m.engine = &http.Server{Handler: http.HandlerFunc(m.serveSearch)}
+	m.proxy = &http.Server{Handler: http.HandlerFunc(m.serveProxy)}
+
+	go m.engine.Serve(m.tunnels)
+	go m.proxy.Serve(listener)
+	return m, nil
+}
+
+// Addr returns the host and port to use as the proxy address
+func (m *MockEngine) Addr() (host, port string) {
+	host, port, _ = net.SplitHostPort(m.listener.Addr().String())
+	return host, port
+}
+
+// RootCAs returns the pool holding the mock's certificate
+func (m *MockEngine) RootCAs() *x509.CertPool {
+	return m.roots
+}
+
+// Searches returns the number of search requests answered
+func (m *MockEngine) Searches() int64 {
+	return m.searches.Load()
+}
+
+// Close stops the mock and closes its connections
+func (m *MockEngine) Close() error {
+	err := m.proxy.Close()
+	m.engine.Close()
+	m.tunnels.Close()
+	return err
+}
+
+// serveProxy opens a CONNECT tunnel into the engine, or answers a plain
+// proxied request directly
+func (m *MockEngine) serveProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		m.serveSearch(w, r)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnelling not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		return
+	}
+
+	// Bytes the client sent after CONNECT are the start of its handshake
+	var tunnel net.Conn = conn
+	if buffered.Reader.Buffered() > 0 {
+		tunnel = &bufferedConn{Conn: conn, r: buffered.Reader}
+	}
+	if !m.tunnels.push(tls.Server(tunnel, m.tls)) {
+		conn.Close()
+	}
+}
+
+// serveSearch answers a search with a results page of distinct URLs derived
+// from the query
+func (m *MockEngine) serveSearch(w http.ResponseWriter, r *http.Request) {
+	if m.latency > 0 {
+		time.Sleep(m.latency)
+	}
+	m.searches.Add(1)
+
+	query := r.URL.Query().Get("q")
+	h := fnv.New32a()
+	h.Write([]byte(query))
+	site := h.Sum32()
+
+	var page strings.Builder
+	page.WriteString("<!doctype html>\n<html lang=\"en\">\n<head><title>")
+	page.WriteString(html.EscapeString(query))
+	page.WriteString(" - Google Search</title></head>\n<body>\n<div id=\"search\">\n")
+	for i := 0; i < m.results; i++ {
+		fmt.Fprintf(&page, "<div class=\"g\">\n<a href=\"/url?q=https://site%d.bench.example/%08x/page%d.php&amp;sa=U\">\n", i, site, i)
+		fmt.Fprintf(&page, "<h3 class=\"r\">Result %d</h3></a>\n", i+1)
+		page.WriteString("<div class=\"s\">A page served by the benchmark engine, with a description of ordinary length.</div>\n</div>\n")
+	}
+	page.WriteString("</div>\n</body>\n</html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Write([]byte(page.String()))
+}
+
+// newCertificate creates a self-signed certificate for the Google domains
+// and the pool trusting it
+func newCertificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dorker bench engine"},
+		DNSNames:              []string{"google.com", "*.google.com", "localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots, nil
+}
+
+// connListener hands tunnelled connections to an http.Server
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// push hands conn to Accept, reporting false once the listener is closed
+func (l *connListener) push(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.closed:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+// bufferedConn reads what the CONNECT request left buffered before the
+// rest of the connection
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,150 @@
+// Package bind picks the source of the worker's outgoing connections on a
+// multi-homed machine: a local address, or a network interface whose
+// address is used. Rules bind each proxy group to one, so that groups of
+// scraping proxies are reached over different uplinks.
+package bind
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Rules binds proxy groups to sources. A group is matched against the
+// address connected to by, in order: its host, the longest "*.domain"
+// pattern covering the host, then "*", which binds every connection. A
+// source is an IP address or a network interface name.
+type Rules map[string]string
+
+// Validate checks that every source is an address or an existing
+// interface
+func (r Rules) Validate() error {
+	for group, source := range r {
+		if _, err := Parse(source); err != nil {
+			return fmt.Errorf("bind %s: %w", group, err)
+		}
+	}
+	return nil
+}
+
+// Source returns the source bound to a host, or "" when no rule binds it
+func (r Rules) Source(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if source, ok := r[host]; ok && host != "" {
+		return source
+	}
+
+	best := ""
+	for pattern := range r {
+		suffix, ok := strings.CutPrefix(pattern, "*.")
+		if !ok {
+			continue
+		}
+		if (host == suffix || strings.HasSuffix(host, "."+suffix)) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return r[best]
+	}
+	return r["*"]
+}
+
+// LocalAddr returns the local address to connect to addr from, nil when
+// no rule binds it. Loopback destinations, such as a local Tor client or
+// SSH forward, are never bound.
+func (r Rules) LocalAddr(network, addr string) (net.Addr, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil
+	}
+	if ip := net.ParseIP(host); (ip != nil && ip.IsLoopback()) || strings.EqualFold(host, "localhost") {
+		return nil, nil
+	}
+	setting := r.Source(host)
+	if setting == "" {
+		return nil, nil
+	}
+
+	source, err := Parse(setting)
+	if err != nil {
+		return nil, err
+	}
+	ip, err := source.IP(net.ParseIP(host))
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}, nil
+	}
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+// Source is an address or interface connections are made from
+type Source struct {
+	ip      net.IP
+	iface   string
+	setting string
+}
+
+// Parse parses a source: an IP address, or the name of a network
+// interface, which must exist
+func Parse(setting string) (*Source, error) {
+	setting = strings.TrimSpace(setting)
+	if ip := net.ParseIP(setting); ip != nil {
+		return &Source{ip: ip, setting: setting}, nil
+	}
+	if setting == "" {
+		return nil, fmt.Errorf("empty source: want an IP address or interface")
+	}
+	if _, err := net.InterfaceByName(setting); err != nil {
+		return nil, fmt.Errorf("%q is neither an IP address nor an interface", setting)
+	}
+	return &Source{iface: setting, setting: setting}, nil
+}
+
+// IP returns the address to connect from. An interface's addresses are
+// read at each call, as they may change; the one matching the family of
+// dest is used, an IPv4 one when dest is not an address, since a bound
+// dial only tries destination addresses of its source's family.
+func (s *Source) IP(dest net.IP) (net.IP, error) {
+	if s.ip != nil {
+		return s.ip, nil
+	}
+
+	iface, err := net.InterfaceByName(s.iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", s.iface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", s.iface, err)
+	}
+
+	wantV6 := dest != nil && dest.To4() == nil
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if (ipNet.IP.To4() == nil) == wantV6 {
+			return ipNet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipNet.IP
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no address", s.iface)
+	}
+	return fallback, nil
+}
+
+// String returns the source as it was given
+func (s *Source) String() string {
+	return s.setting
+}
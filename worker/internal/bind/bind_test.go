@@ -0,0 +1,89 @@
+package bind
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRulesSource(t *testing.T) {
+	rules := Rules{
+		"10.0.0.1":          "192.0.2.1",
+		"*.provider.com":    "192.0.2.2",
+		"*.eu.provider.com": "192.0.2.3",
+		"*":                 "192.0.2.4",
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"10.0.0.1", "192.0.2.1"},
+		{"us.provider.com", "192.0.2.2"},
+		{"provider.com", "192.0.2.2"},
+		{"fr.eu.provider.com", "192.0.2.3"},
+		{"other.net", "192.0.2.4"},
+	}
+	for _, tt := range tests {
+		if got := rules.Source(tt.host); got != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+
+	if got := (Rules{"a.example": "192.0.2.1"}).Source("b.example"); got != "" {
+		t.Errorf("Source without a matching rule = %q", got)
+	}
+}
+
+func TestRulesLocalAddr(t *testing.T) {
+	rules := Rules{"*": "192.0.2.7"}
+
+	local, err := rules.LocalAddr("tcp", "203.0.113.5:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr, ok := local.(*net.TCPAddr); !ok || !addr.IP.Equal(net.ParseIP("192.0.2.7")) {
+		t.Errorf("LocalAddr = %v, want 192.0.2.7", local)
+	}
+
+	for _, addr := range []string{"127.0.0.1:9050", "[::1]:1080", "localhost:8080"} {
+		if local, _ := rules.LocalAddr("tcp", addr); local != nil {
+			t.Errorf("LocalAddr(%q) = %v, want loopback left unbound", addr, local)
+		}
+	}
+	if local, _ := (Rules{}).LocalAddr("tcp", "203.0.113.5:8080"); local != nil {
+		t.Errorf("LocalAddr without rules = %v", local)
+	}
+}
+
+func TestParseInterface(t *testing.T) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		source, err := Parse(iface.Name)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", iface.Name, err)
+		}
+		ip, err := source.IP(net.ParseIP("203.0.113.5"))
+		if err != nil {
+			t.Skip(err)
+		}
+		if !ip.IsLoopback() {
+			t.Errorf("%s address = %v, want a loopback one", iface.Name, ip)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Rules{"*": "192.0.2.1", "*.provider.com": "2001:db8::1"}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if err := (Rules{"*": "no-such-interface0"}).Validate(); err == nil {
+		t.Error("Validate accepted a missing interface")
+	}
+}
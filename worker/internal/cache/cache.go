@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"dorker/worker/internal/engine"
+)
+
+// DefaultMaxEntries bounds the pages kept in memory
+const DefaultMaxEntries = 10000
+
+// Config configures a Cache
+type Config struct {
+	// TTL is how long a parsed page is served from the cache; 0 turns
+	// caching off
+	TTL time.Duration `json:"ttl"`
+
+	// Dir, when set, also keeps pages on disk, so they outlive the process
+	// and the memory bound
+	Dir string `json:"dir,omitempty"`
+
+	// MaxEntries bounds the pages kept in memory; the oldest go first
+	MaxEntries int `json:"max_entries"`
+}
+
+// Key identifies a results page. The page size is part of it, since the
+// same page number covers different results at different sizes.
+type Key struct {
+	Engine         string
+	Query          string
+	Page           int
+	Domain         string
+	ResultsPerPage int
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s\x00%s\x00%d\x00%s\x00%d", k.Engine, k.Query, k.Page, k.Domain, k.ResultsPerPage)
+}
+
+// Entry is a parsed results page, before any per-task filtering
+type Entry struct {
+	Results   []engine.SearchResult `json:"results"`
+	NoResults bool                  `json:"no_results"` // The engine said it found nothing
+	Stored    time.Time             `json:"stored"`
+}
+
+// Cache keeps parsed results pages for a TTL, in memory and optionally on
+// disk. It is safe for concurrent use.
+type Cache struct {
+	ttl        time.Duration
+	dir        string
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[Key]*list.Element // Values are *item
+	order   *list.List            // Oldest first
+}
+
+type item struct {
+	key   Key
+	entry Entry
+}
+
+// New creates a cache for config; nil when caching is off
+func New(config Config) (*Cache, error) {
+	if config.TTL <= 0 {
+		return nil, nil
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultMaxEntries
+	}
+	if config.Dir != "" {
+		if err := os.MkdirAll(config.Dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+
+	return &Cache{
+		ttl:        config.TTL,
+		dir:        config.Dir,
+		maxEntries: config.MaxEntries,
+		entries:    make(map[Key]*list.Element),
+		order:      list.New(),
+	}, nil
+}
+
+// Get returns the page cached under key if it is younger than the TTL.
+// The results are a copy the caller may change.
+func (c *Cache) Get(key Key) (Entry, bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		it := elem.Value.(*item)
+		if now.Sub(it.entry.Stored) < c.ttl {
+			entry := copyEntry(it.entry)
+			c.mu.Unlock()
+			return entry, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return Entry{}, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || now.Sub(entry.Stored) >= c.ttl {
+		os.Remove(path)
+		return Entry{}, false
+	}
+
+	c.mu.Lock()
+	c.insert(key, entry)
+	c.mu.Unlock()
+	return copyEntry(entry), true
+}
+
+// Put caches a parsed page under key, writing it to disk as well when the
+// cache has a directory. A failed disk write leaves the page cached in
+// memory only.
+func (c *Cache) Put(key Key, results []engine.SearchResult, noResults bool) error {
+	entry := copyEntry(Entry{Results: results, NoResults: noResults, Stored: time.Now()})
+
+	c.mu.Lock()
+	c.insert(key, entry)
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached page: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so a reader never sees a
+	// partial page
+	path := c.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cached page: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cached page: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of pages held in memory
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// insert adds or replaces an entry, evicting the oldest past the bound;
+// the caller holds c.mu
+func (c *Cache) insert(key Key, entry Entry) {
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*item).entry = entry
+		c.order.MoveToBack(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushBack(&item{key: key, entry: entry})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*item).key)
+	}
+}
+
+// path returns the file a page is kept in on disk
+func (c *Cache) path(key Key) string {
+	sum := sha256.Sum256([]byte(key.String()))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func copyEntry(entry Entry) Entry {
+	entry.Results = append([]engine.SearchResult(nil), entry.Results...)
+	return entry
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"dorker/worker/internal/engine"
+)
+
+func TestCacheOff(t *testing.T) {
+	c, err := New(Config{})
+	if c != nil || err != nil {
+		t.Errorf("New without TTL = %v, %v, want nil", c, err)
+	}
+}
+
+func TestCacheGetPut(t *testing.T) {
+	c, err := New(Config{TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key{Engine: "google", Query: "inurl:admin", Domain: "www.google.com", ResultsPerPage: 100}
+	if _, ok := c.Get(key); ok {
+		t.Fatal("empty cache hit")
+	}
+
+	results := []engine.SearchResult{{URL: "https://a.example/"}}
+	c.Put(key, results, false)
+	results[0].URL = "changed"
+
+	entry, ok := c.Get(key)
+	if !ok || len(entry.Results) != 1 || entry.Results[0].URL != "https://a.example/" {
+		t.Fatalf("Get = %+v, %v", entry, ok)
+	}
+	entry.Results[0].URL = "changed"
+	if entry, _ := c.Get(key); entry.Results[0].URL != "https://a.example/" {
+		t.Error("cached results changed through a returned copy")
+	}
+
+	other := key
+	other.Page = 1
+	if _, ok := c.Get(other); ok {
+		t.Error("hit for another page")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, _ := New(Config{TTL: 20 * time.Millisecond})
+	key := Key{Engine: "google", Query: "q"}
+	c.Put(key, nil, true)
+
+	if entry, ok := c.Get(key); !ok || !entry.NoResults {
+		t.Fatalf("Get = %+v, %v", entry, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get(key); ok {
+		t.Error("expired page served")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after expiry, want 0", c.Len())
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	c, _ := New(Config{TTL: time.Minute, MaxEntries: 2})
+	for page := 0; page < 3; page++ {
+		c.Put(Key{Query: "q", Page: page}, nil, true)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if _, ok := c.Get(Key{Query: "q", Page: 0}); ok {
+		t.Error("oldest page not evicted")
+	}
+}
+
+func TestCacheDisk(t *testing.T) {
+	dir := t.TempDir()
+	key := Key{Engine: "google", Query: "inurl:admin"}
+
+	c, err := New(Config{TTL: time.Minute, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(key, []engine.SearchResult{{URL: "https://a.example/"}}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new cache over the directory, as after a restart
+	c, _ = New(Config{TTL: time.Minute, Dir: dir})
+	entry, ok := c.Get(key)
+	if !ok || len(entry.Results) != 1 || entry.Results[0].URL != "https://a.example/" {
+		t.Errorf("Get from disk = %+v, %v", entry, ok)
+	}
+}
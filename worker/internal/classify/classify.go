@@ -0,0 +1,122 @@
+package classify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Class is a verdict on what a fetched results page is
+type Class string
+
+const (
+	ClassResults      Class = "results"       // A results page with results
+	ClassEmpty        Class = "empty"         // A genuine page without results
+	ClassBlock        Class = "block"         // A block or CAPTCHA interstitial
+	ClassLayoutChange Class = "layout_change" // Results the parser does not recognize
+
+	// ClassUnknown is the heuristic verdict on a page the detectors could
+	// not settle; it is never a valid classifier answer
+	ClassUnknown Class = "unknown"
+)
+
+// valid reports whether c is one of the known classes
+func (c Class) valid() bool {
+	switch c {
+	case ClassResults, ClassEmpty, ClassBlock, ClassLayoutChange:
+		return true
+	}
+	return false
+}
+
+// Page is a fetched page the heuristics could not settle
+type Page struct {
+	Engine     string `json:"engine"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	HTML       string `json:"html"`
+
+	// Heuristic is what the built-in detectors concluded, used when the
+	// classifier fails
+	Heuristic Class `json:"heuristic"`
+}
+
+// Classifier decides what an ambiguous page is. Implementations may call
+// out to a service or run a model in process; errors make the caller fall
+// back to the heuristic verdict.
+type Classifier interface {
+	Classify(ctx context.Context, page *Page) (Class, error)
+}
+
+// Config describes the HTTP classifier; an empty URL disables it
+type Config struct {
+	URL     string        `json:"url"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// DefaultTimeout bounds a classifier call when Config.Timeout is unset
+const DefaultTimeout = 5 * time.Second
+
+// maxResponse bounds the classifier's reply
+const maxResponse = 64 * 1024
+
+// HTTP posts pages as JSON to an external endpoint, e.g. a model server,
+// which answers {"class": "<class>"}
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// New returns the HTTP classifier described by config, or nil when it has
+// no URL
+func New(config Config) Classifier {
+	if config.URL == "" {
+		return nil
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &HTTP{
+		url:    config.URL,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// Classify sends the page to the endpoint and returns its verdict
+func (h *HTTP) Classify(ctx context.Context, page *Page) (Class, error) {
+	body, err := json.Marshal(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create classifier request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("classifier request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("classifier returned status %d", resp.StatusCode)
+	}
+
+	var verdict struct {
+		Class Class `json:"class"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponse)).Decode(&verdict); err != nil {
+		return "", fmt.Errorf("invalid classifier response: %w", err)
+	}
+	if !verdict.Class.valid() {
+		return "", fmt.Errorf("unknown class %q", verdict.Class)
+	}
+	return verdict.Class, nil
+}
@@ -0,0 +1,61 @@
+package classify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPClassify(t *testing.T) {
+	var got Page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"class":"block","confidence":0.97}`))
+	}))
+	defer server.Close()
+
+	c := New(Config{URL: server.URL})
+	class, err := c.Classify(context.Background(), &Page{Engine: "google", StatusCode: 200, HTML: "<html></html>", Heuristic: ClassEmpty})
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if class != ClassBlock {
+		t.Errorf("class = %q, want %q", class, ClassBlock)
+	}
+	if got.HTML != "<html></html>" || got.Heuristic != ClassEmpty {
+		t.Errorf("endpoint received %+v", got)
+	}
+}
+
+func TestHTTPClassifyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, `{"class":"block"}`},
+		{"unknown class", http.StatusOK, `{"class":"spam"}`},
+		{"not json", http.StatusOK, `block`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			if class, err := New(Config{URL: server.URL}).Classify(context.Background(), &Page{}); err == nil {
+				t.Errorf("Classify = %q, want an error", class)
+			}
+		})
+	}
+}
+
+func TestNewWithoutURL(t *testing.T) {
+	if c := New(Config{}); c != nil {
+		t.Errorf("New without a URL = %v, want nil", c)
+	}
+}
@@ -0,0 +1,152 @@
+package cost
+
+import (
+	"strings"
+	"sync"
+)
+
+// bytesPerGB converts transferred bytes to billed gigabytes
+const bytesPerGB = 1 << 30
+
+// Rate prices traffic as a flat amount per request plus an amount per GB
+// transferred. Amounts are in whatever currency the user bills in.
+type Rate struct {
+	PerRequest float64 `json:"per_request,omitempty"`
+	PerGB      float64 `json:"per_gb,omitempty"`
+}
+
+// Cost returns the price of one request that transferred bytes
+func (r Rate) Cost(bytes int64) float64 {
+	return r.PerRequest + r.PerGB*float64(bytes)/bytesPerGB
+}
+
+// Model holds the rates for proxy groups and engines.
+//
+// Proxy groups are matched against a proxy by, in order: its ID, its host, the
+// longest "*.domain" pattern covering the host, then "*". Engine rates are
+// keyed by engine name and suit API engines billed per query.
+type Model struct {
+	Proxies map[string]Rate `json:"proxies,omitempty"`
+	Engines map[string]Rate `json:"engines,omitempty"`
+}
+
+// Empty reports whether the model prices nothing
+func (m Model) Empty() bool {
+	return len(m.Proxies) == 0 && len(m.Engines) == 0
+}
+
+// proxyGroup returns the group key pricing a proxy, or "" when none does
+func (m Model) proxyGroup(id, host string) string {
+	if _, ok := m.Proxies[id]; ok && id != "" {
+		return id
+	}
+	if _, ok := m.Proxies[host]; ok && host != "" {
+		return host
+	}
+
+	best := ""
+	for pattern := range m.Proxies {
+		suffix, ok := strings.CutPrefix(pattern, "*.")
+		if !ok {
+			continue
+		}
+		if (host == suffix || strings.HasSuffix(host, "."+suffix)) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if _, ok := m.Proxies["*"]; ok {
+		return "*"
+	}
+	return ""
+}
+
+// Summary is the estimated spend so far
+type Summary struct {
+	Total   float64            `json:"total"`
+	Bytes   int64              `json:"bytes"`
+	Proxies map[string]float64 `json:"proxies,omitempty"` // By proxy group
+	Engines map[string]float64 `json:"engines,omitempty"`
+}
+
+// Meter accumulates estimated spend under a model. It is safe for
+// concurrent use.
+type Meter struct {
+	model Model
+
+	mu      sync.Mutex
+	total   float64
+	bytes   int64
+	proxies map[string]float64
+	engines map[string]float64
+}
+
+// NewMeter creates a meter pricing requests with model
+func NewMeter(model Model) *Meter {
+	return &Meter{
+		model:   model,
+		proxies: make(map[string]float64),
+		engines: make(map[string]float64),
+	}
+}
+
+// ChargeProxy records a request sent through a proxy that transferred bytes
+func (m *Meter) ChargeProxy(id, host string, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bytes += bytes
+	group := m.model.proxyGroup(id, host)
+	if group == "" {
+		return
+	}
+
+	amount := m.model.Proxies[group].Cost(bytes)
+	m.proxies[group] += amount
+	m.total += amount
+}
+
+// ChargeEngine records a query sent to an engine
+func (m *Meter) ChargeEngine(engine string) {
+	rate, ok := m.model.Engines[engine]
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	amount := rate.Cost(0)
+	m.engines[engine] += amount
+	m.total += amount
+}
+
+// Total returns the estimated spend so far
+func (m *Meter) Total() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// Summary returns the estimated spend so far with its breakdown
+func (m *Meter) Summary() Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary := Summary{
+		Total:   m.total,
+		Bytes:   m.bytes,
+		Proxies: make(map[string]float64, len(m.proxies)),
+		Engines: make(map[string]float64, len(m.engines)),
+	}
+	for group, amount := range m.proxies {
+		summary.Proxies[group] = amount
+	}
+	for engine, amount := range m.engines {
+		summary.Engines[engine] = amount
+	}
+	return summary
+}
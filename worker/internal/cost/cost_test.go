@@ -0,0 +1,96 @@
+package cost
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRateCost(t *testing.T) {
+	rate := Rate{PerRequest: 0.001, PerGB: 4}
+
+	if got := rate.Cost(0); !approx(got, 0.001) {
+		t.Errorf("Cost(0) = %v, want 0.001", got)
+	}
+	if got := rate.Cost(bytesPerGB / 2); !approx(got, 2.001) {
+		t.Errorf("Cost(0.5GB) = %v, want 2.001", got)
+	}
+}
+
+func TestModelProxyGroup(t *testing.T) {
+	model := Model{Proxies: map[string]Rate{
+		"proxy_7":           {},
+		"10.0.0.1":          {},
+		"*.provider.com":    {},
+		"*.eu.provider.com": {},
+		"*":                 {},
+	}}
+
+	tests := []struct {
+		id, host, want string
+	}{
+		{"proxy_7", "10.0.0.1", "proxy_7"},
+		{"other", "10.0.0.1", "10.0.0.1"},
+		{"other", "gw.provider.com", "*.provider.com"},
+		{"other", "provider.com", "*.provider.com"},
+		{"other", "gw.eu.provider.com", "*.eu.provider.com"},
+		{"other", "notprovider.com", "*"},
+	}
+	for _, tt := range tests {
+		if got := model.proxyGroup(tt.id, tt.host); got != tt.want {
+			t.Errorf("proxyGroup(%q, %q) = %q, want %q", tt.id, tt.host, got, tt.want)
+		}
+	}
+
+	if got := (Model{}).proxyGroup("x", "y"); got != "" {
+		t.Errorf("empty model group = %q, want none", got)
+	}
+}
+
+func TestMeter(t *testing.T) {
+	meter := NewMeter(Model{
+		Proxies: map[string]Rate{"*.provider.com": {PerGB: 8}},
+		Engines: map[string]Rate{"google_cse": {PerRequest: 0.005}},
+	})
+
+	meter.ChargeProxy("p1", "gw.provider.com", bytesPerGB/4)
+	meter.ChargeProxy("p2", "free.example.org", bytesPerGB)
+	meter.ChargeEngine("google_cse")
+	meter.ChargeEngine("google")
+
+	summary := meter.Summary()
+	if !approx(summary.Total, 2.005) || !approx(meter.Total(), 2.005) {
+		t.Errorf("Total = %v, want 2.005", summary.Total)
+	}
+	if summary.Bytes != bytesPerGB+bytesPerGB/4 {
+		t.Errorf("Bytes = %d", summary.Bytes)
+	}
+	if !approx(summary.Proxies["*.provider.com"], 2) || len(summary.Proxies) != 1 {
+		t.Errorf("Proxies = %v", summary.Proxies)
+	}
+	if !approx(summary.Engines["google_cse"], 0.005) || len(summary.Engines) != 1 {
+		t.Errorf("Engines = %v", summary.Engines)
+	}
+}
+
+func TestMeterConcurrent(t *testing.T) {
+	meter := NewMeter(Model{Proxies: map[string]Rate{"*": {PerRequest: 1}}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			meter.ChargeProxy("p", "h", 10)
+		}()
+	}
+	wg.Wait()
+
+	if meter.Total() != 50 {
+		t.Errorf("Total = %v, want 50", meter.Total())
+	}
+}
@@ -0,0 +1,103 @@
+package crashdump
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Bundle is the diagnostic state gathered when the worker dies
+type Bundle struct {
+	Version string
+	Reason  string
+	Message string
+
+	// PanicStack is the stack of the goroutine that panicked, if any
+	PanicStack []byte
+
+	// Sections are written as <name>.json, e.g. recent protocol messages,
+	// pool stats and the effective configuration. Callers redact secrets
+	// before adding them.
+	Sections map[string]any
+}
+
+// Write stores the bundle, together with the stacks of every goroutine, as
+// dorker-crash-<time>-<pid>.zip in dir (the system temp directory when
+// empty) and returns the file's path
+func Write(dir string, bundle Bundle) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create crash dump directory: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("dorker-crash-%s-%d.zip", now.Format("20060102-150405"), os.Getpid())
+	path := filepath.Join(dir, name)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create crash dump: %w", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	add := func(name string, data []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	summary := fmt.Sprintf("time: %s\nversion: %s\ngo: %s %s/%s\npid: %d\ngoroutines: %d\nreason: %s\nmessage: %s\n",
+		now.Format(time.RFC3339), bundle.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH,
+		os.Getpid(), runtime.NumGoroutine(), bundle.Reason, bundle.Message)
+	if err := add("summary.txt", []byte(summary)); err != nil {
+		return "", fmt.Errorf("failed to write crash dump: %w", err)
+	}
+
+	if len(bundle.PanicStack) > 0 {
+		if err := add("panic.txt", bundle.PanicStack); err != nil {
+			return "", fmt.Errorf("failed to write crash dump: %w", err)
+		}
+	}
+
+	if err := add("goroutines.txt", allStacks()); err != nil {
+		return "", fmt.Errorf("failed to write crash dump: %w", err)
+	}
+
+	for name, section := range bundle.Sections {
+		data, err := json.MarshalIndent(section, "", "  ")
+		if err != nil {
+			data = []byte(fmt.Sprintf("%q", err.Error()))
+		}
+		if err := add(name+".json", data); err != nil {
+			return "", fmt.Errorf("failed to write crash dump: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write crash dump: %w", err)
+	}
+	return path, nil
+}
+
+// allStacks returns the stack traces of every goroutine, growing the buffer
+// until they fit
+func allStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 16<<20 {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,54 @@
+package crashdump
+
+import (
+	"archive/zip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Write(dir, Bundle{
+		Version:    "1.2.3",
+		Reason:     "panic",
+		Message:    "panic: boom",
+		PanicStack: []byte("goroutine 7 [running]:\nmain.crash()"),
+		Sections: map[string]any{
+			"pool": map[string]int{"alive": 3},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.HasPrefix(path, dir) || !strings.HasSuffix(path, ".zip") {
+		t.Errorf("path = %q", path)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	if !strings.Contains(files["summary.txt"], "reason: panic") || !strings.Contains(files["summary.txt"], "version: 1.2.3") {
+		t.Errorf("summary.txt = %q", files["summary.txt"])
+	}
+	if !strings.Contains(files["panic.txt"], "main.crash") {
+		t.Errorf("panic.txt = %q", files["panic.txt"])
+	}
+	if !strings.Contains(files["goroutines.txt"], "TestWrite") {
+		t.Error("goroutines.txt does not hold the running goroutines")
+	}
+	if !strings.Contains(files["pool.json"], `"alive": 3`) {
+		t.Errorf("pool.json = %q", files["pool.json"])
+	}
+}
@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+)
+
+// RedirectOutput appends stderr, and optionally stdout, to a log file. In IPC
+// mode stdout carries the protocol and must be left alone.
+func RedirectOutput(path string, includeStdout bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	if err := redirectFD(f, os.Stderr); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to redirect stderr: %w", err)
+	}
+	os.Stderr = f
+
+	if includeStdout {
+		if err := redirectFD(f, os.Stdout); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to redirect stdout: %w", err)
+		}
+		os.Stdout = f
+	}
+
+	return f, nil
+}
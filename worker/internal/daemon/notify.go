@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// systemd notification states
+const (
+	NotifyReady     = "READY=1"
+	NotifyReloading = "RELOADING=1"
+	NotifyStopping  = "STOPPING=1"
+)
+
+// Notify sends a state update to systemd when running under a Type=notify
+// unit. It is a no-op when NOTIFY_SOCKET is not set.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// A leading @ denotes an abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// NotifyStatus sends a free-form status line shown by systemctl status
+func NotifyStatus(status string) error {
+	return Notify("STATUS=" + status)
+}
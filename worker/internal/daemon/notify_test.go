@@ -0,0 +1,43 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram not available: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	if err := Notify(NotifyReady); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if got := string(buf[:n]); got != NotifyReady {
+		t.Errorf("received %q, want %q", got, NotifyReady)
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	if err := Notify(NotifyReady); err != nil {
+		t.Errorf("Notify without socket should be a no-op: %v", err)
+	}
+}
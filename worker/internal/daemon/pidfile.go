@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WritePIDFile records the current process ID. It refuses to overwrite a
+// PID file that belongs to a process that is still running.
+func WritePIDFile(path string) error {
+	if pid, err := ReadPIDFile(path); err == nil && pid != os.Getpid() && processAlive(pid) {
+		return fmt.Errorf("pid file %s: process %d is still running", path, pid)
+	}
+
+	data := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pid file: %w", err)
+	}
+	return nil
+}
+
+// ReadPIDFile returns the process ID stored in a PID file
+func ReadPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid file %s: %w", path, err)
+	}
+	return pid, nil
+}
+
+// RemovePIDFile deletes the PID file if it still belongs to this process
+func RemovePIDFile(path string) error {
+	pid, err := ReadPIDFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if pid != os.Getpid() {
+		return nil
+	}
+	return os.Remove(path)
+}
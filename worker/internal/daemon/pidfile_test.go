@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestWritePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.pid")
+
+	if err := WritePIDFile(path); err != nil {
+		t.Fatalf("WritePIDFile failed: %v", err)
+	}
+
+	pid, err := ReadPIDFile(path)
+	if err != nil {
+		t.Fatalf("ReadPIDFile failed: %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("pid = %d, want %d", pid, os.Getpid())
+	}
+
+	// Rewriting our own PID file is allowed
+	if err := WritePIDFile(path); err != nil {
+		t.Errorf("rewriting own pid file failed: %v", err)
+	}
+}
+
+func TestWritePIDFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.pid")
+
+	// A PID that cannot belong to a live process
+	if err := os.WriteFile(path, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WritePIDFile(path); err != nil {
+		t.Fatalf("stale pid file should be replaced: %v", err)
+	}
+}
+
+func TestWritePIDFileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.pid")
+
+	// The parent process is alive for the duration of the test
+	ppid := os.Getppid()
+	if ppid <= 1 {
+		t.Skip("no usable parent process")
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(ppid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WritePIDFile(path); err == nil {
+		t.Error("expected error for pid file owned by running process")
+	}
+}
+
+func TestReadPIDFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.pid")
+	if err := os.WriteFile(path, []byte("not a pid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadPIDFile(path); err == nil {
+		t.Error("expected error for invalid pid file")
+	}
+}
+
+func TestRemovePIDFile(t *testing.T) {
+	dir := t.TempDir()
+
+	own := filepath.Join(dir, "own.pid")
+	if err := WritePIDFile(own); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemovePIDFile(own); err != nil {
+		t.Fatalf("RemovePIDFile failed: %v", err)
+	}
+	if _, err := os.Stat(own); !os.IsNotExist(err) {
+		t.Error("own pid file should be removed")
+	}
+
+	// A PID file taken over by another process is left alone
+	other := filepath.Join(dir, "other.pid")
+	if err := os.WriteFile(other, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemovePIDFile(other); err != nil {
+		t.Fatalf("RemovePIDFile failed: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("foreign pid file should be kept")
+	}
+
+	// Missing files are not an error
+	if err := RemovePIDFile(filepath.Join(dir, "missing.pid")); err != nil {
+		t.Errorf("missing pid file: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procSetStdHandle = kernel32.NewProc("SetStdHandle")
+)
+
+// processAlive reports whether a process with the given PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// redirectFD points a standard handle at f
+func redirectFD(f *os.File, std *os.File) error {
+	handle := syscall.STD_ERROR_HANDLE
+	if std == os.Stdout {
+		handle = syscall.STD_OUTPUT_HANDLE
+	}
+	r, _, err := procSetStdHandle.Call(uintptr(handle), f.Fd())
+	if r == 0 {
+		return err
+	}
+	return nil
+}
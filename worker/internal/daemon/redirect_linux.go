@@ -0,0 +1,13 @@
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirectFD points a standard file descriptor at f so that output written
+// directly to the descriptor, such as runtime panics, also lands in f.
+// Dup3 is used because linux/arm64 has no dup2.
+func redirectFD(f *os.File, std *os.File) error {
+	return syscall.Dup3(int(f.Fd()), int(std.Fd()), 0)
+}
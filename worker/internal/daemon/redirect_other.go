@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirectFD points a standard file descriptor at f so that output written
+// directly to the descriptor, such as runtime panics, also lands in f
+func redirectFD(f *os.File, std *os.File) error {
+	return syscall.Dup2(int(f.Fd()), int(std.Fd()))
+}
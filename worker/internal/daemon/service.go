@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ServiceConfig describes how the worker is registered as a system service
+type ServiceConfig struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Executable  string   `json:"executable"`
+	Args        []string `json:"args"`
+}
+
+// DefaultServiceConfig returns defaults for the given executable
+func DefaultServiceConfig(executable string) ServiceConfig {
+	return ServiceConfig{
+		Name:        "dorker-worker",
+		Description: "Dorker search worker",
+		Executable:  executable,
+	}
+}
+
+// SystemdUnitDir is where InstallService writes unit files on Linux
+var SystemdUnitDir = "/etc/systemd/system"
+
+// SystemdUnitPath returns the unit file path for a service name
+func SystemdUnitPath(name string) string {
+	return path.Join(SystemdUnitDir, name+".service")
+}
+
+// SystemdUnit renders a Type=notify unit for the service
+func SystemdUnit(config ServiceConfig) string {
+	command := quoteSystemdArg(config.Executable)
+	for _, arg := range config.Args {
+		command += " " + quoteSystemdArg(arg)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", config.Description)
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "Wants=network-online.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=notify\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", command)
+	fmt.Fprintf(&b, "ExecReload=/bin/kill -HUP $MAINPID\n")
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=10\n")
+	fmt.Fprintf(&b, "TimeoutStopSec=45\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// quoteSystemdArg quotes an ExecStart argument when it contains spaces
+func quoteSystemdArg(arg string) string {
+	if !strings.ContainsAny(arg, " \t\"\\") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	config := DefaultServiceConfig("/opt/dorker/worker")
+	config.Args = []string{"--standalone", "--dorks=/srv/my dorks.txt"}
+
+	unit := SystemdUnit(config)
+
+	for _, want := range []string{
+		"Description=Dorker search worker",
+		"Type=notify",
+		`ExecStart=/opt/dorker/worker --standalone "--dorks=/srv/my dorks.txt"`,
+		"ExecReload=/bin/kill -HUP $MAINPID",
+		"Restart=on-failure",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdUnitPath(t *testing.T) {
+	if got := SystemdUnitPath("dorker-worker"); got != "/etc/systemd/system/dorker-worker.service" {
+		t.Errorf("SystemdUnitPath = %q", got)
+	}
+}
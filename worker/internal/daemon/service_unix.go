@@ -0,0 +1,44 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// InstallService writes a systemd unit for the worker. Run
+// `systemctl daemon-reload && systemctl enable --now <name>` afterwards.
+func InstallService(config ServiceConfig) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("service install is not supported on %s", runtime.GOOS)
+	}
+
+	path := SystemdUnitPath(config.Name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("service %s already installed at %s", config.Name, path)
+	}
+
+	if err := os.WriteFile(path, []byte(SystemdUnit(config)), 0644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+	return nil
+}
+
+// UninstallService removes the systemd unit for the worker
+func UninstallService(name string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("service uninstall is not supported on %s", runtime.GOOS)
+	}
+
+	if err := os.Remove(SystemdUnitPath(name)); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	return nil
+}
+
+// RunService is only meaningful on Windows; systemd runs the worker directly
+func RunService(name string, run func(stop <-chan struct{})) error {
+	return fmt.Errorf("service run is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,172 @@
+package daemon
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Service control manager constants, from winsvc.h
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop     = 0x1
+	serviceAcceptShutdown = 0x4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	stopWaitHintMs = 45000
+)
+
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+// serviceTableEntry mirrors SERVICE_TABLE_ENTRYW
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// serviceStatus mirrors SERVICE_STATUS
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// The dispatcher calls back into package-level state; a process hosts at
+// most one service.
+var service struct {
+	mu       sync.Mutex
+	run      func(stop <-chan struct{})
+	handle   uintptr
+	stop     chan struct{}
+	stopOnce sync.Once
+	state    uint32
+}
+
+// InstallService registers the worker with the Windows service manager. The
+// service runs the executable with the configured arguments.
+func InstallService(config ServiceConfig) error {
+	binPath := syscall.EscapeArg(config.Executable)
+	for _, arg := range config.Args {
+		binPath += " " + syscall.EscapeArg(arg)
+	}
+
+	if err := sc("create", config.Name, "binPath=", binPath, "start=", "auto", "DisplayName=", config.Description); err != nil {
+		return err
+	}
+	return sc("description", config.Name, config.Description)
+}
+
+// UninstallService removes the worker from the Windows service manager
+func UninstallService(name string) error {
+	return sc("delete", name)
+}
+
+// sc runs the service control utility
+func sc(args ...string) error {
+	out, err := exec.Command("sc.exe", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sc %s failed: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RunService hands the process to the Windows service manager and calls run
+// once the service starts. The stop channel is closed when the service is
+// asked to stop or the machine shuts down. RunService returns after run does.
+func RunService(name string, run func(stop <-chan struct{})) error {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	service.mu.Lock()
+	service.run = run
+	service.stop = make(chan struct{})
+	service.mu.Unlock()
+
+	table := []serviceTableEntry{
+		{name: namePtr, proc: syscall.NewCallback(serviceMain)},
+		{},
+	}
+	r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+	if r == 0 {
+		return fmt.Errorf("failed to start service dispatcher: %w", err)
+	}
+	return nil
+}
+
+// serviceMain is the ServiceMain entry point invoked by the dispatcher
+func serviceMain(argc uint32, argv **uint16) uintptr {
+	handle, _, _ := procRegisterServiceCtrlHandlerExW.Call(
+		uintptr(unsafe.Pointer(*argv)), // argv[0] is the service name
+		syscall.NewCallback(serviceCtrlHandler),
+		0,
+	)
+	if handle == 0 {
+		return 0
+	}
+
+	service.mu.Lock()
+	service.handle = handle
+	run, stop := service.run, service.stop
+	service.mu.Unlock()
+
+	setServiceState(serviceStartPending, 0)
+	setServiceState(serviceRunning, serviceAcceptStop|serviceAcceptShutdown)
+	run(stop)
+	setServiceState(serviceStopped, 0)
+	return 0
+}
+
+// serviceCtrlHandler receives control requests from the service manager
+func serviceCtrlHandler(control, eventType uint32, eventData, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceState(serviceStopPending, 0)
+		service.stopOnce.Do(func() { close(service.stop) })
+	case serviceControlInterrogate:
+		service.mu.Lock()
+		state := service.state
+		service.mu.Unlock()
+		setServiceState(state, serviceAcceptStop|serviceAcceptShutdown)
+	}
+	return 0
+}
+
+// setServiceState reports the current state to the service manager
+func setServiceState(state, accepts uint32) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	service.state = state
+	status := serviceStatus{
+		serviceType:      serviceWin32OwnProcess,
+		currentState:     state,
+		controlsAccepted: accepts,
+	}
+	if state == serviceStopPending {
+		status.waitHint = stopWaitHintMs
+	}
+	procSetServiceStatus.Call(service.handle, uintptr(unsafe.Pointer(&status)))
+}
@@ -0,0 +1,81 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Bloom is a Bloom filter sized for a capacity and false-positive rate. It is
+// not safe for concurrent use.
+type Bloom struct {
+	bits   []uint64
+	m      uint64 // Number of bits
+	hashes int
+}
+
+// NewBloom creates a filter holding capacity entries at false-positive rate
+// fp: m = -n·ln(p)/ln(2)² bits and k = m/n·ln(2) hash functions
+func NewBloom(capacity int, fp float64) *Bloom {
+	n := float64(capacity)
+	m := math.Ceil(-n * math.Log(fp) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / n * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	words := (uint64(m) + 63) / 64
+	return &Bloom{
+		bits:   make([]uint64, words),
+		m:      words * 64,
+		hashes: k,
+	}
+}
+
+// Add sets the bits for s and reports whether they were all set already,
+// meaning s was possibly added before
+func (b *Bloom) Add(s string) bool {
+	h1, h2 := bloomHashes(s)
+	present := true
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.bits[word]&mask == 0 {
+			present = false
+			b.bits[word] |= mask
+		}
+	}
+	return present
+}
+
+// Test reports whether s was possibly added
+func (b *Bloom) Test(s string) bool {
+	h1, h2 := bloomHashes(s)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		if b.bits[bit/64]&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeBytes returns the memory used by the filter bits
+func (b *Bloom) SizeBytes() int {
+	return len(b.bits) * 8
+}
+
+// bloomHashes derives the two hashes combined as h1 + i·h2 for the i-th
+// hash function. h2 is odd so it never degenerates to a single bit.
+func bloomHashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+
+	// splitmix64 finalizer decorrelates the second hash from the first
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+
+	return h1, h2 | 1
+}
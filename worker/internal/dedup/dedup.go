@@ -0,0 +1,170 @@
+package dedup
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Mode selects how a Set remembers what it has seen
+type Mode string
+
+const (
+	ModeOff   Mode = "off"   // Keep everything
+	ModeExact Mode = "exact" // In-memory map; exact, memory grows with every URL
+	ModeBloom Mode = "bloom" // Bloom filter; fixed memory, optional exact verification
+)
+
+// Config configures a Set
+type Config struct {
+	Mode Mode `json:"mode"`
+
+	// Granularity is the default for tasks that set none; empty is
+	// GranularityURL
+	Granularity Granularity `json:"granularity,omitempty"`
+
+	// Bloom mode sizing: the filter holds Capacity entries at roughly
+	// FalsePositiveRate before it starts to overfill
+	Capacity          int     `json:"capacity"`
+	FalsePositiveRate float64 `json:"fp_rate"`
+
+	// SpillPath, when set in bloom mode, keeps every admitted entry on disk
+	// so filter hits are verified exactly instead of dropped
+	SpillPath string `json:"spill_path,omitempty"`
+}
+
+// DefaultConfig returns dedup off with bloom sizing for a 10M URL run
+func DefaultConfig() Config {
+	return Config{
+		Mode:              ModeOff,
+		Capacity:          10_000_000,
+		FalsePositiveRate: 0.001,
+	}
+}
+
+// Set reports whether entries are new. Implementations are safe for
+// concurrent use.
+type Set interface {
+	// Add records s and reports whether it was not seen before
+	Add(s string) bool
+
+	// Len returns how many distinct entries have been added
+	Len() int
+
+	// Close releases any files held by the set
+	Close() error
+}
+
+// New creates a set for config; nil when dedup is off
+func New(config Config) (Set, error) {
+	defaults := DefaultConfig()
+	if config.Capacity <= 0 {
+		config.Capacity = defaults.Capacity
+	}
+	if config.FalsePositiveRate <= 0 || config.FalsePositiveRate >= 1 {
+		config.FalsePositiveRate = defaults.FalsePositiveRate
+	}
+
+	if !config.Granularity.Valid() {
+		return nil, fmt.Errorf("unknown dedup granularity %q (want url, params, path or domain)", config.Granularity)
+	}
+
+	switch config.Mode {
+	case ModeOff, "":
+		return nil, nil
+	case ModeExact:
+		return newExactSet(), nil
+	case ModeBloom:
+		return newBloomSet(config)
+	}
+	return nil, fmt.Errorf("unknown dedup mode %q (want off, exact or bloom)", config.Mode)
+}
+
+// exactSet remembers every entry in a map
+type exactSet struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newExactSet() *exactSet {
+	return &exactSet{seen: make(map[string]struct{})}
+}
+
+func (s *exactSet) Add(entry string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[entry]; ok {
+		return false
+	}
+	s.seen[entry] = struct{}{}
+	return true
+}
+
+func (s *exactSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.seen)
+}
+
+func (s *exactSet) Close() error {
+	return nil
+}
+
+// bloomSet screens entries with a Bloom filter. Without a spill file a filter
+// hit counts as a duplicate, so about FalsePositiveRate of new entries are
+// lost; with one, hits are checked against the entries on disk.
+type bloomSet struct {
+	mu     sync.Mutex
+	filter *Bloom
+	spill  *spill
+	count  int
+}
+
+func newBloomSet(config Config) (*bloomSet, error) {
+	set := &bloomSet{filter: NewBloom(config.Capacity, config.FalsePositiveRate)}
+	if config.SpillPath != "" {
+		sp, err := openSpill(config.SpillPath)
+		if err != nil {
+			return nil, err
+		}
+		set.spill = sp
+	}
+	return set, nil
+}
+
+func (s *bloomSet) Add(entry string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.filter.Add(entry) {
+		// Possibly seen; only the spill file can tell for sure
+		if s.spill == nil {
+			return false
+		}
+		found, err := s.spill.Contains(entry)
+		if err != nil || found {
+			return false
+		}
+	}
+
+	if s.spill != nil {
+		// An unrecorded entry would later be dropped as a false positive,
+		// which is the same outcome as running without a spill file
+		s.spill.Append(entry)
+	}
+	s.count++
+	return true
+}
+
+func (s *bloomSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+func (s *bloomSet) Close() error {
+	if s.spill == nil {
+		return nil
+	}
+	return s.spill.Close()
+}
@@ -0,0 +1,194 @@
+package dedup
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewOff(t *testing.T) {
+	for _, mode := range []Mode{"", ModeOff} {
+		set, err := New(Config{Mode: mode})
+		if err != nil || set != nil {
+			t.Errorf("New(%q) = %v, %v; want nil, nil", mode, set, err)
+		}
+	}
+
+	if _, err := New(Config{Mode: "fuzzy"}); err == nil {
+		t.Error("New should reject an unknown mode")
+	}
+}
+
+func TestExactSet(t *testing.T) {
+	set, _ := New(Config{Mode: ModeExact})
+	defer set.Close()
+
+	if !set.Add("https://a.example/") || !set.Add("https://b.example/") {
+		t.Error("first adds should be new")
+	}
+	if set.Add("https://a.example/") {
+		t.Error("repeat add should not be new")
+	}
+	if set.Len() != 2 {
+		t.Errorf("Len = %d, want 2", set.Len())
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	const n = 20000
+	b := NewBloom(n, 0.01)
+
+	for i := 0; i < n; i++ {
+		if b.Add(fmt.Sprintf("https://in.example/%d", i)) && i < 100 {
+			t.Errorf("early entry %d reported present", i)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if !b.Test(fmt.Sprintf("https://in.example/%d", i)) {
+			t.Fatalf("entry %d missing: Bloom filters have no false negatives", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if b.Test(fmt.Sprintf("https://out.example/%d", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / n; rate > 0.02 {
+		t.Errorf("false positive rate = %.4f, want about 0.01", rate)
+	}
+
+	// About 9.6 bits per entry at 1%
+	if size := b.SizeBytes(); size > n*2 {
+		t.Errorf("filter uses %d bytes for %d entries", size, n)
+	}
+}
+
+func TestBloomSetWithoutSpill(t *testing.T) {
+	set, err := New(Config{Mode: ModeBloom, Capacity: 1000, FalsePositiveRate: 0.001})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer set.Close()
+
+	if !set.Add("https://a.example/") {
+		t.Error("first add should be new")
+	}
+	if set.Add("https://a.example/") {
+		t.Error("repeat add should not be new")
+	}
+}
+
+func TestBloomSetSpillVerifies(t *testing.T) {
+	// An overfilled filter reports nearly everything as present; the spill
+	// file must still tell new entries from repeats
+	path := filepath.Join(t.TempDir(), "dedup.spill")
+	set, err := New(Config{Mode: ModeBloom, Capacity: 8, FalsePositiveRate: 0.5, SpillPath: path})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer set.Close()
+
+	const n = 500
+	for i := 0; i < n; i++ {
+		if !set.Add(fmt.Sprintf("https://site.example/%d", i)) {
+			t.Fatalf("entry %d dropped as a duplicate", i)
+		}
+	}
+	for i := 0; i < n; i += 50 {
+		if set.Add(fmt.Sprintf("https://site.example/%d", i)) {
+			t.Errorf("repeat of entry %d accepted", i)
+		}
+	}
+	if set.Len() != n {
+		t.Errorf("Len = %d, want %d", set.Len(), n)
+	}
+}
+
+func TestSetsConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.spill")
+	configs := []Config{
+		{Mode: ModeExact},
+		{Mode: ModeBloom, Capacity: 1000, SpillPath: path},
+	}
+
+	for _, config := range configs {
+		set, err := New(config)
+		if err != nil {
+			t.Fatalf("New(%s): %v", config.Mode, err)
+		}
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					set.Add(fmt.Sprintf("https://c.example/%d", i))
+				}
+			}()
+		}
+		wg.Wait()
+
+		if set.Len() != 100 {
+			t.Errorf("%s: Len = %d, want 100", config.Mode, set.Len())
+		}
+		set.Close()
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	dir := t.TempDir()
+	configs := map[string]Config{
+		"exact":       {Mode: ModeExact},
+		"bloom":       {Mode: ModeBloom, Capacity: 1000, FalsePositiveRate: 0.01},
+		"bloom spill": {Mode: ModeBloom, Capacity: 1000, FalsePositiveRate: 0.01, SpillPath: filepath.Join(dir, "spill")},
+	}
+
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			set, err := New(config)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer set.Close()
+			for i := 0; i < 100; i++ {
+				set.Add(fmt.Sprintf("https://seen.example/%d", i))
+			}
+
+			var buf bytes.Buffer
+			if err := Export(set, &buf); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			if config.SpillPath != "" {
+				config.SpillPath += ".imported"
+			}
+			imported, err := Import(config, &buf)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			defer imported.Close()
+
+			if imported.Len() != 100 {
+				t.Errorf("Len = %d after import, want 100", imported.Len())
+			}
+			for i := 0; i < 100; i++ {
+				if imported.Add(fmt.Sprintf("https://seen.example/%d", i)) {
+					t.Fatalf("entry %d is new after import", i)
+				}
+			}
+			if !imported.Add("https://new.example/") {
+				t.Error("an unseen entry should still be new")
+			}
+		})
+	}
+
+	if _, err := Import(Config{}, strings.NewReader("fuzzy 1\n")); err == nil {
+		t.Error("Import should reject an unknown export")
+	}
+}
@@ -0,0 +1,117 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Export writes the state of set so Import can rebuild it, for example on
+// another machine: the entries of an exact set, or the filter of a Bloom
+// set followed by its spilled entries when it has a spill file
+func Export(set Set, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	switch s := set.(type) {
+	case *exactSet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		fmt.Fprintf(bw, "%s %d\n", ModeExact, len(s.seen))
+		for entry := range s.seen {
+			bw.WriteString(strings.ReplaceAll(entry, "\n", " ") + "\n")
+		}
+
+	case *bloomSet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		fmt.Fprintf(bw, "%s %d %d %d %t\n", ModeBloom, len(s.filter.bits), s.filter.hashes, s.count, s.spill != nil)
+		if err := binary.Write(bw, binary.LittleEndian, s.filter.bits); err != nil {
+			return err
+		}
+		if s.spill != nil {
+			if err := s.spill.copyEntries(bw); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("dedup set %T cannot be exported", set)
+	}
+
+	return bw.Flush()
+}
+
+// Import rebuilds a set written by Export. The mode comes from the export;
+// config supplies the spill path. A Bloom set keeps the exported filter's
+// size whatever config's sizing.
+func Import(config Config, r io.Reader) (Set, error) {
+	br := bufio.NewReader(r)
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dedup export: %w", err)
+	}
+
+	var mode Mode
+	fields := strings.Fields(header)
+	if len(fields) > 0 {
+		mode = Mode(fields[0])
+	}
+
+	switch mode {
+	case ModeExact:
+		set := newExactSet()
+		if err := readEntries(br, func(entry string) { set.seen[entry] = struct{}{} }); err != nil {
+			return nil, err
+		}
+		return set, nil
+
+	case ModeBloom:
+		var words, hashes, count int
+		var spilled bool
+		if _, err := fmt.Sscanf(header, "bloom %d %d %d %t", &words, &hashes, &count, &spilled); err != nil || words <= 0 || hashes <= 0 {
+			return nil, fmt.Errorf("invalid dedup export header %q", strings.TrimSpace(header))
+		}
+
+		config.Mode = ModeBloom
+		set, err := newBloomSet(config)
+		if err != nil {
+			return nil, err
+		}
+		bits := make([]uint64, words)
+		if err := binary.Read(br, binary.LittleEndian, bits); err != nil {
+			set.Close()
+			return nil, fmt.Errorf("failed to read dedup filter: %w", err)
+		}
+		set.filter = &Bloom{bits: bits, m: uint64(words) * 64, hashes: hashes}
+		set.count = count
+
+		// Entries seen before the export stay verifiable only if both
+		// sides keep a spill file
+		if spilled && set.spill != nil {
+			if err := readEntries(br, func(entry string) { set.spill.Append(entry) }); err != nil {
+				set.Close()
+				return nil, err
+			}
+		}
+		return set, nil
+	}
+
+	return nil, fmt.Errorf("invalid dedup export header %q", strings.TrimSpace(header))
+}
+
+// readEntries calls add for each line until EOF
+func readEntries(r io.Reader, add func(entry string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpillLine)
+	for scanner.Scan() {
+		add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dedup entries: %w", err)
+	}
+	return nil
+}
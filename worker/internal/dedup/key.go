@@ -0,0 +1,61 @@
+package dedup
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Granularity selects which parts of a URL make it unique
+type Granularity string
+
+const (
+	GranularityURL    Granularity = "url"    // The whole URL, query values included
+	GranularityParams Granularity = "params" // Host, path and query parameter names
+	GranularityPath   Granularity = "path"   // Host and path
+	GranularityDomain Granularity = "domain" // Host only
+)
+
+// Valid reports whether g is a known granularity; empty means GranularityURL
+func (g Granularity) Valid() bool {
+	switch g {
+	case "", GranularityURL, GranularityParams, GranularityPath, GranularityDomain:
+		return true
+	}
+	return false
+}
+
+// Key returns the dedup key of rawURL at granularity g. Coarser keys ignore
+// the scheme and carry a prefix, so they never collide with whole URLs or
+// with each other when tasks of different granularities share a set.
+func Key(rawURL string, g Granularity) string {
+	if g == "" || g == GranularityURL {
+		return rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	host := strings.ToLower(parsed.Host)
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	switch g {
+	case GranularityDomain:
+		return "domain:" + host
+	case GranularityPath:
+		return "path:" + host + path
+	case GranularityParams:
+		query := parsed.Query()
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "params:" + host + path + "?" + strings.Join(names, "&")
+	}
+	return rawURL
+}
@@ -0,0 +1,49 @@
+package dedup
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b        string
+		granularity Granularity
+		same        bool
+	}{
+		{"https://a.example/p?id=1", "https://a.example/p?id=1", GranularityURL, true},
+		{"https://a.example/p?id=1", "https://a.example/p?id=2", GranularityURL, false},
+		{"https://a.example/p?id=1&cat=2", "http://A.example/p?cat=9&id=3", GranularityParams, true},
+		{"https://a.example/p?id=1", "https://a.example/p?id=1&cat=2", GranularityParams, false},
+		{"https://a.example/p?id=1", "https://a.example/p?page=2", GranularityPath, true},
+		{"https://a.example/p", "https://a.example/q", GranularityPath, false},
+		{"https://a.example", "https://a.example/", GranularityPath, true},
+		{"https://a.example/p", "https://a.example/q?x=1", GranularityDomain, true},
+		{"https://a.example/", "https://b.a.example/", GranularityDomain, false},
+	}
+
+	for _, tt := range tests {
+		ka, kb := Key(tt.a, tt.granularity), Key(tt.b, tt.granularity)
+		if (ka == kb) != tt.same {
+			t.Errorf("%s: Key(%q) = %q, Key(%q) = %q, same = %v, want %v", tt.granularity, tt.a, ka, tt.b, kb, ka == kb, tt.same)
+		}
+	}
+}
+
+func TestKeyGranularitiesDoNotCollide(t *testing.T) {
+	const u = "https://a.example/"
+	keys := map[string]Granularity{}
+	for _, g := range []Granularity{GranularityURL, GranularityParams, GranularityPath, GranularityDomain} {
+		k := Key(u, g)
+		if other, ok := keys[k]; ok {
+			t.Errorf("%s and %s share key %q", g, other, k)
+		}
+		keys[k] = g
+	}
+}
+
+func TestNewRejectsGranularity(t *testing.T) {
+	if _, err := New(Config{Mode: ModeExact, Granularity: "fuzzy"}); err == nil {
+		t.Error("New should reject an unknown granularity")
+	}
+	if set, err := New(Config{Mode: ModeExact, Granularity: GranularityDomain}); err != nil || set == nil {
+		t.Errorf("New = %v, %v", set, err)
+	}
+}
@@ -0,0 +1,105 @@
+package dedup
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+)
+
+// spillSegments splits the spill file so a verification reads only the
+// segment an entry hashes to
+const spillSegments = 64
+
+// maxSpillLine bounds a single spilled entry
+const maxSpillLine = 1 << 20
+
+// spill keeps admitted entries on disk, one per line, in segment files named
+// <path>.00 to <path>.63. It is not safe for concurrent use.
+type spill struct {
+	files   [spillSegments]*os.File
+	writers [spillSegments]*bufio.Writer
+	sizes   [spillSegments]int64
+}
+
+// openSpill creates the segment files, truncating any left from an earlier
+// run
+func openSpill(path string) (*spill, error) {
+	sp := &spill{}
+	for i := range sp.files {
+		f, err := os.OpenFile(fmt.Sprintf("%s.%02d", path, i), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+		if err != nil {
+			sp.Close()
+			return nil, fmt.Errorf("failed to open dedup spill file: %w", err)
+		}
+		sp.files[i] = f
+		sp.writers[i] = bufio.NewWriter(f)
+	}
+	return sp, nil
+}
+
+// segment returns the segment an entry belongs to
+func (sp *spill) segment(entry string) int {
+	h := fnv.New32a()
+	h.Write([]byte(entry))
+	return int(h.Sum32() % spillSegments)
+}
+
+// Append records an entry. Line breaks inside it are folded to spaces.
+func (sp *spill) Append(entry string) error {
+	i := sp.segment(entry)
+	line := strings.ReplaceAll(entry, "\n", " ") + "\n"
+	n, err := sp.writers[i].WriteString(line)
+	sp.sizes[i] += int64(n)
+	return err
+}
+
+// Contains scans the entry's segment for it
+func (sp *spill) Contains(entry string) (bool, error) {
+	i := sp.segment(entry)
+	if err := sp.writers[i].Flush(); err != nil {
+		return false, err
+	}
+
+	entry = strings.ReplaceAll(entry, "\n", " ")
+	scanner := bufio.NewScanner(io.NewSectionReader(sp.files[i], 0, sp.sizes[i]))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSpillLine)
+	for scanner.Scan() {
+		if scanner.Text() == entry {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// copyEntries copies every recorded entry to w, one per line
+func (sp *spill) copyEntries(w io.Writer) error {
+	for i, f := range sp.files {
+		if err := sp.writers[i].Flush(); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(f, 0, sp.sizes[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes and closes the segment files
+func (sp *spill) Close() error {
+	var firstErr error
+	for i, f := range sp.files {
+		if f == nil {
+			continue
+		}
+		if err := sp.writers[i].Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
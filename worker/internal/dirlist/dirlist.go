@@ -0,0 +1,269 @@
+// Package dirlist crawls open directory listings ("Index of /...") found
+// among results and enumerates the files they list, within bounds on
+// depth, files and bytes read.
+package dirlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Config describes listing crawls; they are off unless Enabled
+type Config struct {
+	Enabled bool `json:"enabled"`
+
+	// MaxDepth bounds the subdirectories descended into below a listing;
+	// a negative depth reads the listing alone
+	MaxDepth int `json:"max_depth"`
+
+	// MaxFiles bounds the files reported for one listing
+	MaxFiles int `json:"max_files"`
+
+	// MaxListings bounds the listing pages fetched for one listing, its
+	// subdirectories included
+	MaxListings int `json:"max_listings"`
+
+	// MaxBytes bounds what is read of each listing page
+	MaxBytes int64 `json:"max_bytes"`
+
+	// Timeout bounds the crawl of one listing, every page included
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Defaults for unset Config fields
+const (
+	DefaultMaxDepth    = 2
+	DefaultMaxFiles    = 500
+	DefaultMaxListings = 50
+	DefaultMaxBytes    = 2 << 20
+	DefaultTimeout     = 30 * time.Second
+)
+
+// maxRedirects bounds the redirects followed to a listing page, such as
+// the one adding its trailing slash
+const maxRedirects = 3
+
+// ErrNotListing is returned for a page that is not a directory listing
+var ErrNotListing = errors.New("not a directory listing")
+
+// Crawler enumerates the files of directory listings. It keeps no state
+// between crawls and is safe for concurrent use.
+type Crawler struct {
+	maxDepth    int
+	maxFiles    int
+	maxListings int
+	maxBytes    int64
+	timeout     time.Duration
+}
+
+// New returns the crawler described by config, or nil when it is disabled
+func New(config Config) *Crawler {
+	if !config.Enabled {
+		return nil
+	}
+
+	c := &Crawler{
+		maxDepth:    config.MaxDepth,
+		maxFiles:    config.MaxFiles,
+		maxListings: config.MaxListings,
+		maxBytes:    config.MaxBytes,
+		timeout:     config.Timeout,
+	}
+	if c.maxDepth == 0 {
+		c.maxDepth = DefaultMaxDepth
+	} else if c.maxDepth < 0 {
+		c.maxDepth = 0
+	}
+	if c.maxFiles <= 0 {
+		c.maxFiles = DefaultMaxFiles
+	}
+	if c.maxListings <= 0 {
+		c.maxListings = DefaultMaxListings
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = DefaultMaxBytes
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	return c
+}
+
+// Match reports whether a result looks like a directory listing by its
+// title, as servers title them "Index of /path" or "Directory listing for
+// /path"
+func (c *Crawler) Match(title string) bool {
+	if c == nil {
+		return false
+	}
+	return listingTitle(strings.TrimSpace(title))
+}
+
+func listingTitle(title string) bool {
+	title = strings.ToLower(title)
+	return strings.HasPrefix(title, "index of /") || title == "index of" ||
+		strings.HasPrefix(title, "directory listing for /")
+}
+
+// listing is a directory queued for crawling
+type listing struct {
+	url   string
+	depth int
+}
+
+// Crawl fetches the listing at root through transport and returns the
+// URLs of the files it lists, descending into subdirectories up to
+// MaxDepth. Only links below the listing's own path are followed, so a
+// crawl never leaves the directory it started in. A root that is not a
+// listing gives ErrNotListing; subdirectories that fail are skipped.
+func (c *Crawler) Crawl(ctx context.Context, transport http.RoundTripper, root string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("more than %d redirects", maxRedirects)
+			}
+			if req.URL.Host != via[0].URL.Host {
+				return fmt.Errorf("redirect off %s", via[0].URL.Host)
+			}
+			return nil
+		},
+	}
+
+	var files []string
+	seen := map[string]bool{root: true}
+	queue := []listing{{url: root}}
+	for fetched := 0; len(queue) > 0 && fetched < c.maxListings; fetched++ {
+		current := queue[0]
+		queue = queue[1:]
+
+		dirs, found, err := c.read(ctx, client, current.url)
+		if err != nil {
+			if current.depth == 0 {
+				return nil, err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		for _, file := range found {
+			if len(files) == c.maxFiles {
+				return files, nil
+			}
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+		if current.depth < c.maxDepth {
+			for _, dir := range dirs {
+				if !seen[dir] {
+					seen[dir] = true
+					queue = append(queue, listing{url: dir, depth: current.depth + 1})
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// read fetches one listing page and parses it
+func (c *Crawler) read(ctx context.Context, client *http.Client, target string) (dirs, files []string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s: status %d", target, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", target, err)
+	}
+
+	dirs, files, ok := Parse(string(body), resp.Request.URL)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: %w", target, ErrNotListing)
+	}
+	return dirs, files, nil
+}
+
+var (
+	// Listing pages name themselves in their title or heading
+	titlePattern = regexp.MustCompile(`(?is)<(?:title|h1)[^>]*>\s*([^<]*)`)
+
+	hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+)
+
+// Parse reads a listing page fetched from base and returns the absolute
+// URLs of its subdirectories and files, in page order. Links outside
+// base's path, such as the parent directory, and the sort links of Apache
+// listings are dropped. ok is false when the page is not a listing.
+func Parse(page string, base *url.URL) (dirs, files []string, ok bool) {
+	for _, match := range titlePattern.FindAllStringSubmatch(page, 2) {
+		if listingTitle(strings.TrimSpace(html.UnescapeString(match[1]))) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, nil, false
+	}
+
+	// A listing lists what is below its directory, so links resolve
+	// against the directory even when the page was reached without its
+	// trailing slash
+	dir := *base
+	dir.RawQuery, dir.Fragment = "", ""
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+		dir.RawPath = ""
+	}
+
+	seen := make(map[string]bool)
+	for _, match := range hrefPattern.FindAllStringSubmatch(page, -1) {
+		href := html.UnescapeString(match[1])
+		if strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") {
+			continue
+		}
+		link, err := dir.Parse(href)
+		if err != nil || link.RawQuery != "" || link.Host != dir.Host || link.Scheme != dir.Scheme {
+			continue
+		}
+		link.Fragment = ""
+		if !strings.HasPrefix(link.Path, dir.Path) || link.Path == dir.Path {
+			continue
+		}
+
+		resolved := link.String()
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		if strings.HasSuffix(link.Path, "/") {
+			dirs = append(dirs, resolved)
+		} else {
+			files = append(files, resolved)
+		}
+	}
+	return dirs, files, true
+}
@@ -0,0 +1,114 @@
+package dirlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	c := New(Config{Enabled: true})
+
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Index of /backup", true},
+		{"  INDEX OF /", true},
+		{"Directory listing for /files/", true},
+		{"Index of", true},
+		{"Index of the best recipes", false},
+		{"Admin login", false},
+	}
+	for _, tt := range tests {
+		if got := c.Match(tt.title); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+
+	if New(Config{}) != nil {
+		t.Error("disabled config should give a nil crawler")
+	}
+	var disabled *Crawler
+	if disabled.Match("Index of /") {
+		t.Error("nil crawler should match nothing")
+	}
+}
+
+const apacheListing = `<html><head><title>Index of /backup</title></head><body>
+<h1>Index of /backup</h1>
+<table>
+<tr><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th></tr>
+<tr><td><a href="/">Parent Directory</a></td></tr>
+<tr><td><a href="db.sql.gz">db.sql.gz</a></td></tr>
+<tr><td><a href="site%20copy.zip">site copy.zip</a></td></tr>
+<tr><td><a href="old/">old/</a></td></tr>
+<tr><td><a href="db.sql.gz">db.sql.gz</a></td></tr>
+<tr><td><a href="https://elsewhere.test/x.zip">mirror</a></td></tr>
+<tr><td><a href="/other/secret.txt">secret.txt</a></td></tr>
+</table></body></html>`
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("http://files.test/backup")
+	dirs, files, ok := Parse(apacheListing, base)
+	if !ok {
+		t.Fatal("Apache listing not recognized")
+	}
+
+	wantFiles := []string{"http://files.test/backup/db.sql.gz", "http://files.test/backup/site%20copy.zip"}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+	if want := []string{"http://files.test/backup/old/"}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+
+	if _, _, ok := Parse("<title>Welcome</title><a href=\"a.zip\">a</a>", base); ok {
+		t.Error("page without a listing title taken for a listing")
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/pub":
+			http.Redirect(w, req, "/pub/", http.StatusMovedPermanently)
+		case "/pub/":
+			fmt.Fprint(w, `<title>Index of /pub</title><a href="../">Parent</a><a href="a.txt">a</a><a href="sub/">sub</a><a href="gone/">gone</a>`)
+		case "/pub/sub/":
+			fmt.Fprint(w, `<title>Index of /pub/sub</title><a href="b.txt">b</a><a href="deeper/">deeper</a>`)
+		case "/pub/sub/deeper/":
+			fmt.Fprint(w, `<title>Index of /pub/sub/deeper</title><a href="c.txt">c</a>`)
+		case "/page":
+			fmt.Fprint(w, `<title>Home</title>`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Config{Enabled: true, MaxDepth: 1})
+	files, err := c.Crawl(context.Background(), http.DefaultTransport, srv.URL+"/pub")
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	want := []string{srv.URL + "/pub/a.txt", srv.URL + "/pub/sub/b.txt"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	c = New(Config{Enabled: true, MaxFiles: 2})
+	if files, _ := c.Crawl(context.Background(), http.DefaultTransport, srv.URL+"/pub/"); len(files) != 2 {
+		t.Errorf("MaxFiles 2 gave %v", files)
+	}
+
+	if _, err := c.Crawl(context.Background(), http.DefaultTransport, srv.URL+"/page"); !errors.Is(err, ErrNotListing) {
+		t.Errorf("Crawl of a plain page: err = %v, want ErrNotListing", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package dorklist checks dork lists before they are searched. Community
+// lists often hold the same query several times, differing only in spacing,
+// typographic quotes or case; Merge keeps the first of each so it is
+// searched once. Sensitive tags dorks after clearly sensitive data, such as
+// credential dumps, so a policy can hold them back.
+package dorklist
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// quotes maps typographic quotes to the ASCII ones the engines read as
+// phrase delimiters
+var quotes = strings.NewReplacer(
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+)
+
+// Normalize returns the form two dorks share when they are the same query:
+// whitespace runs collapsed, typographic quotes made ASCII and case folded.
+// OR, AND and AROUND(n) are operators only in capitals, so those words keep
+// their case.
+func Normalize(dork string) string {
+	words := strings.Fields(quotes.Replace(dork))
+	for i, word := range words {
+		if !isOperator(word) {
+			words[i] = strings.ToLower(word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// isOperator reports whether word is a capitals-only boolean operator
+func isOperator(word string) bool {
+	switch word {
+	case "OR", "AND":
+		return true
+	}
+	return strings.HasPrefix(word, "AROUND(")
+}
+
+// Merged records a dork dropped as a duplicate of an earlier one
+type Merged struct {
+	Kept   string `json:"kept"`   // The first entry, which is searched
+	Merged string `json:"merged"` // The later entry dropped
+}
+
+// Merge drops every dork normalizing to the same query as an earlier one,
+// keeping the first as written, and reports what was dropped. Blank dorks
+// are dropped without a report.
+func Merge(dorks []string) ([]string, []Merged) {
+	first := make(map[string]string, len(dorks))
+	kept := make([]string, 0, len(dorks))
+	var merged []Merged
+	for _, dork := range dorks {
+		key := Normalize(dork)
+		if key == "" {
+			continue
+		}
+		if original, ok := first[key]; ok {
+			merged = append(merged, Merged{Kept: original, Merged: dork})
+			continue
+		}
+		first[key] = dork
+		kept = append(kept, dork)
+	}
+	return kept, merged
+}
+
+// WriteReport writes one tab-separated kept and merged dork line per
+// dropped entry
+func WriteReport(w io.Writer, merged []Merged) error {
+	for _, m := range merged {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", m.Kept, m.Merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package dorklist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		dork string
+		want string
+	}{
+		{"inurl:admin", "inurl:admin"},
+		{"  INURL:Admin\t intitle:login ", "inurl:admin intitle:login"},
+		{`intitle:“index of” “parent directory”`, `intitle:"index of" "parent directory"`},
+		{"site:example.com ‘config’", "site:example.com 'config'"},
+		{"login OR Admin", "login OR admin"},
+		{"login or admin", "login or admin"},
+		{"password AROUND(3) Config", "password AROUND(3) config"},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.dork); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.dork, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	list := []string{
+		`intitle:"index of" backup`,
+		"inurl:admin",
+		`intitle:“Index of”  backup`,
+		"",
+		"inurl:admin OR login",
+		"inurl:admin or login",
+		"INURL:admin",
+	}
+	kept, merged := Merge(list)
+
+	want := []string{`intitle:"index of" backup`, "inurl:admin", "inurl:admin OR login", "inurl:admin or login"}
+	if len(kept) != len(want) {
+		t.Fatalf("kept = %q, want %q", kept, want)
+	}
+	for i := range want {
+		if kept[i] != want[i] {
+			t.Errorf("kept[%d] = %q, want %q", i, kept[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, merged); err != nil {
+		t.Fatal(err)
+	}
+	report := "intitle:\"index of\" backup\tintitle:“Index of”  backup\ninurl:admin\tINURL:admin\n"
+	if buf.String() != report {
+		t.Errorf("report = %q, want %q", buf.String(), report)
+	}
+}
+
+func TestSensitive(t *testing.T) {
+	tests := []struct {
+		dork string
+		want string
+	}{
+		{"inurl:admin", ""},
+		{"inurl:login password", ""},
+		{"intitle:\"forgot password\" inurl:reset", ""},
+		{`intitle:"index of" password`, CategoryCredentials},
+		{"filetype:txt passwords", CategoryCredentials},
+		{"ext:.env DB_PASSWORD", CategoryCredentials},
+		{"filetype:env", CategoryCredentials},
+		{"inurl:.htpasswd", CategoryCredentials},
+		{`"BEGIN RSA PRIVATE KEY" filetype:pem`, CategoryPrivateKeys},
+		{`intitle:"index of" id_rsa`, CategoryPrivateKeys},
+		{"filetype:xls ssn", CategoryPersonalData},
+		{"passport photo", ""},
+		{`filetype:sql "insert into" users password`, CategoryCredentials + "," + CategoryDatabaseDump},
+		{`"MySQL dump" filetype:sql`, ""},
+		{`"sql dump" inurl:backup`, CategoryDatabaseDump},
+		{"site:example.com filetype:pdf", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Sensitive(tt.dork), ","); got != tt.want {
+			t.Errorf("Sensitive(%q) = %q, want %q", tt.dork, got, tt.want)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	e := Estimator{
+		Pages:          5,
+		ResultsPerPage: 10,
+		Yield:          map[string]int{"inurl:admin": 12, "inurl:login": 0, `intitle:"index of"`: 400},
+	}
+	tests := []struct {
+		dork string
+		want int
+	}{
+		{"INURL:admin", 2},
+		{"inurl:login", 1},
+		{`intitle:“index of”`, 5},
+		{"filetype:pdf", 5},
+	}
+	for _, tt := range tests {
+		if got := e.Estimate(tt.dork); got != tt.want {
+			t.Errorf("Estimate(%q) = %d, want %d", tt.dork, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	costs := map[string]int{"a": 5, "b": 4, "c": 3, "d": 3, "e": 2, "f": 1}
+	list := []string{"a", "b", "c", "d", "e", "f"}
+	shards := Split(list, 3, func(dork string) int { return costs[dork] })
+
+	if len(shards) != 3 {
+		t.Fatalf("got %d shards, want 3", len(shards))
+	}
+	var got []string
+	for _, shard := range shards {
+		if shard.Pages != 6 {
+			t.Errorf("shard %v has %d pages, want 6", shard.Dorks, shard.Pages)
+		}
+		got = append(got, strings.Join(shard.Dorks, ""))
+	}
+	if strings.Join(got, " ") != "af be cd" {
+		t.Errorf("shards = %v, want [af be cd]", got)
+	}
+
+	shards = Split([]string{"a"}, 3, func(string) int { return 1 })
+	if len(shards) != 3 || len(shards[0].Dorks) != 1 || len(shards[1].Dorks) != 0 {
+		t.Errorf("one dork in three shards = %+v", shards)
+	}
+}
@@ -0,0 +1,107 @@
+package dorklist
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Categories of sensitive dorks
+const (
+	CategoryCredentials  = "credentials"   // Passwords, tokens and secret files
+	CategoryPrivateKeys  = "private_keys"  // Key material
+	CategoryPersonalData = "personal_data" // Identity and payment records
+	CategoryDatabaseDump = "database_dump" // Exported databases
+)
+
+// rule tags a dork containing any of its terms, words or phrases of the
+// normalized dork. Terms common in harmless searches only count alongside
+// a data file type or a directory listing.
+type rule struct {
+	category string
+	terms    []string
+	withFile bool
+}
+
+var rules = []rule{
+	{CategoryCredentials, []string{"htpasswd", "kdbx", "aws_secret_access_key"}, false},
+	{CategoryCredentials, []string{
+		"password", "passwords", "passwd", "pwd", "credentials", "db_password", "db_pass",
+		"api_key", "apikey", "secret_key", "access_token", "auth_token",
+	}, true},
+	{CategoryPrivateKeys, []string{
+		"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+		"begin rsa private key", "begin openssh private key", "begin private key",
+	}, false},
+	{CategoryPersonalData, []string{
+		"ssn", "social security", "passport", "credit card", "card number", "cvv", "date of birth",
+	}, true},
+	{CategoryDatabaseDump, []string{"mysqldump", "sql dump", "database dump"}, false},
+	{CategoryDatabaseDump, []string{"insert into", "create table"}, true},
+}
+
+// sensitiveFileTypes are file types that hold secrets whatever the rest of
+// the dork says
+var sensitiveFileTypes = map[string]string{
+	"env":      CategoryCredentials,
+	"htpasswd": CategoryCredentials,
+	"kdbx":     CategoryCredentials,
+	"pem":      CategoryPrivateKeys,
+	"key":      CategoryPrivateKeys,
+	"ppk":      CategoryPrivateKeys,
+	"pfx":      CategoryPrivateKeys,
+	"p12":      CategoryPrivateKeys,
+}
+
+// dataFileTypes are file types that dump data rather than serve pages
+var dataFileTypes = map[string]bool{
+	"txt": true, "log": true, "sql": true, "csv": true, "xls": true, "xlsx": true,
+	"cfg": true, "conf": true, "ini": true, "yml": true, "yaml": true, "json": true,
+	"xml": true, "bak": true, "dump": true, "db": true, "mdb": true,
+}
+
+var (
+	fileTypePattern = regexp.MustCompile(`(?:^|[\s(|-])(?:filetype|ext):\.?([a-z0-9]+)`)
+	wordPattern     = regexp.MustCompile(`[a-z0-9_]+`)
+)
+
+// Sensitive returns the categories of clearly sensitive data a dork looks
+// for, sorted, or none. It is a word list, not a judgement of intent: it
+// flags dorks worth a second look and misses ones phrased around it.
+func Sensitive(dork string) []string {
+	normalized := Normalize(dork)
+	text := " " + strings.Join(wordPattern.FindAllString(normalized, -1), " ") + " "
+
+	found := make(map[string]bool)
+	withFile := strings.Contains(text, " index of ")
+	for _, m := range fileTypePattern.FindAllStringSubmatch(normalized, -1) {
+		if category, ok := sensitiveFileTypes[m[1]]; ok {
+			found[category] = true
+		}
+		if dataFileTypes[m[1]] {
+			withFile = true
+		}
+	}
+
+	for _, r := range rules {
+		if found[r.category] || (r.withFile && !withFile) {
+			continue
+		}
+		for _, term := range r.terms {
+			if strings.Contains(text, " "+term+" ") {
+				found[r.category] = true
+				break
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+	categories := make([]string, 0, len(found))
+	for category := range found {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
@@ -0,0 +1,71 @@
+package dorklist
+
+import "sort"
+
+// Estimator estimates how many result pages searching a dork takes
+type Estimator struct {
+	Pages          int // Pages searched per dork, the estimate without history
+	ResultsPerPage int
+
+	// Yield holds the distinct URLs earlier runs found per dork, keyed by
+	// Normalize. A dork found to have few results is estimated at the pages
+	// they fill, never more than Pages.
+	Yield map[string]int
+}
+
+// Estimate returns the pages a dork is expected to take, at least 1
+func (e Estimator) Estimate(dork string) int {
+	pages := max(e.Pages, 1)
+	urls, ok := e.Yield[Normalize(dork)]
+	if !ok || e.ResultsPerPage <= 0 {
+		return pages
+	}
+	needed := (urls + e.ResultsPerPage - 1) / e.ResultsPerPage
+	return min(max(needed, 1), pages)
+}
+
+// Shard is one part of a split dork list
+type Shard struct {
+	Dorks []string
+	Pages int // Estimated pages of all its dorks
+}
+
+// Split divides dorks into n shards of about equal estimated pages. The
+// dorks costing most are placed first, each on the shard with the fewest
+// pages so far; within a shard, dorks keep their order in the list. The
+// split depends only on the list and the estimates, so it is repeatable.
+func Split(dorks []string, n int, estimate func(string) int) []Shard {
+	n = max(n, 1)
+	type costed struct {
+		index int
+		pages int
+	}
+	order := make([]costed, len(dorks))
+	for i, dork := range dorks {
+		order[i] = costed{index: i, pages: estimate(dork)}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].pages > order[j].pages })
+
+	members := make([][]int, n)
+	shards := make([]Shard, n)
+	for _, c := range order {
+		lightest := 0
+		for i := range shards {
+			if shards[i].Pages < shards[lightest].Pages ||
+				shards[i].Pages == shards[lightest].Pages && len(members[i]) < len(members[lightest]) {
+				lightest = i
+			}
+		}
+		members[lightest] = append(members[lightest], c.index)
+		shards[lightest].Pages += c.pages
+	}
+
+	for i, indexes := range members {
+		sort.Ints(indexes)
+		shards[i].Dorks = make([]string, len(indexes))
+		for j, index := range indexes {
+			shards[i].Dorks[j] = dorks[index]
+		}
+	}
+	return shards
+}
@@ -0,0 +1,198 @@
+// Package egress carries the worker's own connections through the HTTP
+// proxy of the machine it runs on, for hosts that reach the internet only
+// through one, such as behind a corporate proxy. It is a layer in front of
+// the scraping proxies: the worker tunnels to a scraping proxy through the
+// egress proxy with CONNECT, and the scraping proxy carries the search on,
+// so requests go worker → egress proxy → scraping proxy → engine.
+package egress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"dorker/worker/internal/bind"
+	"dorker/worker/internal/tunnel"
+)
+
+// Settings besides an explicit proxy URL
+const (
+	Environment = "env"    // HTTPS_PROXY, HTTP_PROXY and NO_PROXY decide
+	Direct      = "direct" // Connect directly whatever the environment says
+)
+
+// Dialer opens TCP connections, through the egress proxy for addresses it
+// covers, and from the source its bind rules give. A nil Dialer connects
+// directly.
+type Dialer struct {
+	proxyFor func(*url.URL) (*url.URL, error) // nil connects directly
+	fixed    *url.URL                         // The proxy given as a URL; nil when the environment decides
+	dialer   net.Dialer
+	bind     bind.Rules
+}
+
+// New returns the dialer for a setting: Environment (or ""), Direct, or an
+// http:// or https:// proxy URL used for every address. It returns nil when
+// connections go direct, as they do under Environment when no proxy
+// variable is set.
+func New(setting string) (*Dialer, error) {
+	switch setting {
+	case "", Environment:
+		if !environmentSet() {
+			return nil, nil
+		}
+		return newDialer(fromEnvironment, nil), nil
+	case Direct:
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(setting)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("egress proxy %q: want %s, %s or an http:// or https:// URL", redact(setting), Environment, Direct)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("egress proxy %s: unsupported scheme %q", proxyURL.Redacted(), proxyURL.Scheme)
+	}
+	return newDialer(func(*url.URL) (*url.URL, error) { return proxyURL, nil }, proxyURL), nil
+}
+
+func newDialer(proxyFor func(*url.URL) (*url.URL, error), fixed *url.URL) *Dialer {
+	return &Dialer{
+		proxyFor: proxyFor,
+		fixed:    fixed,
+		dialer:   net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// WithBind returns a dialer connecting as d does, from the source rules
+// bind each address to; a nil d gives one connecting directly. The rules
+// follow the address the caller connects to, so a scraping proxy reached
+// through the egress proxy picks the source of the connection to it.
+func (d *Dialer) WithBind(rules bind.Rules) *Dialer {
+	if d == nil {
+		if len(rules) == 0 {
+			return nil
+		}
+		d = newDialer(nil, nil)
+	}
+	bound := *d
+	bound.bind = rules
+	return &bound
+}
+
+// environmentSet reports whether any proxy variable is set
+func environmentSet() bool {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// fromEnvironment picks the proxy for a tunnel to u's host: HTTPS_PROXY,
+// as a CONNECT tunnel is what it is meant for, else HTTP_PROXY. NO_PROXY
+// exempts hosts, and loopback addresses are never proxied.
+func fromEnvironment(u *url.URL) (*url.URL, error) {
+	for _, scheme := range []string{"https", "http"} {
+		target := *u
+		target.Scheme = scheme
+		proxyURL, err := http.ProxyFromEnvironment(&http.Request{URL: &target})
+		if err != nil || proxyURL != nil {
+			return proxyURL, err
+		}
+	}
+	return nil, nil
+}
+
+// Proxy returns the egress proxy for connections to addr, nil when they
+// go direct
+func (d *Dialer) Proxy(addr string) (*url.URL, error) {
+	if d == nil || d.proxyFor == nil {
+		return nil, nil
+	}
+	return d.proxyFor(&url.URL{Host: addr})
+}
+
+// DialContext connects to addr, through a CONNECT tunnel when an egress
+// proxy covers it. Its signature suits http.Transport.DialContext.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d == nil {
+		var direct net.Dialer
+		return direct.DialContext(ctx, network, addr)
+	}
+
+	dialer := d.dialer
+	local, err := d.bind.LocalAddr(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+	if local != nil {
+		dialer.LocalAddr = local
+	}
+	if !strings.HasPrefix(network, "tcp") {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	proxyURL, err := d.Proxy(addr)
+	if err != nil {
+		return nil, fmt.Errorf("egress proxy: %w", err)
+	}
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	conn, err := d.tunnel(ctx, &dialer, proxyURL, addr)
+	if err != nil {
+		return nil, fmt.Errorf("egress proxy %s: %w", proxyURL.Redacted(), err)
+	}
+	return conn, nil
+}
+
+// tunnel opens a CONNECT tunnel to addr through the proxy, with dialer
+func (d *Dialer) tunnel(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(proxyURL.Hostname(), tunnel.DefaultPort(proxyURL)))
+	if err != nil {
+		return nil, err
+	}
+	return tunnel.Open(ctx, conn, proxyURL, addr)
+}
+
+// Describe says where connections go, for the start-up log. Credentials
+// are left out.
+func (d *Dialer) Describe() string {
+	if d == nil || d.proxyFor == nil {
+		return "direct"
+	}
+	if d.fixed != nil {
+		return "via " + d.fixed.Redacted()
+	}
+
+	var set []string
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY", "NO_PROXY"} {
+		value := os.Getenv(name)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(name))
+		}
+		if value != "" {
+			set = append(set, name+"="+redact(value))
+		}
+	}
+	return "via " + strings.Join(set, " ")
+}
+
+// redact drops the password from a proxy setting
+func redact(setting string) string {
+	if proxyURL, err := url.Parse(setting); err == nil && proxyURL.Host != "" {
+		return proxyURL.Redacted()
+	}
+	if strings.Contains(setting, "@") {
+		return "(unparsable)"
+	}
+	return setting
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"path"
+	"strings"
+)
+
+// defaultAssetExtensions are file extensions of page assets, never results
+var defaultAssetExtensions = []string{
+	".js", ".mjs", ".css", ".map",
+	".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".webp", ".avif", ".bmp",
+	".woff", ".woff2", ".ttf", ".otf", ".eot",
+}
+
+// defaultAssetHosts are CDNs that serve page assets rather than content
+var defaultAssetHosts = []string{
+	"gstatic.com",
+	"cdnjs.cloudflare.com",
+	"cdn.jsdelivr.net",
+	"unpkg.com",
+	"code.jquery.com",
+	"bootstrapcdn.com",
+	"use.fontawesome.com",
+	"ajax.aspnetcdn.com",
+}
+
+// AssetConfig tunes the static asset filter. Entries starting with a dot
+// are file extensions (".js"), others hosts matched with their subdomains
+// ("cdn.jsdelivr.net").
+type AssetConfig struct {
+	Disabled bool     `json:"disabled,omitempty"`
+	Deny     []string `json:"deny,omitempty"`  // Dropped in addition to the defaults
+	Allow    []string `json:"allow,omitempty"` // Kept even when a default or Deny entry matches
+}
+
+// AssetFilter drops URLs of scripts, stylesheets, images and fonts that the
+// catch-all patterns pick up on some layouts
+type AssetFilter struct {
+	denyExt   map[string]bool
+	allowExt  map[string]bool
+	denyHost  []string
+	allowHost []string
+}
+
+// NewAssetFilter builds the filter described by config, or returns nil
+// when it is disabled
+func NewAssetFilter(config AssetConfig) *AssetFilter {
+	if config.Disabled {
+		return nil
+	}
+
+	f := &AssetFilter{
+		denyExt:  make(map[string]bool),
+		allowExt: make(map[string]bool),
+	}
+	add := func(entry string, ext map[string]bool, hosts *[]string) {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "."):
+			ext[entry] = true
+		default:
+			*hosts = append(*hosts, entry)
+		}
+	}
+
+	for _, entry := range defaultAssetExtensions {
+		add(entry, f.denyExt, &f.denyHost)
+	}
+	for _, entry := range defaultAssetHosts {
+		add(entry, f.denyExt, &f.denyHost)
+	}
+	for _, entry := range config.Deny {
+		add(entry, f.denyExt, &f.denyHost)
+	}
+	for _, entry := range config.Allow {
+		add(entry, f.allowExt, &f.allowHost)
+	}
+	return f
+}
+
+// Match reports whether urlStr is a static asset the filter drops
+func (f *AssetFilter) Match(urlStr string) bool {
+	if f == nil {
+		return false
+	}
+
+	host, ok := urlHost(urlStr)
+	if !ok {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, domain := range f.allowHost {
+		if hostMatches(host, domain) {
+			return false
+		}
+	}
+
+	ext := strings.ToLower(path.Ext(urlPath(urlStr)))
+	if ext != "" && f.allowExt[ext] {
+		return false
+	}
+
+	if ext != "" && f.denyExt[ext] {
+		return true
+	}
+	for _, domain := range f.denyHost {
+		if hostMatches(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlPath returns the path of an absolute URL, without query or fragment
+func urlPath(urlStr string) string {
+	rest := urlStr
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	i := strings.IndexByte(rest, '/')
+	if i < 0 {
+		return ""
+	}
+	rest = rest[i:]
+	if j := strings.IndexAny(rest, "?#"); j >= 0 {
+		rest = rest[:j]
+	}
+	return rest
+}
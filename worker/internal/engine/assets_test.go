@@ -0,0 +1,82 @@
+package engine
+
+import "testing"
+
+func TestAssetFilterDefaults(t *testing.T) {
+	f := NewAssetFilter(AssetConfig{})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/static/app.js", true},
+		{"https://example.com/theme/style.CSS?v=3", true},
+		{"https://example.com/logo.png#top", true},
+		{"https://cdnjs.cloudflare.com/ajax/libs/jquery/3.7.1/jquery.min", true},
+		{"https://sub.cdn.jsdelivr.net/npm/pkg", true},
+		{"https://example.com/admin/login.php", false},
+		{"https://example.com/js/", false},
+		{"https://example.com/?file=app.js", false},
+		{"https://example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := f.Match(tt.url); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestAssetFilterAllowDeny(t *testing.T) {
+	f := NewAssetFilter(AssetConfig{
+		Deny:  []string{".pdf", "assets.example.net"},
+		Allow: []string{".svg", "unpkg.com"},
+	})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/report.pdf", true},
+		{"https://assets.example.net/page", true},
+		{"https://example.com/diagram.svg", false},
+		{"https://unpkg.com/pkg/index.js", false},
+		{"https://example.com/app.js", true},
+	}
+
+	for _, tt := range tests {
+		if got := f.Match(tt.url); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestAssetFilterDisabled(t *testing.T) {
+	f := NewAssetFilter(AssetConfig{Disabled: true})
+	if f != nil {
+		t.Fatal("disabled filter should be nil")
+	}
+	if f.Match("https://example.com/app.js") {
+		t.Error("nil filter should match nothing")
+	}
+}
+
+func TestGoogleParseResultsSkipsAssets(t *testing.T) {
+	g := NewGoogle()
+
+	html := `
+	<a href="/url?q=https://example.com/admin&amp;sa=U">Result</a>
+	<a href="https://example.com/static/app.js" data-ved="1">Script</a>
+	<div data-href="https://cdnjs.cloudflare.com/ajax/libs/lib.min.css"></div>
+	`
+
+	results := g.ParseResults(html)
+	if len(results) != 1 || results[0].URL != "https://example.com/admin" {
+		t.Errorf("results = %+v, want only the result link", results)
+	}
+
+	g.Assets = nil
+	if results := g.ParseResults(html); len(results) != 3 {
+		t.Errorf("without the filter got %d results, want 3", len(results))
+	}
+}
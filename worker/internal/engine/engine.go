@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -20,8 +21,130 @@ type SearchEngine interface {
 type SearchResult struct {
 	URL         string `json:"url"`
 	Title       string `json:"title"`
-	Description string `json:"description"`
-	Position    int    `json:"position"`
+	Description string `json:"description"` // The result's snippet
+	Position    int    `json:"position"`    // From 1; per page here, absolute in worker results
+
+	// Type is where on the page the result was linked from (TypeOrganic,
+	// TypeAd, ...)
+	Type string `json:"type,omitempty"`
+
+	// DisplayURL is the URL as the results page shows it, breadcrumbs
+	// such as "example.com › admin" included
+	DisplayURL string `json:"display_url,omitempty"`
+
+	// Method is the most reliable extraction method that found the URL and
+	// Confidence its score, from 0 to 1
+	Method     string  `json:"method,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+
+	// FinalURL is where URL ends up when it is a redirector link the
+	// worker resolved
+	FinalURL string `json:"final_url,omitempty"`
+
+	// Language is the ISO 639-1 code detected from the title and
+	// description, when the worker detects languages and could tell
+	Language string `json:"language,omitempty"`
+
+	// Files lists the files below URL when it is an open directory
+	// listing the worker crawled
+	Files []string `json:"files,omitempty"`
+
+	// Matches are the verification patterns URL's page matched, when the
+	// worker verifies results
+	Matches []Match `json:"matches,omitempty"`
+
+	// Fingerprint identifies the application URL's host runs, when the
+	// worker fingerprints hosts and could reach it
+	Fingerprint *Fingerprint `json:"fingerprint,omitempty"`
+
+	// Network is where URL's host is hosted, when the worker looks hosts
+	// up and the host resolves
+	Network *Network `json:"network,omitempty"`
+}
+
+// Fingerprint is what a host's root page and favicon say about the
+// application it runs
+type Fingerprint struct {
+	Title       string `json:"title,omitempty"`        // Of the root page
+	FaviconHash int32  `json:"favicon_hash,omitempty"` // Shodan's http.favicon.hash; 0 without a favicon
+}
+
+// Network is the addresses a host resolves to and the autonomous system
+// announcing the first of them
+type Network struct {
+	IPs     []string `json:"ips"`
+	ASN     uint32   `json:"asn,omitempty"`     // 0 when no AS announces the address
+	Prefix  string   `json:"prefix,omitempty"`  // Announced netblock holding the address, as CIDR
+	Name    string   `json:"as_name,omitempty"` // Registered name of the AS
+	Country string   `json:"country,omitempty"` // Of the AS's registration
+}
+
+// Match is a verification pattern found on a result's page, with the text
+// around its first occurrence
+type Match struct {
+	Pattern string `json:"pattern"`
+	Snippet string `json:"snippet"`
+}
+
+// Target returns the final URL of a resolved redirector link, or the URL
+// itself
+func (r SearchResult) Target() string {
+	if r.FinalURL != "" {
+		return r.FinalURL
+	}
+	return r.URL
+}
+
+// Extraction methods, from the most to the least reliable
+const (
+	MethodResultLink = "result_link" // Google's /url?q= redirect on a result
+	MethodStructured = "structured"  // JSON-LD structured data
+	MethodMobileLink = "mobile_link" // A result link of the mobile layout
+	MethodAdLink     = "ad_link"     // An ad's /aclk click redirect
+	MethodDirectLink = "direct_link" // Any link with a data-ved attribute
+	MethodCite       = "cite"        // A displayed URL in a cite block
+	MethodDataHref   = "data_href"   // Any data-href attribute
+)
+
+// MethodConfidence scores each extraction method. The catch-all patterns
+// also match navigation, ads and assets on some layouts, so they score low.
+var MethodConfidence = map[string]float64{
+	MethodResultLink: 0.95,
+	MethodMobileLink: 0.9,
+	MethodAdLink:     0.9,
+	MethodStructured: 0.8,
+	MethodDirectLink: 0.6,
+	MethodCite:       0.4,
+	MethodDataHref:   0.3,
+}
+
+// googlePatterns extract result URLs from Google results pages, each
+// tagged with its extraction method
+var googlePatterns = []struct {
+	method string
+	re     *regexp.Regexp
+}{
+	// Standard result links
+	{MethodResultLink, regexp.MustCompile(`<a[^>]+href="(/url\?q=|/url\?esrc=s&amp;source=web&amp;rct=j&amp;url=)([^"&]+)`)},
+	// Ad click redirects, on the anchor or in its data-rw attribute
+	{MethodAdLink, regexp.MustCompile(`<a[^>]+(?:href|data-rw)="((?:https://www\.googleadservices\.com/pagead)?/aclk\?[^"]+)"`)},
+	// Direct links in search results
+	{MethodDirectLink, regexp.MustCompile(`<a[^>]+href="(https?://[^"]+)"[^>]*data-ved=`)},
+	// Cite blocks (URL display)
+	{MethodCite, regexp.MustCompile(`<cite[^>]*>([^<]+)</cite>`)},
+	// Data-href attributes
+	{MethodDataHref, regexp.MustCompile(`data-href="(https?://[^"]+)"`)},
+}
+
+// googleMobilePatterns extract the result links of the mobile layout,
+// which link results directly from anchors of their own classes, with the
+// class before or after the href. They apply in mobile mode only.
+var googleMobilePatterns = []struct {
+	method string
+	re     *regexp.Regexp
+}{
+	{MethodMobileLink, regexp.MustCompile(`<a[^>]+class="[^"]*\b(?:cz3goc|C8nzq|BmP5tf)\b[^"]*"[^>]*href="(https?://[^"]+)"`)},
+	{MethodMobileLink, regexp.MustCompile(`<a[^>]+href="(https?://[^"]+)"[^>]*class="[^"]*\b(?:cz3goc|C8nzq|BmP5tf)\b[^"]*"`)},
 }
 
 // Google implements SearchEngine for Google
@@ -32,6 +155,32 @@ type Google struct {
 	Country        string   // gl parameter
 	SafeSearch     bool     // safe parameter
 	ExcludeDomains []string // Domains to exclude from results
+
+	// Assets drops static assets the catch-all patterns pick up; nil keeps
+	// them
+	Assets *AssetFilter
+
+	// Features names the SERP features (FeatureVideo, FeatureMaps, ...)
+	// whose links are kept; links inside other features' containers are
+	// dropped. FeatureAll keeps every feature.
+	Features map[string]bool
+
+	// Vertical is the tbm parameter (VerticalVideo, ...); empty searches
+	// the web. A vertical's results are all of one kind, so no feature
+	// containers are dropped on it.
+	Vertical string
+
+	// Verbatim sends nfpr=1 so Google searches the dork as written rather
+	// than an auto-corrected query
+	Verbatim bool
+
+	// Mobile parses the mobile layout, served to the phone user agents
+	// searches are then sent with, alongside the desktop one
+	Mobile bool
+
+	// OrganicOnly drops ads, featured snippets and "People also ask"
+	// links, keeping only results of TypeOrganic
+	OrganicOnly bool
 }
 
 // NewGoogle creates a new Google search engine
@@ -41,6 +190,8 @@ func NewGoogle() *Google {
 		Language:   "en",
 		Country:    "us",
 		SafeSearch: false,
+		Assets:     NewAssetFilter(AssetConfig{}),
+		Verbatim:   true,
 	}
 }
 
@@ -51,14 +202,19 @@ func (g *Google) Name() string {
 
 // BuildSearchURL constructs the Google search URL
 func (g *Google) BuildSearchURL(query string, page int, resultsPerPage int) string {
+	return g.buildURL(g.Domain, g.Language, g.Country, query, page, resultsPerPage)
+}
+
+// buildURL constructs a Google search URL on the given domain
+func (g *Google) buildURL(domain, language, country string, query string, page int, resultsPerPage int) string {
 	// Base URL
-	baseURL := fmt.Sprintf("https://%s/search", g.Domain)
+	baseURL := fmt.Sprintf("https://%s/search", domain)
 
 	// Build query parameters
 	params := url.Values{}
 	params.Set("q", query)
-	params.Set("hl", g.Language)
-	params.Set("gl", g.Country)
+	params.Set("hl", language)
+	params.Set("gl", country)
 	params.Set("num", fmt.Sprintf("%d", resultsPerPage))
 
 	// Pagination (start parameter)
@@ -72,6 +228,14 @@ func (g *Google) BuildSearchURL(query string, page int, resultsPerPage int) stri
 		params.Set("safe", "active")
 	}
 
+	// Vertical and verbatim query
+	if g.Vertical != "" {
+		params.Set("tbm", g.Vertical)
+	}
+	if g.Verbatim {
+		params.Set("nfpr", "1")
+	}
+
 	// Additional params to look more legitimate
 	params.Set("ie", "UTF-8")
 	params.Set("oe", "UTF-8")
@@ -83,42 +247,92 @@ func (g *Google) BuildSearchURL(query string, page int, resultsPerPage int) stri
 func (g *Google) ParseResults(html string) []SearchResult {
 	var results []SearchResult
 
-	// Multiple patterns for extracting URLs from Google results
-	patterns := []*regexp.Regexp{
-		// Standard result links
-		regexp.MustCompile(`<a[^>]+href="(/url\?q=|/url\?esrc=s&amp;source=web&amp;rct=j&amp;url=)([^"&]+)`),
-		// Direct links in search results
-		regexp.MustCompile(`<a[^>]+href="(https?://[^"]+)"[^>]*data-ved=`),
-		// Cite blocks (URL display)
-		regexp.MustCompile(`<cite[^>]*>([^<]+)</cite>`),
-		// Data-href attributes
-		regexp.MustCompile(`data-href="(https?://[^"]+)"`),
+	// Track seen URLs by result index to avoid duplicates; a URL found by
+	// several methods keeps the confidence of the most reliable
+	seen := make(map[string]int)
+	position := 0
+
+	add := func(result SearchResult) {
+		if i, ok := seen[result.URL]; ok {
+			if result.Confidence > results[i].Confidence {
+				results[i].Method, results[i].Confidence = result.Method, result.Confidence
+			}
+			if results[i].Title == "" {
+				results[i].Title = result.Title
+			}
+			if results[i].Description == "" {
+				results[i].Description = result.Description
+			}
+			if results[i].DisplayURL == "" {
+				results[i].DisplayURL = result.DisplayURL
+			}
+			return
+		}
+		seen[result.URL] = len(results)
+		position++
+		result.Position = position
+		results = append(results, result)
 	}
 
-	// Track seen URLs to avoid duplicates
-	seen := make(map[string]bool)
-	position := 0
+	// Links inside SERP features other than the kept ones are not
+	// organic results
+	var features spans
+	if g.Vertical == "" {
+		features = featureSpans(html, g.Features)
+	}
+
+	// Ads, answer boxes and questions are typed by their containers; a
+	// vertical's results are all of its kind
+	var types []typedSpans
+	if g.Vertical == "" {
+		types = resultTypeSpans(html)
+	}
+
+	patterns := googlePatterns
+	if g.Mobile {
+		patterns = append(patterns[:len(patterns):len(patterns)], googleMobilePatterns...)
+	}
+
+	// Match every pattern first: a result's details run up to the next
+	// result link, whichever pattern finds it
+	found := make([][][]int, len(patterns))
+	var linkStarts []int
+	for i, pattern := range patterns {
+		found[i] = pattern.re.FindAllStringSubmatchIndex(html, -1)
+		if isResultLink(pattern.method) {
+			for _, match := range found[i] {
+				linkStarts = append(linkStarts, match[0])
+			}
+		}
+	}
+	sort.Ints(linkStarts)
 
-	for _, pattern := range patterns {
-		matches := pattern.FindAllStringSubmatch(html, -1)
-		for _, match := range matches {
+	for i, pattern := range patterns {
+		for _, match := range found[i] {
 			var rawURL string
-			if len(match) >= 3 {
-				rawURL = match[2]
-			} else if len(match) >= 2 {
-				rawURL = match[1]
+			if len(match) >= 6 {
+				rawURL = html[match[4]:match[5]]
+			} else if len(match) >= 4 {
+				rawURL = html[match[2]:match[3]]
 			} else {
 				continue
 			}
+			if features.contains(match[0]) {
+				continue
+			}
 
-			// Clean and decode URL
-			cleanURL := g.cleanURL(rawURL)
-			if cleanURL == "" {
+			// Ad click redirects lead to the advertiser
+			typ := resultType(types, match[0])
+			if target, ok := g.adTarget(rawURL); ok {
+				rawURL, typ = target, TypeAd
+			}
+			if g.OrganicOnly && typ != TypeOrganic {
 				continue
 			}
 
-			// Skip if already seen
-			if seen[cleanURL] {
+			// Clean and decode URL
+			cleanURL := g.cleanURL(rawURL)
+			if cleanURL == "" {
 				continue
 			}
 
@@ -132,96 +346,108 @@ func (g *Google) ParseResults(html string) []SearchResult {
 				continue
 			}
 
-			seen[cleanURL] = true
-			position++
+			// Skip scripts, stylesheets, images and CDN assets
+			if g.Assets.Match(cleanURL) {
+				continue
+			}
+
+			// Links to results carry their title, and are followed by their
+			// displayed URL and snippet up to the next result link
+			var title, display, snippet string
+			switch pattern.method {
+			case MethodResultLink, MethodMobileLink, MethodAdLink, MethodDirectLink:
+				title = anchorTitle(html[match[1]:])
+				end := len(html)
+				if next := sort.SearchInts(linkStarts, match[0]+1); next < len(linkStarts) {
+					end = linkStarts[next]
+				}
+				display, snippet = resultDetails(html[match[1]:end])
+			case MethodCite:
+				display = cleanText(rawURL)
+			}
 
-			results = append(results, SearchResult{
-				URL:      cleanURL,
-				Position: position,
+			add(SearchResult{
+				URL:         cleanURL,
+				Title:       title,
+				Description: snippet,
+				DisplayURL:  display,
+				Type:        typ,
+				Method:      pattern.method,
+				Confidence:  MethodConfidence[pattern.method],
 			})
 		}
 	}
 
 	// Also try to extract from JSON-LD if present
-	jsonResults := g.parseJSONLD(html)
-	for _, jr := range jsonResults {
-		if !seen[jr.URL] {
-			seen[jr.URL] = true
-			position++
-			jr.Position = position
-			results = append(results, jr)
-		}
+	for _, jr := range g.parseJSONLD(html) {
+		add(jr)
 	}
 
 	return results
 }
 
-// cleanURL decodes and cleans a URL
-func (g *Google) cleanURL(rawURL string) string {
-	// URL decode
-	decoded, err := url.QueryUnescape(rawURL)
-	if err != nil {
-		decoded = rawURL
-	}
-
-	// Handle HTML entities
-	decoded = strings.ReplaceAll(decoded, "&amp;", "&")
-	decoded = strings.ReplaceAll(decoded, "&#39;", "'")
-	decoded = strings.ReplaceAll(decoded, "&quot;", "\"")
-
-	// Remove tracking parameters from Google redirect URLs
-	if strings.Contains(decoded, "/url?") {
-		if u, err := url.Parse(decoded); err == nil {
-			if q := u.Query().Get("q"); q != "" {
-				decoded = q
-			} else if q := u.Query().Get("url"); q != "" {
-				decoded = q
-			}
-		}
-	}
+// isResultLink reports whether an extraction method finds the link of a
+// result, which its details follow
+func isResultLink(method string) bool {
+	return method == MethodResultLink || method == MethodMobileLink || method == MethodAdLink || method == MethodDirectLink
+}
 
-	// Validate URL
-	parsed, err := url.Parse(decoded)
-	if err != nil {
-		return ""
+// adTarget returns the advertiser URL an ad click redirect, /aclk on
+// googleadservices.com or Google itself, leads to
+func (g *Google) adTarget(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.ReplaceAll(rawURL, "&amp;", "&"))
+	if err != nil || !strings.HasSuffix(u.Path, "/aclk") {
+		return "", false
+	}
+	if u.Host != "" && !g.isGoogleURL(u.String()) {
+		return "", false
 	}
+	target := u.Query().Get("adurl")
+	return target, target != ""
+}
+
+// cleanURL decodes and cleans a URL
+func (g *Google) cleanURL(rawURL string) string {
+	buf := urlBufPool.Get().(*[]byte)
+	defer urlBufPool.Put(buf)
 
-	// Must have scheme and host
-	if parsed.Scheme == "" || parsed.Host == "" {
+	clean, ok := AppendCleanURL((*buf)[:0], rawURL)
+	*buf = clean
+	if !ok {
 		return ""
 	}
 
-	// Only http/https
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return ""
+	// Most links need no decoding, so the input can be returned as is
+	if string(clean) == rawURL {
+		return rawURL
 	}
+	return string(clean)
+}
 
-	return decoded
+// googleDomains are the hosts isGoogleURL treats as Google internal
+var googleDomains = []string{
+	"google.com",
+	"google.co",
+	"googleapis.com",
+	"gstatic.com",
+	"googleusercontent.com",
+	"google-analytics.com",
+	"googleadservices.com",
+	"googlesyndication.com",
+	"youtube.com",
+	"youtu.be",
 }
 
 // isGoogleURL checks if URL is a Google internal URL
 func (g *Google) isGoogleURL(urlStr string) bool {
-	googleDomains := []string{
-		"google.com",
-		"google.co",
-		"googleapis.com",
-		"gstatic.com",
-		"googleusercontent.com",
-		"google-analytics.com",
-		"googleadservices.com",
-		"googlesyndication.com",
-		"youtube.com",
-		"youtu.be",
-	}
-
-	parsed, err := url.Parse(urlStr)
-	if err != nil {
+	host, ok := urlHost(urlStr)
+	if !ok {
 		return false
 	}
 
-	host := strings.ToLower(parsed.Host)
+	host = strings.ToLower(host)
 	for _, domain := range googleDomains {
-		if host == domain || strings.HasSuffix(host, "."+domain) {
+		if hostMatches(host, domain) {
 			return true
 		}
 	}
@@ -235,14 +461,14 @@ func (g *Google) isExcludedDomain(urlStr string) bool {
 		return false
 	}
 
-	parsed, err := url.Parse(urlStr)
-	if err != nil {
+	host, ok := urlHost(urlStr)
+	if !ok {
 		return false
 	}
 
-	host := strings.ToLower(parsed.Host)
+	host = strings.ToLower(host)
 	for _, domain := range g.ExcludeDomains {
-		if host == domain || strings.HasSuffix(host, "."+domain) {
+		if hostMatches(host, domain) {
 			return true
 		}
 	}
@@ -272,7 +498,10 @@ func (g *Google) parseJSONLD(html string) []SearchResult {
 				cleanURL := g.cleanURL(urlMatch[1])
 				if cleanURL != "" && !g.isGoogleURL(cleanURL) {
 					results = append(results, SearchResult{
-						URL: cleanURL,
+						URL:        cleanURL,
+						Type:       TypeOrganic,
+						Method:     MethodStructured,
+						Confidence: MethodConfidence[MethodStructured],
 					})
 				}
 			}
@@ -336,6 +565,29 @@ func (g *Google) DetectBlock(html string) bool {
 	return false
 }
 
+// DetectTorBlock checks for the refusals served to Tor exits: the
+// Cloudflare challenge sites put in front of them, and pages turning away
+// Tor exit nodes by name. Other proxies rarely get these, so the worker
+// checks for them only on Tor, where a new circuit is the remedy.
+func (g *Google) DetectTorBlock(html string) bool {
+	torIndicators := []string{
+		"<title>attention required! | cloudflare</title>",
+		"<title>just a moment...</title>",
+		"cf-chl-",
+		"cf-challenge",
+		"tor exit node",
+	}
+
+	htmlLower := strings.ToLower(html)
+	for _, indicator := range torIndicators {
+		if strings.Contains(htmlLower, indicator) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // DetectNoResults checks if there are no search results
 func (g *Google) DetectNoResults(html string) bool {
 	noResultIndicators := []string{
@@ -116,6 +116,28 @@ func TestGoogleBuildSearchURLWithDifferentDomain(t *testing.T) {
 	}
 }
 
+func TestGoogleBuildLocalizedURL(t *testing.T) {
+	g := NewGoogle()
+
+	locale, ok := LocaleFor(" de")
+	if !ok {
+		t.Fatal("LocaleFor(de) should be known")
+	}
+	url := g.BuildLocalizedURL("test", 1, 10, locale)
+	for _, want := range []string{"https://www.google.de/search?", "hl=de", "gl=de", "start=10"} {
+		if !strings.Contains(url, want) {
+			t.Errorf("URL should contain %q, got: %s", want, url)
+		}
+	}
+	if !strings.HasPrefix(locale.AcceptLanguage, "de-DE") {
+		t.Errorf("AcceptLanguage = %q", locale.AcceptLanguage)
+	}
+
+	if _, ok := LocaleFor("XX"); ok {
+		t.Error("unknown country should not have a locale")
+	}
+}
+
 func TestGoogleParseResults(t *testing.T) {
 	g := NewGoogle()
 
@@ -232,6 +254,158 @@ func TestGoogleParseResultsWithJSONLD(t *testing.T) {
 	}
 }
 
+func TestGoogleParseResultsConfidence(t *testing.T) {
+	g := NewGoogle()
+
+	html := `
+	<div class="g"><a href="/url?q=https://result.example/admin&amp;sa=U">Result</a></div>
+	<a href="https://direct.example/page" data-ved="1">Direct</a>
+	<cite>https://cite.example/path</cite>
+	<div data-href="https://nav.example/menu"></div>
+	<a href="https://result.example/admin" data-ved="2">Same result, direct</a>
+	`
+
+	want := map[string]string{
+		"https://result.example/admin": MethodResultLink,
+		"https://direct.example/page":  MethodDirectLink,
+		"https://cite.example/path":    MethodCite,
+		"https://nav.example/menu":     MethodDataHref,
+	}
+
+	results := g.ParseResults(html)
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(results), results)
+	}
+	for _, r := range results {
+		method, ok := want[r.URL]
+		if !ok {
+			t.Errorf("unexpected URL %s", r.URL)
+			continue
+		}
+		if r.Method != method {
+			t.Errorf("%s: method = %s, want %s", r.URL, r.Method, method)
+		}
+		if r.Confidence != MethodConfidence[method] {
+			t.Errorf("%s: confidence = %v, want %v", r.URL, r.Confidence, MethodConfidence[method])
+		}
+	}
+
+	// Result links must outscore the catch-all patterns
+	if MethodConfidence[MethodResultLink] <= MethodConfidence[MethodDataHref] {
+		t.Error("result links should score above data-href matches")
+	}
+}
+
+func TestGoogleParseResultsConfidenceUpgrade(t *testing.T) {
+	g := NewGoogle()
+
+	// Found first by the cite pattern, then by JSON-LD: the URL keeps its
+	// position but takes the more reliable method
+	html := `
+	<cite>https://both.example/page</cite>
+	<script type="application/ld+json">
+	{"mainEntity": {"itemListElement": [{"url": "https://both.example/page"}]}}
+	</script>
+	`
+
+	results := g.ParseResults(html)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d: %+v", len(results), results)
+	}
+	if results[0].Method != MethodStructured || results[0].Position != 1 {
+		t.Errorf("result = %+v, want method %s at position 1", results[0], MethodStructured)
+	}
+}
+
+func TestGoogleParseResultsTitle(t *testing.T) {
+	g := NewGoogle()
+
+	html := `
+	<div class="g"><a href="/url?q=https://a.example/files/&amp;sa=U"><h3 class="LC20lb">Index of /files</h3><div><cite>a.example</cite></div></a></div>
+	<div class="g"><a href="/url?q=https://b.example/&amp;sa=U"><span>Tom &amp; Jerry</span>
+	<b>fan   site</b></a></div>
+	<cite>https://c.example/</cite>
+	<a href="https://c.example/" data-ved="1">C example</a>
+	`
+
+	want := map[string]string{
+		"https://a.example/files/": "Index of /files",
+		"https://b.example/":       "Tom & Jerry fan site",
+		"https://c.example/":       "C example",
+	}
+
+	results := g.ParseResults(html)
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(results), results)
+	}
+	for _, r := range results {
+		if r.Title != want[r.URL] {
+			t.Errorf("%s: title = %q, want %q", r.URL, r.Title, want[r.URL])
+		}
+	}
+}
+
+func TestGoogleParseResultsSnippet(t *testing.T) {
+	g := NewGoogle()
+
+	html := `
+	<div class="g"><div class="yuRUbf"><a href="https://a.example/admin/" data-ved="1"><br><h3 class="LC20lb">Admin panel</h3>
+	<div class="notranslate"><cite class="qLRx3b">https://a.example <span>› admin</span></cite></div></a></div>
+	<div class="VwiC3b yXK7lf"><span class="MUxGbd">Mar 3, 2024 — </span><span>Log in to the <em>admin</em> &amp; control
+	panel.</span></div></div>
+	<div class="g"><a href="/url?q=https://b.example/&amp;sa=U"><div class="BNeawe vvjwJb AP7Wnd">B example</div>
+	<div class="BNeawe UPmit AP7Wnd">b.example › index</div></a>
+	<div class="BNeawe s3v9rd AP7Wnd"><div><div><div class="BNeawe s3v9rd AP7Wnd">Basic page snippet.</div></div></div></div></div>
+	<div class="g"><a href="/url?q=https://c.example/&amp;sa=U"><h3>No snippet</h3></a></div>
+	`
+
+	want := map[string][2]string{
+		"https://a.example/admin/": {"https://a.example › admin", "Mar 3, 2024 — Log in to the admin & control panel."},
+		"https://b.example/":       {"b.example › index", "Basic page snippet."},
+		"https://c.example/":       {"", ""},
+	}
+
+	results := g.ParseResults(html)
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(results), results)
+	}
+	for _, r := range results {
+		w := want[r.URL]
+		if r.DisplayURL != w[0] {
+			t.Errorf("%s: display URL = %q, want %q", r.URL, r.DisplayURL, w[0])
+		}
+		if r.Description != w[1] {
+			t.Errorf("%s: description = %q, want %q", r.URL, r.Description, w[1])
+		}
+	}
+}
+
+func TestGoogleParseResultsMobile(t *testing.T) {
+	html := `
+	<div class="MjjYud"><div class="mnr-c"><a class="cz3goc BmP5tf" href="https://a.example/admin/"><div class="MUxGbd v0nnCb" role="heading" aria-level="3">Admin <b>panel</b></div>
+	<span class="qzEoUe">a.example › admin</span></a>
+	<div class="MUxGbd yDYNvb lyLwlc">Log in to the admin panel.</div></div></div>
+	<div class="MjjYud"><a href="https://b.example/" class="C8nzq BmP5tf"><div role="heading" aria-level="3">B example</div></a></div>
+	`
+
+	g := NewGoogle()
+	if results := g.ParseResults(html); len(results) != 0 {
+		t.Errorf("desktop mode parsed the mobile layout: %+v", results)
+	}
+
+	g.Mobile = true
+	results := g.ParseResults(html)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(results), results)
+	}
+	if r := results[0]; r.URL != "https://a.example/admin/" || r.Title != "Admin panel" || r.Description != "Log in to the admin panel." || r.Method != MethodMobileLink {
+		t.Errorf("first result = %+v", r)
+	}
+	if r := results[1]; r.URL != "https://b.example/" || r.Title != "B example" || r.Position != 2 {
+		t.Errorf("second result = %+v", r)
+	}
+}
+
 func TestGoogleCleanURL(t *testing.T) {
 	g := NewGoogle()
 
@@ -421,6 +595,40 @@ func TestGoogleDetectBlock(t *testing.T) {
 	}
 }
 
+func TestGoogleDetectTorBlock(t *testing.T) {
+	g := NewGoogle()
+
+	tests := []struct {
+		name string
+		html string
+		want bool
+	}{
+		{
+			name: "cloudflare challenge",
+			html: `<html><head><title>Just a moment...</title></head><body><div id="cf-chl-widget"></div></body></html>`,
+			want: true,
+		},
+		{
+			name: "exit node refused",
+			html: `<html><body>Access from a Tor exit node is not permitted</body></html>`,
+			want: true,
+		},
+		{
+			name: "results about tor",
+			html: `<html><body><div class="g"><a href="https://www.torproject.org/">The Tor network, just a moment...</a></div></body></html>`,
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.DetectTorBlock(tt.html); got != tt.want {
+				t.Errorf("DetectTorBlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGoogleDetectNoResults(t *testing.T) {
 	g := NewGoogle()
 
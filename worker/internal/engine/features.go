@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SERP features: blocks of non-organic results Google mixes into a web
+// results page
+const (
+	FeatureVideo     = "video"     // Video carousel
+	FeatureMaps      = "maps"      // Local pack of places next to a map
+	FeatureNews      = "news"      // Top stories
+	FeatureImages    = "images"    // Image strip
+	FeatureQuestions = "questions" // People also ask
+)
+
+// FeatureAll in Google.Features keeps the links of every feature
+const FeatureAll = "all"
+
+// Verticals, the tbm parameter; a vertical's results are all of one kind
+const (
+	VerticalVideo  = "vid"
+	VerticalLocal  = "lcl"
+	VerticalNews   = "nws"
+	VerticalImages = "isch"
+)
+
+// featureMarkers find the opening tag of each feature's container. They
+// match markup Google has used for a long while, but a redesign can slip
+// past them, in which case the feature's links pass as organic results.
+var featureMarkers = []struct {
+	feature string
+	re      *regexp.Regexp
+}{
+	{FeatureVideo, regexp.MustCompile(`<video-voyager\b|<div[^>]+\baria-label="Videos"`)},
+	{FeatureMaps, regexp.MustCompile(`<div[^>]+\bclass="[^"]*\b(?:VkpGBb|rllt__details)\b|<div[^>]+\baria-label="(?:Places|Local results)"`)},
+	{FeatureNews, regexp.MustCompile(`<g-section-with-header\b|<div[^>]+\baria-label="Top stories"`)},
+	{FeatureImages, regexp.MustCompile(`<div[^>]+\bid="imagebox_bigimages"|<div[^>]+\baria-label="Images"`)},
+	{FeatureQuestions, regexp.MustCompile(`<div[^>]+\bclass="[^"]*\brelated-question-pair\b`)},
+}
+
+// Result types: where on the page a result was linked from
+const (
+	TypeOrganic         = "organic"
+	TypeAd              = "ad"               // Sponsored result or googleadservices redirect
+	TypeFeaturedSnippet = "featured_snippet" // Answer box above the results
+	TypeQuestion        = "people_also_ask"  // Link in a "People also ask" answer
+)
+
+// typeMarkers find the opening tag of the containers of non-organic
+// results, in precedence order: an ad in an answer box is an ad. Like the
+// feature markers they follow Google's current markup.
+var typeMarkers = []struct {
+	typ string
+	re  *regexp.Regexp
+}{
+	{TypeAd, regexp.MustCompile(`<div[^>]+\bid="(?:tads|tadsb|bottomads)"|<div[^>]+\bdata-text-ad\b`)},
+	{TypeQuestion, regexp.MustCompile(`<div[^>]+\bclass="[^"]*\brelated-question-pair\b`)},
+	{TypeFeaturedSnippet, regexp.MustCompile(`<block-component\b|<div[^>]+\bclass="[^"]*\b(?:xpdopen|ifM9O)\b`)},
+}
+
+// typedSpans are the ranges of the page inside containers of one result
+// type
+type typedSpans struct {
+	typ   string
+	spans spans
+}
+
+// resultTypeSpans returns the ranges of the page inside containers of each
+// non-organic result type, in typeMarkers' order
+func resultTypeSpans(html string) []typedSpans {
+	var types []typedSpans
+	for _, marker := range typeMarkers {
+		var found spans
+		for _, loc := range marker.re.FindAllStringIndex(html, -1) {
+			if end := elementEnd(html, loc[0]); end > 0 {
+				found = append(found, span{loc[0], end})
+			}
+		}
+		if len(found) > 0 {
+			types = append(types, typedSpans{marker.typ, mergeSpans(found)})
+		}
+	}
+	return types
+}
+
+// resultType returns the type of a result linked at offset
+func resultType(types []typedSpans, offset int) string {
+	for _, t := range types {
+		if t.spans.contains(offset) {
+			return t.typ
+		}
+	}
+	return TypeOrganic
+}
+
+// span is a byte range of a page, end exclusive
+type span struct{ start, end int }
+
+// spans is a sorted set of non-overlapping ranges
+type spans []span
+
+// contains reports whether offset falls inside one of the ranges
+func (s spans) contains(offset int) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i].end > offset })
+	return i < len(s) && s[i].start <= offset
+}
+
+// featureSpans returns the ranges of the page inside containers of SERP
+// features other than the kept ones
+func featureSpans(html string, keep map[string]bool) spans {
+	if keep[FeatureAll] {
+		return nil
+	}
+
+	var found spans
+	for _, marker := range featureMarkers {
+		if keep[marker.feature] {
+			continue
+		}
+		for _, loc := range marker.re.FindAllStringIndex(html, -1) {
+			if end := elementEnd(html, loc[0]); end > 0 {
+				found = append(found, span{loc[0], end})
+			}
+		}
+	}
+	return mergeSpans(found)
+}
+
+// mergeSpans sorts ranges and merges nested or overlapping ones
+func mergeSpans(found spans) spans {
+	if len(found) == 0 {
+		return nil
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	merged := found[:1]
+	for _, s := range found[1:] {
+		last := &merged[len(merged)-1]
+		if s.start < last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// elementEnd returns the offset just past the closing tag of the element
+// opening at start, balancing nested elements of the same name, or 0 when
+// it is never closed. Google writes its tags in lowercase, so names are
+// matched as they are.
+func elementEnd(html string, start int) int {
+	nameEnd := start + 1
+	for nameEnd < len(html) && isTagNameByte(html[nameEnd]) {
+		nameEnd++
+	}
+	name := html[start+1 : nameEnd]
+	if name == "" {
+		return 0
+	}
+
+	openTag, closeTag := "<"+name, "</"+name+">"
+	depth := 1
+	for i := nameEnd; i < len(html); {
+		nextOpen := indexTag(html[i:], openTag)
+		nextClose := strings.Index(html[i:], closeTag)
+		if nextClose < 0 {
+			return 0
+		}
+		if nextOpen >= 0 && nextOpen < nextClose {
+			depth++
+			i += nextOpen + len(openTag)
+			continue
+		}
+		depth--
+		i += nextClose + len(closeTag)
+		if depth == 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// indexTag finds an opening tag of the given "<name" prefix, skipping
+// longer names that share it ("<div" in "<divider")
+func indexTag(s, open string) int {
+	offset := 0
+	for {
+		i := strings.Index(s[offset:], open)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		after := i + len(open)
+		if after >= len(s) || !isTagNameByte(s[after]) {
+			return i
+		}
+		offset = after
+	}
+}
+
+func isTagNameByte(c byte) bool {
+	return c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
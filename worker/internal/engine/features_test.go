@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+const serpWithFeatures = `
+<div class="g"><a href="/url?q=https://organic.example/admin&amp;sa=U"><h3>Admin</h3></a></div>
+<video-voyager><div><a href="/url?q=https://videos.example/watch&amp;sa=U">Video</a></div></video-voyager>
+<div class="VkpGBb"><div><a href="https://shop.example/" data-ved="1">Website</a></div></div>
+<div jsname="N760b" class="related-question-pair"><div><a href="/url?q=https://answers.example/q&amp;sa=U">Answer</a></div></div>
+<div class="g"><a href="/url?q=https://organic.example/login&amp;sa=U"><h3>Login</h3></a></div>
+`
+
+func TestGoogleParseResultsSuppressesFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		features map[string]bool
+		vertical string
+		want     []string
+	}{
+		{
+			name: "organic only",
+			want: []string{"https://organic.example/admin", "https://organic.example/login"},
+		},
+		{
+			name:     "keep video and maps",
+			features: map[string]bool{FeatureVideo: true, FeatureMaps: true},
+			want:     []string{"https://organic.example/admin", "https://videos.example/watch", "https://organic.example/login", "https://shop.example/"},
+		},
+		{
+			name:     "keep all",
+			features: map[string]bool{FeatureAll: true},
+			want:     []string{"https://organic.example/admin", "https://videos.example/watch", "https://answers.example/q", "https://organic.example/login", "https://shop.example/"},
+		},
+		{
+			name:     "vertical",
+			vertical: VerticalVideo,
+			want:     []string{"https://organic.example/admin", "https://videos.example/watch", "https://answers.example/q", "https://organic.example/login", "https://shop.example/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGoogle()
+			g.Features = tt.features
+			g.Vertical = tt.vertical
+
+			results := g.ParseResults(serpWithFeatures)
+			var got []string
+			for _, r := range results {
+				got = append(got, r.URL)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("URLs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElementEnd(t *testing.T) {
+	html := `<div class="a"><div>inner</div><divider></divider></div><div>after</div>`
+	if end := elementEnd(html, 0); html[:end] != `<div class="a"><div>inner</div><divider></divider></div>` {
+		t.Errorf("element = %q", html[:end])
+	}
+	if end := elementEnd(`<div><div></div>`, 0); end != 0 {
+		t.Errorf("unclosed element end = %d, want 0", end)
+	}
+}
+
+func TestGoogleBuildSearchURLVerticalAndVerbatim(t *testing.T) {
+	g := NewGoogle()
+	if url := g.BuildSearchURL("test", 0, 10); !strings.Contains(url, "nfpr=1") || strings.Contains(url, "tbm=") {
+		t.Errorf("web search URL = %s, want nfpr=1 and no tbm", url)
+	}
+
+	g.Vertical = VerticalVideo
+	g.Verbatim = false
+	if url := g.BuildSearchURL("test", 0, 10); !strings.Contains(url, "tbm=vid") || strings.Contains(url, "nfpr=") {
+		t.Errorf("vertical search URL = %s, want tbm=vid and no nfpr", url)
+	}
+}
+
+const serpWithTypes = `
+<div id="tads"><div data-text-ad="1"><a href="https://shop.example/sale" data-ved="1" data-rw="https://www.googleadservices.com/pagead/aclk?sa=L&amp;ai=x&amp;adurl=https://shop.example/sale"><div role="heading">Sale</div></a></div></div>
+<a href="/aclk?sa=l&amp;ai=y&amp;adurl=https%3A%2F%2Fads.example%2Flanding" data-ved="2">Landing</a>
+<block-component><div><a href="/url?q=https://answer.example/howto&amp;sa=U"><h3>How to</h3></a></div></block-component>
+<div jsname="N760b" class="related-question-pair"><div><a href="/url?q=https://answers.example/q&amp;sa=U">Answer</a></div></div>
+<div class="g"><a href="/url?q=https://organic.example/admin&amp;sa=U"><h3>Admin</h3></a></div>
+`
+
+func TestGoogleParseResultsTypes(t *testing.T) {
+	g := NewGoogle()
+	g.Features = map[string]bool{FeatureQuestions: true}
+
+	want := map[string]string{
+		"https://shop.example/sale":     TypeAd,
+		"https://ads.example/landing":   TypeAd,
+		"https://answer.example/howto":  TypeFeaturedSnippet,
+		"https://answers.example/q":     TypeQuestion,
+		"https://organic.example/admin": TypeOrganic,
+	}
+	results := g.ParseResults(serpWithTypes)
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for _, r := range results {
+		if r.Type != want[r.URL] {
+			t.Errorf("%s: type = %q, want %q", r.URL, r.Type, want[r.URL])
+		}
+	}
+
+	g.OrganicOnly = true
+	results = g.ParseResults(serpWithTypes)
+	if len(results) != 1 || results[0].URL != "https://organic.example/admin" || results[0].Position != 1 {
+		t.Errorf("organic only = %+v, want the organic result at position 1", results)
+	}
+}
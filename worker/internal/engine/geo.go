@@ -0,0 +1,68 @@
+package engine
+
+import "strings"
+
+// Locale is how a local user in one country searches Google: the country
+// domain, the hl and gl parameters and the browser's Accept-Language
+type Locale struct {
+	Domain         string
+	Language       string
+	Country        string
+	AcceptLanguage string
+}
+
+// countryLocales maps ISO 3166-1 alpha-2 country codes to local search
+// settings
+var countryLocales = map[string]Locale{
+	"US": {"www.google.com", "en", "us", "en-US,en;q=0.9"},
+	"GB": {"www.google.co.uk", "en", "gb", "en-GB,en;q=0.9"},
+	"CA": {"www.google.ca", "en", "ca", "en-CA,en;q=0.9,fr-CA;q=0.8"},
+	"AU": {"www.google.com.au", "en", "au", "en-AU,en;q=0.9"},
+	"NZ": {"www.google.co.nz", "en", "nz", "en-NZ,en;q=0.9"},
+	"IE": {"www.google.ie", "en", "ie", "en-IE,en;q=0.9"},
+	"IN": {"www.google.co.in", "en", "in", "en-IN,en;q=0.9,hi;q=0.8"},
+	"SG": {"www.google.com.sg", "en", "sg", "en-SG,en;q=0.9"},
+	"ZA": {"www.google.co.za", "en", "za", "en-ZA,en;q=0.9"},
+	"DE": {"www.google.de", "de", "de", "de-DE,de;q=0.9,en;q=0.8"},
+	"AT": {"www.google.at", "de", "at", "de-AT,de;q=0.9,en;q=0.8"},
+	"CH": {"www.google.ch", "de", "ch", "de-CH,de;q=0.9,fr;q=0.8,en;q=0.7"},
+	"FR": {"www.google.fr", "fr", "fr", "fr-FR,fr;q=0.9,en;q=0.8"},
+	"BE": {"www.google.be", "fr", "be", "fr-BE,fr;q=0.9,nl;q=0.8,en;q=0.7"},
+	"NL": {"www.google.nl", "nl", "nl", "nl-NL,nl;q=0.9,en;q=0.8"},
+	"ES": {"www.google.es", "es", "es", "es-ES,es;q=0.9,en;q=0.8"},
+	"MX": {"www.google.com.mx", "es", "mx", "es-MX,es;q=0.9,en;q=0.8"},
+	"AR": {"www.google.com.ar", "es", "ar", "es-AR,es;q=0.9,en;q=0.8"},
+	"IT": {"www.google.it", "it", "it", "it-IT,it;q=0.9,en;q=0.8"},
+	"PT": {"www.google.pt", "pt-PT", "pt", "pt-PT,pt;q=0.9,en;q=0.8"},
+	"BR": {"www.google.com.br", "pt-BR", "br", "pt-BR,pt;q=0.9,en;q=0.8"},
+	"SE": {"www.google.se", "sv", "se", "sv-SE,sv;q=0.9,en;q=0.8"},
+	"NO": {"www.google.no", "no", "no", "nb-NO,nb;q=0.9,no;q=0.8,en;q=0.7"},
+	"DK": {"www.google.dk", "da", "dk", "da-DK,da;q=0.9,en;q=0.8"},
+	"FI": {"www.google.fi", "fi", "fi", "fi-FI,fi;q=0.9,en;q=0.8"},
+	"PL": {"www.google.pl", "pl", "pl", "pl-PL,pl;q=0.9,en;q=0.8"},
+	"CZ": {"www.google.cz", "cs", "cz", "cs-CZ,cs;q=0.9,en;q=0.8"},
+	"RO": {"www.google.ro", "ro", "ro", "ro-RO,ro;q=0.9,en;q=0.8"},
+	"UA": {"www.google.com.ua", "uk", "ua", "uk-UA,uk;q=0.9,en;q=0.8"},
+	"RU": {"www.google.ru", "ru", "ru", "ru-RU,ru;q=0.9,en;q=0.8"},
+	"TR": {"www.google.com.tr", "tr", "tr", "tr-TR,tr;q=0.9,en;q=0.8"},
+	"JP": {"www.google.co.jp", "ja", "jp", "ja-JP,ja;q=0.9,en;q=0.8"},
+	"KR": {"www.google.co.kr", "ko", "kr", "ko-KR,ko;q=0.9,en;q=0.8"},
+	"TW": {"www.google.com.tw", "zh-TW", "tw", "zh-TW,zh;q=0.9,en;q=0.8"},
+	"HK": {"www.google.com.hk", "zh-HK", "hk", "zh-HK,zh;q=0.9,en;q=0.8"},
+	"ID": {"www.google.co.id", "id", "id", "id-ID,id;q=0.9,en;q=0.8"},
+	"VN": {"www.google.com.vn", "vi", "vn", "vi-VN,vi;q=0.9,en;q=0.8"},
+	"TH": {"www.google.co.th", "th", "th", "th-TH,th;q=0.9,en;q=0.8"},
+}
+
+// LocaleFor returns the local search settings for a country code, reporting
+// whether the country is known
+func LocaleFor(country string) (Locale, bool) {
+	locale, ok := countryLocales[strings.ToUpper(strings.TrimSpace(country))]
+	return locale, ok
+}
+
+// BuildLocalizedURL constructs a search URL on the locale's domain with its
+// hl and gl instead of the engine's own
+func (g *Google) BuildLocalizedURL(query string, page int, resultsPerPage int, locale Locale) string {
+	return g.buildURL(locale.Domain, locale.Language, locale.Country, query, page, resultsPerPage)
+}
@@ -0,0 +1,313 @@
+package engine
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// The clean path runs for every link on every results page, so it works on
+// byte slices and pooled buffers instead of url.QueryUnescape, ReplaceAll and
+// url.Parse. Inputs it cannot fully judge (escapes left after decoding,
+// userinfo, IPv6 hosts, Google redirects) go through cleanURLStd, so both
+// paths always agree.
+
+// urlBufPool recycles the scratch buffers cleanURL decodes into
+var urlBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+var (
+	entityAmp      = []byte("&amp;")
+	entityApos     = []byte("&#39;")
+	entityQuot     = []byte("&quot;")
+	redirectMarker = []byte("/url?")
+)
+
+// verdict is what url.Parse would make of a URL
+type verdict int
+
+const (
+	verdictValid   verdict = iota // Parses as an absolute http or https URL with a host
+	verdictInvalid                // Fails to parse, or has another scheme or no host
+	verdictUnsure                 // Needs url.Parse to decide
+)
+
+// AppendCleanURL appends the decoded, validated form of raw to dst and
+// reports whether raw is an absolute http or https URL. On false dst is
+// returned unchanged. It does not allocate when dst has room.
+func AppendCleanURL(dst []byte, raw string) ([]byte, bool) {
+	start := len(dst)
+
+	// URL decode; a malformed escape leaves the text as it was
+	dst, ok := appendQueryUnescape(dst, raw)
+	if !ok {
+		dst = append(dst, raw...)
+	}
+
+	// Handle HTML entities, one pass each like strings.ReplaceAll
+	b := replaceEntity(dst[start:], entityAmp, '&')
+	b = replaceEntity(b, entityApos, '\'')
+	b = replaceEntity(b, entityQuot, '"')
+	dst = dst[:start+len(b)]
+
+	if bytes.Contains(b, redirectMarker) {
+		return appendCleanURLStd(dst[:start], raw)
+	}
+
+	switch _, _, v := scanHTTPURL(b); v {
+	case verdictValid:
+		return dst, true
+	case verdictInvalid:
+		return dst[:start], false
+	}
+	return appendCleanURLStd(dst[:start], raw)
+}
+
+// appendCleanURLStd is AppendCleanURL through the standard library
+func appendCleanURLStd(dst []byte, raw string) ([]byte, bool) {
+	clean := cleanURLStd(raw)
+	if clean == "" {
+		return dst, false
+	}
+	return append(dst, clean...), true
+}
+
+// cleanURLStd decodes and cleans a URL with net/url. It defines the behaviour
+// AppendCleanURL must match.
+func cleanURLStd(rawURL string) string {
+	// URL decode
+	decoded, err := url.QueryUnescape(rawURL)
+	if err != nil {
+		decoded = rawURL
+	}
+
+	// Handle HTML entities
+	decoded = strings.ReplaceAll(decoded, "&amp;", "&")
+	decoded = strings.ReplaceAll(decoded, "&#39;", "'")
+	decoded = strings.ReplaceAll(decoded, "&quot;", "\"")
+
+	// Remove tracking parameters from Google redirect URLs
+	if strings.Contains(decoded, "/url?") {
+		if u, err := url.Parse(decoded); err == nil {
+			if q := u.Query().Get("q"); q != "" {
+				decoded = q
+			} else if q := u.Query().Get("url"); q != "" {
+				decoded = q
+			}
+		}
+	}
+
+	// Validate URL
+	parsed, err := url.Parse(decoded)
+	if err != nil {
+		return ""
+	}
+
+	// Must have scheme and host
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+
+	// Only http/https
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ""
+	}
+
+	return decoded
+}
+
+// urlHost returns the host of urlStr, port included, as url.Parse reports
+// it. It only allocates for URLs the fast scan cannot judge.
+func urlHost(urlStr string) (string, bool) {
+	if start, end, v := scanHTTPURL(urlStr); v == verdictValid {
+		return urlStr[start:end], true
+	}
+
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return "", false
+	}
+	return parsed.Host, true
+}
+
+// hostMatches reports whether host is domain or one of its subdomains
+func hostMatches(host, domain string) bool {
+	if len(host) == len(domain) {
+		return host == domain
+	}
+	return len(host) > len(domain) &&
+		host[len(host)-len(domain)-1] == '.' &&
+		strings.HasSuffix(host, domain)
+}
+
+// appendQueryUnescape appends s with %XX escapes decoded and '+' turned into
+// a space, as url.QueryUnescape does. On a malformed escape it returns dst
+// unchanged and false.
+func appendQueryUnescape(dst []byte, s string) ([]byte, bool) {
+	start := len(dst)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return dst[:start], false
+			}
+			dst = append(dst, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		case '+':
+			dst = append(dst, ' ')
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst, true
+}
+
+// replaceEntity replaces every entity in b with c in place, scanning left to
+// right without revisiting replaced text
+func replaceEntity(b, entity []byte, c byte) []byte {
+	i := bytes.Index(b, entity)
+	if i < 0 {
+		return b
+	}
+
+	w := i
+	for i < len(b) {
+		if bytes.HasPrefix(b[i:], entity) {
+			b[w] = c
+			i += len(entity)
+		} else {
+			b[w] = b[i]
+			i++
+		}
+		w++
+	}
+	return b[:w]
+}
+
+// scanHTTPURL follows the steps url.Parse takes to find the scheme and host
+// of s and returns where the host (port included) starts and ends
+func scanHTTPURL[S ~string | ~[]byte](s S) (hostStart, hostEnd int, v verdict) {
+	fragment := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '#':
+			fragment = true
+		case c == '%':
+			// Escapes in the host, path and fragment have rules of their own
+			return 0, 0, verdictUnsure
+		case (c < 0x20 || c == 0x7f) && !fragment:
+			// url.Parse rejects control characters before the fragment
+			return 0, 0, verdictInvalid
+		}
+	}
+
+	// url.Parse lowercases the scheme, so only http: or https: in any case
+	// can come out as http or https
+	i := schemeLen(s)
+	if i < 0 {
+		return 0, 0, verdictInvalid
+	}
+	i++
+
+	// The fragment and query are cut off before the authority is looked for
+	end := len(s)
+	for j := i; j < len(s); j++ {
+		if s[j] == '?' || s[j] == '#' {
+			end = j
+			break
+		}
+	}
+
+	// Without // there is no authority and so no host
+	if end-i < 2 || s[i] != '/' || s[i+1] != '/' {
+		return 0, 0, verdictInvalid
+	}
+	hostStart = i + 2
+	hostEnd = hostStart
+	for hostEnd < end && s[hostEnd] != '/' {
+		hostEnd++
+	}
+	if hostEnd == hostStart {
+		return 0, 0, verdictInvalid
+	}
+
+	// Userinfo and IPv6 brackets are left to url.Parse
+	for j := hostStart; j < hostEnd; j++ {
+		if c := s[j]; c == '@' || c == '[' || c == ']' {
+			return 0, 0, verdictUnsure
+		}
+	}
+
+	colon := -1
+	for j := hostStart; j < hostEnd; j++ {
+		switch c := s[j]; {
+		case c == ':' && colon >= 0:
+			// Which colon starts the port differs between Go releases
+			return 0, 0, verdictUnsure
+		case c == ':':
+			colon = j
+		case c >= 0x80, isHostByte(c):
+		default:
+			return 0, 0, verdictInvalid
+		}
+	}
+
+	// Everything after the colon must be a (possibly empty) port
+	if colon >= 0 {
+		for j := colon + 1; j < hostEnd; j++ {
+			if s[j] < '0' || s[j] > '9' {
+				return 0, 0, verdictInvalid
+			}
+		}
+	}
+
+	return hostStart, hostEnd, verdictValid
+}
+
+// schemeLen returns the length of an http or https scheme (any case)
+// followed by a colon at the start of s, or -1
+func schemeLen[S ~string | ~[]byte](s S) int {
+	const scheme = "https"
+	i := 0
+	for i < len(scheme) && i < len(s) && lower(s[i]) == scheme[i] {
+		i++
+	}
+	if i < 4 || i >= len(s) || s[i] != ':' {
+		return -1
+	}
+	return i
+}
+
+// isHostByte reports whether url.Parse accepts the ASCII byte c in a host
+func isHostByte(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("-_.~!$&'()*+,;=<>\"", c) >= 0
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
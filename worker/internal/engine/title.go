@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// maxAnchor bounds how far past a result link anchorTitle looks for the
+// closing tag, so a malformed page cannot make it scan the whole document
+const maxAnchor = 4096
+
+// maxResultBlock bounds how far past a result link resultDetails looks for
+// its displayed URL and snippet
+const maxResultBlock = 8192
+
+var (
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+	// Titles are <h3> headings, or heading divs on the mobile layout
+	headingPattern = regexp.MustCompile(`(?s)<h3[^>]*>(.*?)</h3>|<div[^>]*\brole="heading"[^>]*>(.*?)</div>`)
+
+	// Displayed URLs are cite blocks, or UPmit divs on the basic HTML page
+	displayPattern = regexp.MustCompile(`(?s)<cite[^>]*>(.*?)</cite>|<div[^>]*class="[^"]*\bUPmit\b[^"]*"[^>]*>(.*?)</div>`)
+
+	// Snippets sit in VwiC3b (desktop), st (older desktop), s3v9rd (basic
+	// HTML page) or lEBKkf and yDYNvb (mobile) containers, or ones marked
+	// data-sncf
+	snippetPattern = regexp.MustCompile(`<(div|span)[^>]*(?:class="[^"]*\b(?:VwiC3b|st|s3v9rd|lEBKkf|yDYNvb)\b[^"]*"|data-sncf="1")[^>]*>`)
+)
+
+// anchorTitle returns the title of the result link whose opening tag rest
+// starts inside: the text of its heading, or of the whole anchor when it
+// has none. It returns "" when the anchor is not closed nearby.
+func anchorTitle(rest string) string {
+	if len(rest) > maxAnchor {
+		rest = rest[:maxAnchor]
+	}
+	open := strings.IndexByte(rest, '>')
+	if open < 0 {
+		return ""
+	}
+	inner := rest[open+1:]
+	end := strings.Index(inner, "</a>")
+	if end < 0 {
+		return ""
+	}
+	inner = inner[:end]
+
+	if m := headingPattern.FindStringSubmatch(inner); m != nil {
+		inner = m[1] + m[2]
+	}
+	return cleanText(inner)
+}
+
+// cleanText strips tags from an HTML fragment, decodes its entities and
+// collapses whitespace
+func cleanText(fragment string) string {
+	text := html.UnescapeString(tagPattern.ReplaceAllString(fragment, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// resultDetails returns the displayed URL and snippet of the result whose
+// link block starts with: from inside the link's opening tag up to the
+// next result. Either is "" when the layout does not show it.
+func resultDetails(block string) (display, snippet string) {
+	if len(block) > maxResultBlock {
+		block = block[:maxResultBlock]
+	}
+
+	if m := displayPattern.FindStringSubmatch(block); m != nil {
+		display = cleanText(m[1] + m[2])
+	}
+
+	// The snippet follows the link, whose anchor may hold look-alike
+	// containers of its own
+	rest := block
+	if end := strings.Index(rest, "</a>"); end >= 0 {
+		rest = rest[end+len("</a>"):]
+	}
+	if loc := snippetPattern.FindStringSubmatchIndex(rest); loc != nil {
+		inner := rest[loc[1]:]
+		closing := "</" + rest[loc[2]:loc[3]] + ">"
+		if end := strings.Index(inner, closing); end >= 0 {
+			snippet = cleanText(inner[:end])
+		}
+	}
+	return display, snippet
+}
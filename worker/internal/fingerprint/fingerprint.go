@@ -0,0 +1,327 @@
+// Package fingerprint identifies the application behind result hosts the
+// way Shodan does: by the MurmurHash3 of the host's favicon and the title
+// of its root page. Hosts sharing a favicon hash mostly run the same
+// software, so the hash pivots from one finding to its siblings.
+package fingerprint
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dorker/worker/internal/engine"
+)
+
+// Config describes host fingerprinting; it is off unless Enabled
+type Config struct {
+	Enabled bool `json:"enabled"`
+
+	// Timeout bounds the fingerprinting of one host, favicon included
+	Timeout time.Duration `json:"timeout"`
+
+	// MaxBytes bounds what is read of the root page and of the favicon
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// Defaults for unset Config fields
+const (
+	DefaultTimeout  = 15 * time.Second
+	DefaultMaxBytes = 1 << 20
+)
+
+// maxHosts bounds the hosts kept for the report; hosts beyond it are not
+// fingerprinted
+const maxHosts = 50000
+
+// maxTitle bounds the length of a kept title, in bytes
+const maxTitle = 256
+
+// Fingerprinter fingerprints each host once per run and keeps the outcome,
+// failures included, for clustering. It is safe for concurrent use.
+type Fingerprinter struct {
+	timeout  time.Duration
+	maxBytes int64
+
+	mu    sync.Mutex
+	hosts map[string]*engine.Fingerprint // By origin; nil for hosts that failed
+}
+
+// New returns the fingerprinter described by config, or nil when it is
+// disabled
+func New(config Config) *Fingerprinter {
+	if !config.Enabled {
+		return nil
+	}
+
+	f := &Fingerprinter{
+		timeout:  config.Timeout,
+		maxBytes: config.MaxBytes,
+		hosts:    make(map[string]*engine.Fingerprint),
+	}
+	if f.timeout <= 0 {
+		f.timeout = DefaultTimeout
+	}
+	if f.maxBytes <= 0 {
+		f.maxBytes = DefaultMaxBytes
+	}
+	return f
+}
+
+// Origin returns the scheme and host of rawURL, which key fingerprints, or
+// "" when it has none
+func Origin(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ""
+	}
+	return parsed.Scheme + "://" + strings.ToLower(parsed.Host)
+}
+
+// Cached returns the fingerprint of an origin seen before, nil when it
+// could not be taken. ok is false for an origin not seen yet, or one that
+// is not taken as the host limit is reached.
+func (f *Fingerprinter) Cached(origin string) (fingerprint *engine.Fingerprint, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fingerprint, ok = f.hosts[origin]
+	return fingerprint, ok
+}
+
+// Full reports whether the host limit is reached
+func (f *Fingerprinter) Full() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.hosts) >= maxHosts
+}
+
+// Take fingerprints origin through transport and keeps the outcome. The
+// root page gives the title and names the favicon in its icon link; a
+// page without one falls back to /favicon.ico. A host whose page cannot be
+// fetched fails; one without a favicon has a hash of 0.
+func (f *Fingerprinter) Take(ctx context.Context, transport http.RoundTripper, origin string) (*engine.Fingerprint, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	client := &http.Client{Transport: transport}
+
+	fingerprint, err := f.take(ctx, client, origin)
+
+	f.mu.Lock()
+	if _, ok := f.hosts[origin]; ok || len(f.hosts) < maxHosts {
+		f.hosts[origin] = fingerprint
+	}
+	f.mu.Unlock()
+	return fingerprint, err
+}
+
+func (f *Fingerprinter) take(ctx context.Context, client *http.Client, origin string) (*engine.Fingerprint, error) {
+	page, base, err := f.get(ctx, client, origin+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := &engine.Fingerprint{Title: Title(page)}
+	icon := IconURL(page, base)
+	if icon == "" {
+		icon = origin + "/favicon.ico"
+	}
+	if data, _, err := f.get(ctx, client, icon); err == nil && len(data) > 0 {
+		fingerprint.FaviconHash = Hash([]byte(data))
+	}
+	return fingerprint, nil
+}
+
+// get fetches target and returns its body and final URL; a status other
+// than 200 is an error
+func (f *Fingerprinter) get(ctx context.Context, client *http.Client, target string) (string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("%s: status %d", target, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", target, err)
+	}
+	return string(body), resp.Request.URL, nil
+}
+
+var (
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	linkPattern  = regexp.MustCompile(`(?i)<link\s[^>]*>`)
+	relPattern   = regexp.MustCompile(`(?i)\brel\s*=\s*["']?([^"'>]+)`)
+	hrefPattern  = regexp.MustCompile(`(?i)\bhref\s*=\s*["']?([^"'\s>]+)`)
+)
+
+// Title returns the text of a page's <title>, entities decoded and white
+// space collapsed
+func Title(page string) string {
+	m := titlePattern.FindStringSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+	if len(title) > maxTitle {
+		title = strings.ToValidUTF8(title[:maxTitle], "")
+	}
+	return title
+}
+
+// IconURL returns the absolute URL of the first icon a page links, or ""
+// when it links none
+func IconURL(page string, base *url.URL) string {
+	for _, tag := range linkPattern.FindAllString(page, -1) {
+		rel := relPattern.FindStringSubmatch(tag)
+		if rel == nil || !hasIconRel(rel[1]) {
+			continue
+		}
+		href := hrefPattern.FindStringSubmatch(tag)
+		if href == nil {
+			continue
+		}
+		icon, err := base.Parse(html.UnescapeString(href[1]))
+		if err != nil || (icon.Scheme != "http" && icon.Scheme != "https") {
+			continue
+		}
+		return icon.String()
+	}
+	return ""
+}
+
+// hasIconRel reports whether a rel attribute names an icon: "icon" or
+// "shortcut icon", not "apple-touch-icon"
+func hasIconRel(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "icon" {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns Shodan's http.favicon.hash of a favicon: the signed 32-bit
+// MurmurHash3 of its base64 encoding, wrapped at 76 characters with a
+// trailing newline as Python's base64.encodebytes writes it
+func Hash(favicon []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(favicon)
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76])
+		wrapped.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded)
+	wrapped.WriteByte('\n')
+	return int32(murmur3([]byte(wrapped.String())))
+}
+
+// murmur3 is MurmurHash3's x86 32-bit variant with seed 0
+func murmur3(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h uint32
+
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch tail := data[n:]; len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// Cluster is a set of hosts sharing a favicon hash
+type Cluster struct {
+	FaviconHash int32
+	Title       string   // The most common title among Hosts
+	Hosts       []string // Origins, sorted
+}
+
+// Clusters groups the fingerprinted hosts with a favicon by its hash,
+// largest cluster first
+func (f *Fingerprinter) Clusters() []Cluster {
+	f.mu.Lock()
+	byHash := make(map[int32][]string)
+	titles := make(map[int32]map[string]int)
+	for origin, fingerprint := range f.hosts {
+		if fingerprint == nil || fingerprint.FaviconHash == 0 {
+			continue
+		}
+		hash := fingerprint.FaviconHash
+		byHash[hash] = append(byHash[hash], origin)
+		if titles[hash] == nil {
+			titles[hash] = make(map[string]int)
+		}
+		if fingerprint.Title != "" {
+			titles[hash][fingerprint.Title]++
+		}
+	}
+	f.mu.Unlock()
+
+	clusters := make([]Cluster, 0, len(byHash))
+	for hash, hosts := range byHash {
+		sort.Strings(hosts)
+		cluster := Cluster{FaviconHash: hash, Hosts: hosts}
+		best := 0
+		for title, count := range titles[hash] {
+			if count > best || (count == best && title < cluster.Title) {
+				cluster.Title, best = title, count
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Hosts) != len(clusters[j].Hosts) {
+			return len(clusters[i].Hosts) > len(clusters[j].Hosts)
+		}
+		return clusters[i].FaviconHash < clusters[j].FaviconHash
+	})
+	return clusters
+}
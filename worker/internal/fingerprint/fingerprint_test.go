@@ -0,0 +1,129 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		data string
+		want uint32
+	}{
+		{"", 0},
+		{"hello", 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, tt := range tests {
+		if got := murmur3([]byte(tt.data)); got != tt.want {
+			t.Errorf("murmur3(%q) = %#x, want %#x", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestHashWrapsLikePython(t *testing.T) {
+	// 57 bytes encode to exactly one 76-character line; 58 need a second
+	for _, n := range []int{57, 58, 200} {
+		favicon := bytes.Repeat([]byte{0x89}, n)
+		encoded := base64Lines(favicon)
+		if got, want := Hash(favicon), int32(murmur3([]byte(encoded))); got != want {
+			t.Errorf("%d bytes: Hash = %d, want %d", n, got, want)
+		}
+	}
+}
+
+// base64Lines is Python's base64.encodebytes
+func base64Lines(data []byte) string {
+	var b strings.Builder
+	for len(data) > 0 {
+		chunk := data[:min(57, len(data))]
+		data = data[len(chunk):]
+		b.WriteString(base64.StdEncoding.EncodeToString(chunk))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestTitleAndIcon(t *testing.T) {
+	page := `<html><head><title>
+		Jenkins &amp; Friends </title>
+		<link rel="apple-touch-icon" href="/touch.png">
+		<link href="static/fav.ico?v=2" rel="shortcut icon"></head></html>`
+	base, _ := url.Parse("https://ci.example/login/")
+
+	if got := Title(page); got != "Jenkins & Friends" {
+		t.Errorf("Title = %q", got)
+	}
+	if got := IconURL(page, base); got != "https://ci.example/login/static/fav.ico?v=2" {
+		t.Errorf("IconURL = %q", got)
+	}
+	if got := IconURL("<title>x</title>", base); got != "" {
+		t.Errorf("page without icon link: IconURL = %q", got)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	tests := map[string]string{
+		"https://A.example:8443/x?y=1": "https://a.example:8443",
+		"http://b.example":             "http://b.example",
+		"ftp://c.example/":             "",
+		"not a url":                    "",
+	}
+	for rawURL, want := range tests {
+		if got := Origin(rawURL); got != want {
+			t.Errorf("Origin(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestTakeAndClusters(t *testing.T) {
+	icon := []byte("\x00\x00\x01\x00fake icon")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<title>Grafana</title>`)
+		case "/favicon.ico":
+			w.Write(icon)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := New(Config{Enabled: true})
+	origin := Origin(server.URL)
+	got, err := f.Take(context.Background(), http.DefaultTransport, origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Grafana" || got.FaviconHash != Hash(icon) {
+		t.Errorf("fingerprint = %+v, want Grafana with hash %d", got, Hash(icon))
+	}
+	if cached, ok := f.Cached(origin); !ok || cached != got {
+		t.Errorf("Cached = %+v, %v", cached, ok)
+	}
+
+	// A host that cannot be reached is kept as failed
+	if _, err := f.Take(context.Background(), http.DefaultTransport, "http://127.0.0.1:1"); err == nil {
+		t.Error("unreachable host gave no error")
+	}
+	if cached, ok := f.Cached("http://127.0.0.1:1"); !ok || cached != nil {
+		t.Errorf("failed host: Cached = %+v, %v", cached, ok)
+	}
+
+	clusters := f.Clusters()
+	if len(clusters) != 1 || clusters[0].Title != "Grafana" || len(clusters[0].Hosts) != 1 {
+		t.Errorf("clusters = %+v", clusters)
+	}
+
+	if New(Config{}) != nil {
+		t.Error("disabled config should give a nil fingerprinter")
+	}
+}
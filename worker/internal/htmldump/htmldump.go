@@ -0,0 +1,52 @@
+package htmldump
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dumper writes raw results pages to a directory for debugging the parser.
+// Pages are written straight from the fetch buffer, so keeping them costs
+// no memory past the request.
+type Dumper struct {
+	dir string
+}
+
+// Open creates dir if needed and returns a dumper writing into it
+func Open(dir string) (*Dumper, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HTML dump directory: %w", err)
+	}
+	return &Dumper{dir: dir}, nil
+}
+
+// WritePage stores one response as <task>_p<page>_a<attempt>.html, replacing
+// an earlier dump of the same attempt
+func (d *Dumper) WritePage(taskID string, page, attempt int, html []byte) error {
+	name := fmt.Sprintf("%s_p%d_a%d.html", safeName(taskID), page, attempt)
+	if err := os.WriteFile(filepath.Join(d.dir, name), html, 0644); err != nil {
+		return fmt.Errorf("failed to dump page: %w", err)
+	}
+	return nil
+}
+
+// Dir returns the directory pages are written to
+func (d *Dumper) Dir() string {
+	return d.dir
+}
+
+// safeName keeps task IDs from the controller from escaping the directory
+func safeName(id string) string {
+	if id == "" {
+		return "task"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, id)
+}
@@ -0,0 +1,38 @@
+package htmldump
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDumperWritePage(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pages")
+	d, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if err := d.WritePage("task_1", 2, 1, []byte("<html>page</html>")); err != nil {
+		t.Fatalf("WritePage: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "task_1_p2_a1.html"))
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	if string(data) != "<html>page</html>" {
+		t.Errorf("dump = %q", data)
+	}
+}
+
+func TestDumperSafeName(t *testing.T) {
+	d, _ := Open(t.TempDir())
+
+	// IDs come from the controller and must not leave the directory
+	if err := d.WritePage("../../etc/x", 0, 1, []byte("x")); err != nil {
+		t.Fatalf("WritePage: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(d.Dir(), "______etc_x_p0_a1.html")); err != nil {
+		t.Errorf("dump not written inside the directory: %v", err)
+	}
+}
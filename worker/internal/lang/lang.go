@@ -0,0 +1,148 @@
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts identifies languages written in their own script, checked in
+// order; kana wins over Han so Japanese text is not taken for Chinese
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// stopwords are common short words of each Latin-script language; a word
+// shared by several languages counts for each
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "for", "with", "on", "you", "your", "this", "that", "are", "from", "how", "what", "by", "at", "be", "or", "not", "an"},
+	"es": {"el", "la", "los", "las", "de", "del", "y", "en", "que", "por", "para", "con", "una", "un", "es", "se", "su", "como", "más", "al", "lo", "sin", "sobre"},
+	"fr": {"le", "la", "les", "des", "de", "du", "et", "en", "un", "une", "pour", "dans", "est", "sur", "avec", "au", "aux", "que", "qui", "par", "pas", "ce", "vous"},
+	"de": {"der", "die", "das", "und", "ist", "mit", "für", "von", "den", "dem", "ein", "eine", "nicht", "auf", "zu", "im", "sie", "wie", "auch", "bei", "oder"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "del", "della", "e", "che", "per", "con", "un", "una", "non", "sono", "è", "da", "nel", "alla", "come"},
+	"pt": {"o", "os", "a", "as", "de", "do", "da", "dos", "das", "e", "em", "que", "para", "com", "um", "uma", "não", "no", "na", "por", "se", "como", "mais"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "voor", "met", "niet", "zijn", "ook", "naar", "bij", "uit", "wat", "hoe"},
+	"pl": {"i", "w", "z", "na", "się", "nie", "do", "że", "jest", "to", "jak", "od", "dla", "po", "przez", "oraz"},
+	"tr": {"ve", "bir", "bu", "için", "ile", "da", "de", "ne", "çok", "olarak", "gibi", "daha", "en", "nasıl"},
+}
+
+// letterHints are letters that point at a few Latin-script languages
+var letterHints = map[rune][]string{
+	'ñ': {"es"},
+	'ß': {"de"},
+	'ä': {"de"},
+	'ã': {"pt"},
+	'õ': {"pt"},
+	'ç': {"fr", "pt", "tr"},
+	'è': {"fr", "it"},
+	'ê': {"fr", "pt"},
+	'ł': {"pl"},
+	'ś': {"pl"},
+	'ż': {"pl"},
+	'ą': {"pl"},
+	'ę': {"pl"},
+	'ğ': {"tr"},
+	'ş': {"tr"},
+	'ı': {"tr"},
+}
+
+// wordLangs maps each stopword to the languages using it
+var wordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, word := range words {
+			m[word] = append(m[word], lang)
+		}
+	}
+	return m
+}()
+
+// Detect returns the ISO 639-1 code of the language text is written in, or
+// "" when it cannot tell, as with very short or mixed text. Scripts used by
+// a single language decide outright; Latin text is scored on common words
+// and telltale letters.
+func Detect(text string) string {
+	var letters, latin int
+	counts := make(map[string]int)
+	scores := make(map[string]int)
+
+	for _, r := range strings.ToLower(text) {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			for _, lang := range letterHints[r] {
+				scores[lang]++
+			}
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// A script of its own covering most letters settles it
+	if latin*2 < letters {
+		if counts["ja"] > 0 {
+			return "ja"
+		}
+		return best(counts)
+	}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range wordLangs[word] {
+			scores[lang]++
+		}
+	}
+	return best(scores)
+}
+
+// best returns the language with the highest score, or "" on a tie or when
+// nothing scored
+func best(scores map[string]int) string {
+	var lang string
+	top, tied := 0, false
+	for l, score := range scores {
+		switch {
+		case score > top:
+			lang, top, tied = l, score, false
+		case score == top:
+			tied = true
+		}
+	}
+	if top == 0 || tied {
+		return ""
+	}
+	return lang
+}
+
+// Normalize reduces a language tag such as "es-MX" to its lowercase
+// primary code
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
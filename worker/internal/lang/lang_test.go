@@ -0,0 +1,37 @@
+package lang
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"How to configure the admin panel for your site", "en"},
+		{"Cómo configurar el panel de administración para una tienda", "es"},
+		{"Comment configurer le panneau pour les utilisateurs et des clients", "fr"},
+		{"Die Verwaltung der Benutzer und das Passwort ändern", "de"},
+		{"Como configurar o painel de administração não funciona", "pt"},
+		{"Конфигурация панели администратора", "ru"},
+		{"管理パネルの設定", "ja"},
+		{"管理面板设置", "zh"},
+		{"관리자 패널 설정", "ko"},
+		{"phpMyAdmin", ""},
+		{"404", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{"es-MX": "es", " PT_br ": "pt", "en": "en"} {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level represents log severity
+type Level int
+
+// Log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the level name used on the wire
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseLevel converts a level name to a Level
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", s)
+	}
+}
+
+// Entry is a single log record
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   Level     `json:"level"`
+	Message string    `json:"message"`
+}
+
+// Format renders the entry as a single text line
+func (e Entry) Format() string {
+	return fmt.Sprintf("%s [%s] %s", e.Time.Format(time.RFC3339), strings.ToUpper(e.Level.String()), e.Message)
+}
+
+// Sink receives log entries
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// Logger fans entries out to its sinks
+type Logger struct {
+	mu       sync.RWMutex
+	minLevel Level
+	sinks    []Sink
+}
+
+// New creates a logger that drops entries below minLevel
+func New(minLevel Level, sinks ...Sink) *Logger {
+	return &Logger{
+		minLevel: minLevel,
+		sinks:    sinks,
+	}
+}
+
+// AddSink attaches another sink
+func (l *Logger) AddSink(sink Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks, sink)
+}
+
+// SetLevel changes the minimum level
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.minLevel = level
+}
+
+// Log writes a message to every sink. Sink errors are ignored so that a
+// failing sink never takes the worker down.
+func (l *Logger) Log(level Level, message string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level < l.minLevel {
+		return
+	}
+
+	entry := Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: message,
+	}
+	for _, sink := range l.sinks {
+		sink.Write(entry)
+	}
+}
+
+// Debugf logs a formatted debug message
+func (l *Logger) Debugf(format string, args ...any) {
+	l.Log(LevelDebug, fmt.Sprintf(format, args...))
+}
+
+// Infof logs a formatted info message
+func (l *Logger) Infof(format string, args ...any) {
+	l.Log(LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// Warnf logs a formatted warning
+func (l *Logger) Warnf(format string, args ...any) {
+	l.Log(LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a formatted error
+func (l *Logger) Errorf(format string, args ...any) {
+	l.Log(LevelError, fmt.Sprintf(format, args...))
+}
+
+// Close closes every sink
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var firstErr error
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	l.sinks = nil
+	return firstErr
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Level
+		err   bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"loud", LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.input, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerMinLevel(t *testing.T) {
+	ring := NewRingBuffer(10)
+	logger := New(LevelWarn, ring)
+
+	logger.Debugf("debug %d", 1)
+	logger.Infof("info")
+	logger.Warnf("warn")
+	logger.Errorf("error: %s", "boom")
+
+	entries := ring.Entries(0, LevelDebug)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+	if entries[1].Message != "error: boom" {
+		t.Errorf("message = %q", entries[1].Message)
+	}
+
+	logger.SetLevel(LevelDebug)
+	logger.Debugf("now visible")
+	if got := len(ring.Entries(0, LevelDebug)); got != 3 {
+		t.Errorf("entries after SetLevel = %d, want 3", got)
+	}
+}
+
+func TestLoggerFanOut(t *testing.T) {
+	var buf bytes.Buffer
+	var forwarded []Entry
+
+	logger := New(LevelInfo, NewWriterSink(&buf))
+	logger.AddSink(FuncSink(func(e Entry) error {
+		forwarded = append(forwarded, e)
+		return nil
+	}))
+	// A failing sink must not stop the others
+	logger.AddSink(FuncSink(func(e Entry) error {
+		return errors.New("sink down")
+	}))
+
+	logger.Infof("hello")
+
+	if !strings.Contains(buf.String(), "[INFO] hello") {
+		t.Errorf("writer sink got %q", buf.String())
+	}
+	if len(forwarded) != 1 || forwarded[0].Level != LevelInfo {
+		t.Errorf("func sink got %v", forwarded)
+	}
+
+	if err := logger.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterSink writes text lines to an io.Writer such as stderr
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink creates a sink writing to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write implements Sink
+func (s *WriterSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintln(s.w, entry.Format())
+	return err
+}
+
+// Close implements Sink; the writer is owned by the caller
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FuncSink adapts a function, e.g. the IPC log message sender, to a Sink
+type FuncSink func(entry Entry) error
+
+// Write implements Sink
+func (f FuncSink) Write(entry Entry) error {
+	return f(entry)
+}
+
+// Close implements Sink
+func (f FuncSink) Close() error {
+	return nil
+}
+
+// FileSink appends to a file and rotates it once it grows past MaxSize.
+// Rotated files are named path.1 (newest) through path.<MaxBackups>.
+type FileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileSink opens path for appending. A maxSize of 0 disables rotation.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the log file and records its current size
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// Write implements Sink
+func (s *FileSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("log file closed")
+	}
+
+	line := entry.Format() + "\n"
+	if s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize && s.size > 0 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.WriteString(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate shifts backups up by one and starts a fresh file
+func (s *FileSink) rotate() error {
+	s.file.Close()
+	s.file = nil
+
+	if s.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	return s.open()
+}
+
+// Close implements Sink
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// RingBuffer keeps the most recent entries in memory for remote debugging
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRingBuffer creates a ring buffer holding up to size entries
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &RingBuffer{entries: make([]Entry, size)}
+}
+
+// Write implements Sink
+func (r *RingBuffer) Write(entry Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// Close implements Sink
+func (r *RingBuffer) Close() error {
+	return nil
+}
+
+// Entries returns up to limit of the newest entries at or above minLevel,
+// oldest first. A limit of 0 returns everything buffered.
+func (r *RingBuffer) Entries(limit int, minLevel Level) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ordered []Entry
+	if r.full {
+		ordered = append(ordered, r.entries[r.next:]...)
+	}
+	ordered = append(ordered, r.entries[:r.next]...)
+
+	result := make([]Entry, 0, len(ordered))
+	for _, entry := range ordered {
+		if entry.Level >= minLevel {
+			result = append(result, entry)
+		}
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result
+}
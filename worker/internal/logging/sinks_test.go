@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.log")
+
+	sink, err := NewFileSink(path, 100, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		entry := Entry{Time: time.Now(), Level: LevelInfo, Message: fmt.Sprintf("line %d", i)}
+		if err := sink.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Errorf("expected %s to exist: %v", filepath.Base(name), err)
+			continue
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s size = %d, want <= 100", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("backups beyond MaxBackups should be removed")
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "line 9") {
+		t.Errorf("current file should hold the newest line, got %q", data)
+	}
+}
+
+func TestFileSinkAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	sink.Write(Entry{Time: time.Now(), Level: LevelWarn, Message: "appended"})
+	sink.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "existing\n") || !strings.Contains(string(data), "[WARN] appended") {
+		t.Errorf("file content = %q", data)
+	}
+
+	if err := sink.Write(Entry{}); err == nil {
+		t.Error("write after close should fail")
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer(3)
+
+	levels := []Level{LevelInfo, LevelError, LevelDebug, LevelWarn, LevelInfo}
+	for i, level := range levels {
+		ring.Write(Entry{Level: level, Message: fmt.Sprintf("m%d", i)})
+	}
+
+	all := ring.Entries(0, LevelDebug)
+	if len(all) != 3 {
+		t.Fatalf("entries = %d, want 3", len(all))
+	}
+	if all[0].Message != "m2" || all[2].Message != "m4" {
+		t.Errorf("entries not oldest-first: %v", all)
+	}
+
+	if got := ring.Entries(1, LevelDebug); len(got) != 1 || got[0].Message != "m4" {
+		t.Errorf("limit should keep newest entries, got %v", got)
+	}
+
+	if got := ring.Entries(0, LevelWarn); len(got) != 1 || got[0].Message != "m3" {
+		t.Errorf("level filter got %v", got)
+	}
+}
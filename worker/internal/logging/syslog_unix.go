@@ -0,0 +1,41 @@
+//go:build !windows
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogSink forwards entries to the local syslog daemon
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink connects to the local syslog daemon using tag
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+// Write implements Sink
+func (s *SyslogSink) Write(entry Entry) error {
+	switch entry.Level {
+	case LevelDebug:
+		return s.w.Debug(entry.Message)
+	case LevelWarn:
+		return s.w.Warning(entry.Message)
+	case LevelError:
+		return s.w.Err(entry.Message)
+	default:
+		return s.w.Info(entry.Message)
+	}
+}
+
+// Close implements Sink
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
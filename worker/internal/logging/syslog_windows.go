@@ -0,0 +1,21 @@
+package logging
+
+import "fmt"
+
+// SyslogSink is unavailable on Windows
+type SyslogSink struct{}
+
+// NewSyslogSink always fails on Windows
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	return nil, fmt.Errorf("syslog is not supported on windows")
+}
+
+// Write implements Sink
+func (s *SyslogSink) Write(entry Entry) error {
+	return nil
+}
+
+// Close implements Sink
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"dorker/worker/internal/seal"
+)
+
+// rankedEntry is one URL at its absolute SERP position
+type rankedEntry struct {
+	position int
+	url      string
+}
+
+// RankedWriter collects results per dork and writes them on Close as
+// tab-separated dork, position and URL lines, each dork's URLs by absolute
+// SERP position and dorks in the order first seen. Pages of a dork finish
+// in any order, so nothing is written before Close and every result is held
+// in memory until then.
+type RankedWriter struct {
+	mu     sync.Mutex
+	path   string
+	key    []byte
+	dorks  []string
+	ranks  map[string][]rankedEntry
+	closed bool
+}
+
+// NewRankedWriter returns a writer that creates path on Close, encrypted
+// with package seal when key is set
+func NewRankedWriter(path string, key []byte) *RankedWriter {
+	return &RankedWriter{
+		path:  path,
+		key:   key,
+		ranks: make(map[string][]rankedEntry),
+	}
+}
+
+// Add records url at position among dork's results
+func (r *RankedWriter) Add(dork, url string, position int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ranks[dork]; !ok {
+		r.dorks = append(r.dorks, dork)
+	}
+	r.ranks[dork] = append(r.ranks[dork], rankedEntry{position: position, url: url})
+}
+
+// Close sorts every dork's results and writes the file. Later calls do
+// nothing.
+func (r *RankedWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to create ranked output file: %w", err)
+	}
+	var out io.Writer = f
+	var sealed *seal.Writer
+	if r.key != nil {
+		if sealed, err = seal.NewWriter(f, r.key); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to encrypt ranked output file: %w", err)
+		}
+		out = sealed
+	}
+
+	buf := bufio.NewWriter(out)
+	for _, dork := range r.dorks {
+		entries := r.ranks[dork]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].position < entries[j].position })
+		for _, e := range entries {
+			fmt.Fprintf(buf, "%s\t%d\t%s\n", dork, e.position, e.url)
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if sealed != nil {
+		if err := sealed.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,187 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"dorker/worker/internal/dorklist"
+	"dorker/worker/internal/seal"
+)
+
+// NucleiDefault names the nuclei list of URLs whose dorks call for no
+// particular templates
+const NucleiDefault = "default"
+
+// nucleiTags are the nuclei template tags worth running on the results of
+// a sensitive dork category
+var nucleiTags = map[string][]string{
+	dorklist.CategoryCredentials:  {"exposure", "config"},
+	dorklist.CategoryPrivateKeys:  {"exposure"},
+	dorklist.CategoryPersonalData: {"exposure"},
+	dorklist.CategoryDatabaseDump: {"exposure", "backup"},
+}
+
+// NucleiTags returns the nuclei template tags for a dork's results, sorted,
+// from the sensitive categories it falls in, or none
+func NucleiTags(dork string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, category := range dorklist.Sensitive(dork) {
+		for _, tag := range nucleiTags[category] {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// ScanWriter collects results and writes them on Close as scanner input:
+// an httpx target list of every URL once, and a directory of nuclei target
+// lists, one per set of template tags, each named for its tags, such as
+// "backup,exposure.txt", so it runs as
+//
+//	nuclei -l backup,exposure.txt -tags backup,exposure
+//
+// and URLs without tags in default.txt. A URL found by several dorks gets
+// the tags of them all. Nothing is written before Close and every URL is
+// held in memory until then.
+type ScanWriter struct {
+	mu        sync.Mutex
+	httpxPath string
+	nucleiDir string
+	suffix    string
+	key       []byte
+	urls      []string
+	tags      map[string]map[string]bool
+	dorkTags  map[string][]string
+	closed    bool
+}
+
+// NewScanWriter returns a writer that creates httpxPath and nucleiDir on
+// Close, either left out when empty. With key set, files are encrypted with
+// package seal and named with suffix.
+func NewScanWriter(httpxPath, nucleiDir, suffix string, key []byte) *ScanWriter {
+	return &ScanWriter{
+		httpxPath: httpxPath,
+		nucleiDir: nucleiDir,
+		suffix:    suffix,
+		key:       key,
+		tags:      make(map[string]map[string]bool),
+		dorkTags:  make(map[string][]string),
+	}
+}
+
+// Add records url as a result of dork
+func (s *ScanWriter) Add(dork, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags, ok := s.dorkTags[dork]
+	if !ok {
+		tags = NucleiTags(dork)
+		s.dorkTags[dork] = tags
+	}
+
+	urlTags, ok := s.tags[url]
+	if !ok {
+		urlTags = make(map[string]bool)
+		s.tags[url] = urlTags
+		s.urls = append(s.urls, url)
+	}
+	for _, tag := range tags {
+		urlTags[tag] = true
+	}
+}
+
+// Close writes the files. Later calls do nothing.
+func (s *ScanWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	if s.httpxPath != "" {
+		if err := s.writeList(s.httpxPath, s.urls); err != nil {
+			return fmt.Errorf("failed to write httpx targets: %w", err)
+		}
+	}
+	if s.nucleiDir == "" {
+		return nil
+	}
+
+	// Group URLs by tag set, keeping their order within each list
+	var names []string
+	lists := make(map[string][]string)
+	for _, url := range s.urls {
+		tags := make([]string, 0, len(s.tags[url]))
+		for tag := range s.tags[url] {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		name := strings.Join(tags, ",")
+		if name == "" {
+			name = NucleiDefault
+		}
+		if _, ok := lists[name]; !ok {
+			names = append(names, name)
+		}
+		lists[name] = append(lists[name], url)
+	}
+
+	if err := os.MkdirAll(s.nucleiDir, 0755); err != nil {
+		return fmt.Errorf("failed to create nuclei target directory: %w", err)
+	}
+	for _, name := range names {
+		path := filepath.Join(s.nucleiDir, name+".txt"+s.suffix)
+		if err := s.writeList(path, lists[name]); err != nil {
+			return fmt.Errorf("failed to write nuclei targets: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeList creates path with one URL per line
+func (s *ScanWriter) writeList(path string, urls []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var out io.Writer = f
+	var sealed *seal.Writer
+	if s.key != nil {
+		if sealed, err = seal.NewWriter(f, s.key); err != nil {
+			f.Close()
+			return err
+		}
+		out = sealed
+	}
+
+	buf := bufio.NewWriter(out)
+	for _, url := range urls {
+		buf.WriteString(url)
+		buf.WriteByte('\n')
+	}
+	if err := buf.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if sealed != nil {
+		if err := sealed.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNucleiTags(t *testing.T) {
+	tests := []struct {
+		dork string
+		want []string
+	}{
+		{"inurl:admin", nil},
+		{"filetype:env", []string{"config", "exposure"}},
+		{`filetype:sql "insert into" users password`, []string{"backup", "config", "exposure"}},
+	}
+	for _, tt := range tests {
+		if got := NucleiTags(tt.dork); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NucleiTags(%q) = %v, want %v", tt.dork, got, tt.want)
+		}
+	}
+}
+
+func TestScanWriter(t *testing.T) {
+	dir := t.TempDir()
+	httpxPath := filepath.Join(dir, "httpx.txt")
+	nucleiDir := filepath.Join(dir, "nuclei")
+
+	w := NewScanWriter(httpxPath, nucleiDir, "", nil)
+	w.Add("inurl:admin", "https://a.example/admin")
+	w.Add("filetype:env", "https://b.example/.env")
+	w.Add("inurl:login", "https://c.example/login")
+	// Found again by a sensitive dork, it moves to that dork's list
+	w.Add("filetype:env", "https://a.example/admin")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := readLines(t, httpxPath)
+	want := []string{"https://a.example/admin", "https://b.example/.env", "https://c.example/login"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("httpx targets = %v, want %v", got, want)
+	}
+
+	got = readLines(t, filepath.Join(nucleiDir, "config,exposure.txt"))
+	want = []string{"https://a.example/admin", "https://b.example/.env"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config,exposure targets = %v, want %v", got, want)
+	}
+	got = readLines(t, filepath.Join(nucleiDir, NucleiDefault+".txt"))
+	want = []string{"https://c.example/login"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("default targets = %v, want %v", got, want)
+	}
+
+	entries, err := os.ReadDir(nucleiDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("nuclei lists = %d, want 2", len(entries))
+	}
+}
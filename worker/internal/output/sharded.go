@@ -0,0 +1,308 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"dorker/worker/internal/seal"
+)
+
+// Ordering states which line order a ShardedWriter preserves
+type Ordering string
+
+const (
+	OrderNone   Ordering = "none"   // Any order; writers spread over all shards
+	OrderKey    Ordering = "key"    // Lines sharing a key keep their relative order
+	OrderStrict Ordering = "strict" // Lines appear exactly in write order (one shard)
+)
+
+// Valid reports whether o is a known ordering; empty means OrderNone
+func (o Ordering) Valid() bool {
+	switch o {
+	case "", OrderNone, OrderKey, OrderStrict:
+		return true
+	}
+	return false
+}
+
+// ShardedConfig configures a ShardedWriter
+type ShardedConfig struct {
+	Shards      int      // Number of buffers; forced to 1 for OrderStrict
+	BufferSize  int      // Bytes a shard buffers before writing to the file
+	Ordering    Ordering // Order guarantee; OrderNone by default
+	MaxFileSize int64    // Rotate to a new file past this size; 0 never rotates
+	Key         []byte   // Encrypt every file with package seal when set
+}
+
+// DefaultShardedConfig returns 16 shards of 64 KB with no ordering guarantee
+func DefaultShardedConfig() ShardedConfig {
+	return ShardedConfig{
+		Shards:     16,
+		BufferSize: 64 * 1024,
+		Ordering:   OrderNone,
+	}
+}
+
+// shard is one line buffer with its own lock, padded so neighbouring shards
+// do not share a cache line
+type shard struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	_   [64]byte
+}
+
+// ShardedWriter appends lines to a file through per-shard buffers so
+// concurrent writers contend on a shard lock instead of one file lock. Full
+// shard buffers are written to the file whole; on rotation and Flush every
+// shard is merged into the current file first.
+type ShardedWriter struct {
+	config ShardedConfig
+	shards []*shard
+
+	// fileMu is taken after shard locks, never before
+	fileMu   sync.Mutex
+	base     string
+	file     *os.File
+	out      io.Writer // file, or a sealed writer over it
+	size     int64
+	sequence int
+	files    []string
+	closed   bool
+}
+
+// NewShardedWriter creates path and returns a writer appending to it.
+// Rotated files are named path with .1, .2, ... before the extension.
+func NewShardedWriter(path string, config ShardedConfig) (*ShardedWriter, error) {
+	defaults := DefaultShardedConfig()
+	if config.Shards <= 0 {
+		config.Shards = defaults.Shards
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if !config.Ordering.Valid() {
+		return nil, fmt.Errorf("unknown output ordering %q (want none, key or strict)", config.Ordering)
+	}
+	switch config.Ordering {
+	case "":
+		config.Ordering = OrderNone
+	case OrderStrict:
+		config.Shards = 1
+	}
+
+	s := &ShardedWriter{
+		config: config,
+		shards: make([]*shard, config.Shards),
+		base:   path,
+	}
+	for i := range s.shards {
+		s.shards[i] = &shard{}
+	}
+
+	if err := s.openFile(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// openFile switches output to a new file; fileMu must be held or the writer
+// unshared
+func (s *ShardedWriter) openFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	s.out = f
+	if s.config.Key != nil {
+		sealed, err := seal.NewWriter(f, s.config.Key)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to encrypt output file: %w", err)
+		}
+		s.out = sealed
+	}
+	s.file = f
+	s.size = 0
+	s.files = append(s.files, path)
+	return nil
+}
+
+// closeFile ends the current file, writing the sealed writer's last record
+// first; fileMu must be held
+func (s *ShardedWriter) closeFile() error {
+	if sealed, ok := s.out.(*seal.Writer); ok {
+		if err := sealed.Close(); err != nil {
+			s.file.Close()
+			return err
+		}
+	}
+	return s.file.Close()
+}
+
+// pick chooses the shard for a line
+func (s *ShardedWriter) pick(key string) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	if s.config.Ordering == OrderKey {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return s.shards[h.Sum32()%uint32(len(s.shards))]
+	}
+	// The per-thread generator avoids a shared counter writers would contend on
+	return s.shards[rand.IntN(len(s.shards))]
+}
+
+// WriteLine buffers line plus a newline. key groups lines under OrderKey and
+// is ignored otherwise.
+func (s *ShardedWriter) WriteLine(key, line string) error {
+	sh := s.pick(key)
+
+	sh.mu.Lock()
+	sh.buf.WriteString(line)
+	sh.buf.WriteByte('\n')
+	if sh.buf.Len() < s.config.BufferSize {
+		sh.mu.Unlock()
+		return nil
+	}
+	err := s.writeShard(sh)
+	sh.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.rotateIfFull()
+}
+
+// writeShard moves a shard's buffer into the file; the shard lock must be
+// held so lines sharing a key reach the file in order
+func (s *ShardedWriter) writeShard(sh *shard) error {
+	if sh.buf.Len() == 0 {
+		return nil
+	}
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	n, err := s.out.Write(sh.buf.Bytes())
+	s.size += int64(n)
+	sh.buf.Reset()
+	return err
+}
+
+// lockAll takes every shard lock in index order, matching writeShard's
+// shard-then-file order so rotation cannot deadlock with a flushing shard
+func (s *ShardedWriter) lockAll() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+	}
+}
+
+func (s *ShardedWriter) unlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.Unlock()
+	}
+}
+
+// mergeLocked writes every shard to the file; all shard locks must be held
+func (s *ShardedWriter) mergeLocked() error {
+	for _, sh := range s.shards {
+		if err := s.writeShard(sh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateIfFull merges all shards into the current file and starts the next
+// one once the size limit is passed
+func (s *ShardedWriter) rotateIfFull() error {
+	if s.config.MaxFileSize <= 0 {
+		return nil
+	}
+
+	s.fileMu.Lock()
+	full := s.size >= s.config.MaxFileSize
+	s.fileMu.Unlock()
+	if !full {
+		return nil
+	}
+
+	s.lockAll()
+	defer s.unlockAll()
+
+	// Another writer may have rotated while the locks were taken
+	s.fileMu.Lock()
+	full = s.size >= s.config.MaxFileSize
+	s.fileMu.Unlock()
+	if !full {
+		return nil
+	}
+
+	if err := s.mergeLocked(); err != nil {
+		return err
+	}
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+	s.sequence++
+	return s.openFile(rotatedPath(s.base, s.sequence))
+}
+
+// rotatedPath inserts the sequence number before the extension:
+// results.txt becomes results.1.txt
+func rotatedPath(base string, sequence int) string {
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), sequence, ext)
+}
+
+// Flush merges every shard into the current file
+func (s *ShardedWriter) Flush() error {
+	s.lockAll()
+	defer s.unlockAll()
+	return s.mergeLocked()
+}
+
+// Sync flushes and commits the current file to stable storage
+func (s *ShardedWriter) Sync() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	return s.file.Sync()
+}
+
+// Close flushes and closes the current file. Later calls do nothing.
+func (s *ShardedWriter) Close() error {
+	flushErr := s.Flush()
+
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// Files returns every file written so far, oldest first
+func (s *ShardedWriter) Files() []string {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	return append([]string(nil), s.files...)
+}
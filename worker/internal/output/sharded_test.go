@@ -0,0 +1,267 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"dorker/worker/internal/seal"
+)
+
+func readLines(t *testing.T, paths ...string) []string {
+	t.Helper()
+
+	var lines []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if text := strings.TrimSuffix(string(data), "\n"); text != "" {
+			lines = append(lines, strings.Split(text, "\n")...)
+		}
+	}
+	return lines
+}
+
+func TestShardedWriterConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	w, err := NewShardedWriter(path, ShardedConfig{Shards: 4, BufferSize: 128})
+	if err != nil {
+		t.Fatalf("NewShardedWriter: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 250; i++ {
+				w.WriteLine("", fmt.Sprintf("https://site%d.example/%d", g, i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != 2000 {
+		t.Fatalf("lines = %d, want 2000", len(lines))
+	}
+	sort.Strings(lines)
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == lines[i-1] {
+			t.Fatalf("line %q written twice", lines[i])
+		}
+	}
+}
+
+func TestShardedWriterKeyOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	w, _ := NewShardedWriter(path, ShardedConfig{Shards: 8, BufferSize: 64, Ordering: OrderKey})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			key := fmt.Sprintf("dork_%d", g)
+			for i := 0; i < 200; i++ {
+				w.WriteLine(key, fmt.Sprintf("%s %d", key, i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	w.Close()
+
+	// Each key's lines must appear in the order they were written
+	last := make(map[string]int)
+	for _, line := range readLines(t, path) {
+		var key string
+		var i int
+		fmt.Sscanf(line, "%s %d", &key, &i)
+		if prev, ok := last[key]; ok && i != prev+1 {
+			t.Fatalf("%s: line %d follows %d", key, i, prev)
+		}
+		last[key] = i
+	}
+}
+
+func TestShardedWriterStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	w, _ := NewShardedWriter(path, ShardedConfig{Shards: 8, BufferSize: 16, Ordering: OrderStrict})
+
+	for i := 0; i < 100; i++ {
+		w.WriteLine("", fmt.Sprint(i))
+	}
+	w.Close()
+
+	for i, line := range readLines(t, path) {
+		if line != fmt.Sprint(i) {
+			t.Fatalf("line %d = %q", i, line)
+		}
+	}
+
+	if _, err := NewShardedWriter(path, ShardedConfig{Ordering: "sorted"}); err == nil {
+		t.Error("NewShardedWriter should reject an unknown ordering")
+	}
+}
+
+func TestShardedWriterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	w, _ := NewShardedWriter(path, ShardedConfig{Shards: 2, BufferSize: 32, MaxFileSize: 256})
+
+	for i := 0; i < 200; i++ {
+		w.WriteLine("", fmt.Sprintf("https://r.example/%03d", i))
+	}
+	w.Close()
+
+	files := w.Files()
+	if len(files) < 2 {
+		t.Fatalf("files = %v, want rotation", files)
+	}
+	if want := filepath.Join(filepath.Dir(path), "results.1.txt"); files[1] != want {
+		t.Errorf("first rotated file = %q, want %q", files[1], want)
+	}
+
+	// Nothing lost across files, and no file far past the limit
+	if lines := readLines(t, files...); len(lines) != 200 {
+		t.Errorf("lines across files = %d, want 200", len(lines))
+	}
+	for _, f := range files {
+		info, _ := os.Stat(f)
+		if info.Size() > 256+2*32+64 {
+			t.Errorf("%s is %d bytes", f, info.Size())
+		}
+	}
+}
+
+// lockedWriter is the single-writer baseline: one lock around one buffer
+type lockedWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (l *lockedWriter) WriteLine(key, line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.WriteString(line)
+	return l.w.WriteByte('\n')
+}
+
+// The writer benchmarks run writers in parallel; compare them with
+// -cpu 1,8,32 on a multi-core machine. On a single core sharding only adds
+// overhead.
+const benchLine = "https://www.example.com/some/path/page.php?id=12345&category=products"
+
+func BenchmarkSingleWriter(b *testing.B) {
+	f, _ := os.Create(filepath.Join(b.TempDir(), "results.txt"))
+	defer f.Close()
+	w := &lockedWriter{w: bufio.NewWriterSize(f, 64*1024)}
+
+	b.SetBytes(int64(len(benchLine) + 1))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.WriteLine("", benchLine)
+		}
+	})
+	w.w.Flush()
+}
+
+func BenchmarkShardedWriter(b *testing.B) {
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			w, _ := NewShardedWriter(filepath.Join(b.TempDir(), "results.txt"), ShardedConfig{Shards: shards})
+			defer w.Close()
+
+			b.SetBytes(int64(len(benchLine) + 1))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					w.WriteLine("", benchLine)
+				}
+			})
+		})
+	}
+}
+
+func TestRankedWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranked.tsv")
+	w := NewRankedWriter(path, nil)
+
+	// Page 2 of the first dork finishes before page 1
+	w.Add("inurl:admin", "https://c.example/", 11)
+	w.Add("inurl:admin", "https://d.example/", 12)
+	w.Add("intitle:login", "https://x.example/", 1)
+	w.Add("inurl:admin", "https://a.example/", 1)
+	w.Add("inurl:admin", "https://b.example/", 2)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	want := []string{
+		"inurl:admin\t1\thttps://a.example/",
+		"inurl:admin\t2\thttps://b.example/",
+		"inurl:admin\t11\thttps://c.example/",
+		"inurl:admin\t12\thttps://d.example/",
+		"intitle:login\t1\thttps://x.example/",
+	}
+	lines := readLines(t, path)
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestShardedWriterSealed(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, seal.KeySize)
+	path := filepath.Join(t.TempDir(), "results.txt.enc")
+	w, err := NewShardedWriter(path, ShardedConfig{Shards: 1, BufferSize: 64, MaxFileSize: 256, Key: key})
+	if err != nil {
+		t.Fatalf("NewShardedWriter: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		w.WriteLine("", fmt.Sprintf("https://site.example/%d", i))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	files := w.Files()
+	if len(files) < 2 {
+		t.Fatalf("files = %v, want rotation", files)
+	}
+	var lines []string
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := seal.NewReader(f, key)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		plain, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(plain), "\n"), "\n")...)
+	}
+	if len(lines) != 40 || lines[39] != "https://site.example/39" {
+		t.Errorf("lines = %d, last %q", len(lines), lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,194 @@
+package pacing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome classifies a request for pacing purposes
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeBlocked Outcome = "blocked"
+	OutcomeCaptcha Outcome = "captcha"
+	OutcomeError   Outcome = "error"
+)
+
+// Bucket counts the requests one engine sent during one interval
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	Engine    string    `json:"engine"`
+	Requests  int64     `json:"requests"`
+	Successes int64     `json:"successes"`
+	Blocks    int64     `json:"blocks"`
+	Captchas  int64     `json:"captchas"`
+	Errors    int64     `json:"errors"`
+}
+
+// bucketKey identifies a bucket by interval start and engine
+type bucketKey struct {
+	start  int64 // Unix seconds
+	engine string
+}
+
+// Recorder tallies requests into fixed-width time buckets per engine. It is
+// safe for concurrent use.
+type Recorder struct {
+	width time.Duration
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*Bucket
+}
+
+// NewRecorder creates a recorder with buckets of the given width; one minute
+// when width is not positive
+func NewRecorder(width time.Duration) *Recorder {
+	if width <= 0 {
+		width = time.Minute
+	}
+	return &Recorder{
+		width:   width,
+		buckets: make(map[bucketKey]*Bucket),
+	}
+}
+
+// Record counts a request sent to engine at t
+func (r *Recorder) Record(t time.Time, engine string, outcome Outcome) {
+	start := t.UTC().Truncate(r.width)
+	key := bucketKey{start: start.Unix(), engine: engine}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &Bucket{Start: start, Engine: engine}
+		r.buckets[key] = b
+	}
+
+	b.Requests++
+	switch outcome {
+	case OutcomeSuccess:
+		b.Successes++
+	case OutcomeBlocked:
+		b.Blocks++
+	case OutcomeCaptcha:
+		b.Captchas++
+	default:
+		b.Errors++
+	}
+}
+
+// Buckets returns every bucket ordered by time then engine. Each engine gets
+// a bucket for every interval of the run, zero-filled where it was idle, so
+// the series plot without gaps.
+func (r *Recorder) Buckets() []Bucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.buckets) == 0 {
+		return nil
+	}
+
+	var first, last time.Time
+	engines := make(map[string]bool)
+	for _, b := range r.buckets {
+		if first.IsZero() || b.Start.Before(first) {
+			first = b.Start
+		}
+		if b.Start.After(last) {
+			last = b.Start
+		}
+		engines[b.Engine] = true
+	}
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []Bucket
+	for start := first; !start.After(last); start = start.Add(r.width) {
+		for _, name := range names {
+			if b, ok := r.buckets[bucketKey{start: start.Unix(), engine: name}]; ok {
+				out = append(out, *b)
+			} else {
+				out = append(out, Bucket{Start: start, Engine: name})
+			}
+		}
+	}
+	return out
+}
+
+// csvHeader names the CSV export columns
+var csvHeader = []string{"start", "engine", "requests", "successes", "blocks", "captchas", "errors"}
+
+// WriteCSV writes the buckets as CSV with a header row
+func (r *Recorder) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, b := range r.Buckets() {
+		row := []string{
+			b.Start.Format(time.RFC3339),
+			b.Engine,
+			strconv.FormatInt(b.Requests, 10),
+			strconv.FormatInt(b.Successes, 10),
+			strconv.FormatInt(b.Blocks, 10),
+			strconv.FormatInt(b.Captchas, 10),
+			strconv.FormatInt(b.Errors, 10),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the buckets as a JSON array
+func (r *Recorder) WriteJSON(w io.Writer) error {
+	buckets := r.Buckets()
+	if buckets == nil {
+		buckets = []Bucket{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buckets)
+}
+
+// Export writes the buckets to path, as JSON when it ends in .json and as
+// CSV otherwise
+func (r *Recorder) Export(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create pacing export: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = r.WriteJSON(f)
+	} else {
+		err = r.WriteCSV(f)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write pacing export: %w", err)
+	}
+
+	return f.Close()
+}
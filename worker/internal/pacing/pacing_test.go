@@ -0,0 +1,104 @@
+package pacing
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderBuckets(t *testing.T) {
+	r := NewRecorder(time.Minute)
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	r.Record(base.Add(5*time.Second), "google", OutcomeSuccess)
+	r.Record(base.Add(50*time.Second), "google", OutcomeCaptcha)
+	r.Record(base.Add(10*time.Second), "fetch", OutcomeError)
+	r.Record(base.Add(2*time.Minute+time.Second), "google", OutcomeBlocked)
+
+	buckets := r.Buckets()
+
+	// Three minutes for two engines, idle minutes zero-filled
+	if len(buckets) != 6 {
+		t.Fatalf("buckets = %d, want 6: %+v", len(buckets), buckets)
+	}
+
+	first := buckets[1]
+	if !first.Start.Equal(base) || first.Engine != "google" || first.Requests != 2 || first.Successes != 1 || first.Captchas != 1 {
+		t.Errorf("google first minute = %+v", first)
+	}
+
+	if buckets[0].Engine != "fetch" || buckets[0].Errors != 1 {
+		t.Errorf("fetch first minute = %+v", buckets[0])
+	}
+
+	idle := buckets[3]
+	if !idle.Start.Equal(base.Add(time.Minute)) || idle.Requests != 0 {
+		t.Errorf("idle minute = %+v", idle)
+	}
+
+	if last := buckets[5]; last.Blocks != 1 || last.Engine != "google" {
+		t.Errorf("last minute = %+v", last)
+	}
+}
+
+func TestRecorderEmpty(t *testing.T) {
+	r := NewRecorder(0)
+
+	if r.Buckets() != nil {
+		t.Error("empty recorder should have no buckets")
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty JSON = %q, want []", buf.String())
+	}
+}
+
+func TestRecorderWriteCSV(t *testing.T) {
+	r := NewRecorder(time.Minute)
+	r.Record(time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC), "google", OutcomeBlocked)
+
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+
+	want := "start,engine,requests,successes,blocks,captchas,errors\n" +
+		"2024-01-01T12:00:00Z,google,1,0,1,0,0\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRecorderExport(t *testing.T) {
+	r := NewRecorder(time.Minute)
+	r.Record(time.Now(), "google", OutcomeSuccess)
+
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "pacing.json")
+	if err := r.Export(jsonPath); err != nil {
+		t.Fatalf("Export json: %v", err)
+	}
+	data, _ := os.ReadFile(jsonPath)
+	var buckets []Bucket
+	if err := json.Unmarshal(data, &buckets); err != nil || len(buckets) != 1 {
+		t.Errorf("JSON export = %s (err %v)", data, err)
+	}
+
+	csvPath := filepath.Join(dir, "pacing.csv")
+	if err := r.Export(csvPath); err != nil {
+		t.Fatalf("Export csv: %v", err)
+	}
+	data, _ = os.ReadFile(csvPath)
+	if !strings.HasPrefix(string(data), "start,engine,") {
+		t.Errorf("CSV export = %s", data)
+	}
+}
@@ -0,0 +1,122 @@
+package params
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Harvester collects the distinct query parameter names seen on each host,
+// a common input for parameter fuzzing. It is safe for concurrent use.
+type Harvester struct {
+	mu    sync.Mutex
+	hosts map[string]map[string]struct{}
+}
+
+// NewHarvester creates an empty harvester
+func NewHarvester() *Harvester {
+	return &Harvester{hosts: make(map[string]map[string]struct{})}
+}
+
+// Add records the query parameter names of rawURL; URLs without a query
+// are ignored
+func (h *Harvester) Add(rawURL string) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || parsed.RawQuery == "" {
+		return
+	}
+	query, _ := url.ParseQuery(parsed.RawQuery)
+	if len(query) == 0 {
+		return
+	}
+	host := strings.ToLower(parsed.Host)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := h.hosts[host]
+	if names == nil {
+		names = make(map[string]struct{})
+		h.hosts[host] = names
+	}
+	for name := range query {
+		if name != "" {
+			names[name] = struct{}{}
+		}
+	}
+}
+
+// Hosts returns the parameter names seen per host, each list sorted
+func (h *Harvester) Hosts() map[string][]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[string][]string, len(h.hosts))
+	for host, names := range h.hosts {
+		list := make([]string, 0, len(names))
+		for name := range names {
+			list = append(list, name)
+		}
+		sort.Strings(list)
+		out[host] = list
+	}
+	return out
+}
+
+// Len returns how many hosts had at least one parameter
+func (h *Harvester) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.hosts)
+}
+
+// WriteText writes one "host: name, name" line per host, hosts sorted
+func (h *Harvester) WriteText(w io.Writer) error {
+	hosts := h.Hosts()
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	for _, host := range names {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", host, strings.Join(hosts[host], ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes a JSON object mapping each host to its parameter names
+func (h *Harvester) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h.Hosts())
+}
+
+// Export writes the harvest to path, as JSON when it ends in .json and as
+// text otherwise
+func (h *Harvester) Export(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create parameter export: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = h.WriteJSON(f)
+	} else {
+		err = h.WriteText(f)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write parameter export: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,74 @@
+package params
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestHarvesterAdd(t *testing.T) {
+	h := NewHarvester()
+	h.Add("https://shop.example/item.php?id=1&cat=2")
+	h.Add("https://SHOP.example/list.php?page=3&id=9")
+	h.Add("https://blog.example/post?p=1")
+	h.Add("https://static.example/about")
+	h.Add("https://static.example/about?")
+	h.Add("not a url")
+
+	if h.Len() != 2 {
+		t.Errorf("Len = %d, want 2", h.Len())
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "blog.example: p\nshop.example: cat, id, page\n"
+	if buf.String() != want {
+		t.Errorf("WriteText = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestHarvesterConcurrent(t *testing.T) {
+	h := NewHarvester()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Add("https://a.example/?id=1&q=x")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if names := h.Hosts()["a.example"]; len(names) != 2 {
+		t.Errorf("names = %v, want [id q]", names)
+	}
+}
+
+func TestHarvesterExportJSON(t *testing.T) {
+	h := NewHarvester()
+	h.Add("https://a.example/?id=1")
+
+	path := filepath.Join(t.TempDir(), "params.json")
+	if err := h.Export(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts map[string][]string
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	if len(hosts["a.example"]) != 1 || hosts["a.example"][0] != "id" {
+		t.Errorf("export = %v", hosts)
+	}
+}
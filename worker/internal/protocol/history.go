@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// History sizing: enough recent traffic to show what led up to a crash
+// without holding whole result batches
+const (
+	historySize    = 200
+	historyLineMax = 4096
+)
+
+// Directions of recorded protocol lines
+const (
+	DirectionIn  = "in"  // From the controller
+	DirectionOut = "out" // To the controller
+)
+
+// HistoryEntry is one protocol line kept for diagnostics
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"dir"`
+	Line      string    `json:"line"`
+}
+
+// history is a ring of the most recent protocol lines
+type history struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+}
+
+// record adds a line, truncating very long ones
+func (h *history) record(direction, line string) {
+	if len(line) > historyLineMax {
+		line = line[:historyLineMax] + "...(truncated)"
+	}
+	entry := HistoryEntry{Time: time.Now(), Direction: direction, Line: line}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) < historySize {
+		h.entries = append(h.entries, entry)
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % historySize
+}
+
+// snapshot returns the recorded lines, oldest first
+func (h *history) snapshot() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make([]HistoryEntry, 0, len(h.entries))
+	result = append(result, h.entries[h.next:]...)
+	return append(result, h.entries[:h.next]...)
+}
+
+// redactedLine returns the form of an incoming message safe to keep: init
+// messages have their proxy list, which may carry credentials, replaced by
+// its length
+func redactedLine(msg *Message, line string) string {
+	if msg.Type != MsgTypeInit {
+		return line
+	}
+	proxies, ok := msg.Data["proxies"].([]any)
+	if !ok {
+		return line
+	}
+
+	redacted := *msg
+	redacted.Data = make(map[string]any, len(msg.Data))
+	for key, value := range msg.Data {
+		redacted.Data[key] = value
+	}
+	delete(redacted.Data, "proxies")
+	redacted.Data["proxy_count"] = len(proxies)
+
+	data, err := json.Marshal(&redacted)
+	if err != nil {
+		return `{"type":"init","data":"(unprintable)"}`
+	}
+	return string(data)
+}
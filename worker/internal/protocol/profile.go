@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Built-in profile names
+const (
+	ProfileStealth    = "stealth"
+	ProfileBalanced   = "balanced"
+	ProfileAggressive = "aggressive"
+)
+
+// Profiles are named presets trading speed for detectability. Balanced
+// matches the defaults used when no profile is given.
+var Profiles = map[string]InitConfig{
+	ProfileStealth: {
+		Workers:        3,
+		Timeout:        45 * time.Second,
+		BaseDelay:      20 * time.Second,
+		MinDelay:       10 * time.Second,
+		MaxDelay:       40 * time.Second,
+		MaxRetries:     5,
+		RetryDelay:     30 * time.Second,
+		ResultsPerPage: 10,
+		Strategy:       "least_used",
+		WarmUp:         2 * time.Minute,
+	},
+	ProfileBalanced: {
+		Workers:        10,
+		Timeout:        30 * time.Second,
+		BaseDelay:      8 * time.Second,
+		MinDelay:       3 * time.Second,
+		MaxDelay:       15 * time.Second,
+		MaxRetries:     3,
+		RetryDelay:     5 * time.Second,
+		ResultsPerPage: 100,
+		Strategy:       "weighted",
+	},
+	ProfileAggressive: {
+		Workers:        30,
+		Timeout:        15 * time.Second,
+		BaseDelay:      2 * time.Second,
+		MinDelay:       500 * time.Millisecond,
+		MaxDelay:       5 * time.Second,
+		MaxRetries:     1,
+		RetryDelay:     1 * time.Second,
+		ResultsPerPage: 100,
+		Strategy:       "round_robin",
+	},
+}
+
+// LookupProfile returns the named profile. The empty name selects balanced.
+func LookupProfile(name string) (InitConfig, error) {
+	if name == "" {
+		name = ProfileBalanced
+	}
+	profile, ok := Profiles[name]
+	if !ok {
+		return InitConfig{}, fmt.Errorf("unknown profile %q (available: %v)", name, ProfileNames())
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the available profile names in sorted order
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
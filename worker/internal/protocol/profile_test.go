@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range []string{"", ProfileStealth, ProfileBalanced, ProfileAggressive} {
+		if _, err := LookupProfile(name); err != nil {
+			t.Errorf("LookupProfile(%q) failed: %v", name, err)
+		}
+	}
+
+	if _, err := LookupProfile("turbo"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestParseInitConfigProfile(t *testing.T) {
+	msg := NewMessage(MsgTypeInit)
+	msg.SetData("profile", "stealth")
+	msg.SetData("workers", 7)
+
+	config := ParseInitConfig(msg)
+
+	if config.Profile != ProfileStealth {
+		t.Errorf("Profile = %q, want stealth", config.Profile)
+	}
+	// Explicit settings win over the profile
+	if config.Workers != 7 {
+		t.Errorf("Workers = %d, want 7", config.Workers)
+	}
+	stealth := Profiles[ProfileStealth]
+	if config.BaseDelay != stealth.BaseDelay || config.Strategy != stealth.Strategy || config.WarmUp != stealth.WarmUp {
+		t.Errorf("profile settings not applied: %+v", config)
+	}
+}
+
+func TestParseInitConfigFallbackProfile(t *testing.T) {
+	msg := NewMessage(MsgTypeInit)
+	config := ParseInitConfigWithProfile(msg, ProfileAggressive)
+	if config.Profile != ProfileAggressive || config.Workers != Profiles[ProfileAggressive].Workers {
+		t.Errorf("fallback profile not applied: %+v", config)
+	}
+
+	// A profile named in the message wins over the fallback
+	msg.SetData("profile", "stealth")
+	config = ParseInitConfigWithProfile(msg, ProfileAggressive)
+	if config.Profile != ProfileStealth {
+		t.Errorf("Profile = %q, want stealth", config.Profile)
+	}
+}
+
+func TestBalancedProfileMatchesDefaults(t *testing.T) {
+	config := ParseInitConfig(NewMessage(MsgTypeInit))
+	balanced := Profiles[ProfileBalanced]
+
+	if config.Workers != balanced.Workers || config.BaseDelay != balanced.BaseDelay ||
+		config.RetryDelay != balanced.RetryDelay || config.Strategy != balanced.Strategy {
+		t.Errorf("defaults %+v do not match balanced %+v", config, balanced)
+	}
+}
+
+func TestInitConfigToMessage(t *testing.T) {
+	config := &InitConfig{
+		Profile:   ProfileStealth,
+		Workers:   3,
+		BaseDelay: 20 * time.Second,
+		Strategy:  "least_used",
+		Proxies:   []string{"user:secret@1.2.3.4:8080"},
+	}
+
+	msg := config.ToMessage()
+
+	if msg.Type != MsgTypeConfig {
+		t.Errorf("Type = %q, want %q", msg.Type, MsgTypeConfig)
+	}
+	if msg.GetInt("base_delay") != 20000 {
+		t.Errorf("base_delay = %d, want 20000", msg.GetInt("base_delay"))
+	}
+	if msg.GetInt("proxy_count") != 1 {
+		t.Errorf("proxy_count = %d, want 1", msg.GetInt("proxy_count"))
+	}
+	if _, ok := msg.Data["proxies"]; ok {
+		t.Error("proxy entries should not be echoed")
+	}
+}
+
+func TestInitConfigApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"DORKER_WORKERS":           "4",
+		"DORKER_BASE_DELAY":        "1500",
+		"DORKER_STRATEGY":          "round_robin",
+		"DORKER_MAX_DELAY":         "not a number",
+		"DORKER_MAX_PROXY_SHARE":   "12.5",
+		"DORKER_PROBATION":         "-1",
+		"DORKER_MAX_RELEASES":      "5",
+		"DORKER_SEED":              "1234567890123",
+		"DORKER_SCOPE":             "*.target.com, api.other.io,",
+		"DORKER_RESOLVE_REDIRECTS": "true",
+		"DORKER_LANGUAGES":         "es,pt",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	config := &InitConfig{Workers: 10, MaxDelay: 15 * time.Second}
+	config.applyEnv(lookup)
+
+	if config.Workers != 4 {
+		t.Errorf("Workers = %d, want 4", config.Workers)
+	}
+	if config.BaseDelay != 1500*time.Millisecond {
+		t.Errorf("BaseDelay = %v, want 1.5s", config.BaseDelay)
+	}
+	if config.Strategy != "round_robin" {
+		t.Errorf("Strategy = %q, want round_robin", config.Strategy)
+	}
+	if config.MaxDelay != 15*time.Second {
+		t.Errorf("invalid value should be ignored, MaxDelay = %v", config.MaxDelay)
+	}
+	if config.MaxProxyShare != 12.5 {
+		t.Errorf("MaxProxyShare = %v, want 12.5", config.MaxProxyShare)
+	}
+	if config.Probation != -1 {
+		t.Errorf("Probation = %d, want -1", config.Probation)
+	}
+	if config.MaxReleases != 5 {
+		t.Errorf("MaxReleases = %d, want 5", config.MaxReleases)
+	}
+	if config.Seed != 1234567890123 {
+		t.Errorf("Seed = %d, want 1234567890123", config.Seed)
+	}
+	if len(config.Scope) != 2 || config.Scope[0] != "*.target.com" || config.Scope[1] != "api.other.io" {
+		t.Errorf("Scope = %q, want [*.target.com api.other.io]", config.Scope)
+	}
+	if !config.ResolveRedirects {
+		t.Error("ResolveRedirects should be set")
+	}
+	if len(config.Languages) != 2 || config.Languages[1] != "pt" {
+		t.Errorf("Languages = %q, want [es pt]", config.Languages)
+	}
+}
+
+func TestParseInitConfigEnvOverridesMessage(t *testing.T) {
+	t.Setenv("DORKER_WORKERS", "6")
+
+	msg := NewMessage(MsgTypeInit)
+	msg.SetData("workers", 20)
+
+	if config := ParseInitConfig(msg); config.Workers != 6 {
+		t.Errorf("Workers = %d, want 6 from environment", config.Workers)
+	}
+}
@@ -5,9 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"dorker/worker/internal/alert"
+	"dorker/worker/internal/bind"
+	"dorker/worker/internal/cost"
+	"dorker/worker/internal/engine"
+	"dorker/worker/internal/reqtrace"
 )
 
 // MessageType defines the type of IPC message
@@ -15,24 +24,95 @@ type MessageType string
 
 const (
 	// Commands from CLI to Worker
-	MsgTypeInit      MessageType = "init"
-	MsgTypeTask      MessageType = "task"
-	MsgTypeTaskBatch MessageType = "task_batch"
-	MsgTypePause     MessageType = "pause"
-	MsgTypeResume    MessageType = "resume"
-	MsgTypeShutdown  MessageType = "shutdown"
-	MsgTypeGetStats  MessageType = "get_stats"
+	MsgTypeInit         MessageType = "init"
+	MsgTypeTask         MessageType = "task"
+	MsgTypeTaskBatch    MessageType = "task_batch"
+	MsgTypePause        MessageType = "pause"
+	MsgTypeResume       MessageType = "resume"
+	MsgTypeShutdown     MessageType = "shutdown"
+	MsgTypeGetStats     MessageType = "get_stats"
+	MsgTypeCheckProxies MessageType = "check_proxies"
+	MsgTypeGetLogs      MessageType = "get_logs"
+	MsgTypeGetConfig    MessageType = "get_config"
+
+	// Job snapshots, see OnExportSnapshot
+	MsgTypeExportSnapshot MessageType = "export_snapshot"
+	MsgTypeImportSnapshot MessageType = "import_snapshot"
 
 	// Responses from Worker to CLI
-	MsgTypeStatus    MessageType = "status"
-	MsgTypeResult    MessageType = "result"
-	MsgTypeStats     MessageType = "stats"
-	MsgTypeError     MessageType = "error"
-	MsgTypeLog       MessageType = "log"
-	MsgTypeProgress  MessageType = "progress"
-	MsgTypeProxyInfo MessageType = "proxy_info"
+	MsgTypeStatus     MessageType = "status"
+	MsgTypeResult     MessageType = "result"
+	MsgTypeStats      MessageType = "stats"
+	MsgTypeError      MessageType = "error"
+	MsgTypeLog        MessageType = "log"
+	MsgTypeProgress   MessageType = "progress"
+	MsgTypeProxyInfo  MessageType = "proxy_info"
+	MsgTypeProxyCheck MessageType = "proxy_check"
+	MsgTypeDone       MessageType = "done"
+	MsgTypeFatal      MessageType = "fatal"
+	MsgTypeLogs       MessageType = "logs"
+	MsgTypeConfig     MessageType = "config"
+	MsgTypeAlert      MessageType = "alert"
+)
+
+// ShutdownReason describes why the worker is terminating
+type ShutdownReason string
+
+const (
+	ReasonShutdown      ShutdownReason = "shutdown"       // Controller sent a shutdown message
+	ReasonControllerEOF ShutdownReason = "controller_eof" // Controller closed stdin
+	ReasonInitFailed    ShutdownReason = "init_failed"    // Init could not produce a usable worker
+	ReasonPoolExhausted ShutdownReason = "pool_exhausted" // No alive or recoverable proxies remain
+	ReasonPanic         ShutdownReason = "panic"          // Unrecovered panic
+	ReasonSignal        ShutdownReason = "signal"         // Terminated by an OS signal
+	ReasonDeadline      ShutdownReason = "run_deadline"   // max_run_duration elapsed
+	ReasonBudget        ShutdownReason = "request_budget" // max_requests used up
+)
+
+// Process exit codes, one per shutdown reason
+const (
+	ExitOK            = 0
+	ExitInitFailed    = 2
+	ExitPoolExhausted = 3
+	ExitPanic         = 4
+	ExitControllerEOF = 5
+	ExitSignal        = 6
+	ExitDeadline      = 7
+	ExitBudget        = 8
 )
 
+// ExitCode returns the process exit code for the reason
+func (r ShutdownReason) ExitCode() int {
+	switch r {
+	case ReasonShutdown:
+		return ExitOK
+	case ReasonControllerEOF:
+		return ExitControllerEOF
+	case ReasonInitFailed:
+		return ExitInitFailed
+	case ReasonPoolExhausted:
+		return ExitPoolExhausted
+	case ReasonPanic:
+		return ExitPanic
+	case ReasonSignal:
+		return ExitSignal
+	case ReasonDeadline:
+		return ExitDeadline
+	case ReasonBudget:
+		return ExitBudget
+	}
+	return ExitPanic
+}
+
+// IsFatal reports whether the reason is an error rather than an orderly stop
+func (r ShutdownReason) IsFatal() bool {
+	switch r {
+	case ReasonInitFailed, ReasonPoolExhausted, ReasonPanic:
+		return true
+	}
+	return false
+}
+
 // Message is the base IPC message structure
 type Message struct {
 	Type      MessageType    `json:"type"`
@@ -113,6 +193,46 @@ func (m *Message) GetBool(key string) bool {
 	return false
 }
 
+// GetIntMap gets a map of int values from a JSON object in data; entries
+// that are not numbers are skipped
+func (m *Message) GetIntMap(key string) map[string]int {
+	if m.Data == nil {
+		return nil
+	}
+	if v, ok := m.Data[key].(map[string]any); ok {
+		result := make(map[string]int, len(v))
+		for name, item := range v {
+			switch n := item.(type) {
+			case float64:
+				result[name] = int(n)
+			case int:
+				result[name] = n
+			case int64:
+				result[name] = int(n)
+			}
+		}
+		return result
+	}
+	if v, ok := m.Data[key].(map[string]int); ok {
+		return v
+	}
+	return nil
+}
+
+// GetObject decodes a JSON object in data into v, leaving v untouched when
+// the key is missing
+func (m *Message) GetObject(key string, v any) error {
+	raw, ok := m.Data[key]
+	if !ok || raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
 // GetStringSlice gets a string slice from data
 func (m *Message) GetStringSlice(key string) []string {
 	if m.Data == nil {
@@ -132,73 +252,604 @@ func (m *Message) GetStringSlice(key string) []string {
 
 // InitConfig represents initialization configuration
 type InitConfig struct {
-	Workers        int           `json:"workers"`
-	Timeout        time.Duration `json:"timeout"`
-	BaseDelay      time.Duration `json:"base_delay"`
-	MinDelay       time.Duration `json:"min_delay"`
-	MaxDelay       time.Duration `json:"max_delay"`
-	MaxRetries     int           `json:"max_retries"`
-	ResultsPerPage int           `json:"results_per_page"`
-	Proxies        []string      `json:"proxies"`
-	ProxyFile      string        `json:"proxy_file"`
+	Profile           string             `json:"profile,omitempty"`
+	Workers           int                `json:"workers"`
+	Timeout           time.Duration      `json:"timeout"`
+	BaseDelay         time.Duration      `json:"base_delay"`
+	MinDelay          time.Duration      `json:"min_delay"`
+	MaxDelay          time.Duration      `json:"max_delay"`
+	MaxRetries        int                `json:"max_retries"`
+	RetryDelay        time.Duration      `json:"retry_delay"`
+	ResultsPerPage    int                `json:"results_per_page"`
+	Strategy          string             `json:"strategy"`
+	WarmUp            time.Duration      `json:"warm_up"`
+	TaskTimeout       time.Duration      `json:"task_timeout"`        // Per-task limit across retries, 0 for none
+	MaxRunDuration    time.Duration      `json:"max_run_duration"`    // Whole-run limit, 0 for none
+	MaxRequests       int                `json:"max_requests"`        // Outgoing request budget, 0 for none
+	EngineMaxRequests map[string]int     `json:"engine_max_requests"` // Per-engine budgets, e.g. {"google": 500}
+	Costs             cost.Model         `json:"costs"`               // Prices for spend estimates
+	Dedup             string             `json:"dedup"`               // off, exact or bloom
+	DedupCapacity     int                `json:"dedup_capacity"`      // Expected distinct URLs (bloom)
+	DedupFPRate       float64            `json:"dedup_fp_rate"`       // Target false-positive rate (bloom)
+	DedupSpill        string             `json:"dedup_spill"`         // Spill file for exact verification (bloom)
+	DedupGranularity  string             `json:"dedup_granularity"`   // url, params, path or domain
+	ParseWorkers      int                `json:"parse_workers"`       // Results page parsers, 0 for one per CPU
+	EnrichWorkers     int                `json:"enrich_workers"`      // Requests for parsed pages, 0 for as many as workers
+	PoolShards        int                `json:"pool_shards"`         // Proxy pool shards, 0 to shard by pool size
+	MaxProxyShare     float64            `json:"max_proxy_share"`     // Most requests one proxy may serve, in percent; 0 for no limit
+	Probation         int                `json:"probation"`           // Successes a proxy added at runtime needs for full rotation; 0 for the default, -1 for none
+	ProbationShare    float64            `json:"probation_share"`     // Percent of picks proxies on probation get; 0 for the default
+	Weights           Weights            `json:"weights"`             // Weighted strategy coefficients
+	Preflight         bool               `json:"preflight"`           // Try every proxy against the engine before use
+	PreflightURL      string             `json:"preflight_url"`       // Pre-flight target, empty for Google's generate_204
+	Recheck           bool               `json:"recheck"`             // Release quarantined proxies only once they pass the pre-flight request again
+	MaxReleases       int                `json:"max_releases"`        // Most quarantined proxies released per health check; 0 for the default, -1 for no limit
+	CanaryDork        string             `json:"canary_dork"`         // Dork that always has results, empty for no canary
+	CanaryInterval    time.Duration      `json:"canary_interval"`     // Time between canary searches, 0 for no canary
+	CanaryMinResults  int                `json:"canary_min_results"`  // Fewest results a healthy canary page yields
+	GeoMatch          bool               `json:"geo_match"`           // Search with each proxy's country domain, gl, hl and Accept-Language
+	ClassifierURL     string             `json:"classifier_url"`      // Endpoint classifying ambiguous pages, empty for none
+	ClassifierTimeout time.Duration      `json:"classifier_timeout"`  // Per-page classifier limit
+	MinConfidence     float64            `json:"min_confidence"`      // Lowest extraction confidence kept, 0 to 1; 0 keeps every URL
+	AssetFilter       engine.AssetConfig `json:"asset_filter"`        // Static asset filter allow and deny lists
+	SERPFeatures      []string           `json:"serp_features"`       // SERP features whose links are kept, e.g. ["video", "maps"]
+	MobileSERP        bool               `json:"mobile_serp"`         // Search as a phone and parse Google's mobile layout
+	OrganicOnly       bool               `json:"organic_only"`        // Drop ads, featured snippets and "People also ask" links
+	Scope             []string           `json:"scope"`               // Host patterns results must match, empty for all
+	TitleInclude      []string           `json:"title_include"`       // Terms one of which result titles must contain
+	TitleExclude      []string           `json:"title_exclude"`       // Terms result titles must not contain
+	DetectLanguage    bool               `json:"detect_language"`     // Tag results with their title language
+	Languages         []string           `json:"languages"`           // Language codes results must be in, empty for all
+	ResolveRedirects  bool               `json:"resolve_redirects"`   // Follow redirector links to their final URL
+	ResolveHosts      []string           `json:"resolve_hosts"`       // Redirector hosts, empty for the built-in list
+	ResolveMaxHops    int                `json:"resolve_max_hops"`    // Most redirects followed from one link
+	ResolveTimeout    time.Duration      `json:"resolve_timeout"`     // Per-link resolution limit
+	CrawlListings     bool               `json:"crawl_listings"`      // List the files of open directory listings among results
+	CrawlMaxDepth     int                `json:"crawl_max_depth"`     // Subdirectories descended below a listing; negative for none
+	CrawlMaxFiles     int                `json:"crawl_max_files"`     // Most files reported per listing
+	CrawlTimeout      time.Duration      `json:"crawl_timeout"`       // Per-listing crawl limit
+	VerifyPatterns    []string           `json:"verify_patterns"`     // Keywords, or "re:" regexes, looked for on each result page; empty for no verification
+	VerifyRequire     bool               `json:"verify_require"`      // Drop results whose page matches no pattern
+	VerifyTimeout     time.Duration      `json:"verify_timeout"`      // Per-page verification limit
+	FingerprintHosts  bool               `json:"fingerprint_hosts"`   // Take each result host's favicon hash and title
+	ResolveNetworks   bool               `json:"resolve_networks"`    // Resolve each result host and look up its AS and netblock
+	Alerts            alert.Config       `json:"alerts"`              // Rules raising alerts on matching results, and where they notify
+	CacheTTL          time.Duration      `json:"cache_ttl"`           // How long parsed pages are reused, 0 for off
+	CacheDir          string             `json:"cache_dir"`           // Directory keeping cached pages on disk
+	IdempotencyWindow time.Duration      `json:"idempotency_window"`  // How long a resent task gets the first copy's result, 0 for off
+	Seed              int64              `json:"seed"`                // Derives each proxy's browser session; 0 for a random seed
+	TraceRate         float64            `json:"trace_rate"`          // Percent of requests traced phase by phase; 0 for none
+	Bind              bind.Rules         `json:"bind"`                // Source address or interface per proxy group; a string binds all
+	Proxies           []string           `json:"proxies"`
+	ProxyFile         string             `json:"proxy_file"`
+}
+
+// Weights overrides the weighted rotation strategy's coefficients; unset
+// fields keep the pool defaults
+type Weights struct {
+	SuccessBonus *float64 `json:"success_bonus,omitempty"` // Weight added at a 100% success rate
+	SlowLatency  *int64   `json:"slow_latency,omitempty"`  // Milliseconds of average latency above which a proxy is slow
+	SlowFactor   *float64 `json:"slow_factor,omitempty"`   // Multiplier for a slow proxy's weight
+}
+
+// Empty reports whether no coefficient is overridden
+func (w Weights) Empty() bool {
+	return w.SuccessBonus == nil && w.SlowLatency == nil && w.SlowFactor == nil
 }
 
 // ParseInitConfig parses init config from message data
 func ParseInitConfig(m *Message) *InitConfig {
+	return ParseInitConfigWithProfile(m, "")
+}
+
+// ParseInitConfigWithProfile parses init config from message data. Settings
+// in the message win over the named profile, which wins over the defaults.
+// fallbackProfile is used when the message does not name a profile.
+func ParseInitConfigWithProfile(m *Message, fallbackProfile string) *InitConfig {
 	config := &InitConfig{
-		Workers:        m.GetInt("workers"),
-		Timeout:        time.Duration(m.GetInt("timeout")) * time.Millisecond,
-		BaseDelay:      time.Duration(m.GetInt("base_delay")) * time.Millisecond,
-		MinDelay:       time.Duration(m.GetInt("min_delay")) * time.Millisecond,
-		MaxDelay:       time.Duration(m.GetInt("max_delay")) * time.Millisecond,
-		MaxRetries:     m.GetInt("max_retries"),
-		ResultsPerPage: m.GetInt("results_per_page"),
-		Proxies:        m.GetStringSlice("proxies"),
-		ProxyFile:      m.GetString("proxy_file"),
+		Profile:           m.GetString("profile"),
+		Workers:           m.GetInt("workers"),
+		Timeout:           time.Duration(m.GetInt("timeout")) * time.Millisecond,
+		BaseDelay:         time.Duration(m.GetInt("base_delay")) * time.Millisecond,
+		MinDelay:          time.Duration(m.GetInt("min_delay")) * time.Millisecond,
+		MaxDelay:          time.Duration(m.GetInt("max_delay")) * time.Millisecond,
+		MaxRetries:        m.GetInt("max_retries"),
+		RetryDelay:        time.Duration(m.GetInt("retry_delay")) * time.Millisecond,
+		ResultsPerPage:    m.GetInt("results_per_page"),
+		Strategy:          m.GetString("strategy"),
+		WarmUp:            time.Duration(m.GetInt("warm_up")) * time.Millisecond,
+		TaskTimeout:       time.Duration(m.GetInt("task_timeout")) * time.Millisecond,
+		MaxRunDuration:    time.Duration(m.GetInt("max_run_duration")) * time.Millisecond,
+		MaxRequests:       m.GetInt("max_requests"),
+		EngineMaxRequests: m.GetIntMap("engine_max_requests"),
+		Dedup:             m.GetString("dedup"),
+		DedupCapacity:     m.GetInt("dedup_capacity"),
+		DedupFPRate:       m.GetFloat("dedup_fp_rate"),
+		DedupSpill:        m.GetString("dedup_spill"),
+		DedupGranularity:  m.GetString("dedup_granularity"),
+		ParseWorkers:      m.GetInt("parse_workers"),
+		EnrichWorkers:     m.GetInt("enrich_workers"),
+		PoolShards:        m.GetInt("pool_shards"),
+		MaxProxyShare:     m.GetFloat("max_proxy_share"),
+		Probation:         m.GetInt("probation"),
+		ProbationShare:    m.GetFloat("probation_share"),
+		Preflight:         m.GetBool("preflight"),
+		PreflightURL:      m.GetString("preflight_url"),
+		Recheck:           m.GetBool("recheck"),
+		MaxReleases:       m.GetInt("max_releases"),
+		CanaryDork:        m.GetString("canary_dork"),
+		CanaryInterval:    time.Duration(m.GetInt("canary_interval")) * time.Millisecond,
+		CanaryMinResults:  m.GetInt("canary_min_results"),
+		GeoMatch:          m.GetBool("geo_match"),
+		ClassifierURL:     m.GetString("classifier_url"),
+		ClassifierTimeout: time.Duration(m.GetInt("classifier_timeout")) * time.Millisecond,
+		MinConfidence:     m.GetFloat("min_confidence"),
+		SERPFeatures:      m.GetStringSlice("serp_features"),
+		MobileSERP:        m.GetBool("mobile_serp"),
+		OrganicOnly:       m.GetBool("organic_only"),
+		Scope:             m.GetStringSlice("scope"),
+		TitleInclude:      m.GetStringSlice("title_include"),
+		TitleExclude:      m.GetStringSlice("title_exclude"),
+		DetectLanguage:    m.GetBool("detect_language"),
+		Languages:         m.GetStringSlice("languages"),
+		ResolveRedirects:  m.GetBool("resolve_redirects"),
+		ResolveHosts:      m.GetStringSlice("resolve_hosts"),
+		ResolveMaxHops:    m.GetInt("resolve_max_hops"),
+		ResolveTimeout:    time.Duration(m.GetInt("resolve_timeout")) * time.Millisecond,
+		CrawlListings:     m.GetBool("crawl_listings"),
+		CrawlMaxDepth:     m.GetInt("crawl_max_depth"),
+		CrawlMaxFiles:     m.GetInt("crawl_max_files"),
+		CrawlTimeout:      time.Duration(m.GetInt("crawl_timeout")) * time.Millisecond,
+		VerifyPatterns:    m.GetStringSlice("verify_patterns"),
+		VerifyRequire:     m.GetBool("verify_require"),
+		VerifyTimeout:     time.Duration(m.GetInt("verify_timeout")) * time.Millisecond,
+		FingerprintHosts:  m.GetBool("fingerprint_hosts"),
+		ResolveNetworks:   m.GetBool("resolve_networks"),
+		CacheTTL:          time.Duration(m.GetInt("cache_ttl")) * time.Millisecond,
+		CacheDir:          m.GetString("cache_dir"),
+		IdempotencyWindow: time.Duration(m.GetInt("idempotency_window")) * time.Millisecond,
+		Seed:              int64(m.GetInt("seed")),
+		TraceRate:         m.GetFloat("trace_rate"),
+		Proxies:           m.GetStringSlice("proxies"),
+		ProxyFile:         m.GetString("proxy_file"),
 	}
 
-	// Apply defaults
-	if config.Workers == 0 {
-		config.Workers = 10
+	// A malformed cost model is ignored like any other unparseable value
+	if err := m.GetObject("costs", &config.Costs); err != nil {
+		config.Costs = cost.Model{}
 	}
-	if config.Timeout == 0 {
-		config.Timeout = 30 * time.Second
+	if err := m.GetObject("weights", &config.Weights); err != nil {
+		config.Weights = Weights{}
 	}
-	if config.BaseDelay == 0 {
-		config.BaseDelay = 8 * time.Second
+	if err := m.GetObject("asset_filter", &config.AssetFilter); err != nil {
+		config.AssetFilter = engine.AssetConfig{}
 	}
-	if config.MinDelay == 0 {
-		config.MinDelay = 3 * time.Second
+	if err := m.GetObject("alerts", &config.Alerts); err != nil {
+		config.Alerts = alert.Config{}
 	}
-	if config.MaxDelay == 0 {
-		config.MaxDelay = 15 * time.Second
+	if source := m.GetString("bind"); source != "" {
+		config.Bind = bind.Rules{"*": source}
+	} else if err := m.GetObject("bind", &config.Bind); err != nil {
+		config.Bind = nil
 	}
-	if config.MaxRetries == 0 {
-		config.MaxRetries = 3
+
+	// Environment variables override the message
+	config.applyEnv(os.LookupEnv)
+
+	if config.Profile == "" {
+		config.Profile = fallbackProfile
+	}
+	if config.Profile == "" {
+		config.Profile = ProfileBalanced
 	}
-	if config.ResultsPerPage == 0 {
-		config.ResultsPerPage = 100
+	if profile, err := LookupProfile(config.Profile); err == nil {
+		config.fillFrom(profile)
 	}
 
+	// Apply defaults
+	config.fillFrom(Profiles[ProfileBalanced])
+
 	return config
 }
 
+// envPrefix prefixes environment overrides, e.g. DORKER_WORKERS
+const envPrefix = "DORKER_"
+
+// applyEnv overrides settings from DORKER_* environment variables using the
+// init key names in upper case (durations in milliseconds). Values that do
+// not parse are ignored.
+func (c *InitConfig) applyEnv(lookup func(string) (string, bool)) {
+	intVar := func(key string, dst *int) {
+		if v, ok := lookup(envPrefix + key); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				*dst = n
+			}
+		}
+	}
+	int64Var := func(key string, dst *int64) {
+		if v, ok := lookup(envPrefix + key); ok {
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				*dst = n
+			}
+		}
+	}
+	durationVar := func(key string, dst *time.Duration) {
+		if v, ok := lookup(envPrefix + key); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				*dst = time.Duration(n) * time.Millisecond
+			}
+		}
+	}
+	floatVar := func(key string, dst *float64) {
+		if v, ok := lookup(envPrefix + key); ok {
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				*dst = n
+			}
+		}
+	}
+	boolVar := func(key string, dst *bool) {
+		if v, ok := lookup(envPrefix + key); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				*dst = b
+			}
+		}
+	}
+	stringVar := func(key string, dst *string) {
+		if v, ok := lookup(envPrefix + key); ok && v != "" {
+			*dst = v
+		}
+	}
+	listVar := func(key string, dst *[]string) {
+		if v, ok := lookup(envPrefix + key); ok && v != "" {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+
+	stringVar("PROFILE", &c.Profile)
+	intVar("WORKERS", &c.Workers)
+	durationVar("TIMEOUT", &c.Timeout)
+	durationVar("BASE_DELAY", &c.BaseDelay)
+	durationVar("MIN_DELAY", &c.MinDelay)
+	durationVar("MAX_DELAY", &c.MaxDelay)
+	intVar("MAX_RETRIES", &c.MaxRetries)
+	durationVar("RETRY_DELAY", &c.RetryDelay)
+	intVar("RESULTS_PER_PAGE", &c.ResultsPerPage)
+	stringVar("STRATEGY", &c.Strategy)
+	durationVar("WARM_UP", &c.WarmUp)
+	durationVar("TASK_TIMEOUT", &c.TaskTimeout)
+	durationVar("MAX_RUN_DURATION", &c.MaxRunDuration)
+	intVar("MAX_REQUESTS", &c.MaxRequests)
+	stringVar("DEDUP", &c.Dedup)
+	intVar("DEDUP_CAPACITY", &c.DedupCapacity)
+	stringVar("DEDUP_SPILL", &c.DedupSpill)
+	stringVar("DEDUP_GRANULARITY", &c.DedupGranularity)
+	intVar("PARSE_WORKERS", &c.ParseWorkers)
+	intVar("ENRICH_WORKERS", &c.EnrichWorkers)
+	intVar("POOL_SHARDS", &c.PoolShards)
+	floatVar("MAX_PROXY_SHARE", &c.MaxProxyShare)
+	intVar("PROBATION", &c.Probation)
+	floatVar("PROBATION_SHARE", &c.ProbationShare)
+	boolVar("PREFLIGHT", &c.Preflight)
+	stringVar("PREFLIGHT_URL", &c.PreflightURL)
+	boolVar("RECHECK", &c.Recheck)
+	intVar("MAX_RELEASES", &c.MaxReleases)
+	stringVar("CANARY_DORK", &c.CanaryDork)
+	durationVar("CANARY_INTERVAL", &c.CanaryInterval)
+	intVar("CANARY_MIN_RESULTS", &c.CanaryMinResults)
+	boolVar("GEO_MATCH", &c.GeoMatch)
+	stringVar("CLASSIFIER_URL", &c.ClassifierURL)
+	durationVar("CLASSIFIER_TIMEOUT", &c.ClassifierTimeout)
+	floatVar("MIN_CONFIDENCE", &c.MinConfidence)
+	listVar("SERP_FEATURES", &c.SERPFeatures)
+	boolVar("MOBILE_SERP", &c.MobileSERP)
+	boolVar("ORGANIC_ONLY", &c.OrganicOnly)
+	listVar("SCOPE", &c.Scope)
+	listVar("TITLE_INCLUDE", &c.TitleInclude)
+	listVar("TITLE_EXCLUDE", &c.TitleExclude)
+	boolVar("DETECT_LANGUAGE", &c.DetectLanguage)
+	listVar("LANGUAGES", &c.Languages)
+	boolVar("RESOLVE_REDIRECTS", &c.ResolveRedirects)
+	listVar("RESOLVE_HOSTS", &c.ResolveHosts)
+	intVar("RESOLVE_MAX_HOPS", &c.ResolveMaxHops)
+	durationVar("RESOLVE_TIMEOUT", &c.ResolveTimeout)
+	boolVar("CRAWL_LISTINGS", &c.CrawlListings)
+	intVar("CRAWL_MAX_DEPTH", &c.CrawlMaxDepth)
+	intVar("CRAWL_MAX_FILES", &c.CrawlMaxFiles)
+	durationVar("CRAWL_TIMEOUT", &c.CrawlTimeout)
+	listVar("VERIFY_PATTERNS", &c.VerifyPatterns)
+	boolVar("VERIFY_REQUIRE", &c.VerifyRequire)
+	durationVar("VERIFY_TIMEOUT", &c.VerifyTimeout)
+	boolVar("FINGERPRINT_HOSTS", &c.FingerprintHosts)
+	boolVar("RESOLVE_NETWORKS", &c.ResolveNetworks)
+	durationVar("CACHE_TTL", &c.CacheTTL)
+	stringVar("CACHE_DIR", &c.CacheDir)
+	durationVar("IDEMPOTENCY_WINDOW", &c.IdempotencyWindow)
+	int64Var("SEED", &c.Seed)
+	floatVar("TRACE_RATE", &c.TraceRate)
+	if v, ok := lookup(envPrefix + "BIND"); ok && v != "" {
+		c.Bind = bind.Rules{"*": v}
+	}
+}
+
+// fillFrom copies tuning settings from other into fields that are unset
+func (c *InitConfig) fillFrom(other InitConfig) {
+	if c.Workers == 0 {
+		c.Workers = other.Workers
+	}
+	if c.Timeout == 0 {
+		c.Timeout = other.Timeout
+	}
+	if c.BaseDelay == 0 {
+		c.BaseDelay = other.BaseDelay
+	}
+	if c.MinDelay == 0 {
+		c.MinDelay = other.MinDelay
+	}
+	if c.MaxDelay == 0 {
+		c.MaxDelay = other.MaxDelay
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = other.MaxRetries
+	}
+	if c.RetryDelay == 0 {
+		c.RetryDelay = other.RetryDelay
+	}
+	if c.ResultsPerPage == 0 {
+		c.ResultsPerPage = other.ResultsPerPage
+	}
+	if c.Strategy == "" {
+		c.Strategy = other.Strategy
+	}
+	if c.WarmUp == 0 {
+		c.WarmUp = other.WarmUp
+	}
+}
+
+// ToMessage converts the effective configuration to a config message using
+// the init keys. Proxy entries are summarized by count since they may carry
+// credentials.
+func (c *InitConfig) ToMessage() *Message {
+	msg := NewMessage(MsgTypeConfig)
+	msg.SetData("profile", c.Profile)
+	msg.SetData("workers", c.Workers)
+	msg.SetData("timeout", c.Timeout.Milliseconds())
+	msg.SetData("base_delay", c.BaseDelay.Milliseconds())
+	msg.SetData("min_delay", c.MinDelay.Milliseconds())
+	msg.SetData("max_delay", c.MaxDelay.Milliseconds())
+	msg.SetData("max_retries", c.MaxRetries)
+	msg.SetData("retry_delay", c.RetryDelay.Milliseconds())
+	msg.SetData("results_per_page", c.ResultsPerPage)
+	msg.SetData("strategy", c.Strategy)
+	msg.SetData("warm_up", c.WarmUp.Milliseconds())
+	msg.SetData("task_timeout", c.TaskTimeout.Milliseconds())
+	msg.SetData("max_run_duration", c.MaxRunDuration.Milliseconds())
+	msg.SetData("max_requests", c.MaxRequests)
+	if len(c.EngineMaxRequests) > 0 {
+		msg.SetData("engine_max_requests", c.EngineMaxRequests)
+	}
+	if len(c.Bind) > 0 {
+		msg.SetData("bind", c.Bind)
+	}
+	if !c.Costs.Empty() {
+		msg.SetData("costs", c.Costs)
+	}
+	if c.Dedup != "" {
+		msg.SetData("dedup", c.Dedup)
+		msg.SetData("dedup_capacity", c.DedupCapacity)
+		msg.SetData("dedup_fp_rate", c.DedupFPRate)
+		if c.DedupSpill != "" {
+			msg.SetData("dedup_spill", c.DedupSpill)
+		}
+		if c.DedupGranularity != "" {
+			msg.SetData("dedup_granularity", c.DedupGranularity)
+		}
+	}
+	msg.SetData("parse_workers", c.ParseWorkers)
+	msg.SetData("enrich_workers", c.EnrichWorkers)
+	msg.SetData("pool_shards", c.PoolShards)
+	msg.SetData("max_proxy_share", c.MaxProxyShare)
+	msg.SetData("probation", c.Probation)
+	msg.SetData("probation_share", c.ProbationShare)
+	if !c.Weights.Empty() {
+		msg.SetData("weights", c.Weights)
+	}
+	msg.SetData("preflight", c.Preflight)
+	if c.PreflightURL != "" {
+		msg.SetData("preflight_url", c.PreflightURL)
+	}
+	msg.SetData("recheck", c.Recheck)
+	msg.SetData("max_releases", c.MaxReleases)
+	if c.CanaryDork != "" {
+		msg.SetData("canary_dork", c.CanaryDork)
+		msg.SetData("canary_interval", c.CanaryInterval.Milliseconds())
+		msg.SetData("canary_min_results", c.CanaryMinResults)
+	}
+	msg.SetData("geo_match", c.GeoMatch)
+	if c.ClassifierURL != "" {
+		msg.SetData("classifier_url", c.ClassifierURL)
+		msg.SetData("classifier_timeout", c.ClassifierTimeout.Milliseconds())
+	}
+	if c.MinConfidence > 0 {
+		msg.SetData("min_confidence", c.MinConfidence)
+	}
+	if c.AssetFilter.Disabled || len(c.AssetFilter.Deny) > 0 || len(c.AssetFilter.Allow) > 0 {
+		msg.SetData("asset_filter", c.AssetFilter)
+	}
+	if len(c.SERPFeatures) > 0 {
+		msg.SetData("serp_features", c.SERPFeatures)
+	}
+	if c.MobileSERP {
+		msg.SetData("mobile_serp", true)
+	}
+	if c.OrganicOnly {
+		msg.SetData("organic_only", true)
+	}
+	if len(c.Scope) > 0 {
+		msg.SetData("scope", c.Scope)
+	}
+	if len(c.TitleInclude) > 0 {
+		msg.SetData("title_include", c.TitleInclude)
+	}
+	if len(c.TitleExclude) > 0 {
+		msg.SetData("title_exclude", c.TitleExclude)
+	}
+	if c.DetectLanguage {
+		msg.SetData("detect_language", true)
+	}
+	if len(c.Languages) > 0 {
+		msg.SetData("languages", c.Languages)
+	}
+	if c.ResolveRedirects {
+		msg.SetData("resolve_redirects", true)
+		if len(c.ResolveHosts) > 0 {
+			msg.SetData("resolve_hosts", c.ResolveHosts)
+		}
+		msg.SetData("resolve_max_hops", c.ResolveMaxHops)
+		msg.SetData("resolve_timeout", c.ResolveTimeout.Milliseconds())
+	}
+	if c.CrawlListings {
+		msg.SetData("crawl_listings", true)
+		msg.SetData("crawl_max_depth", c.CrawlMaxDepth)
+		msg.SetData("crawl_max_files", c.CrawlMaxFiles)
+		msg.SetData("crawl_timeout", c.CrawlTimeout.Milliseconds())
+	}
+	if len(c.VerifyPatterns) > 0 {
+		msg.SetData("verify_patterns", c.VerifyPatterns)
+		msg.SetData("verify_require", c.VerifyRequire)
+		msg.SetData("verify_timeout", c.VerifyTimeout.Milliseconds())
+	}
+	if c.FingerprintHosts {
+		msg.SetData("fingerprint_hosts", true)
+	}
+	if c.ResolveNetworks {
+		msg.SetData("resolve_networks", true)
+	}
+	if len(c.Alerts.Rules) > 0 {
+		msg.SetData("alerts", c.Alerts)
+	}
+	if c.CacheTTL > 0 {
+		msg.SetData("cache_ttl", c.CacheTTL.Milliseconds())
+		if c.CacheDir != "" {
+			msg.SetData("cache_dir", c.CacheDir)
+		}
+	}
+	if c.IdempotencyWindow > 0 {
+		msg.SetData("idempotency_window", c.IdempotencyWindow.Milliseconds())
+	}
+	if c.Seed != 0 {
+		msg.SetData("seed", c.Seed)
+	}
+	if c.TraceRate > 0 {
+		msg.SetData("trace_rate", c.TraceRate)
+	}
+	msg.SetData("proxy_count", len(c.Proxies))
+	if c.ProxyFile != "" {
+		msg.SetData("proxy_file", c.ProxyFile)
+	}
+	return msg
+}
+
+// LoadInitConfigFile reads a JSON object using the init message keys
+// (durations in milliseconds) and parses it like an init message.
+// fallbackProfile is used when the file does not name a profile.
+func LoadInitConfigFile(path string, fallbackProfile string) (*InitConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	msg := NewMessage(MsgTypeInit)
+	if err := json.Unmarshal(data, &msg.Data); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return ParseInitConfigWithProfile(msg, fallbackProfile), nil
+}
+
 // TaskData represents a single task
 type TaskData struct {
 	ID   string `json:"id"`
+	Type string `json:"type,omitempty"` // "search" (default) or "fetch"
 	Dork string `json:"dork"`
+	URL  string `json:"url,omitempty"` // Target URL for fetch tasks
 	Page int    `json:"page"`
+
+	// Deadline is an absolute unix time in milliseconds and Timeout a limit
+	// in milliseconds from receipt; the earlier applies. Zero means unset.
+	Deadline int64 `json:"deadline,omitempty"`
+	Timeout  int64 `json:"timeout,omitempty"`
+
+	// MinConfidence drops extracted URLs scoring below it, overriding the
+	// init setting; zero keeps the init setting
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+
+	// Scope limits reported URLs to these host patterns, overriding the
+	// init setting
+	Scope []string `json:"scope,omitempty"`
+
+	// DedupGranularity is url, params, path or domain, overriding the init
+	// setting
+	DedupGranularity string `json:"dedup_granularity,omitempty"`
+
+	// TitleInclude and TitleExclude filter results by title, replacing the
+	// init filters when either is set
+	TitleInclude []string `json:"title_include,omitempty"`
+	TitleExclude []string `json:"title_exclude,omitempty"`
+
+	// Languages keeps only results detected in these languages, overriding
+	// the init setting
+	Languages []string `json:"languages,omitempty"`
+
+	// IdempotencyKey identifies resent copies of the task; without one the
+	// worker derives it from the task's content
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // ParseTaskData parses task data from message
 func ParseTaskData(m *Message) *TaskData {
 	return &TaskData{
-		ID:   m.GetString("task_id"),
-		Dork: m.GetString("dork"),
-		Page: m.GetInt("page"),
+		ID:       m.GetString("task_id"),
+		Type:     m.GetString("type"),
+		Dork:     m.GetString("dork"),
+		URL:      m.GetString("url"),
+		Page:     m.GetInt("page"),
+		Deadline: int64(m.GetInt("deadline")),
+		Timeout:  int64(m.GetInt("timeout")),
+
+		MinConfidence: m.GetFloat("min_confidence"),
+		Scope:         m.GetStringSlice("scope"),
+
+		DedupGranularity: m.GetString("dedup_granularity"),
+
+		TitleInclude: m.GetStringSlice("title_include"),
+		TitleExclude: m.GetStringSlice("title_exclude"),
+		Languages:    m.GetStringSlice("languages"),
+
+		IdempotencyKey: m.GetString("idempotency_key"),
 	}
 }
 
+// DeadlineFrom returns when the task must finish given it arrived at now,
+// or the zero time when it has no deadline
+func (t *TaskData) DeadlineFrom(now time.Time) time.Time {
+	var deadline time.Time
+	if t.Deadline > 0 {
+		deadline = time.UnixMilli(t.Deadline)
+	}
+	if t.Timeout > 0 {
+		timeout := now.Add(time.Duration(t.Timeout) * time.Millisecond)
+		if deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+	return deadline
+}
+
 // ResultData represents task result
 type ResultData struct {
 	TaskID   string   `json:"task_id"`
@@ -208,37 +859,178 @@ type ResultData struct {
 	Error    string   `json:"error,omitempty"`
 	ProxyID  string   `json:"proxy_id"`
 	Duration int64    `json:"duration_ms"`
+
+	// Confidence holds each URL's extraction confidence, in URLs' order
+	Confidence []float64 `json:"confidence,omitempty"`
+
+	// Types holds each URL's result type (organic, ad, featured_snippet or
+	// people_also_ask), in URLs' order
+	Types []string `json:"types,omitempty"`
+
+	// Positions holds each URL's absolute SERP position, in URLs' order,
+	// counted from 1 at the top of the first page
+	Positions []int `json:"positions,omitempty"`
+
+	// Titles, Descriptions and DisplayURLs hold each URL's title, snippet
+	// and URL as the results page shows it, in URLs' order, with "" where
+	// the page has none; each is empty when no URL has one
+	Titles       []string `json:"titles,omitempty"`
+	Descriptions []string `json:"descriptions,omitempty"`
+	DisplayURLs  []string `json:"display_urls,omitempty"`
+
+	// Languages holds each URL's detected language, in URLs' order, with ""
+	// where it could not be told; empty unless detection is on
+	Languages []string `json:"languages,omitempty"`
+
+	// FinalURLs holds where each redirector link among URLs resolved to,
+	// in URLs' order, with "" for URLs left as they are
+	FinalURLs []string `json:"final_urls,omitempty"`
+
+	// Files holds the files listed by each open directory listing among
+	// URLs, in URLs' order, with none for URLs that are not listings
+	Files [][]string `json:"files,omitempty"`
+
+	// Matches holds the verification patterns each URL's page matched, in
+	// URLs' order, with none for URLs that matched nothing
+	Matches [][]engine.Match `json:"matches,omitempty"`
+
+	// Fingerprints holds the fingerprint of each URL's host, in URLs'
+	// order, with null for hosts that could not be reached
+	Fingerprints []*engine.Fingerprint `json:"fingerprints,omitempty"`
+
+	// Networks holds the network of each URL's host, in URLs' order, with
+	// null for hosts that did not resolve
+	Networks []*engine.Network `json:"networks,omitempty"`
+
+	// OutOfScope counts the URLs the task's scope withheld
+	OutOfScope int `json:"out_of_scope,omitempty"`
+
+	// Cached is set when the results came from the response cache
+	Cached bool `json:"cached,omitempty"`
+
+	// Duplicate is set when the task was a resent copy, answered with the
+	// first copy's result
+	Duplicate bool `json:"duplicate,omitempty"`
+
+	// Trace holds the phase timings of the request behind the result, for
+	// requests sampled by trace_rate
+	Trace *reqtrace.Timings `json:"trace,omitempty"`
+
+	// Fetch task output
+	URL        string `json:"url,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       string `json:"body,omitempty"` // base64 of the gzip-compressed body
 }
 
+// BodyEncoding describes how ResultData.Body is encoded on the wire
+const BodyEncoding = "gzip+base64"
+
 // ToMessage converts result data to a message
 func (r *ResultData) ToMessage() *Message {
 	msg := NewMessage(MsgTypeResult)
 	msg.SetData("task_id", r.TaskID)
 	msg.SetData("dork", r.Dork)
 	msg.SetData("urls", r.URLs)
+	if len(r.Confidence) > 0 {
+		msg.SetData("confidence", r.Confidence)
+	}
+	if len(r.Types) > 0 {
+		msg.SetData("types", r.Types)
+	}
+	if len(r.Positions) > 0 {
+		msg.SetData("positions", r.Positions)
+	}
+	if len(r.Titles) > 0 {
+		msg.SetData("titles", r.Titles)
+	}
+	if len(r.Descriptions) > 0 {
+		msg.SetData("descriptions", r.Descriptions)
+	}
+	if len(r.DisplayURLs) > 0 {
+		msg.SetData("display_urls", r.DisplayURLs)
+	}
+	if len(r.Languages) > 0 {
+		msg.SetData("languages", r.Languages)
+	}
+	if len(r.FinalURLs) > 0 {
+		msg.SetData("final_urls", r.FinalURLs)
+	}
+	if len(r.Files) > 0 {
+		msg.SetData("files", r.Files)
+	}
+	if len(r.Matches) > 0 {
+		msg.SetData("matches", r.Matches)
+	}
+	if len(r.Fingerprints) > 0 {
+		msg.SetData("fingerprints", r.Fingerprints)
+	}
+	if len(r.Networks) > 0 {
+		msg.SetData("networks", r.Networks)
+	}
+	if r.OutOfScope > 0 {
+		msg.SetData("out_of_scope", r.OutOfScope)
+	}
+	if r.Cached {
+		msg.SetData("cached", true)
+	}
+	if r.Duplicate {
+		msg.SetData("duplicate", true)
+	}
+	if r.Trace != nil {
+		msg.SetData("trace", r.Trace)
+	}
 	msg.SetData("status", r.Status)
 	msg.SetData("proxy_id", r.ProxyID)
 	msg.SetData("duration_ms", r.Duration)
 	if r.Error != "" {
 		msg.SetData("error", r.Error)
 	}
+	if r.URL != "" {
+		msg.SetData("url", r.URL)
+	}
+	if r.StatusCode != 0 {
+		msg.SetData("status_code", r.StatusCode)
+	}
+	if r.Body != "" {
+		msg.SetData("body", r.Body)
+		msg.SetData("body_encoding", BodyEncoding)
+	}
 	return msg
 }
 
 // StatsData represents worker statistics
 type StatsData struct {
-	TasksTotal     int64   `json:"tasks_total"`
-	TasksCompleted int64   `json:"tasks_completed"`
-	TasksFailed    int64   `json:"tasks_failed"`
-	TasksPending   int64   `json:"tasks_pending"`
-	URLsFound      int64   `json:"urls_found"`
-	CaptchaCount   int64   `json:"captcha_count"`
-	BlockCount     int64   `json:"block_count"`
-	ProxiesAlive   int     `json:"proxies_alive"`
-	ProxiesDead    int     `json:"proxies_dead"`
-	RequestsPerSec float64 `json:"requests_per_sec"`
-	ElapsedMs      int64   `json:"elapsed_ms"`
-	ETAMs          int64   `json:"eta_ms"`
+	TasksTotal        int64   `json:"tasks_total"`
+	TasksCompleted    int64   `json:"tasks_completed"`
+	TasksFailed       int64   `json:"tasks_failed"`
+	TasksPending      int64   `json:"tasks_pending"`
+	URLsFound         int64   `json:"urls_found"`
+	CaptchaCount      int64   `json:"captcha_count"`
+	BlockCount        int64   `json:"block_count"`
+	Requests          int64   `json:"requests"` // Outgoing requests, counted against max_requests
+	EstimatedCost     float64 `json:"estimated_cost"`
+	DuplicateURLs     int64   `json:"duplicate_urls"`      // Dropped by run-wide dedup
+	ClassifiedPages   int64   `json:"classified_pages"`    // Ambiguous pages the classifier settled
+	LayoutChanges     int64   `json:"layout_changes"`      // Pages the classifier found unparsed results on
+	LowConfidenceURLs int64   `json:"low_confidence_urls"` // Dropped below the minimum confidence
+	OutOfScopeURLs    int64   `json:"out_of_scope_urls"`   // Withheld by task scopes
+	ResolvedURLs      int64   `json:"resolved_urls"`       // Redirector links followed to their final URL
+	ListingFiles      int64   `json:"listing_files"`       // Files found crawling directory listings
+	VerifiedURLs      int64   `json:"verified_urls"`       // Results whose page matched a verification pattern
+	UnverifiedURLs    int64   `json:"unverified_urls"`     // Dropped for matching no verification pattern
+	HostFingerprints  int64   `json:"host_fingerprints"`   // Result hosts fingerprinted
+	ResolvedHosts     int64   `json:"resolved_hosts"`      // Result hosts resolved for their network
+	AlertFindings     int64   `json:"alert_findings"`      // Results that matched an alert rule
+	TitleFilteredURLs int64   `json:"title_filtered_urls"` // Dropped by title filters
+	OffLanguageURLs   int64   `json:"off_language_urls"`   // Dropped by language filters
+	CacheHits         int64   `json:"cache_hits"`          // Tasks served from the response cache
+	DuplicateTasks    int64   `json:"duplicate_tasks"`     // Resent tasks answered without running
+	ProxiesAlive      int     `json:"proxies_alive"`
+	ProxiesDead       int     `json:"proxies_dead"`
+	ProxiesProbation  int     `json:"proxies_probation"` // Alive proxies still on probation
+	RequestsPerSec    float64 `json:"requests_per_sec"`
+	ElapsedMs         int64   `json:"elapsed_ms"`
+	ETAMs             int64   `json:"eta_ms"`
 }
 
 // ToMessage converts stats data to a message
@@ -251,8 +1043,27 @@ func (s *StatsData) ToMessage() *Message {
 	msg.SetData("urls_found", s.URLsFound)
 	msg.SetData("captcha_count", s.CaptchaCount)
 	msg.SetData("block_count", s.BlockCount)
+	msg.SetData("requests", s.Requests)
+	msg.SetData("estimated_cost", s.EstimatedCost)
+	msg.SetData("duplicate_urls", s.DuplicateURLs)
+	msg.SetData("classified_pages", s.ClassifiedPages)
+	msg.SetData("layout_changes", s.LayoutChanges)
+	msg.SetData("low_confidence_urls", s.LowConfidenceURLs)
+	msg.SetData("out_of_scope_urls", s.OutOfScopeURLs)
+	msg.SetData("resolved_urls", s.ResolvedURLs)
+	msg.SetData("listing_files", s.ListingFiles)
+	msg.SetData("verified_urls", s.VerifiedURLs)
+	msg.SetData("unverified_urls", s.UnverifiedURLs)
+	msg.SetData("host_fingerprints", s.HostFingerprints)
+	msg.SetData("resolved_hosts", s.ResolvedHosts)
+	msg.SetData("alert_findings", s.AlertFindings)
+	msg.SetData("title_filtered_urls", s.TitleFilteredURLs)
+	msg.SetData("off_language_urls", s.OffLanguageURLs)
+	msg.SetData("cache_hits", s.CacheHits)
+	msg.SetData("duplicate_tasks", s.DuplicateTasks)
 	msg.SetData("proxies_alive", s.ProxiesAlive)
 	msg.SetData("proxies_dead", s.ProxiesDead)
+	msg.SetData("proxies_probation", s.ProxiesProbation)
 	msg.SetData("requests_per_sec", s.RequestsPerSec)
 	msg.SetData("elapsed_ms", s.ElapsedMs)
 	msg.SetData("eta_ms", s.ETAMs)
@@ -264,6 +1075,8 @@ type ProgressData struct {
 	Current    int64   `json:"current"`
 	Total      int64   `json:"total"`
 	Percentage float64 `json:"percentage"`
+
+	EstimatedCost float64 `json:"estimated_cost,omitempty"` // Set when a cost model is configured
 }
 
 // ToMessage converts progress data to a message
@@ -272,6 +1085,63 @@ func (p *ProgressData) ToMessage() *Message {
 	msg.SetData("current", p.Current)
 	msg.SetData("total", p.Total)
 	msg.SetData("percentage", p.Percentage)
+	if p.EstimatedCost > 0 {
+		msg.SetData("estimated_cost", p.EstimatedCost)
+	}
+	return msg
+}
+
+// ProxyCheckData represents the outcome of checking a single proxy
+type ProxyCheckData struct {
+	ProxyID   string `json:"proxy_id"`
+	Alive     bool   `json:"alive"`
+	LatencyMs int64  `json:"latency_ms"`
+	Anonymity string `json:"anonymity"`
+	Country   string `json:"country,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ToMessage converts proxy check data to a message
+func (p *ProxyCheckData) ToMessage() *Message {
+	msg := NewMessage(MsgTypeProxyCheck)
+	msg.SetData("proxy_id", p.ProxyID)
+	msg.SetData("alive", p.Alive)
+	msg.SetData("latency_ms", p.LatencyMs)
+	msg.SetData("anonymity", p.Anonymity)
+	if p.Country != "" {
+		msg.SetData("country", p.Country)
+	}
+	if p.Error != "" {
+		msg.SetData("error", p.Error)
+	}
+	return msg
+}
+
+// LogEntryData represents a buffered log entry
+type LogEntryData struct {
+	Timestamp int64  `json:"ts"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+}
+
+// LogsData represents the response to a get_logs request
+type LogsData struct {
+	Entries []LogEntryData `json:"entries"`
+}
+
+// ToMessage converts logs data to a message
+func (l *LogsData) ToMessage() *Message {
+	msg := NewMessage(MsgTypeLogs)
+	entries := make([]map[string]any, 0, len(l.Entries))
+	for _, e := range l.Entries {
+		entries = append(entries, map[string]any{
+			"ts":      e.Timestamp,
+			"level":   e.Level,
+			"message": e.Message,
+		})
+	}
+	msg.SetData("entries", entries)
+	msg.SetData("count", len(entries))
 	return msg
 }
 
@@ -282,16 +1152,38 @@ type Handler struct {
 	writeMu sync.Mutex
 
 	// Callbacks
-	onInit     func(*InitConfig)
-	onTask     func(*TaskData)
-	onPause    func()
-	onResume   func()
-	onShutdown func()
-	onGetStats func()
+	onInit         func(*InitConfig)
+	onTask         func(*TaskData)
+	onPause        func()
+	onResume       func()
+	onShutdown     func()
+	onGetStats     func()
+	onCheckProxies func(proxyIDs []string)
+	onGetLogs      func(limit int, level string)
+	onGetConfig    func()
+	onReattach     func()
+	onExport       func(path string)
+	onImport       func(path string)
+
+	// Controller reattachment: while detached, outgoing lines are held
+	// for the next controller accepted on listener
+	listener net.Listener
+	conn     net.Conn
+	detached bool
+	held     []string
+	dropped  int
+
+	// Profile applied when an init message does not name one
+	defaultProfile string
+
+	// Recent traffic for crash reports
+	history history
 
 	// State
-	running bool
-	stopCh  chan struct{}
+	running  bool
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	reason   ShutdownReason
 }
 
 // NewHandler creates a new IPC handler
@@ -342,8 +1234,39 @@ func (h *Handler) OnGetStats(fn func()) {
 	h.onGetStats = fn
 }
 
-// Start starts listening for messages
-func (h *Handler) Start() {
+// OnCheckProxies sets the proxy check callback. An empty ID list means all proxies.
+func (h *Handler) OnCheckProxies(fn func(proxyIDs []string)) {
+	h.onCheckProxies = fn
+}
+
+// OnGetConfig sets the get_config handler
+func (h *Handler) OnGetConfig(fn func()) {
+	h.onGetConfig = fn
+}
+
+// OnExportSnapshot sets the export_snapshot callback, given the archive path
+func (h *Handler) OnExportSnapshot(fn func(path string)) {
+	h.onExport = fn
+}
+
+// OnImportSnapshot sets the import_snapshot callback, given the archive path
+func (h *Handler) OnImportSnapshot(fn func(path string)) {
+	h.onImport = fn
+}
+
+// SetDefaultProfile sets the profile used for init messages without one
+func (h *Handler) SetDefaultProfile(name string) {
+	h.defaultProfile = name
+}
+
+// OnGetLogs sets the get_logs handler. limit 0 means all buffered entries;
+// level is the minimum level and may be empty.
+func (h *Handler) OnGetLogs(fn func(limit int, level string)) {
+	h.onGetLogs = fn
+}
+
+// Start starts listening for messages and returns why listening ended
+func (h *Handler) Start() ShutdownReason {
 	h.running = true
 
 	// Send ready message
@@ -352,38 +1275,73 @@ func (h *Handler) Start() {
 	for h.running {
 		select {
 		case <-h.stopCh:
-			return
+			return h.reason
 		default:
 			h.readMessage()
 		}
 	}
+	return h.reason
 }
 
 // Stop stops the handler
 func (h *Handler) Stop() {
-	h.running = false
-	close(h.stopCh)
+	h.StopWithReason(ReasonShutdown)
+}
+
+// StopWithReason stops the handler, recording why. Only the first call counts.
+func (h *Handler) StopWithReason(reason ShutdownReason) {
+	h.stopOnce.Do(func() {
+		h.reason = reason
+		h.running = false
+		close(h.stopCh)
+		if h.listener != nil {
+			h.listener.Close()
+		}
+	})
 }
 
-// readMessage reads and processes a single message
+// readMessage reads and processes a single message, or waits for a new
+// controller while detached
 func (h *Handler) readMessage() {
+	if h.Detached() {
+		h.attach()
+		return
+	}
+
 	line, err := h.reader.ReadString('\n')
 	if err != nil {
 		if err != io.EOF {
 			h.SendError("read_error", err.Error())
+			return
+		}
+		// Process a final unterminated line before treating EOF as disconnect
+		if line != "" {
+			h.processLine(line)
 		}
+		if h.listener != nil && h.running {
+			h.detach()
+			return
+		}
+		h.StopWithReason(ReasonControllerEOF)
 		return
 	}
 
+	h.processLine(line)
+}
+
+// processLine parses and dispatches a single raw message line
+func (h *Handler) processLine(line string) {
 	if line == "" || line == "\n" {
 		return
 	}
 
 	var msg Message
 	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		h.history.record(DirectionIn, strings.TrimSuffix(line, "\n"))
 		h.SendError("parse_error", err.Error())
 		return
 	}
+	h.history.record(DirectionIn, redactedLine(&msg, strings.TrimSuffix(line, "\n")))
 
 	h.handleMessage(&msg)
 }
@@ -393,7 +1351,7 @@ func (h *Handler) handleMessage(msg *Message) {
 	switch msg.Type {
 	case MsgTypeInit:
 		if h.onInit != nil {
-			config := ParseInitConfig(msg)
+			config := ParseInitConfigWithProfile(msg, h.defaultProfile)
 			h.onInit(config)
 		}
 
@@ -410,12 +1368,39 @@ func (h *Handler) handleMessage(msg *Message) {
 				for _, t := range tasks {
 					if taskMap, ok := t.(map[string]any); ok {
 						task := &TaskData{
-							ID:   fmt.Sprintf("%v", taskMap["id"]),
 							Dork: fmt.Sprintf("%v", taskMap["dork"]),
 						}
+						// Without an ID the worker derives one
+						if id, ok := taskMap["id"]; ok && id != nil {
+							task.ID = fmt.Sprintf("%v", id)
+						}
 						if page, ok := taskMap["page"].(float64); ok {
 							task.Page = int(page)
 						}
+						if taskType, ok := taskMap["type"].(string); ok {
+							task.Type = taskType
+						}
+						if u, ok := taskMap["url"].(string); ok {
+							task.URL = u
+						}
+						if min, ok := taskMap["min_confidence"].(float64); ok {
+							task.MinConfidence = min
+						}
+						if patterns, ok := taskMap["scope"].([]any); ok {
+							for _, p := range patterns {
+								if s, ok := p.(string); ok {
+									task.Scope = append(task.Scope, s)
+								}
+							}
+						}
+						if granularity, ok := taskMap["dedup_granularity"].(string); ok {
+							task.DedupGranularity = granularity
+						}
+						fields := &Message{Data: taskMap}
+						task.TitleInclude = fields.GetStringSlice("title_include")
+						task.TitleExclude = fields.GetStringSlice("title_exclude")
+						task.Languages = fields.GetStringSlice("languages")
+						task.IdempotencyKey = fields.GetString("idempotency_key")
 						h.onTask(task)
 					}
 				}
@@ -439,13 +1424,38 @@ func (h *Handler) handleMessage(msg *Message) {
 			h.onShutdown()
 		}
 		h.SendStatus("shutdown", "")
-		h.Stop()
+		h.StopWithReason(ReasonShutdown)
 
 	case MsgTypeGetStats:
 		if h.onGetStats != nil {
 			h.onGetStats()
 		}
 
+	case MsgTypeCheckProxies:
+		if h.onCheckProxies != nil {
+			h.onCheckProxies(msg.GetStringSlice("proxy_ids"))
+		}
+
+	case MsgTypeGetConfig:
+		if h.onGetConfig != nil {
+			h.onGetConfig()
+		}
+
+	case MsgTypeGetLogs:
+		if h.onGetLogs != nil {
+			h.onGetLogs(msg.GetInt("limit"), msg.GetString("level"))
+		}
+
+	case MsgTypeExportSnapshot:
+		if h.onExport != nil {
+			h.onExport(msg.GetString("path"))
+		}
+
+	case MsgTypeImportSnapshot:
+		if h.onImport != nil {
+			h.onImport(msg.GetString("path"))
+		}
+
 	default:
 		h.SendError("unknown_type", fmt.Sprintf("unknown message type: %s", msg.Type))
 	}
@@ -460,11 +1470,22 @@ func (h *Handler) Send(msg *Message) error {
 	if err != nil {
 		return err
 	}
+	h.history.record(DirectionOut, string(data))
 
+	if h.detached {
+		h.hold(string(data))
+		return nil
+	}
 	_, err = fmt.Fprintln(h.writer, string(data))
 	return err
 }
 
+// History returns the most recent protocol lines in both directions,
+// oldest first. Proxy lists in init messages are not kept.
+func (h *Handler) History() []HistoryEntry {
+	return h.history.snapshot()
+}
+
 // SendStatus sends a status message
 func (h *Handler) SendStatus(status string, message string) error {
 	msg := NewMessage(MsgTypeStatus)
@@ -506,6 +1527,14 @@ func (h *Handler) SendLog(level string, message string) error {
 	return h.Send(msg)
 }
 
+// SendAlert sends an alert event; the run continues
+func (h *Handler) SendAlert(event, message string) error {
+	msg := NewMessage(MsgTypeAlert)
+	msg.SetData("event", event)
+	msg.SetData("message", message)
+	return h.Send(msg)
+}
+
 // SendProxyInfo sends proxy information
 func (h *Handler) SendProxyInfo(alive, dead, quarantined int) error {
 	msg := NewMessage(MsgTypeProxyInfo)
@@ -515,3 +1544,50 @@ func (h *Handler) SendProxyInfo(alive, dead, quarantined int) error {
 	msg.SetData("total", alive+dead+quarantined)
 	return h.Send(msg)
 }
+
+// SendProxyCheck sends the result of checking a single proxy
+func (h *Handler) SendProxyCheck(check *ProxyCheckData) error {
+	return h.Send(check.ToMessage())
+}
+
+// SendLogs sends buffered log entries
+func (h *Handler) SendLogs(logs *LogsData) error {
+	return h.Send(logs.ToMessage())
+}
+
+// SendDone sends the final message for an orderly termination
+func (h *Handler) SendDone(reason ShutdownReason, message string) error {
+	msg := NewMessage(MsgTypeDone)
+	msg.SetData("reason", string(reason))
+	msg.SetData("exit_code", reason.ExitCode())
+	if message != "" {
+		msg.SetData("message", message)
+	}
+	return h.Send(msg)
+}
+
+// SendFatal sends the final message for a fatal termination
+func (h *Handler) SendFatal(reason ShutdownReason, message string) error {
+	return h.SendFatalWithDump(reason, message, "")
+}
+
+// SendFatalWithDump sends the final message for a fatal termination,
+// pointing at the diagnostic bundle written for it when dumpPath is set
+func (h *Handler) SendFatalWithDump(reason ShutdownReason, message, dumpPath string) error {
+	msg := NewMessage(MsgTypeFatal)
+	msg.SetData("reason", string(reason))
+	msg.SetData("exit_code", reason.ExitCode())
+	msg.SetData("message", message)
+	if dumpPath != "" {
+		msg.SetData("crash_dump", dumpPath)
+	}
+	return h.Send(msg)
+}
+
+// SendTermination sends done or fatal depending on the reason
+func (h *Handler) SendTermination(reason ShutdownReason, message string) error {
+	if reason.IsFatal() {
+		return h.SendFatal(reason, message)
+	}
+	return h.SendDone(reason, message)
+}
@@ -1,11 +1,19 @@
 package protocol
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"dorker/worker/internal/reqtrace"
 )
 
 func TestNewMessage(t *testing.T) {
@@ -101,6 +109,115 @@ func TestMessageGetStringSlice(t *testing.T) {
 	}
 }
 
+func TestMessageGetIntMap(t *testing.T) {
+	msg := NewMessage(MsgTypeInit)
+	var data map[string]any
+	json.Unmarshal([]byte(`{"google": 500, "fetch": 20, "bad": "x"}`), &data)
+	msg.SetData("engine_max_requests", data)
+
+	got := msg.GetIntMap("engine_max_requests")
+	if len(got) != 2 || got["google"] != 500 || got["fetch"] != 20 {
+		t.Errorf("GetIntMap = %v", got)
+	}
+
+	if msg.GetIntMap("missing") != nil {
+		t.Error("GetIntMap of a missing key should be nil")
+	}
+}
+
+func TestParseInitConfigCosts(t *testing.T) {
+	var data map[string]any
+	json.Unmarshal([]byte(`{"costs": {"proxies": {"*.provider.com": {"per_gb": 8}}, "engines": {"google": {"per_request": 0.002}}}}`), &data)
+	msg := &Message{Type: MsgTypeInit, Data: data}
+
+	config := ParseInitConfig(msg)
+	if config.Costs.Proxies["*.provider.com"].PerGB != 8 || config.Costs.Engines["google"].PerRequest != 0.002 {
+		t.Errorf("Costs = %+v", config.Costs)
+	}
+
+	if _, ok := config.ToMessage().Data["costs"]; !ok {
+		t.Error("config message should include the cost model")
+	}
+
+	msg.SetData("costs", "cheap")
+	if !ParseInitConfig(msg).Costs.Empty() {
+		t.Error("a malformed cost model should be ignored")
+	}
+}
+
+func TestParseInitConfigBind(t *testing.T) {
+	var data map[string]any
+	json.Unmarshal([]byte(`{"bind": {"*.provider.com": "eth1", "*": "10.0.0.5"}}`), &data)
+	msg := &Message{Type: MsgTypeInit, Data: data}
+
+	config := ParseInitConfig(msg)
+	if config.Bind["*.provider.com"] != "eth1" || config.Bind["*"] != "10.0.0.5" {
+		t.Errorf("Bind = %v", config.Bind)
+	}
+	if _, ok := config.ToMessage().Data["bind"]; !ok {
+		t.Error("config message should include the bind rules")
+	}
+
+	msg.SetData("bind", "eth2")
+	if bind := ParseInitConfig(msg).Bind; len(bind) != 1 || bind["*"] != "eth2" {
+		t.Errorf("Bind from a string = %v, want every group bound", bind)
+	}
+	msg.SetData("bind", []any{1})
+	if bind := ParseInitConfig(msg).Bind; bind != nil {
+		t.Errorf("a malformed bind should be ignored, got %v", bind)
+	}
+}
+
+func TestParseInitConfigWeights(t *testing.T) {
+	var data map[string]any
+	json.Unmarshal([]byte(`{"weights": {"success_bonus": 0, "slow_latency": 2000}}`), &data)
+	msg := &Message{Type: MsgTypeInit, Data: data}
+
+	config := ParseInitConfig(msg)
+	if w := config.Weights; w.SuccessBonus == nil || *w.SuccessBonus != 0 || w.SlowLatency == nil || *w.SlowLatency != 2000 || w.SlowFactor != nil {
+		t.Errorf("Weights = %+v, want success_bonus and slow_latency set", w)
+	}
+	if _, ok := config.ToMessage().Data["weights"]; !ok {
+		t.Error("config message should include the weights")
+	}
+
+	msg.SetData("weights", []any{1, 2})
+	if !ParseInitConfig(msg).Weights.Empty() {
+		t.Error("malformed weights should be ignored")
+	}
+}
+
+func TestParseInitConfigAssetFilter(t *testing.T) {
+	var data map[string]any
+	json.Unmarshal([]byte(`{"asset_filter": {"deny": [".pdf"], "allow": [".svg", "unpkg.com"]}}`), &data)
+	msg := &Message{Type: MsgTypeInit, Data: data}
+
+	config := ParseInitConfig(msg)
+	if f := config.AssetFilter; f.Disabled || len(f.Deny) != 1 || len(f.Allow) != 2 {
+		t.Errorf("AssetFilter = %+v, want one deny and two allow entries", f)
+	}
+	if _, ok := config.ToMessage().Data["asset_filter"]; !ok {
+		t.Error("config message should include the asset filter")
+	}
+}
+
+func TestParseInitConfigDedup(t *testing.T) {
+	msg := NewMessage(MsgTypeInit)
+	msg.SetData("dedup", "bloom")
+	msg.SetData("dedup_capacity", 50000000)
+	msg.SetData("dedup_fp_rate", 0.0001)
+	msg.SetData("dedup_spill", "/tmp/dedup.spill")
+
+	config := ParseInitConfig(msg)
+	if config.Dedup != "bloom" || config.DedupCapacity != 50000000 || config.DedupFPRate != 0.0001 || config.DedupSpill != "/tmp/dedup.spill" {
+		t.Errorf("dedup config = %q %d %v %q", config.Dedup, config.DedupCapacity, config.DedupFPRate, config.DedupSpill)
+	}
+
+	if ParseInitConfig(NewMessage(MsgTypeInit)).ToMessage().Data["dedup"] != nil {
+		t.Error("config message should omit dedup when it is not configured")
+	}
+}
+
 func TestMessageGetStringSliceMissing(t *testing.T) {
 	msg := NewMessage(MsgTypeStatus)
 
@@ -119,6 +236,8 @@ func TestParseInitConfig(t *testing.T) {
 	msg.SetData("max_delay", 15000)
 	msg.SetData("max_retries", 5)
 	msg.SetData("results_per_page", 50)
+	msg.SetData("task_timeout", 60000)
+	msg.SetData("max_run_duration", 28800000)
 	msg.SetData("proxy_file", "/path/to/proxies.txt")
 
 	config := ParseInitConfig(msg)
@@ -135,6 +254,10 @@ func TestParseInitConfig(t *testing.T) {
 		t.Errorf("MaxRetries = %d, want 5", config.MaxRetries)
 	}
 
+	if config.TaskTimeout != time.Minute || config.MaxRunDuration != 8*time.Hour {
+		t.Errorf("TaskTimeout = %v, MaxRunDuration = %v", config.TaskTimeout, config.MaxRunDuration)
+	}
+
 	if config.ProxyFile != "/path/to/proxies.txt" {
 		t.Errorf("ProxyFile = %q", config.ProxyFile)
 	}
@@ -183,14 +306,176 @@ func TestParseTaskData(t *testing.T) {
 	}
 }
 
+func TestParseTaskDataFetch(t *testing.T) {
+	msg := NewMessage(MsgTypeTask)
+	msg.SetData("task_id", "fetch_001")
+	msg.SetData("type", "fetch")
+	msg.SetData("url", "https://example.com/page")
+
+	task := ParseTaskData(msg)
+
+	if task.Type != "fetch" {
+		t.Errorf("Type = %q, want %q", task.Type, "fetch")
+	}
+
+	if task.URL != "https://example.com/page" {
+		t.Errorf("URL = %q, want %q", task.URL, "https://example.com/page")
+	}
+}
+
+func TestParseTaskDataMinConfidence(t *testing.T) {
+	msg := NewMessage(MsgTypeTask)
+	msg.SetData("task_id", "task_001")
+	msg.SetData("dork", "inurl:admin")
+	msg.SetData("min_confidence", 0.6)
+
+	if task := ParseTaskData(msg); task.MinConfidence != 0.6 {
+		t.Errorf("MinConfidence = %v, want 0.6", task.MinConfidence)
+	}
+}
+
+func TestHandlerTaskBatchScope(t *testing.T) {
+	var received []*TaskData
+
+	input := `{"type":"task_batch","ts":1234567890,"data":{"tasks":[{"id":"1","dork":"a","scope":["*.target.com","api.other.io"]},{"id":"2","dork":"b"}]}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+	h.OnTask(func(task *TaskData) {
+		received = append(received, task)
+	})
+
+	h.readMessage()
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(received))
+	}
+	if scope := received[0].Scope; len(scope) != 2 || scope[0] != "*.target.com" {
+		t.Errorf("Scope = %q, want [*.target.com api.other.io]", scope)
+	}
+	if received[1].Scope != nil {
+		t.Errorf("task without scope got %q", received[1].Scope)
+	}
+}
+
+func TestHandlerTaskBatchTitleFilters(t *testing.T) {
+	var received []*TaskData
+
+	input := `{"type":"task_batch","ts":1234567890,"data":{"tasks":[{"id":"1","dork":"a","title_include":["index of"],"title_exclude":["404","not found"]},{"id":"2","dork":"b"}]}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+	h.OnTask(func(task *TaskData) {
+		received = append(received, task)
+	})
+
+	h.readMessage()
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(received))
+	}
+	if got := received[0]; len(got.TitleInclude) != 1 || len(got.TitleExclude) != 2 {
+		t.Errorf("title filters = %q / %q", got.TitleInclude, got.TitleExclude)
+	}
+	if received[1].TitleInclude != nil || received[1].TitleExclude != nil {
+		t.Error("task without title filters got some")
+	}
+}
+
+func TestHandlerTaskBatchIdempotency(t *testing.T) {
+	var received []*TaskData
+
+	input := `{"type":"task_batch","ts":1234567890,"data":{"tasks":[{"dork":"a","idempotency_key":"k1"},{"id":7,"dork":"b"}]}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+	h.OnTask(func(task *TaskData) {
+		received = append(received, task)
+	})
+
+	h.readMessage()
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(received))
+	}
+	if got := received[0]; got.ID != "" || got.IdempotencyKey != "k1" {
+		t.Errorf("task without id = %+v, want empty ID and key k1", got)
+	}
+	if got := received[1]; got.ID != "7" || got.IdempotencyKey != "" {
+		t.Errorf("task with id = %+v", got)
+	}
+}
+
+func TestTaskDataDeadline(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+
+	msg := NewMessage(MsgTypeTask)
+	msg.SetData("task_id", "task_001")
+	msg.SetData("deadline", 1700000060000)
+	msg.SetData("timeout", 30000)
+
+	task := ParseTaskData(msg)
+	if task.Deadline != 1700000060000 || task.Timeout != 30000 {
+		t.Fatalf("Deadline = %d, Timeout = %d", task.Deadline, task.Timeout)
+	}
+
+	// The earlier of the two applies
+	if got, want := task.DeadlineFrom(now), now.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("DeadlineFrom = %v, want %v", got, want)
+	}
+
+	task.Timeout = 0
+	if got, want := task.DeadlineFrom(now), time.UnixMilli(1700000060000); !got.Equal(want) {
+		t.Errorf("DeadlineFrom without timeout = %v, want %v", got, want)
+	}
+
+	if !(&TaskData{}).DeadlineFrom(now).IsZero() {
+		t.Error("task without deadline or timeout should have a zero deadline")
+	}
+}
+
+func TestFetchResultDataToMessage(t *testing.T) {
+	result := &ResultData{
+		TaskID:     "fetch_001",
+		Status:     "success",
+		URL:        "https://example.com/page",
+		StatusCode: 200,
+		Body:       "H4sIAAAAAAAA/w==",
+	}
+
+	msg := result.ToMessage()
+
+	if msg.GetString("url") != "https://example.com/page" {
+		t.Errorf("url = %q", msg.GetString("url"))
+	}
+
+	if msg.GetInt("status_code") != 200 {
+		t.Errorf("status_code = %d, want 200", msg.GetInt("status_code"))
+	}
+
+	if msg.GetString("body_encoding") != BodyEncoding {
+		t.Errorf("body_encoding = %q, want %q", msg.GetString("body_encoding"), BodyEncoding)
+	}
+
+	// Search results carry no body fields
+	search := (&ResultData{TaskID: "task_001", Status: "success"}).ToMessage()
+	if _, ok := search.Data["body"]; ok {
+		t.Error("search result should not include body")
+	}
+}
+
 func TestResultDataToMessage(t *testing.T) {
 	result := &ResultData{
-		TaskID:   "task_001",
-		Dork:     "inurl:admin",
-		URLs:     []string{"https://example.com/admin", "https://test.org/admin"},
-		Status:   "success",
-		ProxyID:  "proxy_001",
-		Duration: 1500,
+		TaskID:    "task_001",
+		Dork:      "inurl:admin",
+		URLs:      []string{"https://example.com/admin", "https://test.org/admin"},
+		Positions: []int{11, 14},
+		Status:    "success",
+		ProxyID:   "proxy_001",
+		Duration:  1500,
 	}
 
 	msg := result.ToMessage()
@@ -206,6 +491,26 @@ func TestResultDataToMessage(t *testing.T) {
 	if msg.GetString("status") != "success" {
 		t.Errorf("status = %q", msg.GetString("status"))
 	}
+
+	if positions, _ := msg.Data["positions"].([]int); len(positions) != 2 || positions[1] != 14 {
+		t.Errorf("positions = %v", msg.Data["positions"])
+	}
+}
+
+func TestResultDataTrace(t *testing.T) {
+	result := &ResultData{TaskID: "task_001", Status: "blocked"}
+	if _, ok := result.ToMessage().Data["trace"]; ok {
+		t.Error("untraced result carries a trace")
+	}
+
+	result.Trace = &reqtrace.Timings{Connect: 40, TLS: 380, TTFB: 2100, Total: 2520}
+	data, err := json.Marshal(result.ToMessage())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"trace":{"dns_ms":0,"connect_ms":40,"tls_ms":380,"ttfb_ms":2100,"total_ms":2520}`) {
+		t.Errorf("message = %s", data)
+	}
 }
 
 func TestResultDataWithError(t *testing.T) {
@@ -434,6 +739,20 @@ func TestHandlerSendProxyInfo(t *testing.T) {
 	}
 }
 
+func TestHandlerSendAlert(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(""), &buf)
+
+	if err := h.SendAlert("parser_degraded", "markup changed"); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"type":"alert"`) || !strings.Contains(output, `"event":"parser_degraded"`) {
+		t.Errorf("unexpected alert output: %s", output)
+	}
+}
+
 func TestHandlerCallbacks(t *testing.T) {
 	initCalled := false
 	taskCalled := false
@@ -513,6 +832,30 @@ func TestHandlerTaskBatch(t *testing.T) {
 	}
 }
 
+func TestHandlerTaskBatchFetch(t *testing.T) {
+	var received []*TaskData
+
+	input := `{"type":"task_batch","ts":1234567890,"data":{"tasks":[{"id":"1","type":"fetch","url":"https://example.com/"}]}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+
+	h.OnTask(func(task *TaskData) {
+		received = append(received, task)
+	})
+
+	h.readMessage()
+
+	if len(received) != 1 {
+		t.Fatalf("tasksReceived = %d, want 1", len(received))
+	}
+
+	if received[0].Type != "fetch" || received[0].URL != "https://example.com/" {
+		t.Errorf("task = %+v, want fetch of https://example.com/", received[0])
+	}
+}
+
 func TestHandlerShutdown(t *testing.T) {
 	shutdownCalled := false
 
@@ -644,3 +987,384 @@ func TestMessageTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestHandlerCheckProxies(t *testing.T) {
+	var received []string
+	called := false
+
+	input := `{"type":"check_proxies","ts":1234567890,"data":{"proxy_ids":["p1","p2"]}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+
+	h.OnCheckProxies(func(proxyIDs []string) {
+		called = true
+		received = proxyIDs
+	})
+
+	h.readMessage()
+
+	if !called {
+		t.Fatal("check_proxies callback not called")
+	}
+
+	if len(received) != 2 || received[0] != "p1" {
+		t.Errorf("proxyIDs = %v, want [p1 p2]", received)
+	}
+}
+
+func TestProxyCheckDataToMessage(t *testing.T) {
+	check := &ProxyCheckData{
+		ProxyID:   "http_1.2.3.4_8080",
+		Alive:     true,
+		LatencyMs: 420,
+		Anonymity: "elite",
+		Country:   "US",
+	}
+
+	msg := check.ToMessage()
+
+	if msg.Type != MsgTypeProxyCheck {
+		t.Errorf("Type = %q, want %q", msg.Type, MsgTypeProxyCheck)
+	}
+
+	if !msg.GetBool("alive") {
+		t.Error("alive should be true")
+	}
+
+	if msg.GetInt("latency_ms") != 420 {
+		t.Errorf("latency_ms = %d, want 420", msg.GetInt("latency_ms"))
+	}
+
+	if msg.GetString("country") != "US" {
+		t.Errorf("country = %q, want US", msg.GetString("country"))
+	}
+
+	if _, ok := msg.Data["error"]; ok {
+		t.Error("error should be omitted when empty")
+	}
+}
+
+func TestHandlerStartReturnsReason(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ShutdownReason
+	}{
+		{"shutdown message", `{"type":"shutdown","ts":1}` + "\n", ReasonShutdown},
+		{"controller eof", `{"type":"get_stats","ts":1}` + "\n", ReasonControllerEOF},
+		{"eof on unterminated line", `{"type":"shutdown","ts":1}`, ReasonShutdown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := NewHandlerWithIO(strings.NewReader(tt.input), &buf)
+
+			if got := h.Start(); got != tt.want {
+				t.Errorf("Start() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdownReasonExitCodes(t *testing.T) {
+	reasons := []ShutdownReason{
+		ReasonShutdown,
+		ReasonControllerEOF,
+		ReasonInitFailed,
+		ReasonPoolExhausted,
+		ReasonPanic,
+		ReasonSignal,
+		ReasonDeadline,
+		ReasonBudget,
+	}
+
+	// Every reason maps to a distinct exit code
+	seen := make(map[int]ShutdownReason)
+	for _, r := range reasons {
+		code := r.ExitCode()
+		if other, ok := seen[code]; ok {
+			t.Errorf("%q and %q share exit code %d", r, other, code)
+		}
+		seen[code] = r
+	}
+
+	if ReasonShutdown.ExitCode() != ExitOK {
+		t.Errorf("shutdown exit code = %d, want %d", ReasonShutdown.ExitCode(), ExitOK)
+	}
+
+	if ReasonShutdown.IsFatal() || ReasonSignal.IsFatal() || ReasonControllerEOF.IsFatal() || ReasonDeadline.IsFatal() || ReasonBudget.IsFatal() {
+		t.Error("orderly reasons should not be fatal")
+	}
+
+	if !ReasonPanic.IsFatal() || !ReasonInitFailed.IsFatal() || !ReasonPoolExhausted.IsFatal() {
+		t.Error("error reasons should be fatal")
+	}
+}
+
+func TestHandlerSendTermination(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(""), &buf)
+
+	h.SendTermination(ReasonControllerEOF, "")
+	h.SendTermination(ReasonPoolExhausted, "all proxies are dead")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d messages, want 2", len(lines))
+	}
+
+	var done, fatal Message
+	json.Unmarshal([]byte(lines[0]), &done)
+	json.Unmarshal([]byte(lines[1]), &fatal)
+
+	if done.Type != MsgTypeDone || done.GetString("reason") != "controller_eof" {
+		t.Errorf("first message = %s, want done/controller_eof", lines[0])
+	}
+
+	if fatal.Type != MsgTypeFatal || fatal.GetInt("exit_code") != ExitPoolExhausted {
+		t.Errorf("second message = %s, want fatal with exit code %d", lines[1], ExitPoolExhausted)
+	}
+}
+
+func TestLoadInitConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.json")
+	os.WriteFile(path, []byte(`{"workers":25,"base_delay":2000,"proxy_file":"proxies.txt"}`), 0644)
+
+	config, err := LoadInitConfigFile(path, "")
+	if err != nil {
+		t.Fatalf("LoadInitConfigFile failed: %v", err)
+	}
+
+	if config.Workers != 25 {
+		t.Errorf("Workers = %d, want 25", config.Workers)
+	}
+
+	if config.BaseDelay != 2*time.Second {
+		t.Errorf("BaseDelay = %v, want 2s", config.BaseDelay)
+	}
+
+	if config.ProxyFile != "proxies.txt" {
+		t.Errorf("ProxyFile = %q, want proxies.txt", config.ProxyFile)
+	}
+
+	// Unset keys fall back to init defaults
+	if config.MaxRetries != 3 {
+		t.Errorf("MaxRetries = %d, want default 3", config.MaxRetries)
+	}
+
+	if _, err := LoadInitConfigFile(filepath.Join(t.TempDir(), "missing.json"), ""); err == nil {
+		t.Error("LoadInitConfigFile should fail for missing file")
+	}
+}
+
+func TestHandlerGetLogs(t *testing.T) {
+	var gotLimit int
+	var gotLevel string
+	called := false
+
+	input := `{"type":"get_logs","ts":1234567890,"data":{"limit":50,"level":"warn"}}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+
+	h.OnGetLogs(func(limit int, level string) {
+		called = true
+		gotLimit = limit
+		gotLevel = level
+	})
+
+	h.readMessage()
+
+	if !called {
+		t.Fatal("get_logs callback not called")
+	}
+	if gotLimit != 50 || gotLevel != "warn" {
+		t.Errorf("got limit=%d level=%q, want 50 warn", gotLimit, gotLevel)
+	}
+}
+
+func TestLogsDataToMessage(t *testing.T) {
+	logs := &LogsData{
+		Entries: []LogEntryData{
+			{Timestamp: 1, Level: "info", Message: "started"},
+			{Timestamp: 2, Level: "error", Message: "boom"},
+		},
+	}
+
+	msg := logs.ToMessage()
+
+	if msg.Type != MsgTypeLogs {
+		t.Errorf("Type = %q, want %q", msg.Type, MsgTypeLogs)
+	}
+	if msg.GetInt("count") != 2 {
+		t.Errorf("count = %d, want 2", msg.GetInt("count"))
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"message":"boom"`) {
+		t.Errorf("entries missing from %s", data)
+	}
+}
+
+func TestHandlerGetConfig(t *testing.T) {
+	called := false
+
+	input := `{"type":"get_config","ts":1234567890}
+`
+
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+
+	h.OnGetConfig(func() {
+		called = true
+	})
+
+	h.readMessage()
+
+	if !called {
+		t.Error("get_config callback not called")
+	}
+}
+
+func TestHandlerHistory(t *testing.T) {
+	input := `{"type":"init","ts":0,"data":{"workers":2,"proxies":["user:secret@1.2.3.4:8080"]}}` + "\n" +
+		`not json` + "\n"
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+	h.Start()
+
+	history := h.History()
+	if len(history) < 3 {
+		t.Fatalf("history = %+v", history)
+	}
+
+	// The ready status goes out before anything is read
+	if history[0].Direction != DirectionOut || !strings.Contains(history[0].Line, `"ready"`) {
+		t.Errorf("first entry = %+v", history[0])
+	}
+	init := history[1]
+	if init.Direction != DirectionIn || strings.Contains(init.Line, "secret") || !strings.Contains(init.Line, `"proxy_count":1`) {
+		t.Errorf("init entry = %+v, want proxies redacted", init)
+	}
+	if history[2].Line != "not json" {
+		t.Errorf("unparseable line recorded as %q", history[2].Line)
+	}
+}
+
+func TestHandlerHistoryRing(t *testing.T) {
+	h := NewHandlerWithIO(strings.NewReader(""), io.Discard)
+	for i := 0; i < historySize+10; i++ {
+		h.SendLog("info", fmt.Sprint(i))
+	}
+
+	history := h.History()
+	if len(history) != historySize {
+		t.Fatalf("history length = %d, want %d", len(history), historySize)
+	}
+	if !strings.Contains(history[0].Line, `"message":"10"`) || !strings.Contains(history[len(history)-1].Line, fmt.Sprintf(`"message":"%d"`, historySize+9)) {
+		t.Errorf("history kept %q ... %q", history[0].Line, history[len(history)-1].Line)
+	}
+}
+
+func TestHandlerSendFatalWithDump(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(""), &buf)
+
+	h.SendFatalWithDump(ReasonPanic, "panic: boom", "/tmp/dorker-crash.zip")
+	if !strings.Contains(buf.String(), `"crash_dump":"/tmp/dorker-crash.zip"`) {
+		t.Errorf("fatal message = %s", buf.String())
+	}
+}
+
+func TestParseEOFAction(t *testing.T) {
+	tests := map[string]EOFAction{"": EOFExit, "exit": EOFExit, "Drain": EOFDrain, "reattach": EOFReattach}
+	for name, want := range tests {
+		if got, err := ParseEOFAction(name); err != nil || got != want {
+			t.Errorf("ParseEOFAction(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseEOFAction("linger"); err == nil {
+		t.Error("ParseEOFAction should reject unknown actions")
+	}
+}
+
+func TestHandlerReattach(t *testing.T) {
+	listener, err := ListenController("unix:" + filepath.Join(t.TempDir(), "worker.sock"))
+	if err != nil {
+		t.Fatalf("ListenController: %v", err)
+	}
+
+	// Stdin is closed from the start
+	var stdout bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(""), &stdout)
+	h.ReattachOn(listener)
+	reattached := make(chan struct{})
+	h.OnReattach(func() { close(reattached) })
+
+	done := make(chan ShutdownReason, 1)
+	go func() { done <- h.Start() }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !h.Detached() {
+		if time.Now().After(deadline) {
+			t.Fatal("handler did not detach on EOF")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	h.SendStatus("progress", "sent while detached")
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	<-reattached
+
+	lines := bufio.NewScanner(conn)
+	for _, want := range []string{`"message":"1 held messages follow, 0 dropped"`, `"message":"sent while detached"`} {
+		if !lines.Scan() || !strings.Contains(lines.Text(), want) {
+			t.Fatalf("got %q, want a line containing %s", lines.Text(), want)
+		}
+	}
+
+	conn.Write([]byte(`{"type":"shutdown","ts":1}` + "\n"))
+	if !lines.Scan() || !strings.Contains(lines.Text(), `"status":"shutdown"`) {
+		t.Errorf("got %q after shutdown, want the shutdown status", lines.Text())
+	}
+	if reason := <-done; reason != ReasonShutdown {
+		t.Errorf("Start returned %q, want %q", reason, ReasonShutdown)
+	}
+
+	if out := stdout.String(); strings.Contains(out, "detached") {
+		t.Errorf("stdout got a message sent while detached: %s", out)
+	}
+}
+
+func TestHandlerSnapshotMessages(t *testing.T) {
+	input := `{"type":"export_snapshot","ts":1,"data":{"path":"/tmp/out.zip"}}
+{"type":"import_snapshot","ts":2,"data":{"path":"/tmp/in.zip"}}
+`
+	var buf bytes.Buffer
+	h := NewHandlerWithIO(strings.NewReader(input), &buf)
+
+	var exported, imported string
+	h.OnExportSnapshot(func(path string) { exported = path })
+	h.OnImportSnapshot(func(path string) { imported = path })
+
+	h.readMessage()
+	h.readMessage()
+
+	if exported != "/tmp/out.zip" || imported != "/tmp/in.zip" {
+		t.Errorf("exported %q, imported %q", exported, imported)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("snapshot messages should not be answered by the handler, got: %s", buf.String())
+	}
+}
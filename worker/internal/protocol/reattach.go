@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EOFAction is what the worker does when the controller closes stdin
+type EOFAction string
+
+const (
+	EOFExit     EOFAction = "exit"     // Stop at once, abandoning in-flight tasks
+	EOFDrain    EOFAction = "drain"    // Finish queued and in-flight tasks, then exit
+	EOFReattach EOFAction = "reattach" // Keep working and wait on a socket for a new controller
+)
+
+// ParseEOFAction parses an EOF action name; empty means EOFExit
+func ParseEOFAction(name string) (EOFAction, error) {
+	switch action := EOFAction(strings.ToLower(name)); action {
+	case "":
+		return EOFExit, nil
+	case EOFExit, EOFDrain, EOFReattach:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown EOF action %q (want exit, drain or reattach)", name)
+	}
+}
+
+// maxDetachedLines bounds the messages held for the next controller while
+// none is attached; the oldest are dropped beyond it
+const maxDetachedLines = 100000
+
+// DefaultReattachAddr returns the socket a worker waits on for a new
+// controller when no address is given: a Unix socket in the temp directory
+// named after the process ID
+func DefaultReattachAddr() string {
+	return "unix:" + filepath.Join(os.TempDir(), fmt.Sprintf("dorker-worker-%d.sock", os.Getpid()))
+}
+
+// ListenController listens for controllers on addr, either host:port for TCP
+// or unix:<path> for a Unix socket
+func ListenController(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// ReattachOn makes the handler wait for a new controller on listener when the
+// current one closes its input, instead of stopping. Messages sent while no
+// controller is attached are held and replayed to the next one. Stop closes
+// the listener.
+func (h *Handler) ReattachOn(listener net.Listener) {
+	h.listener = listener
+}
+
+// OnReattach sets the callback run after a new controller attached and the
+// held messages were replayed to it
+func (h *Handler) OnReattach(fn func()) {
+	h.onReattach = fn
+}
+
+// Detached reports whether the handler is waiting for a new controller
+func (h *Handler) Detached() bool {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	return h.detached
+}
+
+// detach starts holding outgoing messages and drops the connection of the
+// controller that went away
+func (h *Handler) detach() {
+	h.writeMu.Lock()
+	h.detached = true
+	conn := h.conn
+	h.conn = nil
+	h.writeMu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// attach waits for the next controller, announces the reattachment and
+// replays the held messages to it
+func (h *Handler) attach() {
+	conn, err := h.listener.Accept()
+	if err != nil {
+		// Closed by Stop, or unusable; either way no controller can return
+		h.StopWithReason(ReasonControllerEOF)
+		return
+	}
+
+	h.writeMu.Lock()
+	h.reader = bufio.NewReader(conn)
+	h.writer = conn
+	h.conn = conn
+	held, dropped := h.held, h.dropped
+	h.held, h.dropped, h.detached = nil, 0, false
+
+	status := NewMessage(MsgTypeStatus)
+	status.SetData("status", "reattached")
+	status.SetData("message", fmt.Sprintf("%d held messages follow, %d dropped", len(held), dropped))
+	if data, err := json.Marshal(status); err == nil {
+		h.history.record(DirectionOut, string(data))
+		held = append([]string{string(data)}, held...)
+	}
+	for _, line := range held {
+		if _, err := fmt.Fprintln(conn, line); err != nil {
+			break
+		}
+	}
+	h.writeMu.Unlock()
+
+	if h.onReattach != nil {
+		h.onReattach()
+	}
+}
+
+// hold keeps a line for the next controller (must hold writeMu)
+func (h *Handler) hold(line string) {
+	if len(h.held) >= maxDetachedLines {
+		h.held = h.held[1:]
+		h.dropped++
+	}
+	h.held = append(h.held, line)
+}
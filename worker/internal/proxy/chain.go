@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+
+	"dorker/worker/internal/tunnel"
+)
+
+// chainSeparator joins the hops of a proxy chain in a proxy line
+const chainSeparator = ">"
+
+// chainPattern matches the separator with the blanks some lists put
+// around it, which would otherwise start the line's comment
+var chainPattern = regexp.MustCompile(`\s*>\s*`)
+
+// DialFunc opens a connection, as net.Dialer.DialContext does
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// chain makes via the first hop of p. The first hop must be a proxy that
+// can tunnel: http, https or socks5.
+func (p *Proxy) chain(via *Proxy) error {
+	switch via.Type {
+	case ProxyTypeHTTP, ProxyTypeHTTPS, ProxyTypeSOCKS5:
+	default:
+		return fmt.Errorf("first hop %s: cannot tunnel through a %s proxy", via, via.Type)
+	}
+	p.Via = via
+	p.ID = generateProxyID(p)
+	return nil
+}
+
+// Dialer returns the function opening connections to the proxy, for
+// http.Transport.DialContext. It is dial itself, except for a chain: there
+// dial reaches the first hop, and a tunnel through it the proxy.
+func (p *Proxy) Dialer(dial DialFunc) DialFunc {
+	if dial == nil {
+		var direct net.Dialer
+		dial = direct.DialContext
+	}
+	if p.Via == nil {
+		return dial
+	}
+
+	via := p.Via
+	viaURL := &url.URL{Scheme: string(via.Type), Host: net.JoinHostPort(via.Host, via.Port)}
+	if via.Username != "" {
+		viaURL.User = url.UserPassword(via.Username, via.Password)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, viaURL.Host)
+		if err != nil {
+			return nil, fmt.Errorf("first hop %s: %w", via, err)
+		}
+		tunneled, err := tunnel.Open(ctx, conn, viaURL, addr)
+		if err != nil {
+			return nil, fmt.Errorf("first hop %s: %w", via, err)
+		}
+		return tunneled, nil
+	}
+}
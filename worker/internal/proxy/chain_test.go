@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// connectHop is a CONNECT-only first hop counting the tunnels it opened
+func connectHop(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	tunnels := new(atomic.Int32)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil || req.Method != http.MethodConnect {
+				conn.Close()
+				continue
+			}
+			upstream, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+				conn.Close()
+				continue
+			}
+			tunnels.Add(1)
+			io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			go func() {
+				defer conn.Close()
+				defer upstream.Close()
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener.Addr().String(), tunnels
+}
+
+func TestChainDialer(t *testing.T) {
+	// The exit proxy answers every request itself, naming what it was asked
+	exit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "exit "+r.URL.String())
+	}))
+	defer exit.Close()
+	hop, tunnels := connectHop(t)
+
+	p, err := NewParser().ParseLine("http://" + hop + ">" + "http://" + exit.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL, _ := url.Parse(p.URL())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:       http.ProxyURL(proxyURL),
+		DialContext: p.Dialer(nil),
+	}}
+
+	resp, err := client.Get("http://example.com/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "exit http://example.com/page" {
+		t.Errorf("body = %q", body)
+	}
+	if n := tunnels.Load(); n != 1 {
+		t.Errorf("first hop opened %d tunnels, want 1", n)
+	}
+
+	// A proxy without a first hop dials as given
+	direct, _ := NewParser().ParseLine(exit.Listener.Addr().String())
+	conn, err := direct.Dialer(nil)(context.Background(), "tcp", exit.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,284 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"dorker/worker/internal/egress"
+)
+
+// Anonymity levels reported by the checker
+const (
+	AnonymityTransparent = "transparent" // Client IP is forwarded to the target
+	AnonymityAnonymous   = "anonymous"   // Proxy identifies itself but hides the client
+	AnonymityElite       = "elite"       // No proxy headers reach the target
+	AnonymityUnknown     = "unknown"
+)
+
+// CheckerConfig holds configuration for on-demand proxy checks
+type CheckerConfig struct {
+	JudgeURL    string        `json:"judge_url"`   // Echoes request headers back as JSON
+	GeoURL      string        `json:"geo_url"`     // Returns the exit country as JSON
+	Timeout     time.Duration `json:"timeout"`     // Per-request timeout
+	Concurrency int           `json:"concurrency"` // Proxies checked in parallel
+}
+
+// DefaultCheckerConfig returns sensible defaults
+func DefaultCheckerConfig() CheckerConfig {
+	return CheckerConfig{
+		JudgeURL:    "http://httpbin.org/get",
+		GeoURL:      "http://ip-api.com/json/?fields=countryCode",
+		Timeout:     10 * time.Second,
+		Concurrency: 50,
+	}
+}
+
+// CheckResult holds the outcome of checking a single proxy
+type CheckResult struct {
+	ProxyID   string        `json:"proxy_id"`
+	Alive     bool          `json:"alive"`
+	Latency   time.Duration `json:"latency"`
+	Anonymity string        `json:"anonymity"`
+	Country   string        `json:"country,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Checker performs active health checks against proxies
+type Checker struct {
+	config CheckerConfig
+	egress *egress.Dialer // Egress proxy in front of the checked proxies; nil for none
+
+	ipMu   sync.Mutex
+	realIP string // Our own address as the judge sees it, once looked up
+}
+
+// NewChecker creates a new proxy checker
+func NewChecker(config CheckerConfig) *Checker {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return &Checker{config: config}
+}
+
+// SetEgress connects to the checked proxies through an egress proxy
+func (c *Checker) SetEgress(d *egress.Dialer) {
+	c.egress = d
+}
+
+// Check tests a single proxy for connectivity, anonymity and exit country
+func (c *Checker) Check(ctx context.Context, p *Proxy) *CheckResult {
+	result := &CheckResult{
+		ProxyID:   p.ID,
+		Anonymity: AnonymityUnknown,
+	}
+
+	client, err := c.newClient(p)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	body, err := c.get(ctx, client, c.config.JudgeURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Latency = time.Since(start)
+	result.Alive = true
+	result.Anonymity = classifyAnonymity(body, c.clientIP(ctx))
+
+	if c.config.GeoURL != "" {
+		if geo, err := c.get(ctx, client, c.config.GeoURL); err == nil {
+			result.Country = parseCountry(geo)
+		}
+	}
+
+	p.mu.Lock()
+	p.Anonymity = result.Anonymity
+	if result.Country != "" {
+		p.Country = result.Country
+	}
+	p.mu.Unlock()
+
+	return result
+}
+
+// CheckAll checks proxies concurrently, invoking onResult as each one finishes.
+// Calls to onResult are serialized.
+func (c *Checker) CheckAll(ctx context.Context, proxies []*Proxy, onResult func(*CheckResult)) {
+	var resultMu sync.Mutex
+	c.each(ctx, proxies, func(p *Proxy) {
+		result := c.Check(ctx, p)
+
+		resultMu.Lock()
+		defer resultMu.Unlock()
+		onResult(result)
+	})
+}
+
+// each runs fn for every proxy, Concurrency at a time, and returns once all
+// started calls finish. It starts no more calls after ctx is done.
+func (c *Checker) each(ctx context.Context, proxies []*Proxy, fn func(*Proxy)) {
+	sem := make(chan struct{}, c.config.Concurrency)
+	var wg sync.WaitGroup
+
+	for _, p := range proxies {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(p *Proxy) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(p)
+		}(p)
+	}
+
+	wg.Wait()
+}
+
+// newClient creates an HTTP client routed through the proxy
+func (c *Checker) newClient(p *Proxy) (*http.Client, error) {
+	// The parse error would echo the password, so report the redacted form
+	proxyURL, err := url.Parse(p.URL())
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", p)
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyURL(proxyURL),
+			DialContext:         p.Dialer(c.egress.DialContext),
+			TLSHandshakeTimeout: c.config.Timeout,
+			DisableKeepAlives:   true,
+		},
+		Timeout: c.config.Timeout,
+	}, nil
+}
+
+// get fetches a URL and returns the body, failing on non-200 responses
+func (c *Checker) get(ctx context.Context, client *http.Client, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
+
+// clientIP asks the judge, without a proxy, for the address our requests
+// come from. A successful lookup is kept for later checks; "" when the
+// judge could not be reached or does not report it.
+func (c *Checker) clientIP(ctx context.Context) string {
+	c.ipMu.Lock()
+	defer c.ipMu.Unlock()
+
+	if c.realIP != "" {
+		return c.realIP
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       c.egress.DialContext,
+			DisableKeepAlives: true,
+		},
+		Timeout: c.config.Timeout,
+	}
+	body, err := c.get(ctx, client, c.config.JudgeURL)
+	if err != nil {
+		return ""
+	}
+
+	var judge struct {
+		Origin string `json:"origin"`
+	}
+	if err := json.Unmarshal(body, &judge); err != nil {
+		return ""
+	}
+	origin, _, _ := strings.Cut(judge.Origin, ",")
+	c.realIP = strings.TrimSpace(origin)
+	return c.realIP
+}
+
+// classifyAnonymity inspects the headers echoed by the judge. A proxy is
+// transparent only when a forwarding header carries realIP; forwarding
+// headers with any other address, such as the proxy's own or a private
+// one, only give the proxy away.
+func classifyAnonymity(body []byte, realIP string) string {
+	var judge struct {
+		Headers map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal(body, &judge); err != nil || judge.Headers == nil {
+		return AnonymityUnknown
+	}
+
+	level := AnonymityElite
+	for name, value := range judge.Headers {
+		switch strings.ToLower(name) {
+		case "x-forwarded-for", "x-real-ip", "client-ip", "forwarded":
+			if realIP != "" && containsIP(value, realIP) {
+				return AnonymityTransparent
+			}
+			level = AnonymityAnonymous
+		case "via", "proxy-connection", "x-proxy-id":
+			level = AnonymityAnonymous
+		}
+	}
+	return level
+}
+
+// containsIP reports whether a forwarding header lists ip, matching whole
+// addresses so 1.2.3.4 is not found in 11.2.3.45. It reads plain lists
+// ("1.2.3.4, 10.0.0.1") and Forwarded pairs (for="[2001:db8::1]:4711").
+func containsIP(value, ip string) bool {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == '=' || r == '"' || r == ' '
+	})
+	for _, field := range fields {
+		if host, _, err := net.SplitHostPort(field); err == nil {
+			field = host
+		}
+		if strings.Trim(field, "[]") == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCountry extracts a country code from a geo lookup response
+func parseCountry(body []byte) string {
+	var geo struct {
+		CountryCode string `json:"countryCode"`
+		Country     string `json:"country"`
+	}
+	if err := json.Unmarshal(body, &geo); err != nil {
+		return ""
+	}
+	if geo.CountryCode != "" {
+		return geo.CountryCode
+	}
+	return geo.Country
+}
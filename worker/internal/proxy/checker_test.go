@@ -0,0 +1,323 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newJudgeProxy starts a server that answers proxied judge and geo requests
+func newJudgeProxy(t *testing.T, judgeHeaders string) (*httptest.Server, *Proxy) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Host {
+		case "judge.test":
+			w.Write([]byte(`{"headers":` + judgeHeaders + `}`))
+		case "geo.test":
+			w.Write([]byte(`{"countryCode":"DE"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+
+	return server, &Proxy{ID: "judge_proxy", Host: host, Port: port, Type: ProxyTypeHTTP}
+}
+
+func testCheckerConfig() CheckerConfig {
+	return CheckerConfig{
+		JudgeURL:    "http://judge.test/get",
+		GeoURL:      "http://geo.test/json",
+		Timeout:     2 * time.Second,
+		Concurrency: 4,
+	}
+}
+
+func TestCheckerCheck(t *testing.T) {
+	server, prx := newJudgeProxy(t, `{"Host":"judge.test","User-Agent":"Go"}`)
+	defer server.Close()
+
+	checker := NewChecker(testCheckerConfig())
+	result := checker.Check(context.Background(), prx)
+
+	if !result.Alive {
+		t.Fatalf("proxy should be alive, error: %s", result.Error)
+	}
+
+	if result.Anonymity != AnonymityElite {
+		t.Errorf("Anonymity = %q, want %q", result.Anonymity, AnonymityElite)
+	}
+
+	if result.Country != "DE" {
+		t.Errorf("Country = %q, want DE", result.Country)
+	}
+
+	if prx.Country != "DE" || prx.Anonymity != AnonymityElite {
+		t.Errorf("proxy metadata not recorded: country=%q anonymity=%q", prx.Country, prx.Anonymity)
+	}
+}
+
+func TestCheckerCheckDead(t *testing.T) {
+	checker := NewChecker(testCheckerConfig())
+	prx := &Proxy{ID: "dead", Host: "127.0.0.1", Port: "1", Type: ProxyTypeHTTP}
+
+	result := checker.Check(context.Background(), prx)
+
+	if result.Alive {
+		t.Error("unreachable proxy should not be alive")
+	}
+
+	if result.Error == "" {
+		t.Error("Error should be set for dead proxy")
+	}
+}
+
+func TestClassifyAnonymity(t *testing.T) {
+	const realIP = "1.2.3.4"
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"headers":{"Host":"x"}}`, AnonymityElite},
+		{`{"headers":{"Via":"1.1 squid"}}`, AnonymityAnonymous},
+		{`{"headers":{"X-Forwarded-For":"1.2.3.4","Via":"1.1 squid"}}`, AnonymityTransparent},
+		{`{"headers":{"X-Forwarded-For":"10.0.0.7, 1.2.3.4"}}`, AnonymityTransparent},
+		{`{"headers":{"Forwarded":"for=\"1.2.3.4:4711\";proto=http"}}`, AnonymityTransparent},
+		// Forwarding headers that don't carry our address only give the proxy away
+		{`{"headers":{"X-Forwarded-For":"203.0.113.9"}}`, AnonymityAnonymous},
+		{`{"headers":{"X-Real-Ip":"11.2.3.45"}}`, AnonymityAnonymous},
+		{`not json`, AnonymityUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := classifyAnonymity([]byte(tt.body), realIP); got != tt.want {
+			t.Errorf("classifyAnonymity(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+
+	// Without our own address nothing can be shown to leak
+	if got := classifyAnonymity([]byte(`{"headers":{"X-Forwarded-For":"1.2.3.4"}}`), ""); got != AnonymityAnonymous {
+		t.Errorf("classifyAnonymity without real IP = %q, want %q", got, AnonymityAnonymous)
+	}
+}
+
+func TestCheckerCheckRealIP(t *testing.T) {
+	tests := []struct {
+		forwarded string
+		want      string
+	}{
+		{"127.0.0.1", AnonymityTransparent},
+		{"198.51.100.1", AnonymityAnonymous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			// The server is both the judge, asked directly for our address,
+			// and the proxy, which adds a forwarding header to what it echoes
+			var direct int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasPrefix(r.RequestURI, "http://") {
+					direct++
+					w.Write([]byte(`{"origin":"127.0.0.1","headers":{}}`))
+					return
+				}
+				w.Write([]byte(`{"origin":"` + tt.forwarded + `","headers":{"X-Forwarded-For":"` + tt.forwarded + `"}}`))
+			}))
+			defer server.Close()
+
+			host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+			if err != nil {
+				t.Fatalf("failed to split server address: %v", err)
+			}
+			prx := &Proxy{ID: "judge_proxy", Host: host, Port: port, Type: ProxyTypeHTTP}
+
+			config := testCheckerConfig()
+			config.JudgeURL = server.URL + "/get"
+			config.GeoURL = ""
+			checker := NewChecker(config)
+
+			for i := 0; i < 2; i++ {
+				if result := checker.Check(context.Background(), prx); result.Anonymity != tt.want {
+					t.Errorf("Anonymity = %q, want %q (error %q)", result.Anonymity, tt.want, result.Error)
+				}
+			}
+			if direct != 1 {
+				t.Errorf("real IP looked up %d times, want once", direct)
+			}
+		})
+	}
+}
+
+func TestCheckerCheckAll(t *testing.T) {
+	server, prx := newJudgeProxy(t, `{"Host":"judge.test"}`)
+	defer server.Close()
+
+	proxies := []*Proxy{
+		prx,
+		{ID: "dead", Host: "127.0.0.1", Port: "1", Type: ProxyTypeHTTP},
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]*CheckResult)
+
+	checker := NewChecker(testCheckerConfig())
+	checker.CheckAll(context.Background(), proxies, func(r *CheckResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[r.ProxyID] = r
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	if !results["judge_proxy"].Alive {
+		t.Error("judge_proxy should be alive")
+	}
+
+	if results["dead"].Alive {
+		t.Error("dead proxy should not be alive")
+	}
+}
+
+// newEngineProxy starts a server that answers proxied pre-flight requests
+// with the given status and Location
+func newEngineProxy(t *testing.T, status int, location string) *Proxy {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if location != "" {
+			w.Header().Set("Location", location)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+	return &Proxy{ID: "engine_" + port, Host: host, Port: port, Type: ProxyTypeHTTP}
+}
+
+func TestCheckerPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		location string
+		want     string
+	}{
+		{"no content", http.StatusNoContent, "", PreflightPassed},
+		{"sorry redirect", http.StatusFound, "https://www.google.com/sorry/index?continue=x", PreflightBanned},
+		{"too many requests", http.StatusTooManyRequests, "", PreflightBanned},
+		{"proxy auth", http.StatusProxyAuthRequired, "", PreflightFailed},
+	}
+
+	checker := NewChecker(testCheckerConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prx := newEngineProxy(t, tt.status, tt.location)
+			result := checker.Preflight(context.Background(), prx, "http://google.test/generate_204")
+			if result.Outcome != tt.want {
+				t.Errorf("Outcome = %q, want %q (error %q)", result.Outcome, tt.want, result.Error)
+			}
+		})
+	}
+}
+
+func TestPoolPreflight(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.PreflightURL = "http://google.test/generate_204"
+	pool := NewPool(config)
+
+	good := newEngineProxy(t, http.StatusNoContent, "")
+	banned := newEngineProxy(t, http.StatusFound, "/sorry/index")
+	dead := &Proxy{ID: "dead", Host: "127.0.0.1", Port: "1", Type: ProxyTypeHTTP}
+	pool.AddProxies([]*Proxy{good, banned, dead})
+
+	var results []*PreflightResult
+	summary := pool.Preflight(context.Background(), NewChecker(testCheckerConfig()), func(r *PreflightResult) {
+		results = append(results, r)
+	})
+
+	if summary != (PreflightSummary{Passed: 1, Banned: 1, Failed: 1}) {
+		t.Errorf("summary = %+v", summary)
+	}
+	if len(results) != 3 {
+		t.Errorf("onResult called %d times, want 3", len(results))
+	}
+	quarantined := pool.GetAllQuarantined()
+	if len(quarantined) != 1 || quarantined[0].ID != banned.ID {
+		t.Errorf("quarantined = %v, want only the banned proxy", quarantined)
+	}
+
+	// Without a pre-flight URL nothing is checked
+	if summary := NewPool(DefaultPoolConfig()).Preflight(context.Background(), NewChecker(testCheckerConfig()), nil); summary != (PreflightSummary{}) {
+		t.Errorf("summary without URL = %+v", summary)
+	}
+}
+
+func TestPoolRecheck(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.QuarantineDuration = time.Minute
+	config.RecheckURL = "http://google.test/generate_204"
+	pool := NewPool(config)
+	pool.checker = NewChecker(testCheckerConfig())
+
+	good := newEngineProxy(t, http.StatusNoContent, "")
+	banned := newEngineProxy(t, http.StatusFound, "/sorry/index")
+	pool.AddProxies([]*Proxy{good, banned})
+	pool.ReportBlock(good.ID)
+	pool.ReportBlock(banned.ID)
+
+	// Nothing is re-checked before its quarantine is up
+	pool.performHealthCheck(context.Background())
+	if stats := pool.Stats(); stats.Quarantined != 2 {
+		t.Fatalf("quarantined = %d before expiry, want 2", stats.Quarantined)
+	}
+
+	expire := func() {
+		for _, prx := range pool.GetAllQuarantined() {
+			prx.SetCooldown(-time.Second)
+		}
+	}
+
+	// Only the proxy that passes is released; the banned one's quarantine
+	// doubles with each failed re-check
+	for i, want := range []time.Duration{2 * time.Minute, 4 * time.Minute} {
+		expire()
+		pool.performHealthCheck(context.Background())
+
+		if good.Status != ProxyStatusAlive || pool.Stats().Alive != 1 {
+			t.Errorf("re-check %d: passing proxy not back in rotation", i+1)
+		}
+		quarantined := pool.GetAllQuarantined()
+		if len(quarantined) != 1 || quarantined[0] != banned {
+			t.Fatalf("re-check %d: quarantined = %v, want only the banned proxy", i+1, quarantined)
+		}
+		if left := time.Until(banned.CooldownUntil); left < want-time.Second || left > want {
+			t.Errorf("re-check %d: quarantine left = %v, want %v", i+1, left, want)
+		}
+	}
+
+	// Without a re-check URL, release is on the timer alone
+	plain := NewPool(DefaultPoolConfig())
+	prx := newEngineProxy(t, http.StatusFound, "/sorry/index")
+	plain.AddProxy(prx)
+	plain.ReportBlock(prx.ID)
+	prx.SetCooldown(-time.Second)
+	plain.performHealthCheck(context.Background())
+	if stats := plain.Stats(); stats.Alive != 1 {
+		t.Errorf("alive = %d without re-check, want 1", stats.Alive)
+	}
+}
@@ -0,0 +1,566 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListFormat is the format of a proxy list
+type ListFormat string
+
+const (
+	FormatText ListFormat = "text" // One proxy per line, in any ParseLine format
+	FormatJSON ListFormat = "json" // An array of objects or of proxy lines
+	FormatCSV  ListFormat = "csv"  // Rows of host, port, username, password, type
+)
+
+// utf8BOM starts files saved by some spreadsheet and export tools
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// DetectFormat tells the format of a proxy list from its file extension,
+// falling back to its content: JSON starts with [ or {, and CSV has a
+// comma or semicolon on its first entry, which no line format does
+func DetectFormat(name string, data []byte) ListFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	}
+
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return FormatJSON
+	}
+	if strings.ContainsAny(firstEntry(data), ",;") {
+		return FormatCSV
+	}
+	return FormatText
+}
+
+// Record is one proxy of a structured list, field by field
+type Record struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Type     string
+	Via      string            // First hop of a chain, as a proxy line
+	Metadata map[string]string // Any other fields, kept as the proxy's metadata
+}
+
+// recordColumns maps the field names providers use to Record fields, for
+// JSON keys and CSV headers alike
+var recordColumns = map[string]string{
+	"host":     "host",
+	"ip":       "host",
+	"address":  "host",
+	"addr":     "host",
+	"server":   "host",
+	"hostname": "host",
+	"port":     "port",
+	"username": "username",
+	"user":     "username",
+	"login":    "username",
+	"password": "password",
+	"pass":     "password",
+	"type":     "type",
+	"protocol": "type",
+	"scheme":   "type",
+	"via":      "via",
+	"proxy":    "line",
+	"url":      "line",
+}
+
+// ParseRecord builds a proxy from a structured record. The host and port
+// are held to the same rules as in a proxy line, and credentials may
+// reference the environment the same way.
+func (p *Parser) ParseRecord(record Record) (*Proxy, error) {
+	host := strings.TrimSpace(record.Host)
+	port := strings.TrimSpace(record.Port)
+
+	// Some exports give the address as host:port
+	if port == "" {
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host, port = host[:i], host[i+1:]
+		}
+	}
+	if host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	if port == "" {
+		return nil, fmt.Errorf("missing port for %s", host)
+	}
+
+	address := host + ":" + port
+	if !p.patterns["ip_port"].MatchString(address) && !p.patterns["host_port"].MatchString(address) {
+		return nil, fmt.Errorf("invalid proxy address: %s", address)
+	}
+
+	proxy := &Proxy{
+		Host:   host,
+		Port:   port,
+		Status: ProxyStatusUnknown,
+		Type:   ProxyTypeHTTP,
+	}
+
+	if kind := strings.ToLower(strings.TrimSpace(record.Type)); kind != "" {
+		if !p.patterns["proto"].MatchString(kind) {
+			return nil, fmt.Errorf("unsupported proxy type %q for %s", record.Type, address)
+		}
+		proxy.Type = parseProxyType(kind)
+	}
+
+	username, err := ExpandEnv(record.Username)
+	if err != nil {
+		return nil, err
+	}
+	password, err := ExpandEnv(record.Password)
+	if err != nil {
+		return nil, err
+	}
+	if (username == "") != (password == "") {
+		return nil, fmt.Errorf("%s needs both a username and a password", address)
+	}
+	proxy.Username = username
+	proxy.Password = password
+	if username != record.Username || password != record.Password {
+		proxy.rawUsername = record.Username
+		proxy.rawPassword = record.Password
+	}
+
+	if _, err := ParseHostOverrides(record.Metadata[hostsMetadataKey]); err != nil {
+		return nil, fmt.Errorf("%s: %w", address, err)
+	}
+	if len(record.Metadata) > 0 {
+		proxy.Metadata = make(map[string]string, len(record.Metadata))
+		for key, value := range record.Metadata {
+			proxy.Metadata[key] = value
+		}
+	}
+
+	if line := strings.TrimSpace(record.Via); line != "" {
+		via, err := p.parseHop(line)
+		if err != nil {
+			return nil, fmt.Errorf("first hop: %w", err)
+		}
+		if err := proxy.chain(via); err != nil {
+			return nil, err
+		}
+	}
+
+	proxy.ID = generateProxyID(proxy)
+	return proxy, nil
+}
+
+// parseFields parses a record given as named fields: a JSON object or a CSV
+// row under a header. A "proxy" or "url" field holds a whole proxy line.
+// Fields with other names are added to metadata, which becomes the proxy's.
+func (p *Parser) parseFields(fields, metadata map[string]string) (*Proxy, error) {
+	record := Record{Metadata: metadata}
+	var line string
+	for name, value := range fields {
+		switch recordColumns[strings.ToLower(strings.TrimSpace(name))] {
+		case "host":
+			record.Host = value
+		case "port":
+			record.Port = value
+		case "username":
+			record.Username = value
+		case "password":
+			record.Password = value
+		case "type":
+			record.Type = value
+		case "via":
+			record.Via = value
+		case "line":
+			line = value
+		default:
+			if value != "" {
+				if record.Metadata == nil {
+					record.Metadata = make(map[string]string)
+				}
+				record.Metadata[name] = value
+			}
+		}
+	}
+
+	if record.Host == "" && line != "" {
+		proxy, err := p.ParseLine(line)
+		if err == nil && proxy == nil {
+			err = fmt.Errorf("empty proxy line")
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range record.Metadata {
+			if proxy.Metadata == nil {
+				proxy.Metadata = make(map[string]string)
+			}
+			proxy.Metadata[key] = value
+		}
+		return proxy, nil
+	}
+	return p.ParseRecord(record)
+}
+
+// parseJSON parses a JSON list: an array of objects or of proxy lines, or
+// an object holding such an array under "proxies" or "data"
+func (p *Parser) parseJSON(data []byte) ([]*Proxy, []error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var wrapper struct {
+			Proxies []json.RawMessage `json:"proxies"`
+			Data    []json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(data, &wrapper) != nil {
+			return nil, []error{fmt.Errorf("invalid JSON proxy list: %w", err)}
+		}
+		entries = wrapper.Proxies
+		if entries == nil {
+			entries = wrapper.Data
+		}
+	}
+
+	var proxies []*Proxy
+	var errs []error
+	for i, raw := range entries {
+		proxy, err := p.parseJSONEntry(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", i+1, err))
+			continue
+		}
+		if proxy != nil {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies, errs
+}
+
+func (p *Parser) parseJSONEntry(raw json.RawMessage) (*Proxy, error) {
+	var line string
+	if json.Unmarshal(raw, &line) == nil {
+		return p.ParseLine(line)
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("want an object or a proxy line")
+	}
+
+	// Metadata comes flat or, as WriteList writes it, under "metadata"
+	fields := make(map[string]string, len(object))
+	var metadata map[string]string
+	for name, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok && strings.EqualFold(name, "metadata") {
+			metadata = make(map[string]string, len(nested))
+			for key, value := range nested {
+				if value, ok := jsonScalar(value); ok {
+					metadata[key] = value
+				}
+			}
+			continue
+		}
+		if value, ok := jsonScalar(value); ok {
+			fields[name] = value
+		}
+	}
+	return p.parseFields(fields, metadata)
+}
+
+// jsonScalar returns a decoded JSON string, number or boolean as a string
+func jsonScalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		// Ports are often numbers
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// parseCSV parses a CSV list, comma or semicolon separated. Under a header
+// row naming the columns they may come in any order; without one they are
+// host, port, username, password and type, or a single column of proxy
+// lines.
+func (p *Parser) parseCSV(data []byte) ([]*Proxy, []error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if first := firstEntry(data); strings.Contains(first, ";") && !strings.Contains(first, ",") {
+		reader.Comma = ';'
+	}
+
+	var proxies []*Proxy
+	var errs []error
+	var header []string
+	for row := 0; ; row++ {
+		cells, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err) // A csv.ParseError names its line
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		if row == 0 && isHeader(cells) {
+			header = cells
+			continue
+		}
+		if len(cells) == 1 && strings.TrimSpace(cells[0]) == "" {
+			continue
+		}
+
+		var proxy *Proxy
+		switch {
+		case header != nil:
+			fields := make(map[string]string, len(header))
+			for i, name := range header {
+				if i < len(cells) {
+					fields[name] = cells[i]
+				}
+			}
+			proxy, err = p.parseFields(fields, nil)
+		case len(cells) == 1:
+			proxy, err = p.ParseLine(cells[0])
+		default:
+			var record Record
+			for i, field := range []*string{&record.Host, &record.Port, &record.Username, &record.Password, &record.Type} {
+				if i < len(cells) {
+					*field = cells[i]
+				}
+			}
+			proxy, err = p.ParseRecord(record)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		if proxy != nil {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies, errs
+}
+
+// isHeader reports whether a CSV row names columns rather than holding a
+// proxy
+func isHeader(cells []string) bool {
+	for _, cell := range cells {
+		if _, ok := recordColumns[strings.ToLower(strings.TrimSpace(cell))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// firstEntry returns the first line of data that is neither blank nor a
+// comment
+func firstEntry(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// parseMetadata parses a proxy line's comment into metadata. key=value
+// pairs become entries, with "quoted" values for ones with blanks; other
+// words are joined under "comment".
+func parseMetadata(comment string) map[string]string {
+	metadata := make(map[string]string)
+	var words []string
+
+	rest := strings.TrimSpace(comment)
+	for rest != "" {
+		token := rest
+		if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			token = rest[:i]
+		}
+
+		if eq := strings.Index(token, "="); eq > 0 {
+			value := token[eq+1:]
+			if quoted, err := strconv.QuotedPrefix(rest[eq+1:]); err == nil {
+				value, _ = strconv.Unquote(quoted)
+				token = rest[:eq+1+len(quoted)]
+			}
+			metadata[token[:eq]] = value
+		} else {
+			words = append(words, token)
+		}
+		rest = strings.TrimSpace(rest[len(token):])
+	}
+
+	if len(words) > 0 {
+		metadata["comment"] = strings.TrimSpace(metadata["comment"] + " " + strings.Join(words, " "))
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// formatMetadata writes metadata as a proxy line comment parseMetadata
+// reads back, keys in order
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for _, key := range sortedKeys(metadata) {
+		value := metadata[key]
+		if value == "" || strings.ContainsAny(value, " \t\"") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// listEntry is a proxy as WriteList writes it
+type listEntry struct {
+	Type     ProxyType         `json:"type"`
+	Host     string            `json:"host"`
+	Port     string            `json:"port"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Via      string            `json:"via,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (p *Proxy) listEntry() listEntry {
+	entry := listEntry{
+		Type:     p.Type,
+		Host:     p.Host,
+		Port:     p.Port,
+		Username: p.Username,
+		Password: p.Password,
+		Metadata: p.metadata(),
+	}
+	if p.rawUsername != "" || p.rawPassword != "" {
+		entry.Username, entry.Password = p.rawUsername, p.rawPassword
+	}
+	if p.Via != nil {
+		entry.Via = p.Via.listEntry().line()
+	}
+	return entry
+}
+
+// line returns the entry as a proxy line, without its first hop or
+// metadata
+func (e listEntry) line() string {
+	line := string(e.Type) + "://"
+	if e.Username != "" {
+		line += e.Username + ":" + e.Password + "@"
+	}
+	return line + e.Host + ":" + e.Port
+}
+
+// WriteList writes proxies as a list in format that ParseFile loads back
+// as it was: credentials as the loaded list gave them, so ${NAME}
+// references stay references, and metadata as a line comment, a
+// "metadata" object or extra CSV columns. Proxies a provider created are
+// left out.
+func WriteList(w io.Writer, proxies []*Proxy, format ListFormat) error {
+	entries := make([]listEntry, 0, len(proxies))
+	for _, proxy := range proxies {
+		if proxy.Provider == "" {
+			entries = append(entries, proxy.listEntry())
+		}
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+
+	case FormatCSV:
+		columns := make(map[string]string)
+		chained := false
+		for _, entry := range entries {
+			for key := range entry.Metadata {
+				columns[key] = ""
+			}
+			chained = chained || entry.Via != ""
+		}
+		keys := sortedKeys(columns)
+
+		// The via column is only written for lists with chains
+		header := []string{"type", "host", "port", "username", "password"}
+		if chained {
+			header = append(header, "via")
+		}
+
+		cw := csv.NewWriter(w)
+		cw.Write(append(header, keys...))
+		for _, entry := range entries {
+			row := []string{string(entry.Type), entry.Host, entry.Port, entry.Username, entry.Password}
+			if chained {
+				row = append(row, entry.Via)
+			}
+			for _, key := range keys {
+				row = append(row, entry.Metadata[key])
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+
+	default:
+		for _, entry := range entries {
+			line := entry.line()
+			if entry.Via != "" {
+				line = entry.Via + chainSeparator + line
+			}
+			if comment := formatMetadata(entry.Metadata); comment != "" {
+				line += " # " + comment
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ExportList writes proxies to path, sorted by ID, in the format its
+// extension names: .json, .csv, or text otherwise
+func ExportList(path string, proxies []*Proxy) error {
+	sorted := append([]*Proxy(nil), proxies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy export: %w", err)
+	}
+	if err := WriteList(f, sorted, DetectFormat(path, nil)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write proxy export: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"dorker/worker/internal/tunnel"
+)
+
+// hostsMetadataKey is the metadata key of a proxy's own host overrides
+const hostsMetadataKey = "hosts"
+
+// Hosts pins host names to addresses, as a hosts file does, for the
+// connections made through proxies. A name with several addresses gets
+// one at random per connection.
+type Hosts map[string][]string
+
+// ParseHostOverrides parses overrides written host=ip[,ip...], separated by
+// blanks or semicolons:
+//
+//	www.google.com=142.250.74.36,142.250.74.68; www.bing.com=204.79.197.200
+func ParseHostOverrides(s string) (Hosts, error) {
+	hosts := make(Hosts)
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ' ' || r == '\t' }) {
+		name, list, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || list == "" {
+			return nil, fmt.Errorf("invalid host override %q: want host=ip[,ip]", entry)
+		}
+		for _, ip := range strings.Split(list, ",") {
+			if err := hosts.add(name, ip); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// ReadHostsFile reads a file in hosts file format: an address followed by
+// the names pinned to it, with # comments
+func ReadHostsFile(path string) (Hosts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hosts := make(Hosts)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("%s line %d: address without a host name", path, lineNum)
+		}
+		for _, name := range fields[1:] {
+			if err := hosts.add(name, fields[0]); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, lineNum, err)
+			}
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// add pins a name to one more address
+func (h Hosts) add(name, ip string) error {
+	ip = strings.TrimSpace(ip)
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid address %q for %s", ip, name)
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	h[name] = append(h[name], ip)
+	return nil
+}
+
+// Merge returns the overrides of h and other, other's winning for a name
+// both pin
+func (h Hosts) Merge(other Hosts) Hosts {
+	if len(other) == 0 {
+		return h
+	}
+	if len(h) == 0 {
+		return other
+	}
+	merged := make(Hosts, len(h)+len(other))
+	for name, ips := range h {
+		merged[name] = ips
+	}
+	for name, ips := range other {
+		merged[name] = ips
+	}
+	return merged
+}
+
+// Lookup returns an address a name is pinned to
+func (h Hosts) Lookup(name string) (string, bool) {
+	ips := h[strings.ToLower(strings.TrimSuffix(name, "."))]
+	if len(ips) == 0 {
+		return "", false
+	}
+	return ips[rand.Intn(len(ips))], true
+}
+
+// Hosts returns the proxy's own host overrides, from the hosts key of its
+// metadata:
+//
+//	1.2.3.4:8080 # hosts="www.google.com=142.250.74.36"
+//
+// The parser refuses a line with malformed overrides.
+func (p *Proxy) Hosts() Hosts {
+	hosts, err := ParseHostOverrides(p.metadata()[hostsMetadataKey])
+	if err != nil {
+		return nil
+	}
+	return hosts
+}
+
+// Route returns the Proxy and DialContext functions of an http.Transport
+// going through the proxy at proxyURL, dialing it with dial as Dialer
+// does. Without host overrides that is the transport's own proxying. With
+// them, a request to a pinned host skips it: the connection is tunneled
+// through the proxy to the pinned address, so the proxy never resolves the
+// name, and TLS still names the host. The proxy's overrides win over
+// hosts. A socks4 proxy cannot carry an address chosen this way and is
+// used without overrides.
+func (p *Proxy) Route(proxyURL *url.URL, dial DialFunc, hosts Hosts) (func(*http.Request) (*url.URL, error), DialFunc) {
+	proxyDial := p.Dialer(dial)
+	hosts = hosts.Merge(p.Hosts())
+	if len(hosts) == 0 || p.Type == ProxyTypeSOCKS4 {
+		return http.ProxyURL(proxyURL), proxyDial
+	}
+
+	selectProxy := func(req *http.Request) (*url.URL, error) {
+		if _, pinned := hosts.Lookup(req.URL.Hostname()); pinned {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+
+	proxyAddr := net.JoinHostPort(p.Host, p.Port)
+	pinnedDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || addr == proxyAddr {
+			return proxyDial(ctx, network, addr)
+		}
+		ip, pinned := hosts.Lookup(host)
+		if !pinned {
+			return proxyDial(ctx, network, addr)
+		}
+		conn, err := proxyDial(ctx, network, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		return tunnel.Open(ctx, conn, proxyURL, net.JoinHostPort(ip, port))
+	}
+	return selectProxy, pinnedDial
+}
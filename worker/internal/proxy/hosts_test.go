@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHostOverrides(t *testing.T) {
+	hosts, err := ParseHostOverrides("WWW.Google.com.=142.250.74.36,142.250.74.68; www.bing.com=2620:1ec:c11::200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hosts{
+		"www.google.com": {"142.250.74.36", "142.250.74.68"},
+		"www.bing.com":   {"2620:1ec:c11::200"},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+
+	for _, bad := range []string{"www.google.com", "=1.2.3.4", "www.google.com=", "www.google.com=google"} {
+		if _, err := ParseHostOverrides(bad); err == nil {
+			t.Errorf("ParseHostOverrides(%q) accepted", bad)
+		}
+	}
+}
+
+func TestReadHostsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	data := "# pinned\n142.250.74.36 www.google.com google.com\n\n204.79.197.200\twww.bing.com # edge\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ReadHostsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, _ := hosts.Lookup("Google.com"); ip != "142.250.74.36" {
+		t.Errorf("google.com = %q", ip)
+	}
+	if ip, _ := hosts.Lookup("www.bing.com"); ip != "204.79.197.200" {
+		t.Errorf("www.bing.com = %q", ip)
+	}
+
+	os.WriteFile(path, []byte("142.250.74.36\n"), 0644)
+	if _, err := ReadHostsFile(path); err == nil {
+		t.Error("ReadHostsFile accepted an address without a name")
+	}
+}
+
+func TestHostsMerge(t *testing.T) {
+	global := Hosts{"a.example": {"10.0.0.1"}, "b.example": {"10.0.0.2"}}
+	own := Hosts{"b.example": {"10.0.0.3"}}
+	merged := global.Merge(own)
+	if ip, _ := merged.Lookup("a.example"); ip != "10.0.0.1" {
+		t.Errorf("a.example = %q", ip)
+	}
+	if ip, _ := merged.Lookup("b.example"); ip != "10.0.0.3" {
+		t.Errorf("b.example = %q, want the proxy's own", ip)
+	}
+	if ip, _ := global.Lookup("b.example"); ip != "10.0.0.2" {
+		t.Errorf("Merge changed its receiver: b.example = %q", ip)
+	}
+}
+
+func TestProxyRoutePinned(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "pinned "+r.Host)
+	}))
+	defer target.Close()
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	hop, tunnels := connectHop(t)
+
+	// The name does not resolve: only the override reaches the target
+	p, err := NewParser().ParseLine("http://" + hop + ` # hosts="search.invalid=127.0.0.1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL, _ := url.Parse(p.URL())
+	selectProxy, dial := p.Route(proxyURL, nil, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: selectProxy, DialContext: dial}}
+
+	resp, err := client.Get("http://search.invalid:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pinned search.invalid:"+port {
+		t.Errorf("body = %q", body)
+	}
+	if n := tunnels.Load(); n != 1 {
+		t.Errorf("proxy opened %d tunnels, want 1", n)
+	}
+}
+
+func TestParseLineRejectsBadHosts(t *testing.T) {
+	if _, err := NewParser().ParseLine(`1.2.3.4:8080 # hosts="www.google.com=google"`); err == nil {
+		t.Error("ParseLine accepted a malformed hosts override")
+	}
+}
@@ -1,29 +1,79 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"dorker/worker/internal/egress"
+)
+
+// RotationStrategy selects how Get picks among available proxies
+type RotationStrategy string
+
+const (
+	StrategyWeighted   RotationStrategy = "weighted"    // Random, weighted by success rate and latency
+	StrategyRoundRobin RotationStrategy = "round_robin" // Cycle through proxies in order
+	StrategyLeastUsed  RotationStrategy = "least_used"  // Prefer the proxy with the fewest requests
 )
 
 // PoolConfig holds configuration for the proxy pool
 type PoolConfig struct {
+	Strategy          RotationStrategy `json:"strategy"`        // Proxy selection strategy
 	MaxFailures       int           `json:"max_failures"`        // Max failures before quarantine
 	CooldownDuration  time.Duration `json:"cooldown_duration"`   // Cooldown after CAPTCHA/rate limit
 	QuarantineDuration time.Duration `json:"quarantine_duration"` // How long to quarantine bad proxies
 	HealthCheckInterval time.Duration `json:"health_check_interval"` // Interval between health checks
 	MinSuccessRate    float64       `json:"min_success_rate"`    // Minimum success rate to stay active
+	Shards            int           `json:"shards"`              // Selection shards; 0 shards automatically by pool size
+	MaxShare          float64       `json:"max_share"`           // Most requests one proxy may serve, in percent; 0 for no limit
+	Weights           WeightConfig  `json:"weights"`             // Weighted strategy coefficients; zero uses the defaults
+	PreflightURL      string        `json:"preflight_url"`       // Engine URL every proxy is tried against before use; empty for no pre-flight
+	RecheckURL        string        `json:"recheck_url"`         // Engine URL a quarantined proxy must pass to be released; empty releases on the timer
+	MaxReleases       int           `json:"max_releases"`        // Most quarantined proxies released or re-checked per health check; 0 for no limit
+	ProbationSuccesses int          `json:"probation_successes"` // Successes a proxy added at runtime needs for full rotation; 0 for no probation
+	ProbationShare    float64       `json:"probation_share"`     // Percent of picks that go to proxies on probation
+}
+
+// WeightConfig holds the coefficients of the weighted strategy. A proxy
+// weighs 1 plus SuccessBonus times its success rate, multiplied by
+// SlowFactor while its average latency is over SlowLatency. A higher
+// SuccessBonus concentrates traffic on proven proxies; a SuccessBonus near 0
+// spreads it evenly for ban avoidance, and a lower SlowFactor favours fast
+// proxies for latency-sensitive work.
+type WeightConfig struct {
+	SuccessBonus float64       `json:"success_bonus"` // Weight added at a 100% success rate
+	SlowLatency  time.Duration `json:"slow_latency"`  // Average latency above which a proxy is slow; 0 for none
+	SlowFactor   float64       `json:"slow_factor"`   // Multiplier for a slow proxy's weight
+}
+
+// DefaultWeightConfig returns the default weighted strategy coefficients
+func DefaultWeightConfig() WeightConfig {
+	return WeightConfig{
+		SuccessBonus: 2.0,
+		SlowLatency:  5 * time.Second,
+		SlowFactor:   0.5,
+	}
 }
 
 // DefaultPoolConfig returns sensible defaults
 func DefaultPoolConfig() PoolConfig {
 	return PoolConfig{
+		Strategy:           StrategyWeighted,
 		MaxFailures:        5,
 		CooldownDuration:   30 * time.Second,
 		QuarantineDuration: 5 * time.Minute,
 		HealthCheckInterval: 1 * time.Minute,
 		MinSuccessRate:     50.0,
+		Weights:            DefaultWeightConfig(),
+		ProbationSuccesses: 3,
+		ProbationShare:     10,
+		MaxReleases:        25,
 	}
 }
 
@@ -31,29 +81,113 @@ func DefaultPoolConfig() PoolConfig {
 type Pool struct {
 	mu       sync.RWMutex
 	proxies  map[string]*Proxy // All proxies by ID
-	alive    []*Proxy          // Available proxies for rotation
 	dead     []*Proxy          // Dead proxies
 	quarantine []*Proxy        // Temporarily quarantined proxies
 
-	config   PoolConfig
-	rng      *rand.Rand
-	stopCh   chan struct{}
-	
+	// Alive proxies, split by ID hash so Get locks and scans one shard. Moves
+	// in and out of shards, and replacing the set when the pool grows, happen
+	// under mu; Get only loads the set.
+	shards  atomic.Pointer[[]*poolShard]
+	rrShard atomic.Uint64 // Next shard for round-robin selection
+
+	config PoolConfig
+
+	// Runs the engine re-check of quarantined proxies; nil without a
+	// RecheckURL
+	checker *Checker
+
+	// Proxies whose exit is rotated when burned, by ID; see SetRotator
+	rotators map[string]*rotatingExit
+
+	// Health check lifecycle; healthCancel is nil while it is stopped
+	healthMu     sync.Mutex
+	healthCancel context.CancelFunc
+	healthWg     sync.WaitGroup
+
 	// Statistics
-	totalRotations int64
-	totalRequests  int64
+	totalRotations atomic.Int64
+	totalRequests  atomic.Int64
 }
 
 // NewPool creates a new proxy pool
 func NewPool(config PoolConfig) *Pool {
-	return &Pool{
+	if config.Weights == (WeightConfig{}) {
+		config.Weights = DefaultWeightConfig()
+	}
+
+	p := &Pool{
 		proxies:    make(map[string]*Proxy),
-		alive:      make([]*Proxy, 0),
 		dead:       make([]*Proxy, 0),
 		quarantine: make([]*Proxy, 0),
 		config:     config,
-		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
-		stopCh:     make(chan struct{}),
+		rotators:   make(map[string]*rotatingExit),
+	}
+	if config.RecheckURL != "" {
+		p.checker = NewChecker(DefaultCheckerConfig())
+	}
+
+	shards := newShards(max(config.Shards, 1))
+	p.shards.Store(&shards)
+	return p
+}
+
+// currentShards returns the shard set in use
+func (p *Pool) currentShards() []*poolShard {
+	return *p.shards.Load()
+}
+
+// shardOf returns the shard a proxy's ID maps to
+func (p *Pool) shardOf(id string) *poolShard {
+	shards := p.currentShards()
+	return shards[shardIndex(id, len(shards))]
+}
+
+// growShards moves an automatically sharded pool from one shard to
+// autoShards once it reaches autoShardThreshold proxies (must hold lock)
+func (p *Pool) growShards() {
+	if p.config.Shards > 0 || len(p.currentShards()) > 1 || len(p.proxies) < autoShardThreshold {
+		return
+	}
+
+	shards := newShards(autoShards)
+	for _, proxy := range p.aliveLocked() {
+		shards[shardIndex(proxy.ID, autoShards)].add(proxy)
+	}
+	p.shards.Store(&shards)
+}
+
+// aliveLocked returns the proxies in rotation across all shards (must hold
+// lock)
+func (p *Pool) aliveLocked() []*Proxy {
+	shards := p.currentShards()
+	result := make([]*Proxy, 0, p.aliveCount())
+	for _, shard := range shards {
+		shard.mu.Lock()
+		result = append(result, shard.alive...)
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// aliveCount returns the number of proxies in rotation without locking
+func (p *Pool) aliveCount() int {
+	var n int64
+	for _, shard := range p.currentShards() {
+		n += shard.count.Load()
+	}
+	return int(n)
+}
+
+// Config returns the pool configuration
+func (p *Pool) Config() PoolConfig {
+	return p.config
+}
+
+// SetEgress connects the engine re-check to quarantined proxies through an
+// egress proxy
+func (p *Pool) SetEgress(d *egress.Dialer) {
+	if p.checker != nil {
+		p.checker.SetEgress(d)
 	}
 }
 
@@ -68,11 +202,51 @@ func (p *Pool) AddProxy(proxy *Proxy) error {
 
 	proxy.Status = ProxyStatusAlive
 	p.proxies[proxy.ID] = proxy
-	p.alive = append(p.alive, proxy)
+	p.growShards()
+	p.shardOf(proxy.ID).add(proxy)
 
 	return nil
 }
 
+// AddProxyOnProbation adds a proxy joining a pool already in use. Until it
+// has ProbationSuccesses successes it only gets ProbationShare percent of
+// the picks, or picks nothing else can serve, so an untried proxy does not
+// take an equal share of traffic from proven ones.
+func (p *Pool) AddProxyOnProbation(proxy *Proxy) error {
+	if n := p.config.ProbationSuccesses; n > 0 {
+		proxy.startProbation(int64(n))
+	}
+	return p.AddProxy(proxy)
+}
+
+// RemoveProxy removes a proxy from the pool regardless of its status
+func (p *Pool) RemoveProxy(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.proxies[id]; !exists {
+		return false
+	}
+
+	delete(p.proxies, id)
+	delete(p.rotators, id)
+	p.shardOf(id).remove(id)
+	p.dead = removeByID(p.dead, id)
+	p.quarantine = removeByID(p.quarantine, id)
+
+	return true
+}
+
+// removeByID returns the slice without the proxy with the given ID
+func removeByID(proxies []*Proxy, id string) []*Proxy {
+	for i, proxy := range proxies {
+		if proxy.ID == id {
+			return append(proxies[:i], proxies[i+1:]...)
+		}
+	}
+	return proxies
+}
+
 // AddProxies adds multiple proxies to the pool
 func (p *Pool) AddProxies(proxies []*Proxy) (added int, errors []error) {
 	for _, proxy := range proxies {
@@ -97,68 +271,117 @@ func (p *Pool) LoadFromFile(filepath string) (added int, errors []error) {
 	return addedCount, errors
 }
 
-// Get returns an available proxy using weighted random selection
-// Proxies with better success rates are more likely to be selected
-func (p *Pool) Get() (*Proxy, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// ReloadFromFile syncs the pool with a proxy file: new entries are added on
+// probation and proxies no longer listed are removed. Existing proxies keep their stats and
+// take the file's metadata. Proxies a provider created are not from the file and stay.
+func (p *Pool) ReloadFromFile(filepath string) (added, removed int, errors []error) {
+	parser := NewParser()
+	proxies, parseErrors := parser.ParseFile(filepath)
+	errors = append(errors, parseErrors...)
 
-	p.totalRotations++
+	// Refuse to empty the pool because of an unreadable file
+	if len(proxies) == 0 && len(parseErrors) > 0 {
+		return 0, 0, errors
+	}
 
-	// Filter available proxies
-	available := make([]*Proxy, 0, len(p.alive))
-	for _, proxy := range p.alive {
-		if proxy.IsAvailable() {
-			available = append(available, proxy)
+	listed := make(map[string]bool, len(proxies))
+	for _, proxy := range proxies {
+		listed[proxy.ID] = true
+		if existing, exists := p.GetByID(proxy.ID); exists {
+			existing.setMetadata(proxy.Metadata)
+			continue
+		}
+		if err := p.AddProxyOnProbation(proxy); err == nil {
+			added++
 		}
 	}
 
-	if len(available) == 0 {
-		return nil, fmt.Errorf("no available proxies")
+	for _, proxy := range p.GetAll() {
+		if !listed[proxy.ID] && proxy.Provider == "" && p.RemoveProxy(proxy.ID) {
+			removed++
+		}
 	}
 
-	// Weighted random selection based on success rate
-	proxy := p.weightedSelect(available)
-	return proxy, nil
+	return added, removed, errors
 }
 
-// weightedSelect selects a proxy based on success rate weights
-func (p *Pool) weightedSelect(proxies []*Proxy) *Proxy {
-	if len(proxies) == 1 {
-		return proxies[0]
-	}
+// Get returns an available proxy using weighted random selection
+// Proxies with better success rates are more likely to be selected. With
+// several shards, selection happens within one shard, moving on to the next
+// when none of its proxies is available. Proxies that have served more than
+// MaxShare percent of the reported requests are passed over in every shard
+// before the least used of them is picked.
+func (p *Pool) Get() (*Proxy, error) {
+	p.totalRotations.Add(1)
 
-	// Calculate weights
-	weights := make([]float64, len(proxies))
-	totalWeight := 0.0
+	maxRequests := math.Inf(1)
+	if p.config.MaxShare > 0 {
+		maxRequests = p.config.MaxShare / 100 * float64(p.totalRequests.Load())
+	}
 
-	for i, proxy := range proxies {
-		// Base weight of 1, plus success rate bonus
-		weight := 1.0
-		if proxy.TotalRequests > 0 {
-			weight += proxy.SuccessRate() / 100.0 * 2.0 // Max bonus of 2.0
+	shards := p.currentShards()
+	start := p.startShard(shards)
+	var capped []*Proxy
+	for i := range shards {
+		shard := shards[(start+i)%len(shards)]
+		if shard.count.Load() == 0 {
+			continue
 		}
-		// Penalize slow proxies
-		if proxy.AvgLatency() > 5*time.Second {
-			weight *= 0.5
+		proxy, shardCapped := shard.pick(&p.config, maxRequests)
+		if proxy != nil {
+			return proxy, nil
 		}
-		weights[i] = weight
-		totalWeight += weight
+		if shardCapped != nil {
+			capped = append(capped, shardCapped)
+		}
+	}
+
+	// A share below one proxy's worth can hold back every proxy; keep
+	// serving with the least used of the whole pool rather than fail
+	if len(capped) > 0 {
+		return leastUsed(capped), nil
 	}
+	return nil, fmt.Errorf("no available proxies")
+}
 
-	// Random selection
-	r := p.rng.Float64() * totalWeight
-	cumulative := 0.0
+// startShard returns the shard Get tries first: the next in turn for
+// round-robin, otherwise a random one weighted by its number of proxies
+func (p *Pool) startShard(shards []*poolShard) int {
+	if len(shards) == 1 {
+		return 0
+	}
+	if p.config.Strategy == StrategyRoundRobin {
+		return int(p.rrShard.Add(1) % uint64(len(shards)))
+	}
 
-	for i, weight := range weights {
-		cumulative += weight
-		if r <= cumulative {
-			return proxies[i]
+	var total int64
+	for _, shard := range shards {
+		total += shard.count.Load()
+	}
+	if total == 0 {
+		return 0
+	}
+	r := rand.Int63n(total)
+	for i, shard := range shards {
+		if r -= shard.count.Load(); r < 0 {
+			return i
 		}
 	}
+	return 0
+}
 
-	// Fallback to last proxy
-	return proxies[len(proxies)-1]
+// leastUsed returns the proxy with the fewest requests, breaking ties by
+// the longest time since last use
+func leastUsed(proxies []*Proxy) *Proxy {
+	best := proxies[0]
+	bestRequests, bestLastUsed := best.usage()
+	for _, proxy := range proxies[1:] {
+		requests, lastUsed := proxy.usage()
+		if requests < bestRequests || (requests == bestRequests && lastUsed.Before(bestLastUsed)) {
+			best, bestRequests, bestLastUsed = proxy, requests, lastUsed
+		}
+	}
+	return best
 }
 
 // GetByID returns a specific proxy by ID
@@ -172,53 +395,59 @@ func (p *Pool) GetByID(id string) (*Proxy, bool) {
 
 // ReportSuccess reports a successful request for a proxy
 func (p *Pool) ReportSuccess(proxyID string, latency time.Duration) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	proxy, exists := p.proxies[proxyID]
+	proxy, exists := p.GetByID(proxyID)
 	if !exists {
 		return
 	}
 
 	proxy.RecordSuccess(latency)
-	p.totalRequests++
+	p.totalRequests.Add(1)
 }
 
 // ReportFailure reports a failed request for a proxy
 func (p *Pool) ReportFailure(proxyID string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	proxy, exists := p.proxies[proxyID]
+	proxy, exists := p.GetByID(proxyID)
 	if !exists {
 		return
 	}
 
 	proxy.RecordFail()
-	p.totalRequests++
+	p.totalRequests.Add(1)
 
 	// Check if should be quarantined
-	if proxy.FailCount >= int64(p.config.MaxFailures) {
+	if proxy.failures() < int64(p.config.MaxFailures) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Another report may have moved or removed it in the meantime
+	if p.proxies[proxyID] == proxy && proxy.Status == ProxyStatusAlive {
 		p.quarantineProxy(proxy)
 	}
 }
 
 // ReportCaptcha reports a CAPTCHA encounter for a proxy
 func (p *Pool) ReportCaptcha(proxyID string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	proxy, exists := p.proxies[proxyID]
+	proxy, exists := p.GetByID(proxyID)
 	if !exists {
 		return
 	}
 
 	proxy.RecordCaptcha()
+	if p.rotate(proxy) {
+		return
+	}
 	proxy.SetCooldown(p.config.CooldownDuration)
 }
 
 // ReportBlock reports that a proxy has been blocked
 func (p *Pool) ReportBlock(proxyID string) {
+	if proxy, exists := p.GetByID(proxyID); exists && p.rotate(proxy) {
+		return
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -232,31 +461,21 @@ func (p *Pool) ReportBlock(proxyID string) {
 
 // quarantineProxy moves a proxy to quarantine (must hold lock)
 func (p *Pool) quarantineProxy(proxy *Proxy) {
-	proxy.Status = ProxyStatusQuarantined
-	proxy.SetCooldown(p.config.QuarantineDuration)
+	// Remove from rotation
+	p.shardOf(proxy.ID).remove(proxy.ID)
 
-	// Remove from alive list
-	for i, ap := range p.alive {
-		if ap.ID == proxy.ID {
-			p.alive = append(p.alive[:i], p.alive[i+1:]...)
-			break
-		}
-	}
+	proxy.setStatus(ProxyStatusQuarantined)
+	proxy.SetCooldown(p.config.QuarantineDuration)
 
 	p.quarantine = append(p.quarantine, proxy)
 }
 
 // markDead marks a proxy as permanently dead (must hold lock)
 func (p *Pool) markDead(proxy *Proxy) {
-	proxy.Status = ProxyStatusDead
+	// Remove from rotation
+	p.shardOf(proxy.ID).remove(proxy.ID)
 
-	// Remove from alive list
-	for i, ap := range p.alive {
-		if ap.ID == proxy.ID {
-			p.alive = append(p.alive[:i], p.alive[i+1:]...)
-			break
-		}
-	}
+	proxy.setStatus(ProxyStatusDead)
 
 	// Remove from quarantine if present
 	for i, qp := range p.quarantine {
@@ -271,8 +490,7 @@ func (p *Pool) markDead(proxy *Proxy) {
 
 // reviveProxy moves a proxy from quarantine back to alive (must hold lock)
 func (p *Pool) reviveProxy(proxy *Proxy) {
-	proxy.Status = ProxyStatusAlive
-	proxy.FailCount = 0 // Reset fail count
+	proxy.setStatus(ProxyStatusAlive) // Also resets the fail count
 
 	// Remove from quarantine
 	for i, qp := range p.quarantine {
@@ -282,56 +500,117 @@ func (p *Pool) reviveProxy(proxy *Proxy) {
 		}
 	}
 
-	p.alive = append(p.alive, proxy)
+	p.shardOf(proxy.ID).add(proxy)
 }
 
-// StartHealthCheck starts the background health check routine
+// StartHealthCheck starts the background health check routine. It does
+// nothing if the health check is already running, and may be called again
+// after StopHealthCheck.
 func (p *Pool) StartHealthCheck() {
-	go func() {
-		ticker := time.NewTicker(p.config.HealthCheckInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				p.performHealthCheck()
-			case <-p.stopCh:
-				return
-			}
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+
+	if p.healthCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.healthCancel = cancel
+	p.healthWg.Add(1)
+	go p.runHealthCheck(ctx)
+}
+
+// runHealthCheck checks the pool every HealthCheckInterval until ctx is done
+func (p *Pool) runHealthCheck(ctx context.Context) {
+	defer p.healthWg.Done()
+
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.performHealthCheck(ctx)
+		case <-ctx.Done():
+			return
 		}
-	}()
+	}
 }
 
-// StopHealthCheck stops the background health check
+// StopHealthCheck stops the background health check and waits for its
+// goroutine to exit. Safe to call more than once, or before StartHealthCheck.
 func (p *Pool) StopHealthCheck() {
-	close(p.stopCh)
+	// healthMu is held across the wait so a concurrent Start cannot begin a
+	// new run before the old one is gone
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+
+	if p.healthCancel == nil {
+		return
+	}
+	p.healthCancel()
+	p.healthCancel = nil
+	p.healthWg.Wait()
 }
 
-// performHealthCheck checks quarantined proxies and revives eligible ones
-func (p *Pool) performHealthCheck() {
+// performHealthCheck checks quarantined proxies and revives eligible ones.
+// With a RecheckURL, a proxy whose quarantine is up must first pass a
+// re-check against the engine, run after the lock is released.
+func (p *Pool) performHealthCheck(ctx context.Context) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 
 	now := time.Now()
 
 	// Check quarantined proxies
-	toRevive := make([]*Proxy, 0)
+	due := make([]*Proxy, 0)
 	for _, proxy := range p.quarantine {
 		if now.After(proxy.CooldownUntil) {
-			toRevive = append(toRevive, proxy)
+			due = append(due, proxy)
 		}
 	}
 
-	for _, proxy := range toRevive {
-		p.reviveProxy(proxy)
+	// Over the release budget, those likeliest to pass the re-check, or else
+	// the longest overdue, go first; the rest wait for the next check, so a
+	// batch quarantined together does not hit the engine together again
+	if p.checker != nil {
+		sortForRecheck(due)
+	} else {
+		sort.Slice(due, func(i, j int) bool {
+			return due[i].CooldownUntil.Before(due[j].CooldownUntil)
+		})
+	}
+	if limit := p.config.MaxReleases; limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	if p.checker == nil {
+		for _, proxy := range due {
+			p.reviveProxy(proxy)
+		}
+		due = nil
 	}
 
 	// Check alive proxies for poor performance
-	for _, proxy := range p.alive {
-		if proxy.TotalRequests >= 10 && proxy.SuccessRate() < p.config.MinSuccessRate {
+	for _, proxy := range p.aliveLocked() {
+		if requests, _ := proxy.usage(); requests >= 10 && proxy.SuccessRate() < p.config.MinSuccessRate {
 			p.quarantineProxy(proxy)
 		}
 	}
+
+	p.mu.Unlock()
+
+	if len(due) > 0 {
+		p.recheck(ctx, due)
+	}
+}
+
+// Exhausted reports whether a non-empty pool has no alive proxies and none
+// in quarantine that could recover
+func (p *Pool) Exhausted() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.proxies) > 0 && p.aliveCount() == 0 && len(p.quarantine) == 0
 }
 
 // Stats returns current pool statistics
@@ -339,27 +618,31 @@ func (p *Pool) Stats() PoolStats {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	alive := p.aliveLocked()
 	stats := PoolStats{
 		Total:       len(p.proxies),
-		Alive:       len(p.alive),
+		Alive:       len(alive),
 		Dead:        len(p.dead),
 		Quarantined: len(p.quarantine),
-		Rotations:   p.totalRotations,
-		Requests:    p.totalRequests,
+		Rotations:   p.totalRotations.Load(),
+		Requests:    p.totalRequests.Load(),
 	}
 
 	// Calculate available (not on cooldown)
-	for _, proxy := range p.alive {
+	for _, proxy := range alive {
 		if proxy.IsAvailable() {
 			stats.Available++
 		}
+		if proxy.onProbation() {
+			stats.Probation++
+		}
 	}
 
 	// Calculate average success rate
 	totalRate := 0.0
 	counted := 0
-	for _, proxy := range p.alive {
-		if proxy.TotalRequests > 0 {
+	for _, proxy := range alive {
+		if requests, _ := proxy.usage(); requests > 0 {
 			totalRate += proxy.SuccessRate()
 			counted++
 		}
@@ -378,6 +661,7 @@ type PoolStats struct {
 	Available      int     `json:"available"`
 	Dead           int     `json:"dead"`
 	Quarantined    int     `json:"quarantined"`
+	Probation      int     `json:"probation"`
 	Rotations      int64   `json:"rotations"`
 	Requests       int64   `json:"requests"`
 	AvgSuccessRate float64 `json:"avg_success_rate"`
@@ -391,14 +675,38 @@ func (s PoolStats) AlivePercentage() float64 {
 	return float64(s.Alive) / float64(s.Total) * 100
 }
 
+// GetAll returns every proxy in the pool regardless of status
+func (p *Pool) GetAll() []*Proxy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]*Proxy, 0, len(p.proxies))
+	for _, proxy := range p.proxies {
+		result = append(result, proxy)
+	}
+	return result
+}
+
+// GetByIDs returns the proxies matching the given IDs, skipping unknown ones
+func (p *Pool) GetByIDs(ids []string) []*Proxy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]*Proxy, 0, len(ids))
+	for _, id := range ids {
+		if proxy, exists := p.proxies[id]; exists {
+			result = append(result, proxy)
+		}
+	}
+	return result
+}
+
 // GetAllAlive returns all alive proxies (for display purposes)
 func (p *Pool) GetAllAlive() []*Proxy {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	result := make([]*Proxy, len(p.alive))
-	copy(result, p.alive)
-	return result
+	return p.aliveLocked()
 }
 
 // GetAllDead returns all dead proxies (for display purposes)
@@ -426,7 +734,7 @@ func (p *Pool) RecommendedWorkers() int {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	alive := len(p.alive)
+	alive := p.aliveCount()
 	if alive == 0 {
 		return 0
 	}
@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sync"
 	"testing"
 	"time"
@@ -277,6 +279,45 @@ func TestPoolHealthCheck(t *testing.T) {
 	}
 }
 
+func TestPoolReleaseBudget(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.MaxReleases = 2
+	pool := NewPool(config)
+
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("p%d", i)
+		pool.AddProxy(&Proxy{ID: id, Host: "192.168.1.1", Port: fmt.Sprint(8080 + i), Type: ProxyTypeHTTP})
+		pool.ReportBlock(id)
+	}
+	// Due in the order added, the first longest overdue
+	for i, proxy := range pool.GetAllQuarantined() {
+		proxy.SetCooldown(-time.Duration(5-i) * time.Second)
+	}
+
+	for check, want := range []int{2, 4, 5} {
+		pool.performHealthCheck(context.Background())
+		if stats := pool.Stats(); stats.Alive != want || stats.Quarantined != 5-want {
+			t.Errorf("check %d: alive = %d, quarantined = %d, want %d and %d",
+				check+1, stats.Alive, stats.Quarantined, want, 5-want)
+		}
+	}
+
+	// The longest overdue were released first
+	pool = NewPool(config)
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("p%d", i)
+		pool.AddProxy(&Proxy{ID: id, Host: "192.168.1.1", Port: fmt.Sprint(8080 + i), Type: ProxyTypeHTTP})
+		pool.ReportBlock(id)
+	}
+	for i, proxy := range pool.GetAllQuarantined() {
+		proxy.SetCooldown(-time.Duration(i+1) * time.Second)
+	}
+	pool.performHealthCheck(context.Background())
+	if quarantined := pool.GetAllQuarantined(); len(quarantined) != 1 || quarantined[0].ID != "p0" {
+		t.Errorf("quarantined = %v, want only p0, the least overdue", quarantined)
+	}
+}
+
 func TestPoolWeightedSelection(t *testing.T) {
 	pool := NewPool(DefaultPoolConfig())
 
@@ -487,3 +528,573 @@ func TestPoolGetAllMethods(t *testing.T) {
 		t.Errorf("dead count = %d, want 0", len(dead))
 	}
 }
+
+func TestPoolGetAllAndByIDs(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+
+	for i := 0; i < 3; i++ {
+		pool.AddProxy(&Proxy{
+			ID:   fmt.Sprintf("test_%d", i),
+			Host: fmt.Sprintf("192.168.1.%d", i),
+			Port: "8080",
+			Type: ProxyTypeHTTP,
+		})
+	}
+	pool.ReportBlock("test_0")
+
+	if all := pool.GetAll(); len(all) != 3 {
+		t.Errorf("GetAll returned %d proxies, want 3", len(all))
+	}
+
+	selected := pool.GetByIDs([]string{"test_0", "test_2", "missing"})
+	if len(selected) != 2 {
+		t.Errorf("GetByIDs returned %d proxies, want 2", len(selected))
+	}
+}
+
+func TestPoolExhausted(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+
+	if pool.Exhausted() {
+		t.Error("empty pool should not be reported as exhausted")
+	}
+
+	proxy := &Proxy{ID: "test_1", Host: "192.168.1.1", Port: "8080", Type: ProxyTypeHTTP}
+	pool.AddProxy(proxy)
+
+	// Quarantined proxies can still recover
+	pool.ReportBlock("test_1")
+	if pool.Exhausted() {
+		t.Error("pool with quarantined proxy should not be exhausted")
+	}
+
+	pool.mu.Lock()
+	pool.markDead(proxy)
+	pool.mu.Unlock()
+
+	if !pool.Exhausted() {
+		t.Error("pool with only dead proxies should be exhausted")
+	}
+}
+
+func TestPoolStopHealthCheckTwice(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+	pool.StartHealthCheck()
+
+	pool.StopHealthCheck()
+	pool.StopHealthCheck() // must not panic
+}
+
+func TestPoolHealthCheckRestart(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.QuarantineDuration = 20 * time.Millisecond
+	config.HealthCheckInterval = 10 * time.Millisecond
+	pool := NewPool(config)
+	pool.AddProxy(&Proxy{ID: "test_1", Host: "192.168.1.1", Port: "8080", Type: ProxyTypeHTTP})
+
+	pool.StopHealthCheck() // before any Start
+	for i := 0; i < 3; i++ {
+		pool.StartHealthCheck()
+		pool.StartHealthCheck() // already running
+		pool.StopHealthCheck()
+		pool.StopHealthCheck()
+	}
+
+	// Once stopped, nothing revives the proxy
+	pool.ReportBlock("test_1")
+	time.Sleep(60 * time.Millisecond)
+	if stats := pool.Stats(); stats.Quarantined != 1 {
+		t.Fatalf("quarantined = %d after stop, want 1", stats.Quarantined)
+	}
+
+	// A restarted health check runs again
+	pool.StartHealthCheck()
+	defer pool.StopHealthCheck()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Alive != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("restarted health check did not revive the proxy")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolHealthCheckConcurrentStartStop(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.HealthCheckInterval = time.Millisecond
+	pool := NewPool(config)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				pool.StartHealthCheck()
+				pool.StopHealthCheck()
+			}
+		}()
+	}
+	wg.Wait()
+
+	pool.healthMu.Lock()
+	running := pool.healthCancel != nil
+	pool.healthMu.Unlock()
+	if running {
+		t.Error("health check still running after the last Stop")
+	}
+}
+
+func TestPoolRemoveProxy(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+	pool.AddProxy(&Proxy{ID: "test_1", Host: "192.168.1.1", Port: "8080", Type: ProxyTypeHTTP})
+	pool.AddProxy(&Proxy{ID: "test_2", Host: "192.168.1.2", Port: "8080", Type: ProxyTypeHTTP})
+	pool.ReportBlock("test_2")
+
+	if !pool.RemoveProxy("test_2") {
+		t.Error("RemoveProxy should succeed for quarantined proxy")
+	}
+
+	if pool.RemoveProxy("missing") {
+		t.Error("RemoveProxy should fail for unknown proxy")
+	}
+
+	stats := pool.Stats()
+	if stats.Total != 1 || stats.Quarantined != 0 {
+		t.Errorf("total = %d, quarantined = %d, want 1 and 0", stats.Total, stats.Quarantined)
+	}
+}
+
+func TestPoolRestoreState(t *testing.T) {
+	newPool := func() *Pool {
+		pool := NewPool(DefaultPoolConfig())
+		pool.AddProxy(&Proxy{ID: "test_1", Host: "192.168.1.1", Port: "8080", Type: ProxyTypeHTTP})
+		pool.AddProxy(&Proxy{ID: "test_2", Host: "192.168.1.2", Port: "8080", Type: ProxyTypeHTTP})
+		pool.AddProxy(&Proxy{ID: "test_3", Host: "192.168.1.3", Port: "8080", Type: ProxyTypeHTTP})
+		return pool
+	}
+
+	old := newPool()
+	old.ReportSuccess("test_1", time.Second)
+	old.ReportSuccess("test_1", time.Second)
+	old.ReportBlock("test_2")
+	dead, _ := old.GetByID("test_3")
+	old.mu.Lock()
+	old.markDead(dead)
+	old.mu.Unlock()
+	quarantineEnds := old.GetAllQuarantined()[0].CooldownUntil
+
+	restored := newPool()
+	states := append(old.State(), ProxyState{ID: "gone", Status: ProxyStatusDead})
+	if n := restored.RestoreState(states); n != 3 {
+		t.Errorf("restored %d proxies, want 3", n)
+	}
+
+	stats := restored.Stats()
+	if stats.Alive != 1 || stats.Quarantined != 1 || stats.Dead != 1 {
+		t.Errorf("alive %d, quarantined %d, dead %d; want 1 each", stats.Alive, stats.Quarantined, stats.Dead)
+	}
+	if prx, _ := restored.GetByID("test_1"); prx.SuccessCount != 2 || prx.TotalLatency != 2*time.Second {
+		t.Errorf("test_1 counters not restored: %d successes, %v latency", prx.SuccessCount, prx.TotalLatency)
+	}
+	if prx, _ := restored.GetByID("test_2"); !prx.CooldownUntil.Equal(quarantineEnds) {
+		t.Errorf("quarantine ends %v, want %v as before", prx.CooldownUntil, quarantineEnds)
+	}
+	if stats.Requests != old.Stats().Requests {
+		t.Errorf("requests = %d, want %d", stats.Requests, old.Stats().Requests)
+	}
+}
+
+func TestPoolReloadFromFile(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "proxies-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	os.WriteFile(tmpfile.Name(), []byte("192.168.1.1:8080\n192.168.1.2:8080\n"), 0644)
+
+	pool := NewPool(DefaultPoolConfig())
+	pool.LoadFromFile(tmpfile.Name())
+	pool.ReportSuccess("http_192.168.1.1_8080", time.Second)
+
+	os.WriteFile(tmpfile.Name(), []byte("192.168.1.1:8080\n192.168.1.3:8080\n"), 0644)
+
+	added, removed, errs := pool.ReloadFromFile(tmpfile.Name())
+	if added != 1 || removed != 1 || len(errs) != 0 {
+		t.Errorf("added = %d, removed = %d, errors = %v, want 1, 1, none", added, removed, errs)
+	}
+
+	// Surviving proxy keeps its statistics
+	kept, ok := pool.GetByID("http_192.168.1.1_8080")
+	if !ok || kept.SuccessCount != 1 {
+		t.Error("existing proxy should keep its stats across reload")
+	}
+
+	// A missing file must not empty the pool
+	if _, removed, _ := pool.ReloadFromFile("/nonexistent/proxies.txt"); removed != 0 {
+		t.Errorf("removed = %d after failed reload, want 0", removed)
+	}
+	if pool.Stats().Total != 2 {
+		t.Errorf("total = %d, want 2", pool.Stats().Total)
+	}
+}
+
+func TestPoolRoundRobinStrategy(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.Strategy = StrategyRoundRobin
+	pool := NewPool(config)
+
+	for i := 0; i < 3; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("p%d", i), Host: "10.0.0.1", Port: fmt.Sprint(8000 + i), Type: ProxyTypeHTTP})
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i < 9; i++ {
+		p, err := pool.Get()
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		seen[p.ID]++
+	}
+
+	for id, count := range seen {
+		if count != 3 {
+			t.Errorf("%s selected %d times, want 3", id, count)
+		}
+	}
+}
+
+func TestPoolLeastUsedStrategy(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.Strategy = StrategyLeastUsed
+	pool := NewPool(config)
+
+	busy := &Proxy{ID: "busy", Host: "10.0.0.1", Port: "8000", Type: ProxyTypeHTTP}
+	idle := &Proxy{ID: "idle", Host: "10.0.0.2", Port: "8000", Type: ProxyTypeHTTP}
+	pool.AddProxy(busy)
+	pool.AddProxy(idle)
+
+	for i := 0; i < 5; i++ {
+		busy.RecordSuccess(time.Millisecond)
+	}
+
+	p, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if p.ID != "idle" {
+		t.Errorf("Get() = %s, want idle", p.ID)
+	}
+}
+
+func TestPoolAutoShards(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+
+	for i := 0; i < autoShardThreshold-1; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("p%d", i), Host: "10.0.0.1", Port: fmt.Sprint(i), Type: ProxyTypeHTTP})
+	}
+	if n := len(pool.currentShards()); n != 1 {
+		t.Fatalf("shards below threshold = %d, want 1", n)
+	}
+
+	pool.AddProxy(&Proxy{ID: "last", Host: "10.0.0.2", Port: "1", Type: ProxyTypeHTTP})
+	if n := len(pool.currentShards()); n != autoShards {
+		t.Fatalf("shards at threshold = %d, want %d", n, autoShards)
+	}
+
+	// Resharding keeps every proxy in rotation exactly once
+	if stats := pool.Stats(); stats.Alive != autoShardThreshold || stats.Available != autoShardThreshold {
+		t.Errorf("alive = %d, available = %d, want %d", stats.Alive, stats.Available, autoShardThreshold)
+	}
+	if _, err := pool.Get(); err != nil {
+		t.Errorf("Get failed: %v", err)
+	}
+}
+
+func TestPoolShardedGet(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.Shards = 8
+	config.MaxFailures = 1
+	pool := NewPool(config)
+
+	// A lone proxy must be found whichever shard Get starts on
+	pool.AddProxy(&Proxy{ID: "only", Host: "10.0.0.1", Port: "8000", Type: ProxyTypeHTTP})
+	for i := 0; i < 50; i++ {
+		if p, err := pool.Get(); err != nil || p.ID != "only" {
+			t.Fatalf("Get = %v, %v", p, err)
+		}
+	}
+
+	pool.ReportFailure("only")
+	if _, err := pool.Get(); err == nil {
+		t.Error("Get should fail once the only proxy is quarantined")
+	}
+	if pool.Stats().Quarantined != 1 {
+		t.Errorf("stats = %+v, want the proxy quarantined", pool.Stats())
+	}
+}
+
+func TestPoolShardedRoundRobin(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.Strategy = StrategyRoundRobin
+	config.Shards = 4
+	pool := NewPool(config)
+
+	for i := 0; i < 64; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("p%d", i), Host: "10.0.0.1", Port: fmt.Sprint(8000 + i), Type: ProxyTypeHTTP})
+	}
+
+	// Every proxy is reached, though shard sizes make counts uneven
+	seen := make(map[string]bool)
+	for i := 0; i < 64*8; i++ {
+		p, _ := pool.Get()
+		seen[p.ID] = true
+	}
+	if len(seen) != 64 {
+		t.Errorf("round robin reached %d of 64 proxies", len(seen))
+	}
+}
+
+// benchPool builds a pool of n proxies split into the given shards
+func benchPool(b *testing.B, n, shards int) *Pool {
+	b.Helper()
+
+	config := DefaultPoolConfig()
+	config.Shards = shards
+	pool := NewPool(config)
+	for i := 0; i < n; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("proxy_%d", i), Host: "10.0.0.1", Port: fmt.Sprint(i), Type: ProxyTypeHTTP})
+	}
+	return pool
+}
+
+// BenchmarkPoolGet measures selection latency with concurrent callers; run
+// with -cpu 1,8,32 to see contention on the shard locks
+func BenchmarkPoolGet(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		for _, shards := range []int{1, 32} {
+			b.Run(fmt.Sprintf("proxies=%d/shards=%d", size, shards), func(b *testing.B) {
+				pool := benchPool(b, size, shards)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						if _, err := pool.Get(); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestPoolMaxShare(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.MaxShare = 30
+	pool := NewPool(config)
+
+	for i := 0; i < 4; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("p%d", i), Host: fmt.Sprintf("10.0.0.%d", i), Port: "8080", Type: ProxyTypeHTTP})
+	}
+
+	// Make p0 by far the best by weight, without quarantining the others
+	for i := 0; i < config.MaxFailures-1; i++ {
+		pool.ReportFailure("p1")
+		pool.ReportFailure("p2")
+		pool.ReportFailure("p3")
+	}
+	pool.ReportSuccess("p0", 10*time.Millisecond)
+
+	for i := 0; i < 1000; i++ {
+		p, err := pool.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		// The others are slow, which keeps their weight at half of p0's
+		latency := 10 * time.Second
+		if p.ID == "p0" {
+			latency = 10 * time.Millisecond
+		}
+		pool.ReportSuccess(p.ID, latency)
+	}
+
+	total := pool.Stats().Requests
+	for _, proxy := range pool.GetAll() {
+		requests, _ := proxy.usage()
+		if share := float64(requests) / float64(total) * 100; share > 31 {
+			t.Errorf("%s served %.1f%% of requests, want at most 30%%", proxy.ID, share)
+		}
+	}
+}
+
+func TestPoolMaxShareBelowFairShare(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.MaxShare = 10
+	pool := NewPool(config)
+
+	pool.AddProxy(&Proxy{ID: "a", Host: "10.0.0.1", Port: "8080", Type: ProxyTypeHTTP})
+	pool.AddProxy(&Proxy{ID: "b", Host: "10.0.0.2", Port: "8080", Type: ProxyTypeHTTP})
+
+	// Two proxies cannot each stay under 10%; Get keeps serving, least used
+	// first
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		p, err := pool.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		counts[p.ID]++
+		pool.ReportSuccess(p.ID, 10*time.Millisecond)
+	}
+	if counts["a"] < 45 || counts["b"] < 45 {
+		t.Errorf("counts = %v, want an even split", counts)
+	}
+}
+
+func TestPoolMaxShareAcrossShards(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.Strategy = StrategyRoundRobin
+	config.Shards = 2
+	config.MaxShare = 50
+	pool := NewPool(config)
+
+	// One proxy in each shard, so Get starts on either in turn
+	ids := make(map[int]string)
+	for i := 0; len(ids) < 2; i++ {
+		id := fmt.Sprintf("p%d", i)
+		if _, ok := ids[shardIndex(id, 2)]; !ok {
+			ids[shardIndex(id, 2)] = id
+		}
+	}
+	busy, idle := ids[0], ids[1]
+	pool.AddProxy(&Proxy{ID: busy, Host: "10.0.0.1", Port: "8080", Type: ProxyTypeHTTP})
+	pool.AddProxy(&Proxy{ID: idle, Host: "10.0.0.2", Port: "8080", Type: ProxyTypeHTTP})
+	for i := 0; i < 9; i++ {
+		pool.ReportSuccess(busy, 10*time.Millisecond)
+	}
+	pool.ReportSuccess(idle, 10*time.Millisecond)
+
+	// The capped proxy's shard has nothing else, but the other shard does
+	for i := 0; i < 10; i++ {
+		if p, err := pool.Get(); err != nil || p.ID != idle {
+			t.Fatalf("Get = %v, %v, want %s while %s is over its share", p, err, idle, busy)
+		}
+	}
+
+	// With both over their share, the least used of the pool serves
+	for i := 0; i < 9; i++ {
+		pool.ReportSuccess(idle, 10*time.Millisecond)
+	}
+	pool.config.MaxShare = 10
+	for i := 0; i < 10; i++ {
+		if p, err := pool.Get(); err != nil || p.ID != busy {
+			t.Fatalf("Get = %v, %v, want the least used %s", p, err, busy)
+		}
+	}
+}
+
+func TestPoolWeightConfig(t *testing.T) {
+	newPool := func(weights WeightConfig) *Pool {
+		config := DefaultPoolConfig()
+		config.Weights = weights
+		pool := NewPool(config)
+		pool.AddProxy(&Proxy{ID: "fast", Host: "10.0.0.1", Port: "8080", Type: ProxyTypeHTTP})
+		pool.AddProxy(&Proxy{ID: "slow", Host: "10.0.0.2", Port: "8080", Type: ProxyTypeHTTP})
+		pool.ReportSuccess("fast", 100*time.Millisecond)
+		pool.ReportSuccess("slow", 2*time.Second)
+		return pool
+	}
+	fastShare := func(pool *Pool) int {
+		fast := 0
+		for i := 0; i < 1000; i++ {
+			if p, _ := pool.Get(); p.ID == "fast" {
+				fast++
+			}
+		}
+		return fast
+	}
+
+	// 2s is not slow by default, so both weigh the same
+	if fast := fastShare(newPool(WeightConfig{})); fast < 400 || fast > 600 {
+		t.Errorf("default weights: fast picked %d of 1000, want about half", fast)
+	}
+
+	// Latency-sensitive tuning: anything over 1s weighs a tenth
+	tuned := WeightConfig{SuccessBonus: 2, SlowLatency: time.Second, SlowFactor: 0.1}
+	if fast := fastShare(newPool(tuned)); fast < 850 {
+		t.Errorf("tuned weights: fast picked %d of 1000, want about 900", fast)
+	}
+}
+
+func TestPoolProbation(t *testing.T) {
+	config := DefaultPoolConfig()
+	config.ProbationSuccesses = 3
+	config.ProbationShare = 10
+	pool := NewPool(config)
+
+	for i := 0; i < 3; i++ {
+		pool.AddProxy(&Proxy{ID: fmt.Sprintf("p%d", i), Host: fmt.Sprintf("10.0.0.%d", i), Port: "8080", Type: ProxyTypeHTTP})
+	}
+	pool.AddProxyOnProbation(&Proxy{ID: "new", Host: "10.0.0.9", Port: "8080", Type: ProxyTypeHTTP})
+
+	if n := pool.Stats().Probation; n != 1 {
+		t.Fatalf("Stats().Probation = %d, want 1", n)
+	}
+
+	// Without probation the new proxy would get a quarter of the picks
+	picks := 0
+	for i := 0; i < 2000; i++ {
+		p, err := pool.Get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if p.ID == "new" {
+			picks++
+		}
+	}
+	if share := float64(picks) / 2000 * 100; share < 5 || share > 15 {
+		t.Errorf("proxy on probation got %.1f%% of picks, want about 10%%", share)
+	}
+
+	for i := 0; i < config.ProbationSuccesses; i++ {
+		pool.ReportSuccess("new", 10*time.Millisecond)
+	}
+	if n := pool.Stats().Probation; n != 0 {
+		t.Errorf("Stats().Probation after %d successes = %d, want 0", config.ProbationSuccesses, n)
+	}
+}
+
+func TestPoolProbationOnly(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+	pool.AddProxyOnProbation(&Proxy{ID: "new", Host: "10.0.0.9", Port: "8080", Type: ProxyTypeHTTP})
+
+	// With nothing proven to use, a proxy on probation serves every pick
+	for i := 0; i < 10; i++ {
+		if p, err := pool.Get(); err != nil || p.ID != "new" {
+			t.Fatalf("Get = %v, %v", p, err)
+		}
+	}
+}
+
+func TestPoolReloadAddsOnProbation(t *testing.T) {
+	path := t.TempDir() + "/proxies.txt"
+	if err := os.WriteFile(path, []byte("10.0.0.1:8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewPool(DefaultPoolConfig())
+	pool.LoadFromFile(path)
+	if n := pool.Stats().Probation; n != 0 {
+		t.Errorf("initial load put %d proxies on probation", n)
+	}
+
+	if err := os.WriteFile(path, []byte("10.0.0.1:8080\n10.0.0.2:8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool.ReloadFromFile(path)
+	if n := pool.Stats().Probation; n != 1 {
+		t.Errorf("reload put %d proxies on probation, want 1", n)
+	}
+}
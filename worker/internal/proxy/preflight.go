@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPreflightURL is a cheap Google endpoint that answers 204 to clients
+// it does not block
+const DefaultPreflightURL = "https://www.google.com/generate_204"
+
+// Pre-flight outcomes
+const (
+	PreflightPassed = "passed" // The engine answered normally
+	PreflightBanned = "banned" // The engine redirected to /sorry or refused the client
+	PreflightFailed = "failed" // The request did not complete
+)
+
+// PreflightResult holds the outcome of one proxy's pre-flight request
+type PreflightResult struct {
+	ProxyID string        `json:"proxy_id"`
+	Outcome string        `json:"outcome"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// maxRecheckDoublings caps how often failed re-checks double a proxy's
+// quarantine: at most 16 times QuarantineDuration
+const maxRecheckDoublings = 4
+
+// PreflightSummary counts the outcomes of a pool pre-flight
+type PreflightSummary struct {
+	Passed int `json:"passed"`
+	Banned int `json:"banned"`
+	Failed int `json:"failed"`
+}
+
+// Preflight issues one request to target through the proxy without
+// following redirects, so a proxy the engine already bans is caught by its
+// redirect to /sorry before it is used for real searches
+func (c *Checker) Preflight(ctx context.Context, p *Proxy, target string) *PreflightResult {
+	result := &PreflightResult{ProxyID: p.ID, Outcome: PreflightFailed}
+
+	client, err := c.newClient(p)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+	result.Latency = time.Since(start)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusForbidden,
+		strings.Contains(resp.Header.Get("Location"), "/sorry/"):
+		result.Outcome = PreflightBanned
+		result.Error = "engine refused the proxy: " + resp.Status
+	case resp.StatusCode >= 200 && resp.StatusCode < 400:
+		result.Outcome = PreflightPassed
+	default:
+		result.Error = "bad status code: " + resp.Status
+	}
+	return result
+}
+
+// Preflight runs the engine pre-flight against every proxy in rotation when
+// PreflightURL is set. Banned proxies are quarantined, failed ones charged a
+// failure, and passed ones credited with the request's latency. onResult,
+// if not nil, is called for each proxy; calls are serialized.
+func (p *Pool) Preflight(ctx context.Context, checker *Checker, onResult func(*PreflightResult)) PreflightSummary {
+	var summary PreflightSummary
+	if p.config.PreflightURL == "" {
+		return summary
+	}
+
+	p.mu.RLock()
+	targets := p.aliveLocked()
+	p.mu.RUnlock()
+
+	var mu sync.Mutex
+	checker.each(ctx, targets, func(prx *Proxy) {
+		result := checker.Preflight(ctx, prx, p.config.PreflightURL)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch result.Outcome {
+		case PreflightPassed:
+			summary.Passed++
+			p.ReportSuccess(prx.ID, result.Latency)
+		case PreflightBanned:
+			summary.Banned++
+			p.ReportBlock(prx.ID)
+		default:
+			summary.Failed++
+			p.ReportFailure(prx.ID)
+		}
+		if onResult != nil {
+			onResult(result)
+		}
+	})
+	return summary
+}
+
+// sortForRecheck orders quarantined proxies likeliest to pass a re-check
+// first: fewest failed re-checks, then best success rate
+func sortForRecheck(due []*Proxy) {
+	failures := make(map[*Proxy]int, len(due))
+	rates := make(map[*Proxy]float64, len(due))
+	for _, prx := range due {
+		failures[prx] = prx.recheckFailures()
+		rates[prx] = prx.SuccessRate()
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if failures[due[i]] != failures[due[j]] {
+			return failures[due[i]] < failures[due[j]]
+		}
+		return rates[due[i]] > rates[due[j]]
+	})
+}
+
+// recheck tries quarantined proxies whose time is up against RecheckURL, in
+// the order given. Passing proxies return to rotation; the rest stay in
+// quarantine for twice as long as the last time, up to maxRecheckDoublings
+// doublings.
+func (p *Pool) recheck(ctx context.Context, due []*Proxy) {
+	p.checker.each(ctx, due, func(prx *Proxy) {
+		result := p.checker.Preflight(ctx, prx, p.config.RecheckURL)
+		if ctx.Err() != nil {
+			// Stopped mid-request; the next health check tries it again
+			return
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// It may have been removed or replaced while the request ran
+		if p.proxies[prx.ID] != prx || prx.Status != ProxyStatusQuarantined {
+			return
+		}
+
+		if result.Outcome == PreflightPassed {
+			prx.passRecheck()
+			p.reviveProxy(prx)
+			return
+		}
+		doublings := min(prx.failRecheck(), maxRecheckDoublings)
+		prx.SetCooldown(p.config.QuarantineDuration << doublings)
+	})
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Rotator changes the exit address behind a proxy, as a VPN client does
+// when it reconnects to another server. Rotate returns once traffic
+// through the proxy leaves from the new exit.
+type Rotator interface {
+	Rotate(ctx context.Context) error
+}
+
+// RotateTimeout bounds one rotation
+const RotateTimeout = 2 * time.Minute
+
+// rotatingExit is the rotation state of a proxy with a Rotator
+type rotatingExit struct {
+	rotator  Rotator
+	interval time.Duration // Least time between the starts of two rotations
+
+	mu      sync.Mutex
+	last    time.Time // Start of the last rotation
+	pending bool      // A rotation is waiting or running
+	count   int64     // Rotations that succeeded
+}
+
+// SetRotator makes a proxy's exit rotate when it is burned. A CAPTCHA or
+// block on the proxy then rests it only until rotator has given it a new
+// exit, instead of cooling it down or quarantining it. Rotations start at
+// least interval apart: a burn sooner than that rests the proxy until the
+// interval is up, and burns while a rotation is pending join it. A failed
+// rotation quarantines the proxy as a block would. A nil rotator makes the
+// proxy ordinary again.
+func (p *Pool) SetRotator(proxyID string, rotator Rotator, interval time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.proxies[proxyID]; !exists {
+		return false
+	}
+	if rotator == nil {
+		delete(p.rotators, proxyID)
+		return true
+	}
+	p.rotators[proxyID] = &rotatingExit{rotator: rotator, interval: interval}
+	return true
+}
+
+// Rotations returns how many times a proxy's exit has been rotated
+func (p *Pool) Rotations(proxyID string) int64 {
+	p.mu.RLock()
+	exit := p.rotators[proxyID]
+	p.mu.RUnlock()
+
+	if exit == nil {
+		return 0
+	}
+	exit.mu.Lock()
+	defer exit.mu.Unlock()
+	return exit.count
+}
+
+// rotate starts rotating a burned proxy's exit and rests the proxy until
+// it is done. It reports false for a proxy without a Rotator, which the
+// caller handles as usual.
+func (p *Pool) rotate(proxy *Proxy) bool {
+	p.mu.RLock()
+	exit := p.rotators[proxy.ID]
+	p.mu.RUnlock()
+
+	if exit == nil {
+		return false
+	}
+
+	exit.mu.Lock()
+	defer exit.mu.Unlock()
+
+	if exit.pending {
+		return true
+	}
+	wait := max(time.Until(exit.last.Add(exit.interval)), 0)
+	exit.pending = true
+	proxy.SetCooldown(wait + RotateTimeout)
+
+	time.AfterFunc(wait, func() { p.finishRotation(proxy, exit) })
+	return true
+}
+
+// finishRotation runs a rotation and puts the proxy back in rotation, or
+// in quarantine when the rotation failed
+func (p *Pool) finishRotation(proxy *Proxy, exit *rotatingExit) {
+	exit.mu.Lock()
+	exit.last = time.Now()
+	exit.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), RotateTimeout)
+	err := exit.rotator.Rotate(ctx)
+	cancel()
+
+	exit.mu.Lock()
+	exit.pending = false
+	if err == nil {
+		exit.count++
+	}
+	exit.mu.Unlock()
+
+	if err == nil {
+		proxy.SetCooldown(0)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// It may have been removed or quarantined in the meantime
+	if p.proxies[proxy.ID] == proxy && proxy.Status == ProxyStatusAlive {
+		p.quarantineProxy(proxy)
+	}
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingRotator counts rotations and fails them while fail is set
+type countingRotator struct {
+	calls atomic.Int32
+	fail  atomic.Bool
+}
+
+func (r *countingRotator) Rotate(ctx context.Context) error {
+	r.calls.Add(1)
+	if r.fail.Load() {
+		return errors.New("exit unreachable")
+	}
+	return nil
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolRotator(t *testing.T) {
+	pool := NewPool(DefaultPoolConfig())
+	vpn := &Proxy{ID: "vpn", Host: "127.0.0.1", Port: "8888", Type: ProxyTypeHTTP}
+	pool.AddProxy(vpn)
+
+	rotator := &countingRotator{}
+	if !pool.SetRotator("vpn", rotator, 200*time.Millisecond) {
+		t.Fatal("SetRotator refused a pooled proxy")
+	}
+	if pool.SetRotator("missing", rotator, 0) {
+		t.Error("SetRotator accepted an unknown proxy")
+	}
+
+	// A CAPTCHA rotates the exit instead of cooling the proxy down
+	pool.ReportCaptcha("vpn")
+	waitFor(t, "the first rotation", func() bool { return pool.Rotations("vpn") == 1 })
+	if !vpn.IsAvailable() {
+		t.Error("proxy not available after its exit rotated")
+	}
+
+	// A block within the interval waits for it, and burns meanwhile join
+	// the pending rotation
+	pool.ReportBlock("vpn")
+	pool.ReportCaptcha("vpn")
+	if vpn.IsAvailable() {
+		t.Error("burned proxy available before its rotation")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if rotator.calls.Load() != 1 {
+		t.Errorf("rotated %d times within the interval, want 1", rotator.calls.Load())
+	}
+	waitFor(t, "the second rotation", func() bool { return pool.Rotations("vpn") == 2 })
+	if vpn.Status != ProxyStatusAlive {
+		t.Errorf("status = %s after a block and a rotation, want alive", vpn.Status)
+	}
+
+	// A failed rotation quarantines the proxy like a block
+	rotator.fail.Store(true)
+	pool.ReportBlock("vpn")
+	waitFor(t, "the quarantine", func() bool { return len(pool.GetAllQuarantined()) == 1 })
+	if pool.Rotations("vpn") != 2 {
+		t.Errorf("rotations = %d after a failure, want 2", pool.Rotations("vpn"))
+	}
+}
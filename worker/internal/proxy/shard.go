@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sharding defaults: a pool stays on one shard, where selection sees every
+// proxy at once, until it is large enough for the global scan to hurt
+const (
+	autoShardThreshold = 4096
+	autoShards         = 32
+)
+
+// poolShard holds part of the alive proxies behind its own lock, so Gets on
+// different shards neither contend nor scan each other's proxies
+type poolShard struct {
+	mu      sync.Mutex
+	alive   []*Proxy
+	count   atomic.Int64 // len(alive), readable without mu
+	rrIndex int          // Next position for round-robin selection
+	rng     *rand.Rand
+
+	// Scratch space reused by selection, guarded by mu
+	available []*Proxy
+	probation []*Proxy
+	weights   []float64
+
+	_ [64]byte // Keep neighbouring shards' locks off one cache line
+}
+
+func newShards(n int) []*poolShard {
+	shards := make([]*poolShard, n)
+	for i := range shards {
+		shards[i] = &poolShard{rng: rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))}
+	}
+	return shards
+}
+
+// shardIndex maps a proxy ID onto one of n shards
+func shardIndex(id string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(n))
+}
+
+// add appends a proxy to the shard's rotation
+func (s *poolShard) add(proxy *Proxy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alive = append(s.alive, proxy)
+	s.count.Store(int64(len(s.alive)))
+}
+
+// remove drops a proxy from the shard's rotation, reporting whether it was
+// there
+func (s *poolShard) remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, proxy := range s.alive {
+		if proxy.ID == id {
+			s.alive = append(s.alive[:i], s.alive[i+1:]...)
+			s.count.Store(int64(len(s.alive)))
+			return true
+		}
+	}
+	return false
+}
+
+// pick selects an available proxy from the shard by strategy, or returns nil
+// when every proxy in it is cooling down or has more than maxRequests
+// requests. Proxies on probation get ProbationShare percent of the picks,
+// least used first. capped is the least used of the proxies held back by
+// maxRequests, for the pool to fall back on once no shard has another.
+func (s *poolShard) pick(config *PoolConfig, maxRequests float64) (proxy, capped *Proxy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cappedRequests int64
+
+	s.available = s.available[:0]
+	s.probation = s.probation[:0]
+	for _, proxy := range s.alive {
+		if !proxy.IsAvailable() {
+			continue
+		}
+		if requests, _ := proxy.usage(); float64(requests) > maxRequests {
+			if capped == nil || requests < cappedRequests {
+				capped, cappedRequests = proxy, requests
+			}
+			continue
+		}
+		if proxy.onProbation() {
+			s.probation = append(s.probation, proxy)
+			continue
+		}
+		s.available = append(s.available, proxy)
+	}
+	// Do not keep the last pick's proxies reachable from the scratch slices
+	defer clear(s.available)
+	defer clear(s.probation)
+
+	// Proxies on probation also serve when no proven one can
+	if len(s.probation) > 0 && (len(s.available) == 0 || s.rng.Float64()*100 < config.ProbationShare) {
+		return leastUsed(s.probation), capped
+	}
+
+	if len(s.available) == 0 {
+		return nil, capped
+	}
+
+	switch config.Strategy {
+	case StrategyRoundRobin:
+		s.rrIndex = (s.rrIndex + 1) % len(s.available)
+		return s.available[s.rrIndex], capped
+	case StrategyLeastUsed:
+		return leastUsed(s.available), capped
+	default:
+		return s.weightedSelect(s.available, config.Weights), capped
+	}
+}
+
+// weightedSelect selects a proxy based on success rate weights
+func (s *poolShard) weightedSelect(proxies []*Proxy, weights WeightConfig) *Proxy {
+	if len(proxies) == 1 {
+		return proxies[0]
+	}
+
+	// Calculate weights
+	s.weights = s.weights[:0]
+	totalWeight := 0.0
+
+	for _, proxy := range proxies {
+		weight := selectionWeight(proxy, weights)
+		s.weights = append(s.weights, weight)
+		totalWeight += weight
+	}
+
+	// Random selection
+	r := s.rng.Float64() * totalWeight
+	cumulative := 0.0
+
+	for i, weight := range s.weights {
+		cumulative += weight
+		if r <= cumulative {
+			return proxies[i]
+		}
+	}
+
+	// Fallback to last proxy
+	return proxies[len(proxies)-1]
+}
+
+// selectionWeight weighs a proxy for weighted selection as described by
+// WeightConfig. It reads the stats under one lock since it runs for every
+// candidate.
+func selectionWeight(proxy *Proxy, weights WeightConfig) float64 {
+	proxy.mu.RLock()
+	defer proxy.mu.RUnlock()
+
+	weight := 1.0
+	if proxy.TotalRequests > 0 {
+		weight += float64(proxy.SuccessCount) / float64(proxy.TotalRequests) * weights.SuccessBonus
+	}
+	if weights.SlowLatency > 0 && proxy.SuccessCount > 0 && proxy.TotalLatency/time.Duration(proxy.SuccessCount) > weights.SlowLatency {
+		weight *= weights.SlowFactor
+	}
+	return weight
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import "time"
+
+// ProxyState is a proxy's standing and counters, saved so a pool can pick
+// up where another left off
+type ProxyState struct {
+	ID            string        `json:"id"`
+	Status        ProxyStatus   `json:"status"`
+	TotalRequests int64         `json:"total_requests"`
+	SuccessCount  int64         `json:"success_count"`
+	FailCount     int64         `json:"fail_count"`
+	CaptchaCount  int64         `json:"captcha_count"`
+	TotalLatency  time.Duration `json:"total_latency"`
+	LastUsed      time.Time     `json:"last_used"`
+	LastSuccess   time.Time     `json:"last_success"`
+	LastFail      time.Time     `json:"last_fail"`
+	CooldownUntil time.Time     `json:"cooldown_until"`
+	GraduateAt    int64         `json:"graduate_at,omitempty"`
+	RecheckFails  int           `json:"recheck_fails,omitempty"`
+}
+
+// state returns a copy of the proxy's standing and counters
+func (p *Proxy) state() ProxyState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return ProxyState{
+		ID:            p.ID,
+		Status:        p.Status,
+		TotalRequests: p.TotalRequests,
+		SuccessCount:  p.SuccessCount,
+		FailCount:     p.FailCount,
+		CaptchaCount:  p.CaptchaCount,
+		TotalLatency:  p.TotalLatency,
+		LastUsed:      p.LastUsed,
+		LastSuccess:   p.LastSuccess,
+		LastFail:      p.LastFail,
+		CooldownUntil: p.CooldownUntil,
+		GraduateAt:    p.graduateAt,
+		RecheckFails:  p.recheckFails,
+	}
+}
+
+// setCounters copies the counters of a saved state
+func (p *Proxy) setCounters(s ProxyState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.TotalRequests = s.TotalRequests
+	p.SuccessCount = s.SuccessCount
+	p.FailCount = s.FailCount
+	p.CaptchaCount = s.CaptchaCount
+	p.TotalLatency = s.TotalLatency
+	p.LastUsed = s.LastUsed
+	p.LastSuccess = s.LastSuccess
+	p.LastFail = s.LastFail
+	p.CooldownUntil = s.CooldownUntil
+	p.graduateAt = s.GraduateAt
+	p.recheckFails = s.RecheckFails
+}
+
+// State returns the standing and counters of every proxy in the pool
+func (p *Pool) State() []ProxyState {
+	proxies := p.GetAll()
+	states := make([]ProxyState, len(proxies))
+	for i, proxy := range proxies {
+		states[i] = proxy.state()
+	}
+	return states
+}
+
+// RestoreState applies saved states to the proxies with the same IDs: dead
+// and quarantined proxies leave rotation again, with the quarantine ending
+// when it did before, and counters carry over. States of proxies not in the
+// pool are ignored. It returns how many proxies were restored.
+func (p *Pool) RestoreState(states []ProxyState) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	restored := 0
+	for _, s := range states {
+		proxy, ok := p.proxies[s.ID]
+		if !ok {
+			continue
+		}
+
+		switch s.Status {
+		case ProxyStatusDead:
+			p.markDead(proxy)
+		case ProxyStatusQuarantined:
+			p.quarantineProxy(proxy)
+		}
+		proxy.setCounters(s)
+		p.totalRequests.Add(s.TotalRequests)
+		restored++
+	}
+	return restored
+}
@@ -0,0 +1,152 @@
+// Package reqtrace times the phases of a sample of requests, to tell
+// whether slow requests are slow at the proxy or at the engine. Through a
+// proxy, DNS and connect time the way to the proxy, TLS the handshake with
+// the engine through the tunnel, and TTFB how long the engine took to
+// answer once asked.
+package reqtrace
+
+import (
+	"context"
+	"crypto/tls"
+	"math/rand"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings are the phases of one traced request, in milliseconds. A phase
+// the request did not go through, such as DNS for a proxy given by
+// address, is 0.
+type Timings struct {
+	DNS     int64 `json:"dns_ms"`
+	Connect int64 `json:"connect_ms"`
+	TLS     int64 `json:"tls_ms"`
+	TTFB    int64 `json:"ttfb_ms"`  // From the request written to the first response byte
+	Total   int64 `json:"total_ms"` // From the start to the first response byte
+
+	// Error names the phase the request failed in, when it did not get a
+	// response
+	Error string `json:"error,omitempty"`
+}
+
+// Sample reports whether to trace a request, for rate percent of requests
+func Sample(rate float64) bool {
+	return rate > 0 && rand.Float64()*100 < rate
+}
+
+// Recorder collects the phase times of one request. Its hooks may run on
+// the transport's dialing goroutines, so it is safe for concurrent use.
+type Recorder struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	failed       string
+}
+
+// Start returns ctx set to trace the request made with it into a new
+// recorder
+func Start(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{start: time.Now()}
+	return httptrace.WithClientTrace(ctx, r.clientTrace()), r
+}
+
+// clientTrace returns the hooks recording into r. Only the first attempt
+// of each phase counts: redirects and parallel dials repeat them.
+func (r *Recorder) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mark(&r.dnsStart)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			r.markDone(&r.dnsDone, "dns", info.Err)
+		},
+		ConnectStart: func(string, string) {
+			r.mark(&r.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			r.markDone(&r.connectDone, "connect", err)
+		},
+		TLSHandshakeStart: func() {
+			r.mark(&r.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			r.markDone(&r.tlsDone, "tls", err)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			r.markDone(&r.wrote, "write", info.Err)
+		},
+		GotFirstResponseByte: func() {
+			r.mark(&r.firstByte)
+		},
+	}
+}
+
+// mark sets t to now unless it is set
+func (r *Recorder) mark(t *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.IsZero() {
+		*t = time.Now()
+	}
+}
+
+// markDone marks the end of a phase, or its failure. A parallel dial may
+// fail after another succeeded, so only a phase with no success yet is
+// failed.
+func (r *Recorder) markDone(t *time.Time, phase string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !t.IsZero() {
+		return
+	}
+	if err != nil {
+		if r.failed == "" {
+			r.failed = phase
+		}
+		return
+	}
+	*t = time.Now()
+	if r.failed == phase {
+		r.failed = ""
+	}
+}
+
+// Timings returns the times recorded so far; nil for a nil recorder, so
+// that an unsampled request has none
+func (r *Recorder) Timings() *Timings {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timings := &Timings{
+		DNS:     span(r.dnsStart, r.dnsDone),
+		Connect: span(r.connectStart, r.connectDone),
+		TLS:     span(r.tlsStart, r.tlsDone),
+		TTFB:    span(r.wrote, r.firstByte),
+		Total:   span(r.start, r.firstByte),
+	}
+	if r.firstByte.IsZero() {
+		timings.Error = r.failed
+		if timings.Error == "" {
+			timings.Error = "no response"
+		}
+	}
+	return timings
+}
+
+// span returns the milliseconds from start to end, 0 unless both are set
+func span(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
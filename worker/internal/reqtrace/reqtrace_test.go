@@ -0,0 +1,79 @@
+package reqtrace
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if Sample(0) {
+			t.Fatal("Sample(0) traced a request")
+		}
+		if !Sample(100) {
+			t.Fatal("Sample(100) skipped a request")
+		}
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var r *Recorder
+	if timings := r.Timings(); timings != nil {
+		t.Errorf("nil recorder gave %+v", timings)
+	}
+}
+
+func TestTraceResponse(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ctx, recorder := Start(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	timings := recorder.Timings()
+	if timings.Error != "" {
+		t.Errorf("Error = %q for a response", timings.Error)
+	}
+	if timings.TTFB < 20 || timings.Total < timings.TTFB {
+		t.Errorf("TTFB = %dms, Total = %dms; want TTFB >= 20ms and Total >= TTFB", timings.TTFB, timings.Total)
+	}
+	if recorder.tlsDone.IsZero() || recorder.connectDone.IsZero() {
+		t.Error("connect or TLS handshake not recorded")
+	}
+}
+
+func TestTraceConnectFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, recorder := Start(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+addr, nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a closed port succeeded")
+	}
+
+	timings := recorder.Timings()
+	if timings.Error != "connect" {
+		t.Errorf("Error = %q, want connect", timings.Error)
+	}
+	if timings.Total != 0 {
+		t.Errorf("Total = %dms without a response", timings.Total)
+	}
+}
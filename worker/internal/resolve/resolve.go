@@ -0,0 +1,186 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes redirect resolution; it is off unless Enabled
+type Config struct {
+	Enabled bool `json:"enabled"`
+
+	// Hosts are the redirector hosts whose links are resolved; empty uses
+	// DefaultHosts. Subdomains of a host match too.
+	Hosts []string `json:"hosts,omitempty"`
+
+	// MaxHops bounds the redirects followed from one link
+	MaxHops int `json:"max_hops"`
+
+	// Timeout bounds the resolution of one link, all hops included
+	Timeout time.Duration `json:"timeout"`
+}
+
+// DefaultHosts are common link shorteners and outbound trackers
+var DefaultHosts = []string{
+	"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd",
+	"lnkd.in", "ow.ly", "rb.gy", "rebrand.ly", "t.co", "t.ly",
+	"tiny.cc", "tinyurl.com", "trib.al",
+}
+
+// Defaults for unset Config fields
+const (
+	DefaultMaxHops = 5
+	DefaultTimeout = 10 * time.Second
+)
+
+// maxCache bounds the resolved links kept; the cache starts over when full
+const maxCache = 10000
+
+// Resolver follows redirector links to their final URL with HEAD requests,
+// caching every link it resolves. It is safe for concurrent use.
+type Resolver struct {
+	hosts   []string
+	maxHops int
+	timeout time.Duration
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// New returns the resolver described by config, or nil when it is disabled
+func New(config Config) *Resolver {
+	if !config.Enabled {
+		return nil
+	}
+
+	hosts := config.Hosts
+	if len(hosts) == 0 {
+		hosts = DefaultHosts
+	}
+	r := &Resolver{
+		maxHops: config.MaxHops,
+		timeout: config.Timeout,
+		cache:   make(map[string]string),
+	}
+	for _, host := range hosts {
+		host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+		if host != "" {
+			r.hosts = append(r.hosts, host)
+		}
+	}
+	if r.maxHops <= 0 {
+		r.maxHops = DefaultMaxHops
+	}
+	if r.timeout <= 0 {
+		r.timeout = DefaultTimeout
+	}
+	return r
+}
+
+// Match reports whether rawURL points at one of the redirector hosts
+func (r *Resolver) Match(rawURL string) bool {
+	if r == nil {
+		return false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	for _, h := range r.hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cached returns the final URL of a link resolved earlier
+func (r *Resolver) Cached(rawURL string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	final, ok := r.cache[rawURL]
+	return final, ok
+}
+
+// Resolve follows the redirects of rawURL through transport, which must
+// not follow them itself, and returns where they end. A link that stops
+// redirecting before MaxHops resolves to its last location; one that keeps
+// going is an error. Each hop is tried with HEAD, falling back to GET for
+// servers that refuse HEAD.
+func (r *Resolver) Resolve(ctx context.Context, transport http.RoundTripper, rawURL string) (string, error) {
+	if final, ok := r.Cached(rawURL); ok {
+		return final, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	current := rawURL
+	for hop := 0; ; hop++ {
+		next, err := r.hop(ctx, transport, current)
+		if err != nil {
+			return "", err
+		}
+		if next == "" {
+			break
+		}
+		if hop == r.maxHops {
+			return "", fmt.Errorf("more than %d redirects", r.maxHops)
+		}
+		current = next
+	}
+
+	r.mu.Lock()
+	if len(r.cache) >= maxCache {
+		r.cache = make(map[string]string)
+	}
+	r.cache[rawURL] = current
+	r.mu.Unlock()
+
+	return current, nil
+}
+
+// hop requests target once and returns the absolute redirect location, or
+// "" when the response is not a redirect
+func (r *Resolver) hop(ctx context.Context, transport http.RoundTripper, target string) (string, error) {
+	resp, err := roundTrip(ctx, transport, http.MethodHead, target)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = roundTrip(ctx, transport, http.MethodGet, target)
+	}
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("redirect from %s: %w", target, err)
+	}
+	return location.String(), nil
+}
+
+func roundTrip(ctx context.Context, transport http.RoundTripper, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
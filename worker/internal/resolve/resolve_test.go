@@ -0,0 +1,87 @@
+package resolve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResolverMatch(t *testing.T) {
+	r := New(Config{Enabled: true, Hosts: []string{"bit.ly", " LNKD.in. "}})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://bit.ly/abc", true},
+		{"https://LNKD.IN/x", true},
+		{"https://www.bit.ly/abc", true},
+		{"https://notbit.ly/abc", false},
+		{"https://example.com/?u=bit.ly", false},
+	}
+	for _, tt := range tests {
+		if got := r.Match(tt.url); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	if New(Config{}) != nil {
+		t.Error("disabled config should give a nil resolver")
+	}
+	var disabled *Resolver
+	if disabled.Match("https://bit.ly/abc") {
+		t.Error("nil resolver should match nothing")
+	}
+}
+
+func TestResolverResolve(t *testing.T) {
+	var requests int64
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		switch req.URL.Path {
+		case "/short":
+			http.Redirect(w, req, "/tracker", http.StatusMovedPermanently)
+		case "/tracker":
+			if req.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, req, srv.URL+"/final?id=1", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, req, "/loop", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	r := New(Config{Enabled: true, MaxHops: 3})
+
+	final, err := r.Resolve(context.Background(), http.DefaultTransport, srv.URL+"/short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final != srv.URL+"/final?id=1" {
+		t.Errorf("final = %q", final)
+	}
+
+	// HEAD short, HEAD+GET tracker, HEAD final
+	if n := atomic.LoadInt64(&requests); n != 4 {
+		t.Errorf("requests = %d, want 4", n)
+	}
+
+	// A second resolve of the same link is answered from the cache
+	if _, err := r.Resolve(context.Background(), http.DefaultTransport, srv.URL+"/short"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&requests); n != 4 {
+		t.Errorf("requests after cached resolve = %d, want 4", n)
+	}
+
+	if _, err := r.Resolve(context.Background(), http.DefaultTransport, srv.URL+"/loop"); err == nil {
+		t.Error("redirect loop should fail")
+	}
+}
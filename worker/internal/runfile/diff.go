@@ -0,0 +1,241 @@
+package runfile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Run holds the distinct URLs of a result file with the dorks that found
+// each
+type Run struct {
+	Path string
+	urls map[string]map[string]bool
+}
+
+// NewRun returns an empty run named path
+func NewRun(path string) *Run {
+	return &Run{Path: path, urls: make(map[string]map[string]bool)}
+}
+
+// Load reads the result file at path into a run
+func Load(path string) (*Run, error) {
+	run := NewRun(path)
+	err := ReadFile(path, func(r Record) error {
+		run.Add(r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Add records a URL and the dork that found it
+func (r *Run) Add(record Record) {
+	dorks := r.urls[record.URL]
+	if dorks == nil {
+		dorks = make(map[string]bool)
+		r.urls[record.URL] = dorks
+	}
+	dorks[record.Dork] = true
+}
+
+// Domains returns the number of distinct URLs per domain
+func (r *Run) Domains() map[string]int {
+	domains := make(map[string]int)
+	for u := range r.urls {
+		if domain := Domain(u); domain != "" {
+			domains[domain]++
+		}
+	}
+	return domains
+}
+
+// Summary counts a run's URLs and domains
+type Summary struct {
+	Path    string `json:"path"`
+	URLs    int    `json:"urls"`
+	Domains int    `json:"domains"`
+}
+
+// URLChange is a URL found in only one of two runs, with the dorks that
+// found it there
+type URLChange struct {
+	URL   string   `json:"url"`
+	Dorks []string `json:"dorks"`
+}
+
+// DomainChange is a domain found in only one of two runs, with its number
+// of URLs there
+type DomainChange struct {
+	Domain string `json:"domain"`
+	URLs   int    `json:"urls"`
+}
+
+// DorkChange counts the URLs a dork gained and lost between two runs
+type DorkChange struct {
+	Dork    string `json:"dork"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// Diff is what changed from run A to run B
+type Diff struct {
+	A         Summary `json:"a"`
+	B         Summary `json:"b"`
+	Unchanged int     `json:"unchanged_urls"`
+
+	AddedURLs      []URLChange    `json:"added_urls"`
+	RemovedURLs    []URLChange    `json:"removed_urls"`
+	AddedDomains   []DomainChange `json:"added_domains"`
+	RemovedDomains []DomainChange `json:"removed_domains"`
+	Dorks          []DorkChange   `json:"dorks"`
+}
+
+// Compare returns what changed from a to b. URLs match exactly; domains
+// match on the lowercased host name.
+func Compare(a, b *Run) *Diff {
+	domainsA, domainsB := a.Domains(), b.Domains()
+	d := &Diff{
+		A: Summary{Path: a.Path, URLs: len(a.urls), Domains: len(domainsA)},
+		B: Summary{Path: b.Path, URLs: len(b.urls), Domains: len(domainsB)},
+	}
+
+	dorks := make(map[string]*DorkChange)
+	dork := func(name string) *DorkChange {
+		if dorks[name] == nil {
+			dorks[name] = &DorkChange{Dork: name}
+		}
+		return dorks[name]
+	}
+
+	for u, found := range b.urls {
+		if a.urls[u] != nil {
+			d.Unchanged++
+			continue
+		}
+		d.AddedURLs = append(d.AddedURLs, URLChange{URL: u, Dorks: sortedKeys(found)})
+		for name := range found {
+			dork(name).Added++
+		}
+	}
+	for u, found := range a.urls {
+		if b.urls[u] != nil {
+			continue
+		}
+		d.RemovedURLs = append(d.RemovedURLs, URLChange{URL: u, Dorks: sortedKeys(found)})
+		for name := range found {
+			dork(name).Removed++
+		}
+	}
+	sort.Slice(d.AddedURLs, func(i, j int) bool { return d.AddedURLs[i].URL < d.AddedURLs[j].URL })
+	sort.Slice(d.RemovedURLs, func(i, j int) bool { return d.RemovedURLs[i].URL < d.RemovedURLs[j].URL })
+
+	d.AddedDomains = domainChanges(domainsB, domainsA)
+	d.RemovedDomains = domainChanges(domainsA, domainsB)
+
+	for _, change := range dorks {
+		d.Dorks = append(d.Dorks, *change)
+	}
+	sort.Slice(d.Dorks, func(i, j int) bool {
+		ci, cj := d.Dorks[i].Added+d.Dorks[i].Removed, d.Dorks[j].Added+d.Dorks[j].Removed
+		if ci != cj {
+			return ci > cj
+		}
+		return d.Dorks[i].Dork < d.Dorks[j].Dork
+	})
+	return d
+}
+
+// domainChanges lists the domains of from missing in other, most URLs first
+func domainChanges(from, other map[string]int) []DomainChange {
+	var changes []DomainChange
+	for domain, n := range from {
+		if _, ok := other[domain]; !ok {
+			changes = append(changes, DomainChange{Domain: domain, URLs: n})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].URLs != changes[j].URLs {
+			return changes[i].URLs > changes[j].URLs
+		}
+		return changes[i].Domain < changes[j].Domain
+	})
+	return changes
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Changed reports whether the runs differ in any URL
+func (d *Diff) Changed() bool {
+	return len(d.AddedURLs) > 0 || len(d.RemovedURLs) > 0
+}
+
+// Write prints the diff for a terminal
+func (d *Diff) Write(w io.Writer) {
+	fmt.Fprintf(w, "A  %s: %d URLs, %d domains\n", d.A.Path, d.A.URLs, d.A.Domains)
+	fmt.Fprintf(w, "B  %s: %d URLs, %d domains\n\n", d.B.Path, d.B.URLs, d.B.Domains)
+	fmt.Fprintf(w, "  URLs     +%d added, -%d removed, %d unchanged\n", len(d.AddedURLs), len(d.RemovedURLs), d.Unchanged)
+	fmt.Fprintf(w, "  domains  +%d added, -%d removed\n", len(d.AddedDomains), len(d.RemovedDomains))
+
+	if len(d.Dorks) > 0 {
+		fmt.Fprintln(w, "\nPer dork:")
+		for _, change := range d.Dorks {
+			fmt.Fprintf(w, "  %6s %6s  %s\n", fmt.Sprintf("+%d", change.Added), fmt.Sprintf("-%d", change.Removed), dorkLabel(change.Dork))
+		}
+	}
+	if len(d.AddedDomains) > 0 {
+		fmt.Fprintln(w, "\nAdded domains:")
+		for _, change := range d.AddedDomains {
+			fmt.Fprintf(w, "  + %s (%d URLs)\n", change.Domain, change.URLs)
+		}
+	}
+	if len(d.RemovedDomains) > 0 {
+		fmt.Fprintln(w, "\nRemoved domains:")
+		for _, change := range d.RemovedDomains {
+			fmt.Fprintf(w, "  - %s (%d URLs)\n", change.Domain, change.URLs)
+		}
+	}
+	if len(d.AddedURLs) > 0 {
+		fmt.Fprintln(w, "\nAdded URLs:")
+		for _, change := range d.AddedURLs {
+			fmt.Fprintf(w, "  + %s%s\n", change.URL, dorkList(change.Dorks))
+		}
+	}
+	if len(d.RemovedURLs) > 0 {
+		fmt.Fprintln(w, "\nRemoved URLs:")
+		for _, change := range d.RemovedURLs {
+			fmt.Fprintf(w, "  - %s%s\n", change.URL, dorkList(change.Dorks))
+		}
+	}
+}
+
+func dorkLabel(dork string) string {
+	if dork == "" {
+		return "(no dork recorded)"
+	}
+	return dork
+}
+
+// dorkList formats the dorks behind a URL, nothing when none was recorded
+func dorkList(dorks []string) string {
+	named := dorks[:0:0]
+	for _, dork := range dorks {
+		if dork != "" {
+			named = append(named, dork)
+		}
+	}
+	if len(named) == 0 {
+		return ""
+	}
+	return "  [" + strings.Join(named, "; ") + "]"
+}
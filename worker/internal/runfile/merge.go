@@ -0,0 +1,128 @@
+package runfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"dorker/worker/internal/dedup"
+)
+
+// FileStats counts what one input file contributed to a merge
+type FileStats struct {
+	Path    string `json:"path"`
+	Records int    `json:"records"`
+	Kept    int    `json:"kept"`
+}
+
+// MergeStats are the aggregate counts of a merge
+type MergeStats struct {
+	Granularity dedup.Granularity `json:"granularity"`
+	Files       []FileStats       `json:"files"`
+	Records     int               `json:"records"`    // Records read from every file
+	URLs        int               `json:"urls"`       // Records written
+	Duplicates  int               `json:"duplicates"` // Records dropped as duplicates
+	Domains     int               `json:"domains"`    // Distinct domains written
+	Dorks       int               `json:"dorks"`      // Distinct dorks behind written URLs
+	First       int64             `json:"first_ms,omitempty"`
+	Last        int64             `json:"last_ms,omitempty"`
+}
+
+// Merger writes the records of several result files as one JSONL file,
+// keeping the first record of each dedup key
+type Merger struct {
+	out         *bufio.Writer
+	granularity dedup.Granularity
+	seen        map[string]bool
+	domains     map[string]bool
+	dorks       map[string]bool
+	stats       MergeStats
+}
+
+// NewMerger returns a merger writing to w and deduping at granularity g
+func NewMerger(w io.Writer, g dedup.Granularity) *Merger {
+	if g == "" {
+		g = dedup.GranularityURL
+	}
+	return &Merger{
+		out:         bufio.NewWriter(w),
+		granularity: g,
+		seen:        make(map[string]bool),
+		domains:     make(map[string]bool),
+		dorks:       make(map[string]bool),
+		stats:       MergeStats{Granularity: g},
+	}
+}
+
+// output is the line written for a record read without one of its own
+type output struct {
+	URL       string `json:"url"`
+	Dork      string `json:"dork,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// AddFile merges the records of the file at path
+func (m *Merger) AddFile(path string) error {
+	file := FileStats{Path: path}
+	err := ReadFile(path, func(r Record) error {
+		file.Records++
+		m.stats.Records++
+		if r.Timestamp > 0 {
+			if m.stats.First == 0 || r.Timestamp < m.stats.First {
+				m.stats.First = r.Timestamp
+			}
+			m.stats.Last = max(m.stats.Last, r.Timestamp)
+		}
+
+		key := dedup.Key(r.URL, m.granularity)
+		if m.seen[key] {
+			m.stats.Duplicates++
+			return nil
+		}
+		m.seen[key] = true
+		file.Kept++
+		m.stats.URLs++
+		if domain := r.Domain(); domain != "" {
+			m.domains[domain] = true
+		}
+		if r.Dork != "" {
+			m.dorks[r.Dork] = true
+		}
+
+		line := []byte(r.Raw)
+		if line == nil {
+			var err error
+			if line, err = json.Marshal(output{URL: r.URL, Dork: r.Dork, Timestamp: r.Timestamp}); err != nil {
+				return err
+			}
+		}
+		m.out.Write(line)
+		return m.out.WriteByte('\n')
+	})
+	m.stats.Files = append(m.stats.Files, file)
+	return err
+}
+
+// Flush writes buffered records to the output
+func (m *Merger) Flush() error {
+	return m.out.Flush()
+}
+
+// Stats returns the counts so far
+func (m *Merger) Stats() MergeStats {
+	stats := m.stats
+	stats.Files = append([]FileStats(nil), m.stats.Files...)
+	stats.Domains = len(m.domains)
+	stats.Dorks = len(m.dorks)
+	return stats
+}
+
+// Write prints the stats for a terminal
+func (s MergeStats) Write(w io.Writer) {
+	for _, f := range s.Files {
+		fmt.Fprintf(w, "  %-40s %d records, %d kept\n", f.Path, f.Records, f.Kept)
+	}
+	fmt.Fprintf(w, "\n  records     %d read, %d duplicates dropped (%s level)\n", s.Records, s.Duplicates, s.Granularity)
+	fmt.Fprintf(w, "  written     %d URLs, %d domains, %d dorks\n", s.URLs, s.Domains, s.Dorks)
+}
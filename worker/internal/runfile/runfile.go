@@ -0,0 +1,119 @@
+// Package runfile reads, compares and merges the result files of runs:
+// JSONL written by the CLI (one {"url", "dork", ...} object per line),
+// worker stdout recorded as JSONL result messages, or plain text with one
+// URL per line as standalone mode writes.
+package runfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxLine bounds one line of a result file; result messages of a full page
+// stay well below it
+const maxLine = 16 << 20
+
+// Record is one URL of a result file
+type Record struct {
+	URL       string
+	Dork      string // Empty for plain text files
+	Timestamp int64  // Milliseconds since the epoch, 0 when not recorded
+
+	// Raw is the line the record came from when it was a single URL
+	// object, so rewriting it keeps fields this package does not know
+	Raw json.RawMessage
+}
+
+// Domain returns the record's host name, lowercased, or "" when the URL
+// has none
+func (r Record) Domain() string {
+	return Domain(r.URL)
+}
+
+// Domain returns rawURL's host name, lowercased, or "" when it has none
+func Domain(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// line is the union of the JSON line shapes a result file may hold
+type line struct {
+	URL       string `json:"url"`
+	Dork      string `json:"dork"`
+	Timestamp int64  `json:"timestamp"`
+
+	// Worker result message
+	Type string `json:"type"`
+	TS   int64  `json:"ts"`
+	Data struct {
+		Dork   string   `json:"dork"`
+		URLs   []string `json:"urls"`
+		Status string   `json:"status"`
+	} `json:"data"`
+}
+
+// Read calls fn with every record of r in file order. Blank lines and
+// messages other than results are skipped; fn's error stops the read.
+func Read(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(text, "{") {
+			if err := fn(Record{URL: text}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var l line
+		if err := json.Unmarshal([]byte(text), &l); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		switch {
+		case l.URL != "":
+			record := Record{URL: l.URL, Dork: l.Dork, Timestamp: l.Timestamp, Raw: json.RawMessage(text)}
+			if err := fn(record); err != nil {
+				return err
+			}
+		case l.Type == "result":
+			for _, u := range l.Data.URLs {
+				if err := fn(Record{URL: u, Dork: l.Data.Dork, Timestamp: l.TS}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", n+1, err)
+	}
+	return nil
+}
+
+// ReadFile calls fn with every record of the file at path
+func ReadFile(path string, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := Read(f, fn); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
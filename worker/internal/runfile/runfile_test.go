@@ -0,0 +1,137 @@
+package runfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dorker/worker/internal/dedup"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRead(t *testing.T) {
+	input := `{"url":"https://a.example/x","dork":"inurl:admin","timestamp":1700000000000,"title":"A"}
+
+{"type":"status","ts":1,"data":{"status":"initialized"}}
+{"type":"result","ts":1700000001000,"data":{"task_id":"t1","dork":"inurl:login","urls":["https://b.example/","https://c.example/"],"status":"success"}}
+# a comment
+https://d.example/page
+`
+	var records []Record
+	err := Read(strings.NewReader(input), func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("records = %+v", records)
+	}
+	if records[0].Dork != "inurl:admin" || records[0].Timestamp != 1700000000000 || !bytes.Contains(records[0].Raw, []byte(`"title":"A"`)) {
+		t.Errorf("CLI record = %+v", records[0])
+	}
+	if records[2].URL != "https://c.example/" || records[2].Dork != "inurl:login" || records[2].Raw != nil {
+		t.Errorf("result message record = %+v", records[2])
+	}
+	if records[3].URL != "https://d.example/page" || records[3].Dork != "" {
+		t.Errorf("plain record = %+v", records[3])
+	}
+
+	if err := Read(strings.NewReader("{broken\n"), func(Record) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("broken line err = %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := writeFile(t, "a.jsonl", `{"url":"https://a.example/1","dork":"d1"}
+{"url":"https://a.example/2","dork":"d1"}
+{"url":"https://old.example/","dork":"d2"}
+`)
+	b := writeFile(t, "b.jsonl", `{"url":"https://a.example/1","dork":"d1"}
+{"url":"https://a.example/3","dork":"d1"}
+{"url":"https://New.example/","dork":"d2"}
+{"url":"https://new.example/x","dork":"d3"}
+`)
+	runA, err := Load(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runB, err := Load(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := Compare(runA, runB)
+	if !d.Changed() || d.Unchanged != 1 || len(d.AddedURLs) != 3 || len(d.RemovedURLs) != 2 {
+		t.Fatalf("diff = %+v", d)
+	}
+	if len(d.AddedDomains) != 1 || d.AddedDomains[0] != (DomainChange{Domain: "new.example", URLs: 2}) {
+		t.Errorf("added domains = %+v", d.AddedDomains)
+	}
+	if len(d.RemovedDomains) != 1 || d.RemovedDomains[0].Domain != "old.example" {
+		t.Errorf("removed domains = %+v", d.RemovedDomains)
+	}
+	if len(d.Dorks) != 3 || d.Dorks[0] != (DorkChange{Dork: "d1", Added: 1, Removed: 1}) || d.Dorks[2] != (DorkChange{Dork: "d3", Added: 1}) {
+		t.Errorf("dorks = %+v", d.Dorks)
+	}
+
+	var out bytes.Buffer
+	d.Write(&out)
+	if !strings.Contains(out.String(), "+ https://new.example/x  [d3]") {
+		t.Errorf("report lacks attribution:\n%s", out.String())
+	}
+
+	if Compare(runA, runA).Changed() {
+		t.Error("a run differs from itself")
+	}
+}
+
+func TestMerger(t *testing.T) {
+	a := writeFile(t, "a.jsonl", `{"url":"https://a.example/item.php?id=1","dork":"d1","timestamp":2000,"title":"kept"}
+{"url":"https://b.example/","dork":"d2","timestamp":1000}
+`)
+	b := writeFile(t, "b.txt", `https://a.example/item.php?id=2
+https://c.example/page
+`)
+
+	var out bytes.Buffer
+	m := NewMerger(&out, dedup.GranularityPath)
+	for _, path := range []string{a, b} {
+		if err := m.AddFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"url":"https://a.example/item.php?id=1","dork":"d1","timestamp":2000,"title":"kept"}
+{"url":"https://b.example/","dork":"d2","timestamp":1000}
+{"url":"https://c.example/page"}
+`
+	if out.String() != want {
+		t.Errorf("merged =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	stats := m.Stats()
+	if stats.Records != 4 || stats.URLs != 3 || stats.Duplicates != 1 || stats.Domains != 3 || stats.Dorks != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.First != 1000 || stats.Last != 2000 {
+		t.Errorf("time span = %d-%d", stats.First, stats.Last)
+	}
+	if len(stats.Files) != 2 || stats.Files[1] != (FileStats{Path: b, Records: 2, Kept: 1}) {
+		t.Errorf("files = %+v", stats.Files)
+	}
+}
@@ -0,0 +1,80 @@
+package scope
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Scope decides which result URLs a job reports. Patterns are hosts:
+// "target.com" matches that host only, "*.target.com" the domain and every
+// subdomain. A scheme, port or path in a pattern is ignored.
+type Scope struct {
+	exact    map[string]bool
+	suffixes []string
+}
+
+// New compiles patterns into a scope, or returns nil when there are none,
+// which puts every URL in scope
+func New(patterns []string) *Scope {
+	s := &Scope{exact: make(map[string]bool)}
+	for _, pattern := range patterns {
+		host, wildcard := normalize(pattern)
+		switch {
+		case host == "":
+		case wildcard:
+			s.suffixes = append(s.suffixes, host)
+		default:
+			s.exact[host] = true
+		}
+	}
+	if len(s.exact) == 0 && len(s.suffixes) == 0 {
+		return nil
+	}
+	return s
+}
+
+// normalize lowercases a pattern down to its host, reporting whether it
+// had a leading "*." wildcard
+func normalize(pattern string) (string, bool) {
+	p := strings.ToLower(strings.TrimSpace(pattern))
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+3:]
+	}
+	if i := strings.IndexAny(p, "/?#"); i >= 0 {
+		p = p[:i]
+	}
+	if i := strings.LastIndexByte(p, ':'); i >= 0 {
+		p = p[:i]
+	}
+
+	wildcard := strings.HasPrefix(p, "*.")
+	p = strings.TrimPrefix(p, "*.")
+	return strings.TrimSuffix(p, "."), wildcard
+}
+
+// Contains reports whether the URL's host is in scope. A nil scope contains
+// every URL.
+func (s *Scope) Contains(rawURL string) bool {
+	if s == nil {
+		return true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return false
+	}
+
+	if s.exact[host] {
+		return true
+	}
+	for _, suffix := range s.suffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
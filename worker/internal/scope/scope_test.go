@@ -0,0 +1,39 @@
+package scope
+
+import "testing"
+
+func TestScopeContains(t *testing.T) {
+	s := New([]string{"*.target.com", "api.other.io", "https://Shop.Example.org/path"})
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://target.com/", true},
+		{"https://www.target.com/login", true},
+		{"http://a.b.target.com:8443/x", true},
+		{"https://api.other.io/v1", true},
+		{"https://shop.example.org/admin", true},
+		{"https://other.io/", false},
+		{"https://dev.api.other.io/", false},
+		{"https://nottarget.com/", false},
+		{"https://target.com.evil.net/", false},
+		{"not a url", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.Contains(tt.url); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestScopeEmpty(t *testing.T) {
+	s := New([]string{"", "  "})
+	if s != nil {
+		t.Fatal("scope without patterns should be nil")
+	}
+	if !s.Contains("https://anything.example/") {
+		t.Error("nil scope should contain every URL")
+	}
+}
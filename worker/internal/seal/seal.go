@@ -0,0 +1,239 @@
+// Package seal encrypts files written in pieces, such as result files that
+// grow during a run, with AES-256-GCM. A sealed file is a header followed
+// by records, one per Write of up to 64 MB. Each record's nonce is the
+// file's random prefix and the record's sequence number, and the last
+// record is marked, so records that are reordered, dropped or cut off fail
+// to open.
+package seal
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeySize is the key length in bytes
+const KeySize = 32
+
+// magic opens every sealed file
+const magic = "DORKSEAL1"
+
+const (
+	prefixSize = 4
+	headerSize = len(magic) + prefixSize
+
+	// maxRecord bounds a record's length so a damaged length field cannot
+	// make a reader allocate without limit
+	maxRecord = 64 << 20
+)
+
+// ErrTruncated is returned when a sealed file ends before its last record
+var ErrTruncated = errors.New("sealed file is truncated")
+
+// ParseKey decodes a key given as 64 hex digits or base64 of 32 bytes
+func ParseKey(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes as hex or base64", KeySize)
+}
+
+// LoadKey returns the key in the file at path, or in the env value when
+// path is empty. It returns nil with no error when neither is set.
+func LoadKey(env, path string) ([]byte, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		return ParseKey(string(data))
+	}
+	if env == "" {
+		return nil, nil
+	}
+	return ParseKey(env)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce builds a record's nonce from the file prefix and its sequence
+func nonce(prefix []byte, sequence uint64) []byte {
+	n := make([]byte, prefixSize+8)
+	copy(n, prefix)
+	binary.BigEndian.PutUint64(n[prefixSize:], sequence)
+	return n
+}
+
+// additional binds a record to its file header and to whether it is last
+func additional(header []byte, last bool) []byte {
+	ad := make([]byte, len(header)+1)
+	copy(ad, header)
+	if last {
+		ad[len(header)] = 1
+	}
+	return ad
+}
+
+// Writer seals each Write as one record. Close writes the last record; a
+// file without it does not open.
+type Writer struct {
+	w        io.Writer
+	aead     cipher.AEAD
+	header   []byte
+	sequence uint64
+	closed   bool
+}
+
+// NewWriter writes a header to w and returns a writer sealing with key
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header}, nil
+}
+
+// Write seals p as one record, or several when it is longer than a record
+// holds, and reports len(p) on success
+func (s *Writer) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed sealed writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), maxRecord-s.aead.Overhead())]
+		if err := s.record(chunk, false); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (s *Writer) record(p []byte, last bool) error {
+	sealed := s.aead.Seal(nil, nonce(s.header[len(magic):], s.sequence), p, additional(s.header, last))
+	s.sequence++
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := s.w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// Close writes the last record. It does not close the underlying writer.
+func (s *Writer) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.record(nil, true)
+}
+
+// Reader opens a sealed stream record by record
+type Reader struct {
+	r        *bufio.Reader
+	aead     cipher.AEAD
+	header   []byte
+	sequence uint64
+	pending  []byte
+	done     bool
+}
+
+// NewReader reads the header from r and returns a reader opening with key
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a sealed file")
+	}
+	return &Reader{r: br, aead: aead, header: header}, nil
+}
+
+// Read returns opened plaintext; it fails on a wrong key, damaged or
+// reordered records, and with ErrTruncated when the last record is missing
+func (s *Reader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next opens the following record into pending
+func (s *Reader) next() error {
+	var length [4]byte
+	if _, err := io.ReadFull(s.r, length[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size < uint32(s.aead.Overhead()) || size > maxRecord {
+		return fmt.Errorf("sealed record %d is damaged", s.sequence)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(s.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	n := nonce(s.header[len(magic):], s.sequence)
+	plain, err := s.aead.Open(nil, n, sealed, additional(s.header, false))
+	if err != nil {
+		last, lastErr := s.aead.Open(nil, n, sealed, additional(s.header, true))
+		if lastErr != nil {
+			return fmt.Errorf("sealed record %d does not open: wrong key or damaged file", s.sequence)
+		}
+		if _, err := s.r.Peek(1); err != io.EOF {
+			return fmt.Errorf("data after the last sealed record")
+		}
+		plain, s.done = last, true
+	}
+	s.sequence++
+	s.pending = plain
+	return nil
+}
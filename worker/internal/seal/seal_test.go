@@ -0,0 +1,101 @@
+package seal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func sealed(t *testing.T, key []byte, parts ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range parts {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func open(key, data []byte) (string, error) {
+	r, err := NewReader(bytes.NewReader(data), key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(r)
+	return string(plain), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	key, err := ParseKey("5mJ6Xq0y0mYl2c1o5c8jv2m6i2s3XqJ3u8y7gk9m0e4=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := sealed(t, key, "https://a.example/\n", "", "https://b.example/\nhttps://c.example/\n")
+	if bytes.Contains(data, []byte("example")) {
+		t.Error("sealed file holds plaintext")
+	}
+	plain, err := open(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "https://a.example/\nhttps://b.example/\nhttps://c.example/\n" {
+		t.Errorf("plain = %q", plain)
+	}
+
+	empty, err := open(key, sealed(t, key))
+	if err != nil || empty != "" {
+		t.Errorf("empty file = %q, %v", empty, err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	data := sealed(t, key, "first\n", "second\n")
+
+	other := bytes.Repeat([]byte{8}, KeySize)
+	if _, err := open(other, data); err == nil {
+		t.Error("wrong key opened the file")
+	}
+
+	flipped := append([]byte(nil), data...)
+	flipped[headerSize+10] ^= 1
+	if _, err := open(key, flipped); err == nil {
+		t.Error("damaged record opened")
+	}
+
+	// Cutting the last record off, or a record short, is caught
+	for _, cut := range []int{len(data) - 4 - 16, len(data) - 1} {
+		if _, err := open(key, data[:cut]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("cut at %d: err = %v, want ErrTruncated", cut, err)
+		}
+	}
+
+	if _, err := open(key, []byte("plain text")); err == nil {
+		t.Error("unsealed data opened")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	hexKey := strings.Repeat("ab", KeySize)
+	if key, err := ParseKey(hexKey + "\n"); err != nil || key[0] != 0xab {
+		t.Errorf("hex key = %x, %v", key, err)
+	}
+	if _, err := ParseKey("q83vEjRWeJA="); err == nil {
+		t.Error("short key accepted")
+	}
+	if key, err := LoadKey("", ""); key != nil || err != nil {
+		t.Errorf("no key = %x, %v", key, err)
+	}
+}
@@ -0,0 +1,36 @@
+package selftest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunOffline(t *testing.T) {
+	report := Run(context.Background(), Options{})
+
+	if !report.Passed() {
+		var out strings.Builder
+		report.Write(&out)
+		t.Fatalf("self-test failed:\n%s", out.String())
+	}
+
+	// Without a probe proxy the live check is skipped, not failed
+	last := report.Checks[len(report.Checks)-1]
+	if last.Name != "live_probe" || !last.Skipped {
+		t.Errorf("live_probe check = %+v, want skipped", last)
+	}
+}
+
+func TestRunBadProbe(t *testing.T) {
+	report := Run(context.Background(), Options{ProbeProxy: "not a proxy"})
+	if report.Passed() {
+		t.Error("report passed with an unparseable probe proxy")
+	}
+
+	var out strings.Builder
+	report.Write(&out)
+	if !strings.Contains(out.String(), "✗ FAIL  live_probe") || !strings.Contains(out.String(), "1 of 4 checks failed") {
+		t.Errorf("report output:\n%s", out.String())
+	}
+}
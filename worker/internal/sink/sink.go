@@ -0,0 +1,231 @@
+// Package sink renders result batches through a user's Go template and
+// hands the output to a command or appends it to a file, for output formats
+// the worker does not write itself, such as XML or nmap target lists.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"dorker/worker/internal/engine"
+	"dorker/worker/internal/worker"
+)
+
+// Config describes a template sink. Template is required, with Command,
+// File or both.
+type Config struct {
+	Template string        // Go template file rendered once per batch
+	Command  string        // Shell command receiving each rendered batch on stdin
+	File     string        // File each rendered batch is appended to
+	Timeout  time.Duration // Limit for one Command run; 0 uses 30s
+}
+
+// Item is one URL of a batch
+type Item struct {
+	URL         string // As the engine linked it
+	Target      string // Where a resolved redirector link leads, else URL
+	Host        string // Target's host name
+	Title       string
+	Description string // Snippet shown on the results page
+	DisplayURL  string // URL as the results page shows it
+	Position    int    // Absolute SERP position
+	Type        string // Result type: organic, ad, featured_snippet or people_also_ask
+	Confidence  float64
+	Language    string
+	Files       []string            // Files listed when Target is a crawled directory listing
+	Matches     []engine.Match      // Verification patterns Target's page matched
+	Fingerprint *engine.Fingerprint // Target's host favicon hash and title, when taken
+	Network     *engine.Network     // Target's host addresses, AS and netblock, when looked up
+}
+
+// Batch is the data a template renders: one task's result
+type Batch struct {
+	TaskID  string
+	Dork    string
+	Status  string
+	ProxyID string
+	Cached  bool
+	Time    time.Time
+	URLs    []Item
+}
+
+// BatchFrom converts a worker result into a batch
+func BatchFrom(result *worker.Result) *Batch {
+	batch := &Batch{
+		TaskID:  result.TaskID,
+		Dork:    result.Dork,
+		Status:  string(result.Status),
+		ProxyID: result.ProxyID,
+		Cached:  result.Cached,
+		Time:    result.Timestamp,
+		URLs:    make([]Item, len(result.URLs)),
+	}
+	for i, u := range result.URLs {
+		target := u.Target()
+		var host string
+		if parsed, err := url.Parse(target); err == nil {
+			host = parsed.Hostname()
+		}
+		batch.URLs[i] = Item{
+			URL:         u.URL,
+			Target:      target,
+			Host:        host,
+			Title:       u.Title,
+			Description: u.Description,
+			DisplayURL:  u.DisplayURL,
+			Position:    u.Position,
+			Type:        u.Type,
+			Confidence:  u.Confidence,
+			Language:    u.Language,
+			Files:       u.Files,
+			Matches:     u.Matches,
+			Fingerprint: u.Fingerprint,
+			Network:     u.Network,
+		}
+	}
+	return batch
+}
+
+// funcs are the helpers templates may call besides the built-in ones
+var funcs = template.FuncMap{
+	"xml": func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+}
+
+// Sink renders batches and delivers them. Write is safe for concurrent use;
+// batches are delivered one at a time in the order written.
+type Sink struct {
+	config Config
+	tmpl   *template.Template
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// New parses the template and opens the file, writing the template's
+// "header" block when it defines one
+func New(config Config) (*Sink, error) {
+	if config.Template == "" {
+		return nil, fmt.Errorf("sink needs a template")
+	}
+	if config.Command == "" && config.File == "" {
+		return nil, fmt.Errorf("sink needs a command or a file")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	text, err := os.ReadFile(config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sink template: %w", err)
+	}
+	tmpl, err := template.New("batch").Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sink template: %w", err)
+	}
+
+	s := &Sink{config: config, tmpl: tmpl}
+	if config.File != "" {
+		f, err := os.OpenFile(config.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sink file: %w", err)
+		}
+		s.file = f
+		if err := s.writeBlock("header"); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// writeBlock appends a named template to the file when the template
+// defines it
+func (s *Sink) writeBlock(name string) error {
+	if s.tmpl.Lookup(name) == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&buf, name, nil); err != nil {
+		return fmt.Errorf("sink template %s: %w", name, err)
+	}
+	_, err := s.file.Write(buf.Bytes())
+	return err
+}
+
+// Write renders batch and delivers it. A batch rendering to nothing, such
+// as one without URLs for a template ranging over them, is skipped.
+func (s *Sink) Write(batch *Batch) error {
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, batch); err != nil {
+		return fmt.Errorf("sink template: %w", err)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		if _, err := s.file.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("sink file: %w", err)
+		}
+	}
+	if s.config.Command != "" {
+		return s.run(batch, buf.Bytes())
+	}
+	return nil
+}
+
+// run starts the command through the shell with the rendered batch on
+// stdin and the batch's task and dork in its environment
+func (s *Sink) run(batch *Batch, input []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", s.config.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", s.config.Command)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), "DORKER_TASK_ID="+batch.TaskID, "DORKER_DORK="+batch.Dork)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sink command: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Close writes the template's "footer" block, when it defines one, and
+// closes the file
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.writeBlock("footer")
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
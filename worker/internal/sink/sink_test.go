@@ -0,0 +1,99 @@
+package sink
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"dorker/worker/internal/engine"
+	"dorker/worker/internal/worker"
+)
+
+func writeTemplate(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sink.tmpl")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testResult(dork string, urls ...string) *worker.Result {
+	result := &worker.Result{TaskID: "t1", Dork: dork, Status: worker.StatusSuccess, Timestamp: time.Now()}
+	for i, u := range urls {
+		result.URLs = append(result.URLs, engine.SearchResult{URL: u, Position: i + 1})
+	}
+	return result
+}
+
+func TestSinkFile(t *testing.T) {
+	tmpl := writeTemplate(t, `{{define "header"}}<results>
+{{end}}{{define "footer"}}</results>
+{{end}}{{range .URLs}}<url dork="{{xml $.Dork}}" position="{{.Position}}" host="{{.Host}}">{{xml .Target}}</url>
+{{end}}`)
+	out := filepath.Join(t.TempDir(), "results.xml")
+
+	s, err := New(Config{Template: tmpl, File: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(BatchFrom(testResult(`intitle:"a&b"`, "https://a.example/x?y=1&z=2"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(BatchFrom(testResult("empty"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(out)
+	want := "<results>\n" +
+		`<url dork="intitle:&#34;a&amp;b&#34;" position="1" host="a.example">https://a.example/x?y=1&amp;z=2</url>` + "\n" +
+		"</results>\n"
+	if string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+}
+
+func TestSinkCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	tmpl := writeTemplate(t, `{{range .URLs}}{{.Host}}{{"\n"}}{{end}}`)
+	out := filepath.Join(t.TempDir(), "targets.txt")
+
+	s, err := New(Config{Template: tmpl, Command: `{ echo "# $DORKER_DORK"; cat; } >> ` + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write(BatchFrom(testResult("inurl:admin", "https://a.example/", "https://b.example/login"))); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(out)
+	if string(data) != "# inurl:admin\na.example\nb.example\n" {
+		t.Errorf("command output = %q", data)
+	}
+
+	failing, err := New(Config{Template: tmpl, Command: "echo broken >&2; exit 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failing.Write(BatchFrom(testResult("x", "https://a.example/"))); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("failing command err = %v", err)
+	}
+}
+
+func TestSinkConfig(t *testing.T) {
+	if _, err := New(Config{Template: writeTemplate(t, "{{.Dork}}")}); err == nil {
+		t.Error("sink without a command or file accepted")
+	}
+	if _, err := New(Config{Template: writeTemplate(t, "{{.Dork"), File: filepath.Join(t.TempDir(), "x")}); err == nil {
+		t.Error("broken template accepted")
+	}
+}
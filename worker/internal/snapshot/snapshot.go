@@ -0,0 +1,237 @@
+// Package snapshot stores the state of a running job in a single archive so
+// the job can continue on another machine.
+package snapshot
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/worker"
+)
+
+// formatVersion is bumped when the archive layout changes incompatibly
+const formatVersion = 1
+
+// Archive entries
+const (
+	entryManifest   = "manifest.json"
+	entryConfig     = "config.json"
+	entryTasks      = "tasks.jsonl"
+	entryProxies    = "proxies.json"
+	entryProxyState = "proxy_state.json"
+	entryDedup      = "dedup.bin"
+)
+
+// Snapshot is the state of a job: what it was told, what is left to do,
+// what it has seen and how its proxies stand
+type Snapshot struct {
+	Version string    // Worker version that wrote the snapshot
+	Created time.Time // When the snapshot was taken
+
+	// Config holds the job's init keys, without its proxies
+	Config map[string]any
+
+	// Tasks are the tasks not yet done, retries included
+	Tasks []*worker.Task
+
+	// Proxies and their standing; the list keeps credentials as the job
+	// loaded them
+	Proxies    []*proxy.Proxy
+	ProxyState []proxy.ProxyState
+
+	// Dedup is the dedup set as dedup.Export wrote it; nil when dedup is off
+	Dedup []byte
+
+	// Stats are the job's counters when the snapshot was taken, for
+	// reference
+	Stats worker.Stats
+}
+
+// manifest describes an archive
+type manifest struct {
+	Format  int          `json:"format"`
+	Version string       `json:"version"`
+	Created time.Time    `json:"created"`
+	Tasks   int          `json:"tasks"`
+	Proxies int          `json:"proxies"`
+	Dedup   bool         `json:"dedup"`
+	Stats   worker.Stats `json:"stats"`
+}
+
+// Write stores the snapshot as a zip archive at path. The archive holds
+// proxy credentials, so it is only readable by its owner; it replaces any
+// file at path only once complete.
+func Write(path string, snap *Snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp, snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// write writes the archive entries to w
+func write(w io.Writer, snap *Snapshot) error {
+	zw := zip.NewWriter(w)
+	addJSON := func(name string, v any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	if err := addJSON(entryManifest, manifest{
+		Format:  formatVersion,
+		Version: snap.Version,
+		Created: snap.Created,
+		Tasks:   len(snap.Tasks),
+		Proxies: len(snap.Proxies),
+		Dedup:   snap.Dedup != nil,
+		Stats:   snap.Stats,
+	}); err != nil {
+		return err
+	}
+	if err := addJSON(entryConfig, snap.Config); err != nil {
+		return err
+	}
+
+	f, err := zw.Create(entryTasks)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, task := range snap.Tasks {
+		if err := enc.Encode(task); err != nil {
+			return err
+		}
+	}
+
+	if f, err = zw.Create(entryProxies); err != nil {
+		return err
+	}
+	if err := proxy.WriteList(f, snap.Proxies, proxy.FormatJSON); err != nil {
+		return err
+	}
+	if err := addJSON(entryProxyState, snap.ProxyState); err != nil {
+		return err
+	}
+
+	if snap.Dedup != nil {
+		if f, err = zw.Create(entryDedup); err != nil {
+			return err
+		}
+		if _, err := f.Write(snap.Dedup); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// Read loads a snapshot archive written by Write
+func Read(path string) (*Snapshot, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	read := func(name string) ([]byte, error) {
+		f, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("snapshot has no %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	readJSON := func(name string, v any) error {
+		data, err := read(name)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("invalid %s in snapshot: %w", name, err)
+		}
+		return nil
+	}
+
+	var m manifest
+	if err := readJSON(entryManifest, &m); err != nil {
+		return nil, err
+	}
+	if m.Format != formatVersion {
+		return nil, fmt.Errorf("snapshot format %d is not supported (want %d)", m.Format, formatVersion)
+	}
+
+	snap := &Snapshot{Version: m.Version, Created: m.Created, Stats: m.Stats}
+	if err := readJSON(entryConfig, &snap.Config); err != nil {
+		return nil, err
+	}
+	if err := readJSON(entryProxyState, &snap.ProxyState); err != nil {
+		return nil, err
+	}
+
+	data, err := read(entryTasks)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var task worker.Task
+		if err := json.Unmarshal(scanner.Bytes(), &task); err != nil {
+			return nil, fmt.Errorf("invalid task in snapshot: %w", err)
+		}
+		snap.Tasks = append(snap.Tasks, &task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot tasks: %w", err)
+	}
+
+	if data, err = read(entryProxies); err != nil {
+		return nil, err
+	}
+	proxies, errs := proxy.NewParser().Parse(data, proxy.FormatJSON)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid proxy in snapshot: %w", errors.Join(errs...))
+	}
+	snap.Proxies = proxies
+
+	if m.Dedup {
+		if snap.Dedup, err = read(entryDedup); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
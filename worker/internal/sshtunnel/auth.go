@@ -0,0 +1,117 @@
+package sshtunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultKeys are the key files under ~/.ssh tried without a key file,
+// as ssh tries them
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// knownHosts returns the host key check of a known_hosts file, by default
+// ~/.ssh/known_hosts. Hosts missing from it are refused, as ssh does in
+// batch mode.
+func knownHosts(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("known_hosts: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// probeKey is a host key no known_hosts line holds, which makes a check
+// list the keys it knows for a host
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "probe" }
+func (probeKey) Marshal() []byte                     { return []byte("probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+
+// hostKeyAlgorithms returns the algorithms of the keys known_hosts holds
+// for addr, so the server is asked for one of those rather than a type it
+// prefers but known_hosts lacks. Nil, for an unknown host, keeps the
+// defaults and lets the check refuse the host.
+func hostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, &net.TCPAddr{IP: net.IPv4zero}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		if known.Key.Type() == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, known.Key.Type())
+	}
+	return algorithms
+}
+
+// authMethods returns the logins to offer: the key file alone when there
+// is one, as ssh -i with IdentitiesOnly, or else the SSH agent's keys and
+// the default key files. The returned func closes the agent connection.
+// Keys with a passphrase can only be used through the agent.
+func authMethods(keyFile string) ([]ssh.AuthMethod, func(), error) {
+	if keyFile != "" {
+		signer, err := readKey(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, func() {}, nil
+	}
+
+	var signers []ssh.Signer
+	closeAgent := func() {}
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			closeAgent = func() { conn.Close() }
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultKeys {
+			if signer, err := readKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		closeAgent()
+		return nil, nil, errors.New("no key to log in with: set a key file or load one into the SSH agent")
+	}
+	// One method for all keys, as the client tries each method once
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, closeAgent, nil
+}
+
+// readKey reads an unencrypted private key file
+func readKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("key %s has a passphrase: load it into the SSH agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", path, err)
+	}
+	return signer, nil
+}
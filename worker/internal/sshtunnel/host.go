@@ -0,0 +1,90 @@
+package sshtunnel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"dorker/worker/internal/proxy"
+)
+
+// Host is an SSH server to tunnel through
+type Host struct {
+	User    string
+	Name    string // Host name or address
+	Port    string
+	KeyFile string // Private key for this host; empty uses Config.KeyFile
+}
+
+// String returns the host as user@host:port
+func (h Host) String() string {
+	return h.User + "@" + net.JoinHostPort(h.Name, h.Port)
+}
+
+// ParseHost parses a host line: user@host[:port], or an ssh:// URL that
+// may name a key file:
+//
+//	deploy@203.0.113.7:2222
+//	ssh://deploy@203.0.113.8?key=${HOME}/.ssh/fleet_ed25519
+//
+// ${NAME} references are expanded as in proxy lines. The port defaults
+// to 22. Logins are by key, so a password is refused.
+func ParseHost(line string) (Host, error) {
+	expanded, err := proxy.ExpandEnv(strings.TrimSpace(line))
+	if err != nil {
+		return Host{}, err
+	}
+	if !strings.Contains(expanded, "://") {
+		expanded = "ssh://" + expanded
+	}
+
+	u, err := url.Parse(expanded)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" || u.User == nil || u.User.Username() == "" {
+		return Host{}, fmt.Errorf("invalid SSH host %q: want user@host[:port] or ssh://user@host[:port]", proxy.RedactCredentials(line))
+	}
+	if strings.HasPrefix(u.Hostname(), "-") {
+		return Host{}, fmt.Errorf("invalid SSH host name %q", u.Hostname())
+	}
+	if _, ok := u.User.Password(); ok {
+		return Host{}, fmt.Errorf("SSH host %s: passwords are not supported, log in with a key or the SSH agent", u.Hostname())
+	}
+
+	host := Host{User: u.User.Username(), Name: u.Hostname(), Port: u.Port(), KeyFile: u.Query().Get("key")}
+	if host.Port == "" {
+		host.Port = "22"
+	}
+	return host, nil
+}
+
+// ParseFile reads a file of host lines. Blank lines and lines starting
+// with # are skipped; errors name their line.
+func ParseFile(path string) ([]Host, []error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer f.Close()
+
+	var hosts []Host
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		host, err := ParseHost(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNum, err))
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return hosts, errs
+}
@@ -0,0 +1,156 @@
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol values, RFC 1928
+const (
+	socksVersion      = 0x05
+	socksNoAuth       = 0x00
+	socksNoAcceptable = 0xff
+	socksConnect      = 0x01
+	socksIPv4         = 0x01
+	socksDomain       = 0x03
+	socksIPv6         = 0x04
+
+	socksSucceeded          = 0x00
+	socksGeneralFailure     = 0x01
+	socksHostUnreachable    = 0x04
+	socksCommandUnsupported = 0x07
+	socksAddressUnsupported = 0x08
+)
+
+// errSOCKSReply is a request refused with a SOCKS5 reply code
+type errSOCKSReply byte
+
+func (e errSOCKSReply) Error() string {
+	return fmt.Sprintf("SOCKS5 reply %d", byte(e))
+}
+
+// forward serves one SOCKS5 connection: it reads the CONNECT, opens the
+// destination over the SSH connection and copies both ways until either
+// side closes. The handshake and the dial are bounded by Timeout.
+func (t *Tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.config.Timeout))
+
+	addr, err := socksRequest(conn)
+	if err != nil {
+		var reply errSOCKSReply
+		if errors.As(err, &reply) {
+			socksReply(conn, byte(reply))
+		}
+		return
+	}
+
+	client := t.currentClient()
+	if client == nil {
+		socksReply(conn, socksGeneralFailure)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.config.Timeout)
+	remote, err := client.DialContext(ctx, "tcp", addr)
+	cancel()
+	if err != nil {
+		socksReply(conn, socksHostUnreachable)
+		return
+	}
+	defer remote.Close()
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Either side closing ends the forward; the deferred closes then stop
+	// the other copy
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// socksRequest reads the greeting and the CONNECT request of a SOCKS5
+// client and returns the destination as host:port. Only CONNECT without
+// authentication is served.
+func socksRequest(conn net.Conn) (string, error) {
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return "", err
+	}
+	if greeting[0] != socksVersion {
+		return "", errors.New("not a SOCKS5 client")
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksNoAcceptable {
+		return "", errors.New("SOCKS5 client offered no method without authentication")
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[1] != socksConnect {
+		return "", errSOCKSReply(socksCommandUnsupported)
+	}
+	var host string
+	switch header[3] {
+	case socksIPv4, socksIPv6:
+		ip := make([]byte, net.IPv4len)
+		if header[3] == socksIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", errSOCKSReply(socksAddressUnsupported)
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))), nil
+}
+
+// socksReply answers a CONNECT; the bound address is left unspecified,
+// since the forward has none of its own
+func socksReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0x00, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
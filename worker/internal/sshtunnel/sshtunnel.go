@@ -0,0 +1,311 @@
+// Package sshtunnel turns SSH servers into SOCKS5 proxies. Each server gets
+// an SSH connection and a SOCKS5 server on a loopback port whose
+// connections are forwarded over it, as ssh -D does, so a fleet of servers
+// the user can log in to becomes a proxy source with nothing installed on
+// them. Connections that drop are made again. Like any ssh -D, the SOCKS5
+// servers take no credentials and are open to every local user.
+package sshtunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"dorker/worker/internal/proxy"
+)
+
+// Provider names the proxies tunnels create, see proxy.Proxy.Provider
+const Provider = "ssh"
+
+// Config holds the settings shared by all tunnels
+type Config struct {
+	KeyFile    string        // Private key for hosts without their own; empty uses the agent and ~/.ssh/id_*
+	KnownHosts string        // known_hosts file checking host keys; empty is ~/.ssh/known_hosts
+	Timeout    time.Duration // For connecting and logging in to a host
+	KeepAlive  time.Duration // Interval of keepalives finding dead connections
+
+	// Delay before reconnecting a tunnel whose connection dropped, doubled
+	// while reconnects keep failing up to MaxRestartDelay
+	RestartDelay    time.Duration
+	MaxRestartDelay time.Duration
+}
+
+// DefaultConfig returns the default tunnel settings
+func DefaultConfig() Config {
+	return Config{
+		Timeout:         15 * time.Second,
+		KeepAlive:       30 * time.Second,
+		RestartDelay:    5 * time.Second,
+		MaxRestartDelay: 2 * time.Minute,
+	}
+}
+
+// Tunnel is one SSH server served as a SOCKS5 proxy on a loopback port
+type Tunnel struct {
+	host     Host
+	config   Config
+	hostKeys ssh.HostKeyCallback
+	listener net.Listener
+	proxy    *proxy.Proxy
+
+	mu      sync.Mutex
+	client  *ssh.Client // nil while reconnecting
+	closed  bool
+	quit    chan struct{} // Closed by Close
+	stopped sync.WaitGroup
+}
+
+// Open connects to host and serves the SOCKS5 proxy. A host that cannot
+// be reached or refuses the login is an error, so a bad entry is reported
+// at start; after that, the connection is made again whenever it drops,
+// until Close.
+func Open(host Host, config Config) (*Tunnel, error) {
+	defaults := DefaultConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.RestartDelay <= 0 {
+		config.RestartDelay = defaults.RestartDelay
+	}
+	if config.MaxRestartDelay < config.RestartDelay {
+		config.MaxRestartDelay = max(defaults.MaxRestartDelay, config.RestartDelay)
+	}
+
+	hostKeys, err := knownHosts(config.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tunnel{host: host, config: config, hostKeys: hostKeys, quit: make(chan struct{})}
+	client, err := t.connect()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	t.client = client
+	t.listener = listener
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	t.proxy = &proxy.Proxy{
+		// The ID names the server, not the port, which changes every run
+		ID:       "ssh_" + host.String(),
+		Host:     "127.0.0.1",
+		Port:     port,
+		Type:     proxy.ProxyTypeSOCKS5,
+		Status:   proxy.ProxyStatusUnknown,
+		Provider: Provider,
+		Metadata: map[string]string{"ssh": host.String()},
+	}
+	t.stopped.Add(2)
+	go t.serve()
+	go t.supervise(client)
+	return t, nil
+}
+
+// Proxy returns the tunnel's SOCKS5 proxy
+func (t *Tunnel) Proxy() *proxy.Proxy {
+	return t.proxy
+}
+
+// Close stops the SOCKS5 server and closes the connection, ending the
+// forwarded connections with it
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.quit)
+	client := t.client
+	t.mu.Unlock()
+
+	t.listener.Close()
+	if client != nil {
+		client.Close()
+	}
+	t.stopped.Wait()
+	return nil
+}
+
+// connect dials the host and logs in
+func (t *Tunnel) connect() (*ssh.Client, error) {
+	keyFile := t.host.KeyFile
+	if keyFile == "" {
+		keyFile = t.config.KeyFile
+	}
+	auth, closeAuth, err := authMethods(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAuth()
+
+	addr := net.JoinHostPort(t.host.Name, t.host.Port)
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:              t.host.User,
+		Auth:              auth,
+		HostKeyCallback:   t.hostKeys,
+		HostKeyAlgorithms: hostKeyAlgorithms(t.hostKeys, addr),
+		Timeout:           t.config.Timeout,
+	})
+}
+
+// currentClient returns the connection forwards go over, nil while it is
+// being made again
+func (t *Tunnel) currentClient() *ssh.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.client
+}
+
+// supervise makes the connection again whenever it drops, waiting longer
+// while reconnects keep failing. SOCKS5 connections meanwhile fail, and
+// the pool rests the proxy as it would any other.
+func (t *Tunnel) supervise(client *ssh.Client) {
+	defer t.stopped.Done()
+	delay := t.config.RestartDelay
+	for {
+		t.watch(client)
+		t.mu.Lock()
+		t.client = nil
+		t.mu.Unlock()
+
+		for {
+			select {
+			case <-t.quit:
+				return
+			case <-time.After(delay):
+			}
+			var err error
+			if client, err = t.connect(); err == nil {
+				delay = t.config.RestartDelay
+				break
+			}
+			delay = min(delay*2, t.config.MaxRestartDelay)
+		}
+
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			client.Close()
+			return
+		}
+		t.client = client
+		t.mu.Unlock()
+	}
+}
+
+// watch returns once the connection drops or, with KeepAlive set, stops
+// answering keepalives within Timeout, which it then closes
+func (t *Tunnel) watch(client *ssh.Client) {
+	dropped := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(dropped)
+	}()
+	if t.config.KeepAlive <= 0 {
+		<-dropped
+		return
+	}
+
+	ticker := time.NewTicker(t.config.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-dropped:
+			return
+		case <-ticker.C:
+		}
+		answered := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+		select {
+		case err := <-answered:
+			if err == nil {
+				continue
+			}
+		case <-time.After(t.config.Timeout):
+		}
+		client.Close()
+		<-dropped
+		return
+	}
+}
+
+// serve accepts SOCKS5 connections until Close
+func (t *Tunnel) serve() {
+	defer t.stopped.Done()
+	for {
+		conn, err := t.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		go t.forward(conn)
+	}
+}
+
+// Tunnels runs a tunnel per host, as a proxy source for the pool
+type Tunnels struct {
+	tunnels []*Tunnel
+}
+
+// Start opens a tunnel to each host at once. Hosts that fail are reported
+// and left out.
+func Start(hosts []Host, config Config) (*Tunnels, []error) {
+	opened := make([]*Tunnel, len(hosts))
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host Host) {
+			defer wg.Done()
+			opened[i], errs[i] = Open(host, config)
+		}(i, host)
+	}
+	wg.Wait()
+
+	tunnels := &Tunnels{}
+	var failed []error
+	for i := range hosts {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		tunnels.tunnels = append(tunnels.tunnels, opened[i])
+	}
+	return tunnels, failed
+}
+
+// Proxies returns the tunnels' SOCKS5 proxies, in host order
+func (ts *Tunnels) Proxies() []*proxy.Proxy {
+	if ts == nil {
+		return nil
+	}
+	proxies := make([]*proxy.Proxy, len(ts.tunnels))
+	for i, t := range ts.tunnels {
+		proxies[i] = t.Proxy()
+	}
+	return proxies
+}
+
+// Close stops every tunnel
+func (ts *Tunnels) Close() {
+	if ts == nil {
+		return
+	}
+	for _, t := range ts.tunnels {
+		t.Close()
+	}
+}
@@ -0,0 +1,362 @@
+package sshtunnel
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"dorker/worker/internal/tunnel"
+)
+
+// sshServer is an SSH server taking one client key and serving
+// direct-tcpip channels, as sshd does for ssh -D
+type sshServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newSSHServer(t *testing.T, clientKey ssh.PublicKey) *sshServer {
+	t.Helper()
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &sshServer{addr: listener.Addr().String(), hostKey: signer.PublicKey()}
+	t.Cleanup(func() {
+		listener.Close()
+		server.drop()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go serveSSH(conn, config)
+		}
+	}()
+	return server
+}
+
+// drop closes every connection, as a server going away does
+func (s *sshServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			go io.Copy(remote, channel)
+			io.Copy(channel, remote)
+		}()
+	}
+}
+
+// echoServer returns the address of a server echoing what it reads
+func echoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// clientKey writes a new private key file and returns its public key
+func clientKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sshKey, _ := ssh.NewPublicKey(public)
+	return path, sshKey
+}
+
+// knownHostsFile writes a known_hosts file holding the server's key
+func knownHostsFile(t *testing.T, server *sshServer) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serverHost returns the Host entry of the server
+func serverHost(server *sshServer) Host {
+	name, port, _ := net.SplitHostPort(server.addr)
+	return Host{User: "deploy", Name: name, Port: port}
+}
+
+// echoThrough sends a line to the echo server through the tunnel's proxy
+func echoThrough(proxyAddr, echoAddr string) error {
+	conn, err := net.DialTimeout("tcp", proxyAddr, time.Second)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err = tunnel.Open(ctx, conn, &url.URL{Scheme: "socks5"}, echoAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		return err
+	}
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if string(reply) != "ping\n" {
+		return errors.New("echo returned " + string(reply))
+	}
+	return nil
+}
+
+func TestParseHost(t *testing.T) {
+	t.Setenv("TEST_SSH_KEY", "/keys/fleet")
+	tests := []struct {
+		line string
+		want Host
+	}{
+		{"deploy@203.0.113.7", Host{User: "deploy", Name: "203.0.113.7", Port: "22"}},
+		{"deploy@vps.example.com:2222", Host{User: "deploy", Name: "vps.example.com", Port: "2222"}},
+		{"ssh://root@[2001:db8::1]:22?key=${TEST_SSH_KEY}", Host{User: "root", Name: "2001:db8::1", Port: "22", KeyFile: "/keys/fleet"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseHost(tt.line)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHost(%q) = %+v, %v; want %+v", tt.line, got, err, tt.want)
+		}
+	}
+
+	for _, line := range []string{"203.0.113.7", "http://deploy@203.0.113.7", "deploy:secret@203.0.113.7", "deploy@-oProxyCommand=x"} {
+		_, err := ParseHost(line)
+		if err == nil {
+			t.Errorf("ParseHost(%q) succeeded", line)
+		} else if strings.Contains(err.Error(), "secret") {
+			t.Errorf("ParseHost(%q) error leaks the password: %v", line, err)
+		}
+	}
+}
+
+func TestOpenForwards(t *testing.T) {
+	keyFile, key := clientKey(t)
+	server := newSSHServer(t, key)
+	config := Config{KeyFile: keyFile, KnownHosts: knownHostsFile(t, server), Timeout: 5 * time.Second}
+
+	tunnel, err := Open(serverHost(server), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+
+	p := tunnel.Proxy()
+	if p.ID != "ssh_deploy@"+server.addr || p.Provider != Provider || p.Host != "127.0.0.1" {
+		t.Errorf("proxy = %+v", p)
+	}
+	proxyAddr := net.JoinHostPort(p.Host, p.Port)
+
+	// Host names are resolved at the server's end
+	_, echoPort, _ := net.SplitHostPort(echoServer(t))
+	if err := echoThrough(proxyAddr, net.JoinHostPort("localhost", echoPort)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A destination the server cannot reach fails the CONNECT alone
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+	if err := echoThrough(proxyAddr, closed.Addr().String()); err == nil || !strings.Contains(err.Error(), "SOCKS5") {
+		t.Errorf("CONNECT to a closed port = %v, want a SOCKS5 failure", err)
+	}
+	if err := echoThrough(proxyAddr, net.JoinHostPort("127.0.0.1", echoPort)); err != nil {
+		t.Errorf("after a failed CONNECT: %v", err)
+	}
+}
+
+func TestOpenReconnects(t *testing.T) {
+	keyFile, key := clientKey(t)
+	server := newSSHServer(t, key)
+	config := Config{KeyFile: keyFile, KnownHosts: knownHostsFile(t, server), Timeout: 5 * time.Second, RestartDelay: 100 * time.Millisecond}
+
+	tunnel, err := Open(serverHost(server), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+	proxyAddr := net.JoinHostPort(tunnel.Proxy().Host, tunnel.Proxy().Port)
+	echoAddr := echoServer(t)
+
+	// The connection drops; forwards come back on the same port
+	server.drop()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := echoThrough(proxyAddr, echoAddr)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("forward was not restored: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Close ends the SOCKS5 server
+	tunnel.Close()
+	if conn, err := net.Dial("tcp", proxyAddr); err == nil {
+		conn.Close()
+		t.Error("SOCKS5 port still open after Close")
+	}
+}
+
+func TestOpenFails(t *testing.T) {
+	keyFile, key := clientKey(t)
+	server := newSSHServer(t, key)
+	host := serverHost(server)
+	knownHosts := knownHostsFile(t, server)
+	config := Config{KeyFile: keyFile, KnownHosts: knownHosts, Timeout: 5 * time.Second}
+
+	// A host missing from known_hosts is refused
+	empty := filepath.Join(t.TempDir(), "known_hosts")
+	os.WriteFile(empty, nil, 0o600)
+	unknown := config
+	unknown.KnownHosts = empty
+	if _, err := Open(host, unknown); err == nil {
+		t.Error("Open of a host missing from known_hosts succeeded")
+	}
+
+	// So is a key the server does not take
+	otherKey, _ := clientKey(t)
+	wrongKey := config
+	wrongKey.KeyFile = otherKey
+	if _, err := Open(host, wrongKey); err == nil {
+		t.Error("Open with a key the server refuses succeeded")
+	}
+
+	// A host's own key wins over the shared one
+	host.KeyFile = keyFile
+	tunnel, err := Open(host, wrongKey)
+	if err != nil {
+		t.Fatalf("Open with the host's own key: %v", err)
+	}
+	tunnel.Close()
+
+	missing := config
+	missing.KnownHosts = filepath.Join(t.TempDir(), "missing")
+	if _, err := Open(host, missing); err == nil {
+		t.Error("Open with a missing known_hosts file succeeded")
+	}
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	_, key := clientKey(t)
+	server := newSSHServer(t, key)
+	callback, err := knownHosts(knownHostsFile(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the ed25519 key is known, so only it is asked for
+	if got := hostKeyAlgorithms(callback, server.addr); len(got) != 1 || got[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("hostKeyAlgorithms = %q, want [%s]", got, ssh.KeyAlgoED25519)
+	}
+	if got := hostKeyAlgorithms(callback, "203.0.113.7:22"); got != nil {
+		t.Errorf("hostKeyAlgorithms of an unknown host = %q, want nil", got)
+	}
+}
@@ -1,6 +1,7 @@
 package stealth
 
 import (
+	"hash/fnv"
 	"math/rand"
 	"sync"
 	"time"
@@ -23,6 +24,8 @@ const (
 	OSWindows OSType = "windows"
 	OSMacOS   OSType = "macos"
 	OSLinux   OSType = "linux"
+	OSAndroid OSType = "android"
+	OSiOS     OSType = "ios"
 )
 
 // Fingerprint represents a browser fingerprint for stealth requests
@@ -47,12 +50,49 @@ type Fingerprint struct {
 type Manager struct {
 	mu           sync.RWMutex
 	fingerprints []*Fingerprint
+	mobile       []*Fingerprint // Phone browsers, for mobile searches
 	rng          *rand.Rand
 
 	// Settings
 	rotateEvery    int // Rotate fingerprint every N requests
 	requestCounter int
 	current        *Fingerprint
+
+	// Sessions by key, derived from seed
+	seed     int64
+	sessions map[string]*Session
+}
+
+// Session is one consistent browser: the same fingerprint on every request
+// and its own pace between them. It is derived from the manager's seed and
+// the session key, so one seed gives a key the same session in every run.
+type Session struct {
+	Fingerprint *Fingerprint
+	Mobile      *Fingerprint // The session's phone, for mobile searches
+	Pace        float64      // Multiplier on the base delay, from 0.8 to 1.25
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// Headers returns HTTP headers for the session's fingerprint
+func (s *Session) Headers() map[string]string {
+	return headersFor(s.Fingerprint)
+}
+
+// MobileHeaders returns HTTP headers for the session's phone
+func (s *Session) MobileHeaders() map[string]string {
+	return headersFor(s.Mobile)
+}
+
+// Delay returns a randomized delay between the session's requests, with
+// the base delay scaled by its pace
+func (s *Session) Delay(config TimingConfig) time.Duration {
+	config.BaseDelay = time.Duration(float64(config.BaseDelay) * s.Pace)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CalculateDelay(config, s.rng)
 }
 
 // NewManager creates a new stealth manager
@@ -61,10 +101,13 @@ func NewManager() *Manager {
 		fingerprints: make([]*Fingerprint, 0),
 		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
 		rotateEvery:  100,
+		seed:         time.Now().UnixNano(),
+		sessions:     make(map[string]*Session),
 	}
 
 	// Load default fingerprints
 	m.loadDefaultFingerprints()
+	m.loadMobileFingerprints()
 
 	// Set initial fingerprint
 	if len(m.fingerprints) > 0 {
@@ -208,6 +251,61 @@ func (m *Manager) loadDefaultFingerprints() {
 	}
 }
 
+// loadMobileFingerprints loads phone browser fingerprints, which get
+// Google's mobile layout
+func (m *Manager) loadMobileFingerprints() {
+	m.mobile = []*Fingerprint{
+		// Chrome on Android
+		{
+			ID:              "chrome_android_120",
+			Browser:         BrowserChrome,
+			BrowserVersion:  "120.0.0.0",
+			OS:              OSAndroid,
+			OSVersion:       "10",
+			UserAgent:       "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			AcceptLanguage:  "en-US,en;q=0.9",
+			AcceptEncoding:  "gzip, deflate, br",
+			Accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8",
+			SecChUa:         `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+			SecChUaPlatform: `"Android"`,
+			SecChUaMobile:   "?1",
+			JA3:             "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513,29-23-24,0",
+		},
+		// Safari on iPhone
+		{
+			ID:              "safari_ios_17",
+			Browser:         BrowserSafari,
+			BrowserVersion:  "17.1",
+			OS:              OSiOS,
+			OSVersion:       "17.1",
+			UserAgent:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			AcceptLanguage:  "en-US,en;q=0.9",
+			AcceptEncoding:  "gzip, deflate, br",
+			Accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			SecChUa:         "",
+			SecChUaPlatform: "",
+			SecChUaMobile:   "",
+			JA3:             "771,4865-4866-4867-49196-49195-52393-49200-49199-52392-49162-49161-49172-49171-157-156-53-47-49160-49170-10,0-23-65281-10-11-16-5-13-18-51-45-43-27-21,29-23-24-25,0",
+		},
+		// Firefox on Android
+		{
+			ID:              "firefox_android_121",
+			Browser:         BrowserFirefox,
+			BrowserVersion:  "121.0",
+			OS:              OSAndroid,
+			OSVersion:       "14",
+			UserAgent:       "Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			AcceptLanguage:  "en-US,en;q=0.5",
+			AcceptEncoding:  "gzip, deflate, br",
+			Accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			SecChUa:         "",
+			SecChUaPlatform: "",
+			SecChUaMobile:   "",
+			JA3:             "771,4865-4867-4866-49195-49199-52393-52392-49196-49200-49162-49161-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-34-51-43-13-45-28-21,29-23-24-25-256-257,0",
+		},
+	}
+}
+
 // GetFingerprint returns the current fingerprint, rotating if necessary
 func (m *Manager) GetFingerprint() *Fingerprint {
 	m.mu.Lock()
@@ -260,11 +358,63 @@ func (m *Manager) AddFingerprint(fp *Fingerprint) {
 	m.fingerprints = append(m.fingerprints, fp)
 }
 
+// SetSeed sets the seed sessions are derived from, dropping the sessions
+// derived so far
+func (m *Manager) SetSeed(seed int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seed = seed
+	m.sessions = make(map[string]*Session)
+}
+
+// Seed returns the seed sessions are derived from
+func (m *Manager) Seed() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.seed
+}
+
+// Session returns the session for key, such as a proxy ID, deriving it on
+// first use
+func (m *Manager) Session(key string) *Session {
+	m.mu.RLock()
+	session, ok := m.sessions[key]
+	m.mu.RUnlock()
+	if ok {
+		return session
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if session, ok := m.sessions[key]; ok {
+		return session
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	rng := rand.New(rand.NewSource(m.seed ^ int64(h.Sum64())))
+
+	session = &Session{Pace: 0.8 + rng.Float64()*0.45, rng: rng}
+	if len(m.fingerprints) > 0 {
+		session.Fingerprint = m.fingerprints[rng.Intn(len(m.fingerprints))]
+	}
+	if len(m.mobile) > 0 {
+		session.Mobile = m.mobile[rng.Intn(len(m.mobile))]
+	}
+	m.sessions[key] = session
+	return session
+}
+
 // GetHeaders returns HTTP headers for the current fingerprint
 func (m *Manager) GetHeaders() map[string]string {
-	fp := m.GetFingerprint()
+	return headersFor(m.GetFingerprint())
+}
+
+// headersFor returns HTTP headers for a fingerprint, or fallback headers
+// without one
+func headersFor(fp *Fingerprint) map[string]string {
 	if fp == nil {
-		return m.getDefaultHeaders()
+		return defaultHeaders()
 	}
 
 	headers := map[string]string{
@@ -290,8 +440,8 @@ func (m *Manager) GetHeaders() map[string]string {
 	return headers
 }
 
-// getDefaultHeaders returns fallback headers
-func (m *Manager) getDefaultHeaders() map[string]string {
+// defaultHeaders returns fallback headers
+func defaultHeaders() map[string]string {
 	return map[string]string{
 		"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8",
@@ -1,6 +1,7 @@
 package stealth
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -370,6 +371,74 @@ func TestFingerprintUserAgents(t *testing.T) {
 	}
 }
 
+func TestManagerSession(t *testing.T) {
+	m := NewManager()
+	m.SetSeed(42)
+
+	session := m.Session("proxy_1")
+	if session.Fingerprint == nil {
+		t.Fatal("session has no fingerprint")
+	}
+	if session.Pace < 0.8 || session.Pace > 1.25 {
+		t.Errorf("Pace = %v, want 0.8 to 1.25", session.Pace)
+	}
+	if m.Session("proxy_1") != session {
+		t.Error("a key should keep its session")
+	}
+	if session.Headers()["User-Agent"] != session.Fingerprint.UserAgent {
+		t.Error("session headers should carry its fingerprint's User-Agent")
+	}
+	if session.Mobile == nil || session.Mobile.OS != OSAndroid && session.Mobile.OS != OSiOS {
+		t.Fatalf("session phone = %+v", session.Mobile)
+	}
+	if session.MobileHeaders()["User-Agent"] != session.Mobile.UserAgent {
+		t.Error("mobile headers should carry the session phone's User-Agent")
+	}
+
+	// The same seed derives the same session in another run
+	other := NewManager()
+	other.SetSeed(42)
+	if again := other.Session("proxy_1"); again.Fingerprint.ID != session.Fingerprint.ID || again.Pace != session.Pace {
+		t.Errorf("seed 42 derived %s at %v, then %s at %v", session.Fingerprint.ID, session.Pace, again.Fingerprint.ID, again.Pace)
+	}
+
+	// Different keys get different browsers
+	browsers := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		browsers[m.Session(fmt.Sprintf("proxy_%d", i)).Fingerprint.ID] = true
+	}
+	if len(browsers) < 3 {
+		t.Errorf("50 sessions used %d fingerprints", len(browsers))
+	}
+
+	// A new seed derives sessions afresh
+	m.SetSeed(43)
+	if m.Session("proxy_1") == session {
+		t.Error("SetSeed should drop earlier sessions")
+	}
+}
+
+func TestSessionDelay(t *testing.T) {
+	m := NewManager()
+	config := TimingConfig{
+		BaseDelay:     10 * time.Second,
+		MinDelay:      time.Second,
+		MaxDelay:      time.Minute,
+		JitterPercent: 0.1,
+	}
+
+	for i := 0; i < 20; i++ {
+		session := m.Session(fmt.Sprintf("proxy_%d", i))
+		base := float64(config.BaseDelay) * session.Pace
+		for j := 0; j < 10; j++ {
+			delay := float64(session.Delay(config))
+			if delay < base*0.9 || delay > base*1.1 {
+				t.Fatalf("delay %v outside 10%% of the session's base %v", time.Duration(delay), time.Duration(base))
+			}
+		}
+	}
+}
+
 func TestMathFunctions(t *testing.T) {
 	// Test sqrt
 	sqrt4 := math_Sqrt(4)
@@ -0,0 +1,219 @@
+// Package tor uses a local Tor client as a proxy. Searches go through its
+// SOCKS port, and a burned exit is replaced by asking the client, over its
+// control port, for new circuits with the NEWNYM signal. Tor ignores the
+// signal more often than every NewnymInterval, so rotations are spaced at
+// least that far apart.
+package tor
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"dorker/worker/internal/proxy"
+)
+
+// Provider names the proxy of a Tor client, see proxy.Proxy.Provider
+const Provider = "tor"
+
+// NewnymInterval is how often Tor honours NEWNYM
+const NewnymInterval = 10 * time.Second
+
+// Config locates a Tor client
+type Config struct {
+	SOCKSAddr   string // SocksPort
+	ControlAddr string // ControlPort; empty leaves circuits alone
+	Password    string // For HashedControlPassword; empty uses the auth cookie or none
+}
+
+// DefaultConfig returns the ports of a default Tor install
+func DefaultConfig() Config {
+	return Config{
+		SOCKSAddr:   "127.0.0.1:9050",
+		ControlAddr: "127.0.0.1:9051",
+	}
+}
+
+// Proxy returns the proxy of the client's SOCKS port
+func Proxy(config Config) (*proxy.Proxy, error) {
+	host, port, err := net.SplitHostPort(config.SOCKSAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Tor SOCKS address %q: %w", config.SOCKSAddr, err)
+	}
+	return &proxy.Proxy{
+		ID:       "tor_" + config.SOCKSAddr,
+		Host:     host,
+		Port:     port,
+		Type:     proxy.ProxyTypeSOCKS5,
+		Status:   proxy.ProxyStatusUnknown,
+		Provider: Provider,
+	}, nil
+}
+
+// Controller signals a Tor client over its control port. It connects for
+// each signal, so a restarted client is no concern.
+type Controller struct {
+	addr     string
+	password string
+	timeout  time.Duration
+}
+
+// NewController returns a controller for the client's control port
+func NewController(config Config) *Controller {
+	return &Controller{addr: config.ControlAddr, password: config.Password, timeout: 10 * time.Second}
+}
+
+// Check logs in to the control port, so a wrong port or password shows
+// at start
+func (c *Controller) Check(ctx context.Context) error {
+	return c.do(ctx)
+}
+
+// Rotate sends NEWNYM: new requests get new circuits, and so most likely
+// a new exit
+func (c *Controller) Rotate(ctx context.Context) error {
+	return c.do(ctx, "SIGNAL NEWNYM")
+}
+
+// do logs in and sends commands, each of which must be answered 250
+func (c *Controller) do(ctx context.Context, commands ...string) error {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("tor control port: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	session := &session{conn: conn, reader: bufio.NewReader(conn)}
+	if err := session.authenticate(c.password); err != nil {
+		return fmt.Errorf("tor control port: %w", err)
+	}
+	for _, command := range commands {
+		if _, err := session.command(command); err != nil {
+			return fmt.Errorf("tor control port: %s: %w", command, err)
+		}
+	}
+	session.command("QUIT")
+	return nil
+}
+
+// session is one connection to the control port
+type session struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// command sends a command and returns its reply lines, without their
+// status codes; a status other than 250 is an error
+func (s *session) command(command string) ([]string, error) {
+	if _, err := fmt.Fprintf(s.conn, "%s\r\n", command); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if line[:3] != "250" {
+			return nil, fmt.Errorf("%s", line)
+		}
+		lines = append(lines, line[4:])
+		// "250 " ends the reply; "250-" and "250+" continue it
+		if line[3] == ' ' {
+			return lines, nil
+		}
+	}
+}
+
+// authenticate logs in with the password when there is one, else with the
+// auth cookie when the client offers cookie authentication, else with none
+func (s *session) authenticate(password string) error {
+	if password != "" {
+		_, err := s.command("AUTHENTICATE " + quote(password))
+		return err
+	}
+
+	info, err := s.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	methods, cookieFile := parseProtocolInfo(info)
+	if methods["NULL"] {
+		_, err = s.command("AUTHENTICATE")
+		return err
+	}
+	if !methods["COOKIE"] || cookieFile == "" {
+		return fmt.Errorf("the control port wants a password")
+	}
+	cookie, err := os.ReadFile(cookieFile)
+	if err != nil {
+		return fmt.Errorf("reading the auth cookie: %w", err)
+	}
+	_, err = s.command("AUTHENTICATE " + hex.EncodeToString(cookie))
+	return err
+}
+
+// parseProtocolInfo reads the auth methods and cookie file from a
+// PROTOCOLINFO reply line such as
+//
+//	AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/run/tor/control.authcookie"
+func parseProtocolInfo(lines []string) (methods map[string]bool, cookieFile string) {
+	methods = make(map[string]bool)
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		for _, field := range strings.Fields(line[len("AUTH "):]) {
+			if list, ok := strings.CutPrefix(field, "METHODS="); ok {
+				for _, method := range strings.Split(list, ",") {
+					methods[method] = true
+				}
+			}
+		}
+		if _, rest, ok := strings.Cut(line, `COOKIEFILE="`); ok {
+			cookieFile = unquote(rest)
+		}
+	}
+	return methods, cookieFile
+}
+
+// quote writes a control protocol quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// unquote reads a quoted string up to its closing quote, the opening one
+// already consumed
+func unquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package tor
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeControl is a Tor control port accepting one way to log in
+type fakeControl struct {
+	listener net.Listener
+	auth     string // Expected AUTHENTICATE line
+	info     string // PROTOCOLINFO AUTH line
+
+	mu      sync.Mutex
+	signals []string
+}
+
+func newFakeControl(t *testing.T, auth, info string) *fakeControl {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeControl{listener: listener, auth: auth, info: info}
+	go f.serve()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeControl) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeControl) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PROTOCOLINFO 1":
+			conn.Write([]byte("250-PROTOCOLINFO 1\r\n250-" + f.info + "\r\n250-VERSION Tor=\"0.4.8.10\"\r\n250 OK\r\n"))
+		case strings.HasPrefix(line, "AUTHENTICATE"):
+			if line != f.auth {
+				conn.Write([]byte("515 Authentication failed\r\n"))
+				return
+			}
+			authenticated = true
+			conn.Write([]byte("250 OK\r\n"))
+		case !authenticated:
+			conn.Write([]byte("514 Authentication required.\r\n"))
+			return
+		case line == "QUIT":
+			conn.Write([]byte("250 closing connection\r\n"))
+			return
+		default:
+			f.mu.Lock()
+			f.signals = append(f.signals, line)
+			f.mu.Unlock()
+			conn.Write([]byte("250 OK\r\n"))
+		}
+	}
+}
+
+func TestControllerCookie(t *testing.T) {
+	cookie := []byte("0123456789abcdef0123456789abcdef")
+	cookieFile := filepath.Join(t.TempDir(), "control.authcookie")
+	if err := os.WriteFile(cookieFile, cookie, 0600); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeControl(t, "AUTHENTICATE "+hex.EncodeToString(cookie),
+		`AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="`+cookieFile+`"`)
+
+	controller := NewController(Config{ControlAddr: fake.listener.Addr().String()})
+	if err := controller.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if err := controller.Rotate(context.Background()); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if len(fake.signals) != 1 || fake.signals[0] != "SIGNAL NEWNYM" {
+		t.Errorf("signals = %q, want one NEWNYM", fake.signals)
+	}
+}
+
+func TestControllerPassword(t *testing.T) {
+	fake := newFakeControl(t, `AUTHENTICATE "pa\"ss"`, "AUTH METHODS=HASHEDPASSWORD")
+	addr := fake.listener.Addr().String()
+
+	if err := NewController(Config{ControlAddr: addr, Password: `pa"ss`}).Rotate(context.Background()); err != nil {
+		t.Errorf("Rotate with the password: %v", err)
+	}
+	if err := NewController(Config{ControlAddr: addr}).Rotate(context.Background()); err == nil || !strings.Contains(err.Error(), "password") {
+		t.Errorf("Rotate without a password = %v, want a password error", err)
+	}
+	if err := NewController(Config{ControlAddr: addr, Password: "wrong"}).Rotate(context.Background()); err == nil || !strings.Contains(err.Error(), "515") {
+		t.Errorf("Rotate with a wrong password = %v, want the 515", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	p, err := Proxy(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "tor_127.0.0.1:9050" || p.Type != "socks5" || p.Provider != Provider {
+		t.Errorf("proxy = %+v", p)
+	}
+	if _, err := Proxy(Config{SOCKSAddr: "9050"}); err == nil {
+		t.Error("Proxy accepted an address without a host")
+	}
+}
@@ -0,0 +1,255 @@
+// Package tunnel opens TCP tunnels through a proxy over a connection
+// already made to it: an HTTP CONNECT, over TLS for an https:// proxy, or a
+// SOCKS5 CONNECT. The egress layer and proxy chains both build on it.
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Open tunnels to addr through the proxy at the other end of conn, by the
+// protocol its URL names: http, https or socks5. Credentials come from the
+// URL. The handshake is bounded by ctx; the tunnel itself is not. conn is
+// closed when the handshake fails.
+func Open(ctx context.Context, conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var tunneled net.Conn
+	var err error
+	switch proxyURL.Scheme {
+	case "http", "https":
+		tunneled, err = connect(ctx, conn, proxyURL, addr)
+	case "socks5":
+		tunneled, err = socks5(conn, proxyURL.User, addr)
+	default:
+		err = fmt.Errorf("cannot tunnel through a %s proxy", proxyURL.Scheme)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return tunneled, nil
+}
+
+// DefaultPort returns a proxy's port, defaulting it by scheme
+func DefaultPort(proxyURL *url.URL) string {
+	if port := proxyURL.Port(); port != "" {
+		return port
+	}
+	switch proxyURL.Scheme {
+	case "https":
+		return "443"
+	case "socks5":
+		return "1080"
+	}
+	return "80"
+}
+
+// connect sends an HTTP CONNECT, with Basic Proxy-Authorization when the
+// URL carries credentials
+func connect(ctx context.Context, conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a tunnel the proxy already sent data through along with
+// its CONNECT response
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// SOCKS5 protocol constants, RFC 1928 and RFC 1929
+const (
+	socksVersion      = 0x05
+	socksNoAuth       = 0x00
+	socksUserPass     = 0x02
+	socksNoAcceptable = 0xff
+	socksConnect      = 0x01
+	socksIPv4         = 0x01
+	socksDomain       = 0x03
+	socksIPv6         = 0x04
+)
+
+// socksReplies names the SOCKS5 reply codes
+var socksReplies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5 sends a SOCKS5 CONNECT, offering username/password
+// authentication when there are credentials. Host names are passed on for
+// the proxy to resolve.
+func socks5(conn net.Conn, user *url.Userinfo, addr string) (net.Conn, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port in %s", addr)
+	}
+
+	methods := []byte{socksNoAuth}
+	if user != nil {
+		methods = []byte{socksNoAuth, socksUserPass}
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != socksVersion {
+		return nil, errors.New("not a SOCKS5 proxy")
+	}
+	switch reply[1] {
+	case socksNoAuth:
+	case socksUserPass:
+		if user == nil {
+			return nil, errors.New("SOCKS5 proxy wants credentials")
+		}
+		if err := socksAuthenticate(conn, user); err != nil {
+			return nil, err
+		}
+	case socksNoAcceptable:
+		return nil, errors.New("SOCKS5 proxy accepted no authentication method")
+	default:
+		return nil, fmt.Errorf("SOCKS5 proxy chose unknown method %d", reply[1])
+	}
+
+	request := []byte{socksVersion, socksConnect, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name too long: %s", host)
+		}
+		request = append(request, socksDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(append(request, socksIPv4), ip4...)
+	} else {
+		request = append(append(request, socksIPv6), ip...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	// The reply ends with the bound address, whose length its type gives
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if header[1] != 0x00 {
+		reason := socksReplies[header[1]]
+		if reason == "" {
+			reason = fmt.Sprintf("reply %d", header[1])
+		}
+		return nil, fmt.Errorf("SOCKS5 CONNECT %s: %s", addr, reason)
+	}
+	var bound int
+	switch header[3] {
+	case socksIPv4:
+		bound = net.IPv4len
+	case socksIPv6:
+		bound = net.IPv6len
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, err
+		}
+		bound = int(length[0])
+	default:
+		return nil, fmt.Errorf("SOCKS5 reply with unknown address type %d", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, bound+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// socksAuthenticate runs the username/password subnegotiation
+func socksAuthenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("SOCKS5 credentials too long")
+	}
+
+	request := []byte{0x01, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("SOCKS5 authentication failed")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// socksServer is a SOCKS5 proxy wanting user:secret, recording the
+// destination of its last CONNECT and connecting it to an echo
+func socksServer(t *testing.T) (string, chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	targets := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(conn, targets)
+		}
+	}()
+	return listener.Addr().String(), targets
+}
+
+func serveSOCKS(conn net.Conn, targets chan string) {
+	defer conn.Close()
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return
+	}
+	io.ReadFull(conn, make([]byte, greeting[1]))
+	conn.Write([]byte{socksVersion, socksUserPass})
+
+	// Username/password subnegotiation
+	header := make([]byte, 2)
+	io.ReadFull(conn, header)
+	username := make([]byte, header[1])
+	io.ReadFull(conn, username)
+	length := make([]byte, 1)
+	io.ReadFull(conn, length)
+	password := make([]byte, length[0])
+	io.ReadFull(conn, password)
+	if string(username) != "user" || string(password) != "secret" {
+		conn.Write([]byte{0x01, 0x01})
+		return
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	request := make([]byte, 5)
+	if _, err := io.ReadFull(conn, request); err != nil || request[3] != socksDomain {
+		return
+	}
+	host := make([]byte, request[4])
+	io.ReadFull(conn, host)
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+	targets <- net.JoinHostPort(string(host), strconv.Itoa(int(port[0])<<8|int(port[1])))
+
+	conn.Write([]byte{socksVersion, 0x00, 0x00, socksIPv4, 127, 0, 0, 1, 0, 0})
+	io.Copy(conn, conn)
+}
+
+func TestOpenSOCKS5(t *testing.T) {
+	addr, targets := socksServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL := &url.URL{Scheme: "socks5", Host: addr, User: url.UserPassword("user", "secret")}
+	tunneled, err := Open(context.Background(), conn, proxyURL, "example.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunneled.Close()
+	if target := <-targets; target != "example.com:443" {
+		t.Errorf("CONNECT to %s, want example.com:443", target)
+	}
+
+	tunneled.Write([]byte("ping"))
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(tunneled, echo); err != nil || string(echo) != "ping" {
+		t.Errorf("echo = %q, %v", echo, err)
+	}
+
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL.User = url.UserPassword("user", "wrong")
+	if _, err := Open(context.Background(), conn, proxyURL, "example.com:443"); err == nil {
+		t.Error("Open with wrong credentials succeeded")
+	}
+}
+
+func TestOpenUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	if _, err := Open(context.Background(), client, &url.URL{Scheme: "socks4", Host: "h:1080"}, "example.com:80"); err == nil {
+		t.Error("Open through socks4 succeeded")
+	}
+}
+
+func TestDefaultPort(t *testing.T) {
+	for scheme, want := range map[string]string{"http": "80", "https": "443", "socks5": "1080"} {
+		if got := DefaultPort(&url.URL{Scheme: scheme, Host: "proxy"}); got != want {
+			t.Errorf("DefaultPort(%s) = %s, want %s", scheme, got, want)
+		}
+	}
+	if got := DefaultPort(&url.URL{Scheme: "http", Host: "proxy:3128"}); got != "3128" {
+		t.Errorf("DefaultPort with port = %s", got)
+	}
+}
@@ -0,0 +1,233 @@
+// Package verify confirms results by their content: it fetches each result
+// page and looks for user-given keywords or regular expressions, such as
+// an SQL error message or a phpMyAdmin banner, quoting what it found.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"dorker/worker/internal/engine"
+)
+
+// Config describes result verification; it is off without patterns
+type Config struct {
+	// Patterns are matched against each result page: keywords ignoring
+	// case and how their words are spaced, or regular expressions when
+	// prefixed with "re:"
+	Patterns []string `json:"patterns,omitempty"`
+
+	// Require drops results whose page matches no pattern, or cannot be
+	// fetched, so only confirmed hits are reported
+	Require bool `json:"require"`
+
+	// Concurrency bounds the pages of one results page fetched at once
+	Concurrency int `json:"concurrency"`
+
+	// MaxBytes bounds what is read of each page
+	MaxBytes int64 `json:"max_bytes"`
+
+	// Timeout bounds the fetch of one page
+	Timeout time.Duration `json:"timeout"`
+}
+
+// Defaults for unset Config fields
+const (
+	DefaultConcurrency = 5
+	DefaultMaxBytes    = 1 << 20
+	DefaultTimeout     = 15 * time.Second
+)
+
+// regexPrefix marks a pattern as a regular expression
+const regexPrefix = "re:"
+
+// snippetContext is how many bytes of the page a snippet shows on each
+// side of a match
+const snippetContext = 60
+
+// Validate checks that every regular expression compiles
+func (c Config) Validate() error {
+	_, err := compile(c.Patterns)
+	return err
+}
+
+// pattern is a compiled pattern and how it was given
+type pattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+func compile(patterns []string) ([]pattern, error) {
+	var compiled []pattern
+	for _, source := range patterns {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		expr := keyword(source)
+		if raw, ok := strings.CutPrefix(source, regexPrefix); ok {
+			expr = raw
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("verify pattern %q: %w", source, err)
+		}
+		compiled = append(compiled, pattern{source: source, re: re})
+	}
+	return compiled, nil
+}
+
+// keyword returns the expression matching a keyword ignoring case, its
+// words separated by any white space, as pages wrap and indent text
+func keyword(source string) string {
+	words := strings.Fields(source)
+	for i, word := range words {
+		words[i] = regexp.QuoteMeta(word)
+	}
+	return `(?i)` + strings.Join(words, `\s+`)
+}
+
+// Verifier fetches result pages and matches them against the patterns.
+// It is safe for concurrent use.
+type Verifier struct {
+	patterns    []pattern
+	require     bool
+	concurrency int
+	maxBytes    int64
+	timeout     time.Duration
+}
+
+// New returns the verifier described by config, or nil when it has no
+// patterns. Patterns that do not compile are left out; Validate reports
+// them.
+func New(config Config) *Verifier {
+	var patterns []pattern
+	for _, source := range config.Patterns {
+		if compiled, err := compile([]string{source}); err == nil {
+			patterns = append(patterns, compiled...)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	v := &Verifier{
+		patterns:    patterns,
+		require:     config.Require,
+		concurrency: config.Concurrency,
+		maxBytes:    config.MaxBytes,
+		timeout:     config.Timeout,
+	}
+	if v.concurrency <= 0 {
+		v.concurrency = DefaultConcurrency
+	}
+	if v.maxBytes <= 0 {
+		v.maxBytes = DefaultMaxBytes
+	}
+	if v.timeout <= 0 {
+		v.timeout = DefaultTimeout
+	}
+	return v
+}
+
+// Require reports whether results without a match are dropped
+func (v *Verifier) Require() bool {
+	return v != nil && v.require
+}
+
+// Check fetches target through transport and returns the patterns its
+// page matches, each with a snippet around its first match. The page is
+// matched whatever its status, as error pages are often the hit.
+func (v *Verifier) Check(ctx context.Context, transport http.RoundTripper, target string) ([]engine.Match, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	client := &http.Client{Transport: transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, v.maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", target, err)
+	}
+	return v.Match(string(body)), nil
+}
+
+// Match returns the patterns page matches, in pattern order
+func (v *Verifier) Match(page string) []engine.Match {
+	var matches []engine.Match
+	for _, p := range v.patterns {
+		loc := p.re.FindStringIndex(page)
+		if loc == nil {
+			continue
+		}
+		matches = append(matches, engine.Match{
+			Pattern: p.source,
+			Snippet: snippet(page, loc[0], loc[1]),
+		})
+	}
+	return matches
+}
+
+var (
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// snippet returns the text around page[start:end], markup and runs of
+// white space collapsed to single spaces
+func snippet(page string, start, end int) string {
+	from := max(start-snippetContext, 0)
+	for from > 0 && !utf8.RuneStart(page[from]) {
+		from--
+	}
+	to := min(end+snippetContext, len(page))
+	for to < len(page) && !utf8.RuneStart(page[to]) {
+		to++
+	}
+
+	// A tag cut by the window's edge is dropped as well
+	head := page[from:start]
+	if close := strings.IndexByte(head, '>'); close >= 0 && !strings.Contains(head[:close], "<") {
+		from += close + 1
+	}
+	tail := page[end:to]
+	if open := strings.LastIndexByte(tail, '<'); open >= 0 && !strings.Contains(tail[open:], ">") {
+		to = end + open
+	}
+
+	text := tagPattern.ReplaceAllString(page[from:to], " ")
+	text = spacePattern.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+// Each runs check on every result index with at most Concurrency calls
+// at once, returning when all are done
+func (v *Verifier) Each(n int, check func(i int)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, v.concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			check(i)
+		}(i)
+	}
+	wg.Wait()
+}
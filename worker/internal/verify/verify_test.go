@@ -0,0 +1,105 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	if New(Config{}) != nil {
+		t.Error("config without patterns should give a nil verifier")
+	}
+	if New(Config{Patterns: []string{" ", "re:("}}) != nil {
+		t.Error("config without valid patterns should give a nil verifier")
+	}
+	var disabled *Verifier
+	if disabled.Require() {
+		t.Error("nil verifier should require nothing")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Config{Patterns: []string{"phpMyAdmin", `re:mysql_\w+\(`}}).Validate(); err != nil {
+		t.Errorf("valid patterns: %v", err)
+	}
+	err := (Config{Patterns: []string{"ok", "re:[a-"}}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "re:[a-") {
+		t.Errorf("invalid regex error = %v, want one naming the pattern", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	v := New(Config{Patterns: []string{"SQL syntax", `re:mysql_\w+\(\)`, "phpMyAdmin"}})
+
+	page := `<html><body><div class="error">
+		<b>Warning</b>: You have an error in your sql   syntax; check the manual
+		that corresponds to your MySQL server version</div>
+		<p>called from mysql_fetch_array() in /var/www/index.php</p></body></html>`
+	matches := v.Match(page)
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2: %+v", len(matches), matches)
+	}
+	if matches[0].Pattern != "SQL syntax" || matches[1].Pattern != `re:mysql_\w+\(\)` {
+		t.Errorf("patterns = %q, %q; want them in pattern order", matches[0].Pattern, matches[1].Pattern)
+	}
+	// Snippets read as text around the match
+	for _, m := range matches {
+		if strings.ContainsAny(m.Snippet, "<>\n\t") || strings.Contains(m.Snippet, "  ") {
+			t.Errorf("snippet %q keeps markup or white space runs", m.Snippet)
+		}
+	}
+	if !strings.Contains(matches[0].Snippet, "error in your sql syntax") {
+		t.Errorf("snippet %q does not show the match", matches[0].Snippet)
+	}
+
+	if got := v.Match("<html>nothing here</html>"); len(got) != 0 {
+		t.Errorf("unrelated page matched %+v", got)
+	}
+}
+
+func TestSnippetRuneBoundaries(t *testing.T) {
+	page := strings.Repeat("é", 100) + "needle" + strings.Repeat("ü", 100)
+	start := strings.Index(page, "needle")
+	got := snippet(page, start, start+len("needle"))
+	if !strings.Contains(got, "needle") || !strings.HasPrefix(got, "é") || !strings.HasSuffix(got, "ü") {
+		t.Errorf("snippet = %q, want whole runes around the match", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// An error page is a hit like any other
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "<h1>Welcome to phpMyAdmin</h1>")
+	}))
+	defer server.Close()
+
+	v := New(Config{Patterns: []string{"phpmyadmin"}})
+	matches, err := v.Check(context.Background(), http.DefaultTransport, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Snippet != "Welcome to phpMyAdmin" {
+		t.Errorf("matches = %+v, want the phpMyAdmin banner", matches)
+	}
+}
+
+func TestCheckMaxBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 100)+"phpMyAdmin")
+	}))
+	defer server.Close()
+
+	v := New(Config{Patterns: []string{"phpMyAdmin"}, MaxBytes: 50})
+	matches, err := v.Check(context.Background(), http.DefaultTransport, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("matched %+v past MaxBytes", matches)
+	}
+}
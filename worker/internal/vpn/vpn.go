@@ -0,0 +1,100 @@
+// Package vpn rotates the exit of a VPN the worker uses as one proxy, such
+// as a WireGuard or OpenVPN client container serving an HTTP or SOCKS
+// proxy. Switching servers is left to the user's tooling: a shell command,
+// such as wg-quick bringing up another peer, or a request to the VPN
+// client's control API. The pool calls it when the exit is burned.
+package vpn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"dorker/worker/internal/proxy"
+)
+
+// Provider names the proxy of a VPN, see proxy.Proxy.Provider
+const Provider = "vpn"
+
+// New returns the rotator for a setting: an http:// or https:// URL,
+// optionally preceded by a method, is requested (POST by default) and must
+// answer 2xx; anything else is a shell command that must exit 0. Either
+// must return once the new exit is up.
+//
+//	wg-quick down wg0 && wg-quick up wg1
+//	PUT http://127.0.0.1:8000/v1/openvpn/status
+func New(setting string) (proxy.Rotator, error) {
+	setting = strings.TrimSpace(setting)
+	if setting == "" {
+		return nil, fmt.Errorf("empty VPN rotation setting")
+	}
+
+	method, target := http.MethodPost, setting
+	if fields := strings.Fields(setting); len(fields) == 2 && strings.Contains(fields[1], "://") {
+		method, target = strings.ToUpper(fields[0]), fields[1]
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return Command(setting), nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid VPN rotation URL %q", proxy.RedactCredentials(target))
+	}
+	return &API{Method: method, URL: u}, nil
+}
+
+// Command is a shell command rotating the exit
+type Command string
+
+// Rotate runs the command through the shell
+func (c Command) Rotate(ctx context.Context) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", string(c))
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", string(c))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rotation command: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// API is a control API request rotating the exit. Credentials in the URL
+// are sent as basic auth.
+type API struct {
+	Method string
+	URL    *url.URL
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Rotate makes the request
+func (a *API) Rotate(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, a.Method, a.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("rotation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("rotation request: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dorker/worker/internal/engine"
+)
+
+// Alert events sent while a run continues
+const (
+	AlertParserDegraded  = "parser_degraded"  // The canary dork stopped yielding results
+	AlertParserRecovered = "parser_recovered" // The canary dork yields results again
+	AlertFinding         = "finding"          // A result matched an alert rule
+)
+
+// Alert reports a condition the user should hear about without the run
+// stopping
+type Alert struct {
+	Event     string    `json:"event"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// canaryTaskID identifies canary requests in the audit log and HTML dump
+const canaryTaskID = "canary"
+
+// canaryQuorum is how many distinct proxies must get an empty but otherwise
+// clean canary page before the parser is declared degraded, so one proxy
+// served odd markup does not raise the alert
+const canaryQuorum = 3
+
+// runCanary searches for the canary dork every CanaryInterval until the
+// worker stops or the run ends
+func (w *Worker) runCanary(interval time.Duration) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-w.runCtx.Done():
+			return
+		case <-ticker.C:
+			w.checkCanary()
+		}
+	}
+}
+
+// checkCanary runs the canary dork once through the pool. Failed requests,
+// CAPTCHAs and blocks say nothing about the parser and are ignored.
+func (w *Worker) checkCanary() {
+	cfg := w.currentConfig()
+	google := w.engine.(*engine.Google)
+
+	prx, err := w.pool.Get()
+	if err != nil {
+		return
+	}
+
+	task := &Task{ID: canaryTaskID, Dork: cfg.CanaryDork}
+	searchURL := w.searchURL(cfg.CanaryDork, 0, prx)
+
+	ctx, cancel := context.WithTimeout(w.runCtx, cfg.RequestTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	statusCode, html, err := w.makeRequest(ctx, task, searchURL, prx)
+	duration := time.Since(startTime)
+
+	switch {
+	case err != nil:
+		w.recordRequest(task, searchURL, prx, statusCode, StatusError, err, duration)
+		return
+	case google.DetectCaptcha(html):
+		w.recordRequest(task, searchURL, prx, statusCode, StatusCaptcha, nil, duration)
+		return
+	case google.DetectBlock(html):
+		w.recordRequest(task, searchURL, prx, statusCode, StatusBlocked, nil, duration)
+		return
+	}
+
+	results := google.ParseResults(html)
+	w.recordRequest(task, searchURL, prx, statusCode, StatusSuccess, nil, duration)
+	w.observeCanary(prx.ID, len(results))
+}
+
+// observeCanary records how many results the canary dork yielded through a
+// proxy and raises an alert when the parser's state changes
+func (w *Worker) observeCanary(proxyID string, found int) {
+	expected := max(w.currentConfig().CanaryMinResults, 1)
+
+	w.canaryMu.Lock()
+	var alert *Alert
+	if found >= expected {
+		clear(w.canaryEmpty)
+		if w.canaryDegraded {
+			w.canaryDegraded = false
+			alert = &Alert{
+				Event:   AlertParserRecovered,
+				Message: fmt.Sprintf("canary dork yields %d results again", found),
+			}
+		}
+	} else {
+		w.canaryEmpty[proxyID] = true
+
+		// Small pools cannot supply a full quorum
+		quorum := min(canaryQuorum, max(w.pool.Stats().Alive, 1))
+		if !w.canaryDegraded && len(w.canaryEmpty) >= quorum {
+			w.canaryDegraded = true
+			alert = &Alert{
+				Event: AlertParserDegraded,
+				Message: fmt.Sprintf("canary dork returned %d results (expected at least %d) on %d proxies with HTTP 200; the results page markup may have changed",
+					found, expected, len(w.canaryEmpty)),
+			}
+		}
+	}
+	w.canaryMu.Unlock()
+
+	if alert != nil {
+		alert.Timestamp = time.Now()
+		if w.onAlert != nil {
+			w.onAlert(*alert)
+		}
+	}
+}
+
+// ParserDegraded reports whether the canary currently finds the parser
+// degraded
+func (w *Worker) ParserDegraded() bool {
+	w.canaryMu.Lock()
+	defer w.canaryMu.Unlock()
+	return w.canaryDegraded
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"dorker/worker/internal/proxy"
+)
+
+func newCanaryWorker(t *testing.T, proxies int) (*Worker, *[]Alert) {
+	t.Helper()
+
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	for i := 0; i < proxies; i++ {
+		pool.AddProxy(&proxy.Proxy{ID: fmt.Sprintf("p%d", i), Host: "127.0.0.1", Port: fmt.Sprint(8000 + i), Type: proxy.ProxyTypeHTTP})
+	}
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.CanaryMinResults = 5
+	w := New(config, pool)
+
+	var alerts []Alert
+	w.SetAlertHandler(func(alert Alert) { alerts = append(alerts, alert) })
+	return w, &alerts
+}
+
+func TestCanaryNeedsQuorum(t *testing.T) {
+	w, alerts := newCanaryWorker(t, 10)
+
+	// Repeated empty pages through one proxy are not enough
+	for i := 0; i < 5; i++ {
+		w.observeCanary("p0", 0)
+	}
+	w.observeCanary("p1", 0)
+	if len(*alerts) != 0 || w.ParserDegraded() {
+		t.Fatalf("alerts = %+v before quorum", *alerts)
+	}
+
+	w.observeCanary("p2", 0)
+	if len(*alerts) != 1 || (*alerts)[0].Event != AlertParserDegraded {
+		t.Fatalf("alerts = %+v, want parser_degraded", *alerts)
+	}
+	if !w.ParserDegraded() {
+		t.Error("ParserDegraded = false after the alert")
+	}
+
+	// Raised once per degradation
+	w.observeCanary("p3", 0)
+	if len(*alerts) != 1 {
+		t.Errorf("alerts = %d, want 1", len(*alerts))
+	}
+}
+
+func TestCanaryRecovery(t *testing.T) {
+	w, alerts := newCanaryWorker(t, 1)
+
+	// A single-proxy pool cannot supply three proxies
+	w.observeCanary("p0", 0)
+	w.observeCanary("p0", 12)
+	if len(*alerts) != 2 || (*alerts)[1].Event != AlertParserRecovered {
+		t.Fatalf("alerts = %+v, want degraded then recovered", *alerts)
+	}
+	if w.ParserDegraded() {
+		t.Error("ParserDegraded = true after recovery")
+	}
+
+	// Healthy results clear the empty-page count
+	w.observeCanary("p0", 12)
+	if len(*alerts) != 2 {
+		t.Errorf("alerts = %d, want 2", len(*alerts))
+	}
+}
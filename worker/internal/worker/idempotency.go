@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+)
+
+// Key returns the task's idempotency key: IdempotencyKey when set,
+// otherwise a hash of what the task asks for, so a resent copy of a task
+// has the same key whatever its ID
+func (t *Task) Key() string {
+	if t.IdempotencyKey != "" {
+		return t.IdempotencyKey
+	}
+
+	request := *t
+	request.ID = ""
+	request.Retry = 0
+	request.Deadline = time.Time{}
+	if request.Type == "" {
+		request.Type = TaskTypeSearch
+	}
+	data, _ := json.Marshal(request)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// idemEntry is a task seen within the idempotency window
+type idemEntry struct {
+	seen    time.Time
+	result  *Result  // nil while the task runs
+	waiters []string // IDs of copies submitted while it ran
+}
+
+// idemSeen records when a key was seen, in order, for expiry
+type idemSeen struct {
+	key  string
+	seen time.Time
+}
+
+// admit reports whether a submitted task should run. A copy of a task seen
+// within IdempotencyWindow does not: it is answered with the earlier
+// task's result, at once when that has finished or as soon as it does.
+func (w *Worker) admit(task *Task) bool {
+	window := w.currentConfig().IdempotencyWindow
+	if window <= 0 {
+		return true
+	}
+
+	key := task.Key()
+	now := time.Now()
+
+	w.idemMu.Lock()
+	w.expireIdem(now, window)
+
+	entry, ok := w.idemTasks[key]
+	if !ok {
+		w.idemTasks[key] = &idemEntry{seen: now}
+		w.idemOrder = append(w.idemOrder, idemSeen{key: key, seen: now})
+		w.idemRunning[task.ID] = key
+		w.idemMu.Unlock()
+		return true
+	}
+
+	atomic.AddInt64(&w.stats.DuplicateTasks, 1)
+	if entry.result == nil {
+		entry.waiters = append(entry.waiters, task.ID)
+		w.idemMu.Unlock()
+		return false
+	}
+	result := entry.result
+	w.idemMu.Unlock()
+
+	w.sendResult(duplicateResult(result, task.ID))
+	return false
+}
+
+// settle records a task's final result for later copies and returns the
+// results of the copies waiting on it. Only results worth repeating are
+// kept; after a failure a copy runs again.
+func (w *Worker) settle(result *Result) []*Result {
+	w.idemMu.Lock()
+	key, ok := w.idemRunning[result.TaskID]
+	if !ok {
+		w.idemMu.Unlock()
+		return nil
+	}
+	delete(w.idemRunning, result.TaskID)
+
+	entry := w.idemTasks[key]
+	if entry == nil {
+		w.idemMu.Unlock()
+		return nil
+	}
+	waiters := entry.waiters
+	entry.waiters = nil
+	if result.Status == StatusSuccess || result.Status == StatusNoResults {
+		// The window runs from when the result is known
+		entry.result = result
+		entry.seen = time.Now()
+		w.idemOrder = append(w.idemOrder, idemSeen{key: key, seen: entry.seen})
+	} else {
+		delete(w.idemTasks, key)
+	}
+	w.idemMu.Unlock()
+
+	duplicates := make([]*Result, len(waiters))
+	for i, id := range waiters {
+		duplicates[i] = duplicateResult(result, id)
+	}
+	return duplicates
+}
+
+// forget drops a task that was admitted but never ran
+func (w *Worker) forget(task *Task) {
+	w.idemMu.Lock()
+	defer w.idemMu.Unlock()
+
+	if key, ok := w.idemRunning[task.ID]; ok {
+		delete(w.idemRunning, task.ID)
+		delete(w.idemTasks, key)
+	}
+}
+
+// expireIdem forgets results older than window; the caller holds idemMu.
+// A task still running is kept until it finishes, so its copies are
+// answered.
+func (w *Worker) expireIdem(now time.Time, window time.Duration) {
+	for len(w.idemOrder) > 0 {
+		oldest := w.idemOrder[0]
+		if now.Sub(oldest.seen) < window {
+			break
+		}
+		w.idemOrder = w.idemOrder[1:]
+		if entry := w.idemTasks[oldest.key]; entry != nil && entry.seen.Equal(oldest.seen) && entry.result != nil {
+			delete(w.idemTasks, oldest.key)
+		}
+	}
+}
+
+// duplicateResult copies a result for a resent task
+func duplicateResult(result *Result, taskID string) *Result {
+	duplicate := *result
+	duplicate.TaskID = taskID
+	duplicate.Duplicate = true
+	return &duplicate
+}
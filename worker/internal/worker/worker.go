@@ -1,18 +1,44 @@
 package worker
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"dorker/worker/internal/alert"
+	"dorker/worker/internal/asn"
+	"dorker/worker/internal/audit"
+	"dorker/worker/internal/cache"
+	"dorker/worker/internal/classify"
+	"dorker/worker/internal/cost"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/dirlist"
+	"dorker/worker/internal/egress"
 	"dorker/worker/internal/engine"
+	"dorker/worker/internal/fingerprint"
+	"dorker/worker/internal/htmldump"
+	"dorker/worker/internal/lang"
+	"dorker/worker/internal/pacing"
+	"dorker/worker/internal/params"
 	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/reqtrace"
+	"dorker/worker/internal/resolve"
+	"dorker/worker/internal/scope"
 	"dorker/worker/internal/stealth"
+	"dorker/worker/internal/tor"
+	"dorker/worker/internal/verify"
 )
 
 // Config holds worker configuration
@@ -34,6 +60,137 @@ type Config struct {
 	// Results
 	ResultsPerPage int `json:"results_per_page"`
 	MaxPages       int `json:"max_pages"`
+
+	// WarmUp staggers worker start-up evenly over this period so a run does
+	// not open with a burst of simultaneous requests
+	WarmUp time.Duration `json:"warm_up"`
+
+	// Deadlines; zero disables. TaskTimeout bounds each task across its
+	// retries, MaxRunDuration the whole run from the first Start.
+	TaskTimeout    time.Duration `json:"task_timeout"`
+	MaxRunDuration time.Duration `json:"max_run_duration"`
+
+	// Request budgets; zero disables. Every attempt, retries included,
+	// counts. Engine budgets are keyed by engine name, with "fetch" for
+	// fetch tasks.
+	MaxRequests       int            `json:"max_requests"`
+	EngineMaxRequests map[string]int `json:"engine_max_requests,omitempty"`
+
+	// Costs prices requests for the EstimatedCost stat; empty disables
+	Costs cost.Model `json:"costs"`
+
+	// Dedup describes the run-wide URL dedup; the set itself is supplied
+	// with SetDedup
+	Dedup dedup.Config `json:"dedup"`
+
+	// ParseWorkers parse fetched results pages so fetch goroutines can move
+	// on to their next request; 0 runs one per CPU
+	ParseWorkers int `json:"parse_workers"`
+
+	// EnrichWorkers make the requests parsed pages need, so parse workers
+	// only parse: classifying ambiguous pages, resolving, verifying,
+	// crawling, fingerprinting and looking up results, and the retries
+	// these lead to; 0 runs as many as Workers
+	EnrichWorkers int `json:"enrich_workers"`
+
+	// Canary: every CanaryInterval the worker searches CanaryDork, which
+	// should always yield at least CanaryMinResults results, and raises
+	// AlertParserDegraded when clean pages on several proxies yield fewer.
+	// An empty dork or zero interval disables it.
+	CanaryDork       string        `json:"canary_dork"`
+	CanaryInterval   time.Duration `json:"canary_interval"`
+	CanaryMinResults int           `json:"canary_min_results"`
+
+	// Classifier describes the external page classifier consulted when the
+	// heuristics cannot tell what a page without results is; the classifier
+	// itself is supplied with SetClassifier
+	Classifier classify.Config `json:"classifier"`
+
+	// GeoMatch searches on the Google domain, hl, gl and Accept-Language of
+	// each proxy's exit country, where a check has found it
+	GeoMatch bool `json:"geo_match"`
+
+	// MinConfidence drops extracted URLs scoring below it, for tasks that
+	// do not set their own; zero keeps every URL
+	MinConfidence float64 `json:"min_confidence"`
+
+	// Assets tunes the filter dropping scripts, stylesheets, images and CDN
+	// URLs from extracted results
+	Assets engine.AssetConfig `json:"assets"`
+
+	// SERPFeatures names the SERP features (video, maps, news, images,
+	// questions, or all) whose links are reported; links in other features
+	// are dropped as non-organic
+	SERPFeatures []string `json:"serp_features,omitempty"`
+
+	// Mobile searches with each proxy session's phone user agent and
+	// parses Google's mobile layout
+	Mobile bool `json:"mobile"`
+
+	// OrganicOnly drops ads, featured snippets and "People also ask" links
+	// from extracted results
+	OrganicOnly bool `json:"organic_only"`
+
+	// Scope limits reported URLs to these host patterns ("target.com",
+	// "*.target.com") for tasks that set none; empty reports every URL
+	Scope []string `json:"scope,omitempty"`
+
+	// Title filters for tasks that set none: a result is kept only when its
+	// title contains one of TitleInclude, if any, and none of TitleExclude.
+	// Matching ignores case.
+	TitleInclude []string `json:"title_include,omitempty"`
+	TitleExclude []string `json:"title_exclude,omitempty"`
+
+	// DetectLanguage tags results with the language of their title and
+	// description. Languages keeps only results detected in one of these
+	// languages (ISO 639-1 codes) for tasks that set none, and implies
+	// detection; results whose language cannot be told are kept.
+	DetectLanguage bool     `json:"detect_language"`
+	Languages      []string `json:"languages,omitempty"`
+
+	// Resolve follows redirector links (bit.ly, lnkd.in, ...) among the
+	// results to their final URL through the proxy pool
+	Resolve resolve.Config `json:"resolve"`
+
+	// Crawl lists the files of open directory listings ("Index of /...")
+	// among the results, crawling each through the proxy pool
+	Crawl dirlist.Config `json:"crawl"`
+
+	// Verify fetches every result page through the proxy pool and looks for
+	// keywords or regular expressions on it, optionally dropping results
+	// that show none
+	Verify verify.Config `json:"verify"`
+
+	// Fingerprint takes the favicon hash and root page title of every
+	// result host once per run, through the proxy pool
+	Fingerprint fingerprint.Config `json:"fingerprint"`
+
+	// Networks resolves every result host once per run and looks up the
+	// AS and netblock announcing it
+	Networks asn.Config `json:"networks"`
+
+	// Alerts raises an alert, and notifies the rule's channels, for every
+	// result matching an alert rule as results come in
+	Alerts alert.Config `json:"alerts"`
+
+	// Cache serves a search task from a parsed page of an identical search
+	// made within its TTL, instead of a request
+	Cache cache.Config `json:"cache"`
+
+	// IdempotencyWindow answers a task submitted again within it, such as
+	// one a controller resends after reconnecting, with the first copy's
+	// result instead of running it twice; 0 runs every task
+	IdempotencyWindow time.Duration `json:"idempotency_window"`
+
+	// Seed derives each proxy's browser session: the fingerprint, headers
+	// and pace it keeps for the run. One seed gives a proxy the same session
+	// in every run; 0 picks a random seed, which Config then reports.
+	Seed int64 `json:"seed"`
+
+	// TraceRate is the percent of requests timed phase by phase (DNS,
+	// connect, TLS, time to first byte), the timings going out with their
+	// task's result; 0 traces none
+	TraceRate float64 `json:"trace_rate"`
 }
 
 // DefaultConfig returns sensible defaults
@@ -52,12 +209,52 @@ func DefaultConfig() Config {
 	}
 }
 
+// TaskType identifies what a task asks the worker to do
+type TaskType string
+
+const (
+	TaskTypeSearch TaskType = "search" // Run a dork against the search engine
+	TaskTypeFetch  TaskType = "fetch"  // Retrieve an arbitrary URL through the proxy pool
+)
+
 // Task represents a single dork query task
 type Task struct {
-	ID    string `json:"id"`
-	Dork  string `json:"dork"`
-	Page  int    `json:"page"`
-	Retry int    `json:"retry"`
+	ID    string   `json:"id"`
+	Type  TaskType `json:"type,omitempty"`
+	Dork  string   `json:"dork"`
+	URL   string   `json:"url,omitempty"`
+	Page  int      `json:"page"`
+	Retry int      `json:"retry"`
+
+	// Deadline abandons the task with StatusTimeout once passed. When zero
+	// it is set from TaskTimeout the first time the task is processed.
+	Deadline time.Time `json:"deadline,omitempty"`
+
+	// MinConfidence overrides Config.MinConfidence for this task when set
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+
+	// Scope overrides Config.Scope for this task when set
+	Scope []string `json:"scope,omitempty"`
+
+	// DedupGranularity overrides Config.Dedup.Granularity for this task
+	// when set
+	DedupGranularity dedup.Granularity `json:"dedup_granularity,omitempty"`
+
+	// TitleInclude and TitleExclude override their Config counterparts for
+	// this task when either is set
+	TitleInclude []string `json:"title_include,omitempty"`
+	TitleExclude []string `json:"title_exclude,omitempty"`
+
+	// Languages overrides Config.Languages for this task when set
+	Languages []string `json:"languages,omitempty"`
+
+	// IdempotencyKey identifies copies of the same task; when empty it is
+	// derived from the task's content, see Key
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// trace holds the timings of the task's last request when it was
+	// sampled for tracing
+	trace *reqtrace.Timings
 }
 
 // Result represents the result of a task
@@ -70,6 +267,25 @@ type Result struct {
 	ProxyID   string                 `json:"proxy_id"`
 	Duration  time.Duration          `json:"duration"`
 	Timestamp time.Time              `json:"timestamp"`
+
+	// OutOfScope counts the URLs the task's scope withheld
+	OutOfScope int `json:"out_of_scope,omitempty"`
+
+	// Cached is set when the page came from the cache, without a request
+	Cached bool `json:"cached,omitempty"`
+
+	// Duplicate is set on the result of a resent task, copied from the
+	// first copy's
+	Duplicate bool `json:"duplicate,omitempty"`
+
+	// Trace is the timing of the request behind the result, when it was
+	// sampled for tracing
+	Trace *reqtrace.Timings `json:"trace,omitempty"`
+
+	// Fetch task output
+	URL        string `json:"url,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       []byte `json:"body,omitempty"` // gzip-compressed response body
 }
 
 // ResultStatus represents the status of a result
@@ -82,39 +298,138 @@ const (
 	StatusBlocked   ResultStatus = "blocked"
 	StatusError     ResultStatus = "error"
 	StatusRetry     ResultStatus = "retry"
+	StatusTimeout   ResultStatus = "timeout"
+	StatusSkipped   ResultStatus = "skipped"
+)
+
+// Reasons a run ends before its tasks are done
+var (
+	ErrRunDeadline     = errors.New("run deadline exceeded")
+	ErrBudgetExhausted = errors.New("request budget exhausted")
 )
 
+// ErrStopped is returned by Start once the worker has been stopped; a
+// stopped worker has closed its results and cannot run again, unlike a
+// paused one
+var ErrStopped = errors.New("worker stopped")
+
 // Stats holds worker statistics
 type Stats struct {
-	TasksTotal      int64         `json:"tasks_total"`
-	TasksCompleted  int64         `json:"tasks_completed"`
-	TasksFailed     int64         `json:"tasks_failed"`
-	URLsFound       int64         `json:"urls_found"`
-	CaptchaCount    int64         `json:"captcha_count"`
-	BlockCount      int64         `json:"block_count"`
-	TotalDuration   time.Duration `json:"total_duration"`
-	RequestsPerSec  float64       `json:"requests_per_sec"`
+	TasksTotal        int64         `json:"tasks_total"`
+	TasksCompleted    int64         `json:"tasks_completed"`
+	TasksFailed       int64         `json:"tasks_failed"`
+	URLsFound         int64         `json:"urls_found"`
+	CaptchaCount      int64         `json:"captcha_count"`
+	BlockCount        int64         `json:"block_count"`
+	Requests          int64         `json:"requests"`
+	EstimatedCost     float64       `json:"estimated_cost"`
+	DuplicateURLs     int64         `json:"duplicate_urls"`
+	ClassifiedPages   int64         `json:"classified_pages"`
+	LayoutChanges     int64         `json:"layout_changes"`
+	LowConfidenceURLs int64         `json:"low_confidence_urls"`
+	OutOfScopeURLs    int64         `json:"out_of_scope_urls"`
+	ResolvedURLs      int64         `json:"resolved_urls"`
+	ListingFiles      int64         `json:"listing_files"`
+	VerifiedURLs      int64         `json:"verified_urls"`
+	UnverifiedURLs    int64         `json:"unverified_urls"`
+	HostFingerprints  int64         `json:"host_fingerprints"`
+	ResolvedHosts     int64         `json:"resolved_hosts"`
+	AlertFindings     int64         `json:"alert_findings"`
+	TitleFilteredURLs int64         `json:"title_filtered_urls"`
+	OffLanguageURLs   int64         `json:"off_language_urls"`
+	CacheHits         int64         `json:"cache_hits"`
+	DuplicateTasks    int64         `json:"duplicate_tasks"`
+	TotalDuration     time.Duration `json:"total_duration"`
+	RequestsPerSec    float64       `json:"requests_per_sec"`
 }
 
 // Worker handles the actual work
 type Worker struct {
 	config   Config
+	configMu sync.RWMutex
 	pool     *proxy.Pool
 	stealth  *stealth.Manager
 	engine   engine.SearchEngine
+	audit    *audit.Log
+	cost     *cost.Meter
+	pacing   *pacing.Recorder
+	params   *params.Harvester
+	resolver *resolve.Resolver
+	crawler  *dirlist.Crawler
+	verifier *verify.Verifier
+	dedup    dedup.Set
+	cache    *cache.Cache
+	htmlDump *htmldump.Dumper
+	onAlert  func(Alert)
+
+	classifier    classify.Classifier
+	fingerprinter *fingerprint.Fingerprinter
+	networks      *asn.Lookup
+	alerts        *alert.Notifier
+
+	// TLS settings of outgoing requests; nil uses the system defaults
+	tlsConfig *tls.Config
+
+	// Egress proxy in front of the scraping proxies; nil connects to them
+	// directly
+	egress *egress.Dialer
+
+	// Host names pinned to addresses, see proxy.Proxy.Route
+	hosts proxy.Hosts
+
+	// Canary state; canaryEmpty holds the proxies that got an empty page
+	// since the canary last found results
+	canaryMu       sync.Mutex
+	canaryEmpty    map[string]bool
+	canaryDegraded bool
+
+	// Idempotency state: tasks by key for IdempotencyWindow, in the order
+	// seen, and the keys of admitted tasks by ID until they finish
+	idemMu      sync.Mutex
+	idemTasks   map[string]*idemEntry
+	idemOrder   []idemSeen
+	idemRunning map[string]string
 
 	// Channels
 	tasks    chan *Task
 	results  chan *Result
 	stopCh   chan struct{}
 
-	// State
+	// Parse pipeline; parseJobs is bounded so fetching stalls rather than
+	// buffering pages without limit when parsing falls behind. Parsed pages
+	// needing requests go on to enrichJobs, bounded the same way.
+	parseJobs  chan *parseJob
+	parseWg    sync.WaitGroup
+	enrichJobs chan *parseJob
+	enrichWg   sync.WaitGroup
+
+	// Run end; ended is closed when the run deadline passes or the request
+	// budget runs out. runCtx is cancelled only for the deadline.
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+	runTimer  *time.Timer
+	ended     chan struct{}
+	endOnce   sync.Once
+	endErr    error
+
+	// Request budget accounting
+	engineRequests map[string]int
+	budgetMu       sync.Mutex
+
+	// State; lifecycleMu serializes Start, Pause and Stop. A pause closes
+	// stopCh, which Start then replaces; stopped is set for good by Stop.
+	lifecycleMu sync.Mutex
+	started     bool
+	paused      bool
+	stopped     bool
+
 	running  atomic.Bool
+	draining atomic.Bool
+	inFlight atomic.Int64
 	wg       sync.WaitGroup
 
 	// Stats
 	stats    Stats
-	statsMu  sync.RWMutex
 	startTime time.Time
 
 	// HTTP client (will be replaced per-request with proxy)
@@ -123,7 +438,19 @@ type Worker struct {
 
 // New creates a new worker
 func New(config Config, proxyPool *proxy.Pool) *Worker {
-	return &Worker{
+	if config.ParseWorkers <= 0 {
+		config.ParseWorkers = runtime.NumCPU()
+	}
+	if config.EnrichWorkers <= 0 {
+		config.EnrichWorkers = max(config.Workers, 1)
+	}
+	if config.Seed == 0 {
+		// Below 2^53, so the seed survives a trip through a JSON number
+		config.Seed = rand.Int63n(1<<53-1) + 1
+	}
+
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	w := &Worker{
 		config:  config,
 		pool:    proxyPool,
 		stealth: stealth.NewManager(),
@@ -131,40 +458,266 @@ func New(config Config, proxyPool *proxy.Pool) *Worker {
 		tasks:   make(chan *Task, config.BufferSize),
 		results: make(chan *Result, config.BufferSize),
 		stopCh:  make(chan struct{}),
+		parseJobs: make(chan *parseJob, parseQueueFactor*config.ParseWorkers),
+		enrichJobs: make(chan *parseJob, parseQueueFactor*config.EnrichWorkers),
+		canaryEmpty: make(map[string]bool),
+		idemTasks:   make(map[string]*idemEntry),
+		idemRunning: make(map[string]string),
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+		ended:     make(chan struct{}),
+		engineRequests: make(map[string]int),
 		baseTransport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
 		},
 	}
+	w.stealth.SetSeed(config.Seed)
+	if !config.Costs.Empty() {
+		w.cost = cost.NewMeter(config.Costs)
+	}
+	google := w.engine.(*engine.Google)
+	google.Assets = engine.NewAssetFilter(config.Assets)
+	if len(config.SERPFeatures) > 0 {
+		google.Features = make(map[string]bool, len(config.SERPFeatures))
+		for _, feature := range config.SERPFeatures {
+			google.Features[strings.ToLower(strings.TrimSpace(feature))] = true
+		}
+	}
+	google.Mobile = config.Mobile
+	google.OrganicOnly = config.OrganicOnly
+	w.resolver = resolve.New(config.Resolve)
+	w.crawler = dirlist.New(config.Crawl)
+	w.verifier = verify.New(config.Verify)
+	w.fingerprinter = fingerprint.New(config.Fingerprint)
+	w.networks = asn.New(config.Networks)
+	w.alerts = alert.New(config.Alerts)
+	return w
 }
 
-// Start starts the worker pool
-func (w *Worker) Start() {
+// Start starts the worker pool, or resumes it after Pause. It fails with
+// ErrStopped after Stop.
+func (w *Worker) Start() error {
+	w.lifecycleMu.Lock()
+	defer w.lifecycleMu.Unlock()
+
+	if w.stopped {
+		return ErrStopped
+	}
 	if w.running.Load() {
-		return
+		return nil
+	}
+
+	// Pause closed the stop channel the fetch goroutines watched; the
+	// parse workers kept running on the open parse queue
+	if w.paused {
+		w.stopCh = make(chan struct{})
+		w.paused = false
 	}
 
 	w.running.Store(true)
+	w.draining.Store(false)
 	w.startTime = time.Now()
 
+	// The run deadline counts from the first start, not from a resume
+	if w.runTimer == nil && w.config.MaxRunDuration > 0 {
+		w.runTimer = time.AfterFunc(w.config.MaxRunDuration, func() { w.endRun(ErrRunDeadline) })
+	}
+
 	// Start worker goroutines
 	for i := 0; i < w.config.Workers; i++ {
 		w.wg.Add(1)
-		go w.worker(i)
+		go w.worker(i, w.warmUpDelay(i))
+	}
+	if !w.started {
+		for i := 0; i < w.config.ParseWorkers; i++ {
+			w.parseWg.Add(1)
+			go w.parseWorker()
+		}
+		for i := 0; i < w.config.EnrichWorkers; i++ {
+			w.enrichWg.Add(1)
+			go w.enrichWorker()
+		}
+	}
+	if w.config.CanaryDork != "" && w.config.CanaryInterval > 0 {
+		w.wg.Add(1)
+		go w.runCanary(w.config.CanaryInterval)
 	}
+	w.started = true
+	return nil
 }
 
-// Stop stops the worker pool
-func (w *Worker) Stop() {
+// warmUpDelay returns how long worker id waits before taking its first task
+func (w *Worker) warmUpDelay(id int) time.Duration {
+	if w.config.WarmUp <= 0 || w.config.Workers <= 1 {
+		return 0
+	}
+	return w.config.WarmUp * time.Duration(id) / time.Duration(w.config.Workers)
+}
+
+// endRun ends the run for cause. Past the deadline in-flight requests are
+// cancelled and queued tasks complete with StatusTimeout; once the budget is
+// spent in-flight requests finish and queued tasks complete with
+// StatusSkipped.
+func (w *Worker) endRun(cause error) {
+	w.endOnce.Do(func() {
+		w.endErr = cause
+		close(w.ended)
+		if errors.Is(cause, ErrRunDeadline) {
+			w.cancelRun()
+		}
+	})
+}
+
+// Ended returns a channel that is closed once the run deadline passes or the
+// request budget runs out
+func (w *Worker) Ended() <-chan struct{} {
+	return w.ended
+}
+
+// EndReason returns ErrRunDeadline or ErrBudgetExhausted once Ended is
+// closed, and nil before
+func (w *Worker) EndReason() error {
+	select {
+	case <-w.ended:
+		return w.endErr
+	default:
+		return nil
+	}
+}
+
+// Pause stops taking tasks and waits for the ones in flight to finish.
+// Queued tasks, the parse queue and the results channel are kept, so Start
+// resumes where the worker left off.
+func (w *Worker) Pause() {
+	w.lifecycleMu.Lock()
+	defer w.lifecycleMu.Unlock()
+	w.halt()
+}
+
+// halt stops the fetch goroutines; the caller holds lifecycleMu
+func (w *Worker) halt() {
 	if !w.running.Load() {
 		return
 	}
-
 	w.running.Store(false)
 	close(w.stopCh)
 	w.wg.Wait()
+	w.paused = true
+}
+
+// Stop stops the worker pool for good and closes its results. A worker
+// that never started is left as it is.
+func (w *Worker) Stop() {
+	w.lifecycleMu.Lock()
+	defer w.lifecycleMu.Unlock()
+
+	if !w.started || w.stopped {
+		return
+	}
+	w.halt()
+	w.stopped = true
+
+	// Fetch goroutines are the only senders, so once they are gone the
+	// parse workers finish the pages already handed over and exit, and
+	// then, with no more senders of their own, the enrich workers
+	close(w.parseJobs)
+	w.parseWg.Wait()
+	close(w.enrichJobs)
+	w.enrichWg.Wait()
 	close(w.results)
+
+	// Findings raised before the stop still go out
+	if w.alerts != nil {
+		w.alerts.Close()
+	}
+}
+
+// Drain stops accepting new tasks, waits up to timeout for queued and
+// in-flight tasks to finish, then stops the pool. It reports whether the
+// queue emptied before the timeout.
+func (w *Worker) Drain(timeout time.Duration) bool {
+	if !w.running.Load() {
+		return true
+	}
+
+	w.draining.Store(true)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(timeout)
+	drained := false
+	for time.Now().Before(deadline) {
+		if len(w.tasks) == 0 && w.inFlight.Load() == 0 {
+			drained = true
+			break
+		}
+		<-ticker.C
+	}
+
+	w.Stop()
+	return drained
+}
+
+// TakePending removes and returns the tasks still queued in a stopped
+// worker, retries included, so they can be carried over to another run. It
+// returns nil while the worker runs.
+func (w *Worker) TakePending() []*Task {
+	if w.running.Load() {
+		return nil
+	}
+
+	var pending []*Task
+	for {
+		select {
+		case task := <-w.tasks:
+			pending = append(pending, task)
+		default:
+			return pending
+		}
+	}
+}
+
+// Reconfigure applies new timing, retry and paging settings to a running
+// worker. Concurrency, buffer sizes, costs and the asset filter are fixed at
+// construction.
+func (w *Worker) Reconfigure(config Config) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+
+	config.Workers = w.config.Workers
+	config.BufferSize = w.config.BufferSize
+	config.ParseWorkers = w.config.ParseWorkers
+	config.EnrichWorkers = w.config.EnrichWorkers
+	config.CanaryInterval = w.config.CanaryInterval
+	config.Costs = w.config.Costs
+	config.Assets = w.config.Assets
+	config.SERPFeatures = w.config.SERPFeatures
+	config.Mobile = w.config.Mobile
+	config.OrganicOnly = w.config.OrganicOnly
+	config.Resolve = w.config.Resolve
+	config.Crawl = w.config.Crawl
+	config.Verify = w.config.Verify
+	config.Fingerprint = w.config.Fingerprint
+	config.Networks = w.config.Networks
+	config.Alerts = w.config.Alerts
+	config.Cache = w.config.Cache
+	config.Seed = w.config.Seed
+	w.config = config
+}
+
+// Config returns the active configuration, including runtime updates
+func (w *Worker) Config() Config {
+	return w.currentConfig()
+}
+
+// currentConfig returns a snapshot of the active configuration
+func (w *Worker) currentConfig() Config {
+	w.configMu.RLock()
+	defer w.configMu.RUnlock()
+	return w.config
 }
 
 // Submit submits a task to the worker pool
@@ -173,11 +726,34 @@ func (w *Worker) Submit(task *Task) error {
 		return fmt.Errorf("worker not running")
 	}
 
+	if w.draining.Load() {
+		return fmt.Errorf("worker draining")
+	}
+
+	if task.Type == TaskTypeFetch && task.URL == "" {
+		return fmt.Errorf("fetch task requires a url")
+	}
+
+	if !task.DedupGranularity.Valid() {
+		return fmt.Errorf("unknown dedup granularity %q", task.DedupGranularity)
+	}
+
+	// A task without an ID gets one from its content, so the same request
+	// always has the same ID
+	if task.ID == "" {
+		task.ID = "task_" + task.Key()
+	}
+
+	if !w.admit(task) {
+		return nil
+	}
+
 	select {
 	case w.tasks <- task:
 		atomic.AddInt64(&w.stats.TasksTotal, 1)
 		return nil
 	default:
+		w.forget(task)
 		return fmt.Errorf("task buffer full")
 	}
 }
@@ -187,12 +763,47 @@ func (w *Worker) Results() <-chan *Result {
 	return w.results
 }
 
+// CostSummary returns the estimated spend with its breakdown; it is empty
+// when no cost model is configured
+func (w *Worker) CostSummary() cost.Summary {
+	if w.cost == nil {
+		return cost.Summary{}
+	}
+	return w.cost.Summary()
+}
+
 // Stats returns current statistics
 func (w *Worker) Stats() Stats {
-	w.statsMu.RLock()
-	defer w.statsMu.RUnlock()
-
-	stats := w.stats
+	// Counters are updated atomically while tasks run, so each is loaded
+	// on its own rather than copied with the struct
+	stats := Stats{
+		TasksTotal:        atomic.LoadInt64(&w.stats.TasksTotal),
+		TasksCompleted:    atomic.LoadInt64(&w.stats.TasksCompleted),
+		TasksFailed:       atomic.LoadInt64(&w.stats.TasksFailed),
+		URLsFound:         atomic.LoadInt64(&w.stats.URLsFound),
+		CaptchaCount:      atomic.LoadInt64(&w.stats.CaptchaCount),
+		BlockCount:        atomic.LoadInt64(&w.stats.BlockCount),
+		Requests:          atomic.LoadInt64(&w.stats.Requests),
+		DuplicateURLs:     atomic.LoadInt64(&w.stats.DuplicateURLs),
+		ClassifiedPages:   atomic.LoadInt64(&w.stats.ClassifiedPages),
+		LayoutChanges:     atomic.LoadInt64(&w.stats.LayoutChanges),
+		LowConfidenceURLs: atomic.LoadInt64(&w.stats.LowConfidenceURLs),
+		OutOfScopeURLs:    atomic.LoadInt64(&w.stats.OutOfScopeURLs),
+		ResolvedURLs:      atomic.LoadInt64(&w.stats.ResolvedURLs),
+		ListingFiles:      atomic.LoadInt64(&w.stats.ListingFiles),
+		VerifiedURLs:      atomic.LoadInt64(&w.stats.VerifiedURLs),
+		UnverifiedURLs:    atomic.LoadInt64(&w.stats.UnverifiedURLs),
+		HostFingerprints:  atomic.LoadInt64(&w.stats.HostFingerprints),
+		ResolvedHosts:     atomic.LoadInt64(&w.stats.ResolvedHosts),
+		AlertFindings:     atomic.LoadInt64(&w.stats.AlertFindings),
+		TitleFilteredURLs: atomic.LoadInt64(&w.stats.TitleFilteredURLs),
+		OffLanguageURLs:   atomic.LoadInt64(&w.stats.OffLanguageURLs),
+		CacheHits:         atomic.LoadInt64(&w.stats.CacheHits),
+		DuplicateTasks:    atomic.LoadInt64(&w.stats.DuplicateTasks),
+	}
+	if w.cost != nil {
+		stats.EstimatedCost = w.cost.Total()
+	}
 	stats.TotalDuration = time.Since(w.startTime)
 
 	if stats.TotalDuration.Seconds() > 0 {
@@ -203,9 +814,19 @@ func (w *Worker) Stats() Stats {
 }
 
 // worker is the main worker goroutine
-func (w *Worker) worker(id int) {
+func (w *Worker) worker(id int, startDelay time.Duration) {
 	defer w.wg.Done()
 
+	if startDelay > 0 {
+		timer := time.NewTimer(startDelay)
+		select {
+		case <-w.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
 	for {
 		select {
 		case <-w.stopCh:
@@ -214,13 +835,38 @@ func (w *Worker) worker(id int) {
 			if !ok {
 				return
 			}
+			w.inFlight.Add(1)
 			w.processTask(id, task)
+			w.inFlight.Add(-1)
 		}
 	}
 }
 
 // processTask processes a single task
 func (w *Worker) processTask(workerID int, task *Task) {
+	ctx, cancel := w.taskContext(task)
+	defer cancel()
+
+	if ctx.Err() != nil {
+		w.sendTimeout(task, nil, 0)
+		return
+	}
+
+	// A cached page needs neither a request nor the budget for one
+	if task.Type != TaskTypeFetch && w.serveCached(task) {
+		return
+	}
+
+	if err := w.reserveRequest(w.engineName(task)); err != nil {
+		w.sendSkipped(task, err)
+		return
+	}
+
+	if task.Type == TaskTypeFetch {
+		w.processFetch(ctx, workerID, task)
+		return
+	}
+
 	startTime := time.Now()
 
 	// Get a proxy
@@ -239,25 +885,42 @@ func (w *Worker) processTask(workerID int, task *Task) {
 	}
 
 	// Build search URL
-	searchURL := w.engine.(*engine.Google).BuildSearchURL(task.Dork, task.Page, w.config.ResultsPerPage)
+	searchURL := w.searchURL(task.Dork, task.Page, prx)
 
 	// Make request
-	html, err := w.makeRequest(searchURL, prx)
+	reqCtx, recorder := w.traceRequest(ctx)
+	statusCode, html, err := w.makeRequest(reqCtx, task, searchURL, prx)
 	duration := time.Since(startTime)
+	task.trace = recorder.Timings()
+
+	// A cancelled request says nothing about the proxy
+	if err != nil && ctx.Err() != nil {
+		w.recordRequest(task, searchURL, prx, statusCode, StatusTimeout, err, duration)
+		w.sendTimeout(task, prx, duration)
+		return
+	}
 
 	if err != nil {
+		w.recordRequest(task, searchURL, prx, statusCode, StatusError, err, duration)
 		w.pool.ReportFailure(prx.ID)
 		w.handleRequestError(task, prx, err, duration)
 		return
 	}
 
+	// A Tor exit turned away gets a new circuit through the block report
+	if prx.Provider == tor.Provider && w.engine.(*engine.Google).DetectTorBlock(html) {
+		w.handleBlock(task, searchURL, prx, statusCode, duration)
+		return
+	}
+
 	// Check for CAPTCHA
 	if w.engine.(*engine.Google).DetectCaptcha(html) {
+		w.recordRequest(task, searchURL, prx, statusCode, StatusCaptcha, nil, duration)
 		w.pool.ReportCaptcha(prx.ID)
 		atomic.AddInt64(&w.stats.CaptchaCount, 1)
 
 		// Retry with different proxy
-		if task.Retry < w.config.MaxRetries {
+		if task.Retry < w.currentConfig().MaxRetries {
 			task.Retry++
 			w.retryTask(task)
 			return
@@ -270,6 +933,8 @@ func (w *Worker) processTask(workerID int, task *Task) {
 			ProxyID:   prx.ID,
 			Duration:  duration,
 			Timestamp: time.Now(),
+
+			Trace: task.trace,
 		})
 		atomic.AddInt64(&w.stats.TasksFailed, 1)
 		return
@@ -277,207 +942,1327 @@ func (w *Worker) processTask(workerID int, task *Task) {
 
 	// Check for block
 	if w.engine.(*engine.Google).DetectBlock(html) {
-		w.pool.ReportBlock(prx.ID)
-		atomic.AddInt64(&w.stats.BlockCount, 1)
+		w.handleBlock(task, searchURL, prx, statusCode, duration)
+		return
+	}
 
-		// Retry with different proxy
-		if task.Retry < w.config.MaxRetries {
-			task.Retry++
-			w.retryTask(task)
-			return
-		}
+	// A good response clears the proxy; parsing the page does not need it
+	w.pool.ReportSuccess(prx.ID, duration)
 
-		w.sendResult(&Result{
-			TaskID:    task.ID,
-			Dork:      task.Dork,
-			Status:    StatusBlocked,
-			ProxyID:   prx.ID,
-			Duration:  duration,
-			Timestamp: time.Now(),
-		})
-		atomic.AddInt64(&w.stats.TasksFailed, 1)
-		return
+	// The page counts as in flight until a parse worker is done with it, so
+	// Drain waits for it
+	w.inFlight.Add(1)
+	w.parseJobs <- &parseJob{
+		task:       task,
+		searchURL:  searchURL,
+		prx:        prx,
+		statusCode: statusCode,
+		html:       html,
+		duration:   duration,
 	}
 
-	// Parse results
-	results := w.engine.(*engine.Google).ParseResults(html)
+	// Apply delay before next request
+	w.applyDelay(prx)
+}
 
-	// Report success
-	w.pool.ReportSuccess(prx.ID, duration)
+// handleBlock quarantines a proxy that got a block page and retries the task
+// through another, or fails it once its retries are used up
+func (w *Worker) handleBlock(task *Task, searchURL string, prx *proxy.Proxy, statusCode int, duration time.Duration) {
+	w.recordRequest(task, searchURL, prx, statusCode, StatusBlocked, nil, duration)
+	w.pool.ReportBlock(prx.ID)
+	atomic.AddInt64(&w.stats.BlockCount, 1)
 
-	// Check for no results
-	if len(results) == 0 {
-		if w.engine.(*engine.Google).DetectNoResults(html) {
-			w.sendResult(&Result{
-				TaskID:    task.ID,
-				Dork:      task.Dork,
-				Status:    StatusNoResults,
-				URLs:      results,
-				ProxyID:   prx.ID,
-				Duration:  duration,
-				Timestamp: time.Now(),
-			})
-		} else {
-			w.sendResult(&Result{
-				TaskID:    task.ID,
-				Dork:      task.Dork,
-				Status:    StatusSuccess,
-				URLs:      results,
-				ProxyID:   prx.ID,
-				Duration:  duration,
-				Timestamp: time.Now(),
-			})
-		}
-		atomic.AddInt64(&w.stats.TasksCompleted, 1)
+	// Retry with different proxy
+	if task.Retry < w.currentConfig().MaxRetries {
+		task.Retry++
+		w.retryTask(task)
 		return
 	}
 
-	// Success with results
-	atomic.AddInt64(&w.stats.URLsFound, int64(len(results)))
-	atomic.AddInt64(&w.stats.TasksCompleted, 1)
-
 	w.sendResult(&Result{
 		TaskID:    task.ID,
 		Dork:      task.Dork,
-		Status:    StatusSuccess,
-		URLs:      results,
+		Status:    StatusBlocked,
 		ProxyID:   prx.ID,
 		Duration:  duration,
 		Timestamp: time.Now(),
+
+		Trace: task.trace,
 	})
+	atomic.AddInt64(&w.stats.TasksFailed, 1)
+}
 
-	// Apply delay before next request
-	w.applyDelay()
+// parseJob is a fetched results page waiting for a parse worker, and then
+// for an enrich worker if it needs requests
+type parseJob struct {
+	task       *Task
+	searchURL  string
+	prx        *proxy.Proxy // nil for a page served from the cache
+	statusCode int
+	html       string
+	duration   time.Duration
+
+	// Set by the parse worker
+	results   []engine.SearchResult
+	noResults bool
 }
 
-// makeRequest makes an HTTP request through a proxy
-func (w *Worker) makeRequest(targetURL string, prx *proxy.Proxy) (string, error) {
-	// Parse proxy URL
-	proxyURL, err := url.Parse(prx.URL())
-	if err != nil {
-		return "", fmt.Errorf("invalid proxy URL: %w", err)
-	}
+// parseQueueFactor sizes the parse queue per parse worker
+const parseQueueFactor = 4
 
-	// Create transport with proxy
-	transport := &http.Transport{
-		Proxy:               http.ProxyURL(proxyURL),
-		MaxIdleConns:        10,
-		IdleConnTimeout:     30 * time.Second,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
+// parseWorker parses pages handed over by the fetch goroutines until the
+// parse queue is closed
+func (w *Worker) parseWorker() {
+	defer w.parseWg.Done()
 
-	// Create client
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   w.config.RequestTimeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 3 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
+	for job := range w.parseJobs {
+		w.parsePage(job)
+		w.inFlight.Add(-1)
 	}
+}
 
-	// Create request
-	req, err := http.NewRequestWithContext(context.Background(), "GET", targetURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
+// enrichWorker settles pages the parse workers hand over until the enrich
+// queue is closed
+func (w *Worker) enrichWorker() {
+	defer w.enrichWg.Done()
 
-	// Set headers from stealth manager
-	headers := w.stealth.GetHeaders()
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	for job := range w.enrichJobs {
+		w.settlePage(job)
+		w.inFlight.Add(-1)
 	}
+}
 
-	// Additional headers
-	req.Header.Set("Referer", "https://www.google.com/")
-	req.Header.Set("DNT", "1")
+// parsePage extracts the results from a fetched page and reports them, or
+// hands the page to the enrich workers when that takes requests
+func (w *Worker) parsePage(job *parseJob) {
+	job.results = w.engine.(*engine.Google).ParseResults(job.html)
+	job.noResults = len(job.results) == 0 && w.engine.(*engine.Google).DetectNoResults(job.html)
 
-	// Make request
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+	// Only the classifier reads the page after this; drop it otherwise so
+	// it is not held while the job waits in the enrich queue
+	if len(job.results) > 0 || job.noResults {
+		job.html = ""
 	}
-	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status code: %d", resp.StatusCode)
+	if w.needsRequests(job) {
+		// Counted in flight until settled, like a page waiting to be parsed
+		w.inFlight.Add(1)
+		w.enrichJobs <- job
+		return
 	}
+	w.settlePage(job)
+}
 
-	// Read body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read body: %w", err)
+// needsRequests reports whether settling a parsed page makes requests: an
+// ambiguous page goes to the classifier, and results may go out to be
+// resolved, verified, crawled, fingerprinted or looked up
+func (w *Worker) needsRequests(job *parseJob) bool {
+	if len(job.results) == 0 {
+		return !job.noResults && w.classifier != nil
 	}
-
-	return string(body), nil
+	return w.resolver != nil || w.verifier != nil || w.crawler != nil ||
+		w.fingerprinter != nil || w.networks != nil
 }
 
-// handleRequestError handles request errors
-func (w *Worker) handleRequestError(task *Task, prx *proxy.Proxy, err error, duration time.Duration) {
-	// Retry if possible
-	if task.Retry < w.config.MaxRetries {
-		task.Retry++
-		w.retryTask(task)
+// settlePage classifies a parsed page the heuristics could not settle,
+// then caches and reports it, or retries its task when it turns out to be
+// a block
+func (w *Worker) settlePage(job *parseJob) {
+	task, prx := job.task, job.prx
+	results, noResults := job.results, job.noResults
+
+	// Neither results nor a no-results notice: the heuristics cannot tell
+	// an empty page from a block or a changed layout
+	var class classify.Class
+	if len(results) == 0 && !noResults {
+		class = w.classify(job)
+	}
+
+	// The page is no longer needed; drop it so it is not held while the
+	// result waits on a full results channel
+	job.html = ""
+
+	switch class {
+	case classify.ClassEmpty:
+		noResults = true
+	case classify.ClassBlock:
+		w.handleBlock(task, job.searchURL, prx, job.statusCode, job.duration)
 		return
+	case classify.ClassLayoutChange, classify.ClassResults:
+		atomic.AddInt64(&w.stats.LayoutChanges, 1)
 	}
 
-	w.sendResult(&Result{
-		TaskID:    task.ID,
-		Dork:      task.Dork,
-		Status:    StatusError,
-		Error:     err.Error(),
-		ProxyID:   prx.ID,
-		Duration:  duration,
-		Timestamp: time.Now(),
-	})
-	atomic.AddInt64(&w.stats.TasksFailed, 1)
+	// Only pages that said what they hold are worth serving again
+	if len(results) > 0 || noResults {
+		w.cachePage(task, results, noResults)
+	}
+	w.reportPage(job, results, noResults)
 }
 
-// retryTask requeues a task for retry
-func (w *Worker) retryTask(task *Task) {
-	// Apply retry delay
-	time.Sleep(w.config.RetryDelay)
+// reportPage filters a parsed page's results for the task and reports
+// them. A page from the cache made no request, so none is recorded.
+func (w *Worker) reportPage(job *parseJob, results []engine.SearchResult, noResults bool) {
+	task, prx := job.task, job.prx
+	cached := prx == nil
+	var proxyID string
+	var trace *reqtrace.Timings
+	if !cached {
+		proxyID = prx.ID
+		trace = task.trace
+	}
 
-	select {
-	case w.tasks <- task:
-		// Requeued successfully
-	default:
-		// Buffer full, send error
+	// Positions count from the top of the page; the page's offset makes them
+	// absolute so results keep their SERP rank across pages
+	offset := task.Page * w.currentConfig().ResultsPerPage
+	for i := range results {
+		results[i].Position += offset
+	}
+
+	// Check for no results
+	if len(results) == 0 {
+		status := StatusSuccess
+		if noResults {
+			status = StatusNoResults
+		}
+		if !cached {
+			w.recordRequest(task, job.searchURL, prx, job.statusCode, status, nil, job.duration)
+		}
 		w.sendResult(&Result{
 			TaskID:    task.ID,
 			Dork:      task.Dork,
-			Status:    StatusError,
-			Error:     "retry buffer full",
+			Status:    status,
+			URLs:      results,
+			ProxyID:   proxyID,
+			Duration:  job.duration,
 			Timestamp: time.Now(),
+
+			Cached: cached,
+			Trace:  trace,
 		})
-		atomic.AddInt64(&w.stats.TasksFailed, 1)
+		atomic.AddInt64(&w.stats.TasksCompleted, 1)
+		return
 	}
-}
 
-// sendResult sends a result to the results channel
-func (w *Worker) sendResult(result *Result) {
-	select {
-	case w.results <- result:
-		// Sent successfully
-	default:
-		// Results buffer full, drop oldest or log
+	// Success with results; URLs below the confidence floor, failing the
+	// title or language filters, out of scope or already returned this run
+	// are dropped, as are unverified ones when verification requires a
+	// match.
+	// Redirector links are resolved first so scope and dedup see where they
+	// lead.
+	results = w.filterConfidence(task, results)
+	results = w.filterTitles(task, results)
+	results = w.filterLanguages(task, results)
+	w.resolveRedirects(task, results)
+	results, outOfScope := w.filterScope(task, results)
+	w.harvestParams(results)
+	results = w.dedupe(task, results)
+	results = w.verifyResults(task, results)
+	w.crawlListings(task, results)
+	w.fingerprintHosts(task, results)
+	w.lookupNetworks(results)
+	w.checkAlerts(task, results)
+	if !cached {
+		w.recordRequest(task, job.searchURL, prx, job.statusCode, StatusSuccess, nil, job.duration)
 	}
-}
-
-// applyDelay applies a randomized delay between requests
-func (w *Worker) applyDelay() {
+	atomic.AddInt64(&w.stats.URLsFound, int64(len(results)))
+	atomic.AddInt64(&w.stats.TasksCompleted, 1)
+
+	w.sendResult(&Result{
+		TaskID:    task.ID,
+		Dork:      task.Dork,
+		Status:    StatusSuccess,
+		URLs:      results,
+		ProxyID:   proxyID,
+		Duration:  job.duration,
+		Timestamp: time.Now(),
+
+		OutOfScope: outOfScope,
+		Cached:     cached,
+		Trace:      trace,
+	})
+}
+
+// cacheKey returns the cache key of a search task's page. Pages searched
+// with GeoMatch depend on the proxy's country, which is not known before a
+// proxy is picked, so they are not cached.
+func (w *Worker) cacheKey(task *Task) (cache.Key, bool) {
+	cfg := w.currentConfig()
+	if w.cache == nil || cfg.GeoMatch {
+		return cache.Key{}, false
+	}
+	return cache.Key{
+		Engine:         w.engine.Name(),
+		Query:          task.Dork,
+		Page:           task.Page,
+		Domain:         w.engine.(*engine.Google).Domain,
+		ResultsPerPage: cfg.ResultsPerPage,
+	}, true
+}
+
+// serveCached reports a search task from a cached page, if there is one
+// within the TTL, and returns whether it did. The cached results go through
+// the task's filters like a fresh page's.
+func (w *Worker) serveCached(task *Task) bool {
+	key, ok := w.cacheKey(task)
+	if !ok {
+		return false
+	}
+	entry, ok := w.cache.Get(key)
+	if !ok {
+		return false
+	}
+
+	atomic.AddInt64(&w.stats.CacheHits, 1)
+	w.reportPage(&parseJob{task: task}, entry.Results, entry.NoResults)
+	return true
+}
+
+// cachePage keeps a parsed page for identical searches within the TTL
+func (w *Worker) cachePage(task *Task, results []engine.SearchResult, noResults bool) {
+	key, ok := w.cacheKey(task)
+	if !ok {
+		return
+	}
+	// A failed disk write still leaves the page cached in memory
+	w.cache.Put(key, results, noResults)
+}
+
+// classify asks the classifier what an ambiguous page is, returning "" when
+// there is none or it fails so the heuristic verdict stands
+func (w *Worker) classify(job *parseJob) classify.Class {
+	if w.classifier == nil {
+		return ""
+	}
+
+	class, err := w.classifier.Classify(w.runCtx, &classify.Page{
+		Engine:     w.engine.Name(),
+		URL:        job.searchURL,
+		StatusCode: job.statusCode,
+		HTML:       job.html,
+		Heuristic:  classify.ClassUnknown,
+	})
+	if err != nil {
+		return ""
+	}
+	atomic.AddInt64(&w.stats.ClassifiedPages, 1)
+	return class
+}
+
+// filterConfidence drops results scoring below the task's minimum
+// confidence, or the configured one when the task sets none
+func (w *Worker) filterConfidence(task *Task, results []engine.SearchResult) []engine.SearchResult {
+	min := task.MinConfidence
+	if min <= 0 {
+		min = w.currentConfig().MinConfidence
+	}
+	if min <= 0 {
+		return results
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		if r.Confidence >= min {
+			kept = append(kept, r)
+		}
+	}
+	atomic.AddInt64(&w.stats.LowConfidenceURLs, int64(len(results)-len(kept)))
+	return kept
+}
+
+// filterTitles drops results whose title fails the task's title filters,
+// or the configured ones when the task sets none. With include terms, a
+// result without a title is dropped since it cannot match.
+func (w *Worker) filterTitles(task *Task, results []engine.SearchResult) []engine.SearchResult {
+	include, exclude := task.TitleInclude, task.TitleExclude
+	if len(include) == 0 && len(exclude) == 0 {
+		config := w.currentConfig()
+		include, exclude = config.TitleInclude, config.TitleExclude
+	}
+	if len(include) == 0 && len(exclude) == 0 {
+		return results
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		title := strings.ToLower(r.Title)
+		if (len(include) == 0 || containsAny(title, include)) && !containsAny(title, exclude) {
+			kept = append(kept, r)
+		}
+	}
+	atomic.AddInt64(&w.stats.TitleFilteredURLs, int64(len(results)-len(kept)))
+	return kept
+}
+
+// containsAny reports whether the lowercased text contains any of the
+// terms, ignoring case; empty terms never match
+func containsAny(text string, terms []string) bool {
+	for _, term := range terms {
+		if term != "" && strings.Contains(text, strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterLanguages tags results with their detected language when detection
+// is on and drops those detected in a language outside the task's list, or
+// the configured one when the task sets none
+func (w *Worker) filterLanguages(task *Task, results []engine.SearchResult) []engine.SearchResult {
+	config := w.currentConfig()
+	languages := task.Languages
+	if len(languages) == 0 {
+		languages = config.Languages
+	}
+	if len(languages) == 0 && !config.DetectLanguage {
+		return results
+	}
+
+	allowed := make(map[string]bool, len(languages))
+	for _, l := range languages {
+		if code := lang.Normalize(l); code != "" {
+			allowed[code] = true
+		}
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		r.Language = lang.Detect(r.Title + " " + r.Description)
+		if len(allowed) == 0 || r.Language == "" || allowed[r.Language] {
+			kept = append(kept, r)
+		}
+	}
+	atomic.AddInt64(&w.stats.OffLanguageURLs, int64(len(results)-len(kept)))
+	return kept
+}
+
+// resolveRedirects sets the final URL of every redirector link among the
+// results, each resolved through a proxy from the pool unless it is cached.
+// Links that fail to resolve are reported as they are.
+func (w *Worker) resolveRedirects(task *Task, results []engine.SearchResult) {
+	if w.resolver == nil {
+		return
+	}
+
+	for i := range results {
+		link := results[i].URL
+		if !w.resolver.Match(link) {
+			continue
+		}
+
+		final, ok := w.resolver.Cached(link)
+		if !ok {
+			var err error
+			if final, err = w.resolveLink(task, link); err != nil {
+				continue
+			}
+		}
+		if final != link {
+			results[i].FinalURL = final
+			atomic.AddInt64(&w.stats.ResolvedURLs, 1)
+		}
+	}
+}
+
+// resolveLink resolves one redirector link through a proxy from the pool
+func (w *Worker) resolveLink(task *Task, link string) (string, error) {
+	transport, err := w.poolTransport(task, stepResolve)
+	if err != nil {
+		return "", err
+	}
+	defer transport.CloseIdleConnections()
+
+	return w.resolver.Resolve(w.runCtx, transport, link)
+}
+
+// crawlListings lists the files of every open directory listing among the
+// results, each crawled through a proxy from the pool. A result that is
+// not a listing after all, or cannot be read, is reported without files.
+func (w *Worker) crawlListings(task *Task, results []engine.SearchResult) {
+	if w.crawler == nil {
+		return
+	}
+
+	for i := range results {
+		if !w.crawler.Match(results[i].Title) {
+			continue
+		}
+		transport, err := w.poolTransport(task, stepCrawl)
+		if err != nil {
+			return
+		}
+		files, err := w.crawler.Crawl(w.runCtx, transport, results[i].Target())
+		transport.CloseIdleConnections()
+		if err != nil {
+			continue
+		}
+		results[i].Files = files
+		atomic.AddInt64(&w.stats.ListingFiles, int64(len(files)))
+	}
+}
+
+// fingerprintHosts tags every result with the fingerprint of its host,
+// taking each host's through a proxy from the pool the first time it is
+// seen. Hosts that cannot be reached are tagged with none.
+func (w *Worker) fingerprintHosts(task *Task, results []engine.SearchResult) {
+	if w.fingerprinter == nil {
+		return
+	}
+
+	for i := range results {
+		origin := fingerprint.Origin(results[i].Target())
+		if origin == "" {
+			continue
+		}
+		host, ok := w.fingerprinter.Cached(origin)
+		if !ok {
+			if w.fingerprinter.Full() {
+				continue
+			}
+			transport, err := w.poolTransport(task, stepFingerprint)
+			if err != nil {
+				return
+			}
+			host, err = w.fingerprinter.Take(w.runCtx, transport, origin)
+			transport.CloseIdleConnections()
+			if err == nil {
+				atomic.AddInt64(&w.stats.HostFingerprints, 1)
+			}
+		}
+		results[i].Fingerprint = host
+	}
+}
+
+// HostClusters groups the hosts fingerprinted so far by favicon hash,
+// largest cluster first; nil unless hosts are fingerprinted
+func (w *Worker) HostClusters() []fingerprint.Cluster {
+	if w.fingerprinter == nil {
+		return nil
+	}
+	return w.fingerprinter.Clusters()
+}
+
+// lookupNetworks tags every result with the network of its host, looking
+// each host up the first time it is seen. Hosts that do not resolve are
+// tagged with none.
+func (w *Worker) lookupNetworks(results []engine.SearchResult) {
+	if w.networks == nil {
+		return
+	}
+
+	for i := range results {
+		parsed, err := url.Parse(results[i].Target())
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(parsed.Hostname())
+		network, ok := w.networks.Cached(host)
+		if !ok {
+			if w.networks.Full() {
+				continue
+			}
+			network, err = w.networks.Take(w.runCtx, host)
+			if err == nil {
+				atomic.AddInt64(&w.stats.ResolvedHosts, 1)
+			}
+		}
+		results[i].Network = network
+	}
+}
+
+// checkAlerts evaluates the alert rules against every result, raising an
+// alert for each finding
+func (w *Worker) checkAlerts(task *Task, results []engine.SearchResult) {
+	if w.alerts == nil {
+		return
+	}
+
+	for _, result := range results {
+		for _, finding := range w.alerts.Check(task.ID, task.Dork, result.Target(), result.Title) {
+			atomic.AddInt64(&w.stats.AlertFindings, 1)
+			if w.onAlert != nil {
+				w.onAlert(Alert{
+					Event:     AlertFinding,
+					Message:   finding.Message(),
+					Timestamp: finding.Time,
+				})
+			}
+		}
+	}
+}
+
+// NetworkGroups groups the hosts looked up so far by AS and netblock,
+// largest first; nil unless networks are looked up
+func (w *Worker) NetworkGroups() []asn.Group {
+	if w.networks == nil {
+		return nil
+	}
+	return w.networks.Groups()
+}
+
+// verifyResults fetches every result page through a proxy from the pool
+// and records the patterns it matches. When verification requires a match,
+// results matching none, or whose page cannot be fetched, are dropped.
+func (w *Worker) verifyResults(task *Task, results []engine.SearchResult) []engine.SearchResult {
+	if w.verifier == nil || len(results) == 0 {
+		return results
+	}
+
+	w.verifier.Each(len(results), func(i int) {
+		transport, err := w.poolTransport(task, stepVerify)
+		if err != nil {
+			return
+		}
+		defer transport.CloseIdleConnections()
+
+		matches, err := w.verifier.Check(w.runCtx, transport, results[i].Target())
+		if err == nil {
+			results[i].Matches = matches
+		}
+	})
+
+	kept := results[:0]
+	for _, result := range results {
+		if len(result.Matches) > 0 {
+			atomic.AddInt64(&w.stats.VerifiedURLs, 1)
+		} else if w.verifier.Require() {
+			atomic.AddInt64(&w.stats.UnverifiedURLs, 1)
+			continue
+		}
+		kept = append(kept, result)
+	}
+	return kept
+}
+
+// Steps making requests on behalf of a task's results; each is budgeted,
+// priced and audited under its name, as engines are under theirs
+const (
+	stepResolve     = "resolve"
+	stepVerify      = "verify"
+	stepCrawl       = "crawl"
+	stepFingerprint = "fingerprint"
+)
+
+// poolTransport returns a transport through a proxy from the pool, for
+// requests made by step on behalf of task's results rather than for the
+// task itself
+func (w *Worker) poolTransport(task *Task, step string) (*stepTransport, error) {
+	prx, err := w.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := url.Parse(prx.URL())
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", prx)
+	}
+
+	selectProxy, dial := prx.Route(proxyURL, w.egress.DialContext, w.hosts)
+	return &stepTransport{
+		w:    w,
+		task: task,
+		step: step,
+		prx:  prx,
+		base: &http.Transport{
+			Proxy:               selectProxy,
+			DialContext:         dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// stepTransport sends a step's requests through one proxy, accounting for
+// each like a search request: it is reserved against the budgets, charged,
+// recorded and reported to the pool. Only transport errors count against
+// the proxy; a status code is the target site's answer, not the proxy's.
+type stepTransport struct {
+	w    *Worker
+	task *Task
+	step string
+	prx  *proxy.Proxy
+	base *http.Transport
+}
+
+func (t *stepTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := t.w
+	if err := w.reserveRequest(t.step); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+	if err != nil {
+		outcome := StatusError
+		if req.Context().Err() != nil {
+			outcome = StatusTimeout
+		} else {
+			w.pool.ReportFailure(t.prx.ID)
+		}
+		w.recordStep(t.task, t.step, req.URL, t.prx, 0, outcome, err, duration)
+		return nil, err
+	}
+
+	w.pool.ReportSuccess(t.prx.ID, duration)
+	w.recordStep(t.task, t.step, req.URL, t.prx, resp.StatusCode, StatusSuccess, nil, duration)
+	if w.cost != nil {
+		resp.Body = &chargedBody{ReadCloser: resp.Body, meter: w.cost, prx: t.prx}
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections to the proxy
+func (t *stepTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// chargedBody charges its proxy for the bytes read from a response body
+// once it is closed
+type chargedBody struct {
+	io.ReadCloser
+	meter *cost.Meter
+	prx   *proxy.Proxy
+	n     int64
+}
+
+func (b *chargedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *chargedBody) Close() error {
+	b.meter.ChargeProxy(b.prx.ID, b.prx.Host, b.n)
+	return b.ReadCloser.Close()
+}
+
+// harvestParams records the query parameter names of results before dedup,
+// so names on URLs dropped as duplicates at a coarse granularity still count
+func (w *Worker) harvestParams(results []engine.SearchResult) {
+	if w.params == nil {
+		return
+	}
+	for _, r := range results {
+		w.params.Add(r.Target())
+	}
+}
+
+// filterScope drops results outside the task's scope, or the configured one
+// when the task sets none, and returns how many it dropped
+func (w *Worker) filterScope(task *Task, results []engine.SearchResult) ([]engine.SearchResult, int) {
+	patterns := task.Scope
+	if len(patterns) == 0 {
+		patterns = w.currentConfig().Scope
+	}
+	s := scope.New(patterns)
+	if s == nil {
+		return results, 0
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		if s.Contains(r.Target()) {
+			kept = append(kept, r)
+		}
+	}
+	dropped := len(results) - len(kept)
+	atomic.AddInt64(&w.stats.OutOfScopeURLs, int64(dropped))
+	return kept, dropped
+}
+
+// dedupe drops results whose URL an earlier task already returned, compared
+// at the task's granularity or the configured one when the task sets none
+func (w *Worker) dedupe(task *Task, results []engine.SearchResult) []engine.SearchResult {
+	if w.dedup == nil {
+		return results
+	}
+
+	granularity := task.DedupGranularity
+	if granularity == "" {
+		granularity = w.currentConfig().Dedup.Granularity
+	}
+
+	kept := results[:0]
+	for _, r := range results {
+		if w.dedup.Add(dedup.Key(r.Target(), granularity)) {
+			kept = append(kept, r)
+		}
+	}
+	atomic.AddInt64(&w.stats.DuplicateURLs, int64(len(results)-len(kept)))
+	return kept
+}
+
+// processFetch retrieves an arbitrary URL through the proxy pool
+func (w *Worker) processFetch(ctx context.Context, workerID int, task *Task) {
+	startTime := time.Now()
+
+	prx, err := w.pool.Get()
+	if err != nil {
+		w.sendResult(&Result{
+			TaskID:    task.ID,
+			URL:       task.URL,
+			Status:    StatusError,
+			Error:     fmt.Sprintf("no proxy available: %v", err),
+			Duration:  time.Since(startTime),
+			Timestamp: time.Now(),
+		})
+		atomic.AddInt64(&w.stats.TasksFailed, 1)
+		return
+	}
+
+	body := getBodyBuffer()
+	reqCtx, recorder := w.traceRequest(ctx)
+	statusCode, err := w.doRequest(reqCtx, task.URL, prx, "", false, body)
+	duration := time.Since(startTime)
+	task.trace = recorder.Timings()
+
+	// Only the compressed copy is sent on, so the buffer goes back to the
+	// pool before the result is emitted
+	var compressed []byte
+	var compressErr error
+	if err == nil && statusCode != http.StatusTooManyRequests && statusCode != http.StatusForbidden {
+		compressed, compressErr = compressBody(body.Bytes())
+	}
+	putBodyBuffer(body)
+
+	if err != nil && ctx.Err() != nil {
+		w.recordRequest(task, task.URL, prx, statusCode, StatusTimeout, err, duration)
+		w.sendTimeout(task, prx, duration)
+		return
+	}
+
+	if err != nil {
+		w.recordRequest(task, task.URL, prx, statusCode, StatusError, err, duration)
+		w.pool.ReportFailure(prx.ID)
+		w.handleRequestError(task, prx, err, duration)
+		return
+	}
+
+	// Rate limiting and outright refusals count against the proxy
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusForbidden {
+		w.recordRequest(task, task.URL, prx, statusCode, StatusBlocked, nil, duration)
+		w.pool.ReportBlock(prx.ID)
+		atomic.AddInt64(&w.stats.BlockCount, 1)
+
+		if task.Retry < w.currentConfig().MaxRetries {
+			task.Retry++
+			w.retryTask(task)
+			return
+		}
+
+		w.sendResult(&Result{
+			TaskID:     task.ID,
+			URL:        task.URL,
+			Status:     StatusBlocked,
+			StatusCode: statusCode,
+			ProxyID:    prx.ID,
+			Duration:   duration,
+			Timestamp:  time.Now(),
+			Trace:      task.trace,
+		})
+		atomic.AddInt64(&w.stats.TasksFailed, 1)
+		return
+	}
+
+	w.recordRequest(task, task.URL, prx, statusCode, StatusSuccess, nil, duration)
+	w.pool.ReportSuccess(prx.ID, duration)
+
+	if compressErr != nil {
+		w.sendResult(&Result{
+			TaskID:     task.ID,
+			URL:        task.URL,
+			Status:     StatusError,
+			Error:      fmt.Sprintf("failed to compress body: %v", compressErr),
+			StatusCode: statusCode,
+			ProxyID:    prx.ID,
+			Duration:   duration,
+			Timestamp:  time.Now(),
+			Trace:      task.trace,
+		})
+		atomic.AddInt64(&w.stats.TasksFailed, 1)
+		return
+	}
+
+	atomic.AddInt64(&w.stats.TasksCompleted, 1)
+
+	w.sendResult(&Result{
+		TaskID:     task.ID,
+		URL:        task.URL,
+		Status:     StatusSuccess,
+		StatusCode: statusCode,
+		Body:       compressed,
+		ProxyID:    prx.ID,
+		Duration:   duration,
+		Timestamp:  time.Now(),
+		Trace:      task.trace,
+	})
+
+	w.applyDelay(prx)
+}
+
+// makeRequest makes a search request through a proxy and returns the status
+// code and page HTML
+func (w *Worker) makeRequest(ctx context.Context, task *Task, targetURL string, prx *proxy.Proxy) (int, string, error) {
+	body := getBodyBuffer()
+	defer putBodyBuffer(body)
+
+	statusCode, err := w.doRequest(ctx, targetURL, prx, refererFor(targetURL), w.currentConfig().Mobile, body)
+	if err != nil {
+		return statusCode, "", err
+	}
+
+	// The dump is written from the fetch buffer, blocked pages included, so
+	// keeping pages costs no extra copy
+	if w.htmlDump != nil {
+		w.htmlDump.WritePage(task.ID, task.Page, task.Retry+1, body.Bytes())
+	}
+
+	// Check status code
+	if statusCode != http.StatusOK {
+		return statusCode, "", fmt.Errorf("bad status code: %d", statusCode)
+	}
+
+	return statusCode, body.String(), nil
+}
+
+// traceRequest returns ctx set to trace the request made with it, for the
+// TraceRate sample of requests; the recorder is nil for the others
+func (w *Worker) traceRequest(ctx context.Context) (context.Context, *reqtrace.Recorder) {
+	if !reqtrace.Sample(w.currentConfig().TraceRate) {
+		return ctx, nil
+	}
+	return reqtrace.Start(ctx)
+}
+
+// refererFor returns the home page of the engine domain a search URL is on,
+// so the referer matches the domain the session searches
+func refererFor(targetURL string) string {
+	parsed, err := url.Parse(targetURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/"
+}
+
+// locale returns the search locale of the proxy's exit country when
+// GeoMatch is on and the country is known
+func (w *Worker) locale(prx *proxy.Proxy) (engine.Locale, bool) {
+	if !w.currentConfig().GeoMatch {
+		return engine.Locale{}, false
+	}
+	return engine.LocaleFor(prx.CountryCode())
+}
+
+// searchURL builds the search URL for a dork sent through prx, localized to
+// its exit country with GeoMatch
+func (w *Worker) searchURL(dork string, page int, prx *proxy.Proxy) string {
+	google := w.engine.(*engine.Google)
+	resultsPerPage := w.currentConfig().ResultsPerPage
+	if locale, ok := w.locale(prx); ok {
+		return google.BuildLocalizedURL(dork, page, resultsPerPage, locale)
+	}
+	return google.BuildSearchURL(dork, page, resultsPerPage)
+}
+
+// doRequest performs a GET through a proxy with stealth headers applied,
+// those of the session's phone when mobile, and reads the response body
+// into body
+func (w *Worker) doRequest(ctx context.Context, targetURL string, prx *proxy.Proxy, referer string, mobile bool, body *bytes.Buffer) (int, error) {
+	// Parse proxy URL
+	// The parse error would echo the password, so report the redacted form
+	proxyURL, err := url.Parse(prx.URL())
+	if err != nil {
+		return 0, fmt.Errorf("invalid proxy URL: %s", prx)
+	}
+
+	// Create transport with proxy
+	selectProxy, dial := prx.Route(proxyURL, w.egress.DialContext, w.hosts)
+	transport := &http.Transport{
+		Proxy:               selectProxy,
+		DialContext:         dial,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     w.tlsConfig,
+	}
+
+	// Create client
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   w.currentConfig().RequestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set the headers of the proxy's session, so one exit IP always shows
+	// the same browser
+	session := w.stealth.Session(prx.ID)
+	headers := session.Headers()
+	if mobile {
+		headers = session.MobileHeaders()
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if locale, ok := w.locale(prx); ok {
+		req.Header.Set("Accept-Language", locale.AcceptLanguage)
+	}
+
+	// Additional headers
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+	req.Header.Set("DNT", "1")
+
+	// Make request; the proxy is charged for whatever crossed the wire
+	var transferred int64
+	if w.cost != nil {
+		defer func() { w.cost.ChargeProxy(prx.ID, prx.Host, transferred) }()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read body
+	if err := readBody(resp, &transferred, body); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// readBody reads a response body, undoing gzip content encoding, and adds
+// the encoded size to transferred. Accept-Encoding is set explicitly by the
+// stealth headers, which disables the transport's transparent decompression.
+func readBody(resp *http.Response, transferred *int64, body *bytes.Buffer) error {
+	var reader io.Reader = &countingReader{r: resp.Body, n: transferred}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	_, err := body.ReadFrom(reader)
+	return err
+}
+
+// bodyPool recycles response body buffers, so a busy worker reuses a few
+// page-sized buffers instead of growing a fresh one for every response
+var bodyPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBody keeps an unusually large response from pinning its buffer
+// in the pool
+const maxPooledBody = 4 << 20
+
+func getBodyBuffer() *bytes.Buffer {
+	return bodyPool.Get().(*bytes.Buffer)
+}
+
+// putBodyBuffer returns a buffer to the pool; nothing may use its bytes
+// afterwards
+func putBodyBuffer(body *bytes.Buffer) {
+	if body.Cap() > maxPooledBody {
+		return
+	}
+	body.Reset()
+	bodyPool.Put(body)
+}
+
+// countingReader adds the bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// compressBody gzips a response body for transport back to the controller
+func compressBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// recordRequest counts an outgoing request in the pacing recorder and
+// appends it to the audit log, for whichever is set
+func (w *Worker) recordRequest(task *Task, targetURL string, prx *proxy.Proxy, statusCode int, outcome ResultStatus, err error, duration time.Duration) {
+	if w.pacing != nil {
+		w.pacing.Record(time.Now(), w.engineName(task), pacingOutcome(outcome))
+	}
+
+	if w.audit == nil {
+		return
+	}
+
+	record := audit.Record{
+		TaskID:     task.ID,
+		Engine:     w.engineName(task),
+		Dork:       task.Dork,
+		Page:       task.Page,
+		Attempt:    task.Retry + 1,
+		Proxy:      prx.ID,
+		StatusCode: statusCode,
+		Outcome:    string(outcome),
+		DurationMs: duration.Milliseconds(),
+	}
+	if task.Type == TaskTypeFetch {
+		record.URL = targetURL
+	}
+	if parsed, perr := url.Parse(targetURL); perr == nil {
+		record.Domain = parsed.Hostname()
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	w.audit.Record(record)
+}
+
+// recordStep records a request a step made on behalf of task's results,
+// under the step's name and with its URL
+func (w *Worker) recordStep(task *Task, step string, target *url.URL, prx *proxy.Proxy, statusCode int, outcome ResultStatus, err error, duration time.Duration) {
+	if w.pacing != nil {
+		w.pacing.Record(time.Now(), step, pacingOutcome(outcome))
+	}
+
+	if w.audit == nil {
+		return
+	}
+
+	record := audit.Record{
+		TaskID:     task.ID,
+		Engine:     step,
+		Domain:     target.Hostname(),
+		Dork:       task.Dork,
+		URL:        target.String(),
+		Page:       task.Page,
+		Attempt:    task.Retry + 1,
+		Proxy:      prx.ID,
+		StatusCode: statusCode,
+		Outcome:    string(outcome),
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	w.audit.Record(record)
+}
+
+// pacingOutcome maps a request outcome onto the pacing categories
+func pacingOutcome(status ResultStatus) pacing.Outcome {
+	switch status {
+	case StatusSuccess, StatusNoResults:
+		return pacing.OutcomeSuccess
+	case StatusBlocked:
+		return pacing.OutcomeBlocked
+	case StatusCaptcha:
+		return pacing.OutcomeCaptcha
+	}
+	return pacing.OutcomeError
+}
+
+// handleRequestError handles request errors
+func (w *Worker) handleRequestError(task *Task, prx *proxy.Proxy, err error, duration time.Duration) {
+	// Retry if possible
+	if task.Retry < w.currentConfig().MaxRetries {
+		task.Retry++
+		w.retryTask(task)
+		return
+	}
+
+	w.sendResult(&Result{
+		TaskID:    task.ID,
+		Dork:      task.Dork,
+		URL:       task.URL,
+		Status:    StatusError,
+		Error:     err.Error(),
+		ProxyID:   prx.ID,
+		Duration:  duration,
+		Timestamp: time.Now(),
+
+		Trace: task.trace,
+	})
+	atomic.AddInt64(&w.stats.TasksFailed, 1)
+}
+
+// taskContext returns the context for a task's requests: the run context,
+// narrowed to the task deadline when there is one
+func (w *Worker) taskContext(task *Task) (context.Context, context.CancelFunc) {
+	if task.Deadline.IsZero() {
+		if timeout := w.currentConfig().TaskTimeout; timeout > 0 {
+			task.Deadline = time.Now().Add(timeout)
+		}
+	}
+	if task.Deadline.IsZero() {
+		return context.WithCancel(w.runCtx)
+	}
+	return context.WithDeadline(w.runCtx, task.Deadline)
+}
+
+// engineName returns the budget and audit name for the engine serving task
+func (w *Worker) engineName(task *Task) string {
+	if task.Type == TaskTypeFetch {
+		return string(TaskTypeFetch)
+	}
+	return w.engine.Name()
+}
+
+// reserveRequest counts one outgoing request against the global and engine
+// budgets. Running out of global budget ends the run; running out of an
+// engine budget only refuses that engine's requests.
+func (w *Worker) reserveRequest(engineName string) error {
+	cfg := w.currentConfig()
+
+	w.budgetMu.Lock()
+	defer w.budgetMu.Unlock()
+
+	if cfg.MaxRequests > 0 && atomic.LoadInt64(&w.stats.Requests) >= int64(cfg.MaxRequests) {
+		w.endRun(ErrBudgetExhausted)
+		return ErrBudgetExhausted
+	}
+	if limit := cfg.EngineMaxRequests[engineName]; limit > 0 && w.engineRequests[engineName] >= limit {
+		return fmt.Errorf("%s %w", engineName, ErrBudgetExhausted)
+	}
+
+	w.engineRequests[engineName]++
+	atomic.AddInt64(&w.stats.Requests, 1)
+	if w.cost != nil {
+		w.cost.ChargeEngine(engineName)
+	}
+	return nil
+}
+
+// sendSkipped reports a task dropped without a request because a budget ran
+// out
+func (w *Worker) sendSkipped(task *Task, err error) {
+	w.sendResult(&Result{
+		TaskID:    task.ID,
+		Dork:      task.Dork,
+		URL:       task.URL,
+		Status:    StatusSkipped,
+		Error:     err.Error(),
+		Timestamp: time.Now(),
+	})
+	atomic.AddInt64(&w.stats.TasksFailed, 1)
+}
+
+// sendTimeout reports a task abandoned because its own deadline or the run
+// deadline passed. prx is nil when no request was attempted.
+func (w *Worker) sendTimeout(task *Task, prx *proxy.Proxy, duration time.Duration) {
+	reason := "task deadline exceeded"
+	if w.runCtx.Err() != nil {
+		reason = "run deadline exceeded"
+	}
+
+	result := &Result{
+		TaskID:    task.ID,
+		Dork:      task.Dork,
+		URL:       task.URL,
+		Status:    StatusTimeout,
+		Error:     reason,
+		Duration:  duration,
+		Timestamp: time.Now(),
+	}
+	if prx != nil {
+		result.ProxyID = prx.ID
+		result.Trace = task.trace
+	}
+	w.sendResult(result)
+	atomic.AddInt64(&w.stats.TasksFailed, 1)
+}
+
+// wait sleeps for d, returning early if the worker stops or the run deadline
+// passes
+func (w *Worker) wait(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-w.stopCh:
+	case <-w.runCtx.Done():
+	}
+}
+
+// retryTask requeues a task for retry
+func (w *Worker) retryTask(task *Task) {
+	// Apply retry delay
+	w.wait(w.currentConfig().RetryDelay)
+
+	select {
+	case w.tasks <- task:
+		// Requeued successfully
+	default:
+		// Buffer full, send error
+		w.sendResult(&Result{
+			TaskID:    task.ID,
+			Dork:      task.Dork,
+			Status:    StatusError,
+			Error:     "retry buffer full",
+			Timestamp: time.Now(),
+		})
+		atomic.AddInt64(&w.stats.TasksFailed, 1)
+	}
+}
+
+// sendResult sends a result to the results channel
+func (w *Worker) sendResult(result *Result) {
+	duplicates := w.settle(result)
+
+	select {
+	case w.results <- result:
+		// Sent successfully
+	default:
+		// Results buffer full, drop oldest or log
+	}
+
+	// Copies resent while the task ran follow its own result
+	for _, duplicate := range duplicates {
+		w.sendResult(duplicate)
+	}
+}
+
+// applyDelay applies a randomized delay between requests, at the pace of
+// the session of the proxy just used
+func (w *Worker) applyDelay(prx *proxy.Proxy) {
+	cfg := w.currentConfig()
 	config := stealth.TimingConfig{
-		BaseDelay:     w.config.BaseDelay,
-		MinDelay:      w.config.MinDelay,
-		MaxDelay:      w.config.MaxDelay,
+		BaseDelay:     cfg.BaseDelay,
+		MinDelay:      cfg.MinDelay,
+		MaxDelay:      cfg.MaxDelay,
 		JitterPercent: 0.3,
 	}
 
-	delay := stealth.CalculateDelay(config, nil)
-	time.Sleep(delay)
+	delay := w.stealth.Session(prx.ID).Delay(config)
+	w.wait(delay)
 }
 
 // SetEngine sets a custom search engine
@@ -485,8 +2270,80 @@ func (w *Worker) SetEngine(e engine.SearchEngine) {
 	w.engine = e
 }
 
-// SetStealthManager sets a custom stealth manager
+// SetAuditLog records every outgoing request to the given audit log
+func (w *Worker) SetAuditLog(log *audit.Log) {
+	w.audit = log
+}
+
+// SetEgress connects to the scraping proxies through an egress proxy
+func (w *Worker) SetEgress(d *egress.Dialer) {
+	w.egress = d
+}
+
+// SetHosts pins host names to addresses for requests through every proxy;
+// a proxy's own overrides win
+func (w *Worker) SetHosts(hosts proxy.Hosts) {
+	w.hosts = hosts
+}
+
+// SetPacingRecorder counts every outgoing request in the given recorder
+func (w *Worker) SetPacingRecorder(recorder *pacing.Recorder) {
+	w.pacing = recorder
+}
+
+// SetParamHarvester collects the query parameter names of every reported
+// URL in the given harvester
+func (w *Worker) SetParamHarvester(harvester *params.Harvester) {
+	w.params = harvester
+}
+
+// SetDedup drops URLs already returned earlier in the run using set; nil
+// returns every URL
+func (w *Worker) SetDedup(set dedup.Set) {
+	w.dedup = set
+}
+
+// SetCache serves search tasks from c when it holds their page; nil makes
+// a request for every task
+func (w *Worker) SetCache(c *cache.Cache) {
+	w.cache = c
+}
+
+// SetClassifier consults c about pages without results that carry no
+// no-results notice; nil keeps the heuristic verdict
+func (w *Worker) SetClassifier(c classify.Classifier) {
+	w.classifier = c
+}
+
+// SetHTMLDump writes every fetched results page to dumper; nil disables
+func (w *Worker) SetHTMLDump(dumper *htmldump.Dumper) {
+	w.htmlDump = dumper
+}
+
+// SetAlertHandler calls fn for every alert the worker raises. fn runs on
+// the goroutine that detected the condition and must not block.
+func (w *Worker) SetAlertHandler(fn func(Alert)) {
+	w.onAlert = fn
+}
+
+// SetAlertErrorHandler calls fn for every alert notification that could
+// not be delivered. Call it before Start.
+func (w *Worker) SetAlertErrorHandler(fn func(error)) {
+	if w.alerts != nil {
+		w.alerts.SetErrorHandler(fn)
+	}
+}
+
+// SetTLSConfig sets the TLS settings of outgoing requests, such as the root
+// CAs of an intercepting proxy; nil uses the system defaults
+func (w *Worker) SetTLSConfig(config *tls.Config) {
+	w.tlsConfig = config
+}
+
+// SetStealthManager sets a custom stealth manager, deriving its sessions
+// from the worker's seed
 func (w *Worker) SetStealthManager(m *stealth.Manager) {
+	m.SetSeed(w.currentConfig().Seed)
 	w.stealth = m
 }
 
@@ -500,6 +2357,17 @@ func (w *Worker) TaskQueueLength() int {
 	return len(w.tasks)
 }
 
+// ParseQueueLength returns the number of fetched pages waiting to be parsed
+func (w *Worker) ParseQueueLength() int {
+	return len(w.parseJobs)
+}
+
+// EnrichQueueLength returns the number of parsed pages waiting for an
+// enrich worker
+func (w *Worker) EnrichQueueLength() int {
+	return len(w.enrichJobs)
+}
+
 // ResultQueueLength returns the current result queue length
 func (w *Worker) ResultQueueLength() int {
 	return len(w.results)
@@ -1,15 +1,38 @@
 package worker
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"dorker/worker/internal/audit"
+	"dorker/worker/internal/cache"
+	"dorker/worker/internal/classify"
+	"dorker/worker/internal/cost"
+	"dorker/worker/internal/dedup"
+	"dorker/worker/internal/dirlist"
+	"dorker/worker/internal/engine"
+	"dorker/worker/internal/fingerprint"
+	"dorker/worker/internal/pacing"
+	"dorker/worker/internal/params"
 	"dorker/worker/internal/proxy"
+	"dorker/worker/internal/resolve"
+	"dorker/worker/internal/verify"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -93,6 +116,55 @@ func TestWorkerStartStop(t *testing.T) {
 	}
 }
 
+func TestWorkerPauseResume(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>target page</html>"))
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Workers = 1
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.Start()
+	w.Pause()
+	if w.IsRunning() {
+		t.Fatal("worker should not be running while paused")
+	}
+
+	// A resumed worker runs tasks on fresh channels and delivers their
+	// results where it did before
+	if err := w.Start(); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if err := w.Submit(&Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	select {
+	case result := <-w.Results():
+		if result.Status != StatusSuccess {
+			t.Errorf("Status = %q, want %q (error: %s)", result.Status, StatusSuccess, result.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result after resuming")
+	}
+
+	w.Pause()
+	w.Stop()
+	if _, ok := <-w.Results(); ok {
+		t.Error("results should be closed after Stop")
+	}
+	if err := w.Start(); !errors.Is(err, ErrStopped) {
+		t.Errorf("Start after Stop = %v, want ErrStopped", err)
+	}
+	w.Stop()
+}
+
 func TestWorkerSubmitNotRunning(t *testing.T) {
 	config := DefaultConfig()
 	pool := proxy.NewPool(proxy.DefaultPoolConfig())
@@ -278,7 +350,10 @@ func TestWorkerCaptchaDetection(t *testing.T) {
 	defer server.Close()
 
 	// Verify CAPTCHA HTML is detected
-	resp, _ := http.Get(server.URL)
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("mock server request failed: %v", err)
+	}
 	defer resp.Body.Close()
 
 	// Read body and check
@@ -299,7 +374,10 @@ func TestWorkerBlockDetection(t *testing.T) {
 	}))
 	defer server.Close()
 
-	resp, _ := http.Get(server.URL)
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("mock server request failed: %v", err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusForbidden {
@@ -433,7 +511,7 @@ func TestWorkerApplyDelay(t *testing.T) {
 
 	// Measure delay
 	start := time.Now()
-	w.applyDelay()
+	w.applyDelay(&proxy.Proxy{ID: "test_1"})
 	elapsed := time.Since(start)
 
 	if elapsed < config.MinDelay {
@@ -445,6 +523,49 @@ func TestWorkerApplyDelay(t *testing.T) {
 	}
 }
 
+func TestWorkerSeed(t *testing.T) {
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	// Without a seed the worker picks one and reports it
+	w := New(DefaultConfig(), pool)
+	seed := w.Config().Seed
+	if seed == 0 {
+		t.Fatal("no seed picked")
+	}
+
+	// That seed gives every proxy the same session in another run
+	config := DefaultConfig()
+	config.Seed = seed
+	again := New(config, pool)
+	for _, id := range []string{"p1", "p2", "p3"} {
+		first, second := w.stealth.Session(id), again.stealth.Session(id)
+		if first.Fingerprint.ID != second.Fingerprint.ID || first.Pace != second.Pace {
+			t.Errorf("%s: session %s at %v, then %s at %v", id,
+				first.Fingerprint.ID, first.Pace, second.Fingerprint.ID, second.Pace)
+		}
+	}
+
+	// A reload keeps the run's seed
+	config.Seed = 7
+	again.Reconfigure(config)
+	if got := again.Config().Seed; got != seed {
+		t.Errorf("seed after Reconfigure = %d, want %d", got, seed)
+	}
+}
+
+func TestRefererFor(t *testing.T) {
+	tests := map[string]string{
+		"https://www.google.de/search?q=x": "https://www.google.de/",
+		"http://127.0.0.1:8080/search":     "http://127.0.0.1:8080/",
+		"not a url":                        "",
+	}
+	for target, want := range tests {
+		if got := refererFor(target); got != want {
+			t.Errorf("refererFor(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
 func TestWorkerSendResult(t *testing.T) {
 	config := DefaultConfig()
 	config.BufferSize = 5
@@ -497,3 +618,1461 @@ func TestConfigValidation(t *testing.T) {
 		t.Errorf("ResultsPerPage = %d, should be between 10 and 100", config.ResultsPerPage)
 	}
 }
+
+// newTestProxy starts a server that answers proxied HTTP requests directly
+// and returns it along with a pool proxy pointing at it
+func newTestProxy(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *proxy.Proxy) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+
+	return server, &proxy.Proxy{
+		ID:   "test_proxy",
+		Host: host,
+		Port: port,
+		Type: proxy.ProxyTypeHTTP,
+	}
+}
+
+func TestWorkerSubmitFetchRequiresURL(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+	w.running.Store(true)
+
+	if err := w.Submit(&Task{ID: "fetch_1", Type: TaskTypeFetch}); err == nil {
+		t.Error("Submit should fail for fetch task without url")
+	}
+}
+
+func TestWorkerProcessFetch(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() != "http://target.test/page" {
+			t.Errorf("proxied URL = %q", r.URL.String())
+		}
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("User-Agent header missing")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html>target page</html>"))
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/page"})
+
+	result := <-w.results
+	if result.Status != StatusSuccess {
+		t.Fatalf("Status = %q, want %q (error: %s)", result.Status, StatusSuccess, result.Error)
+	}
+
+	if result.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", result.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(result.Body))
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if string(body) != "<html>target page</html>" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestWorkerProcessFetchBlocked(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"})
+
+	result := <-w.results
+	if result.Status != StatusBlocked {
+		t.Errorf("Status = %q, want %q", result.Status, StatusBlocked)
+	}
+
+	if pool.Stats().Quarantined != 1 {
+		t.Error("blocked proxy should be quarantined")
+	}
+}
+
+func TestWorkerTraceRate(t *testing.T) {
+	for _, rate := range []float64{0, 100} {
+		server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+		defer server.Close()
+
+		config := DefaultConfig()
+		config.MaxRetries = 0
+		config.TraceRate = rate
+		pool := proxy.NewPool(proxy.DefaultPoolConfig())
+		pool.AddProxy(prx)
+
+		w := New(config, pool)
+		w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"})
+
+		result := <-w.results
+		if result.Status != StatusBlocked {
+			t.Fatalf("Status = %q, want %q (error: %s)", result.Status, StatusBlocked, result.Error)
+		}
+		if rate == 0 && result.Trace != nil {
+			t.Errorf("rate 0 traced the request: %+v", result.Trace)
+		}
+		if rate == 100 && (result.Trace == nil || result.Trace.Error != "") {
+			t.Errorf("rate 100: blocked result trace = %+v, want timings of a response", result.Trace)
+		}
+	}
+}
+
+func TestWorkerDrain(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0 // Nothing consumes the queue
+	config.BufferSize = 10
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+	w.Start()
+	w.Submit(&Task{ID: "1", Dork: "test"})
+
+	// Queue never empties, so the drain must time out and still stop
+	if w.Drain(100 * time.Millisecond) {
+		t.Error("Drain should report timeout with queued tasks")
+	}
+
+	if w.IsRunning() {
+		t.Error("worker should be stopped after Drain")
+	}
+}
+
+func TestWorkerTakePending(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0 // Nothing consumes the queue
+	config.BufferSize = 10
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+	w.Start()
+	w.Submit(&Task{ID: "1", Dork: "first"})
+	w.Submit(&Task{ID: "2", Dork: "second"})
+
+	if pending := w.TakePending(); pending != nil {
+		t.Errorf("TakePending of a running worker = %v, want nil", pending)
+	}
+
+	w.Stop()
+	pending := w.TakePending()
+	if len(pending) != 2 || pending[0].ID != "1" || pending[1].ID != "2" {
+		t.Errorf("pending = %v, want tasks 1 and 2 in order", pending)
+	}
+	if w.TaskQueueLength() != 0 {
+		t.Errorf("queue length = %d after TakePending, want 0", w.TaskQueueLength())
+	}
+}
+
+func TestWorkerDrainEmpty(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 1
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+	w.Start()
+
+	if !w.Drain(time.Second) {
+		t.Error("Drain of an idle worker should succeed")
+	}
+}
+
+func TestWorkerSubmitWhileDraining(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+	w.running.Store(true)
+	w.draining.Store(true)
+
+	if err := w.Submit(&Task{ID: "1", Dork: "test"}); err == nil {
+		t.Error("Submit should fail while draining")
+	}
+}
+
+func TestWorkerReconfigure(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 3
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+
+	w := New(config, pool)
+
+	updated := DefaultConfig()
+	updated.Workers = 50
+	updated.BaseDelay = 42 * time.Millisecond
+	updated.MaxRetries = 7
+	w.Reconfigure(updated)
+
+	current := w.currentConfig()
+	if current.BaseDelay != 42*time.Millisecond || current.MaxRetries != 7 {
+		t.Errorf("config not applied: %+v", current)
+	}
+
+	if current.Workers != 3 {
+		t.Errorf("Workers = %d, should stay fixed at 3", current.Workers)
+	}
+}
+
+func TestWorkerAuditLog(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "blocked.test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html><body>nothing here</body></html>"))
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("audit.Open failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	config.MaxRetries = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.SetAuditLog(log)
+
+	w.processTask(0, &Task{ID: "search_1", Dork: "inurl:admin", Page: 2})
+	<-w.results
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://blocked.test/x"})
+	<-w.results
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit lines = %d, want 2:\n%s", len(lines), data)
+	}
+
+	var search, fetch audit.Record
+	json.Unmarshal([]byte(lines[0]), &search)
+	json.Unmarshal([]byte(lines[1]), &fetch)
+
+	// The test proxy cannot tunnel HTTPS, so the search request fails
+	if search.Engine != "google" || search.Domain != "www.google.com" || search.Dork != "inurl:admin" ||
+		search.Page != 2 || search.Proxy != "test_proxy" || search.Outcome != string(StatusError) || search.Error == "" {
+		t.Errorf("search record = %+v", search)
+	}
+
+	if fetch.Engine != "fetch" || fetch.Domain != "blocked.test" || fetch.URL != "http://blocked.test/x" ||
+		fetch.StatusCode != http.StatusForbidden || fetch.Outcome != string(StatusBlocked) || fetch.Attempt != 1 {
+		t.Errorf("fetch record = %+v", fetch)
+	}
+}
+
+func TestWorkerWarmUpDelay(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 4
+	config.WarmUp = 4 * time.Second
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+	for id, want := range []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second} {
+		if got := w.warmUpDelay(id); got != want {
+			t.Errorf("warmUpDelay(%d) = %v, want %v", id, got, want)
+		}
+	}
+
+	// Stop must not wait for workers still warming up
+	w.Start()
+	start := time.Now()
+	w.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop took %v while workers were warming up", elapsed)
+	}
+}
+
+func TestWorkerTaskDeadline(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.TaskTimeout = 100 * time.Millisecond
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+
+	// An already expired task is not attempted
+	w.processTask(0, &Task{ID: "late", Type: TaskTypeFetch, URL: "http://target.test/", Deadline: time.Now().Add(-time.Second)})
+	result := <-w.results
+	if result.Status != StatusTimeout || result.ProxyID != "" {
+		t.Errorf("expired task result = %+v", result)
+	}
+
+	// TaskTimeout cancels the in-flight request without blaming the proxy
+	start := time.Now()
+	w.processTask(0, &Task{ID: "slow", Type: TaskTypeFetch, URL: "http://target.test/"})
+	result = <-w.results
+	if result.Status != StatusTimeout || result.Error != "task deadline exceeded" {
+		t.Errorf("slow task result = %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("task ran %v past its 100ms timeout", elapsed)
+	}
+	if pool.Stats().Alive != 1 {
+		t.Error("proxy should stay alive after a task timeout")
+	}
+	if w.Stats().TasksFailed != 2 {
+		t.Errorf("TasksFailed = %d, want 2", w.Stats().TasksFailed)
+	}
+}
+
+func TestWorkerRunDeadline(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Workers = 1
+	config.MaxRunDuration = 100 * time.Millisecond
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.Start()
+	for i := 0; i < 3; i++ {
+		w.Submit(&Task{ID: fmt.Sprintf("fetch_%d", i), Type: TaskTypeFetch, URL: "http://target.test/"})
+	}
+
+	select {
+	case <-w.Ended():
+	case <-time.After(time.Second):
+		t.Fatal("run deadline did not expire")
+	}
+
+	// The in-flight request is cancelled and queued tasks flush as timeouts
+	if !w.Drain(time.Second) {
+		t.Fatal("drain did not finish after the run deadline")
+	}
+	count := 0
+	for result := range w.Results() {
+		if result.Status != StatusTimeout || result.Error != "run deadline exceeded" {
+			t.Errorf("result = %+v", result)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("results = %d, want 3", count)
+	}
+}
+
+func TestWorkerRequestBudget(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	config.MaxRequests = 2
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	for i := 0; i < 3; i++ {
+		w.processTask(0, &Task{ID: fmt.Sprintf("fetch_%d", i), Type: TaskTypeFetch, URL: "http://target.test/"})
+	}
+
+	for i, want := range []ResultStatus{StatusSuccess, StatusSuccess, StatusSkipped} {
+		if result := <-w.results; result.Status != want {
+			t.Errorf("result %d status = %q, want %q", i, result.Status, want)
+		}
+	}
+
+	select {
+	case <-w.Ended():
+	default:
+		t.Fatal("run should end once the budget is spent")
+	}
+	if !errors.Is(w.EndReason(), ErrBudgetExhausted) {
+		t.Errorf("EndReason = %v, want %v", w.EndReason(), ErrBudgetExhausted)
+	}
+	if w.Stats().Requests != 2 {
+		t.Errorf("Requests = %d, want 2", w.Stats().Requests)
+	}
+}
+
+func TestWorkerEngineRequestBudget(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	config.EngineMaxRequests = map[string]int{"fetch": 1}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"})
+	w.processTask(0, &Task{ID: "fetch_2", Type: TaskTypeFetch, URL: "http://target.test/"})
+
+	<-w.results
+	result := <-w.results
+	if result.Status != StatusSkipped || result.Error != "fetch request budget exhausted" {
+		t.Errorf("over-budget result = %+v", result)
+	}
+
+	// An engine budget refuses that engine's requests without ending the run
+	if w.EndReason() != nil {
+		t.Errorf("EndReason = %v, want nil", w.EndReason())
+	}
+}
+
+func TestWorkerCostEstimate(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(make([]byte, 1024))
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseDelay = 0
+	config.MinDelay = 0
+	config.MaxDelay = 0
+	config.Costs = cost.Model{
+		Proxies: map[string]cost.Rate{"*": {PerRequest: 0.01, PerGB: 1 << 20}},
+		Engines: map[string]cost.Rate{"fetch": {PerRequest: 0.5}},
+	}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	w := New(config, pool)
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"})
+	<-w.results
+
+	// 0.01 per request + 1KB at 2^20 per GB (1.0) + 0.5 for the engine
+	summary := w.CostSummary()
+	if summary.Bytes != 1024 {
+		t.Errorf("Bytes = %d, want 1024", summary.Bytes)
+	}
+	if got := w.Stats().EstimatedCost; got < 1.5099 || got > 1.5101 {
+		t.Errorf("EstimatedCost = %v, want 1.51", got)
+	}
+}
+
+func TestWorkerPacingRecorder(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 0
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+
+	recorder := pacing.NewRecorder(time.Minute)
+	w := New(config, pool)
+	w.SetPacingRecorder(recorder)
+
+	w.processTask(0, &Task{ID: "fetch_1", Type: TaskTypeFetch, URL: "http://target.test/"})
+	<-w.results
+
+	buckets := recorder.Buckets()
+	if len(buckets) != 1 || buckets[0].Engine != "fetch" || buckets[0].Requests != 1 || buckets[0].Blocks != 1 {
+		t.Errorf("buckets = %+v", buckets)
+	}
+}
+
+func TestWorkerDedupe(t *testing.T) {
+	w := New(DefaultConfig(), proxy.NewPool(proxy.DefaultPoolConfig()))
+
+	page := []engine.SearchResult{{URL: "https://a.example/"}, {URL: "https://b.example/"}}
+	if got := w.dedupe(&Task{}, page); len(got) != 2 {
+		t.Errorf("without a set dedupe kept %d of 2", len(got))
+	}
+
+	set, _ := dedup.New(dedup.Config{Mode: dedup.ModeExact})
+	w.SetDedup(set)
+
+	w.dedupe(&Task{}, []engine.SearchResult{{URL: "https://a.example/"}})
+	got := w.dedupe(&Task{}, []engine.SearchResult{{URL: "https://a.example/"}, {URL: "https://c.example/"}})
+	if len(got) != 1 || got[0].URL != "https://c.example/" {
+		t.Errorf("dedupe = %+v, want only c.example", got)
+	}
+	if w.Stats().DuplicateURLs != 1 {
+		t.Errorf("DuplicateURLs = %d, want 1", w.Stats().DuplicateURLs)
+	}
+}
+
+func TestWorkerDedupeGranularity(t *testing.T) {
+	config := DefaultConfig()
+	config.Dedup.Granularity = dedup.GranularityPath
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	set, _ := dedup.New(dedup.Config{Mode: dedup.ModeExact})
+	w.SetDedup(set)
+
+	page := []engine.SearchResult{
+		{URL: "https://a.example/item.php?id=1"},
+		{URL: "https://a.example/item.php?id=2"},
+		{URL: "https://a.example/list.php?id=1"},
+	}
+	if got := w.dedupe(&Task{}, page); len(got) != 2 {
+		t.Errorf("path granularity kept %d of 3, want 2", len(got))
+	}
+
+	// A task's own granularity wins over the configured one
+	page = []engine.SearchResult{{URL: "https://b.example/x"}, {URL: "https://b.example/y"}}
+	if got := w.dedupe(&Task{DedupGranularity: dedup.GranularityDomain}, page); len(got) != 1 {
+		t.Errorf("domain granularity kept %d of 2, want 1", len(got))
+	}
+}
+
+func TestWorkerSubmitRejectsGranularity(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	w.Start()
+	defer w.Stop()
+
+	if err := w.Submit(&Task{ID: "t1", Dork: "a", DedupGranularity: "fuzzy"}); err == nil {
+		t.Error("Submit should reject an unknown dedup granularity")
+	}
+}
+
+func TestWorkerParsePipeline(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.ParseWorkers = 2
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	w.Start()
+
+	page := `<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`
+	prx := &proxy.Proxy{ID: "p1"}
+	for i := 0; i < 6; i++ {
+		w.inFlight.Add(1)
+		w.parseJobs <- &parseJob{task: &Task{ID: fmt.Sprintf("t%d", i), Dork: "inurl:admin"}, prx: prx, statusCode: 200, html: page}
+	}
+
+	// Drain counts handed-over pages as in flight until they are parsed
+	if !w.Drain(time.Second) {
+		t.Fatal("drain did not wait for the parse queue")
+	}
+
+	count := 0
+	for result := range w.Results() {
+		if result.Status != StatusSuccess || len(result.URLs) != 1 || result.URLs[0].URL != "https://a.example/admin" {
+			t.Errorf("result = %+v", result)
+		}
+		count++
+	}
+	if count != 6 {
+		t.Errorf("results = %d, want 6", count)
+	}
+	if w.Stats().URLsFound != 6 {
+		t.Errorf("URLsFound = %d, want 6", w.Stats().URLsFound)
+	}
+}
+
+// blockingClassifier holds every page until release is closed
+type blockingClassifier struct{ release chan struct{} }
+
+func (b *blockingClassifier) Classify(ctx context.Context, page *classify.Page) (classify.Class, error) {
+	<-b.release
+	return classify.ClassEmpty, nil
+}
+
+func TestWorkerEnrichPool(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.ParseWorkers = 1
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	classifier := &blockingClassifier{release: make(chan struct{})}
+	w.SetClassifier(classifier)
+	w.Start()
+
+	prx := &proxy.Proxy{ID: "p1"}
+	ambiguous := `<html><body><div id="main">nothing the parser knows</div></body></html>`
+	plain := `<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`
+	for i, page := range []string{ambiguous, plain} {
+		w.inFlight.Add(1)
+		w.parseJobs <- &parseJob{task: &Task{ID: fmt.Sprintf("t%d", i), Dork: "inurl:admin"}, prx: prx, statusCode: 200, html: page}
+	}
+
+	// The classifier holds the first page on an enrich worker; the parse
+	// worker goes on to the second
+	select {
+	case result := <-w.results:
+		if result.TaskID != "t1" || result.Status != StatusSuccess {
+			t.Errorf("result = %+v, want t1's", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("parse worker waited on the classifier")
+	}
+
+	close(classifier.release)
+	if !w.Drain(time.Second) {
+		t.Fatal("drain did not wait for the enrich worker")
+	}
+	if result := <-w.results; result.TaskID != "t0" || result.Status != StatusNoResults {
+		t.Errorf("result = %+v, want t0 without results", result)
+	}
+}
+
+func TestWorkerParseWorkersDefault(t *testing.T) {
+	w := New(DefaultConfig(), proxy.NewPool(proxy.DefaultPoolConfig()))
+	if w.Config().ParseWorkers < 1 {
+		t.Errorf("ParseWorkers = %d, want one per CPU", w.Config().ParseWorkers)
+	}
+	if cap(w.parseJobs) != parseQueueFactor*w.Config().ParseWorkers {
+		t.Errorf("parse queue holds %d pages", cap(w.parseJobs))
+	}
+	if w.Config().EnrichWorkers != w.Config().Workers {
+		t.Errorf("EnrichWorkers = %d, want Workers = %d", w.Config().EnrichWorkers, w.Config().Workers)
+	}
+}
+
+func TestWorkerParseReleasesPage(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+	job := &parseJob{
+		task: &Task{ID: "t1", Dork: "inurl:admin"},
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: `<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`,
+	}
+	w.parsePage(job)
+
+	// The page must be dropped before the result is emitted
+	if job.html != "" {
+		t.Error("parsePage kept the page after parsing")
+	}
+	if result := <-w.results; len(result.URLs) != 1 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestBodyBufferPool(t *testing.T) {
+	body := getBodyBuffer()
+	body.WriteString("<html>page</html>")
+	putBodyBuffer(body)
+
+	if body.Len() != 0 {
+		t.Error("putBodyBuffer did not reset the buffer")
+	}
+
+	// Oversized buffers are left to the garbage collector
+	large := getBodyBuffer()
+	large.Grow(maxPooledBody + 1)
+	large.WriteString("x")
+	putBodyBuffer(large)
+	if large.Len() != 1 {
+		t.Error("oversized buffer was pooled")
+	}
+}
+
+func TestWorkerGeoMatch(t *testing.T) {
+	german := &proxy.Proxy{ID: "de", Host: "10.0.0.1", Port: "8080", Type: proxy.ProxyTypeHTTP, Country: "DE"}
+	unknown := &proxy.Proxy{ID: "xx", Host: "10.0.0.2", Port: "8080", Type: proxy.ProxyTypeHTTP}
+
+	config := DefaultConfig()
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	if url := w.searchURL("test", 0, german); !strings.Contains(url, "www.google.com/") || !strings.Contains(url, "gl=us") {
+		t.Errorf("without GeoMatch the engine's locale should be used, got %s", url)
+	}
+
+	config.GeoMatch = true
+	w.Reconfigure(config)
+	if url := w.searchURL("test", 0, german); !strings.Contains(url, "www.google.de/") || !strings.Contains(url, "hl=de") || !strings.Contains(url, "gl=de") {
+		t.Errorf("German proxy should search google.de, got %s", url)
+	}
+	if url := w.searchURL("test", 0, unknown); !strings.Contains(url, "www.google.com/") {
+		t.Errorf("proxy without a country should use the default domain, got %s", url)
+	}
+	if _, ok := w.locale(unknown); ok {
+		t.Error("proxy without a country should have no locale")
+	}
+}
+
+// parse runs a fetched page through a parse worker and, if it is handed
+// on, an enrich worker
+func parse(w *Worker, job *parseJob) {
+	w.parsePage(job)
+	select {
+	case job := <-w.enrichJobs:
+		w.settlePage(job)
+		w.inFlight.Add(-1)
+	default:
+	}
+}
+
+// stubClassifier answers every page with one class or error
+type stubClassifier struct {
+	class     classify.Class
+	err       error
+	pages     int
+	heuristic classify.Class // Heuristic of the last page
+}
+
+func (s *stubClassifier) Classify(ctx context.Context, page *classify.Page) (classify.Class, error) {
+	s.pages++
+	s.heuristic = page.Heuristic
+	return s.class, s.err
+}
+
+func TestWorkerClassifier(t *testing.T) {
+	newJob := func(html string) *parseJob {
+		return &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:admin"},
+			prx:  &proxy.Proxy{ID: "p1"},
+			html: html,
+		}
+	}
+	const ambiguous = `<html><body><div id="main">nothing the parser knows</div></body></html>`
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.MaxRetries = 0
+
+	t.Run("empty", func(t *testing.T) {
+		w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+		stub := &stubClassifier{class: classify.ClassEmpty}
+		w.SetClassifier(stub)
+
+		parse(w, newJob(ambiguous))
+		if result := <-w.results; result.Status != StatusNoResults {
+			t.Errorf("status = %s, want %s", result.Status, StatusNoResults)
+		}
+		if w.Stats().ClassifiedPages != 1 {
+			t.Errorf("ClassifiedPages = %d, want 1", w.Stats().ClassifiedPages)
+		}
+		if stub.heuristic != classify.ClassUnknown {
+			t.Errorf("heuristic = %q, want %q", stub.heuristic, classify.ClassUnknown)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+		w.SetClassifier(&stubClassifier{class: classify.ClassBlock})
+
+		parse(w, newJob(ambiguous))
+		if result := <-w.results; result.Status != StatusBlocked {
+			t.Errorf("status = %s, want %s", result.Status, StatusBlocked)
+		}
+		if w.Stats().BlockCount != 1 {
+			t.Errorf("BlockCount = %d, want 1", w.Stats().BlockCount)
+		}
+	})
+
+	t.Run("layout change", func(t *testing.T) {
+		w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+		w.SetClassifier(&stubClassifier{class: classify.ClassLayoutChange})
+
+		parse(w, newJob(ambiguous))
+		if result := <-w.results; result.Status != StatusSuccess {
+			t.Errorf("status = %s, want %s", result.Status, StatusSuccess)
+		}
+		if w.Stats().LayoutChanges != 1 {
+			t.Errorf("LayoutChanges = %d, want 1", w.Stats().LayoutChanges)
+		}
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+		w.SetClassifier(&stubClassifier{err: errors.New("endpoint down")})
+
+		// The heuristic verdict stands when the classifier fails
+		parse(w, newJob(ambiguous))
+		if result := <-w.results; result.Status != StatusSuccess {
+			t.Errorf("status = %s, want %s", result.Status, StatusSuccess)
+		}
+	})
+
+	t.Run("not ambiguous", func(t *testing.T) {
+		w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+		stub := &stubClassifier{class: classify.ClassBlock}
+		w.SetClassifier(stub)
+
+		parse(w, newJob(`<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`))
+		<-w.results
+		if stub.pages != 0 {
+			t.Error("a page with results should not be classified")
+		}
+	})
+}
+
+func TestWorkerMinConfidence(t *testing.T) {
+	const html = `
+	<a href="/url?q=https://result.example/admin&amp;sa=U">Result</a>
+	<div data-href="https://nav.example/menu"></div>
+	`
+	newJob := func(min float64) *parseJob {
+		return &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:admin", MinConfidence: min},
+			prx:  &proxy.Proxy{ID: "p1"},
+			html: html,
+		}
+	}
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.MinConfidence = 0.5
+
+	tests := []struct {
+		name string
+		min  float64
+		want int
+	}{
+		{"config default", 0, 1},
+		{"task override", 0.1, 2},
+		{"task stricter", 0.99, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+			parse(w, newJob(tt.min))
+			result := <-w.results
+			if result.Status != StatusSuccess {
+				t.Errorf("status = %s, want %s", result.Status, StatusSuccess)
+			}
+			if len(result.URLs) != tt.want {
+				t.Errorf("got %d URLs, want %d", len(result.URLs), tt.want)
+			}
+			if dropped := w.Stats().LowConfidenceURLs; dropped != int64(2-tt.want) {
+				t.Errorf("LowConfidenceURLs = %d, want %d", dropped, 2-tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerScope(t *testing.T) {
+	const html = `
+	<a href="/url?q=https://www.target.com/admin&amp;sa=U">In scope</a>
+	<a href="/url?q=https://other.example/admin&amp;sa=U">Out of scope</a>
+	<a href="/url?q=https://api.target.com/v1&amp;sa=U">In scope</a>
+	`
+	newJob := func(scope []string) *parseJob {
+		return &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:admin", Scope: scope},
+			prx:  &proxy.Proxy{ID: "p1"},
+			html: html,
+		}
+	}
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Scope = []string{"*.target.com"}
+
+	tests := []struct {
+		name       string
+		scope      []string
+		want       int
+		outOfScope int
+	}{
+		{"config default", nil, 2, 1},
+		{"task override", []string{"other.example"}, 1, 2},
+		{"task wider", []string{"*.target.com", "other.example"}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+			parse(w, newJob(tt.scope))
+			result := <-w.results
+			if len(result.URLs) != tt.want {
+				t.Errorf("got %d URLs, want %d", len(result.URLs), tt.want)
+			}
+			if result.OutOfScope != tt.outOfScope {
+				t.Errorf("OutOfScope = %d, want %d", result.OutOfScope, tt.outOfScope)
+			}
+			if got := w.Stats().OutOfScopeURLs; got != int64(tt.outOfScope) {
+				t.Errorf("OutOfScopeURLs = %d, want %d", got, tt.outOfScope)
+			}
+		})
+	}
+}
+
+func TestWorkerParamHarvester(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Dedup.Granularity = dedup.GranularityDomain
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	set, _ := dedup.New(dedup.Config{Mode: dedup.ModeExact})
+	w.SetDedup(set)
+	harvester := params.NewHarvester()
+	w.SetParamHarvester(harvester)
+
+	parse(w, &parseJob{
+		task: &Task{ID: "t1", Dork: "inurl:id="},
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: `<a href="/url?q=https://a.example/item.php%3Fid%3D1&amp;sa=U">A</a>
+		<a href="/url?q=https://a.example/list.php%3Fcat%3D2&amp;sa=U">B</a>`,
+	})
+	<-w.results
+
+	// The second URL is a duplicate at domain granularity, but its
+	// parameter still counts
+	if names := harvester.Hosts()["a.example"]; len(names) != 2 {
+		t.Errorf("names = %v, want [cat id]", names)
+	}
+}
+
+func TestWorkerResolveRedirects(t *testing.T) {
+	var requests atomic.Int64
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		switch r.Host {
+		case "bit.ly":
+			http.Redirect(w, r, "http://www.target.com/admin", http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Scope = []string{"*.target.com"}
+	config.Resolve = resolve.Config{Enabled: true}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+	w := New(config, pool)
+
+	newJob := func() *parseJob {
+		return &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:admin"},
+			prx:  prx,
+			html: `<a href="/url?q=http://bit.ly/abc&amp;sa=U">Short</a>
+			<a href="/url?q=https://other.example/page&amp;sa=U">Other</a>`,
+		}
+	}
+
+	// The short link resolves into scope; the other URL is never requested
+	parse(w, newJob())
+	result := <-w.results
+	if len(result.URLs) != 1 {
+		t.Fatalf("got %d URLs, want 1: %v", len(result.URLs), result.URLs)
+	}
+	if got := result.URLs[0]; got.URL != "http://bit.ly/abc" || got.FinalURL != "http://www.target.com/admin" {
+		t.Errorf("result = %+v", got)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+
+	// A repeat is resolved from the cache
+	parse(w, newJob())
+	<-w.results
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests after repeat = %d, want 2", n)
+	}
+	if got := w.Stats().ResolvedURLs; got != 2 {
+		t.Errorf("ResolvedURLs = %d, want 2", got)
+	}
+}
+
+func TestWorkerStepRequests(t *testing.T) {
+	var requests atomic.Int64
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Host == "bit.ly" {
+			http.Redirect(w, r, "http://www.target.com/admin", http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("audit.Open failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Resolve = resolve.Config{Enabled: true}
+	config.EngineMaxRequests = map[string]int{stepResolve: 2}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+	w := New(config, pool)
+	w.SetAuditLog(log)
+
+	newJob := func(link string) *parseJob {
+		return &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:admin"},
+			prx:  prx,
+			html: `<a href="/url?q=` + link + `&amp;sa=U">Short</a>`,
+		}
+	}
+
+	// Both hops of the redirect are budgeted and audited under the step
+	parse(w, newJob("http://bit.ly/abc"))
+	if result := <-w.results; result.URLs[0].FinalURL != "http://www.target.com/admin" {
+		t.Errorf("result = %+v", result.URLs[0])
+	}
+	if n := w.Stats().Requests; n != 2 {
+		t.Errorf("Requests = %d, want 2", n)
+	}
+
+	// With the step's budget spent, the next link goes out unresolved
+	parse(w, newJob("http://bit.ly/def"))
+	if result := <-w.results; result.URLs[0].FinalURL != "" {
+		t.Errorf("resolved past the budget: %+v", result.URLs[0])
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	// The pages' own search records follow the hops
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("audit lines = %d, want 4:\n%s", len(lines), data)
+	}
+	var hop, search audit.Record
+	json.Unmarshal([]byte(lines[0]), &hop)
+	json.Unmarshal([]byte(lines[3]), &search)
+	if search.Engine != "google" {
+		t.Errorf("last record = %+v, want the second page's search", search)
+	}
+	if hop.Engine != stepResolve || hop.TaskID != "t1" || hop.Domain != "bit.ly" || hop.URL != "http://bit.ly/abc" ||
+		hop.Proxy != prx.ID || hop.StatusCode != http.StatusMovedPermanently || hop.Outcome != string(StatusSuccess) {
+		t.Errorf("hop record = %+v", hop)
+	}
+}
+
+func TestWorkerCrawlListings(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "http://a.example/files/":
+			fmt.Fprint(w, `<title>Index of /files</title><a href="../">Parent</a><a href="dump.sql">dump.sql</a><a href="keys/">keys/</a>`)
+		case "http://a.example/files/keys/":
+			fmt.Fprint(w, `<title>Index of /files/keys</title><a href="id_rsa">id_rsa</a>`)
+		default:
+			fmt.Fprint(w, `<title>Welcome</title>`)
+		}
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Crawl = dirlist.Config{Enabled: true}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+	w := New(config, pool)
+
+	parse(w, &parseJob{
+		task: &Task{ID: "t1", Dork: `intitle:"index of"`},
+		prx:  prx,
+		html: `
+		<div class="g"><a href="/url?q=http://a.example/files/&amp;sa=U"><h3>Index of /files</h3></a></div>
+		<div class="g"><a href="/url?q=http://b.example/&amp;sa=U"><h3>Index of /</h3></a></div>
+		<div class="g"><a href="/url?q=http://c.example/&amp;sa=U"><h3>Welcome</h3></a></div>`,
+	})
+	result := <-w.results
+	if len(result.URLs) != 3 {
+		t.Fatalf("got %d URLs, want 3: %+v", len(result.URLs), result.URLs)
+	}
+
+	want := []string{"http://a.example/files/dump.sql", "http://a.example/files/keys/id_rsa"}
+	if got := result.URLs[0].Files; !reflect.DeepEqual(got, want) {
+		t.Errorf("listing files = %v, want %v", got, want)
+	}
+	// A title that promised a listing the page is not has no files
+	for _, u := range result.URLs[1:] {
+		if len(u.Files) != 0 {
+			t.Errorf("%s: files = %v, want none", u.URL, u.Files)
+		}
+	}
+	if got := w.Stats().ListingFiles; got != 2 {
+		t.Errorf("ListingFiles = %d, want 2", got)
+	}
+}
+
+func TestWorkerVerify(t *testing.T) {
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Host {
+		case "a.example":
+			fmt.Fprint(w, `<title>Error</title><b>Warning</b>: You have an error in your SQL syntax near ''`)
+		case "b.example":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<h1>phpMyAdmin</h1>`)
+		default:
+			fmt.Fprint(w, `<title>Welcome</title>`)
+		}
+	})
+	defer server.Close()
+
+	const html = `
+	<div class="g"><a href="/url?q=http://a.example/item.php%3Fid%3D1&amp;sa=U"><h3>Item</h3></a></div>
+	<div class="g"><a href="/url?q=http://b.example/&amp;sa=U"><h3>Admin</h3></a></div>
+	<div class="g"><a href="/url?q=http://c.example/&amp;sa=U"><h3>Welcome</h3></a></div>`
+
+	for _, require := range []bool{false, true} {
+		config := DefaultConfig()
+		config.Workers = 0
+		config.Verify = verify.Config{Patterns: []string{"sql syntax", "phpMyAdmin"}, Require: require}
+		pool := proxy.NewPool(proxy.DefaultPoolConfig())
+		pool.AddProxy(prx)
+		w := New(config, pool)
+
+		parse(w, &parseJob{
+			task: &Task{ID: "t1", Dork: "inurl:id="},
+			prx:  prx,
+			html: html,
+		})
+		result := <-w.results
+
+		want := 3
+		if require {
+			want = 2
+		}
+		if len(result.URLs) != want {
+			t.Fatalf("require=%v: got %d URLs, want %d: %+v", require, len(result.URLs), want, result.URLs)
+		}
+		if m := result.URLs[0].Matches; len(m) != 1 || m[0].Pattern != "sql syntax" {
+			t.Errorf("require=%v: %s matches = %+v", require, result.URLs[0].URL, m)
+		}
+		if m := result.URLs[1].Matches; len(m) != 1 || m[0].Pattern != "phpMyAdmin" {
+			t.Errorf("require=%v: %s matches = %+v", require, result.URLs[1].URL, m)
+		}
+		if !require && len(result.URLs[2].Matches) != 0 {
+			t.Errorf("unmatched page has matches %+v", result.URLs[2].Matches)
+		}
+
+		stats := w.Stats()
+		if stats.VerifiedURLs != 2 || stats.UnverifiedURLs != int64(3-want) {
+			t.Errorf("require=%v: verified %d, unverified %d", require, stats.VerifiedURLs, stats.UnverifiedURLs)
+		}
+	}
+}
+
+func TestWorkerFingerprintHosts(t *testing.T) {
+	var rootFetches int
+	server, prx := newTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "http://a.example/":
+			rootFetches++
+			fmt.Fprint(w, `<title>Grafana</title><link rel="icon" href="/public/fav32.png">`)
+		case "http://a.example/public/fav32.png":
+			fmt.Fprint(w, "icon")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Fingerprint = fingerprint.Config{Enabled: true}
+	pool := proxy.NewPool(proxy.DefaultPoolConfig())
+	pool.AddProxy(prx)
+	w := New(config, pool)
+
+	parse(w, &parseJob{
+		task: &Task{ID: "t1", Dork: `intitle:"grafana"`},
+		prx:  prx,
+		html: `
+		<div class="g"><a href="/url?q=http://a.example/login&amp;sa=U"><h3>Grafana</h3></a></div>
+		<div class="g"><a href="/url?q=http://a.example/d/home&amp;sa=U"><h3>Home</h3></a></div>
+		<div class="g"><a href="/url?q=http://b.example/&amp;sa=U"><h3>Gone</h3></a></div>`,
+	})
+	result := <-w.results
+	if len(result.URLs) != 3 {
+		t.Fatalf("got %d URLs, want 3: %+v", len(result.URLs), result.URLs)
+	}
+
+	want := &engine.Fingerprint{Title: "Grafana", FaviconHash: fingerprint.Hash([]byte("icon"))}
+	for _, u := range result.URLs[:2] {
+		if !reflect.DeepEqual(u.Fingerprint, want) {
+			t.Errorf("%s: fingerprint = %+v, want %+v", u.URL, u.Fingerprint, want)
+		}
+	}
+	if result.URLs[2].Fingerprint != nil {
+		t.Errorf("unreachable host has fingerprint %+v", result.URLs[2].Fingerprint)
+	}
+	// Each host is fingerprinted once
+	if rootFetches != 1 {
+		t.Errorf("root page fetched %d times, want 1", rootFetches)
+	}
+	if got := w.Stats().HostFingerprints; got != 1 {
+		t.Errorf("HostFingerprints = %d, want 1", got)
+	}
+	if clusters := w.HostClusters(); len(clusters) != 1 || clusters[0].Hosts[0] != "http://a.example" {
+		t.Errorf("clusters = %+v", clusters)
+	}
+}
+
+func TestWorkerTitleFilters(t *testing.T) {
+	const html = `
+	<div class="g"><a href="/url?q=https://a.example/files/&amp;sa=U"><h3>Index of /files</h3></a></div>
+	<div class="g"><a href="/url?q=https://b.example/missing/&amp;sa=U"><h3>Index of /missing - 404 Not Found</h3></a></div>
+	<div class="g"><a href="/url?q=https://c.example/&amp;sa=U"><h3>Welcome</h3></a></div>
+	`
+	config := DefaultConfig()
+	config.Workers = 0
+	config.TitleExclude = []string{"404"}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    int
+	}{
+		{"config default", nil, nil, 2},
+		{"task include", []string{"INDEX OF"}, nil, 2},
+		{"task include and exclude", []string{"index of"}, []string{"not found"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+			parse(w, &parseJob{
+				task: &Task{ID: "t1", Dork: "intitle:\"index of\"", TitleInclude: tt.include, TitleExclude: tt.exclude},
+				prx:  &proxy.Proxy{ID: "p1"},
+				html: html,
+			})
+			result := <-w.results
+			if len(result.URLs) != tt.want {
+				t.Errorf("got %d URLs, want %d: %+v", len(result.URLs), tt.want, result.URLs)
+			}
+			if got := w.Stats().TitleFilteredURLs; got != int64(3-tt.want) {
+				t.Errorf("TitleFilteredURLs = %d, want %d", got, 3-tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerLanguageFilter(t *testing.T) {
+	const html = `
+	<div class="g"><a href="/url?q=https://a.example/&amp;sa=U"><h3>Cómo configurar el panel de administración</h3></a></div>
+	<div class="g"><a href="/url?q=https://b.example/&amp;sa=U"><h3>How to configure the admin panel</h3></a></div>
+	<div class="g"><a href="/url?q=https://c.example/&amp;sa=U"><h3>phpMyAdmin</h3></a></div>
+	`
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Languages = []string{"es-ES"}
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+
+	parse(w, &parseJob{
+		task: &Task{ID: "t1", Dork: "inurl:admin"},
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: html,
+	})
+	result := <-w.results
+
+	// The English result goes; the one that cannot be told stays
+	if len(result.URLs) != 2 {
+		t.Fatalf("got %d URLs, want 2: %+v", len(result.URLs), result.URLs)
+	}
+	if result.URLs[0].Language != "es" || result.URLs[1].Language != "" {
+		t.Errorf("languages = %q, %q", result.URLs[0].Language, result.URLs[1].Language)
+	}
+	if got := w.Stats().OffLanguageURLs; got != 1 {
+		t.Errorf("OffLanguageURLs = %d, want 1", got)
+	}
+
+	// A task's own list replaces the configured one
+	parse(w, &parseJob{
+		task: &Task{ID: "t2", Dork: "inurl:admin", Languages: []string{"en"}},
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: html,
+	})
+	if result := <-w.results; len(result.URLs) != 2 || result.URLs[0].URL != "https://b.example/" {
+		t.Errorf("task override kept %+v", result.URLs)
+	}
+}
+
+func TestWorkerCache(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.Cache = cache.Config{TTL: time.Minute}
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	pageCache, err := cache.New(config.Cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetCache(pageCache)
+
+	parse(w, &parseJob{
+		task: &Task{ID: "t1", Dork: "inurl:admin"},
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: `<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`,
+	})
+	if result := <-w.results; result.Cached {
+		t.Error("fetched page reported as cached")
+	}
+
+	// The pool is empty, so only the cache can answer
+	w.processTask(0, &Task{ID: "t2", Dork: "inurl:admin"})
+	result := <-w.results
+	if !result.Cached || result.Status != StatusSuccess || len(result.URLs) != 1 || result.ProxyID != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	if stats := w.Stats(); stats.CacheHits != 1 || stats.Requests != 0 {
+		t.Errorf("CacheHits = %d, Requests = %d, want 1 and 0", stats.CacheHits, stats.Requests)
+	}
+
+	// Another page of the dork is a different search
+	w.processTask(0, &Task{ID: "t3", Dork: "inurl:admin", Page: 1})
+	if result := <-w.results; result.Cached {
+		t.Error("second page served from the first page's cache entry")
+	}
+}
+
+func TestWorkerAbsolutePositions(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.ResultsPerPage = 10
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	set, _ := dedup.New(dedup.Config{Mode: dedup.ModeExact})
+	w.SetDedup(set)
+
+	html := `<a href="/url?q=https://a.example/&amp;sa=U">A</a>
+<a href="/url?q=https://b.example/&amp;sa=U">B</a>
+<a href="/url?q=https://c.example/&amp;sa=U">C</a>`
+
+	parse(w, &parseJob{task: &Task{ID: "t1", Dork: "inurl:admin"}, prx: &proxy.Proxy{ID: "p1"}, html: `<a href="/url?q=https://b.example/&amp;sa=U">B</a>`})
+	<-w.results
+
+	// b.example is a duplicate; the others keep their place on the third page
+	parse(w, &parseJob{task: &Task{ID: "t2", Dork: "inurl:admin", Page: 2}, prx: &proxy.Proxy{ID: "p1"}, html: html})
+	result := <-w.results
+	if len(result.URLs) != 2 || result.URLs[0].Position != 21 || result.URLs[1].Position != 23 {
+		t.Fatalf("URLs = %+v, want positions 21 and 23", result.URLs)
+	}
+}
+
+func TestTaskKey(t *testing.T) {
+	a := &Task{ID: "1", Dork: "inurl:admin", Retry: 2, Deadline: time.Now()}
+	b := &Task{ID: "2", Type: TaskTypeSearch, Dork: "inurl:admin"}
+	if a.Key() != b.Key() {
+		t.Error("copies of a task have different keys")
+	}
+	if a.Key() == (&Task{Dork: "inurl:admin", Page: 1}).Key() {
+		t.Error("different pages share a key")
+	}
+	if key := (&Task{Dork: "inurl:admin", IdempotencyKey: "k1"}).Key(); key != "k1" {
+		t.Errorf("Key = %q, want the given key", key)
+	}
+}
+
+func TestWorkerIdempotency(t *testing.T) {
+	config := DefaultConfig()
+	config.Workers = 0
+	config.IdempotencyWindow = time.Minute
+	w := New(config, proxy.NewPool(proxy.DefaultPoolConfig()))
+	w.running.Store(true)
+
+	// A task without an ID gets one from its content
+	first := &Task{Dork: "inurl:admin"}
+	if err := w.Submit(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != "task_"+first.Key() {
+		t.Errorf("ID = %q", first.ID)
+	}
+
+	// A copy resent while the first runs waits for its result
+	if err := w.Submit(&Task{ID: "resent", Dork: "inurl:admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.tasks) != 1 {
+		t.Fatalf("queued %d tasks, want 1", len(w.tasks))
+	}
+
+	parse(w, &parseJob{
+		task: <-w.tasks,
+		prx:  &proxy.Proxy{ID: "p1"},
+		html: `<a href="/url?q=https://a.example/admin&amp;sa=U">A</a>`,
+	})
+	if result := <-w.results; result.TaskID != first.ID || result.Duplicate {
+		t.Errorf("first result = %+v", result)
+	}
+	if result := <-w.results; result.TaskID != "resent" || !result.Duplicate || len(result.URLs) != 1 {
+		t.Errorf("waiting copy's result = %+v", result)
+	}
+
+	// A copy resent afterwards is answered at once
+	w.Submit(&Task{ID: "late", Dork: "inurl:admin"})
+	if result := <-w.results; result.TaskID != "late" || !result.Duplicate {
+		t.Errorf("late copy's result = %+v", result)
+	}
+	if len(w.tasks) != 0 {
+		t.Error("late copy was queued")
+	}
+	if got := w.Stats().DuplicateTasks; got != 2 {
+		t.Errorf("DuplicateTasks = %d, want 2", got)
+	}
+
+	// After a failure a copy runs again
+	failed := &Task{ID: "f1", Dork: "inurl:login"}
+	w.Submit(failed)
+	<-w.tasks
+	w.sendResult(&Result{TaskID: "f1", Status: StatusError})
+	<-w.results
+	w.Submit(&Task{ID: "f2", Dork: "inurl:login"})
+	if len(w.tasks) != 1 {
+		t.Error("copy of a failed task was not run")
+	}
+}